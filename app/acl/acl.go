@@ -0,0 +1,371 @@
+package acl
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/genzai-io/sliced/common/match"
+)
+
+var (
+	ErrWrongPass     = errors.New("WRONGPASS invalid username-password pair or user is disabled.")
+	ErrNoAuth        = errors.New("NOAUTH Authentication required.")
+	ErrNoUser        = errors.New("ERR no such user")
+	ErrDefaultUser   = errors.New("ERR The 'default' user cannot be removed")
+	ErrNoPermKey     = errors.New("NOPERM this user has no permissions to access one of the keys used as arguments")
+	ErrNoPermChannel = errors.New("NOPERM this user has no permissions to access one of the channels used as arguments")
+	ErrUsersSnapshot = errors.New("invalid users snapshot")
+	ErrLocalUser     = errors.New("ERR the user is set by the acl.users setting of a node")
+	ErrLocalUsers    = errors.New("acl.users lines must be a username followed by rules")
+)
+
+// Name of the user that connections use before they AUTH.
+const DefaultUser = "default"
+
+// Categories of commands a user may run.
+type Category uint8
+
+const (
+	// Commands that only read keys, channels or schemas
+	Read Category = 1 << iota
+	// Commands that change keys or publish
+	Write
+	// Database, schema, script and user management
+	Admin
+	// Raft protocol and cluster membership
+	Raft
+
+	All = Read | Write | Admin | Raft
+)
+
+var categories = []struct {
+	name     string
+	category Category
+}{
+	{"read", Read},
+	{"write", Write},
+	{"admin", Admin},
+	{"raft", Raft},
+}
+
+// Parses a category name. "all" is every category.
+func ParseCategory(name string) (Category, bool) {
+	if strings.EqualFold(name, "all") {
+		return All, true
+	}
+	for _, c := range categories {
+		if strings.EqualFold(name, c.name) {
+			return c.category, true
+		}
+	}
+	return 0, false
+}
+
+// Names of every category.
+func Categories() []string {
+	names := make([]string, len(categories))
+	for i, c := range categories {
+		names[i] = c.name
+	}
+	return names
+}
+
+func (c Category) String() string {
+	var names []string
+	for _, cat := range categories {
+		if c&cat.category != 0 {
+			names = append(names, cat.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// Error for a command the user is not allowed to run.
+func NoPermCommand(name string) error {
+	return fmt.Errorf("NOPERM this user has no permissions to run the '%s' command", strings.ToLower(name))
+}
+
+// Hex encoded SHA-256 of a password. Only hashes are kept so snapshots and
+// the cluster log never contain passwords.
+func HashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+// A user and it's permissions. Stored users are never modified so they
+// may be read without holding a lock.
+type User struct {
+	Name    string
+	Enabled bool
+	// Any password is accepted
+	NoPass bool
+	// SHA-256 hashes of the accepted passwords
+	Passwords  []string
+	Categories Category
+	// Glob patterns of the keys the user may access
+	Keys []string
+	// Glob patterns of the pub/sub channels and topics the user may access
+	Channels []string
+}
+
+// A new user is disabled and may not run any command until rules are set.
+func NewUser(name string) *User {
+	return &User{Name: name}
+}
+
+func (u *User) Clone() *User {
+	clone := *u
+	clone.Passwords = append([]string(nil), u.Passwords...)
+	clone.Keys = append([]string(nil), u.Keys...)
+	clone.Channels = append([]string(nil), u.Channels...)
+	return &clone
+}
+
+// Applies rules in the ACL SETUSER syntax.
+//
+//	on, off              enable or disable the user
+//	>password, <password add or remove a password
+//	#hash, !hash         add or remove a SHA-256 password hash
+//	nopass, resetpass    accept any password or forget every password
+//	+@category, -@category
+//	allcommands, nocommands
+//	~pattern, allkeys, resetkeys
+//	&pattern, allchannels, resetchannels
+//	reset                back to a new user
+func (u *User) SetRules(rules ...string) error {
+	for _, rule := range rules {
+		if err := u.setRule(rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *User) setRule(rule string) error {
+	switch strings.ToLower(rule) {
+	case "on":
+		u.Enabled = true
+		return nil
+	case "off":
+		u.Enabled = false
+		return nil
+	case "nopass":
+		u.NoPass = true
+		u.Passwords = nil
+		return nil
+	case "resetpass":
+		u.NoPass = false
+		u.Passwords = nil
+		return nil
+	case "allcommands":
+		u.Categories = All
+		return nil
+	case "nocommands":
+		u.Categories = 0
+		return nil
+	case "allkeys":
+		u.Keys = []string{"*"}
+		return nil
+	case "resetkeys":
+		u.Keys = nil
+		return nil
+	case "allchannels":
+		u.Channels = []string{"*"}
+		return nil
+	case "resetchannels":
+		u.Channels = nil
+		return nil
+	case "reset":
+		*u = User{Name: u.Name}
+		return nil
+	}
+
+	if len(rule) < 2 {
+		return ruleError(rule)
+	}
+	value := rule[1:]
+	switch rule[0] {
+	case '>':
+		u.addPassword(HashPassword(value))
+	case '<':
+		u.removePassword(HashPassword(value))
+	case '#':
+		if _, err := hex.DecodeString(value); err != nil || len(value) != sha256.Size*2 {
+			return ruleError(rule)
+		}
+		u.addPassword(strings.ToLower(value))
+	case '!':
+		u.removePassword(strings.ToLower(value))
+	case '~':
+		u.Keys = addPattern(u.Keys, value)
+	case '&':
+		u.Channels = addPattern(u.Channels, value)
+	case '+', '-':
+		if value[0] != '@' {
+			return ruleError(rule)
+		}
+		category, ok := ParseCategory(value[1:])
+		if !ok {
+			return ruleError(rule)
+		}
+		if rule[0] == '+' {
+			u.Categories |= category
+		} else {
+			u.Categories &^= category
+		}
+	default:
+		return ruleError(rule)
+	}
+	return nil
+}
+
+// Replaces the passwords in rules with their hashes so they can be written
+// to the cluster log.
+func HashRules(rules []string) []string {
+	hashed := make([]string, len(rules))
+	for i, rule := range rules {
+		switch {
+		case strings.HasPrefix(rule, ">"):
+			hashed[i] = "#" + HashPassword(rule[1:])
+		case strings.HasPrefix(rule, "<"):
+			hashed[i] = "!" + HashPassword(rule[1:])
+		default:
+			hashed[i] = rule
+		}
+	}
+	return hashed
+}
+
+func ruleError(rule string) error {
+	return fmt.Errorf("ERR Error in ACL SETUSER modifier '%s': Syntax error", rule)
+}
+
+func (u *User) addPassword(hash string) {
+	u.NoPass = false
+	for _, existing := range u.Passwords {
+		if existing == hash {
+			return
+		}
+	}
+	u.Passwords = append(u.Passwords, hash)
+}
+
+func (u *User) removePassword(hash string) {
+	for i, existing := range u.Passwords {
+		if existing == hash {
+			u.Passwords = append(u.Passwords[:i:i], u.Passwords[i+1:]...)
+			return
+		}
+	}
+}
+
+func addPattern(patterns []string, pattern string) []string {
+	for _, existing := range patterns {
+		if existing == pattern || existing == "*" {
+			return patterns
+		}
+	}
+	return append(patterns, pattern)
+}
+
+// Rules that recreate the user when applied to a new user.
+func (u *User) Rules() []string {
+	var rules []string
+	if u.Enabled {
+		rules = append(rules, "on")
+	} else {
+		rules = append(rules, "off")
+	}
+	if u.NoPass {
+		rules = append(rules, "nopass")
+	}
+	for _, hash := range u.Passwords {
+		rules = append(rules, "#"+hash)
+	}
+	for _, pattern := range u.Keys {
+		rules = append(rules, "~"+pattern)
+	}
+	for _, pattern := range u.Channels {
+		rules = append(rules, "&"+pattern)
+	}
+	if u.Categories == All {
+		rules = append(rules, "+@all")
+	} else {
+		for _, c := range categories {
+			if u.Categories&c.category != 0 {
+				rules = append(rules, "+@"+c.name)
+			}
+		}
+	}
+	return rules
+}
+
+// Whether the password is accepted. Disabled users accept none.
+func (u *User) CheckPassword(password string) bool {
+	if !u.Enabled {
+		return false
+	}
+	if u.NoPass {
+		return true
+	}
+	hash := []byte(HashPassword(password))
+	for _, existing := range u.Passwords {
+		if subtle.ConstantTimeCompare(hash, []byte(existing)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the user may run commands of the category.
+func (u *User) Can(category Category) bool {
+	return u.Categories&category == category
+}
+
+// Whether the user may access the key.
+func (u *User) CanKey(key string) bool {
+	return matchAny(u.Keys, key)
+}
+
+// Whether the user may access the channel or topic.
+func (u *User) CanChannel(channel string) bool {
+	return matchAny(u.Channels, channel)
+}
+
+// Whether the user may subscribe to a channel pattern. Patterns must be
+// allowed as is since a narrower pattern cannot be proven to match less.
+func (u *User) CanPattern(pattern string) bool {
+	for _, p := range u.Channels {
+		if p == "*" || p == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the user may access every key and channel.
+func (u *User) Unrestricted() bool {
+	return u.Categories == All && u.CanPattern("*") && hasAll(u.Keys)
+}
+
+func hasAll(patterns []string) bool {
+	for _, p := range patterns {
+		if p == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, p := range patterns {
+		if p == "*" || match.Match(value, p) {
+			return true
+		}
+	}
+	return false
+}
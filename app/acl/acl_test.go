@@ -0,0 +1,204 @@
+package acl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUserRules(t *testing.T) {
+	user := NewUser("alice")
+	if err := user.SetRules("on", ">secret", "~cache:*", "&news.*", "+@read", "+@write"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !user.CheckPassword("secret") || user.CheckPassword("wrong") {
+		t.Fatal("expected only the password to be accepted")
+	}
+	if !user.Can(Read) || !user.Can(Write) || user.Can(Admin) || user.Can(Raft) {
+		t.Fatalf("unexpected categories %s", user.Categories)
+	}
+	if !user.CanKey("cache:a") || user.CanKey("session:a") {
+		t.Fatal("expected only cache keys")
+	}
+	if !user.CanChannel("news.sport") || user.CanChannel("chat") {
+		t.Fatal("expected only news channels")
+	}
+	if !user.CanPattern("news.*") || user.CanPattern("*") {
+		t.Fatal("expected only literal patterns")
+	}
+	if user.Unrestricted() {
+		t.Fatal("expected a restricted user")
+	}
+
+	// The rules recreate the same user
+	clone := NewUser("alice")
+	if err := clone.SetRules(user.Rules()...); err != nil {
+		t.Fatal(err)
+	}
+	if !clone.CheckPassword("secret") || !clone.CanKey("cache:a") || clone.Categories != user.Categories {
+		t.Fatal("expected the rules to recreate the user")
+	}
+
+	if err := user.SetRules("off"); err != nil {
+		t.Fatal(err)
+	}
+	if user.CheckPassword("secret") {
+		t.Fatal("expected disabled users to accept no password")
+	}
+	if err := user.SetRules("+@unknown"); err == nil {
+		t.Fatal("expected an unknown category to fail")
+	}
+}
+
+func TestHashRules(t *testing.T) {
+	rules := HashRules([]string{">secret", "<old", "~*"})
+	if rules[0] != "#"+HashPassword("secret") || rules[1] != "!"+HashPassword("old") || rules[2] != "~*" {
+		t.Fatalf("unexpected rules %v", rules)
+	}
+
+	user := NewUser("bob")
+	if err := user.SetRules(append([]string{"on"}, rules...)...); err != nil {
+		t.Fatal(err)
+	}
+	if !user.CheckPassword("secret") {
+		t.Fatal("expected the hashed password to be accepted")
+	}
+}
+
+func TestUsers(t *testing.T) {
+	users := NewUsers()
+	if _, err := users.Authenticate(DefaultUser, "anything"); err != nil {
+		t.Fatalf("expected the default user to need no password: %v", err)
+	}
+	if err := users.SetUser("alice", "on", ">secret", "+@read", "~*"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Authenticate("alice", "wrong"); err != ErrWrongPass {
+		t.Fatalf("expected ErrWrongPass got %v", err)
+	}
+
+	restored := NewUsers()
+	if err := restored.Unmarshal(users.Marshal(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if names := restored.Names(); len(names) != 2 || names[0] != "alice" || names[1] != DefaultUser {
+		t.Fatalf("unexpected users %v", names)
+	}
+	if _, err := restored.Authenticate("alice", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := users.DelUser(DefaultUser); err != ErrDefaultUser {
+		t.Fatalf("expected ErrDefaultUser got %v", err)
+	}
+	if removed, err := users.DelUser("alice", "nobody"); err != nil || removed != 1 {
+		t.Fatalf("expected 1 user removed got %d %v", removed, err)
+	}
+}
+
+func TestUsers_Local(t *testing.T) {
+	users := NewUsers()
+	if err := users.SetUser("alice", "on", ">cluster"); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.SetLocal([]string{"alice on >node", "ops on nopass"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Authenticate("alice", "node"); err != nil {
+		t.Fatalf("expected the node user to hide the cluster user: %v", err)
+	}
+	if names := users.Names(); len(names) != 3 {
+		t.Fatalf("unexpected users %v", names)
+	}
+	if !users.IsLocal("ops") || users.IsLocal(DefaultUser) {
+		t.Fatal("expected only ops and alice to be local")
+	}
+
+	// Snapshots keep only the users of the cluster
+	restored := NewUsers()
+	if err := restored.Unmarshal(users.Marshal(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restored.Authenticate("alice", "cluster"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := restored.Get("ops"); ok {
+		t.Fatal("expected the node user to stay out of the snapshot")
+	}
+
+	if err := users.SetLocal([]string{"bob"}); err != ErrLocalUsers {
+		t.Fatalf("expected ErrLocalUsers got %v", err)
+	}
+	if err := users.SetLocal(nil); err != nil || users.IsLocal("ops") {
+		t.Fatalf("expected the node users to be removed got %v", err)
+	}
+}
+
+// Store of users kept in memory.
+type memoryStore map[string][]byte
+
+func (s memoryStore) Users() ([]byte, error) {
+	var b []byte
+	for _, user := range s {
+		b = append(b, user...)
+	}
+	return b, nil
+}
+
+func (s memoryStore) SaveUser(name string, user []byte) error {
+	s[name] = user
+	return nil
+}
+
+func (s memoryStore) DeleteUser(name string) error {
+	delete(s, name)
+	return nil
+}
+
+func TestUsers_Store(t *testing.T) {
+	store := memoryStore{}
+	users := NewUsers()
+	if err := users.SetStore(store); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.SetUser("alice", "on", ">secret", "+@read"); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.SetUser("bob", "on", "nopass"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.DelUser("bob"); err != nil {
+		t.Fatal(err)
+	}
+	if len(store) != 1 || len(store["alice"]) == 0 {
+		t.Fatalf("expected alice to be saved got %q", store)
+	}
+
+	// Only the hash of the password is saved
+	restarted := NewUsers()
+	if err := restarted.SetStore(store); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restarted.Authenticate("alice", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(store["alice"]), "secret") {
+		t.Fatal("expected the password to be hashed")
+	}
+
+	// A snapshot replaces the saved users
+	snapshot := NewUsers()
+	snapshot.SetUser(DefaultUser, "resetpass", ">admin")
+	if err := restarted.Unmarshal(snapshot.Marshal(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store["alice"]; ok || len(store) != 1 {
+		t.Fatalf("expected only the default user to be saved got %q", store)
+	}
+	if err := restarted.SetStore(store); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restarted.Authenticate(DefaultUser, "admin"); err != nil {
+		t.Fatal(err)
+	}
+}
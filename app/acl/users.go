@@ -0,0 +1,286 @@
+package acl
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+// Users of the cluster. Changes are made through the cluster log so every
+// node has the same users and each node writes them to it's Store.
+var Default = NewUsers()
+
+// Durable copy of the users of the cluster. Every user is saved in the
+// format of Marshal with the rules holding only password hashes.
+type Store interface {
+	// Every saved user one after the other
+	Users() ([]byte, error)
+	SaveUser(name string, user []byte) error
+	DeleteUser(name string) error
+}
+
+type Users struct {
+	mu    sync.RWMutex
+	users map[string]*User
+	// Users of the acl.users setting of this node. They hide the users of
+	// the cluster with the same name and are neither replicated nor saved.
+	local map[string]*User
+	store Store
+}
+
+// Creates the users with only the default user. It needs no password and
+// may run every command so a cluster is open until it is locked down with
+// ACL SETUSER default.
+func NewUsers() *Users {
+	u := &Users{users: make(map[string]*User)}
+	u.reset()
+	return u
+}
+
+func (u *Users) reset() {
+	user := NewUser(DefaultUser)
+	user.SetRules("on", "nopass", "allkeys", "allchannels", "allcommands")
+	u.users = map[string]*User{DefaultUser: user}
+}
+
+func (u *Users) Get(name string) (*User, bool) {
+	u.mu.RLock()
+	user, ok := u.local[name]
+	if !ok {
+		user, ok = u.users[name]
+	}
+	u.mu.RUnlock()
+	return user, ok
+}
+
+// Parses "name rule ..." lines into users.
+func ParseLocalUsers(lines []string) (map[string]*User, error) {
+	users := make(map[string]*User, len(lines))
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, ErrLocalUsers
+		}
+		user := NewUser(fields[0])
+		if err := user.SetRules(fields[1:]...); err != nil {
+			return nil, err
+		}
+		users[user.Name] = user
+	}
+	return users, nil
+}
+
+// Replaces the users of this node with "name rule ..." lines.
+func (u *Users) SetLocal(lines []string) error {
+	users, err := ParseLocalUsers(lines)
+	if err != nil {
+		return err
+	}
+	u.mu.Lock()
+	u.local = users
+	u.mu.Unlock()
+	return nil
+}
+
+// Whether a user comes from the acl.users setting of this node.
+func (u *Users) IsLocal(name string) bool {
+	u.mu.RLock()
+	_, ok := u.local[name]
+	u.mu.RUnlock()
+	return ok
+}
+
+// Replaces the users with the users saved in store and saves every change
+// after to it. A nil store stops saving.
+func (u *Users) SetStore(store Store) error {
+	if store == nil {
+		u.mu.Lock()
+		u.store = nil
+		u.mu.Unlock()
+		return nil
+	}
+	saved, err := store.Users()
+	if err != nil {
+		return err
+	}
+	users, err := unmarshalUsers(saved)
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.reset()
+	for name, user := range users {
+		u.users[name] = user
+	}
+	u.store = store
+	return nil
+}
+
+// Creates or modifies a user. The rules are applied to the existing user
+// or a new user.
+func (u *Users) SetUser(name string, rules ...string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var user *User
+	if existing, ok := u.users[name]; ok {
+		user = existing.Clone()
+	} else {
+		user = NewUser(name)
+	}
+	if err := user.SetRules(rules...); err != nil {
+		return err
+	}
+	if u.store != nil {
+		if err := u.store.SaveUser(name, marshalUser(nil, user)); err != nil {
+			return err
+		}
+	}
+	u.users[name] = user
+	return nil
+}
+
+// Removes users and returns how many existed.
+func (u *Users) DelUser(names ...string) (int, error) {
+	for _, name := range names {
+		if name == DefaultUser {
+			return 0, ErrDefaultUser
+		}
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	removed := 0
+	for _, name := range names {
+		if _, ok := u.users[name]; !ok {
+			continue
+		}
+		if u.store != nil {
+			if err := u.store.DeleteUser(name); err != nil {
+				return removed, err
+			}
+		}
+		delete(u.users, name)
+		removed++
+	}
+	return removed, nil
+}
+
+// Sorted user names of the cluster and this node.
+func (u *Users) Names() []string {
+	u.mu.RLock()
+	names := make([]string, 0, len(u.users)+len(u.local))
+	for name := range u.users {
+		names = append(names, name)
+	}
+	for name := range u.local {
+		if _, ok := u.users[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	u.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// User for a username and password.
+func (u *Users) Authenticate(name, password string) (*User, error) {
+	user, ok := u.Get(name)
+	if !ok || !user.CheckPassword(password) {
+		return nil, ErrWrongPass
+	}
+	return user, nil
+}
+
+// Serializes every user of the cluster as a series of RESP arrays of the
+// name followed by the rules. This is used by Raft snapshots.
+func (u *Users) Marshal(b []byte) []byte {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	names := make([]string, 0, len(u.users))
+	for name := range u.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		b = marshalUser(b, u.users[name])
+	}
+	return b
+}
+
+func marshalUser(b []byte, user *User) []byte {
+	rules := user.Rules()
+	b = resp.AppendArray(b, 1+len(rules))
+	b = resp.AppendBulkString(b, user.Name)
+	for _, rule := range rules {
+		b = resp.AppendBulkString(b, rule)
+	}
+	return b
+}
+
+// Replaces every user with the contents of Marshal. The users are saved
+// to the store in place of the ones it had.
+func (u *Users) Unmarshal(b []byte) error {
+	users, err := unmarshalUsers(b)
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.store != nil {
+		for name := range u.users {
+			if _, ok := users[name]; ok {
+				continue
+			}
+			if err := u.store.DeleteUser(name); err != nil {
+				return err
+			}
+		}
+		for name, user := range users {
+			if err := u.store.SaveUser(name, marshalUser(nil, user)); err != nil {
+				return err
+			}
+		}
+	}
+	u.reset()
+	for name, user := range users {
+		u.users[name] = user
+	}
+	return nil
+}
+
+func unmarshalUsers(b []byte) (map[string]*User, error) {
+	users := make(map[string]*User)
+
+	var (
+		args     [][]byte
+		complete bool
+		err      error
+	)
+	for len(b) > 0 {
+		_, complete, args, _, b, err = resp.ParseNextCommand(b, args[:0])
+		if err != nil {
+			return nil, err
+		}
+		if !complete || len(args) == 0 {
+			return nil, ErrUsersSnapshot
+		}
+		user := NewUser(string(args[0]))
+		for _, rule := range args[1:] {
+			if err := user.SetRules(string(rule)); err != nil {
+				return nil, err
+			}
+		}
+		users[user.Name] = user
+	}
+	return users, nil
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/genzai-io/sliced/app/acl"
+)
+
+// Access a command requires. Keys are found by their argument positions in
+// the style of Redis key specs. Commands without registered access are
+// Admin commands.
+type Access struct {
+	// Zero allows every authenticated user
+	Category acl.Category
+
+	// Position of the first and last key. Negative positions count from the
+	// end so -1 is the last argument. FirstKey 0 means there are no keys.
+	FirstKey int
+	LastKey  int
+	Step     int
+
+	// Position of an argument with the number of keys that follow it
+	NumKeys int
+
+	// The keys are pub/sub channels or channel patterns
+	Channels bool
+	Patterns bool
+
+	// Access of subcommands by their upper case name. The subcommand is the
+	// first argument and a subcommand that is not here has the access of
+	// the command.
+	Subcommands map[string]Access
+}
+
+var accessRules = make(map[string]*Access)
+
+// Registers the access of a command by name.
+func RegisterAccess(name string, access Access) {
+	if access.Step == 0 {
+		access.Step = 1
+	}
+	for sub, subAccess := range access.Subcommands {
+		if subAccess.Step == 0 {
+			subAccess.Step = 1
+			access.Subcommands[sub] = subAccess
+		}
+	}
+	accessRules[strings.ToUpper(name)] = &access
+	accessRules[strings.ToLower(name)] = &access
+}
+
+// Access of a command by name.
+func CommandAccess(name string) *Access {
+	if access, ok := accessRules[name]; ok {
+		return access
+	}
+	if access, ok := accessRules[strings.ToUpper(name)]; ok {
+		return access
+	}
+	return &Access{Category: acl.Admin}
+}
+
+// Access of the subcommand in args if it has it's own.
+func (access *Access) subcommand(args [][]byte) *Access {
+	if len(access.Subcommands) == 0 || len(args) < 2 {
+		return access
+	}
+	if sub, ok := access.Subcommands[strings.ToUpper(string(args[1]))]; ok {
+		return &sub
+	}
+	return access
+}
+
+// Positions of the first and last key in the arguments of a command. First
+// is greater than last when there are no keys.
+func (access *Access) keyRange(args [][]byte) (first, last int) {
+	first, last = access.FirstKey, access.LastKey
+	if access.NumKeys > 0 {
+		if access.NumKeys >= len(args) {
+			return 1, 0
+		}
+		n, err := strconv.Atoi(string(args[access.NumKeys]))
+		if err != nil || n <= 0 {
+			return 1, 0
+		}
+		first, last = access.NumKeys+1, access.NumKeys+n
+	}
+	if first <= 0 {
+		return 1, 0
+	}
+	if last < 0 {
+		last += len(args)
+	}
+	if last >= len(args) {
+		last = len(args) - 1
+	}
+	return first, last
+}
+
+// Keys in the arguments of a command. Pub/sub channels are not keys.
+func CommandKeys(args [][]byte) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	access := CommandAccess(string(args[0])).subcommand(args)
+	if access.Channels || access.Patterns {
+		return nil
+	}
+	var keys []string
+	first, last := access.keyRange(args)
+	for i := first; i <= last; i += access.Step {
+		keys = append(keys, string(args[i]))
+	}
+	return keys
+}
+
+// Checks whether the user may run a command.
+func CheckAccess(user *acl.User, args [][]byte) error {
+	if len(args) == 0 || user.Unrestricted() {
+		return nil
+	}
+
+	name := *(*string)(unsafe.Pointer(&args[0]))
+	access := CommandAccess(name).subcommand(args)
+	if !user.Can(access.Category) {
+		return acl.NoPermCommand(name)
+	}
+
+	first, last := access.keyRange(args)
+	for i := first; i <= last; i += access.Step {
+		arg := *(*string)(unsafe.Pointer(&args[i]))
+		switch {
+		case access.Patterns:
+			if !user.CanPattern(arg) {
+				return acl.ErrNoPermChannel
+			}
+		case access.Channels:
+			if !user.CanChannel(arg) {
+				return acl.ErrNoPermChannel
+			}
+		default:
+			if !user.CanKey(arg) {
+				return acl.ErrNoPermKey
+			}
+		}
+	}
+	return nil
+}
+
+// Authenticated user of the connection. Connections that did not AUTH
+// use the default user as long as it needs no password.
+func (c *Context) ACLUser() (*acl.User, error) {
+	name := c.User
+	if name == "" {
+		name = acl.DefaultUser
+	}
+	user, ok := acl.Default.Get(name)
+	if !ok || !user.Enabled || (c.User == "" && !user.NoPass) {
+		return nil, acl.ErrNoAuth
+	}
+	return user, nil
+}
+
+// Checks whether the connection may run a command. Commands without a
+// category or keys such as AUTH are allowed before authenticating.
+func (c *Context) CheckAccess(args [][]byte) error {
+	if len(args) == 0 {
+		return nil
+	}
+	if access := CommandAccess(*(*string)(unsafe.Pointer(&args[0]))); access.Category == 0 && access.FirstKey == 0 && access.NumKeys == 0 {
+		return nil
+	}
+	user, err := c.ACLUser()
+	if err != nil {
+		return err
+	}
+	return CheckAccess(user, args)
+}
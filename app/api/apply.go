@@ -1,8 +1,29 @@
 package api
 
-import "github.com/genzai-io/sliced/common/raft"
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
 
-var ApplyCommands map[string]ApplyCommand
+	"github.com/genzai-io/sliced/common/raft"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+// Name of the log entry that stamps a command with the time it was proposed.
+const ApplyAtName = "AT"
+
+var (
+	ErrApplyEmpty    = errors.New("empty apply command")
+	ErrApplyNotFound = errors.New("apply command not found")
+)
+
+// Global registry of commands that may be replicated through a Raft log.
+var ApplyCommands map[string]func() ApplyCommand
+
+func init() {
+	ApplyCommands = make(map[string]func() ApplyCommand)
+}
 
 // Transaction is a single log entry in the Raft log.
 type Transaction struct {
@@ -11,11 +32,102 @@ type Transaction struct {
 	log *raft.Log
 }
 
-
+// A command that is serialized into a Raft log entry and applied
+// on every member of the Raft group in the same order. Handle must
+// be deterministic since it will be invoked on every node.
 type ApplyCommand interface {
+	Name() string
+
+	// Marshal must write a RESP array whose first element is the Name
 	Marshal(b []byte) []byte
 
 	Unmarshal(b []byte) error
 
 	Handle() CommandReply
 }
+
+// Registers a factory for an ApplyCommand by name.
+func RegisterApply(name string, factory func() ApplyCommand) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if _, ok := ApplyCommands[name]; ok {
+		panic("apply command name '" + name + "' already used")
+	}
+	ApplyCommands[name] = factory
+}
+
+// Writes a Raft log entry for the command stamped with the time it was
+// proposed in unix seconds. Every node applies the command as of that time.
+func MarshalApply(command ApplyCommand, now time.Time) []byte {
+	b := resp.AppendArray(nil, 3)
+	b = resp.AppendBulkString(b, ApplyAtName)
+	b = resp.AppendBulkInt64(b, now.Unix())
+	return resp.AppendBulk(b, command.Marshal(nil))
+}
+
+// Parses a Raft log entry into it's ApplyCommand and the time it was
+// proposed. Entries written without a time return 0.
+func UnmarshalApplyAt(b []byte) (ApplyCommand, int64, error) {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(args) == 0 {
+		return nil, 0, ErrApplyEmpty
+	}
+	if !strings.EqualFold(string(args[0]), ApplyAtName) {
+		command, err := UnmarshalApply(b)
+		return command, 0, err
+	}
+	if len(args) != 3 {
+		return nil, 0, ErrInvalidParam
+	}
+	at, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return nil, 0, ErrInvalidParam
+	}
+	command, err := UnmarshalApply(args[2])
+	return command, at, err
+}
+
+// Parses a Raft log entry into it's ApplyCommand
+func UnmarshalApply(b []byte) (ApplyCommand, error) {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, ErrApplyEmpty
+	}
+	if strings.EqualFold(string(args[0]), ApplyAtName) {
+		command, _, err := UnmarshalApplyAt(b)
+		return command, err
+	}
+
+	factory, ok := ApplyCommands[strings.ToUpper(string(args[0]))]
+	if !ok {
+		return nil, ErrApplyNotFound
+	}
+
+	command := factory()
+	if err := command.Unmarshal(b); err != nil {
+		return nil, err
+	}
+	return command, nil
+}
+
+// Propose submits an ApplyCommand to the cluster Raft log and waits
+// until it's applied locally. If there is no cluster running then
+// the command is applied directly.
+func Propose(command ApplyCommand) CommandReply {
+	if Cluster == nil {
+		return command.Handle()
+	}
+	r := Cluster.Raft()
+	if r == nil {
+		return command.Handle()
+	}
+	if !r.IsLeader() {
+		return Err("ERR not leader: " + string(r.Leader()))
+	}
+	return r.Apply(MarshalApply(command, time.Now()))
+}
@@ -12,10 +12,6 @@ func init() {
 	Commands = make(map[string]Command)
 }
 
-type CommandStats struct {
-	Name string
-}
-
 var GlobalRaftID = RaftID{
 	DatabaseID: -1,
 	SliceID:    -1,
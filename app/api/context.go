@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/genzai-io/sliced/app/pubsub"
 	"github.com/genzai-io/sliced/common/evio"
 	"github.com/genzai-io/sliced/common/resp"
 )
@@ -61,6 +63,21 @@ type Context struct {
 
 	Multi Multi
 
+	// Keys WATCH'd for the next EXEC
+	Watched Watched
+
+	// Channel and pattern subscriptions of a PUB/SUB connection
+	Subscriber *pubsub.Subscriber
+
+	// Name of the user set by AUTH or HELLO
+	User string
+
+	// Remote address of the connection
+	Addr string
+
+	// Commands are running on the connection's worker goroutine
+	OnWorker bool
+
 	// Assigned raft context
 	// This is used for the RaftTransport to support multiple Raft clusters
 	// over the same port
@@ -110,10 +127,15 @@ func (c *Context) AppendCommand(b []byte, command Command) []byte {
 	if command == nil {
 		command = Err("ERR nil command")
 	}
+	start := time.Now()
 	reply := command.Handle(c)
+	elapsed := time.Since(start)
 	if reply == nil {
 		reply = Err("ERR nil reply for command '" + command.Name() + "'")
 	}
+	_, failed := reply.(Err)
+	Stats.Record(command.Name(), elapsed, c.OnWorker, failed)
+	Slowlog.Add(c, command, elapsed)
 
 	before := len(b)
 	b = reply.MarshalReply(b)
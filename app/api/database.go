@@ -1,9 +1,14 @@
 package api
 
-type Databases interface {
-	GetByName(name string) Database
+// Databases of the cluster. Set by core.
+var Databases IDatabases
 
-	GetByID(id int32) Database
+type IDatabases interface {
+	// Names of every database sorted
+	Names() []string
+
+	// Checks a new database and returns the log entry that creates it.
+	CreateEntry(name string) (ApplyCommand, error)
 }
 
 type Database interface {
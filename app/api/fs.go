@@ -3,4 +3,12 @@ package api
 var Drives IDrives
 
 type IDrives interface {
+	// Takes a ZFS snapshot of the dataset of every drive with writes to the
+	// drives paused. Drives that are not on ZFS are skipped.
+	Snapshot(name string) ([]DriveSnapshot, error)
+}
+
+type DriveSnapshot struct {
+	Mount    string
+	Snapshot string // Full name of the ZFS snapshot
 }
@@ -0,0 +1,32 @@
+package api
+
+import "github.com/genzai-io/sliced/app/table"
+
+// Keyspace is the table behind the key/value commands. It is only
+// mutated through ApplyCommands so every member of the cluster holds
+// the same data. Callers must hold it's lock through View or Update.
+var Keyspace = table.NewTable()
+
+// A Command that operates on a keyspace table and may be called from a
+// script. Invoke is called with the table lock already held and must not
+// propose to the cluster log since the script itself is replicated.
+type KeyspaceCommand interface {
+	Command
+
+	Invoke(ks *table.Table) CommandReply
+}
+
+// Keys WATCH'd by a connection and the revision each had when it was
+// watched. A missing key is watched at the revision of the keyspace.
+type Watched map[string]uint64
+
+// Whether any watched key was written, deleted or expired since it was
+// watched. The caller must hold the table lock.
+func (w Watched) Changed(ks *table.Table) bool {
+	for key, revision := range w {
+		if ks.ChangedSince(table.StringKey(key), revision) {
+			return true
+		}
+	}
+	return false
+}
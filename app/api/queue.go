@@ -0,0 +1,19 @@
+package api
+
+import "time"
+
+// Queues of the cluster. Set by core.
+var Queues IQueues
+
+type IQueues interface {
+	// Names of every queue sorted
+	Names() []string
+
+	// Checks a change against the queues and returns the log entry that
+	// makes it. Messages are dequeued through the log so every node hands
+	// out the same messages.
+	CreateEntry(name string) (ApplyCommand, error)
+	EnqueueEntry(name string, messages []string) (ApplyCommand, error)
+	DequeueEntry(name string, count int, visibility time.Duration) (ApplyCommand, error)
+	AckEntry(name string, ids []uint64) (ApplyCommand, error)
+}
@@ -57,6 +57,9 @@ type RaftService interface {
 
 	Vote(payload []byte) CommandReply
 
+	// Appends a command to the log and waits for the FSM to apply it
+	Apply(payload []byte) CommandReply
+
 	Install(ctx *Context, arg []byte) Command
 
 	Bootstrap() error
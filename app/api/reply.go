@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"io"
 
+	"github.com/genzai-io/sliced/common/diskio"
 	"github.com/genzai-io/sliced/common/resp"
 )
 
@@ -162,6 +163,15 @@ func (e Err) Handle(ctx *Context) CommandReply {
 	return e
 }
 
+// Reply for an error from a store. Writes refused while the disk of the
+// store is full or failing reply READONLY so clients can tell them apart.
+func ErrReply(err error) Err {
+	if diskio.IsReadOnly(err) {
+		return Err("READONLY " + err.Error())
+	}
+	return Err("ERR " + err.Error())
+}
+
 //
 //
 //
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+// Most arguments and bytes of each argument kept for a slow command.
+const (
+	slowlogMaxArgs     = 32
+	slowlogMaxArgBytes = 128
+)
+
+// Slow commands of this node.
+var Slowlog = NewSlowLog(10*time.Millisecond, 128)
+
+// A command that took longer than the threshold.
+type SlowLogEntry struct {
+	ID       int64
+	Time     time.Time
+	Duration time.Duration
+	Args     []string
+	Addr     string // Client address
+	User     string
+}
+
+// Ring of the latest slow commands.
+type SlowLog struct {
+	mu        sync.Mutex
+	threshold time.Duration
+	entries   []SlowLogEntry // Ring of the latest entries
+	next      int            // Ring position of the next entry
+	maxLen    int
+	nextID    int64
+}
+
+func NewSlowLog(threshold time.Duration, maxLen int) *SlowLog {
+	l := &SlowLog{}
+	l.Configure(threshold, maxLen)
+	return l
+}
+
+// Sets the threshold and the most entries kept. A negative threshold
+// disables the log and zero logs every command.
+func (l *SlowLog) Configure(threshold time.Duration, maxLen int) {
+	if maxLen < 0 {
+		maxLen = 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.threshold = threshold
+	if maxLen != l.maxLen {
+		// Keep the newest entries
+		entries := l.newest(maxLen)
+		l.entries = make([]SlowLogEntry, 0, maxLen)
+		for i := len(entries) - 1; i >= 0; i-- {
+			l.entries = append(l.entries, entries[i])
+		}
+		l.next = 0
+		if maxLen > 0 {
+			l.next = len(l.entries) % maxLen
+		}
+		l.maxLen = maxLen
+	}
+}
+
+func (l *SlowLog) Threshold() time.Duration {
+	l.mu.Lock()
+	threshold := l.threshold
+	l.mu.Unlock()
+	return threshold
+}
+
+// Logs the command when it took at least the threshold.
+func (l *SlowLog) Add(ctx *Context, command Command, d time.Duration) {
+	l.mu.Lock()
+	if l.threshold < 0 || d < l.threshold || l.maxLen == 0 {
+		l.mu.Unlock()
+		return
+	}
+	l.mu.Unlock()
+
+	entry := SlowLogEntry{
+		Time:     time.Now(),
+		Duration: d,
+		Args:     slowlogArgs(command),
+		Addr:     ctx.Addr,
+		User:     ctx.User,
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxLen == 0 {
+		return
+	}
+	entry.ID = l.nextID
+	l.nextID++
+	if len(l.entries) < l.maxLen {
+		l.entries = append(l.entries, entry)
+	} else {
+		l.entries[l.next] = entry
+	}
+	l.next = (l.next + 1) % l.maxLen
+}
+
+// Up to n of the newest entries with the newest first. A negative n gets
+// every entry.
+func (l *SlowLog) Get(n int) []SlowLogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.newest(n)
+}
+
+func (l *SlowLog) newest(n int) []SlowLogEntry {
+	if n < 0 || n > len(l.entries) {
+		n = len(l.entries)
+	}
+	entries := make([]SlowLogEntry, 0, n)
+	for i := 1; i <= n; i++ {
+		index := (l.next - i + len(l.entries)) % len(l.entries)
+		entries = append(entries, l.entries[index])
+	}
+	return entries
+}
+
+func (l *SlowLog) Len() int {
+	l.mu.Lock()
+	n := len(l.entries)
+	l.mu.Unlock()
+	return n
+}
+
+// Removes every entry.
+func (l *SlowLog) Reset() {
+	l.mu.Lock()
+	l.entries = l.entries[:0]
+	l.next = 0
+	l.mu.Unlock()
+}
+
+// Arguments of the command as it was given. Long arguments and argument
+// lists are shortened the same way Redis does.
+func slowlogArgs(command Command) []string {
+	args, _, err := resp.ParseCommand(command.Marshal(nil))
+	if err != nil || len(args) == 0 {
+		return []string{command.Name()}
+	}
+
+	n := len(args)
+	if n > slowlogMaxArgs {
+		n = slowlogMaxArgs
+	}
+	list := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		if i == slowlogMaxArgs-1 && len(args) > slowlogMaxArgs {
+			list = append(list, "... ("+strconv.Itoa(len(args)-slowlogMaxArgs+1)+" more arguments)")
+			break
+		}
+		arg := string(args[i])
+		if len(arg) > slowlogMaxArgBytes {
+			arg = arg[:slowlogMaxArgBytes] + "... (" + strconv.Itoa(len(arg)-slowlogMaxArgBytes) + " more bytes)"
+		}
+		list = append(list, arg)
+	}
+	return list
+}
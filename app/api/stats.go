@@ -0,0 +1,157 @@
+package api
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/genzai-io/sliced/common/histogram"
+)
+
+// Range and precision of the command latency histograms in microseconds.
+const (
+	latencyMin     = 1
+	latencyMax     = int64(time.Minute / time.Microsecond)
+	latencySigFigs = 2
+)
+
+// Statistics of the commands handled by this node.
+var Stats = NewStatsTable()
+
+// Statistics of a single command. Times are in microseconds.
+type CommandStats struct {
+	Name       string
+	Calls      int64
+	Errors     int64 // Calls that replied with an error
+	Usec       int64
+	LoopUsec   int64 // Time spent on the event loop
+	WorkerUsec int64 // Time spent on connection workers
+	Latency    *hdrhistogram.Histogram
+}
+
+func (s *CommandStats) UsecPerCall() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Usec) / float64(s.Calls)
+}
+
+// Number of calls that took at most each bound in microseconds. Bounds
+// must be ascending.
+func (s *CommandStats) Buckets(bounds []int64) []int64 {
+	bars := s.Latency.Distribution()
+	counts := make([]int64, len(bounds))
+	var count int64
+	for i, bound := range bounds {
+		for len(bars) > 0 && bars[0].To <= bound {
+			count += bars[0].Count
+			bars = bars[1:]
+		}
+		counts[i] = count
+	}
+	return counts
+}
+
+type commandEntry struct {
+	mu    sync.Mutex
+	stats CommandStats
+}
+
+// Statistics of every command by name.
+type StatsTable struct {
+	mu       sync.RWMutex
+	commands map[string]*commandEntry
+}
+
+func NewStatsTable() *StatsTable {
+	return &StatsTable{commands: make(map[string]*commandEntry)}
+}
+
+// Records a call to a command. Replies handled in place of a command are
+// not registered commands and are ignored.
+func (t *StatsTable) Record(name string, d time.Duration, worker, failed bool) {
+	name = strings.ToLower(name)
+	if _, ok := Commands[name]; !ok {
+		return
+	}
+
+	t.mu.RLock()
+	e, ok := t.commands[name]
+	t.mu.RUnlock()
+	if !ok {
+		t.mu.Lock()
+		if e, ok = t.commands[name]; !ok {
+			e = &commandEntry{stats: CommandStats{
+				Name:    name,
+				Latency: hdrhistogram.New(latencyMin, latencyMax, latencySigFigs),
+			}}
+			t.commands[name] = e
+		}
+		t.mu.Unlock()
+	}
+
+	us := int64(d / time.Microsecond)
+	e.mu.Lock()
+	s := &e.stats
+	s.Calls++
+	if failed {
+		s.Errors++
+	}
+	s.Usec += us
+	if worker {
+		s.WorkerUsec += us
+	} else {
+		s.LoopUsec += us
+	}
+	if us < latencyMin {
+		us = latencyMin
+	} else if us > latencyMax {
+		us = latencyMax
+	}
+	s.Latency.RecordValue(us)
+	e.mu.Unlock()
+}
+
+// Copy of the statistics of a command.
+func (t *StatsTable) Get(name string) (*CommandStats, bool) {
+	t.mu.RLock()
+	e, ok := t.commands[strings.ToLower(name)]
+	t.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return e.snapshot(), true
+}
+
+// Calls fn with a copy of the statistics of every command in name order.
+func (t *StatsTable) Each(fn func(s *CommandStats)) {
+	t.mu.RLock()
+	names := make([]string, 0, len(t.commands))
+	for name := range t.commands {
+		names = append(names, name)
+	}
+	t.mu.RUnlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		if s, ok := t.Get(name); ok {
+			fn(s)
+		}
+	}
+}
+
+// Forgets the statistics of every command.
+func (t *StatsTable) Reset() {
+	t.mu.Lock()
+	t.commands = make(map[string]*commandEntry)
+	t.mu.Unlock()
+}
+
+func (e *commandEntry) snapshot() *CommandStats {
+	e.mu.Lock()
+	s := e.stats
+	s.Latency = hdrhistogram.Import(e.stats.Latency.Export())
+	e.mu.Unlock()
+	return &s
+}
@@ -1,3 +1,29 @@
 package api
 
-type Topic interface {}
\ No newline at end of file
+type Topic interface {}
+
+// Topics of the cluster. Set by core.
+var Topics ITopics
+
+type ITopics interface {
+	// Names of every topic sorted
+	Names() []string
+
+	// Checks a change against the topics and returns the log entry that
+	// makes it. Rejected records are reported before they reach the log.
+	CreateEntry(name string, options []string) (ApplyCommand, error)
+	AlterEntry(name string, options []string) (ApplyCommand, error)
+	AppendEntry(name string, records []string) (ApplyCommand, error)
+
+	// Reads up to count records of a topic starting at offset.
+	Read(name string, offset uint64, count int) ([]TopicRecord, error)
+}
+
+// A record read from a topic.
+type TopicRecord struct {
+	// Position of the record in the topic counting from zero
+	Offset uint64
+	// Index of the log entry that appended the record
+	LogID uint64
+	Data  []byte
+}
@@ -0,0 +1,388 @@
+// Online backups of the stores and segments of a node.
+//
+// Every store and set of files on the node registers a Source. A backup
+// prepares every source and then holds them while it pins the files by hard
+// linking them into a staging directory and saves a copy of every store, so
+// the files and the stores are taken at the same point. Files are immutable
+// as far as they are listed, so an incremental backup only contains the files
+// that are missing from its base.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/genzai-io/sliced"
+)
+
+// Name of the first entry of every archive.
+const manifestName = "MANIFEST"
+
+var (
+	ErrNoManifest  = errors.New("backup: archive does not start with a manifest")
+	ErrBrokenChain = errors.New("backup: archive is not based on the previous one")
+	ErrInvalidName = errors.New("backup: invalid name in archive")
+)
+
+// A file that never changes once it is listed, such as a sealed segment, or
+// that is only appended to, such as the segment a topic writes to.
+type File struct {
+	// Key that identifies the contents across backups.
+	Key  string
+	Path string
+	// Bytes to back up of a file that is appended to. Zero backs up the
+	// whole file.
+	Size int64
+}
+
+// Something on the node that is backed up.
+type Source struct {
+	// Path of the store written by Save.
+	Path string
+	// Called before anything is copied. Raft groups take a snapshot here.
+	Prepare func() error
+	// Stops the changes to the stores and files of every source until
+	// release is called. Files are listed and stores saved while every
+	// source is held.
+	Hold func() (release func())
+	// Writes a consistent copy of the store at Path.
+	Save func(w io.Writer) error
+	// Lists the immutable files of the source.
+	Files func() ([]File, error)
+}
+
+var (
+	mu      sync.Mutex
+	sources = make(map[*Source]struct{})
+)
+
+// Includes the source in every backup until it is unregistered.
+func Register(src *Source) {
+	mu.Lock()
+	sources[src] = struct{}{}
+	mu.Unlock()
+}
+
+func Unregister(src *Source) {
+	mu.Lock()
+	delete(sources, src)
+	mu.Unlock()
+}
+
+func registered() []*Source {
+	mu.Lock()
+	list := make([]*Source, 0, len(sources))
+	for src := range sources {
+		list = append(list, src)
+	}
+	mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
+
+// Describes the contents of an archive.
+type Manifest struct {
+	ID     string
+	Base   string `json:",omitempty"` // ID of the backup this one builds on
+	Node   string
+	Time   time.Time
+	Stores []string
+	Files  []ManifestFile
+}
+
+type ManifestFile struct {
+	Key    string
+	Name   string
+	Size   int64
+	Backup string // ID of the backup with the contents
+}
+
+func (m *Manifest) file(key string) (ManifestFile, bool) {
+	for _, f := range m.Files {
+		if f.Key == key {
+			return f, true
+		}
+	}
+	return ManifestFile{}, false
+}
+
+// Name of a path in an archive. Paths under the home directory are relative
+// to it so that an archive may be restored onto another home directory.
+func Name(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if moved.HomeDir != "" {
+		if rel, err := filepath.Rel(moved.HomeDir, path); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return "home/" + filepath.ToSlash(rel)
+		}
+	}
+	return "abs" + filepath.ToSlash(path)
+}
+
+// Path of a name in an archive.
+func Path(name string) (string, error) {
+	if strings.Contains(name, "..") {
+		return "", ErrInvalidName
+	}
+	switch {
+	case strings.HasPrefix(name, "home/"):
+		return filepath.Join(moved.HomeDir, filepath.FromSlash(name[len("home/"):])), nil
+	case strings.HasPrefix(name, "abs/"):
+		return filepath.FromSlash(name[len("abs"):]), nil
+	}
+	return "", ErrInvalidName
+}
+
+type pinned struct {
+	name string
+	path string // Hard link or the original file
+	file *os.File
+	size int64
+}
+
+// Writes a backup of every registered source to w. Files that are listed in
+// base are left out of the archive.
+func Create(w io.Writer, base *Manifest) (*Manifest, error) {
+	now := time.Now()
+	m := &Manifest{
+		ID:   strconv.FormatInt(now.UnixNano(), 36),
+		Node: string(moved.ClusterID),
+		Time: now,
+	}
+	if base != nil {
+		m.Base = base.ID
+	}
+
+	list := registered()
+	for _, src := range list {
+		if src.Prepare != nil {
+			if err := src.Prepare(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	staging, err := ioutil.TempDir(stagingDir(), ".backup-"+m.ID+"-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	release := hold(list)
+	files, stores, err := copySources(list, base, m, staging)
+	release()
+	defer func() {
+		for _, p := range append(stores, files...) {
+			if p.file != nil {
+				p.file.Close()
+			}
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    manifestName,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: now,
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return nil, err
+	}
+	for _, p := range append(stores, files...) {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    p.name,
+			Mode:    0644,
+			Size:    p.size,
+			ModTime: now,
+		}); err != nil {
+			return nil, err
+		}
+		if _, err := io.CopyN(tw, p.file, p.size); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Holds every source that can be held. The sources are released in reverse.
+func hold(list []*Source) (release func()) {
+	var releases []func()
+	for _, src := range list {
+		if src.Hold != nil {
+			releases = append(releases, src.Hold())
+		}
+	}
+	return func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+}
+
+// Pins the files and saves the stores of the sources into staging. Files
+// that are listed in base are only added to the manifest. What was copied is
+// returned even on errors so it can be closed.
+func copySources(list []*Source, base, m *Manifest, staging string) (files, stores []*pinned, err error) {
+	// Pin the files first so they outlive compaction during the backup.
+	for _, src := range list {
+		if src.Files == nil {
+			continue
+		}
+		found, err := src.Files()
+		if err != nil {
+			return files, stores, err
+		}
+		for _, f := range found {
+			if base != nil {
+				if bf, ok := base.file(f.Key); ok {
+					m.Files = append(m.Files, bf)
+					continue
+				}
+			}
+			p, err := pin(staging, len(files), f.Path, f.Size)
+			if err != nil {
+				if os.IsNotExist(err) {
+					// Removed before it was pinned
+					continue
+				}
+				return files, stores, err
+			}
+			p.name = Name(f.Path)
+			files = append(files, p)
+			m.Files = append(m.Files, ManifestFile{
+				Key:    f.Key,
+				Name:   p.name,
+				Size:   p.size,
+				Backup: m.ID,
+			})
+		}
+	}
+
+	// Save the stores. Sizes must be known before they are added to the archive.
+	for _, src := range list {
+		if src.Save == nil {
+			continue
+		}
+		f, err := ioutil.TempFile(staging, "store-")
+		if err != nil {
+			return files, stores, err
+		}
+		p := &pinned{name: Name(src.Path), file: f}
+		stores = append(stores, p)
+		if err := src.Save(f); err != nil {
+			return files, stores, fmt.Errorf("backup: save %s: %s", src.Path, err)
+		}
+		if p.size, err = f.Seek(0, io.SeekCurrent); err != nil {
+			return files, stores, err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return files, stores, err
+		}
+		m.Stores = append(m.Stores, p.name)
+	}
+	return files, stores, nil
+}
+
+// Hard links the file into the staging directory. Files on another device
+// are kept open instead. Only the first size bytes are backed up unless size
+// is zero.
+func pin(staging string, n int, path string, size int64) (*pinned, error) {
+	p := &pinned{path: filepath.Join(staging, strconv.Itoa(n))}
+	if err := os.Link(path, p.path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+		p.path = path
+	}
+	f, err := os.Open(p.path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	p.file = f
+	p.size = info.Size()
+	if size > 0 && size < p.size {
+		p.size = size
+	}
+	return p, nil
+}
+
+func stagingDir() string {
+	if moved.StoreDir == "" || moved.StoreDir == ":memory:" {
+		return ""
+	}
+	return moved.StoreDir
+}
+
+// Reads the manifest at the start of an archive.
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	m, _, err := readManifest(tar.NewReader(gz))
+	return m, err
+}
+
+func readManifest(tr *tar.Reader) (*Manifest, *tar.Header, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil, ErrNoManifest
+		}
+		return nil, nil, err
+	}
+	if hdr.Name != manifestName {
+		return nil, nil, ErrNoManifest
+	}
+	m := &Manifest{}
+	if err := json.NewDecoder(tr).Decode(m); err != nil {
+		return nil, nil, err
+	}
+	return m, hdr, nil
+}
+
+// Reads the manifest of the archive at path.
+func ReadManifestFile(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadManifest(f)
+}
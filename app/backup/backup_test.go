@@ -0,0 +1,208 @@
+package backup
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/genzai-io/sliced"
+)
+
+func writeFile(t *testing.T, path, data string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectFile(t *testing.T, path, data string) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != data {
+		t.Fatalf("expected %s to contain %q got %q", path, data, b)
+	}
+}
+
+func createFile(t *testing.T, path string, base *Manifest) *Manifest {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	m, err := Create(f, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestCreateRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	home := moved.HomeDir
+	defer func() { moved.HomeDir = home }()
+	moved.HomeDir = filepath.Join(dir, "node")
+
+	state := "v1"
+	segments := []string{filepath.Join(moved.HomeDir, "data", "s", "0", "t", "1", "1.s")}
+	writeFile(t, segments[0], "segment 1")
+
+	prepared := 0
+	src := &Source{
+		Path:    filepath.Join(moved.HomeDir, "store", "store.db"),
+		Prepare: func() error { prepared++; return nil },
+		Save: func(w io.Writer) error {
+			_, err := io.WriteString(w, state)
+			return err
+		},
+		Files: func() ([]File, error) {
+			var files []File
+			for _, path := range segments {
+				files = append(files, File{Key: filepath.Base(path), Path: path})
+			}
+			return files, nil
+		},
+	}
+	Register(src)
+	defer Unregister(src)
+
+	full := createFile(t, filepath.Join(dir, "full.tar.gz"), nil)
+	if prepared != 1 || len(full.Stores) != 1 || len(full.Files) != 1 {
+		t.Fatalf("unexpected manifest %+v", full)
+	}
+
+	state = "v2"
+	segments = append(segments, filepath.Join(moved.HomeDir, "data", "s", "0", "t", "1", "2.s"))
+	writeFile(t, segments[1], "segment 2")
+
+	incr := createFile(t, filepath.Join(dir, "incr.tar.gz"), full)
+	if incr.Base != full.ID || len(incr.Files) != 2 ||
+		incr.Files[0].Backup != full.ID || incr.Files[1].Backup != incr.ID {
+		t.Fatalf("unexpected manifest %+v", incr)
+	}
+
+	// Only the new segment is in the incremental archive
+	var names []string
+	if err := readArchive(filepath.Join(dir, "incr.tar.gz"), func(name string, r io.Reader) error {
+		names = append(names, name)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "home/store/store.db" || names[1] != "home/data/s/0/t/1/2.s" {
+		t.Fatalf("unexpected entries %v", names)
+	}
+
+	// Restore onto another home directory
+	moved.HomeDir = filepath.Join(dir, "restored")
+	archives := []string{filepath.Join(dir, "full.tar.gz"), filepath.Join(dir, "incr.tar.gz")}
+	if _, err := Restore(archives[1:], false); err == nil {
+		t.Fatal("expected an incomplete chain to fail")
+	}
+	m, err := Restore(archives, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != incr.ID {
+		t.Fatalf("expected the last manifest got %+v", m)
+	}
+	expectFile(t, filepath.Join(moved.HomeDir, "store", "store.db"), "v2")
+	expectFile(t, filepath.Join(moved.HomeDir, "data", "s", "0", "t", "1", "1.s"), "segment 1")
+	expectFile(t, filepath.Join(moved.HomeDir, "data", "s", "0", "t", "1", "2.s"), "segment 2")
+
+	if _, err := Restore(archives, false); err == nil {
+		t.Fatal("expected a restore over existing files to fail")
+	}
+	if _, err := Restore(archives[:1], true); err != nil {
+		t.Fatal(err)
+	}
+	expectFile(t, filepath.Join(moved.HomeDir, "store", "store.db"), "v1")
+}
+
+func TestName(t *testing.T) {
+	home := moved.HomeDir
+	defer func() { moved.HomeDir = home }()
+	moved.HomeDir = "/var/sliced"
+
+	for path, name := range map[string]string{
+		"/var/sliced/store/raft.db": "home/store/raft.db",
+		"/var/slicedx/raft.db":      "abs/var/slicedx/raft.db",
+		"/mnt/ssd/s/1/t/2/3.s":      "abs/mnt/ssd/s/1/t/2/3.s",
+	} {
+		if got := Name(path); got != name {
+			t.Fatalf("expected %s for %s got %s", name, path, got)
+		}
+		if got, err := Path(name); err != nil || got != path {
+			t.Fatalf("expected %s for %s got %s %v", path, name, got, err)
+		}
+	}
+	if _, err := Path("home/../../etc/passwd"); err != ErrInvalidName {
+		t.Fatal("expected names outside of the root to be invalid")
+	}
+}
+
+func TestCreate_Hold(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	home := moved.HomeDir
+	defer func() { moved.HomeDir = home }()
+	moved.HomeDir = filepath.Join(dir, "node")
+
+	// The tail has been written past what was listed
+	tail := filepath.Join(moved.HomeDir, "data", "topics", "1", "1.s")
+	writeFile(t, tail, "listed,written later")
+
+	var held, prepared bool
+	var events []string
+	src := &Source{
+		Path: filepath.Join(moved.HomeDir, "store", "raft.log"),
+		Prepare: func() error {
+			prepared = !held
+			return nil
+		},
+		Hold: func() func() {
+			held = true
+			return func() { held = false }
+		},
+		Save: func(w io.Writer) error {
+			events = append(events, "save held="+strconv.FormatBool(held))
+			_, err := io.WriteString(w, "log")
+			return err
+		},
+		Files: func() ([]File, error) {
+			events = append(events, "files held="+strconv.FormatBool(held))
+			return []File{{Key: "tail@6", Path: tail, Size: 6}}, nil
+		},
+	}
+	Register(src)
+	defer Unregister(src)
+
+	m := createFile(t, filepath.Join(dir, "full.tar.gz"), nil)
+	if !prepared || held || len(events) != 2 || events[0] != "files held=true" || events[1] != "save held=true" {
+		t.Fatalf("expected the files and stores to be copied while held got prepared=%v held=%v %v", prepared, held, events)
+	}
+	if len(m.Files) != 1 || m.Files[0].Size != 6 {
+		t.Fatalf("unexpected manifest %+v", m)
+	}
+
+	moved.HomeDir = filepath.Join(dir, "restored")
+	if _, err := Restore([]string{filepath.Join(dir, "full.tar.gz")}, false); err != nil {
+		t.Fatal(err)
+	}
+	expectFile(t, filepath.Join(moved.HomeDir, "data", "topics", "1", "1.s"), "listed")
+}
@@ -0,0 +1,163 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/genzai-io/sliced"
+)
+
+// Rebuilds the stores and files of a node from a full backup followed by its
+// incremental backups in order. The node must not be running. Existing
+// stores and files are only replaced when force is set.
+func Restore(paths []string, force bool) (*Manifest, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("backup: nothing to restore")
+	}
+
+	manifests := make([]*Manifest, len(paths))
+	for i, path := range paths {
+		m, err := ReadManifestFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("backup: %s: %s", path, err)
+		}
+		if i == 0 && m.Base != "" {
+			return nil, fmt.Errorf("backup: %s is an incremental backup of %s", path, m.Base)
+		}
+		if i > 0 && m.Base != manifests[i-1].ID {
+			return nil, ErrBrokenChain
+		}
+		manifests[i] = m
+	}
+
+	// Every store comes from the last backup and every file from the backup
+	// that holds its contents.
+	last := manifests[len(manifests)-1]
+	stores := make(map[string]bool)
+	for _, name := range last.Stores {
+		stores[name] = false
+	}
+	files := make(map[string]string)
+	for _, f := range last.Files {
+		files[f.Name] = f.Backup
+	}
+
+	if !force {
+		for name := range stores {
+			if err := checkMissing(name); err != nil {
+				return nil, err
+			}
+		}
+		for name := range files {
+			if err := checkMissing(name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for i, path := range paths {
+		m := manifests[i]
+		err := readArchive(path, func(name string, r io.Reader) error {
+			if done, ok := stores[name]; ok && m == last && !done {
+				stores[name] = true
+				return extract(name, r)
+			}
+			if id, ok := files[name]; ok && id == m.ID {
+				delete(files, name)
+				return extract(name, r)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("backup: %s: %s", path, err)
+		}
+	}
+
+	for name, done := range stores {
+		if !done {
+			return nil, fmt.Errorf("backup: store %s is missing from the archive", name)
+		}
+	}
+	for name, id := range files {
+		return nil, fmt.Errorf("backup: %s of backup %s is missing from the archives", name, id)
+	}
+	return last, nil
+}
+
+func checkMissing(name string) error {
+	path, err := Path(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup: %s already exists", path)
+	}
+	return nil
+}
+
+// Calls fn with every entry after the manifest.
+func readArchive(path string, fn func(name string, r io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	if _, _, err := readManifest(tr); err != nil {
+		return err
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// Writes next to the target and renames so a failed restore never leaves a
+// partial file in place.
+func extract(name string, r io.Reader) error {
+	path, err := Path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), moved.PathMode); err != nil {
+		return err
+	}
+	tmp := path + ".restore"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,179 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoCertificate = errors.New("tls certificate not configured")
+	ErrInvalidCA     = errors.New("no certificates found in tls ca file")
+)
+
+// How often the files are checked for changes.
+var ReloadInterval = time.Second
+
+// Certificates shared by the RESP API, the raft transport and the web
+// server.
+var Default = &Store{}
+
+// Certificate, key and CA files of a node. The files are loaded again when
+// they change so certificates can be replaced without a restart. A failed
+// reload keeps the previous certificates.
+type Store struct {
+	mu       sync.RWMutex
+	certFile string
+	keyFile  string
+	caFile   string
+
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	modTime time.Time
+	checked time.Time
+}
+
+// Sets the files and loads them. An empty certFile disables TLS.
+func (s *Store) Configure(certFile, keyFile, caFile string) error {
+	s.mu.Lock()
+	s.certFile, s.keyFile, s.caFile = certFile, keyFile, caFile
+	s.cert, s.pool = nil, nil
+	s.modTime, s.checked = time.Time{}, time.Time{}
+	s.mu.Unlock()
+
+	if certFile == "" {
+		return nil
+	}
+	return s.Reload()
+}
+
+// Whether TLS is configured.
+func (s *Store) Enabled() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.certFile != ""
+}
+
+// Loads the files again.
+func (s *Store) Reload() error {
+	s.mu.RLock()
+	certFile, keyFile, caFile := s.certFile, s.keyFile, s.caFile
+	s.mu.RUnlock()
+
+	if certFile == "" {
+		return ErrNoCertificate
+	}
+	modTime := lastModified(certFile, keyFile, caFile)
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	var pool *x509.CertPool
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return ErrInvalidCA
+		}
+	}
+
+	s.mu.Lock()
+	s.cert, s.pool = &cert, pool
+	s.modTime, s.checked = modTime, time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// Reloads the files when they changed since the last load.
+func (s *Store) check() {
+	s.mu.Lock()
+	if s.certFile == "" || time.Since(s.checked) < ReloadInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.checked = time.Now()
+	changed := lastModified(s.certFile, s.keyFile, s.caFile).After(s.modTime)
+	s.mu.Unlock()
+
+	if changed {
+		s.Reload()
+	}
+}
+
+func (s *Store) current() (*tls.Certificate, *x509.CertPool, error) {
+	s.check()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.cert == nil {
+		return nil, nil, ErrNoCertificate
+	}
+	return s.cert, s.pool, nil
+}
+
+// Config for listeners with nextProtos offered through ALPN. Clients may
+// present a certificate which is verified against the CA when one is
+// configured.
+func (s *Store) ServerConfig(nextProtos ...string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: nextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool, err := s.current()
+			if err != nil {
+				return nil, err
+			}
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				NextProtos:   nextProtos,
+				Certificates: []tls.Certificate{*cert},
+			}
+			if pool != nil {
+				config.ClientCAs = pool
+				config.ClientAuth = tls.VerifyClientCertIfGiven
+			}
+			return config, nil
+		},
+	}
+}
+
+// Config for dialing other nodes. The node certificate is presented as
+// the client certificate and servers are verified against the CA.
+func (s *Store) ClientConfig(serverName string) *tls.Config {
+	_, pool, _ := s.current()
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		RootCAs:    pool,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _, err := s.current()
+			return cert, err
+		},
+	}
+}
+
+// Whether the peer presented a certificate that was verified.
+func Verified(state *tls.ConnectionState) bool {
+	return state != nil && len(state.VerifiedChains) > 0
+}
+
+func lastModified(files ...string) time.Time {
+	var last time.Time
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+	return last
+}
@@ -0,0 +1,70 @@
+package certs
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// Certificate a client is served by the store.
+func served(t *testing.T, store *Store) []byte {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	go tls.Server(server, store.ServerConfig()).Handshake()
+
+	conn := tls.Client(client, &tls.Config{InsecureSkipVerify: true})
+	if err := conn.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	return conn.ConnectionState().PeerCertificates[0].Raw
+}
+
+func TestStoreReload(t *testing.T) {
+	ReloadInterval = 0
+	defer func() { ReloadInterval = time.Second }()
+
+	dir, err := ioutil.TempDir("", "sliced-certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := &Store{}
+	if store.Enabled() {
+		t.Fatal("expected TLS to be off without a certificate")
+	}
+	certFile, keyFile, err := WriteSelfSigned(dir, "127.0.0.1", "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Configure(certFile, keyFile, certFile); err != nil {
+		t.Fatal(err)
+	}
+	first := served(t, store)
+
+	// Replace the certificate
+	if _, _, err := WriteSelfSigned(dir, "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+
+	if second := served(t, store); bytes.Equal(first, second) {
+		t.Fatal("expected the new certificate to be served")
+	}
+
+	// A broken file keeps the current certificate
+	if err := ioutil.WriteFile(keyFile, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reload(); err == nil {
+		t.Fatal("expected the reload to fail")
+	}
+	served(t, store)
+}
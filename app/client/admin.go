@@ -0,0 +1,245 @@
+package client
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/genzai-io/sliced/common/redigo/redis"
+)
+
+// Member of the raft configuration of the cluster.
+type Member struct {
+	ID       string
+	Addr     string
+	Suffrage string
+	Leader   bool
+}
+
+// Members of the cluster as the leader sees them and the index of their
+// configuration.
+func (c *Client) Members() ([]Member, uint64, error) {
+	values, err := redis.Values(c.Do("RAFTCONFIG"))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(values) == 0 {
+		return nil, 0, errors.New("empty raft configuration")
+	}
+	index, err := redis.Uint64(values[0], nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	leader := c.Leader()
+	members := make([]Member, 0, len(values)-1)
+	for _, value := range values[1:] {
+		server, err := redis.Strings(value, nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(server) != 3 {
+			continue
+		}
+		members = append(members, Member{
+			ID:       server[0],
+			Addr:     server[1],
+			Suffrage: server[2],
+			Leader:   server[1] == leader,
+		})
+	}
+	return members, index, nil
+}
+
+// Adds the member listening at addr to the cluster. Members without a vote
+// only replicate.
+func (c *Client) Join(addr string, voter bool) error {
+	v := 0
+	if voter {
+		v = 1
+	}
+	_, err := c.Do("JOIN", addr, v)
+	return err
+}
+
+// Removes the member listening at addr from the cluster.
+func (c *Client) Remove(addr string) error {
+	_, err := c.Do("REMOVE", addr)
+	return err
+}
+
+// Takes the vote of the member listening at addr.
+func (c *Client) Demote(addr string) error {
+	_, err := c.Do("DEMOTE", addr)
+	return err
+}
+
+// Creates a database and returns its ID.
+func (c *Client) CreateDatabase(name string) (int64, error) {
+	return redis.Int64(c.Do("DB", "CREATE", name))
+}
+
+// Names of the databases sorted.
+func (c *Client) Databases() ([]string, error) {
+	return redis.Strings(c.Do("DB", "LIST"))
+}
+
+// Settings of the leader matching pattern.
+func (c *Client) ConfigGet(pattern string) (map[string]string, error) {
+	return redis.StringMap(c.Do("CONFIG", "GET", pattern))
+}
+
+// Changes a setting of the leader that applies without a restart.
+func (c *Client) ConfigSet(key, value string) error {
+	_, err := c.Do("CONFIG", "SET", key, value)
+	return err
+}
+
+// Writes the running config of the leader to its config file.
+func (c *Client) ConfigRewrite() error {
+	_, err := c.Do("CONFIG", "REWRITE")
+	return err
+}
+
+// INFO of the leader for the sections.
+func (c *Client) Info(sections ...string) (string, error) {
+	args := make([]interface{}, len(sections))
+	for i, section := range sections {
+		args[i] = section
+	}
+	return redis.String(c.Do("INFO", args...))
+}
+
+// Result of a backup.
+type Backup struct {
+	ID     string
+	Base   string
+	Stores int64
+	Files  int64
+	Bytes  int64
+}
+
+// Backs up the stores of the leader to path on the leader. A base makes the
+// backup incremental to the backup at base.
+func (c *Client) Backup(path, base string) (*Backup, error) {
+	args := []interface{}{path}
+	if base != "" {
+		args = append(args, "BASE", base)
+	}
+	values, err := redis.Values(c.Do("BACKUP", args...))
+	if err != nil {
+		return nil, err
+	}
+	b := &Backup{}
+	for i := 0; i+1 < len(values); i += 2 {
+		field, _ := redis.String(values[i], nil)
+		switch field {
+		case "id":
+			b.ID, _ = redis.String(values[i+1], nil)
+		case "base":
+			b.Base, _ = redis.String(values[i+1], nil)
+		case "stores":
+			b.Stores, _ = redis.Int64(values[i+1], nil)
+		case "files":
+			b.Files, _ = redis.Int64(values[i+1], nil)
+		case "bytes":
+			b.Bytes, _ = redis.Int64(values[i+1], nil)
+		}
+	}
+	return b, nil
+}
+
+// Field of an index. Paths are GJSON paths for JSON documents and field
+// paths for protobuf messages.
+type IndexField struct {
+	Path            string
+	Desc            bool
+	CaseInsensitive bool
+}
+
+// Definition of an index over the documents of the keys matching Pattern.
+type Index struct {
+	Name    string
+	Pattern string
+	// JSON or PROTOBUF
+	Codec string
+	// Protobuf message of the documents
+	Message string
+	Fields  []IndexField
+}
+
+// Creates an index.
+func (c *Client) CreateIndex(index Index) error {
+	codec := strings.ToUpper(index.Codec)
+	if codec == "" {
+		codec = "JSON"
+	}
+	args := []interface{}{index.Name, index.Pattern, codec}
+	if codec == "PROTOBUF" {
+		args = append(args, index.Message)
+	}
+	for _, field := range index.Fields {
+		args = append(args, field.Path)
+		if field.Desc {
+			args = append(args, "DESC")
+		}
+		if field.CaseInsensitive {
+			args = append(args, "CI")
+		}
+	}
+	_, err := c.Do("CREATEINDEX", args...)
+	return err
+}
+
+// Bounds of an index query. Min and Max are inclusive and left out when
+// empty. Setting both to the same value matches entries equal to it.
+type IndexQuery struct {
+	Min  string
+	Max  string
+	Desc bool
+	// Entries returned at most. The server picks a limit when zero.
+	Limit int
+}
+
+// Entry of an index.
+type IndexEntry struct {
+	Key   string
+	Value []byte
+}
+
+// Reads the entries of an index in the order of the index. Keys the user
+// may not read are left out.
+func (c *Client) QueryIndex(name string, query IndexQuery) ([]IndexEntry, error) {
+	args := []interface{}{"QUERY", name}
+	if query.Min != "" {
+		args = append(args, "MIN", query.Min)
+	}
+	if query.Max != "" {
+		args = append(args, "MAX", query.Max)
+	}
+	if query.Desc {
+		args = append(args, "DESC")
+	}
+	if query.Limit > 0 {
+		args = append(args, "LIMIT", query.Limit)
+	}
+	values, err := redis.Values(c.Do("INDEX", args...))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]IndexEntry, len(values))
+	for i, value := range values {
+		fields, err := redis.Values(value, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) != 2 {
+			return nil, errors.New("invalid INDEX QUERY reply")
+		}
+		if entries[i].Key, err = redis.String(fields[0], nil); err != nil {
+			return nil, err
+		}
+		if entries[i].Value, err = redis.Bytes(fields[1], nil); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,307 @@
+// Package client talks to a cluster over the API. Commands go to the raft
+// leader and "not the leader" replies are followed to the new leader.
+package client
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/common/raft"
+	"github.com/genzai-io/sliced/common/redigo/redis"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+var (
+	// Returned when none of the members could be reached.
+	ErrNoMembers = errors.New("no members to connect to")
+	// Returned when a command was sent to a new leader more than
+	// MaxRedirects times.
+	ErrTooManyRedirects = errors.New("too many redirects")
+	// Returned by Close when the client is already closed.
+	ErrClosed = errors.New("client closed")
+
+	// Returned for keys and members that do not exist.
+	ErrNil = redis.ErrNil
+)
+
+// Options of a client. Only Addrs is required.
+type Options struct {
+	// API addresses of some members of the cluster
+	Addrs []string
+
+	// Credentials sent with AUTH when Password is set
+	User     string
+	Password string
+
+	// Dials the members over TLS when set
+	TLSConfig *tls.Config
+	// Dials the members. Overrides TLSConfig.
+	Dial func(network, addr string) (net.Conn, error)
+
+	// Idle connections kept per member
+	MaxIdle int
+	// Idle connections are closed after
+	IdleTimeout time.Duration
+	// New leaders a single command is sent to
+	MaxRedirects int
+}
+
+// Connections to the members of a cluster pooled per member. A client is
+// safe to use from several goroutines.
+type Client struct {
+	opts Options
+
+	mu     sync.RWMutex
+	pools  map[string]*redis.Pool
+	leader string
+	closed bool
+}
+
+// Connects to the first reachable member of opts.Addrs and asks it for the
+// leader.
+func New(opts Options) (*Client, error) {
+	if len(opts.Addrs) == 0 {
+		return nil, ErrNoMembers
+	}
+	if opts.MaxIdle <= 0 {
+		opts.MaxIdle = 5
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = time.Minute
+	}
+	if opts.MaxRedirects <= 0 {
+		opts.MaxRedirects = 5
+	}
+	c := &Client{
+		opts:  opts,
+		pools: make(map[string]*redis.Pool),
+	}
+	if err := c.discover(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Closes the connections to every member.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	c.closed = true
+	for _, pool := range c.pools {
+		pool.Close()
+	}
+	c.pools = nil
+	return nil
+}
+
+// Address of the raft leader last seen. Empty until a member knows it.
+func (c *Client) Leader() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.leader
+}
+
+// Asks the members for the leader until one knows it. Fails when no member
+// can be reached.
+func (c *Client) discover() error {
+	var err error = ErrNoMembers
+	reached := false
+	for _, addr := range c.members() {
+		var leader string
+		leader, err = redis.String(c.doAt(addr, "LEADER"))
+		if err != nil && err != ErrNil {
+			continue
+		}
+		reached = true
+		if leader != "" {
+			c.setLeader(leader)
+			return nil
+		}
+	}
+	if reached {
+		// Reachable but without a leader yet
+		return nil
+	}
+	return err
+}
+
+// Leader first followed by the seeds.
+func (c *Client) members() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	members := make([]string, 0, len(c.opts.Addrs)+1)
+	if c.leader != "" {
+		members = append(members, c.leader)
+	}
+	for _, addr := range c.opts.Addrs {
+		if addr != c.leader {
+			members = append(members, addr)
+		}
+	}
+	return members
+}
+
+func (c *Client) setLeader(addr string) {
+	c.mu.Lock()
+	c.leader = addr
+	c.mu.Unlock()
+}
+
+// Member commands are sent to.
+func (c *Client) route() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.leader != "" {
+		return c.leader
+	}
+	return c.opts.Addrs[0]
+}
+
+func (c *Client) pool(addr string) (*redis.Pool, error) {
+	c.mu.RLock()
+	pool, ok := c.pools[addr]
+	closed := c.closed
+	c.mu.RUnlock()
+	if ok {
+		return pool, nil
+	}
+	if closed {
+		return nil, ErrClosed
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, ErrClosed
+	}
+	if pool, ok = c.pools[addr]; ok {
+		return pool, nil
+	}
+	pool = &redis.Pool{
+		MaxIdle:     c.opts.MaxIdle,
+		IdleTimeout: c.opts.IdleTimeout,
+		Dial: func() (redis.Conn, error) {
+			return c.dial(addr)
+		},
+	}
+	c.pools[addr] = pool
+	return pool, nil
+}
+
+func (c *Client) dial(addr string) (redis.Conn, error) {
+	var opts []redis.DialOption
+	switch {
+	case c.opts.Dial != nil:
+		opts = append(opts, redis.DialNetDial(c.opts.Dial))
+	case c.opts.TLSConfig != nil:
+		opts = append(opts, redis.DialUseTLS(true), redis.DialTLSConfig(c.opts.TLSConfig))
+	}
+	conn, err := redis.Dial("tcp", addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if c.opts.Password != "" {
+		if _, err := conn.Do("AUTH", c.opts.User, c.opts.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Sends a command to the leader.
+func (c *Client) Do(name string, args ...interface{}) (interface{}, error) {
+	return c.do(c.route(), name, args...)
+}
+
+// Sends a command of the server and returns its reply.
+func (c *Client) Send(command api.Command) (interface{}, error) {
+	name, args, err := marshal(command)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(name, args...)
+}
+
+// Name and arguments of a command of the server.
+func marshal(command api.Command) (string, []interface{}, error) {
+	packet, _, err := resp.ParseCommand(command.Marshal(nil))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(packet) == 0 {
+		return "", nil, errors.New("empty command")
+	}
+	args := make([]interface{}, len(packet)-1)
+	for i, arg := range packet[1:] {
+		args[i] = arg
+	}
+	return string(packet[0]), args, nil
+}
+
+// Sends a command to addr and follows it to the leader.
+func (c *Client) do(addr string, name string, args ...interface{}) (interface{}, error) {
+	for i := 0; ; i++ {
+		reply, err := c.doAt(addr, name, args...)
+		if err == nil {
+			return reply, nil
+		}
+
+		if notLeader(err) {
+			if i == c.opts.MaxRedirects {
+				return nil, ErrTooManyRedirects
+			}
+			leader, lerr := redis.String(c.doAt(addr, "LEADER"))
+			if lerr != nil || leader == "" || leader == addr {
+				return nil, err
+			}
+			c.setLeader(leader)
+			addr = leader
+			continue
+		}
+		if _, ok := err.(redis.Error); ok || err == ErrNil || err == ErrClosed {
+			return reply, err
+		}
+		// The member cannot be reached. Look for a new leader when it was
+		// the leader.
+		if i == c.opts.MaxRedirects || addr != c.Leader() {
+			return nil, err
+		}
+		c.setLeader("")
+		if c.discover() != nil || c.Leader() == "" {
+			return nil, err
+		}
+		addr = c.Leader()
+	}
+}
+
+// Sends a command on a pooled connection to addr. Error replies are returned
+// as the error.
+func (c *Client) doAt(addr string, name string, args ...interface{}) (interface{}, error) {
+	pool, err := c.pool(addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := pool.Get()
+	defer conn.Close()
+	reply, err := conn.Do(name, args...)
+	if err == nil && reply == nil {
+		err = ErrNil
+	}
+	return reply, err
+}
+
+// Whether an error asks for the command to go to the leader.
+func notLeader(err error) bool {
+	e, ok := err.(redis.Error)
+	return ok && strings.Contains(string(e), raft.ErrNotLeader.Error())
+}
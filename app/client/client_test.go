@@ -0,0 +1,368 @@
+package client_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/client"
+	"github.com/genzai-io/sliced/app/core"
+	cmd_server "github.com/genzai-io/sliced/app/server/cmd"
+	"github.com/genzai-io/sliced/common/redigo/redis"
+	"github.com/genzai-io/sliced/common/resp"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+// API address of the node started by TestMain.
+var addr string
+
+func TestMain(m *testing.M) {
+	stop, err := startNode()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	stop()
+	os.Exit(code)
+}
+
+// Starts a node bootstrapped in a temporary home directory with the API on a
+// free local port.
+func startNode() (stop func(), err error) {
+	home, err := ioutil.TempDir("", "sliced-client")
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		os.RemoveAll(home)
+		return nil, err
+	}
+	addr = l.Addr().String()
+	l.Close()
+
+	// Directories the node does not take from the config are made in the
+	// working directory
+	wd, err := os.Getwd()
+	if err != nil {
+		os.RemoveAll(home)
+		return nil, err
+	}
+	if err := os.Chdir(home); err != nil {
+		os.RemoveAll(home)
+		return nil, err
+	}
+	cleanup := func() {
+		os.Chdir(wd)
+		os.RemoveAll(home)
+	}
+
+	moved.LogLevel = int(zerolog.ErrorLevel)
+	moved.HomeDir = home
+	viper.Set("data.path", home+"/data")
+	viper.Set("store.path", home+"/store")
+	viper.Set("api.host", addr)
+	viper.Set("bootstrap", true)
+	if err := moved.Configure(); err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	if err := core.Instance.Start(); err != nil {
+		cleanup()
+		return nil, err
+	}
+	server := cmd_server.NewServer()
+	stop = func() {
+		server.Stop()
+		core.Instance.Stop()
+		cleanup()
+	}
+	if err := server.Start(); err != nil {
+		core.Instance.Stop()
+		cleanup()
+		return nil, err
+	}
+
+	for deadline := time.Now().Add(10 * time.Second); !core.Instance.Cluster.IsLeader(); time.Sleep(50 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			stop()
+			return nil, fmt.Errorf("node did not become the leader")
+		}
+	}
+	return stop, nil
+}
+
+func newClient(t *testing.T) *client.Client {
+	c, err := client.New(client.Options{Addrs: []string{addr}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClient_Leader(t *testing.T) {
+	c := newClient(t)
+	defer c.Close()
+	// The leader is the address the node advertises to raft
+	members, _, err := c.Members()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 || !members[0].Leader || c.Leader() != members[0].Addr {
+		t.Fatalf("expected the node to lead alone, got %+v", members)
+	}
+
+	if err := c.ConfigSet("slowlog.max-len", "10"); err != nil {
+		t.Fatal(err)
+	}
+	settings, err := c.ConfigGet("slowlog.max-len")
+	if err != nil || settings["slowlog.max-len"] != "10" {
+		t.Fatalf("expected 10, got %v %v", settings, err)
+	}
+}
+
+func TestClient_NoMembers(t *testing.T) {
+	if _, err := client.New(client.Options{}); err != client.ErrNoMembers {
+		t.Fatalf("expected ErrNoMembers, got %v", err)
+	}
+	if _, err := client.New(client.Options{Addrs: []string{"127.0.0.1:1"}}); err == nil {
+		t.Fatal("expected dial error")
+	}
+}
+
+func TestClient_Closed(t *testing.T) {
+	c := newClient(t)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != client.ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	if _, err := c.Get("closed"); err != client.ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestClient_KV(t *testing.T) {
+	c := newClient(t)
+	defer c.Close()
+
+	if err := c.Set("kv:1", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	value, err := c.Get("kv:1")
+	if err != nil || string(value) != "a" {
+		t.Fatalf("expected a, got %q %v", value, err)
+	}
+	if _, err := c.Get("kv:missing"); err != client.ErrNil {
+		t.Fatalf("expected ErrNil, got %v", err)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	c := newClient(t)
+	defer c.Close()
+
+	p := c.Pipeline()
+	p.Set("pipeline:a", []byte("1"))
+	p.Set("pipeline:b", []byte("2"))
+	p.Get("pipeline:a")
+	p.Get("pipeline:missing")
+	p.Do("NOPE")
+	replies, err := p.Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replies) != 5 {
+		t.Fatalf("expected 5 replies, got %d", len(replies))
+	}
+	if s, _ := redis.String(replies[1], nil); s != "OK" {
+		t.Fatalf("expected OK, got %v", replies[1])
+	}
+	if s, _ := redis.String(replies[2], nil); s != "1" {
+		t.Fatalf("expected 1, got %v", replies[2])
+	}
+	if replies[3] != nil {
+		t.Fatalf("expected nil, got %v", replies[3])
+	}
+	if _, ok := replies[4].(redis.Error); !ok {
+		t.Fatalf("expected an error reply, got %v", replies[4])
+	}
+	if p.Len() != 0 {
+		t.Fatal("expected an empty pipeline")
+	}
+
+	n, err := c.Del("pipeline:a", "pipeline:b", "pipeline:missing")
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 deleted, got %d %v", n, err)
+	}
+}
+
+func TestClient_Topic(t *testing.T) {
+	c := newClient(t)
+	defer c.Close()
+
+	topic := fmt.Sprintf("orders-%d", time.Now().UnixNano())
+	if _, err := c.TopicCreate(topic, client.TopicOptions{Codec: "JSON", Sync: "50"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.TopicCreate(topic, client.TopicOptions{}); err == nil {
+		t.Fatal("expected the topic to exist")
+	}
+	if _, err := c.TopicCreate("invalid", client.TopicOptions{Sync: "sometimes"}); err == nil {
+		t.Fatal("expected an invalid sync option")
+	}
+
+	n, err := c.TopicAppend(topic, []byte(`{"id":1}`), []byte(`{"id":2}`))
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 appended, got %d %v", n, err)
+	}
+	if _, err := c.TopicAppend("missing", []byte("x")); err == nil {
+		t.Fatal("expected a missing topic")
+	}
+
+	records, err := c.TopicRead(topic, 1, 10)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("expected 1 record, got %v %v", records, err)
+	}
+	if records[0].Offset != 1 || string(records[0].Data) != `{"id":2}` {
+		t.Fatalf("expected the second record, got %+v", records[0])
+	}
+	names, err := c.Topics()
+	if err != nil || !contains(names, topic) {
+		t.Fatalf("expected %s in %v %v", topic, names, err)
+	}
+}
+
+func TestClient_Database(t *testing.T) {
+	c := newClient(t)
+	defer c.Close()
+
+	db := fmt.Sprintf("db-%d", time.Now().UnixNano())
+	if _, err := c.CreateDatabase(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateDatabase(db); err == nil {
+		t.Fatal("expected the database to exist")
+	}
+	names, err := c.Databases()
+	if err != nil || !contains(names, db) {
+		t.Fatalf("expected %s in %v %v", db, names, err)
+	}
+}
+
+func TestClient_Queue(t *testing.T) {
+	c := newClient(t)
+	defer c.Close()
+
+	queue := fmt.Sprintf("jobs-%d", time.Now().UnixNano())
+	if _, err := c.QueueCreate(queue); err != nil {
+		t.Fatal(err)
+	}
+	ids, err := c.QueueEnqueue(queue, []byte("a"), []byte("b"))
+	if err != nil || len(ids) != 2 {
+		t.Fatalf("expected 2 IDs, got %v %v", ids, err)
+	}
+
+	messages, err := c.QueueDequeue(queue, 5, time.Minute)
+	if err != nil || len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %v %v", messages, err)
+	}
+	if messages[0].ID != ids[0] || string(messages[0].Data) != "a" {
+		t.Fatalf("expected a first, got %+v", messages[0])
+	}
+	if messages, _ = c.QueueDequeue(queue, 5, time.Minute); len(messages) != 0 {
+		t.Fatalf("expected the messages to stay hidden, got %v", messages)
+	}
+
+	n, err := c.QueueAck(queue, ids...)
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 acknowledged, got %d %v", n, err)
+	}
+	if _, err := c.QueueEnqueue("missing", []byte("x")); err == nil {
+		t.Fatal("expected a missing queue")
+	}
+	names, err := c.Queues()
+	if err != nil || !contains(names, queue) {
+		t.Fatalf("expected %s in %v %v", queue, names, err)
+	}
+}
+
+func TestClient_QueryIndex(t *testing.T) {
+	c := newClient(t)
+	defer c.Close()
+
+	err := c.CreateIndex(client.Index{
+		Name:    "people",
+		Pattern: "person:*",
+		Fields:  []client.IndexField{{Path: "age"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, age := range []int{30, 20, 40} {
+		if err := c.Set(fmt.Sprintf("person:%d", i), []byte(fmt.Sprintf(`{"age":%d}`, age))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := c.QueryIndex("people", client.IndexQuery{Min: "25"})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %v %v", entries, err)
+	}
+	if entries[0].Key != "person:0" || entries[1].Key != "person:2" {
+		t.Fatalf("expected person:0 and person:2, got %v", entries)
+	}
+	entries, err = c.QueryIndex("people", client.IndexQuery{Desc: true, Limit: 1})
+	if err != nil || len(entries) != 1 || entries[0].Key != "person:2" {
+		t.Fatalf("expected person:2, got %v %v", entries, err)
+	}
+	if _, err := c.QueryIndex("missing", client.IndexQuery{}); err == nil {
+		t.Fatal("expected a missing index")
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Command of the server as the api package sees it.
+type testGet struct{ key string }
+
+func (c testGet) Name() string                             { return "GET" }
+func (c testGet) Help() string                             { return "GET key" }
+func (c testGet) IsError() bool                            { return false }
+func (c testGet) IsWorker() bool                           { return false }
+func (c testGet) Parse(args [][]byte) api.Command          { return c }
+func (c testGet) Handle(ctx *api.Context) api.CommandReply { return api.NIL }
+func (c testGet) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 2)
+	b = resp.AppendBulkString(b, "GET")
+	return resp.AppendBulkString(b, c.key)
+}
+
+func TestClient_Send(t *testing.T) {
+	c := newClient(t)
+	defer c.Close()
+	if err := c.Set("send", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := redis.String(c.Send(testGet{key: "send"}))
+	if err != nil || value != "v" {
+		t.Fatalf("expected v, got %q %v", value, err)
+	}
+}
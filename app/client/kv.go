@@ -0,0 +1,77 @@
+package client
+
+import (
+	"github.com/genzai-io/sliced/common/redigo/redis"
+)
+
+// Value of key. Returns ErrNil when the key does not exist.
+func (c *Client) Get(key string) ([]byte, error) {
+	return redis.Bytes(c.Do("GET", key))
+}
+
+// Sets key to value.
+func (c *Client) Set(key string, value []byte) error {
+	_, err := c.Do("SET", key, value)
+	return err
+}
+
+// Deletes the keys and returns how many existed.
+func (c *Client) Del(keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	p := c.Pipeline()
+	for _, key := range keys {
+		p.Do("DEL", key)
+	}
+	replies, err := p.Exec()
+	if err != nil {
+		return 0, err
+	}
+	var removed int64
+	for _, reply := range replies {
+		n, err := redis.Int64(reply, nil)
+		if err != nil {
+			return removed, err
+		}
+		removed += n
+	}
+	return removed, nil
+}
+
+// Adds elements to the HyperLogLog at key. Returns whether its estimate
+// changed.
+func (c *Client) PFAdd(key string, elements ...string) (bool, error) {
+	args := make([]interface{}, 0, len(elements)+1)
+	args = append(args, key)
+	for _, element := range elements {
+		args = append(args, element)
+	}
+	return redis.Bool(c.Do("PFADD", args...))
+}
+
+// Estimated number of unique elements added to the HyperLogLog at key.
+func (c *Client) PFCount(key string) (int64, error) {
+	return redis.Int64(c.Do("PFCOUNT", key))
+}
+
+// Publishes message to channel and returns the number of subscribers that
+// received it.
+func (c *Client) Publish(channel string, message []byte) (int64, error) {
+	return redis.Int64(c.Do("PUBLISH", channel, message))
+}
+
+// Queues a GET. Its reply is nil when the key does not exist.
+func (p *Pipeline) Get(key string) {
+	p.Do("GET", key)
+}
+
+// Queues a SET.
+func (p *Pipeline) Set(key string, value []byte) {
+	p.Do("SET", key, value)
+}
+
+// Queues a DEL of a single key.
+func (p *Pipeline) Del(key string) {
+	p.Do("DEL", key)
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/common/redigo/redis"
+)
+
+// Commands sent together. Exec sends the commands on one connection before
+// reading the replies.
+type Pipeline struct {
+	c        *Client
+	commands []pipelined
+}
+
+type pipelined struct {
+	addr string
+	name string
+	args []interface{}
+}
+
+// Pipeline of commands sent with Exec.
+func (c *Client) Pipeline() *Pipeline {
+	return &Pipeline{c: c}
+}
+
+// Queues a command.
+func (p *Pipeline) Do(name string, args ...interface{}) {
+	p.commands = append(p.commands, pipelined{addr: p.c.route(), name: name, args: args})
+}
+
+// Queues a command of the server.
+func (p *Pipeline) Send(command api.Command) error {
+	name, args, err := marshal(command)
+	if err != nil {
+		return err
+	}
+	p.Do(name, args...)
+	return nil
+}
+
+// Number of queued commands.
+func (p *Pipeline) Len() int {
+	return len(p.commands)
+}
+
+// Sends the queued commands and returns their replies in order. Error replies
+// take the place of their reply as a redis.Error. Commands sent to a member
+// that is no longer the leader are sent again on their own. The pipeline is empty afterwards.
+func (p *Pipeline) Exec() ([]interface{}, error) {
+	commands := p.commands
+	p.commands = nil
+
+	byAddr := make(map[string][]int)
+	for i, command := range commands {
+		byAddr[command.addr] = append(byAddr[command.addr], i)
+	}
+
+	replies := make([]interface{}, len(commands))
+	errs := make([]error, len(commands))
+	var wg sync.WaitGroup
+	for addr, indexes := range byAddr {
+		wg.Add(1)
+		go func(addr string, indexes []int) {
+			defer wg.Done()
+			p.c.execAt(addr, commands, indexes, replies, errs)
+		}(addr, indexes)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if _, ok := err.(redis.Error); !ok || notLeader(err) {
+			command := commands[i]
+			replies[i], err = p.c.do(command.addr, command.name, command.args...)
+		}
+		switch err.(type) {
+		case nil:
+		case redis.Error:
+			replies[i] = err
+		default:
+			if err != ErrNil {
+				return replies, err
+			}
+		}
+	}
+	return replies, nil
+}
+
+// Sends the commands at indexes to addr on one connection.
+func (c *Client) execAt(addr string, commands []pipelined, indexes []int, replies []interface{}, errs []error) {
+	fail := func(err error) {
+		for _, i := range indexes {
+			if errs[i] == nil && replies[i] == nil {
+				errs[i] = err
+			}
+		}
+	}
+	pool, err := c.pool(addr)
+	if err != nil {
+		fail(err)
+		return
+	}
+	conn := pool.Get()
+	defer conn.Close()
+
+	for _, i := range indexes {
+		if err := conn.Send(commands[i].name, commands[i].args...); err != nil {
+			fail(err)
+			return
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		fail(err)
+		return
+	}
+	for _, i := range indexes {
+		reply, err := conn.Receive()
+		if err != nil {
+			if _, ok := err.(redis.Error); !ok {
+				fail(err)
+				return
+			}
+		}
+		replies[i], errs[i] = reply, err
+	}
+}
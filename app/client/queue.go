@@ -0,0 +1,89 @@
+package client
+
+import (
+	"errors"
+	"time"
+
+	"github.com/genzai-io/sliced/common/redigo/redis"
+)
+
+// A message delivered by a queue.
+type Message struct {
+	ID   uint64
+	Data []byte
+}
+
+// Creates a queue and returns its ID.
+func (c *Client) QueueCreate(name string) (int64, error) {
+	return redis.Int64(c.Do("QUEUE", "CREATE", name))
+}
+
+// Names of the queues sorted.
+func (c *Client) Queues() ([]string, error) {
+	return redis.Strings(c.Do("QUEUE", "LIST"))
+}
+
+// Adds messages to the end of a queue and returns the ID of each.
+func (c *Client) QueueEnqueue(name string, messages ...[]byte) ([]uint64, error) {
+	args := make([]interface{}, 0, len(messages)+2)
+	args = append(args, "ENQUEUE", name)
+	for _, message := range messages {
+		args = append(args, message)
+	}
+	values, err := redis.Values(c.Do("QUEUE", args...))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint64, len(values))
+	for i, value := range values {
+		if ids[i], err = redis.Uint64(value, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// Delivers up to count messages of a queue in the order they were enqueued
+// and hides them for visibility. Messages that are not acknowledged by then
+// are delivered again.
+func (c *Client) QueueDequeue(name string, count int, visibility time.Duration) ([]Message, error) {
+	ms := int64(visibility / time.Millisecond)
+	if ms <= 0 {
+		ms = 1
+	}
+	values, err := redis.Values(c.Do("QUEUE", "DEQUEUE", name, "COUNT", count, "VISIBILITY", ms))
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]Message, len(values))
+	for i, value := range values {
+		fields, err := redis.Values(value, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) != 2 {
+			return nil, errors.New("invalid QUEUE DEQUEUE reply")
+		}
+		if messages[i].ID, err = redis.Uint64(fields[0], nil); err != nil {
+			return nil, err
+		}
+		if messages[i].Data, err = redis.Bytes(fields[1], nil); err != nil {
+			return nil, err
+		}
+	}
+	return messages, nil
+}
+
+// Removes delivered messages from a queue and returns how many were
+// removed. Messages acknowledged before are not counted.
+func (c *Client) QueueAck(name string, ids ...uint64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	args := make([]interface{}, 0, len(ids)+2)
+	args = append(args, "ACK", name)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	return redis.Int64(c.Do("QUEUE", args...))
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"errors"
+
+	"github.com/genzai-io/sliced/common/redigo/redis"
+)
+
+// Options of a topic. Empty options keep the defaults of the server.
+type TopicOptions struct {
+	// Protobuf message the records are validated against
+	Schema string
+	// PROTOBUF or JSON
+	Codec string
+	// OFF, WARN or REJECT
+	Validation string
+	// ALWAYS, NEVER or the milliseconds between syncs
+	Sync string
+}
+
+// Creates a topic and returns its ID.
+func (c *Client) TopicCreate(name string, opts TopicOptions) (int64, error) {
+	args := []interface{}{"CREATE", name}
+	for _, option := range []struct{ name, value string }{
+		{"SCHEMA", opts.Schema},
+		{"CODEC", opts.Codec},
+		{"VALIDATION", opts.Validation},
+		{"SYNC", opts.Sync},
+	} {
+		if option.value != "" {
+			args = append(args, option.name, option.value)
+		}
+	}
+	return redis.Int64(c.Do("TOPIC", args...))
+}
+
+// Names of the topics sorted.
+func (c *Client) Topics() ([]string, error) {
+	return redis.Strings(c.Do("TOPIC", "LIST"))
+}
+
+// Appends records to a topic and returns how many were appended. A record
+// rejected by the schema of the topic fails the whole append.
+func (c *Client) TopicAppend(name string, records ...[]byte) (int64, error) {
+	args := make([]interface{}, 0, len(records)+2)
+	args = append(args, "APPEND", name)
+	for _, record := range records {
+		args = append(args, record)
+	}
+	return redis.Int64(c.Do("TOPIC", args...))
+}
+
+// A record of a topic.
+type Record struct {
+	// Position of the record in the topic counting from zero
+	Offset uint64
+	// Index of the log entry that appended the record
+	LogID uint64
+	Data  []byte
+}
+
+// Reads up to count records of a topic starting at offset. Fewer records
+// are returned once the end of the topic is reached.
+func (c *Client) TopicRead(name string, offset uint64, count int) ([]Record, error) {
+	values, err := redis.Values(c.Do("TOPIC", "READ", name, "FROM", offset, "COUNT", count))
+	if err != nil {
+		return nil, err
+	}
+	records := make([]Record, len(values))
+	for i, value := range values {
+		fields, err := redis.Values(value, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) != 3 {
+			return nil, errors.New("invalid TOPIC READ reply")
+		}
+		r := &records[i]
+		if r.Offset, err = redis.Uint64(fields[0], nil); err != nil {
+			return nil, err
+		}
+		if r.LogID, err = redis.Uint64(fields[1], nil); err != nil {
+			return nil, err
+		}
+		if r.Data, err = redis.Bytes(fields[2], nil); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"github.com/genzai-io/sliced/app/acl"
+	"github.com/genzai-io/sliced/app/api"
+)
+
+// Access of the commands. Commands that are not here are Admin commands.
+func init() {
+	var (
+		none     = api.Access{}
+		read     = api.Access{Category: acl.Read}
+		readKey  = api.Access{Category: acl.Read, FirstKey: 1, LastKey: 1}
+		readKeys = api.Access{Category: acl.Read, FirstKey: 1, LastKey: -1}
+		writeKey = api.Access{Category: acl.Write, FirstKey: 1, LastKey: 1}
+		script   = api.Access{Category: acl.Write, NumKeys: 2}
+		admin    = api.Access{Category: acl.Admin}
+		raft     = api.Access{Category: acl.Raft}
+		// Topic and queue names follow the subcommand and are checked like
+		// channels
+		topic = api.Access{Category: acl.Admin, FirstKey: 2, LastKey: 2, Channels: true}
+		queue = api.Access{Category: acl.Write, FirstKey: 2, LastKey: 2, Channels: true}
+	)
+
+	for name, access := range map[string]api.Access{
+		// Connection
+		"AUTH":         none,
+		"HELLO":        none,
+		"PING":         none,
+		"QUIT":         none,
+		"ASKING":       none,
+		"WATCH":        {FirstKey: 1, LastKey: -1},
+		"UNWATCH":      none,
+		"UNSUBSCRIBE":  none,
+		"PUNSUBSCRIBE": none,
+
+		// Keyspace
+		"GET":     readKey,
+		"DUMP":    readKey,
+		"PFCOUNT": readKeys,
+		"SET":     writeKey,
+		"PFADD":   writeKey,
+		"RESTORE": writeKey,
+		"DEL":     {Category: acl.Write, FirstKey: 1, LastKey: -1},
+		"PFMERGE": {Category: acl.Write, FirstKey: 1, LastKey: -1},
+		// Only the declared keys are checked
+		"EVAL":    script,
+		"EVALSHA": script,
+
+		// Pub/Sub
+		"SUBSCRIBE":  {Category: acl.Read, FirstKey: 1, LastKey: -1, Channels: true},
+		"PSUBSCRIBE": {Category: acl.Read, FirstKey: 1, LastKey: -1, Patterns: true},
+		"PUBLISH":    {Category: acl.Write, FirstKey: 1, LastKey: 1, Channels: true},
+
+		// Topics
+		"TOPIC": {
+			Category: acl.Admin, FirstKey: 2, LastKey: 2, Channels: true,
+			Subcommands: map[string]api.Access{
+				TopicCreate: topic,
+				TopicAlter:  topic,
+				TopicAppend: {Category: acl.Write, FirstKey: 2, LastKey: 2, Channels: true},
+				TopicRead:   {Category: acl.Read, FirstKey: 2, LastKey: 2, Channels: true},
+				TopicList:   read,
+			},
+		},
+
+		// Queues
+		"QUEUE": {
+			Category: acl.Admin, FirstKey: 2, LastKey: 2, Channels: true,
+			Subcommands: map[string]api.Access{
+				QueueCreate:  topic,
+				QueueEnqueue: queue,
+				QueueDequeue: queue,
+				QueueAck:     queue,
+				QueueList:    read,
+			},
+		},
+
+		// Databases
+		"DB": {
+			Category: acl.Admin,
+			Subcommands: map[string]api.Access{
+				DBCreate: admin,
+				DBList:   read,
+			},
+		},
+
+		// Schemas, indexes and settings
+		"SCHEMAGET":   read,
+		"SCHEMALIST":  read,
+		"SCHEMAADD":   admin,
+		"SCHEMADEL":   admin,
+		"CREATEINDEX": admin,
+		"INDEX":       read,
+		"CONFIG":      admin,
+		"ACL":         admin,
+
+		// Raft and cluster membership
+		api.RaftInstallSnapshotName: raft,
+		api.RaftAppendName:          raft,
+		api.RaftVoteName:            raft,
+		api.RaftChunkName:           raft,
+		api.RaftDoneName:            raft,
+		api.RaftSnapshotName:        raft,
+		api.RaftSnapshotsName:       raft,
+		api.RaftSlice:               raft,
+		api.RaftBootstrap:           raft,
+		api.RaftJoinName:            raft,
+		api.RaftDemote:              raft,
+		api.RaftJoinSlaveName:       raft,
+		api.RaftRemoveName:          raft,
+		api.RaftStatsName:           raft,
+		api.RaftStateName:           raft,
+		api.RaftConfigName:          raft,
+		api.RaftLeaderName:          raft,
+		api.RaftShrinkName:          raft,
+		"VOTE":                      raft,
+		"RAFTCONFIG":                raft,
+		"PUBRELAY":                  raft,
+	} {
+		api.RegisterAccess(name, access)
+	}
+}
@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/genzai-io/sliced/app/acl"
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() {
+	api.Register(&ACL{})
+	api.RegisterApply(ApplyACLSetUserName, func() api.ApplyCommand { return &ApplyACLSetUser{} })
+	api.RegisterApply(ApplyACLDelUserName, func() api.ApplyCommand { return &ApplyACLDelUser{} })
+}
+
+const (
+	ApplyACLSetUserName = "ACLSETUSER"
+	ApplyACLDelUserName = "ACLDELUSER"
+)
+
+const (
+	ACLSetUser = "SETUSER"
+	ACLDelUser = "DELUSER"
+	ACLGetUser = "GETUSER"
+	ACLList    = "LIST"
+	ACLUsers   = "USERS"
+	ACLWhoAmI  = "WHOAMI"
+	ACLCat     = "CAT"
+)
+
+// Manages the users of the cluster. Users are changed through the
+// cluster log.
+//
+//	ACL SETUSER username [rule ...]
+//	ACL DELUSER username [username ...]
+//	ACL GETUSER username
+//	ACL LIST
+//	ACL USERS
+//	ACL WHOAMI
+//	ACL CAT
+type ACL struct {
+	Sub  string
+	Args []string
+}
+
+func (c *ACL) Name() string { return "ACL" }
+func (c *ACL) Help() string {
+	return "ACL SETUSER username [rule ...] | DELUSER username [username ...] | GETUSER username | LIST | USERS | WHOAMI | CAT"
+}
+func (c *ACL) IsError() bool  { return false }
+func (c *ACL) IsWorker() bool { return c.Sub == ACLSetUser || c.Sub == ACLDelUser }
+
+func (c *ACL) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 2+len(c.Args))
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Sub)
+	return appendKeys(buf, c.Args)
+}
+
+func (c *ACL) Parse(args [][]byte) Command {
+	if len(args) < 2 {
+		return Err("ERR wrong number of arguments for 'acl' command")
+	}
+	cmd := &ACL{
+		Sub:  strings.ToUpper(string(args[1])),
+		Args: parseKeys(args[2:]),
+	}
+
+	switch cmd.Sub {
+	default:
+		return Err("ERR unknown ACL subcommand '" + string(args[1]) + "'")
+	case ACLSetUser, ACLDelUser:
+		if len(cmd.Args) == 0 {
+			return Err("ERR wrong number of arguments for 'acl|" + strings.ToLower(cmd.Sub) + "' command")
+		}
+	case ACLGetUser:
+		if len(cmd.Args) != 1 {
+			return Err("ERR wrong number of arguments for 'acl|getuser' command")
+		}
+	case ACLList, ACLUsers, ACLWhoAmI, ACLCat:
+		if len(cmd.Args) != 0 {
+			return Err("ERR wrong number of arguments for 'acl|" + strings.ToLower(cmd.Sub) + "' command")
+		}
+	}
+	return cmd
+}
+
+func (c *ACL) Handle(ctx *Context) Reply {
+	switch c.Sub {
+	case ACLSetUser:
+		if acl.Default.IsLocal(c.Args[0]) {
+			return Err(acl.ErrLocalUser.Error())
+		}
+		// Check the rules before they reach the log
+		user, ok := acl.Default.Get(c.Args[0])
+		if ok {
+			user = user.Clone()
+		} else {
+			user = acl.NewUser(c.Args[0])
+		}
+		if err := user.SetRules(c.Args[1:]...); err != nil {
+			return Err(err.Error())
+		}
+		return api.Propose(&ApplyACLSetUser{Username: c.Args[0], Rules: acl.HashRules(c.Args[1:])})
+
+	case ACLDelUser:
+		for _, name := range c.Args {
+			if name == acl.DefaultUser {
+				return Err(acl.ErrDefaultUser.Error())
+			}
+			if acl.Default.IsLocal(name) {
+				return Err(acl.ErrLocalUser.Error())
+			}
+		}
+		return api.Propose(&ApplyACLDelUser{Usernames: c.Args})
+
+	case ACLGetUser:
+		user, ok := acl.Default.Get(c.Args[0])
+		if !ok {
+			return api.NIL
+		}
+		return userReply(user)
+
+	case ACLList:
+		names := acl.Default.Names()
+		reply := make(api.Array, 0, len(names))
+		for _, name := range names {
+			if user, ok := acl.Default.Get(name); ok {
+				reply = append(reply, api.BulkString("user "+name+" "+strings.Join(user.Rules(), " ")))
+			}
+		}
+		return reply
+
+	case ACLUsers:
+		return stringArray(acl.Default.Names())
+
+	case ACLWhoAmI:
+		if ctx.User == "" {
+			return api.BulkString(acl.DefaultUser)
+		}
+		return api.BulkString(ctx.User)
+
+	case ACLCat:
+		return stringArray(acl.Categories())
+	}
+	return Err("ERR unknown ACL subcommand")
+}
+
+func userReply(user *acl.User) Reply {
+	var flags []string
+	if user.Enabled {
+		flags = append(flags, "on")
+	} else {
+		flags = append(flags, "off")
+	}
+	if user.NoPass {
+		flags = append(flags, "nopass")
+	}
+	return api.Array{
+		api.BulkString("flags"), stringArray(flags),
+		api.BulkString("passwords"), stringArray(user.Passwords),
+		api.BulkString("categories"), stringArray(strings.Split(user.Categories.String(), ",")),
+		api.BulkString("keys"), stringArray(user.Keys),
+		api.BulkString("channels"), stringArray(user.Channels),
+	}
+}
+
+func stringArray(values []string) api.Array {
+	reply := make(api.Array, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			reply = append(reply, api.BulkString(v))
+		}
+	}
+	return reply
+}
+
+// Cluster log entry for ACL SETUSER.
+type ApplyACLSetUser struct {
+	Username string
+	Rules    []string
+}
+
+func (c *ApplyACLSetUser) Name() string { return ApplyACLSetUserName }
+
+func (c *ApplyACLSetUser) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 2+len(c.Rules))
+	b = resp.AppendBulkString(b, c.Name())
+	b = resp.AppendBulkString(b, c.Username)
+	return appendKeys(b, c.Rules)
+}
+
+func (c *ApplyACLSetUser) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return api.ErrInvalidParam
+	}
+	c.Username = string(args[1])
+	c.Rules = parseKeys(args[2:])
+	return nil
+}
+
+func (c *ApplyACLSetUser) Handle() api.CommandReply {
+	if err := acl.Default.SetUser(c.Username, c.Rules...); err != nil {
+		return Err(err.Error())
+	}
+	return api.OK
+}
+
+// Cluster log entry for ACL DELUSER.
+type ApplyACLDelUser struct {
+	Usernames []string
+}
+
+func (c *ApplyACLDelUser) Name() string { return ApplyACLDelUserName }
+
+func (c *ApplyACLDelUser) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 1+len(c.Usernames))
+	b = resp.AppendBulkString(b, c.Name())
+	return appendKeys(b, c.Usernames)
+}
+
+func (c *ApplyACLDelUser) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return api.ErrInvalidParam
+	}
+	c.Usernames = parseKeys(args[1:])
+	return nil
+}
+
+func (c *ApplyACLDelUser) Handle() api.CommandReply {
+	removed, err := acl.Default.DelUser(c.Usernames...)
+	if err != nil {
+		return Err(err.Error())
+	}
+	return api.Int(removed)
+}
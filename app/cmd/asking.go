@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&Asking{}) }
+
+// Sent by clients that follow an ASK redirect before the command. Slots are
+// not moved between members yet so it only acknowledges.
+//
+//	ASKING
+type Asking struct{}
+
+func (c *Asking) Name() string   { return "ASKING" }
+func (c *Asking) Help() string   { return "ASKING" }
+func (c *Asking) IsError() bool  { return false }
+func (c *Asking) IsWorker() bool { return false }
+
+func (c *Asking) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 1)
+	buf = resp.AppendBulkString(buf, c.Name())
+	return buf
+}
+
+func (c *Asking) Parse(args [][]byte) Command {
+	if len(args) != 1 {
+		return Err("ERR wrong number of arguments for 'asking' command")
+	}
+	return c
+}
+
+func (c *Asking) Handle(ctx *Context) Reply {
+	return Ok
+}
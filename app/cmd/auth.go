@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/acl"
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() {
+	api.Register(&Auth{})
+	api.Register(&Hello{})
+}
+
+// Authenticates the connection. The default user is assumed when only a
+// password is given.
+//
+//	AUTH [username] password
+type Auth struct {
+	Username string
+	Password string
+}
+
+func (c *Auth) Name() string   { return "AUTH" }
+func (c *Auth) Help() string   { return "AUTH [username] password" }
+func (c *Auth) IsError() bool  { return false }
+func (c *Auth) IsWorker() bool { return false }
+
+func (c *Auth) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 3)
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Username)
+	buf = resp.AppendBulkString(buf, c.Password)
+	return buf
+}
+
+func (c *Auth) Parse(args [][]byte) Command {
+	switch len(args) {
+	case 2:
+		return &Auth{Username: acl.DefaultUser, Password: string(args[1])}
+	case 3:
+		return &Auth{Username: string(args[1]), Password: string(args[2])}
+	}
+	return Err("ERR wrong number of arguments for 'auth' command")
+}
+
+func (c *Auth) Handle(ctx *Context) Reply {
+	if _, err := acl.Default.Authenticate(c.Username, c.Password); err != nil {
+		return Err(err.Error())
+	}
+	ctx.User = c.Username
+	return Ok
+}
+
+// Handshake that optionally authenticates and replies with the server
+// properties. Only protocol version 2 is supported.
+//
+//	HELLO [protover [AUTH username password]]
+type Hello struct {
+	Protocol int
+	Auth     *Auth
+}
+
+func (c *Hello) Name() string   { return "HELLO" }
+func (c *Hello) Help() string   { return "HELLO [protover [AUTH username password]]" }
+func (c *Hello) IsError() bool  { return false }
+func (c *Hello) IsWorker() bool { return false }
+
+func (c *Hello) Marshal(buf []byte) []byte {
+	if c.Auth == nil {
+		buf = resp.AppendArray(buf, 2)
+		buf = resp.AppendBulkString(buf, c.Name())
+		return resp.AppendBulkInt(buf, c.Protocol)
+	}
+	buf = resp.AppendArray(buf, 5)
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkInt(buf, c.Protocol)
+	buf = resp.AppendBulkString(buf, "AUTH")
+	buf = resp.AppendBulkString(buf, c.Auth.Username)
+	return resp.AppendBulkString(buf, c.Auth.Password)
+}
+
+func (c *Hello) Parse(args [][]byte) Command {
+	cmd := &Hello{Protocol: 2}
+	if len(args) == 1 {
+		return cmd
+	}
+
+	switch string(args[1]) {
+	case "2":
+	case "3":
+		return Err("NOPROTO sorry, this protocol version is not supported")
+	default:
+		return Err("ERR Protocol version is not an integer or out of range")
+	}
+
+	for i := 2; i < len(args); i++ {
+		if !strings.EqualFold(string(args[i]), "auth") || i+2 >= len(args) {
+			return Err("ERR Syntax error in HELLO option '" + string(args[i]) + "'")
+		}
+		cmd.Auth = &Auth{Username: string(args[i+1]), Password: string(args[i+2])}
+		i += 2
+	}
+	return cmd
+}
+
+func (c *Hello) Handle(ctx *Context) Reply {
+	if c.Auth != nil {
+		if reply := c.Auth.Handle(ctx); reply.IsError() {
+			return reply
+		}
+	}
+	return api.Array{
+		api.BulkString("server"), api.BulkString(moved.Name),
+		api.BulkString("version"), api.BulkString(moved.VersionStr),
+		api.BulkString("proto"), api.Int(c.Protocol),
+		api.BulkString("mode"), api.BulkString("cluster"),
+		api.BulkString("modules"), api.Array{},
+	}
+}
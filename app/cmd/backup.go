@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/backup"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&Backup{}) }
+
+const BackupBase = "BASE"
+
+// Writes a backup of the node to an archive on the node. With a base
+// archive only the files that are missing from it are included. Replies
+// with the id of the backup, the number of stores and files it restores and
+// the bytes of the files it added.
+//
+//	BACKUP path [BASE path]
+type Backup struct {
+	Path string
+	Base string
+}
+
+func (c *Backup) Name() string   { return "BACKUP" }
+func (c *Backup) Help() string   { return "BACKUP path [BASE path]" }
+func (c *Backup) IsError() bool  { return false }
+func (c *Backup) IsWorker() bool { return true }
+
+func (c *Backup) Marshal(buf []byte) []byte {
+	if c.Base == "" {
+		buf = resp.AppendArray(buf, 2)
+		buf = resp.AppendBulkString(buf, c.Name())
+		return resp.AppendBulkString(buf, c.Path)
+	}
+	buf = resp.AppendArray(buf, 4)
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Path)
+	buf = resp.AppendBulkString(buf, BackupBase)
+	return resp.AppendBulkString(buf, c.Base)
+}
+
+func (c *Backup) Parse(args [][]byte) Command {
+	switch {
+	case len(args) == 2:
+		return &Backup{Path: string(args[1])}
+	case len(args) == 4 && strings.ToUpper(string(args[2])) == BackupBase:
+		return &Backup{Path: string(args[1]), Base: string(args[3])}
+	}
+	return Err("ERR wrong number of arguments for 'backup' command")
+}
+
+func (c *Backup) Handle(ctx *Context) Reply {
+	var base *backup.Manifest
+	if c.Base != "" {
+		var err error
+		if base, err = backup.ReadManifestFile(c.Base); err != nil {
+			return Err("ERR " + err.Error())
+		}
+	}
+
+	// Never leave a partial archive at path
+	tmp := c.Path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return Err("ERR " + err.Error())
+	}
+	m, err := backup.Create(f, base)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, c.Path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return Err("ERR " + err.Error())
+	}
+
+	var size int64
+	for _, f := range m.Files {
+		if f.Backup == m.ID {
+			size += f.Size
+		}
+	}
+	return api.Array{
+		api.BulkString("id"), api.BulkString(m.ID),
+		api.BulkString("base"), api.BulkString(m.Base),
+		api.BulkString("stores"), api.Int(len(m.Stores)),
+		api.BulkString("files"), api.Int(len(m.Files)),
+		api.BulkString("bytes"), api.Int(size),
+	}
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/common/match"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&Config{}) }
+
+const (
+	ConfigGet     = "GET"
+	ConfigSet     = "SET"
+	ConfigRewrite = "REWRITE"
+)
+
+// Reads and changes the config of the node while it runs.
+//
+//	CONFIG GET pattern
+//	CONFIG SET key value
+//	CONFIG REWRITE
+//
+// GET replies with the key and value of every setting matching the pattern.
+// SET applies settings that change without a restart and rejects the others.
+// REWRITE writes the running config to the config file.
+type Config struct {
+	Sub  string
+	Args []string
+}
+
+func (c *Config) Name() string   { return "CONFIG" }
+func (c *Config) Help() string   { return "CONFIG GET pattern | SET key value | REWRITE" }
+func (c *Config) IsError() bool  { return false }
+func (c *Config) IsWorker() bool { return true }
+
+func (c *Config) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 2+len(c.Args))
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Sub)
+	return appendKeys(buf, c.Args)
+}
+
+func (c *Config) Parse(args [][]byte) Command {
+	if len(args) < 2 {
+		return Err("ERR wrong number of arguments for 'config' command")
+	}
+	cmd := &Config{
+		Sub:  strings.ToUpper(string(args[1])),
+		Args: parseKeys(args[2:]),
+	}
+
+	want := 0
+	switch cmd.Sub {
+	default:
+		return Err("ERR unknown CONFIG subcommand '" + string(args[1]) + "'")
+	case ConfigGet:
+		want = 1
+	case ConfigSet:
+		want = 2
+	case ConfigRewrite:
+	}
+	if len(cmd.Args) != want {
+		return Err("ERR wrong number of arguments for 'config|" + strings.ToLower(cmd.Sub) + "' command")
+	}
+	return cmd
+}
+
+func (c *Config) Handle(ctx *Context) Reply {
+	switch c.Sub {
+	case ConfigGet:
+		config := moved.CurrentConfig()
+		pattern := strings.ToLower(c.Args[0])
+		reply := make(api.Array, 0, 2)
+		for _, key := range moved.ConfigKeys() {
+			if !match.Match(key, pattern) {
+				continue
+			}
+			value, _ := config.Get(key)
+			reply = append(reply, api.BulkString(key), api.BulkString(value))
+		}
+		return reply
+
+	case ConfigSet:
+		switch err := moved.SetConfig(c.Args[0], c.Args[1]); err {
+		case nil:
+			return api.OK
+		case moved.ErrConfigUnknown:
+			return Err("ERR unknown config setting '" + c.Args[0] + "'")
+		case moved.ErrConfigRestart:
+			return Err("ERR '" + strings.ToLower(c.Args[0]) + "' cannot change until a restart")
+		default:
+			return Err("ERR " + err.Error())
+		}
+
+	case ConfigRewrite:
+		if _, err := moved.RewriteConfig(); err != nil {
+			return Err("ERR " + err.Error())
+		}
+		return api.OK
+	}
+	return Err("ERR unknown CONFIG subcommand '" + c.Sub + "'")
+}
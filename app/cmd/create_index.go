@@ -1 +1,222 @@
 package cmd
+
+import (
+	"strings"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/document"
+	"github.com/genzai-io/sliced/app/table"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() {
+	api.Register(&CreateIndex{})
+	api.RegisterApply(ApplyCreateIndexName, func() api.ApplyCommand { return &ApplyCreateIndex{} })
+	CreateCommands["INDEX"] = &CreateIndex{}
+	table.IndexerFromDef = indexerFromDef
+}
+
+const ApplyCreateIndexName = "IDXDEF"
+
+const (
+	IndexCodecJSON     = "JSON"
+	IndexCodecProtobuf = "PROTOBUF"
+)
+
+// Defines a secondary index on the keyspace over the values of keys matching
+// a pattern. Protobuf encoded values are indexed by field path of a
+// registered message. A repeated field is indexed by a single element, its
+// number of elements or, without either, by each of its elements. A
+// composite index has at most 2 fields and indexes a single entry per value.
+//
+//	CREATEINDEX name pattern JSON path [DESC] [CI] [path [DESC] [CI]]
+//	CREATEINDEX name pattern PROTOBUF message path [DESC] [CI] [path [DESC] [CI]]
+type CreateIndex struct {
+	IndexName string
+	Pattern   string
+	Codec     string
+	Message   string
+	Fields    []document.KeyField
+}
+
+func (c *CreateIndex) Name() string { return "CREATEINDEX" }
+func (c *CreateIndex) Help() string {
+	return "CREATEINDEX name pattern JSON|PROTOBUF [message] path [DESC] [CI] [path [DESC] [CI]]"
+}
+func (c *CreateIndex) IsError() bool  { return false }
+func (c *CreateIndex) IsWorker() bool { return false }
+
+func (c *CreateIndex) Marshal(buf []byte) []byte {
+	return c.marshal(buf, c.Name())
+}
+
+// Writes the definition as a RESP array headed by name.
+func (c *CreateIndex) marshal(buf []byte, name string) []byte {
+	count := 4
+	if c.Codec == IndexCodecProtobuf {
+		count++
+	}
+	for _, f := range c.Fields {
+		count++
+		if f.Desc {
+			count++
+		}
+		if f.CaseInsensitive {
+			count++
+		}
+	}
+
+	buf = resp.AppendArray(buf, count)
+	buf = resp.AppendBulkString(buf, name)
+	buf = resp.AppendBulkString(buf, c.IndexName)
+	buf = resp.AppendBulkString(buf, c.Pattern)
+	buf = resp.AppendBulkString(buf, c.Codec)
+	if c.Codec == IndexCodecProtobuf {
+		buf = resp.AppendBulkString(buf, c.Message)
+	}
+	for _, f := range c.Fields {
+		buf = resp.AppendBulkString(buf, f.Path)
+		if f.Desc {
+			buf = resp.AppendBulkString(buf, "DESC")
+		}
+		if f.CaseInsensitive {
+			buf = resp.AppendBulkString(buf, "CI")
+		}
+	}
+	return buf
+}
+
+func (c *CreateIndex) Parse(args [][]byte) Command {
+	if len(args) < 5 {
+		return Err("ERR invalid params")
+	}
+
+	cmd := &CreateIndex{
+		IndexName: string(args[1]),
+		Pattern:   string(args[2]),
+		Codec:     strings.ToUpper(string(args[3])),
+	}
+
+	args = args[4:]
+	switch cmd.Codec {
+	default:
+		return Err("ERR invalid codec '" + cmd.Codec + "'")
+
+	case IndexCodecJSON:
+
+	case IndexCodecProtobuf:
+		if len(args) < 2 {
+			return Err("ERR invalid params")
+		}
+		cmd.Message = string(args[0])
+		args = args[1:]
+	}
+
+	for _, arg := range args {
+		switch strings.ToUpper(string(arg)) {
+		case "DESC":
+			if len(cmd.Fields) == 0 {
+				return Err("ERR DESC before path")
+			}
+			cmd.Fields[len(cmd.Fields)-1].Desc = true
+		case "CI":
+			if len(cmd.Fields) == 0 {
+				return Err("ERR CI before path")
+			}
+			cmd.Fields[len(cmd.Fields)-1].CaseInsensitive = true
+		default:
+			cmd.Fields = append(cmd.Fields, document.KeyField{Path: string(arg)})
+		}
+	}
+	if len(cmd.Fields) == 0 {
+		return Err("ERR no index fields")
+	}
+	return cmd
+}
+
+// Creates the Indexer for the definition.
+func (c *CreateIndex) Indexer() (table.Indexer, error) {
+	if c.Codec == IndexCodecProtobuf {
+		mt, ok := document.Service.MessageType(c.Message)
+		if !ok {
+			return nil, document.ErrSchemaNotFound
+		}
+		return mt.KeyIndexer(c.Fields...)
+	}
+
+	if len(c.Fields) > 2 {
+		return nil, document.ErrIndexFields
+	}
+	fields := make([]*table.IndexField, len(c.Fields))
+	for i, f := range c.Fields {
+		opts := table.IndexAny(f.Desc)
+		if f.CaseInsensitive {
+			opts |= table.CaseInsensitive
+		}
+		fields[i] = table.NewIndexer(f.Path, opts, table.JSONProjector(f.Path))
+	}
+	if len(fields) == 1 {
+		return fields[0], nil
+	}
+	return table.Composite(fields...), nil
+}
+
+// Checks the definition before it reaches the log.
+func (c *CreateIndex) Handle(ctx *Context) Reply {
+	if _, err := c.Indexer(); err != nil {
+		return Error(err)
+	}
+	return api.Propose(&ApplyCreateIndex{Index: *c})
+}
+
+// Recreates an Indexer from a definition written by Marshal.
+func indexerFromDef(def []byte) (table.Indexer, error) {
+	args, _, err := resp.ParseCommand(def)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := (&CreateIndex{}).Parse(args).(*CreateIndex)
+	if !ok {
+		return nil, api.ErrInvalidParam
+	}
+	return c.Indexer()
+}
+
+// Cluster log entry that creates an index on the keyspace of every node.
+type ApplyCreateIndex struct {
+	Index CreateIndex
+}
+
+func (c *ApplyCreateIndex) Name() string { return ApplyCreateIndexName }
+
+func (c *ApplyCreateIndex) Marshal(b []byte) []byte {
+	return c.Index.marshal(b, c.Name())
+}
+
+func (c *ApplyCreateIndex) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	index, ok := c.Index.Parse(args).(*CreateIndex)
+	if !ok {
+		return api.ErrInvalidParam
+	}
+	c.Index = *index
+	return nil
+}
+
+func (c *ApplyCreateIndex) Handle() api.CommandReply {
+	indexer, err := c.Index.Indexer()
+	if err != nil {
+		return Error(err)
+	}
+	def := c.Index.Marshal(nil)
+	err = api.Keyspace.Update(func() error {
+		return api.Keyspace.CreateIndexDef(c.Index.IndexName, c.Index.Pattern, def, indexer)
+	})
+	if err != nil {
+		return Error(err)
+	}
+	return Ok
+}
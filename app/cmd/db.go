@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&DB{}) }
+
+const (
+	DBCreate = "CREATE"
+	DBList   = "LIST"
+)
+
+// Manages databases. Databases are created through the cluster log and CREATE
+// replies the ID of the new database.
+//
+//	DB CREATE name
+//	DB LIST
+type DB struct {
+	Sub      string
+	Database string
+}
+
+func (c *DB) Name() string   { return "DB" }
+func (c *DB) Help() string   { return "DB CREATE name | LIST" }
+func (c *DB) IsError() bool  { return false }
+func (c *DB) IsWorker() bool { return c.Sub != DBList }
+
+func (c *DB) Marshal(buf []byte) []byte {
+	if c.Sub == DBList {
+		buf = resp.AppendArray(buf, 2)
+		buf = resp.AppendBulkString(buf, c.Name())
+		return resp.AppendBulkString(buf, c.Sub)
+	}
+	buf = resp.AppendArray(buf, 3)
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Sub)
+	return resp.AppendBulkString(buf, c.Database)
+}
+
+func (c *DB) Parse(args [][]byte) Command {
+	if len(args) < 2 {
+		return Err("ERR invalid params")
+	}
+	cmd := &DB{Sub: strings.ToUpper(string(args[1]))}
+
+	switch cmd.Sub {
+	default:
+		return Err("ERR unknown DB subcommand '" + string(args[1]) + "'")
+
+	case DBList:
+		if len(args) != 2 {
+			return Err("ERR invalid params")
+		}
+
+	case DBCreate:
+		if len(args) != 3 {
+			return Err("ERR invalid params")
+		}
+		cmd.Database = string(args[2])
+	}
+	return cmd
+}
+
+func (c *DB) Handle(ctx *Context) Reply {
+	if api.Databases == nil {
+		return Err("ERR databases not started")
+	}
+
+	switch c.Sub {
+	case DBList:
+		names := api.Databases.Names()
+		reply := make(api.Array, len(names))
+		for i, name := range names {
+			reply[i] = api.BulkString(name)
+		}
+		return reply
+	}
+
+	entry, err := api.Databases.CreateEntry(c.Database)
+	if err != nil {
+		return Error(err)
+	}
+	return api.Propose(entry)
+}
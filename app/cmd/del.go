@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/table"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() {
+	api.Register(&Del{})
+	api.RegisterApply(ApplyDelName, func() api.ApplyCommand { return &ApplyDel{} })
+}
+
+const ApplyDelName = "KEYDEL"
+
+// Removes keys and replies with the number that existed.
+//
+//	DEL key [key ...]
+type Del struct {
+	Keys []string
+}
+
+func (c *Del) Name() string   { return "DEL" }
+func (c *Del) Help() string   { return "DEL key [key ...]" }
+func (c *Del) IsError() bool  { return false }
+func (c *Del) IsWorker() bool { return true }
+
+func (c *Del) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 1+len(c.Keys))
+	buf = resp.AppendBulkString(buf, c.Name())
+	return appendKeys(buf, c.Keys)
+}
+
+func (c *Del) Parse(args [][]byte) Command {
+	if len(args) < 2 {
+		return Err("ERR wrong number of arguments for 'del' command")
+	}
+	return &Del{Keys: parseKeys(args[1:])}
+}
+
+func (c *Del) Handle(ctx *Context) Reply {
+	return api.Propose(&ApplyDel{Keys: c.Keys})
+}
+
+func (c *Del) Invoke(ks *table.Table) Reply {
+	removed := 0
+	for _, key := range c.Keys {
+		if _, err := ks.Delete(table.StringKey(key)); err == nil {
+			removed++
+		} else if err != moved.ErrNotFound {
+			return keyspaceError(err)
+		}
+	}
+	return api.Int(removed)
+}
+
+// Cluster log entry for DEL.
+type ApplyDel struct {
+	Keys []string
+}
+
+func (c *ApplyDel) Name() string { return ApplyDelName }
+
+func (c *ApplyDel) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 1+len(c.Keys))
+	b = resp.AppendBulkString(b, c.Name())
+	return appendKeys(b, c.Keys)
+}
+
+func (c *ApplyDel) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return api.ErrInvalidParam
+	}
+	c.Keys = parseKeys(args[1:])
+	return nil
+}
+
+func (c *ApplyDel) Handle() api.CommandReply {
+	return update(&Del{Keys: c.Keys})
+}
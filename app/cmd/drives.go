@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&Drives{}) }
+
+const DrivesSnapshot = "SNAPSHOT"
+
+// Manages the drives of the node.
+//
+//	DRIVES SNAPSHOT name
+//
+// SNAPSHOT takes a ZFS snapshot of every drive on a ZFS dataset with writes
+// briefly paused and replies with the mount and snapshot of every drive.
+type Drives struct {
+	Sub  string
+	Args []string
+}
+
+func (c *Drives) Name() string   { return "DRIVES" }
+func (c *Drives) Help() string   { return "DRIVES SNAPSHOT name" }
+func (c *Drives) IsError() bool  { return false }
+func (c *Drives) IsWorker() bool { return true }
+
+func (c *Drives) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 2+len(c.Args))
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Sub)
+	return appendKeys(buf, c.Args)
+}
+
+func (c *Drives) Parse(args [][]byte) Command {
+	if len(args) < 2 {
+		return Err("ERR wrong number of arguments for 'drives' command")
+	}
+	cmd := &Drives{
+		Sub:  strings.ToUpper(string(args[1])),
+		Args: parseKeys(args[2:]),
+	}
+	switch cmd.Sub {
+	default:
+		return Err("ERR unknown DRIVES subcommand '" + string(args[1]) + "'")
+	case DrivesSnapshot:
+		if len(cmd.Args) != 1 || strings.ContainsAny(cmd.Args[0], "@/ ") {
+			return Err("ERR DRIVES SNAPSHOT expects a snapshot name")
+		}
+	}
+	return cmd
+}
+
+func (c *Drives) Handle(ctx *Context) Reply {
+	if api.Drives == nil {
+		return Err("ERR drives are not started")
+	}
+
+	snapshots, err := api.Drives.Snapshot(c.Args[0])
+	if err != nil {
+		return Err("ERR " + err.Error())
+	}
+	reply := make(api.Array, 0, len(snapshots)*2)
+	for _, s := range snapshots {
+		reply = append(reply, api.BulkString(s.Mount), api.BulkString(s.Snapshot))
+	}
+	return reply
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/table"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&Dump{}) }
+
+// Serializes the value stored at key in a format understood by RESTORE.
+//
+//	DUMP key
+type Dump struct {
+	Key string
+}
+
+func (c *Dump) Name() string   { return "DUMP" }
+func (c *Dump) Help() string   { return "DUMP key" }
+func (c *Dump) IsError() bool  { return false }
+func (c *Dump) IsWorker() bool { return false }
+
+func (c *Dump) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 2)
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Key)
+	return buf
+}
+
+func (c *Dump) Parse(args [][]byte) Command {
+	if len(args) != 2 {
+		return Err("ERR wrong number of arguments for 'dump' command")
+	}
+	return &Dump{Key: string(args[1])}
+}
+
+func (c *Dump) Handle(ctx *Context) Reply {
+	return view(c)
+}
+
+func (c *Dump) Invoke(ks *table.Table) Reply {
+	payload, err := ks.Dump(table.StringKey(c.Key))
+	if err == moved.ErrNotFound {
+		return api.NIL
+	}
+	if err != nil {
+		return keyspaceError(err)
+	}
+	return api.BulkString(payload)
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/script"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&Eval{}) }
+
+// Runs a Lua script atomically against the keyspace. Every key must hash
+// to the same slot. The script is replicated with it's arguments.
+//
+//	EVAL script numkeys [key ...] [arg ...]
+type Eval struct {
+	Source string
+	Keys   []string
+	Args   []string
+}
+
+func (c *Eval) Name() string   { return "EVAL" }
+func (c *Eval) Help() string   { return "EVAL script numkeys [key ...] [arg ...]" }
+func (c *Eval) IsError() bool  { return false }
+func (c *Eval) IsWorker() bool { return true }
+
+func (c *Eval) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 3+len(c.Keys)+len(c.Args))
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Source)
+	buf = resp.AppendBulkInt(buf, len(c.Keys))
+	buf = appendKeys(buf, c.Keys)
+	return appendKeys(buf, c.Args)
+}
+
+func (c *Eval) Parse(args [][]byte) Command {
+	keys, argv, err := parseEvalArgs(args)
+	if err != nil {
+		return err
+	}
+	return &Eval{
+		Source: string(args[1]),
+		Keys:   keys,
+		Args:   argv,
+	}
+}
+
+func (c *Eval) Handle(ctx *Context) Reply {
+	s, err := script.Scripts.Load(c.Source)
+	if err != nil {
+		return Err("ERR Error compiling script: " + err.Error())
+	}
+	return proposeEval(s, c.Keys, c.Args)
+}
+
+// Splits the numkeys, keys and args of EVAL and EVALSHA.
+func parseEvalArgs(args [][]byte) ([]string, []string, Command) {
+	if len(args) < 3 {
+		return nil, nil, Err("ERR wrong number of arguments for '" + string(args[0]) + "' command")
+	}
+	numKeys, err := strconv.Atoi(string(args[2]))
+	if err != nil {
+		return nil, nil, Err("ERR value is not an integer or out of range")
+	}
+	if numKeys < 0 {
+		return nil, nil, Err("ERR Number of keys can't be negative")
+	}
+	if numKeys > len(args)-3 {
+		return nil, nil, Err("ERR Number of keys can't be greater than number of args")
+	}
+	return parseKeys(args[3 : 3+numKeys]), parseKeys(args[3+numKeys:]), nil
+}
+
+func proposeEval(s *script.Script, keys, args []string) Reply {
+	if _, err := script.KeysSlot(keys); err != nil {
+		return Err(err.Error())
+	}
+	// The budget is taken from the timeout of the proposing node so every
+	// node runs the script to the same instruction
+	timeout := time.Duration(atomic.LoadInt64(&moved.ScriptTimeout)) * time.Millisecond
+	return api.Propose(&script.ApplyEval{
+		Source: s.Source,
+		Budget: script.BudgetOf(timeout),
+		Keys:   keys,
+		Args:   args,
+	})
+}
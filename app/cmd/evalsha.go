@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/script"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&EvalSHA{}) }
+
+// Runs a script from the script cache by it's SHA1 digest.
+//
+//	EVALSHA sha1 numkeys [key ...] [arg ...]
+type EvalSHA struct {
+	SHA  string
+	Keys []string
+	Args []string
+}
+
+func (c *EvalSHA) Name() string   { return "EVALSHA" }
+func (c *EvalSHA) Help() string   { return "EVALSHA sha1 numkeys [key ...] [arg ...]" }
+func (c *EvalSHA) IsError() bool  { return false }
+func (c *EvalSHA) IsWorker() bool { return true }
+
+func (c *EvalSHA) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 3+len(c.Keys)+len(c.Args))
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.SHA)
+	buf = resp.AppendBulkInt(buf, len(c.Keys))
+	buf = appendKeys(buf, c.Keys)
+	return appendKeys(buf, c.Args)
+}
+
+func (c *EvalSHA) Parse(args [][]byte) Command {
+	keys, argv, err := parseEvalArgs(args)
+	if err != nil {
+		return err
+	}
+	return &EvalSHA{
+		SHA:  string(args[1]),
+		Keys: keys,
+		Args: argv,
+	}
+}
+
+func (c *EvalSHA) Handle(ctx *Context) Reply {
+	s, ok := script.Scripts.Get(c.SHA)
+	if !ok {
+		return Err(script.ErrNoScript.Error())
+	}
+	return proposeEval(s, c.Keys, c.Args)
+}
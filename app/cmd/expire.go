@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"strconv"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/table"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() {
+	api.RegisterApply(ApplyExpireName, func() api.ApplyCommand { return &ApplyExpire{} })
+}
+
+const ApplyExpireName = "KEYEXPIRE"
+
+// Cluster log entry that removes expired keys. The leader proposes it for
+// keys that expired according to it's clock. Now is in unix seconds and
+// keys that were written with a later expiration since are kept.
+type ApplyExpire struct {
+	Now  int64
+	Keys []string
+}
+
+func (c *ApplyExpire) Name() string { return ApplyExpireName }
+
+func (c *ApplyExpire) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 2+len(c.Keys))
+	b = resp.AppendBulkString(b, c.Name())
+	b = resp.AppendBulkInt64(b, c.Now)
+	return appendKeys(b, c.Keys)
+}
+
+func (c *ApplyExpire) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return api.ErrInvalidParam
+	}
+	if c.Now, err = strconv.ParseInt(string(args[1]), 10, 64); err != nil {
+		return err
+	}
+	c.Keys = parseKeys(args[2:])
+	return nil
+}
+
+func (c *ApplyExpire) Handle() api.CommandReply {
+	var expired int
+	api.Keyspace.Update(func() error {
+		for _, key := range c.Keys {
+			if api.Keyspace.Expire(table.StringKey(key), c.Now) {
+				expired++
+			}
+		}
+		return nil
+	})
+	return api.Int(expired)
+}
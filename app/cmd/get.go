@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"github.com/genzai-io/sliced"
 	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/table"
 	"github.com/genzai-io/sliced/common/resp"
 )
 
@@ -12,7 +14,7 @@ type Get struct {
 }
 
 func (c Get) Name() string   { return "GET" }
-func (c Get) Help() string   { return "" }
+func (c Get) Help() string   { return "GET key" }
 func (c Get) IsError() bool  { return false }
 func (c Get) IsWorker() bool { return false }
 
@@ -34,5 +36,16 @@ func (c Get) Parse(args [][]byte) Command {
 }
 
 func (c Get) Handle(ctx *Context) Reply {
-	return api.BulkString("" + c.Key)
+	return view(c)
+}
+
+func (c Get) Invoke(ks *table.Table) Reply {
+	value, err := ks.Get(table.StringKey(c.Key))
+	if err == moved.ErrNotFound {
+		return api.NIL
+	}
+	if err != nil {
+		return keyspaceError(err)
+	}
+	return api.BulkString(value)
 }
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/table"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&Index{}) }
+
+const IndexQuery = "QUERY"
+
+// Entries returned by INDEX QUERY without a limit.
+const indexQueryDefaultLimit = 100
+
+// Reads the secondary indexes created by CREATEINDEX. QUERY replies the key
+// and value of every entry of an index in the order of the index. MIN and
+// MAX bound the indexed values inclusively and EQ sets both. Values are
+// compared as numbers when they parse as one. Bounds apply to indexes of a
+// single field. Keys the user may not read are left out.
+//
+//	INDEX QUERY name [EQ value] [MIN value] [MAX value] [DESC] [LIMIT count]
+type Index struct {
+	Sub       string
+	IndexName string
+	Args      []string
+
+	// Options of QUERY
+	Min   table.Key
+	Max   table.Key
+	Desc  bool
+	Limit int
+}
+
+func (c *Index) Name() string { return "INDEX" }
+func (c *Index) Help() string {
+	return "INDEX QUERY name [EQ value] [MIN value] [MAX value] [DESC] [LIMIT count]"
+}
+func (c *Index) IsError() bool  { return false }
+func (c *Index) IsWorker() bool { return false }
+
+func (c *Index) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 3+len(c.Args))
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Sub)
+	buf = resp.AppendBulkString(buf, c.IndexName)
+	return appendKeys(buf, c.Args)
+}
+
+func (c *Index) Parse(args [][]byte) Command {
+	if len(args) < 3 {
+		return Err("ERR invalid params")
+	}
+	cmd := &Index{
+		Sub:       strings.ToUpper(string(args[1])),
+		IndexName: string(args[2]),
+		Args:      parseKeys(args[3:]),
+		Limit:     indexQueryDefaultLimit,
+	}
+	if cmd.Sub != IndexQuery {
+		return Err("ERR unknown INDEX subcommand '" + string(args[1]) + "'")
+	}
+
+	for i := 3; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		if option == "DESC" {
+			cmd.Desc = true
+			continue
+		}
+		if i+1 == len(args) {
+			return Err("ERR syntax error")
+		}
+		i++
+		switch option {
+		default:
+			return Err("ERR syntax error")
+		case "EQ":
+			cmd.Min = table.ParseKeyBytes(args[i])
+			cmd.Max = cmd.Min
+		case "MIN":
+			cmd.Min = table.ParseKeyBytes(args[i])
+		case "MAX":
+			cmd.Max = table.ParseKeyBytes(args[i])
+		case "LIMIT":
+			n, err := strconv.ParseUint(string(args[i]), 10, 31)
+			if err != nil {
+				return Err("ERR value is not an integer or out of range")
+			}
+			cmd.Limit = int(n)
+		}
+	}
+	return cmd
+}
+
+func (c *Index) Handle(ctx *Context) Reply {
+	user, err := ctx.ACLUser()
+	if err != nil {
+		return Error(err)
+	}
+
+	reply := api.Array{}
+	err = api.Keyspace.View(func() error {
+		iterator := func(item table.IndexItem) bool {
+			if len(reply) == c.Limit {
+				return false
+			}
+			if c.Desc && c.Min != nil && item.Key().Compare(c.Min) < 0 {
+				return false
+			}
+			if !c.Desc && c.Max != nil && item.Key().Compare(c.Max) > 0 {
+				return false
+			}
+			value := item.Value()
+			key := fmt.Sprint(value.Key)
+			if !user.CanKey(key) {
+				return true
+			}
+			reply = append(reply, api.Array{api.BulkString(key), api.BulkString(value.Value)})
+			return true
+		}
+
+		switch {
+		case c.Desc && c.Max != nil:
+			return api.Keyspace.DescendLessOrEqual(c.IndexName, c.Max, iterator)
+		case c.Desc:
+			return api.Keyspace.Descend(c.IndexName, iterator)
+		case c.Min != nil:
+			return api.Keyspace.AscendGreaterOrEqual(c.IndexName, c.Min, iterator)
+		default:
+			return api.Keyspace.Ascend(c.IndexName, iterator)
+		}
+	})
+	if err == moved.ErrNotFound {
+		return Err("ERR index not found")
+	}
+	if err != nil {
+		return keyspaceError(err)
+	}
+	return reply
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&Info{}) }
+
+const (
+	InfoCommandStats = "commandstats"
+	InfoLatencyStats = "latencystats"
+)
+
+var infoSections = []string{InfoCommandStats, InfoLatencyStats}
+
+// Percentiles reported by INFO latencystats.
+var infoPercentiles = []float64{50, 99, 99.9}
+
+// Reports the state of the node in the INFO format. Without a section every
+// section is reported.
+//
+//	INFO [section ...]
+type Info struct {
+	Sections []string
+}
+
+func (c *Info) Name() string   { return "INFO" }
+func (c *Info) Help() string   { return "INFO [commandstats | latencystats | all ...]" }
+func (c *Info) IsError() bool  { return false }
+func (c *Info) IsWorker() bool { return false }
+
+func (c *Info) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 1+len(c.Sections))
+	buf = resp.AppendBulkString(buf, c.Name())
+	return appendKeys(buf, c.Sections)
+}
+
+func (c *Info) Parse(args [][]byte) Command {
+	cmd := &Info{}
+	for _, arg := range args[1:] {
+		cmd.Sections = append(cmd.Sections, strings.ToLower(string(arg)))
+	}
+	return cmd
+}
+
+func (c *Info) Handle(ctx *Context) Reply {
+	sections := c.Sections
+	if len(sections) == 0 {
+		sections = infoSections
+	}
+
+	var b strings.Builder
+	for _, section := range sections {
+		switch section {
+		case "all", "everything", "default":
+			c.writeSection(&b, infoSections...)
+		default:
+			c.writeSection(&b, section)
+		}
+	}
+	return api.BulkString(b.String())
+}
+
+func (c *Info) writeSection(b *strings.Builder, sections ...string) {
+	for _, section := range sections {
+		switch section {
+		case InfoCommandStats:
+			if b.Len() > 0 {
+				b.WriteString("\r\n")
+			}
+			b.WriteString("# Commandstats\r\n")
+			api.Stats.Each(func(s *api.CommandStats) {
+				fmt.Fprintf(b, "cmdstat_%s:calls=%d,usec=%d,usec_per_call=%.2f,rejected_calls=0,failed_calls=%d,loop_usec=%d,worker_usec=%d\r\n",
+					s.Name, s.Calls, s.Usec, s.UsecPerCall(), s.Errors, s.LoopUsec, s.WorkerUsec)
+			})
+
+		case InfoLatencyStats:
+			if b.Len() > 0 {
+				b.WriteString("\r\n")
+			}
+			b.WriteString("# Latencystats\r\n")
+			api.Stats.Each(func(s *api.CommandStats) {
+				fmt.Fprintf(b, "latency_percentiles_usec_%s:", s.Name)
+				for i, p := range infoPercentiles {
+					if i > 0 {
+						b.WriteByte(',')
+					}
+					fmt.Fprintf(b, "p%g=%d", p, s.Latency.ValueAtQuantile(p))
+				}
+				b.WriteString("\r\n")
+			})
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/table"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+// Reply for a keyspace error. Errors that carry their own Redis error
+// code are passed through as is.
+func keyspaceError(err error) api.Err {
+	switch err {
+	case table.ErrWrongType, table.ErrInvalidHLL, table.ErrBusyKey:
+		return api.Err(err.Error())
+	}
+	return api.Err("ERR " + err.Error())
+}
+
+// Invokes a keyspace command holding the keyspace write lock.
+func update(c api.KeyspaceCommand) Reply {
+	var reply Reply
+	api.Keyspace.Update(func() error {
+		reply = c.Invoke(api.Keyspace)
+		return nil
+	})
+	return reply
+}
+
+// Invokes a keyspace command holding the keyspace read lock.
+func view(c api.KeyspaceCommand) Reply {
+	var reply Reply
+	api.Keyspace.View(func() error {
+		reply = c.Invoke(api.Keyspace)
+		return nil
+	})
+	return reply
+}
+
+func appendKeys(buf []byte, keys []string) []byte {
+	for _, key := range keys {
+		buf = resp.AppendBulkString(buf, key)
+	}
+	return buf
+}
+
+func parseKeys(args [][]byte) []string {
+	keys := make([]string, len(args))
+	for i, arg := range args {
+		keys[i] = string(arg)
+	}
+	return keys
+}
+
+func tableKeys(keys []string) []table.Key {
+	result := make([]table.Key, len(keys))
+	for i, key := range keys {
+		result[i] = table.StringKey(key)
+	}
+	return result
+}
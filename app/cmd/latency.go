@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&Latency{}) }
+
+const LatencyHistogram = "HISTOGRAM"
+
+// Reports the latency distribution of commands.
+//
+//	LATENCY HISTOGRAM [command ...]
+//
+// Every command is given as the number of calls followed by the cumulative
+// count of calls at power of two microsecond buckets.
+type Latency struct {
+	Sub      string
+	Commands []string
+}
+
+func (c *Latency) Name() string   { return "LATENCY" }
+func (c *Latency) Help() string   { return "LATENCY HISTOGRAM [command ...]" }
+func (c *Latency) IsError() bool  { return false }
+func (c *Latency) IsWorker() bool { return false }
+
+func (c *Latency) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 2+len(c.Commands))
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Sub)
+	return appendKeys(buf, c.Commands)
+}
+
+func (c *Latency) Parse(args [][]byte) Command {
+	if len(args) < 2 {
+		return Err("ERR wrong number of arguments for 'latency' command")
+	}
+	cmd := &Latency{
+		Sub:      strings.ToUpper(string(args[1])),
+		Commands: parseKeys(args[2:]),
+	}
+	if cmd.Sub != LatencyHistogram {
+		return Err("ERR unknown LATENCY subcommand '" + string(args[1]) + "'")
+	}
+	return cmd
+}
+
+func (c *Latency) Handle(ctx *Context) Reply {
+	var list []*api.CommandStats
+	if len(c.Commands) == 0 {
+		api.Stats.Each(func(s *api.CommandStats) {
+			list = append(list, s)
+		})
+	} else {
+		for _, name := range c.Commands {
+			if s, ok := api.Stats.Get(name); ok {
+				list = append(list, s)
+			}
+		}
+	}
+
+	reply := make(api.Array, 0, len(list)*2)
+	for _, s := range list {
+		reply = append(reply, api.BulkString(s.Name), api.Array{
+			api.BulkString("calls"),
+			api.Int(s.Calls),
+			api.BulkString("histogram_usec"),
+			latencyBuckets(s),
+		})
+	}
+	return reply
+}
+
+// Bucket and cumulative count pairs of the power of two buckets where the
+// count grows.
+func latencyBuckets(s *api.CommandStats) api.Array {
+	var bounds []int64
+	for bound := int64(1); ; bound <<= 1 {
+		bounds = append(bounds, bound)
+		if bound >= s.Latency.Max() {
+			break
+		}
+	}
+
+	var (
+		reply api.Array
+		last  int64
+	)
+	for i, count := range s.Buckets(bounds) {
+		if count > last {
+			reply = append(reply, api.Int(bounds[i]), api.Int(count))
+			last = count
+		}
+	}
+	return reply
+}
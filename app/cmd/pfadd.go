@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/table"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() {
+	api.Register(&PFAdd{})
+	api.RegisterApply(ApplyPFAddName, func() api.ApplyCommand { return &ApplyPFAdd{} })
+}
+
+const ApplyPFAddName = "KEYPFADD"
+
+// Adds elements to a HyperLogLog. Replies 1 if the approximated
+// cardinality may have changed.
+//
+//	PFADD key [element ...]
+type PFAdd struct {
+	Key      string
+	Elements []string
+}
+
+func (c *PFAdd) Name() string   { return "PFADD" }
+func (c *PFAdd) Help() string   { return "PFADD key [element ...]" }
+func (c *PFAdd) IsError() bool  { return false }
+func (c *PFAdd) IsWorker() bool { return true }
+
+func (c *PFAdd) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 2+len(c.Elements))
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Key)
+	return appendKeys(buf, c.Elements)
+}
+
+func (c *PFAdd) Parse(args [][]byte) Command {
+	if len(args) < 2 {
+		return Err("ERR wrong number of arguments for 'pfadd' command")
+	}
+	return &PFAdd{
+		Key:      string(args[1]),
+		Elements: parseKeys(args[2:]),
+	}
+}
+
+func (c *PFAdd) Handle(ctx *Context) Reply {
+	return api.Propose(&ApplyPFAdd{Key: c.Key, Elements: c.Elements})
+}
+
+func (c *PFAdd) Invoke(ks *table.Table) Reply {
+	elements := make([][]byte, len(c.Elements))
+	for i, e := range c.Elements {
+		elements[i] = []byte(e)
+	}
+
+	updated, err := ks.PFAdd(table.StringKey(c.Key), elements...)
+	if err != nil {
+		return keyspaceError(err)
+	}
+	if updated {
+		return api.Int(1)
+	}
+	return api.Int(0)
+}
+
+// Cluster log entry for PFADD.
+type ApplyPFAdd struct {
+	Key      string
+	Elements []string
+}
+
+func (c *ApplyPFAdd) Name() string { return ApplyPFAddName }
+
+func (c *ApplyPFAdd) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 2+len(c.Elements))
+	b = resp.AppendBulkString(b, c.Name())
+	b = resp.AppendBulkString(b, c.Key)
+	return appendKeys(b, c.Elements)
+}
+
+func (c *ApplyPFAdd) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return api.ErrInvalidParam
+	}
+	c.Key = string(args[1])
+	c.Elements = parseKeys(args[2:])
+	return nil
+}
+
+func (c *ApplyPFAdd) Handle() api.CommandReply {
+	return update(&PFAdd{Key: c.Key, Elements: c.Elements})
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/table"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&PFCount{}) }
+
+// Approximated cardinality of the union of one or more HyperLogLogs.
+//
+//	PFCOUNT key [key ...]
+type PFCount struct {
+	Keys []string
+}
+
+func (c *PFCount) Name() string   { return "PFCOUNT" }
+func (c *PFCount) Help() string   { return "PFCOUNT key [key ...]" }
+func (c *PFCount) IsError() bool  { return false }
+func (c *PFCount) IsWorker() bool { return false }
+
+func (c *PFCount) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 1+len(c.Keys))
+	buf = resp.AppendBulkString(buf, c.Name())
+	return appendKeys(buf, c.Keys)
+}
+
+func (c *PFCount) Parse(args [][]byte) Command {
+	if len(args) < 2 {
+		return Err("ERR wrong number of arguments for 'pfcount' command")
+	}
+	return &PFCount{Keys: parseKeys(args[1:])}
+}
+
+func (c *PFCount) Handle(ctx *Context) Reply {
+	return view(c)
+}
+
+func (c *PFCount) Invoke(ks *table.Table) Reply {
+	count, err := ks.PFCount(tableKeys(c.Keys)...)
+	if err != nil {
+		return keyspaceError(err)
+	}
+	return api.Int(count)
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/table"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() {
+	api.Register(&PFMerge{})
+	api.RegisterApply(ApplyPFMergeName, func() api.ApplyCommand { return &ApplyPFMerge{} })
+}
+
+const ApplyPFMergeName = "KEYPFMERGE"
+
+// Merges HyperLogLogs into the destination key.
+//
+//	PFMERGE destkey sourcekey [sourcekey ...]
+type PFMerge struct {
+	Dest    string
+	Sources []string
+}
+
+func (c *PFMerge) Name() string   { return "PFMERGE" }
+func (c *PFMerge) Help() string   { return "PFMERGE destkey sourcekey [sourcekey ...]" }
+func (c *PFMerge) IsError() bool  { return false }
+func (c *PFMerge) IsWorker() bool { return true }
+
+func (c *PFMerge) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 2+len(c.Sources))
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Dest)
+	return appendKeys(buf, c.Sources)
+}
+
+func (c *PFMerge) Parse(args [][]byte) Command {
+	if len(args) < 3 {
+		return Err("ERR wrong number of arguments for 'pfmerge' command")
+	}
+	return &PFMerge{
+		Dest:    string(args[1]),
+		Sources: parseKeys(args[2:]),
+	}
+}
+
+func (c *PFMerge) Handle(ctx *Context) Reply {
+	return api.Propose(&ApplyPFMerge{Dest: c.Dest, Sources: c.Sources})
+}
+
+func (c *PFMerge) Invoke(ks *table.Table) Reply {
+	if err := ks.PFMerge(table.StringKey(c.Dest), tableKeys(c.Sources)...); err != nil {
+		return keyspaceError(err)
+	}
+	return api.OK
+}
+
+// Cluster log entry for PFMERGE.
+type ApplyPFMerge struct {
+	Dest    string
+	Sources []string
+}
+
+func (c *ApplyPFMerge) Name() string { return ApplyPFMergeName }
+
+func (c *ApplyPFMerge) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 2+len(c.Sources))
+	b = resp.AppendBulkString(b, c.Name())
+	b = resp.AppendBulkString(b, c.Dest)
+	return appendKeys(b, c.Sources)
+}
+
+func (c *ApplyPFMerge) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) < 3 {
+		return api.ErrInvalidParam
+	}
+	c.Dest = string(args[1])
+	c.Sources = parseKeys(args[2:])
+	return nil
+}
+
+func (c *ApplyPFMerge) Handle() api.CommandReply {
+	return update(&PFMerge{Dest: c.Dest, Sources: c.Sources})
+}
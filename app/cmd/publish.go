@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"sync"
+
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/node"
+	"github.com/genzai-io/sliced/app/pubsub"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() {
+	api.Register(&Publish{})
+	api.Register(&PubRelay{})
+}
+
+// Number of published messages that may wait to be relayed to the rest of
+// the cluster. Messages are dropped when it's full instead of blocking the
+// event loop.
+const relayBacklog = 4096
+
+var (
+	relayCh   = make(chan *PubRelay, relayBacklog)
+	relayOnce sync.Once
+)
+
+// Posts a message to a channel. Subscribers on this node receive it
+// right away and it is relayed to every other member of the cluster.
+// The reply is the number of subscribers on this node that received it.
+//
+//	PUBLISH channel message
+type Publish struct {
+	Channel string
+	Message string
+}
+
+func (c *Publish) Name() string   { return "PUBLISH" }
+func (c *Publish) Help() string   { return "PUBLISH channel message" }
+func (c *Publish) IsError() bool  { return false }
+func (c *Publish) IsWorker() bool { return false }
+
+func (c *Publish) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 3)
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Channel)
+	buf = resp.AppendBulkString(buf, c.Message)
+	return buf
+}
+
+func (c *Publish) Parse(args [][]byte) Command {
+	if len(args) != 3 {
+		return Err("ERR wrong number of arguments for 'publish' command")
+	}
+	return &Publish{Channel: string(args[1]), Message: string(args[2])}
+}
+
+func (c *Publish) Handle(ctx *Context) Reply {
+	received := pubsub.Default.Publish(c.Channel, c.Message)
+	relay(&PubRelay{Channel: c.Channel, Message: c.Message})
+	return api.Int(received)
+}
+
+// Message relayed from another member of the cluster. It is only
+// delivered to the subscribers of this node.
+//
+//	PUBRELAY channel message
+type PubRelay struct {
+	Channel string
+	Message string
+}
+
+func (c *PubRelay) Name() string   { return "PUBRELAY" }
+func (c *PubRelay) Help() string   { return "PUBRELAY channel message" }
+func (c *PubRelay) IsError() bool  { return false }
+func (c *PubRelay) IsWorker() bool { return false }
+
+func (c *PubRelay) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 3)
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Channel)
+	buf = resp.AppendBulkString(buf, c.Message)
+	return buf
+}
+
+func (c *PubRelay) Parse(args [][]byte) Command {
+	if len(args) != 3 {
+		return Err("ERR wrong number of arguments for 'pubrelay' command")
+	}
+	return &PubRelay{Channel: string(args[1]), Message: string(args[2])}
+}
+
+func (c *PubRelay) Handle(ctx *Context) Reply {
+	return api.Int(pubsub.Default.Publish(c.Channel, c.Message))
+}
+
+// Queues a message for the relay goroutine which sends them in order.
+func relay(msg *PubRelay) {
+	if api.Cluster == nil {
+		return
+	}
+	relayOnce.Do(func() { go relayLoop() })
+
+	select {
+	case relayCh <- msg:
+	default:
+		moved.Logger.Warn().Str("channel", msg.Channel).Msg("pubsub relay backlog is full, message dropped")
+	}
+}
+
+func relayLoop() {
+	for msg := range relayCh {
+		for _, address := range relayPeers() {
+			if reply := node.Dial(address).Send(msg); reply != nil && reply.IsError() {
+				moved.Logger.Warn().Str("node", address).Msgf("pubsub relay failed: %v", reply)
+			}
+		}
+	}
+}
+
+// API addresses of the other members of the cluster.
+func relayPeers() []string {
+	r := api.Cluster.Raft()
+	if r == nil {
+		return nil
+	}
+	future, err := r.Configuration()
+	if err != nil {
+		return nil
+	}
+	var peers []string
+	for _, server := range future.Configuration().Servers {
+		if server.Address != moved.ClusterAddress {
+			peers = append(peers, string(server.Address))
+		}
+	}
+	return peers
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&Queue{}) }
+
+const (
+	QueueCreate  = "CREATE"
+	QueueEnqueue = "ENQUEUE"
+	QueueDequeue = "DEQUEUE"
+	QueueAck     = "ACK"
+	QueueList    = "LIST"
+)
+
+// Messages delivered by QUEUE DEQUEUE without a count and how long they
+// are hidden without a visibility.
+const (
+	queueDequeueDefaultCount      = 1
+	queueDequeueDefaultVisibility = 30 * time.Second
+)
+
+// Manages queues. Queues and their messages go through the cluster log.
+// ENQUEUE replies the ID of each message. DEQUEUE replies the ID and data of
+// up to COUNT messages in the order they were enqueued and hides them for
+// VISIBILITY milliseconds. Messages that are not acknowledged by then are
+// delivered again. ACK removes messages and replies how many were removed.
+//
+//	QUEUE CREATE name
+//	QUEUE ENQUEUE name message [message ...]
+//	QUEUE DEQUEUE name [COUNT count] [VISIBILITY ms]
+//	QUEUE ACK name id [id ...]
+//	QUEUE LIST
+type Queue struct {
+	Sub   string
+	Queue string
+	Args  []string
+
+	// Options of DEQUEUE
+	Count      int
+	Visibility time.Duration
+	// IDs of ACK
+	IDs []uint64
+}
+
+func (c *Queue) Name() string { return "QUEUE" }
+func (c *Queue) Help() string {
+	return "QUEUE CREATE name | ENQUEUE name message [message ...] | DEQUEUE name [COUNT count] [VISIBILITY ms] | ACK name id [id ...] | LIST"
+}
+func (c *Queue) IsError() bool  { return false }
+func (c *Queue) IsWorker() bool { return c.Sub != QueueList }
+
+func (c *Queue) Marshal(buf []byte) []byte {
+	if c.Sub == QueueList {
+		buf = resp.AppendArray(buf, 2)
+		buf = resp.AppendBulkString(buf, c.Name())
+		return resp.AppendBulkString(buf, c.Sub)
+	}
+	buf = resp.AppendArray(buf, 3+len(c.Args))
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Sub)
+	buf = resp.AppendBulkString(buf, c.Queue)
+	return appendKeys(buf, c.Args)
+}
+
+func (c *Queue) Parse(args [][]byte) Command {
+	if len(args) < 2 {
+		return Err("ERR invalid params")
+	}
+	cmd := &Queue{Sub: strings.ToUpper(string(args[1]))}
+
+	switch cmd.Sub {
+	default:
+		return Err("ERR unknown QUEUE subcommand '" + string(args[1]) + "'")
+
+	case QueueList:
+		if len(args) != 2 {
+			return Err("ERR invalid params")
+		}
+		return cmd
+
+	case QueueCreate:
+		if len(args) != 3 {
+			return Err("ERR invalid params")
+		}
+
+	case QueueEnqueue:
+		if len(args) < 4 {
+			return Err("ERR invalid params")
+		}
+
+	case QueueDequeue:
+		if len(args) < 3 || len(args)%2 == 0 {
+			return Err("ERR invalid params")
+		}
+		cmd.Count = queueDequeueDefaultCount
+		cmd.Visibility = queueDequeueDefaultVisibility
+		for i := 3; i < len(args); i += 2 {
+			n, err := strconv.ParseUint(string(args[i+1]), 10, 31)
+			if err != nil || n == 0 {
+				return Err("ERR value is not an integer or out of range")
+			}
+			switch strings.ToUpper(string(args[i])) {
+			case "COUNT":
+				cmd.Count = int(n)
+			case "VISIBILITY":
+				cmd.Visibility = time.Duration(n) * time.Millisecond
+			default:
+				return Err("ERR syntax error")
+			}
+		}
+
+	case QueueAck:
+		if len(args) < 4 {
+			return Err("ERR invalid params")
+		}
+		cmd.IDs = make([]uint64, len(args)-3)
+		for i, arg := range args[3:] {
+			id, err := strconv.ParseUint(string(arg), 10, 64)
+			if err != nil {
+				return Err("ERR value is not an integer or out of range")
+			}
+			cmd.IDs[i] = id
+		}
+	}
+	cmd.Queue = string(args[2])
+	cmd.Args = parseKeys(args[3:])
+	return cmd
+}
+
+func (c *Queue) Handle(ctx *Context) Reply {
+	if api.Queues == nil {
+		return Err("ERR queues not started")
+	}
+
+	var (
+		entry api.ApplyCommand
+		err   error
+	)
+	switch c.Sub {
+	case QueueList:
+		names := api.Queues.Names()
+		reply := make(api.Array, len(names))
+		for i, name := range names {
+			reply[i] = api.BulkString(name)
+		}
+		return reply
+
+	case QueueCreate:
+		entry, err = api.Queues.CreateEntry(c.Queue)
+
+	case QueueEnqueue:
+		entry, err = api.Queues.EnqueueEntry(c.Queue, c.Args)
+
+	case QueueDequeue:
+		entry, err = api.Queues.DequeueEntry(c.Queue, c.Count, c.Visibility)
+
+	case QueueAck:
+		entry, err = api.Queues.AckEntry(c.Queue, c.IDs)
+	}
+	if err != nil {
+		return Error(err)
+	}
+	return api.Propose(entry)
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/table"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() {
+	api.Register(&Restore{})
+	api.RegisterApply(ApplyRestoreName, func() api.ApplyCommand { return &ApplyRestore{} })
+}
+
+const ApplyRestoreName = "KEYRESTORE"
+
+// Creates a key from a DUMP payload. The TTL is in milliseconds and 0
+// means no expiration. With ABSTTL the TTL is a unix time in milliseconds.
+//
+//	RESTORE key ttl serialized-value [REPLACE] [ABSTTL]
+type Restore struct {
+	Key     string
+	TTL     int64
+	Payload string
+	Replace bool
+	AbsTTL  bool
+}
+
+func (c *Restore) Name() string { return "RESTORE" }
+func (c *Restore) Help() string {
+	return "RESTORE key ttl serialized-value [REPLACE] [ABSTTL]"
+}
+func (c *Restore) IsError() bool  { return false }
+func (c *Restore) IsWorker() bool { return true }
+
+func (c *Restore) Marshal(buf []byte) []byte {
+	count := 4
+	if c.Replace {
+		count++
+	}
+	if c.AbsTTL {
+		count++
+	}
+	buf = resp.AppendArray(buf, count)
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Key)
+	buf = resp.AppendBulkInt64(buf, c.TTL)
+	buf = resp.AppendBulkString(buf, c.Payload)
+	if c.Replace {
+		buf = resp.AppendBulkString(buf, "REPLACE")
+	}
+	if c.AbsTTL {
+		buf = resp.AppendBulkString(buf, "ABSTTL")
+	}
+	return buf
+}
+
+func (c *Restore) Parse(args [][]byte) Command {
+	if len(args) < 4 {
+		return Err("ERR wrong number of arguments for 'restore' command")
+	}
+	ttl, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil || ttl < 0 {
+		return Err("ERR Invalid TTL value, must be >= 0")
+	}
+
+	cmd := &Restore{
+		Key:     string(args[1]),
+		TTL:     ttl,
+		Payload: string(args[3]),
+	}
+	for _, arg := range args[4:] {
+		switch strings.ToUpper(string(arg)) {
+		case "REPLACE":
+			cmd.Replace = true
+		case "ABSTTL":
+			cmd.AbsTTL = true
+		default:
+			return Err("ERR syntax error")
+		}
+	}
+	return cmd
+}
+
+func (c *Restore) Handle(ctx *Context) Reply {
+	// Resolve the expiration once so every node agrees on it
+	var expires int64
+	if c.TTL > 0 {
+		at := c.TTL
+		if !c.AbsTTL {
+			at += time.Now().UnixNano() / int64(time.Millisecond)
+		}
+		// Table expirations are in seconds
+		expires = (at + 999) / 1000
+	}
+
+	return api.Propose(&ApplyRestore{
+		Key:     c.Key,
+		Expires: expires,
+		Payload: c.Payload,
+		Replace: c.Replace,
+	})
+}
+
+// Cluster log entry for RESTORE. Expires is in absolute unix seconds.
+type ApplyRestore struct {
+	Key     string
+	Expires int64
+	Payload string
+	Replace bool
+}
+
+func (c *ApplyRestore) Name() string { return ApplyRestoreName }
+
+func (c *ApplyRestore) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 5)
+	b = resp.AppendBulkString(b, c.Name())
+	b = resp.AppendBulkString(b, c.Key)
+	b = resp.AppendBulkInt64(b, c.Expires)
+	b = resp.AppendBulkString(b, c.Payload)
+	if c.Replace {
+		b = resp.AppendBulkInt(b, 1)
+	} else {
+		b = resp.AppendBulkInt(b, 0)
+	}
+	return b
+}
+
+func (c *ApplyRestore) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) != 5 {
+		return api.ErrInvalidParam
+	}
+	c.Expires, err = strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return err
+	}
+	c.Key = string(args[1])
+	c.Payload = string(args[3])
+	c.Replace = string(args[4]) == "1"
+	return nil
+}
+
+func (c *ApplyRestore) Handle() api.CommandReply {
+	var reply api.CommandReply
+	api.Keyspace.Update(func() error {
+		reply = c.Invoke(api.Keyspace)
+		return nil
+	})
+	return reply
+}
+
+func (c *ApplyRestore) Invoke(ks *table.Table) api.CommandReply {
+	if err := ks.Restore(table.StringKey(c.Key), c.Expires, c.Payload, c.Replace); err != nil {
+		return keyspaceError(err)
+	}
+	return api.OK
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/document"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&SchemaAdd{}) }
+
+// Registers a new version of a FileDescriptorSet for a package path.
+// The version is checked against the compatibility rule before it is
+// replicated through the cluster log. A rule given becomes the rule of the
+// package, otherwise the package keeps its rule which starts as BACKWARD.
+//
+//	SCHEMAADD package [NONE|BACKWARD|FORWARD|FULL] descriptor-set
+type SchemaAdd struct {
+	Package string
+	Compat  document.Compatibility
+	Set     []byte
+}
+
+func (c *SchemaAdd) Name() string { return "SCHEMAADD" }
+func (c *SchemaAdd) Help() string {
+	return "SCHEMAADD package [NONE|BACKWARD|FORWARD|FULL] descriptor-set"
+}
+func (c *SchemaAdd) IsError() bool  { return false }
+func (c *SchemaAdd) IsWorker() bool { return true }
+
+func (c *SchemaAdd) Marshal(buf []byte) []byte {
+	if c.Compat == document.CompatDefault {
+		buf = resp.AppendArray(buf, 3)
+	} else {
+		buf = resp.AppendArray(buf, 4)
+	}
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Package)
+	if c.Compat != document.CompatDefault {
+		buf = resp.AppendBulkString(buf, c.Compat.String())
+	}
+	buf = resp.AppendBulk(buf, c.Set)
+	return buf
+}
+
+func (c *SchemaAdd) Parse(args [][]byte) Command {
+	cmd := &SchemaAdd{}
+
+	switch len(args) {
+	default:
+		return Err("ERR expected 2 or 3 params")
+
+	case 3:
+		cmd.Package = string(args[1])
+		cmd.Compat = document.CompatDefault
+		cmd.Set = append([]byte{}, args[2]...)
+		return cmd
+
+	case 4:
+		cmd.Package = string(args[1])
+		compat, err := document.ParseCompatibility(string(args[2]))
+		if err != nil {
+			return Error(err)
+		}
+		cmd.Compat = compat
+		cmd.Set = append([]byte{}, args[3]...)
+		return cmd
+	}
+}
+
+func (c *SchemaAdd) Handle(ctx *Context) Reply {
+	// Fail fast before it reaches the log
+	if err := document.Service.CheckSchema(c.Package, c.Compat, c.Set); err != nil {
+		return Error(err)
+	}
+
+	return api.Propose(&document.ApplySchemaRegister{
+		Package:   c.Package,
+		Compat:    c.Compat,
+		Timestamp: time.Now().UnixNano(),
+		Set:       c.Set,
+	})
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"strconv"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/document"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&SchemaDel{}) }
+
+// Removes a version of a package from the schema registry.
+//
+//	SCHEMADEL package version
+type SchemaDel struct {
+	Package string
+	Version int32
+}
+
+func (c *SchemaDel) Name() string   { return "SCHEMADEL" }
+func (c *SchemaDel) Help() string   { return "SCHEMADEL package version" }
+func (c *SchemaDel) IsError() bool  { return false }
+func (c *SchemaDel) IsWorker() bool { return true }
+
+func (c *SchemaDel) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 3)
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Package)
+	buf = resp.AppendBulkInt32(buf, c.Version)
+	return buf
+}
+
+func (c *SchemaDel) Parse(args [][]byte) Command {
+	if len(args) != 3 {
+		return Err("ERR expected 2 params")
+	}
+
+	version, err := strconv.Atoi(string(args[2]))
+	if err != nil || version <= 0 {
+		return Err("ERR invalid version: " + string(args[2]))
+	}
+
+	return &SchemaDel{
+		Package: string(args[1]),
+		Version: int32(version),
+	}
+}
+
+func (c *SchemaDel) Handle(ctx *Context) Reply {
+	if _, ok := document.Service.Schema(c.Package, c.Version); !ok {
+		return Error(document.ErrSchemaNotFound)
+	}
+
+	return api.Propose(&document.ApplySchemaUnregister{
+		Package: c.Package,
+		Version: c.Version,
+	})
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"strconv"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/document"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&SchemaGet{}) }
+
+// Fetches the gzipped FileDescriptorSet of a version of a package.
+// Version 0 or no version is the latest.
+//
+//	SCHEMAGET package [version]
+type SchemaGet struct {
+	Package string
+	Version int32
+}
+
+func (c *SchemaGet) Name() string   { return "SCHEMAGET" }
+func (c *SchemaGet) Help() string   { return "SCHEMAGET package [version]" }
+func (c *SchemaGet) IsError() bool  { return false }
+func (c *SchemaGet) IsWorker() bool { return false }
+
+func (c *SchemaGet) Marshal(buf []byte) []byte {
+	if c.Version == 0 {
+		buf = resp.AppendArray(buf, 2)
+		buf = resp.AppendBulkString(buf, c.Name())
+		buf = resp.AppendBulkString(buf, c.Package)
+	} else {
+		buf = resp.AppendArray(buf, 3)
+		buf = resp.AppendBulkString(buf, c.Name())
+		buf = resp.AppendBulkString(buf, c.Package)
+		buf = resp.AppendBulkInt32(buf, c.Version)
+	}
+	return buf
+}
+
+func (c *SchemaGet) Parse(args [][]byte) Command {
+	cmd := &SchemaGet{}
+
+	switch len(args) {
+	default:
+		return Err("ERR expected 1 or 2 params")
+
+	case 2:
+		cmd.Package = string(args[1])
+		return cmd
+
+	case 3:
+		cmd.Package = string(args[1])
+		version, err := strconv.Atoi(string(args[2]))
+		if err != nil || version < 0 {
+			return Err("ERR invalid version: " + string(args[2]))
+		}
+		cmd.Version = int32(version)
+		return cmd
+	}
+}
+
+func (c *SchemaGet) Handle(ctx *Context) Reply {
+	v, ok := document.Service.Schema(c.Package, c.Version)
+	if !ok {
+		return api.NIL
+	}
+	return api.Bulk(v.Set)
+}
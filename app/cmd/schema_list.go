@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/document"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&SchemaList{}) }
+
+// Lists registered packages or the versions of a single package.
+// Each version is an array of [version, compatibility, hash, timestamp, messages...]
+//
+//	SCHEMALIST [package]
+type SchemaList struct {
+	Package string
+}
+
+func (c *SchemaList) Name() string   { return "SCHEMALIST" }
+func (c *SchemaList) Help() string   { return "SCHEMALIST [package]" }
+func (c *SchemaList) IsError() bool  { return false }
+func (c *SchemaList) IsWorker() bool { return false }
+
+func (c *SchemaList) Marshal(buf []byte) []byte {
+	if c.Package == "" {
+		buf = resp.AppendArray(buf, 1)
+		buf = resp.AppendBulkString(buf, c.Name())
+	} else {
+		buf = resp.AppendArray(buf, 2)
+		buf = resp.AppendBulkString(buf, c.Name())
+		buf = resp.AppendBulkString(buf, c.Package)
+	}
+	return buf
+}
+
+func (c *SchemaList) Parse(args [][]byte) Command {
+	switch len(args) {
+	case 1:
+		return &SchemaList{}
+	case 2:
+		return &SchemaList{Package: string(args[1])}
+	}
+	return Err("ERR expected 0 or 1 params")
+}
+
+func (c *SchemaList) Handle(ctx *Context) Reply {
+	if c.Package == "" {
+		names := document.Service.Schemas()
+		reply := make(api.Array, len(names))
+		for i, name := range names {
+			reply[i] = api.BulkString(name)
+		}
+		return reply
+	}
+
+	versions := document.Service.SchemaVersions(c.Package)
+	reply := make(api.Array, len(versions))
+	for i, v := range versions {
+		messages := v.MessageNames()
+		item := make(api.Array, 0, len(messages)+4)
+		item = append(item,
+			api.Int(v.Version),
+			api.BulkString(document.Service.Compat(v.Package).String()),
+			api.BulkString(v.Hash),
+			api.Int(v.Timestamp.UnixNano()),
+		)
+		for _, name := range messages {
+			item = append(item, api.BulkString(name))
+		}
+		reply[i] = item
+	}
+	return reply
+}
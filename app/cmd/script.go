@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/script"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&Script{}) }
+
+const (
+	ScriptLoad   = "LOAD"
+	ScriptExists = "EXISTS"
+	ScriptFlush  = "FLUSH"
+)
+
+// Manages the local script cache.
+//
+//	SCRIPT LOAD script
+//	SCRIPT EXISTS sha1 [sha1 ...]
+//	SCRIPT FLUSH
+type Script struct {
+	Sub  string
+	Args []string
+}
+
+func (c *Script) Name() string   { return "SCRIPT" }
+func (c *Script) Help() string   { return "SCRIPT LOAD script | EXISTS sha1 [sha1 ...] | FLUSH" }
+func (c *Script) IsError() bool  { return false }
+func (c *Script) IsWorker() bool { return false }
+
+func (c *Script) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 2+len(c.Args))
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Sub)
+	return appendKeys(buf, c.Args)
+}
+
+func (c *Script) Parse(args [][]byte) Command {
+	if len(args) < 2 {
+		return Err("ERR wrong number of arguments for 'script' command")
+	}
+	cmd := &Script{
+		Sub:  strings.ToUpper(string(args[1])),
+		Args: parseKeys(args[2:]),
+	}
+
+	switch cmd.Sub {
+	default:
+		return Err("ERR unknown SCRIPT subcommand '" + string(args[1]) + "'")
+	case ScriptLoad:
+		if len(cmd.Args) != 1 {
+			return Err("ERR wrong number of arguments for 'script|load' command")
+		}
+	case ScriptExists:
+		if len(cmd.Args) == 0 {
+			return Err("ERR wrong number of arguments for 'script|exists' command")
+		}
+	case ScriptFlush:
+		if len(cmd.Args) != 0 {
+			return Err("ERR wrong number of arguments for 'script|flush' command")
+		}
+	}
+	return cmd
+}
+
+func (c *Script) Handle(ctx *Context) Reply {
+	switch c.Sub {
+	case ScriptLoad:
+		s, err := script.Scripts.Load(c.Args[0])
+		if err != nil {
+			return Err("ERR Error compiling script: " + err.Error())
+		}
+		return api.BulkString(s.SHA)
+
+	case ScriptExists:
+		exists := script.Scripts.Exists(c.Args...)
+		reply := make(api.Array, len(exists))
+		for i, ok := range exists {
+			if ok {
+				reply[i] = api.Int(1)
+			} else {
+				reply[i] = api.Int(0)
+			}
+		}
+		return reply
+
+	case ScriptFlush:
+		script.Scripts.Flush()
+		return Ok
+	}
+	return Err("ERR unknown SCRIPT subcommand")
+}
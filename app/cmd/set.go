@@ -1,11 +1,17 @@
 package cmd
 
 import (
-	"github.com/genzai-io/sliced/common/resp"
 	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/table"
+	"github.com/genzai-io/sliced/common/resp"
 )
 
-func init() { api.Register(&Set{}) }
+func init() {
+	api.Register(&Set{})
+	api.RegisterApply(ApplySetName, func() api.ApplyCommand { return &ApplySet{} })
+}
+
+const ApplySetName = "KEYSET"
 
 type Set struct {
 	Key   string
@@ -13,14 +19,15 @@ type Set struct {
 }
 
 func (c *Set) Name() string   { return "SET" }
-func (c *Set) Help() string   { return "" }
+func (c *Set) Help() string   { return "SET key value" }
 func (c *Set) IsError() bool  { return false }
-func (c *Set) IsWorker() bool { return false }
+func (c *Set) IsWorker() bool { return true }
 
 func (c *Set) Marshal(b []byte) []byte {
-	b = resp.AppendArray(b, 2)
+	b = resp.AppendArray(b, 3)
 	b = resp.AppendBulkString(b, c.Name())
 	b = resp.AppendBulkString(b, c.Key)
+	b = resp.AppendBulkString(b, c.Value)
 	return b
 }
 
@@ -36,5 +43,45 @@ func (c *Set) Parse(args [][]byte) Command {
 }
 
 func (c *Set) Handle(ctx *Context) Reply {
+	return api.Propose(&ApplySet{Key: c.Key, Value: c.Value})
+}
+
+func (c *Set) Invoke(ks *table.Table) Reply {
+	if _, _, err := ks.Set(table.StringKey(c.Key), c.Value, 0); err != nil {
+		return keyspaceError(err)
+	}
 	return Ok
 }
+
+// Cluster log entry for SET.
+type ApplySet struct {
+	Key   string
+	Value string
+}
+
+func (c *ApplySet) Name() string { return ApplySetName }
+
+func (c *ApplySet) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 3)
+	b = resp.AppendBulkString(b, c.Name())
+	b = resp.AppendBulkString(b, c.Key)
+	b = resp.AppendBulkString(b, c.Value)
+	return b
+}
+
+func (c *ApplySet) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) != 3 {
+		return api.ErrInvalidParam
+	}
+	c.Key = string(args[1])
+	c.Value = string(args[2])
+	return nil
+}
+
+func (c *ApplySet) Handle() api.CommandReply {
+	return update(&Set{Key: c.Key, Value: c.Value})
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&Slowlog{}) }
+
+const (
+	SlowlogGet   = "GET"
+	SlowlogLen   = "LEN"
+	SlowlogReset = "RESET"
+)
+
+// Entries returned by SLOWLOG GET without a count.
+const slowlogDefaultCount = 10
+
+// Reads the commands that took longer than the slowlog threshold.
+//
+//	SLOWLOG GET [count]
+//	SLOWLOG LEN
+//	SLOWLOG RESET
+type Slowlog struct {
+	Sub   string
+	Count int
+}
+
+func (c *Slowlog) Name() string   { return "SLOWLOG" }
+func (c *Slowlog) Help() string   { return "SLOWLOG GET [count] | LEN | RESET" }
+func (c *Slowlog) IsError() bool  { return false }
+func (c *Slowlog) IsWorker() bool { return false }
+
+func (c *Slowlog) Marshal(buf []byte) []byte {
+	if c.Sub != SlowlogGet {
+		buf = resp.AppendArray(buf, 2)
+		buf = resp.AppendBulkString(buf, c.Name())
+		return resp.AppendBulkString(buf, c.Sub)
+	}
+	buf = resp.AppendArray(buf, 3)
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Sub)
+	return resp.AppendBulkInt64(buf, int64(c.Count))
+}
+
+func (c *Slowlog) Parse(args [][]byte) Command {
+	if len(args) < 2 {
+		return Err("ERR wrong number of arguments for 'slowlog' command")
+	}
+	cmd := &Slowlog{
+		Sub:   strings.ToUpper(string(args[1])),
+		Count: slowlogDefaultCount,
+	}
+
+	switch cmd.Sub {
+	default:
+		return Err("ERR unknown SLOWLOG subcommand '" + string(args[1]) + "'")
+	case SlowlogGet:
+		if len(args) > 3 {
+			return Err("ERR wrong number of arguments for 'slowlog|get' command")
+		}
+		if len(args) == 3 {
+			count, err := strconv.Atoi(string(args[2]))
+			if err != nil || count < -1 {
+				return Err("ERR count should be greater than or equal to -1")
+			}
+			cmd.Count = count
+		}
+	case SlowlogLen, SlowlogReset:
+		if len(args) != 2 {
+			return Err("ERR wrong number of arguments for 'slowlog|" + strings.ToLower(cmd.Sub) + "' command")
+		}
+	}
+	return cmd
+}
+
+func (c *Slowlog) Handle(ctx *Context) Reply {
+	switch c.Sub {
+	case SlowlogLen:
+		return api.Int(api.Slowlog.Len())
+
+	case SlowlogReset:
+		api.Slowlog.Reset()
+		return api.OK
+	}
+
+	entries := api.Slowlog.Get(c.Count)
+	reply := make(api.Array, 0, len(entries))
+	for _, entry := range entries {
+		args := make(api.Array, len(entry.Args))
+		for i, arg := range entry.Args {
+			args[i] = api.BulkString(arg)
+		}
+		reply = append(reply, api.Array{
+			api.Int(entry.ID),
+			api.Int(entry.Time.Unix()),
+			api.Int(entry.Duration / time.Microsecond),
+			args,
+			api.BulkString(entry.Addr),
+			api.BulkString(entry.User),
+		})
+	}
+	return reply
+}
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/pubsub"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() {
+	api.Register(&Subscribe{})
+	api.Register(&Unsubscribe{})
+	api.Register(&PSubscribe{})
+	api.Register(&PUnsubscribe{})
+}
+
+// Subscribes the connection to channels. The connection only accepts
+// PUB/SUB commands until every subscription is removed.
+//
+//	SUBSCRIBE channel [channel ...]
+type Subscribe struct {
+	Channels []string
+}
+
+func (c *Subscribe) Name() string   { return "SUBSCRIBE" }
+func (c *Subscribe) Help() string   { return "SUBSCRIBE channel [channel ...]" }
+func (c *Subscribe) IsError() bool  { return false }
+func (c *Subscribe) IsWorker() bool { return false }
+
+func (c *Subscribe) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 1+len(c.Channels))
+	buf = resp.AppendBulkString(buf, c.Name())
+	return appendKeys(buf, c.Channels)
+}
+
+func (c *Subscribe) Parse(args [][]byte) Command {
+	if len(args) < 2 {
+		return Err("ERR wrong number of arguments for 'subscribe' command")
+	}
+	return &Subscribe{Channels: parseKeys(args[1:])}
+}
+
+func (c *Subscribe) Handle(ctx *Context) Reply {
+	if ctx.Subscriber == nil {
+		return Err("ERR PUB/SUB is not supported by this connection")
+	}
+	reply := make(subscriptionReply, len(c.Channels))
+	for i, channel := range c.Channels {
+		reply[i] = subscription("subscribe", channel, pubsub.Default.Subscribe(ctx.Subscriber, channel))
+	}
+	return pubSubMode(ctx, reply)
+}
+
+// Unsubscribes the connection from channels or from every channel when
+// none are given.
+//
+//	UNSUBSCRIBE [channel [channel ...]]
+type Unsubscribe struct {
+	Channels []string
+}
+
+func (c *Unsubscribe) Name() string   { return "UNSUBSCRIBE" }
+func (c *Unsubscribe) Help() string   { return "UNSUBSCRIBE [channel [channel ...]]" }
+func (c *Unsubscribe) IsError() bool  { return false }
+func (c *Unsubscribe) IsWorker() bool { return false }
+
+func (c *Unsubscribe) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 1+len(c.Channels))
+	buf = resp.AppendBulkString(buf, c.Name())
+	return appendKeys(buf, c.Channels)
+}
+
+func (c *Unsubscribe) Parse(args [][]byte) Command {
+	return &Unsubscribe{Channels: parseKeys(args[1:])}
+}
+
+func (c *Unsubscribe) Handle(ctx *Context) Reply {
+	if ctx.Subscriber == nil {
+		return subscriptionReply{subscription("unsubscribe", "", 0)}
+	}
+	channels := c.Channels
+	if len(channels) == 0 {
+		channels = pubsub.Default.Channels(ctx.Subscriber)
+	}
+	if len(channels) == 0 {
+		return pubSubMode(ctx, subscriptionReply{
+			subscription("unsubscribe", "", pubsub.Default.Count(ctx.Subscriber)),
+		})
+	}
+	reply := make(subscriptionReply, len(channels))
+	for i, channel := range channels {
+		reply[i] = subscription("unsubscribe", channel, pubsub.Default.Unsubscribe(ctx.Subscriber, channel))
+	}
+	return pubSubMode(ctx, reply)
+}
+
+// Subscribes the connection to glob-style patterns.
+//
+//	PSUBSCRIBE pattern [pattern ...]
+type PSubscribe struct {
+	Patterns []string
+}
+
+func (c *PSubscribe) Name() string   { return "PSUBSCRIBE" }
+func (c *PSubscribe) Help() string   { return "PSUBSCRIBE pattern [pattern ...]" }
+func (c *PSubscribe) IsError() bool  { return false }
+func (c *PSubscribe) IsWorker() bool { return false }
+
+func (c *PSubscribe) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 1+len(c.Patterns))
+	buf = resp.AppendBulkString(buf, c.Name())
+	return appendKeys(buf, c.Patterns)
+}
+
+func (c *PSubscribe) Parse(args [][]byte) Command {
+	if len(args) < 2 {
+		return Err("ERR wrong number of arguments for 'psubscribe' command")
+	}
+	return &PSubscribe{Patterns: parseKeys(args[1:])}
+}
+
+func (c *PSubscribe) Handle(ctx *Context) Reply {
+	if ctx.Subscriber == nil {
+		return Err("ERR PUB/SUB is not supported by this connection")
+	}
+	reply := make(subscriptionReply, len(c.Patterns))
+	for i, pattern := range c.Patterns {
+		reply[i] = subscription("psubscribe", pattern, pubsub.Default.PSubscribe(ctx.Subscriber, pattern))
+	}
+	return pubSubMode(ctx, reply)
+}
+
+// Unsubscribes the connection from patterns or from every pattern when
+// none are given.
+//
+//	PUNSUBSCRIBE [pattern [pattern ...]]
+type PUnsubscribe struct {
+	Patterns []string
+}
+
+func (c *PUnsubscribe) Name() string   { return "PUNSUBSCRIBE" }
+func (c *PUnsubscribe) Help() string   { return "PUNSUBSCRIBE [pattern [pattern ...]]" }
+func (c *PUnsubscribe) IsError() bool  { return false }
+func (c *PUnsubscribe) IsWorker() bool { return false }
+
+func (c *PUnsubscribe) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 1+len(c.Patterns))
+	buf = resp.AppendBulkString(buf, c.Name())
+	return appendKeys(buf, c.Patterns)
+}
+
+func (c *PUnsubscribe) Parse(args [][]byte) Command {
+	return &PUnsubscribe{Patterns: parseKeys(args[1:])}
+}
+
+func (c *PUnsubscribe) Handle(ctx *Context) Reply {
+	if ctx.Subscriber == nil {
+		return subscriptionReply{subscription("punsubscribe", "", 0)}
+	}
+	patterns := c.Patterns
+	if len(patterns) == 0 {
+		patterns = pubsub.Default.Patterns(ctx.Subscriber)
+	}
+	if len(patterns) == 0 {
+		return pubSubMode(ctx, subscriptionReply{
+			subscription("punsubscribe", "", pubsub.Default.Count(ctx.Subscriber)),
+		})
+	}
+	reply := make(subscriptionReply, len(patterns))
+	for i, pattern := range patterns {
+		reply[i] = subscription("punsubscribe", pattern, pubsub.Default.PUnsubscribe(ctx.Subscriber, pattern))
+	}
+	return pubSubMode(ctx, reply)
+}
+
+// The connection stays in PUB/SUB mode while it has any subscription.
+func pubSubMode(ctx *Context, reply Reply) Reply {
+	if pubsub.Default.Count(ctx.Subscriber) > 0 {
+		ctx.SetKind(api.ConnPubSub)
+	} else {
+		ctx.SetKind(api.ConnCommand)
+	}
+	return reply
+}
+
+// An empty name is a nil channel.
+func subscription(kind, name string, count int) api.Array {
+	var channel Reply = api.NIL
+	if name != "" {
+		channel = api.BulkString(name)
+	}
+	return api.Array{api.BulkString(kind), channel, api.Int(count)}
+}
+
+// Every channel or pattern of a (un)subscribe gets a reply of it's own.
+type subscriptionReply []api.Array
+
+func (r subscriptionReply) IsError() bool { return false }
+
+func (r subscriptionReply) MarshalReply(b []byte) []byte {
+	for _, reply := range r {
+		b = reply.MarshalReply(b)
+	}
+	return b
+}
+
+func (r subscriptionReply) UnmarshalReply(packet []byte, args [][]byte) error {
+	return nil
+}
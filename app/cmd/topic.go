@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() { api.Register(&Topic{}) }
+
+const (
+	TopicCreate = "CREATE"
+	TopicAlter  = "ALTER"
+	TopicAppend = "APPEND"
+	TopicList   = "LIST"
+	TopicRead   = "READ"
+)
+
+// Records returned by TOPIC READ without a count.
+const topicReadDefaultCount = 100
+
+// Manages topics. Definitions and records go through the cluster log.
+// Records are validated against the schema of the topic according to its
+// validation mode and a rejected record fails the whole append with an
+// error naming the record. SYNC sets when appends are synced to disk:
+// on every append, every number of milliseconds or never. Topics are synced
+// every segment.sync-interval by default. READ replies the offset, log index
+// and data of each record from the offset FROM on. Offsets number the records
+// of a topic from zero.
+//
+//	TOPIC CREATE name [SCHEMA message] [CODEC PROTOBUF|JSON] [VALIDATION OFF|WARN|REJECT] [SYNC ALWAYS|ms|NEVER]
+//	TOPIC ALTER name [SCHEMA message] [CODEC PROTOBUF|JSON] [VALIDATION OFF|WARN|REJECT] [SYNC ALWAYS|ms|NEVER]
+//	TOPIC APPEND name record [record ...]
+//	TOPIC READ name [FROM offset] [COUNT count]
+//	TOPIC LIST
+type Topic struct {
+	Sub   string
+	Topic string
+	Args  []string
+
+	// Options of READ
+	From  uint64
+	Count int
+}
+
+func (c *Topic) Name() string { return "TOPIC" }
+func (c *Topic) Help() string {
+	return "TOPIC CREATE|ALTER name [SCHEMA message] [CODEC PROTOBUF|JSON] [VALIDATION OFF|WARN|REJECT] [SYNC ALWAYS|ms|NEVER] | APPEND name record [record ...] | READ name [FROM offset] [COUNT count] | LIST"
+}
+func (c *Topic) IsError() bool  { return false }
+func (c *Topic) IsWorker() bool { return c.Sub != TopicList }
+
+func (c *Topic) Marshal(buf []byte) []byte {
+	if c.Sub == TopicList {
+		buf = resp.AppendArray(buf, 2)
+		buf = resp.AppendBulkString(buf, c.Name())
+		return resp.AppendBulkString(buf, c.Sub)
+	}
+	buf = resp.AppendArray(buf, 3+len(c.Args))
+	buf = resp.AppendBulkString(buf, c.Name())
+	buf = resp.AppendBulkString(buf, c.Sub)
+	buf = resp.AppendBulkString(buf, c.Topic)
+	return appendKeys(buf, c.Args)
+}
+
+func (c *Topic) Parse(args [][]byte) Command {
+	if len(args) < 2 {
+		return Err("ERR invalid params")
+	}
+	cmd := &Topic{Sub: strings.ToUpper(string(args[1]))}
+
+	switch cmd.Sub {
+	default:
+		return Err("ERR unknown TOPIC subcommand '" + string(args[1]) + "'")
+
+	case TopicList:
+		if len(args) != 2 {
+			return Err("ERR invalid params")
+		}
+		return cmd
+
+	case TopicCreate, TopicAlter:
+		if len(args) < 3 || len(args)%2 == 0 {
+			return Err("ERR invalid params")
+		}
+
+	case TopicRead:
+		if len(args) < 3 || len(args)%2 == 0 {
+			return Err("ERR invalid params")
+		}
+		cmd.Count = topicReadDefaultCount
+		for i := 3; i < len(args); i += 2 {
+			n, err := strconv.ParseUint(string(args[i+1]), 10, 63)
+			if err != nil {
+				return Err("ERR value is not an integer or out of range")
+			}
+			switch strings.ToUpper(string(args[i])) {
+			case "FROM":
+				cmd.From = n
+			case "COUNT":
+				cmd.Count = int(n)
+			default:
+				return Err("ERR syntax error")
+			}
+		}
+
+	case TopicAppend:
+		if len(args) < 4 {
+			return Err("ERR invalid params")
+		}
+	}
+	cmd.Topic = string(args[2])
+	cmd.Args = parseKeys(args[3:])
+	return cmd
+}
+
+func (c *Topic) Handle(ctx *Context) Reply {
+	if api.Topics == nil {
+		return Err("ERR topics not started")
+	}
+
+	var (
+		entry api.ApplyCommand
+		err   error
+	)
+	switch c.Sub {
+	case TopicList:
+		names := api.Topics.Names()
+		reply := make(api.Array, len(names))
+		for i, name := range names {
+			reply[i] = api.BulkString(name)
+		}
+		return reply
+
+	case TopicRead:
+		records, err := api.Topics.Read(c.Topic, c.From, c.Count)
+		if err != nil {
+			return Error(err)
+		}
+		reply := make(api.Array, len(records))
+		for i, r := range records {
+			reply[i] = api.Array{
+				api.Int(r.Offset),
+				api.Int(r.LogID),
+				api.Bulk(r.Data),
+			}
+		}
+		return reply
+
+	case TopicCreate:
+		entry, err = api.Topics.CreateEntry(c.Topic, c.Args)
+
+	case TopicAlter:
+		entry, err = api.Topics.AlterEntry(c.Topic, c.Args)
+
+	case TopicAppend:
+		// Fail fast before it reaches the log
+		entry, err = api.Topics.AppendEntry(c.Topic, c.Args)
+	}
+	if err != nil {
+		return Error(err)
+	}
+	return api.Propose(entry)
+}
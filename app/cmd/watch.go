@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/table"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() {
+	api.Register(&Watch{})
+	api.Register(&Unwatch{})
+}
+
+// Marks keys to be watched for the next EXEC. The transaction is aborted
+// if any of them is written before it runs.
+//
+//	WATCH key [key ...]
+type Watch struct {
+	Keys []string
+}
+
+func (c *Watch) Name() string   { return "WATCH" }
+func (c *Watch) Help() string   { return "WATCH key [key ...]" }
+func (c *Watch) IsError() bool  { return false }
+func (c *Watch) IsWorker() bool { return false }
+
+func (c *Watch) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 1+len(c.Keys))
+	buf = resp.AppendBulkString(buf, c.Name())
+	return appendKeys(buf, c.Keys)
+}
+
+func (c *Watch) Parse(args [][]byte) Command {
+	if len(args) < 2 {
+		return Err("ERR wrong number of arguments for 'watch' command")
+	}
+	return &Watch{Keys: parseKeys(args[1:])}
+}
+
+// Records the current revision of each key. Keys that are already watched
+// keep their original revision.
+func (c *Watch) Handle(ctx *Context) Reply {
+	if ctx.Watched == nil {
+		ctx.Watched = make(api.Watched)
+	}
+	api.Keyspace.View(func() error {
+		for _, key := range c.Keys {
+			if _, ok := ctx.Watched[key]; !ok {
+				ctx.Watched[key] = api.Keyspace.WatchRevision(table.StringKey(key))
+			}
+		}
+		return nil
+	})
+	return Ok
+}
+
+// Forgets every watched key.
+//
+//	UNWATCH
+type Unwatch struct{}
+
+func (c *Unwatch) Name() string   { return "UNWATCH" }
+func (c *Unwatch) Help() string   { return "UNWATCH" }
+func (c *Unwatch) IsError() bool  { return false }
+func (c *Unwatch) IsWorker() bool { return false }
+
+func (c *Unwatch) Marshal(buf []byte) []byte {
+	buf = resp.AppendArray(buf, 1)
+	buf = resp.AppendBulkString(buf, c.Name())
+	return buf
+}
+
+func (c *Unwatch) Parse(args [][]byte) Command {
+	if len(args) != 1 {
+		return Err("ERR wrong number of arguments for 'unwatch' command")
+	}
+	return c
+}
+
+func (c *Unwatch) Handle(ctx *Context) Reply {
+	ctx.Watched = nil
+	return Ok
+}
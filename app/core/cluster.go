@@ -7,8 +7,13 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/backup"
+	"github.com/genzai-io/sliced/app/cmd"
+	"github.com/genzai-io/sliced/app/events"
 	"github.com/genzai-io/sliced/app/node"
 	"github.com/genzai-io/sliced/app/raft"
 	"github.com/genzai-io/sliced/common/raft"
@@ -52,8 +57,13 @@ type ClusterService struct {
 	transport *raft_service.RaftTransport
 	//transport  *raft.NetworkTransport
 	store      IRaftStore
+	backup     *backup.Source
 	observerCh chan raft.Observation
 	observer   *raft.Observer
+
+	// Held while a log entry is applied. Backups hold it so the topic
+	// segments and the log are copied at the same entry.
+	applyMu sync.Mutex
 }
 
 func (s *ClusterService) OnStart() error {
@@ -121,7 +131,7 @@ func (s *ClusterService) OnStart() error {
 	}
 
 	// Create the log store and stable store.
-	s.store, err = raft_service.NewLogStore(
+	store, err := raft_service.NewLogStore(
 		logpath,
 		raft_service.Low,
 		s.Logger.With().Str("logger", "cluster.store").Logger(),
@@ -131,6 +141,15 @@ func (s *ClusterService) OnStart() error {
 		s.Logger.Error().Err(err).Msg("log store failed")
 		return fmt.Errorf("new log store: %s", err)
 	}
+	s.store = store
+
+	// Snapshots are in memory so the log is all there is to back up. Topic
+	// segments are appended to as entries are applied so applying waits for
+	// the backup to copy them.
+	if logpath != ":memory:" {
+		s.backup = &backup.Source{Path: logpath, Save: store.Save, Hold: s.holdApply}
+		backup.Register(s.backup)
+	}
 
 	//s.set = item.NewSortedSet()
 	//bootstrap := s.set.Length() == 0
@@ -153,6 +172,7 @@ func (s *ClusterService) OnStart() error {
 	})
 	go s.runObserver()
 	s.raft.RegisterObserver(s.observer)
+	go s.runExpire()
 
 	// Bootstrap?
 	if moved.Bootstrap {
@@ -172,12 +192,32 @@ func (s *ClusterService) OnStart() error {
 	return nil
 }
 
+// Stops applying log entries until release is called.
+func (s *ClusterService) holdApply() (release func()) {
+	s.applyMu.Lock()
+	return s.applyMu.Unlock
+}
+
+// Interval between checks of the leader and configuration for events.
+const watchInterval = time.Second
+
 func (s *ClusterService) runObserver() {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	var (
+		leader  raft.ServerAddress
+		members map[raft.ServerID]raft.ServerAddress
+	)
+
 	for {
 		select {
 		case <-s.ctx.Done():
 			return
 
+		case <-ticker.C:
+			leader, members = s.publishChanges(leader, members)
+
 		case observation, ok := <-s.observerCh:
 			if !ok {
 				return
@@ -186,6 +226,7 @@ func (s *ClusterService) runObserver() {
 			switch val := observation.Data.(type) {
 			case raft.RaftState:
 				s.Logger.Debug().Msgf("state changed to %s", val)
+				leader, members = s.publishChanges(leader, members)
 
 			case *raft.RequestVoteRequest:
 				s.Logger.Debug().Msgf("vote request %s", val)
@@ -194,9 +235,96 @@ func (s *ClusterService) runObserver() {
 	}
 }
 
+// Publishes leader changes and the nodes that joined or left since the
+// last check. The raft library only observes state changes and votes so
+// the leader and configuration are compared instead.
+func (s *ClusterService) publishChanges(
+	leader raft.ServerAddress,
+	members map[raft.ServerID]raft.ServerAddress,
+) (raft.ServerAddress, map[raft.ServerID]raft.ServerAddress) {
+	if current := s.raft.Leader(); current != leader {
+		leader = current
+		if leader != "" {
+			events.Publish(events.LeaderChanged, string(leader),
+				"term", s.raft.Stats()["term"])
+		}
+	}
+
+	future := s.raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return leader, members
+	}
+	current := make(map[raft.ServerID]raft.ServerAddress)
+	for _, srv := range future.Configuration().Servers {
+		current[srv.ID] = srv.Address
+	}
+	// The first configuration seen is the starting point
+	if members == nil {
+		return leader, current
+	}
+	for id, address := range current {
+		if _, ok := members[id]; !ok {
+			events.Publish(events.NodeJoined, string(address), "id", string(id))
+		}
+	}
+	for id, address := range members {
+		if _, ok := current[id]; !ok {
+			events.Publish(events.NodeLeft, string(address), "id", string(id))
+		}
+	}
+	return leader, current
+}
+
+// Interval between scans for expired keys and the most keys removed by a
+// single log entry.
+const (
+	expireInterval = 100 * time.Millisecond
+	expireBatch    = 64
+)
+
+// The leader removes expired keys through the cluster log so every member
+// deletes them at the same point and observers see a single expire event.
+func (s *ClusterService) runExpire() {
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+
+		case <-ticker.C:
+			if !s.IsLeader() {
+				continue
+			}
+
+			now := time.Now().Unix()
+			var keys []string
+			api.Keyspace.View(func() error {
+				for _, key := range api.Keyspace.Expired(now, expireBatch) {
+					keys = append(keys, fmt.Sprint(key))
+				}
+				return nil
+			})
+			if len(keys) == 0 {
+				continue
+			}
+
+			if reply, ok := api.Propose(&cmd.ApplyExpire{Now: now, Keys: keys}).(api.Err); ok {
+				s.Logger.Warn().Str("err", string(reply)).Msg("expire failed")
+			}
+		}
+	}
+}
+
 func (c *ClusterService) OnStop() {
 	c.cancel()
 
+	if c.backup != nil {
+		backup.Unregister(c.backup)
+		c.backup = nil
+	}
+
 	if err := c.raft.Shutdown(); err.Error() != nil {
 		c.Logger.Error().AnErr("err", err.Error()).Msg("raft.Shutdown() error")
 	}
@@ -1,8 +1,16 @@
 package core
 
 import (
+	"bytes"
+	"encoding/binary"
 	"io"
 
+	"github.com/genzai-io/sliced/app/acl"
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/database"
+	"github.com/genzai-io/sliced/app/document"
+	"github.com/genzai-io/sliced/app/queue"
+	"github.com/genzai-io/sliced/app/slice"
 	"github.com/genzai-io/sliced/common/raft"
 )
 
@@ -10,7 +18,25 @@ type clusterFSM ClusterService
 
 // Apply applies a Raft log entry to the key-value store.
 func (f *clusterFSM) Apply(l *raft.Log) interface{} {
-	return nil
+	if l.Type != raft.LogCommand {
+		return nil
+	}
+
+	command, at, err := api.UnmarshalApplyAt(l.Data)
+	if err != nil {
+		f.Logger.Error().AnErr("err", err).Uint64("index", l.Index).Msg("invalid log entry")
+		return api.Err("ERR " + err.Error())
+	}
+
+	f.applyMu.Lock()
+	defer f.applyMu.Unlock()
+
+	// Changes made by the command are tagged with the log index and keys
+	// expire as of the time the command was proposed.
+	api.Keyspace.SetCommitIndex(l.Index)
+	api.Keyspace.SetNow(at)
+	defer api.Keyspace.SetNow(0)
+	return command.Handle()
 }
 
 // Snapshot returns a snapshot of the key-value store.
@@ -18,29 +44,180 @@ func (f *clusterFSM) Snapshot() (raft.FSMSnapshot, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	return &clusterFSMSnapshot{}, nil
+	var keyspace bytes.Buffer
+	err := api.Keyspace.View(func() error {
+		return api.Keyspace.Save(&keyspace)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	topics, err := slice.Topics.Marshal(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	databases, err := database.Databases.Marshal(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	queues, err := queue.Queues.Marshal(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &clusterFSMSnapshot{
+		schemas:   document.Service.MarshalSchemas(nil),
+		keyspace:  keyspace.Bytes(),
+		users:     acl.Default.Marshal(nil),
+		topics:    topics,
+		databases: databases,
+		queues:    queues,
+	}, nil
 }
 
 // Restore stores the key-value store to a previous state.
 func (f *clusterFSM) Restore(rc io.ReadCloser) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	defer rc.Close()
+
 	// Set the state from the snapshot, no lock required according to
 	// Hashicorp docs.
-	//f.db = db
+	schemas, err := readSnapshotSection(rc)
+	if err != nil {
+		return err
+	}
+	if err := document.Service.UnmarshalSchemas(schemas); err != nil {
+		return err
+	}
+
+	keyspace, err := readSnapshotSection(rc)
+	if err != nil {
+		return err
+	}
+	err = api.Keyspace.Update(func() error {
+		return api.Keyspace.Load(bytes.NewReader(keyspace))
+	})
+	if err != nil {
+		return err
+	}
+
+	// Snapshots taken before users were replicated end here and only
+	// have the default user
+	users, err := readSnapshotSection(rc)
+	if err == io.EOF {
+		users, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := acl.Default.Unmarshal(users); err != nil {
+		return err
+	}
+
+	// Snapshots taken before topics were replicated end here
+	topics, err := readSnapshotSection(rc)
+	if err == io.EOF {
+		topics, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := slice.Topics.Unmarshal(topics); err != nil {
+		return err
+	}
+
+	// Snapshots taken before databases were replicated end here
+	databases, err := readSnapshotSection(rc)
+	if err == io.EOF {
+		databases, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := database.Databases.Unmarshal(databases); err != nil {
+		return err
+	}
+
+	// Snapshots taken before queues were replicated end here
+	queues, err := readSnapshotSection(rc)
+	if err == io.EOF {
+		queues, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	return queue.Queues.Unmarshal(queues)
+}
+
+// Reads a length prefixed section of a snapshot.
+func readSnapshotSection(r io.Reader) ([]byte, error) {
+	var size [8]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	section := make([]byte, binary.LittleEndian.Uint64(size[:]))
+	if _, err := io.ReadFull(r, section); err != nil {
+		return nil, err
+	}
+	return section, nil
+}
 
-	return nil
+// Writes a length prefixed section of a snapshot.
+func writeSnapshotSection(w io.Writer, section []byte) error {
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(section)))
+	if _, err := w.Write(size[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(section)
+	return err
 }
 
+// Cluster state is replicated by the cluster log into the schema registry,
+// the keyspace, the users, the topics, the databases and the queues. The
+// Dictionary is local to the node and backed up on its own. The users are
+// also saved to it so they're loaded before the log is replayed.
 type clusterFSMSnapshot struct {
-	store *Dictionary
+	schemas   []byte
+	keyspace  []byte
+	users     []byte
+	topics    []byte
+	databases []byte
+	queues    []byte
 }
 
 func (f *clusterFSMSnapshot) Persist(sink raft.SnapshotSink) error {
 	err := func() error {
-		// Encode data.
-		err := f.store.db.Save(sink)
-		if err != nil {
+		// Encode schema registry
+		if err := writeSnapshotSection(sink, f.schemas); err != nil {
+			return err
+		}
+
+		// Encode keyspace
+		if err := writeSnapshotSection(sink, f.keyspace); err != nil {
+			return err
+		}
+
+		// Encode users
+		if err := writeSnapshotSection(sink, f.users); err != nil {
+			return err
+		}
+
+		// Encode topics
+		if err := writeSnapshotSection(sink, f.topics); err != nil {
+			return err
+		}
+
+		// Encode databases
+		if err := writeSnapshotSection(sink, f.databases); err != nil {
+			return err
+		}
+
+		// Encode queues
+		if err := writeSnapshotSection(sink, f.queues); err != nil {
 			return err
 		}
 
@@ -61,6 +61,18 @@ func (s *ClusterService) Vote(payload []byte) api.CommandReply {
 	return s.transport.HandleRequestVote(payload)
 }
 
+// Appends to the log and waits for the clusterFSM to apply it
+func (s *ClusterService) Apply(payload []byte) api.CommandReply {
+	future := s.raft.Apply(payload, moved.RaftTimeout)
+	if err := future.Error(); err != nil {
+		return api.Err("ERR " + err.Error())
+	}
+	if reply, ok := future.Response().(api.CommandReply); ok && reply != nil {
+		return reply
+	}
+	return api.OK
+}
+
 // Only for RaftTransport RAFTINSTALL
 func (s *ClusterService) Install(conn *cmd.Context, arg []byte) cmd.Command {
 	return s.transport.HandleInstallSnapshot(conn, arg)
@@ -1,16 +1,69 @@
 package core
 
 import (
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"time"
+
 	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/acl"
 	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/certs"
+	"github.com/genzai-io/sliced/app/database"
 	"github.com/genzai-io/sliced/app/fs"
+	"github.com/genzai-io/sliced/app/notify"
+	"github.com/genzai-io/sliced/app/queue"
+	"github.com/genzai-io/sliced/app/record"
+	"github.com/genzai-io/sliced/app/slice"
 	"github.com/genzai-io/sliced/common/service"
+	"github.com/genzai-io/sliced/proto/store"
 )
 
 var Instance *Service
 
 func init() {
 	Instance = NewService()
+	moved.OnConfigChange(configChanged)
+	moved.OnConfigValidate(func(c *moved.Config) error {
+		_, err := acl.ParseLocalUsers(c.ACLUsers)
+		return err
+	})
+}
+
+// Applies the config settings that change while the node runs.
+func configChanged(changes []moved.ConfigChange) {
+	for _, change := range changes {
+		switch change.Key {
+		case "segment.sync-interval":
+			configureSync()
+
+		case "slowlog.slower-than", "slowlog.max-len":
+			configureSlowlog()
+
+		case "acl.users":
+			configureUsers()
+		}
+	}
+}
+
+func configureSync() {
+	if interval := atomic.LoadInt64(&moved.SegmentSyncInterval); interval > 0 {
+		fs.SetSyncInterval(time.Duration(interval) * time.Millisecond)
+	}
+}
+
+func configureSlowlog() {
+	api.Slowlog.Configure(
+		time.Duration(atomic.LoadInt64(&moved.SlowlogSlowerThan))*time.Microsecond,
+		int(atomic.LoadInt64(&moved.SlowlogMaxLen)),
+	)
+}
+
+func configureUsers() {
+	if err := acl.Default.SetLocal(moved.CurrentConfig().ACLUsers); err != nil {
+		moved.Logger.Error().Err(err).Msg("acl.users")
+	}
 }
 
 // Instance store for the definitions of system objects and Cluster topology.
@@ -25,6 +78,9 @@ type Service struct {
 	Schema  *Dictionary
 	Cluster *ClusterService
 	Drives  *fs.DriveService
+
+	stopNotify func()
+	stopCDC    func()
 }
 
 func NewService() *Service {
@@ -37,6 +93,11 @@ func NewService() *Service {
 
 func (b *Service) OnStart() error {
 	var err error
+	// Load the certificates before the cluster dials any member
+	if err = certs.Default.Configure(moved.TLSCertFile, moved.TLSKeyFile, moved.TLSCAFile); err != nil {
+		return err
+	}
+
 	// Start schema service
 	b.Schema = newStore()
 	err = b.Schema.Start()
@@ -45,7 +106,15 @@ func (b *Service) OnStart() error {
 	}
 	//api.Database = b.Database
 
+	// Users are loaded from the Dictionary before the cluster log changes
+	// them
+	if err = acl.Default.SetStore(b.Schema); err != nil {
+		b.Schema.Stop()
+		return err
+	}
+
 	// Start drive service
+	configureSync()
 	b.Drives = fs.NewDriveService()
 	err = b.Drives.Start()
 	if err != nil {
@@ -53,6 +122,10 @@ func (b *Service) OnStart() error {
 		return err
 	}
 	api.Drives = b.Drives
+	slice.CreateTail = b.createTail
+	api.Topics = slice.Topics
+	api.Databases = database.Databases
+	api.Queues = queue.Queues
 
 	// Start Cluster
 	b.Cluster = newCluster(b.Schema)
@@ -64,10 +137,64 @@ func (b *Service) OnStart() error {
 	}
 	api.Cluster = b.Cluster
 
+	configureSlowlog()
+	configureUsers()
+
+	// Keyspace notifications
+	flags, err := notify.ParseFlags(moved.NotifyKeyspaceEvents)
+	if err != nil {
+		b.Logger.Warn().Str("value", moved.NotifyKeyspaceEvents).Msg("invalid notify.keyspace-events")
+	}
+	b.stopNotify = notify.Start(api.Keyspace, 0, flags)
+
+	// Change feed. The keys are backfilled when the topic is created.
+	if moved.NotifyCDCTopic != "" {
+		cdc := notify.StartCDC(api.Keyspace, "*", cdcTopic(moved.NotifyCDCTopic))
+		b.stopCDC = cdc.Stop
+		slice.TopicCreated = func(topic *slice.Topic) {
+			if topic.Model().Name == moved.NotifyCDCTopic {
+				cdc.Backfill()
+			}
+		}
+	}
+
 	return nil
 }
 
+// Topic the change feed is appended to. It's looked up on every append
+// since it may be created after the node starts.
+type cdcTopic string
+
+func (name cdcTopic) AppendRecords(records []*record.Record) error {
+	topic, ok := slice.Topics.Get(string(name))
+	if !ok {
+		return notify.ErrNoFeed
+	}
+	return topic.Append(records)
+}
+
+// Creates the tail segment of a topic on the drive picked for it. Segments
+// are named by the log index of their first record so replaying the cluster
+// log starts a new segment instead of writing over one.
+func (b *Service) createTail(topic *store.Topic, first uint64) (*fs.SegmentWriter, error) {
+	name := filepath.Join("topics", strconv.FormatInt(topic.Id, 10), strconv.FormatUint(first, 10)+".s")
+	tail, err := b.Drives.Create(topic.Drive, name, 0, topic.Level)
+	if err != nil {
+		return nil, err
+	}
+	tail.SetLevel(topic.Level, time.Duration(topic.SyncInterval)*time.Millisecond)
+	return tail, nil
+}
+
 func (b *Service) OnStop() {
+	if b.stopNotify != nil {
+		b.stopNotify()
+	}
+	if b.stopCDC != nil {
+		b.stopCDC()
+		slice.TopicCreated = nil
+	}
+
 	if err := b.Cluster.Stop(); err != nil {
 		b.Logger.Error().AnErr("err", err).Msg("Cluster.Stop() error")
 	}
@@ -76,6 +203,7 @@ func (b *Service) OnStop() {
 		b.Logger.Error().AnErr("err", err).Msg("Drives.Stop() error")
 	}
 
+	acl.Default.SetStore(nil)
 	if err := b.Schema.Stop(); err != nil {
 		b.Logger.Error().AnErr("err", err).Msg("Database.Stop() error")
 	}
@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/backup"
 	"github.com/genzai-io/sliced/app/database"
 	"github.com/genzai-io/sliced/app/node"
 	"github.com/genzai-io/sliced/app/raft"
@@ -25,6 +26,8 @@ type Dictionary struct {
 	mu   sync.RWMutex
 	db   *btrdb.DB
 
+	backup *backup.Source
+
 	bootstrap bool
 
 	raftLock  sync.Mutex
@@ -79,10 +82,19 @@ func (s *Dictionary) OnStart() error {
 		return err
 	}
 
+	if name != ":memory:" {
+		s.backup = &backup.Source{Path: name, Save: s.db.Save}
+		backup.Register(s.backup)
+	}
+
 	return nil
 }
 
 func (s *Dictionary) OnStop() {
+	if s.backup != nil {
+		backup.Unregister(s.backup)
+		s.backup = nil
+	}
 	if err := s.db.Close(); err != nil {
 		s.Logger.Error().AnErr("err", err).Msg("db.Close() error")
 	}
@@ -1 +1,45 @@
 package core
+
+import (
+	"testing"
+
+	"github.com/genzai-io/sliced/app/acl"
+	"github.com/genzai-io/sliced/common/btrdb"
+)
+
+func TestDictionary_Users(t *testing.T) {
+	db, err := btrdb.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	dict := &Dictionary{db: db}
+
+	users := acl.NewUsers()
+	if err := users.SetStore(dict); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.SetUser("alice", "on", ">secret", "+@read"); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.SetUser("bob", "on", "nopass"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.DelUser("bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := acl.NewUsers()
+	if err := loaded.SetStore(dict); err != nil {
+		t.Fatal(err)
+	}
+	if names := loaded.Names(); len(names) != 2 || names[0] != "alice" {
+		t.Fatalf("unexpected users %v", names)
+	}
+	if _, err := loaded.Authenticate("alice", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dict.DeleteUser("nobody"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"github.com/genzai-io/sliced/common/btrdb"
+)
+
+// Users are saved under this prefix followed by their name.
+const userKeyPrefix = "acl:user:"
+
+// Every user saved by SaveUser. Implements acl.Store.
+func (s *Dictionary) Users() ([]byte, error) {
+	var users []byte
+	err := s.db.View(func(tx *btrdb.Tx) error {
+		return tx.AscendKeys(userKeyPrefix+"*", func(key, value string) bool {
+			users = append(users, value...)
+			return true
+		})
+	})
+	return users, err
+}
+
+func (s *Dictionary) SaveUser(name string, user []byte) error {
+	return s.db.Update(func(tx *btrdb.Tx) error {
+		_, _, err := tx.Set(userKeyPrefix+name, string(user), nil)
+		return err
+	})
+}
+
+func (s *Dictionary) DeleteUser(name string) error {
+	return s.db.Update(func(tx *btrdb.Tx) error {
+		if _, err := tx.Delete(userKeyPrefix + name); err != nil && err != btrdb.ErrNotFound {
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() {
+	api.RegisterApply(DatabaseCreateName, func() api.ApplyCommand { return &ApplyDatabaseCreate{} })
+}
+
+const DatabaseCreateName = "DBDEF"
+
+// Checks a new database and returns the log entry that creates it.
+func (c *Catalog) CreateEntry(name string) (api.ApplyCommand, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrDatabaseName
+	}
+	if _, ok := c.Get(name); ok {
+		return nil, ErrDatabaseExists
+	}
+	return &ApplyDatabaseCreate{Database: name, Timestamp: time.Now().UnixNano()}, nil
+}
+
+// Cluster log entry that creates a database. The time it was proposed at is
+// part of the entry so every node records the same creation time.
+type ApplyDatabaseCreate struct {
+	Database  string
+	Timestamp int64
+}
+
+func (c *ApplyDatabaseCreate) Name() string { return DatabaseCreateName }
+
+func (c *ApplyDatabaseCreate) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 3)
+	b = resp.AppendBulkString(b, c.Name())
+	b = resp.AppendBulkString(b, c.Database)
+	return resp.AppendBulkString(b, strconv.FormatInt(c.Timestamp, 10))
+}
+
+func (c *ApplyDatabaseCreate) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) != 3 {
+		return api.ErrInvalidParam
+	}
+	c.Timestamp, err = strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return err
+	}
+	c.Database = string(args[1])
+	return nil
+}
+
+// Replies the ID of the database.
+func (c *ApplyDatabaseCreate) Handle() api.CommandReply {
+	model, err := Databases.Create(c.Database, uint64(c.Timestamp))
+	if err != nil {
+		return api.Err("ERR " + err.Error())
+	}
+	return api.Int(int64(model.Id))
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/genzai-io/sliced/common/resp"
+	"github.com/genzai-io/sliced/proto/store"
+)
+
+var (
+	ErrDatabaseExists = errors.New("database already exists")
+	ErrDatabaseName   = errors.New("database name not set")
+
+	ErrInvalidDatabaseSnapshot = errors.New("invalid database snapshot")
+)
+
+// Databases of the cluster.
+var Databases = NewCatalog()
+
+// Definitions of every database by name. Databases are created through the
+// cluster log so every node has the same definitions.
+type Catalog struct {
+	mu     sync.RWMutex
+	nextID int32
+	byName map[string]*store.Database
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{
+		byName: make(map[string]*store.Database),
+	}
+}
+
+// Gets a copy of a database by name.
+func (c *Catalog) Get(name string) (store.Database, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	model, ok := c.byName[name]
+	if !ok {
+		return store.Database{}, false
+	}
+	return *model, true
+}
+
+// Names of every database sorted.
+func (c *Catalog) Names() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	names := make([]string, 0, len(c.byName))
+	for name := range c.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Creates a database and assigns it the next ID. created is in nanoseconds.
+// This must only be invoked from a Raft FSM so every node assigns the same
+// IDs.
+func (c *Catalog) Create(name string, created uint64) (store.Database, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return store.Database{}, ErrDatabaseName
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.byName[name]; ok {
+		return store.Database{}, ErrDatabaseExists
+	}
+	c.nextID++
+	model := &store.Database{
+		Id:      c.nextID,
+		Name:    name,
+		Created: created,
+		Changed: created,
+	}
+	c.byName[name] = model
+	return *model, nil
+}
+
+// Serializes every database definition as a series of RESP arrays.
+// This is used by Raft snapshots.
+func (c *Catalog) Marshal(b []byte) ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	names := make([]string, 0, len(c.byName))
+	for name := range c.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		data, err := c.byName[name].Marshal()
+		if err != nil {
+			return nil, err
+		}
+		b = resp.AppendArray(b, 1)
+		b = resp.AppendBulk(b, data)
+	}
+	return b, nil
+}
+
+// Replaces every database definition with the contents of Marshal.
+func (c *Catalog) Unmarshal(b []byte) error {
+	byName := make(map[string]*store.Database)
+	nextID := int32(0)
+
+	var (
+		args     [][]byte
+		complete bool
+		err      error
+	)
+	for len(b) > 0 {
+		_, complete, args, _, b, err = resp.ParseNextCommand(b, args[:0])
+		if err != nil {
+			return err
+		}
+		if !complete || len(args) != 1 {
+			return ErrInvalidDatabaseSnapshot
+		}
+		model := &store.Database{}
+		if err := model.Unmarshal(args[0]); err != nil {
+			return err
+		}
+		byName[model.Name] = model
+		if model.Id > nextID {
+			nextID = model.Id
+		}
+	}
+
+	c.mu.Lock()
+	c.byName = byName
+	c.nextID = nextID
+	c.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/genzai-io/sliced/app/api"
+)
+
+func TestCatalog_Create(t *testing.T) {
+	c := NewCatalog()
+	for i, name := range []string{"orders", "accounts"} {
+		model, err := c.Create(name, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if model.Id != int32(i+1) || model.Created != 100 {
+			t.Fatalf("unexpected database %v", model)
+		}
+	}
+	if _, err := c.Create("orders", 200); err != ErrDatabaseExists {
+		t.Fatalf("expected ErrDatabaseExists got %v", err)
+	}
+	if _, err := c.Create(" ", 200); err != ErrDatabaseName {
+		t.Fatalf("expected ErrDatabaseName got %v", err)
+	}
+	if names := c.Names(); len(names) != 2 || names[0] != "accounts" || names[1] != "orders" {
+		t.Fatalf("unexpected names %v", names)
+	}
+
+	// Definitions and the next ID survive a snapshot
+	b, err := c.Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewCatalog()
+	if err := restored.Unmarshal(b); err != nil {
+		t.Fatal(err)
+	}
+	if model, ok := restored.Get("accounts"); !ok || model.Id != 2 {
+		t.Fatalf("expected accounts got %v", model)
+	}
+	if model, _ := restored.Create("users", 300); model.Id != 3 {
+		t.Fatalf("expected ID 3 got %v", model)
+	}
+	if err := restored.Unmarshal([]byte("*2\r\n$1\r\na\r\n$1\r\nb\r\n")); err != ErrInvalidDatabaseSnapshot {
+		t.Fatalf("expected ErrInvalidDatabaseSnapshot got %v", err)
+	}
+}
+
+func TestApplyDatabaseCreate(t *testing.T) {
+	Databases = NewCatalog()
+	defer func() { Databases = NewCatalog() }()
+
+	entry, err := Databases.CreateEntry("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every node applies the same entry
+	applied := &ApplyDatabaseCreate{}
+	if err := applied.Unmarshal(entry.Marshal(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if reply := applied.Handle(); reply != api.Int(1) {
+		t.Fatalf("expected ID 1 got %v", reply)
+	}
+	if _, err := Databases.CreateEntry("orders"); err != ErrDatabaseExists {
+		t.Fatalf("expected ErrDatabaseExists got %v", err)
+	}
+	if reply := applied.Handle(); reply != api.Err("ERR "+ErrDatabaseExists.Error()) {
+		t.Fatalf("expected the duplicate to be rejected got %v", reply)
+	}
+}
@@ -0,0 +1,196 @@
+package document
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+)
+
+// Compatibility rule enforced when a new version of a schema is registered.
+//
+// Backward means consumers using the new schema can read data produced
+// with the previous schema. Forward means consumers still using the previous
+// schema can read data produced with the new schema. Full is both.
+type Compatibility byte
+
+const (
+	CompatNone     Compatibility = 0
+	CompatBackward Compatibility = 1
+	CompatForward  Compatibility = 2
+	CompatFull     Compatibility = CompatBackward | CompatForward
+
+	// Keeps the level of the package or BACKWARD for a new package
+	CompatDefault Compatibility = 0xff
+)
+
+func (c Compatibility) String() string {
+	switch c {
+	case CompatNone:
+		return "NONE"
+	case CompatBackward:
+		return "BACKWARD"
+	case CompatForward:
+		return "FORWARD"
+	case CompatFull:
+		return "FULL"
+	case CompatDefault:
+		return "DEFAULT"
+	}
+	return "UNKNOWN"
+}
+
+func ParseCompatibility(s string) (Compatibility, error) {
+	switch strings.ToUpper(s) {
+	case "", "DEFAULT":
+		return CompatDefault, nil
+	case "NONE":
+		return CompatNone, nil
+	case "BACKWARD":
+		return CompatBackward, nil
+	case "FORWARD":
+		return CompatForward, nil
+	case "FULL":
+		return CompatFull, nil
+	}
+	return CompatNone, fmt.Errorf("invalid compatibility '%s'", s)
+}
+
+// Returned when a schema version breaks the compatibility rules of it's package.
+type CompatibilityError struct {
+	Package    string
+	Compat     Compatibility
+	Violations []string
+}
+
+func (e *CompatibilityError) Error() string {
+	return fmt.Sprintf("schema '%s' is not %s compatible: %s",
+		e.Package, e.Compat, strings.Join(e.Violations, "; "))
+}
+
+// Checks a new schema version against the previously registered versions of the
+// same package. Field numbers may never be reused with a different name or type
+// across any previous version. Type and label changes as well as the removal of
+// messages, enums and enum values are checked against the latest version.
+func CheckCompatibility(history []*SchemaVersion, next *SchemaVersion, compat Compatibility) error {
+	if compat == CompatNone || len(history) == 0 {
+		return nil
+	}
+
+	err := &CompatibilityError{
+		Package: next.Package,
+		Compat:  compat,
+	}
+	violate := func(format string, args ...interface{}) {
+		err.Violations = append(err.Violations, fmt.Sprintf(format, args...))
+	}
+
+	nextMessages := next.messages()
+
+	// Field numbers can never be reused
+	for _, prev := range history {
+		for fqn, pm := range prev.messages() {
+			nm, ok := nextMessages[fqn]
+			if !ok {
+				continue
+			}
+			for _, pf := range pm.Fields {
+				nf := nm.FieldByNumber(int(pf.Number))
+				if nf == nil || nf.Name == pf.Name {
+					continue
+				}
+				violate("field %s number %d reused by '%s' (was '%s' in version %d)",
+					fqn, pf.Number, nf.Name, pf.Name, prev.Version)
+			}
+		}
+	}
+
+	latest := history[len(history)-1]
+	for fqn, pm := range latest.messages() {
+		nm, ok := nextMessages[fqn]
+		if !ok {
+			violate("message %s removed", fqn)
+			continue
+		}
+
+		for _, pf := range pm.Fields {
+			nf := nm.FieldByNumber(int(pf.Number))
+			if nf == nil {
+				if compat&CompatForward != 0 && pf.Descriptor.IsRequired() {
+					violate("required field %s.%s removed", fqn, pf.Name)
+				}
+				continue
+			}
+			if nf.Name != pf.Name {
+				// Already reported as reused
+				continue
+			}
+			if pf.WireType != nf.WireType ||
+				pf.Descriptor.GetTypeName() != nf.Descriptor.GetTypeName() {
+				violate("field %s.%s type changed from %s to %s",
+					fqn, pf.Name, fieldTypeName(pf), fieldTypeName(nf))
+				continue
+			}
+			if pf.Descriptor.IsRepeated() != nf.Descriptor.IsRepeated() {
+				violate("field %s.%s label changed from %s to %s",
+					fqn, pf.Name, pf.Descriptor.GetLabel(), nf.Descriptor.GetLabel())
+				continue
+			}
+			if !pf.Descriptor.IsRequired() && nf.Descriptor.IsRequired() && compat&CompatBackward != 0 {
+				violate("field %s.%s changed to required", fqn, pf.Name)
+			}
+			if pf.Descriptor.IsRequired() && !nf.Descriptor.IsRequired() && compat&CompatForward != 0 {
+				violate("field %s.%s is no longer required", fqn, pf.Name)
+			}
+		}
+
+		if compat&CompatBackward != 0 {
+			for _, nf := range nm.Fields {
+				if pm.FieldByNumber(int(nf.Number)) == nil && nf.Descriptor.IsRequired() {
+					violate("required field %s.%s added", fqn, nf.Name)
+				}
+			}
+		}
+	}
+
+	nextEnums := next.enums()
+	for fqn, pe := range latest.enums() {
+		ne, ok := nextEnums[fqn]
+		if !ok {
+			violate("enum %s removed", fqn)
+			continue
+		}
+		if compat&CompatBackward == 0 {
+			continue
+		}
+		for _, pv := range pe.Values {
+			if ne.valueByNumber(pv.Number) == nil {
+				violate("enum value %s.%s (%d) removed", fqn, pv.Name, pv.Number)
+			}
+		}
+	}
+
+	if len(err.Violations) == 0 {
+		return nil
+	}
+	sort.Strings(err.Violations)
+	return err
+}
+
+func (e *EnumType) valueByNumber(number int32) *EnumValue {
+	for _, v := range e.Values {
+		if v.Number == number {
+			return v
+		}
+	}
+	return nil
+}
+
+func fieldTypeName(f *FieldType) string {
+	switch f.WireType {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return f.Descriptor.GetTypeName()
+	}
+	return strings.ToLower(strings.TrimPrefix(f.WireType.String(), "TYPE_"))
+}
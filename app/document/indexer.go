@@ -0,0 +1,466 @@
+package document
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/genzai-io/sliced/app/table"
+	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+)
+
+var (
+	ErrIndexPathEmpty = errors.New("index path is empty")
+	ErrIndexNoFields  = errors.New("index has no fields")
+	ErrIndexFields    = errors.New("composite index supports at most 2 fields")
+	ErrIndexMulti     = errors.New("a repeated field without an element cannot be part of a composite index")
+)
+
+// Definition of a single field of a secondary index key.
+// Mirrors schema.KeyField.
+type KeyField struct {
+	// Dot separated path from the root message. A repeated field may be
+	// followed by an element index or '#' for the number of elements.
+	// Otherwise every element is indexed and a value has an entry per
+	// element.
+	Path            string
+	Desc            bool
+	CaseInsensitive bool
+}
+
+// A single step of a resolved field path.
+type pbufPathStep struct {
+	field *FieldType
+	// Element of a repeated field. -1 when the field is not repeated,
+	// -2 to project the number of elements and -3 for every element.
+	element int
+}
+
+const (
+	pathNoElement = -1
+	pathCount     = -2
+	pathEach      = -3
+)
+
+// Resolves a dot separated field path against the message type.
+func (mt *MessageType) resolvePath(path string) ([]pbufPathStep, error) {
+	if path == "" {
+		return nil, ErrIndexPathEmpty
+	}
+
+	var (
+		steps    []pbufPathStep
+		current  = mt
+		segments = strings.Split(path, ".")
+	)
+	for i := 0; i < len(segments); i++ {
+		if current == nil {
+			return nil, fmt.Errorf("index path '%s': '%s' is not a message", path, segments[i-1])
+		}
+		field, ok := current.FieldsByName[segments[i]]
+		if !ok {
+			field = current.fieldByJSONName(segments[i])
+		}
+		if field == nil {
+			return nil, fmt.Errorf("index path '%s': field '%s' not found in %s", path, segments[i], current.FQN)
+		}
+
+		step := pbufPathStep{field: field, element: pathNoElement}
+		if field.Descriptor.IsRepeated() {
+			step.element = pathEach
+			if i+1 < len(segments) {
+				if segments[i+1] == "#" {
+					step.element = pathCount
+					i++
+				} else if n, err := strconv.Atoi(segments[i+1]); err == nil {
+					if n < 0 {
+						return nil, fmt.Errorf("index path '%s': negative element %d", path, n)
+					}
+					step.element = n
+					i++
+				}
+			}
+		}
+		steps = append(steps, step)
+
+		if step.element == pathCount {
+			if i+1 < len(segments) {
+				return nil, fmt.Errorf("index path '%s': '#' must be the last segment", path)
+			}
+			break
+		}
+		current = field.Message
+	}
+
+	last := steps[len(steps)-1]
+	if last.element != pathCount && last.field.WireType == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return nil, fmt.Errorf("index path '%s': '%s' is a message", path, last.field.Name)
+	}
+	return steps, nil
+}
+
+// Whether the path selects every element of a repeated field.
+func multiValued(steps []pbufPathStep) bool {
+	for _, step := range steps {
+		if step.element == pathEach {
+			return true
+		}
+	}
+	return false
+}
+
+// Creates a KeyProjector that pulls a single key out of protobuf encoded
+// values. Paths through a repeated field without an element are rejected
+// since they select more than one key.
+func (mt *MessageType) PBUFProjector(path string) (table.KeyProjector, error) {
+	steps, err := mt.resolvePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if multiValued(steps) {
+		return nil, fmt.Errorf("index path '%s' selects every element of a repeated field", path)
+	}
+
+	return func(item *table.ValueItem) table.Key {
+		buf := []byte(item.Value)
+		for i, step := range steps {
+			last := i == len(steps)-1
+
+			// Fast path for singular scalar fields
+			if last && step.element == pathNoElement {
+				key, err := step.field.PBUFGet(buf)
+				if err != nil {
+					return table.SkipKey
+				}
+				return pbufIndexKey(key)
+			}
+
+			if step.element == pathCount {
+				count, err := step.field.pbufCount(buf)
+				if err != nil {
+					return table.SkipKey
+				}
+				return table.IntKey(count)
+			}
+
+			if last {
+				key, err := step.field.pbufElement(buf, step.element)
+				if err != nil {
+					return table.SkipKey
+				}
+				return pbufIndexKey(key)
+			}
+
+			// Descend into the embedded message
+			var ok bool
+			if buf, ok = step.field.pbufMessage(buf, step.element); !ok {
+				return table.SkipKey
+			}
+		}
+		return table.SkipKey
+	}, nil
+}
+
+// Creates a MultiKeyProjector that pulls every key a path selects out of
+// protobuf encoded values. Repeated fields without an element select each
+// of their elements and a key is returned once.
+func (mt *MessageType) PBUFMultiProjector(path string) (table.MultiKeyProjector, error) {
+	steps, err := mt.resolvePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(item *table.ValueItem) []table.Key {
+		var keys []table.Key
+		pbufWalk(steps, []byte(item.Value), func(key table.Key) {
+			if key == table.SkipKey {
+				return
+			}
+			for _, k := range keys {
+				if k == key {
+					return
+				}
+			}
+			keys = append(keys, key)
+		})
+		return keys
+	}, nil
+}
+
+// Calls fn with every key the steps select in buf.
+func pbufWalk(steps []pbufPathStep, buf []byte, fn func(key table.Key)) {
+	step := steps[0]
+	last := len(steps) == 1
+	switch {
+	case step.element == pathCount:
+		if count, err := step.field.pbufCount(buf); err == nil {
+			fn(table.IntKey(count))
+		}
+
+	case step.element == pathEach:
+		step.field.pbufEach(buf, func(wire int, raw uint64, b []byte) bool {
+			if last {
+				fn(pbufIndexKey(step.field.pbufKey(wire, raw, b)))
+			} else if wire == 2 {
+				pbufWalk(steps[1:], b, fn)
+			}
+			return true
+		})
+
+	case last && step.element == pathNoElement:
+		if key, err := step.field.PBUFGet(buf); err == nil {
+			fn(pbufIndexKey(key))
+		}
+
+	case last:
+		if key, err := step.field.pbufElement(buf, step.element); err == nil {
+			fn(pbufIndexKey(key))
+		}
+
+	default:
+		if msg, ok := step.field.pbufMessage(buf, step.element); ok {
+			pbufWalk(steps[1:], msg, fn)
+		}
+	}
+}
+
+// Creates a single field index from a protobuf field path. The index options
+// are derived from the field type. A repeated field without an element gives
+// a value an entry for each element.
+func (mt *MessageType) PBUFIndexer(field KeyField) (*table.IndexField, error) {
+	steps, err := mt.resolvePath(field.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	last := steps[len(steps)-1]
+	var opts table.IndexOpts
+	if last.element == pathCount {
+		opts = table.IndexInt(field.Desc)
+	} else {
+		opts = pbufIndexOpts(last.field.WireType, field.Desc)
+	}
+	if field.CaseInsensitive {
+		opts |= table.CaseInsensitive
+	}
+
+	if multiValued(steps) {
+		projector, err := mt.PBUFMultiProjector(field.Path)
+		if err != nil {
+			return nil, err
+		}
+		return table.NewMultiIndexer(field.Path, opts, projector), nil
+	}
+	projector, err := mt.PBUFProjector(field.Path)
+	if err != nil {
+		return nil, err
+	}
+	return table.NewIndexer(field.Path, opts, projector), nil
+}
+
+// Creates an Indexer from a key definition. A single field creates a simple
+// index and multiple fields create a composite index.
+func (mt *MessageType) KeyIndexer(fields ...KeyField) (table.Indexer, error) {
+	switch len(fields) {
+	case 0:
+		return nil, ErrIndexNoFields
+	case 1:
+		return mt.PBUFIndexer(fields[0])
+	case 2:
+		// Composite
+	default:
+		return nil, ErrIndexFields
+	}
+
+	indexers := make([]*table.IndexField, len(fields))
+	for i, field := range fields {
+		indexer, err := mt.PBUFIndexer(field)
+		if err != nil {
+			return nil, err
+		}
+		if indexer.IsMulti() {
+			return nil, ErrIndexMulti
+		}
+		indexers[i] = indexer
+	}
+	return table.Composite(indexers...), nil
+}
+
+func pbufIndexOpts(t descriptor.FieldDescriptorProto_Type, desc bool) table.IndexOpts {
+	switch t {
+	case descriptor.FieldDescriptorProto_TYPE_STRING,
+		descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return table.IndexString(desc)
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE,
+		descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return table.IndexFloat(desc)
+	}
+	return table.IndexInt(desc)
+}
+
+// Bools are indexed as 0 and 1.
+func pbufIndexKey(key table.Key) table.Key {
+	switch key {
+	case table.True:
+		return table.IntKey(1)
+	case table.False:
+		return table.IntKey(0)
+	}
+	return key
+}
+
+// Iterates every occurrence of the field in a protobuf message. Packed
+// repeated fields are expanded into their elements. The raw value is the
+// varint for wire type 0, the little endian value for wire types 1 and 5 and
+// the bytes for wire type 2.
+func (f *FieldType) pbufEach(buf []byte, fn func(wire int, raw uint64, b []byte) bool) error {
+	packable := pbufWireType(f.WireType) != 2
+
+	for len(buf) > 0 {
+		key, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return ErrProtobufField
+		}
+		buf = buf[n:]
+		wire := int(key & 7)
+		match := int32(key>>3) == f.Number
+
+		var (
+			raw uint64
+			b   []byte
+		)
+		switch wire {
+		case 0:
+			raw, n = binary.Uvarint(buf)
+			if n <= 0 {
+				return ErrProtobufVarint
+			}
+			buf = buf[n:]
+		case 5:
+			if len(buf) < 4 {
+				return ErrProtobuf32bit
+			}
+			raw = uint64(binary.LittleEndian.Uint32(buf))
+			buf = buf[4:]
+		case 1:
+			if len(buf) < 8 {
+				return ErrProtobuf64bit
+			}
+			raw = binary.LittleEndian.Uint64(buf)
+			buf = buf[8:]
+		case 2:
+			raw, n = binary.Uvarint(buf)
+			if n <= 0 || raw > uint64(len(buf)-n) {
+				return ErrProtobufLength
+			}
+			b = buf[n : n+int(raw)]
+			buf = buf[n+int(raw):]
+		default:
+			return ErrProtobufWireType
+		}
+
+		if !match {
+			continue
+		}
+
+		if wire == 2 && packable {
+			elementWire := pbufWireType(f.WireType)
+			for len(b) > 0 {
+				switch elementWire {
+				case 0:
+					raw, n = binary.Uvarint(b)
+					if n <= 0 {
+						return ErrProtobufVarint
+					}
+					b = b[n:]
+				case 5:
+					if len(b) < 4 {
+						return ErrProtobuf32bit
+					}
+					raw = uint64(binary.LittleEndian.Uint32(b))
+					b = b[4:]
+				case 1:
+					if len(b) < 8 {
+						return ErrProtobuf64bit
+					}
+					raw = binary.LittleEndian.Uint64(b)
+					b = b[8:]
+				}
+				if !fn(elementWire, raw, nil) {
+					return nil
+				}
+			}
+			continue
+		}
+
+		if !fn(wire, raw, b) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Number of occurrences of a repeated field.
+func (f *FieldType) pbufCount(buf []byte) (int, error) {
+	count := 0
+	err := f.pbufEach(buf, func(wire int, raw uint64, b []byte) bool {
+		count++
+		return true
+	})
+	return count, err
+}
+
+// Key of a single element of a repeated scalar field.
+func (f *FieldType) pbufElement(buf []byte, element int) (table.Key, error) {
+	var (
+		key table.Key = table.SkipKey
+		i             = 0
+	)
+	err := f.pbufEach(buf, func(wire int, raw uint64, b []byte) bool {
+		if i < element {
+			i++
+			return true
+		}
+		key = f.pbufKey(wire, raw, b)
+		return false
+	})
+	return key, err
+}
+
+// Key of an occurrence of a scalar field as passed by pbufEach.
+func (f *FieldType) pbufKey(wire int, raw uint64, b []byte) table.Key {
+	switch wire {
+	case 0:
+		return pbufVarintToKey(f.WireType, raw)
+	case 5:
+		return pbufFixed32ToKey(f.WireType, uint32(raw))
+	case 1:
+		return pbufFixed64ToKey(f.WireType, raw)
+	case 2:
+		return table.StringKey(b)
+	}
+	return table.SkipKey
+}
+
+// Bytes of an embedded message. Element is ignored for singular fields.
+func (f *FieldType) pbufMessage(buf []byte, element int) ([]byte, bool) {
+	var (
+		result []byte
+		found  bool
+		i      = 0
+	)
+	err := f.pbufEach(buf, func(wire int, raw uint64, b []byte) bool {
+		if wire != 2 {
+			return true
+		}
+		if element > 0 && i < element {
+			i++
+			return true
+		}
+		result, found = b, true
+		return false
+	})
+	return result, err == nil && found
+}
@@ -0,0 +1,145 @@
+package document
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/genzai-io/sliced/app/table"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+)
+
+func TestMessageType_PBUFProjector(t *testing.T) {
+	address := testField("address", 5, descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_LABEL_OPTIONAL)
+	address.TypeName = proto.String(".test.Address")
+
+	set := &descriptor.FileDescriptorSet{
+		File: []*descriptor.FileDescriptorProto{{
+			Name:    proto.String("test/user.proto"),
+			Package: proto.String("test"),
+			Syntax:  proto.String("proto3"),
+			MessageType: []*descriptor.DescriptorProto{{
+				Name: proto.String("User"),
+				Field: []*descriptor.FieldDescriptorProto{
+					testField("id", 1, descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+					testField("name", 2, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+					testField("tags", 3, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_LABEL_REPEATED),
+					testField("scores", 4, descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_LABEL_REPEATED),
+					address,
+				},
+			}, {
+				Name: proto.String("Address"),
+				Field: []*descriptor.FieldDescriptorProto{
+					testField("city", 1, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+				},
+			}},
+		}},
+	}
+	b, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewProtoService()
+	if _, err := s.RegisterSchema("test", CompatNone, b, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	mt, _ := s.MessageType(".test.User")
+
+	// id = 7, name = "bob", tags = ["a", "b"], scores = [3, 5] packed, address.city = "nyc"
+	value := []byte{
+		0x08, 0x07,
+		0x12, 0x03, 'b', 'o', 'b',
+		0x1a, 0x01, 'a',
+		0x1a, 0x01, 'b',
+		0x22, 0x02, 0x03, 0x05,
+		0x2a, 0x05, 0x0a, 0x03, 'n', 'y', 'c',
+	}
+	item := &table.ValueItem{Value: string(value)}
+
+	tests := []struct {
+		path string
+		key  table.Key
+	}{
+		{"id", table.IntKey(7)},
+		{"name", table.StringKey("bob")},
+		{"tags.1", table.StringKey("b")},
+		{"tags.#", table.IntKey(2)},
+		{"scores.1", table.IntKey(5)},
+		{"address.city", table.StringKey("nyc")},
+		{"tags.2", table.SkipKey},
+	}
+	for _, test := range tests {
+		projector, err := mt.PBUFProjector(test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key := projector(item); key != test.key {
+			t.Fatalf("%s: expected %v got %v", test.path, test.key, key)
+		}
+	}
+
+	if _, err := mt.PBUFProjector("address"); err == nil {
+		t.Fatal("expected message path to be rejected")
+	}
+	if _, err := mt.PBUFProjector("tags"); err == nil {
+		t.Fatal("expected a single key projector for every element to be rejected")
+	}
+	if _, err := mt.PBUFProjector("email"); err == nil {
+		t.Fatal("expected unknown path to be rejected")
+	}
+
+	indexer, err := mt.KeyIndexer(KeyField{Path: "name"}, KeyField{Path: "id", Desc: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if indexer.Fields() != 2 {
+		t.Fatalf("expected 2 fields got %d", indexer.Fields())
+	}
+	if _, err := mt.KeyIndexer(KeyField{Path: "name"}, KeyField{Path: "id"}, KeyField{Path: "tags.#"}); err != ErrIndexFields {
+		t.Fatalf("expected ErrIndexFields got %v", err)
+	}
+
+	// Every element of a repeated field
+	multi := []struct {
+		path string
+		keys string
+	}{
+		{"tags", "[a b]"},
+		{"scores", "[3 5]"},
+		{"id", "[7]"},
+	}
+	for _, test := range multi {
+		projector, err := mt.PBUFMultiProjector(test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if keys := fmt.Sprint(projector(item)); keys != test.keys {
+			t.Fatalf("%s: expected %s got %s", test.path, test.keys, keys)
+		}
+	}
+	if _, err := mt.KeyIndexer(KeyField{Path: "name"}, KeyField{Path: "tags"}); err != ErrIndexMulti {
+		t.Fatalf("expected ErrIndexMulti got %v", err)
+	}
+
+	tbl := table.NewTable()
+	tbl.Set(table.StringKey("u:1"), string(value), 0)
+	// tags = ["b", "c"]
+	tbl.Set(table.StringKey("u:2"), string([]byte{0x1a, 0x01, 'b', 0x1a, 0x01, 'c'}), 0)
+	indexer, err = mt.KeyIndexer(KeyField{Path: "tags"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.CreateIndex("tags", "u:*", indexer); err != nil {
+		t.Fatal(err)
+	}
+	var entries []string
+	tbl.Ascend("tags", func(item table.IndexItem) bool {
+		entries = append(entries, fmt.Sprintf("%v:%v", item.Key(), item.Value().Key))
+		return true
+	})
+	if got := fmt.Sprint(entries); got != "[a:u:1 b:u:1 b:u:2 c:u:2]" {
+		t.Fatalf("expected an entry per tag got %s", got)
+	}
+}
@@ -12,7 +12,41 @@ import (
 
 var nilMessageType = &MessageType{}
 
+// Every registered version of a single message type keyed by it's FQN.
+// Versions are ordered oldest to newest.
 type MessageVersions struct {
+	FQN      string
+	Versions []*MessageType
+}
+
+// Latest registered version or nil if there are none.
+func (v *MessageVersions) Latest() *MessageType {
+	if v == nil || len(v.Versions) == 0 {
+		return nil
+	}
+	return v.Versions[len(v.Versions)-1]
+}
+
+// Finds a specific version.
+func (v *MessageVersions) Get(version string) *MessageType {
+	if v == nil {
+		return nil
+	}
+	for _, m := range v.Versions {
+		if m.Version == version {
+			return m
+		}
+	}
+	return nil
+}
+
+func (v *MessageVersions) remove(version string) {
+	for i, m := range v.Versions {
+		if m.Version == version {
+			v.Versions = append(v.Versions[:i], v.Versions[i+1:]...)
+			return
+		}
+	}
 }
 
 // Represents a single type of document or "prototype".
@@ -127,7 +161,7 @@ func (p *MessageType) resolve() {
 }
 
 func (p *MessageType) FieldByNumber(number int) *FieldType {
-	if number < 0 || number > len(p.FieldTable) {
+	if number < 0 || number >= len(p.FieldTable) {
 		return nil
 	}
 	return p.FieldTable[number]
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"unsafe"
 
 	"github.com/genzai-io/sliced/app/table"
@@ -263,13 +264,13 @@ func (mt *MessageType) PBUFGet(buf []byte, fields []*FieldType, keys []table.Key
 
 			case 5: // 32-bit
 				index += 4
-				if index >= l {
+				if index > l {
 					return keys, ErrProtobuf32bit
 				}
 
 			case 1: // 64-bit
 				index += 8
-				if index >= l {
+				if index > l {
 					return keys, ErrProtobuf64bit
 				}
 
@@ -287,7 +288,7 @@ func (mt *MessageType) PBUFGet(buf []byte, fields []*FieldType, keys []table.Key
 					key |= (uint64(b) & 0x7F) << shift
 					if b < 0x80 {
 						index += int(key)
-						if index >= l {
+						if index > l {
 							return keys, io.ErrUnexpectedEOF
 						}
 						break
@@ -322,28 +323,18 @@ func (mt *MessageType) PBUFGet(buf []byte, fields []*FieldType, keys []table.Key
 			keys[fieldidx] = pbufVarintToKey(field.WireType, key)
 
 		case 5: // 32-bit
-			index += 4
-			if index >= l {
+			if index+4 > l {
 				return keys, io.ErrUnexpectedEOF
 			}
-			keys[fieldidx] = table.IntKey(uint64(buf[index]) |
-				uint64(buf[index+1])<<8 |
-				uint64(buf[index+2])<<16 |
-				uint64(buf[index+3])<<24)
+			keys[fieldidx] = pbufFixed32ToKey(field.WireType, binary.LittleEndian.Uint32(buf[index:]))
+			index += 4
 
 		case 1: // 64-bit
-			index += 8
-			if index >= l {
+			if index+8 > l {
 				return keys, io.ErrUnexpectedEOF
 			}
-			keys[fieldidx] = table.IntKey(uint64(buf[index]) |
-				uint64(buf[index+1])<<8 |
-				uint64(buf[index+2])<<16 |
-				uint64(buf[index+3])<<24 |
-				uint64(buf[index+4])<<32 |
-				uint64(buf[index+5])<<40 |
-				uint64(buf[index+6])<<48 |
-				uint64(buf[index+7])<<56)
+			keys[fieldidx] = pbufFixed64ToKey(field.WireType, binary.LittleEndian.Uint64(buf[index:]))
+			index += 8
 
 		case 2: // length-delimited
 			key = 0
@@ -359,7 +350,7 @@ func (mt *MessageType) PBUFGet(buf []byte, fields []*FieldType, keys []table.Key
 				key |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					end := int(key) + index
-					if end >= l {
+					if end > l {
 						return keys, io.ErrUnexpectedEOF
 					}
 					keys[fieldidx] = table.StringKey(buf[index:end])
@@ -446,13 +437,13 @@ func (mt *FieldType) PBUFGet(buf []byte) (table.Key, error) {
 
 			case 5: // 32-bit
 				index += 4
-				if index >= l {
+				if index > l {
 					return table.SkipKey, ErrProtobuf32bit
 				}
 
 			case 1: // 64-bit
 				index += 8
-				if index >= l {
+				if index > l {
 					return table.SkipKey, ErrProtobuf64bit
 				}
 
@@ -470,7 +461,7 @@ func (mt *FieldType) PBUFGet(buf []byte) (table.Key, error) {
 					key |= (uint64(b) & 0x7F) << shift
 					if b < 0x80 {
 						index += int(key)
-						if index >= l {
+						if index > l {
 							return table.SkipKey, io.ErrUnexpectedEOF
 						}
 						break
@@ -505,28 +496,16 @@ func (mt *FieldType) PBUFGet(buf []byte) (table.Key, error) {
 			return pbufVarintToKey(mt.WireType, key), nil
 
 		case 5: // 32-bit
-			index += 4
-			if index >= l {
+			if index+4 > l {
 				return table.SkipKey, io.ErrUnexpectedEOF
 			}
-			return table.IntKey(uint64(buf[index]) |
-				uint64(buf[index+1])<<8 |
-				uint64(buf[index+2])<<16 |
-				uint64(buf[index+3])<<24), nil
+			return pbufFixed32ToKey(mt.WireType, binary.LittleEndian.Uint32(buf[index:])), nil
 
 		case 1: // 64-bit
-			index += 8
-			if index >= l {
+			if index+8 > l {
 				return table.SkipKey, io.ErrUnexpectedEOF
 			}
-			return table.IntKey(uint64(buf[index]) |
-				uint64(buf[index+1])<<8 |
-				uint64(buf[index+2])<<16 |
-				uint64(buf[index+3])<<24 |
-				uint64(buf[index+4])<<32 |
-				uint64(buf[index+5])<<40 |
-				uint64(buf[index+6])<<48 |
-				uint64(buf[index+7])<<56), nil
+			return pbufFixed64ToKey(mt.WireType, binary.LittleEndian.Uint64(buf[index:])), nil
 
 		case 2: // length-delimited
 			key = 0
@@ -542,7 +521,7 @@ func (mt *FieldType) PBUFGet(buf []byte) (table.Key, error) {
 				key |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					end := int(key) + index
-					if end >= l {
+					if end > l {
 						return table.SkipKey, io.ErrUnexpectedEOF
 					}
 					return table.StringKey(buf[index:end]), nil
@@ -585,11 +564,7 @@ func pbufVarintToKey(t descriptor.FieldDescriptorProto_Type, raw uint64) table.K
 		// Not ZigZag encoded.  Negative numbers take 10 bytes.  Use TYPE_SINT32 if
 		// negative values are likely.
 	case descriptor.FieldDescriptorProto_TYPE_INT32:
-		x := int64(raw >> 1)
-		if raw&1 != 0 {
-			x = ^x
-		}
-		return table.IntKey(*(*float64)(unsafe.Pointer(&raw)))
+		return table.IntKey(int32(raw))
 
 	case descriptor.FieldDescriptorProto_TYPE_FIXED64:
 		x := int64(raw >> 1)
@@ -627,3 +602,23 @@ func pbufVarintToKey(t descriptor.FieldDescriptorProto_Type, raw uint64) table.K
 
 	return table.SkipKey
 }
+
+func pbufFixed32ToKey(t descriptor.FieldDescriptorProto_Type, raw uint32) table.Key {
+	switch t {
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return table.FloatKey(math.Float32frombits(raw))
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return table.IntKey(int32(raw))
+	}
+	return table.IntKey(raw)
+}
+
+func pbufFixed64ToKey(t descriptor.FieldDescriptorProto_Type, raw uint64) table.Key {
+	switch t {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return table.FloatKey(math.Float64frombits(raw))
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return table.IntKey(int64(raw))
+	}
+	return table.IntKey(raw)
+}
@@ -0,0 +1,410 @@
+package document
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/genzai-io/sliced/common/base58"
+	"github.com/genzai-io/sliced/common/resp"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+)
+
+var (
+	ErrSchemaNotFound  = errors.New("schema not found")
+	ErrSchemaEmpty     = errors.New("schema has no files")
+	ErrSchemaPackage   = errors.New("schema package not set")
+	ErrSchemaDuplicate = errors.New("schema already registered")
+
+	ErrInvalidSchemaSnapshot = errors.New("invalid schema snapshot")
+)
+
+// A single registered version of a FileDescriptorSet for a package path.
+// Versions start at 1 and are assigned in the order they were applied to
+// the cluster log which keeps them consistent on every node.
+type SchemaVersion struct {
+	Package   string
+	Version   int32
+	Hash      string
+	Timestamp time.Time
+
+	// Gzipped FileDescriptorSet
+	Set   []byte
+	Files []*ProtoFile
+}
+
+// Unique messages of the version keyed by FQN.
+func (v *SchemaVersion) messages() map[string]*MessageType {
+	m := make(map[string]*MessageType)
+	for _, f := range v.Files {
+		for key, msg := range f.Messages {
+			if key == msg.FQN {
+				m[key] = msg
+			}
+		}
+	}
+	return m
+}
+
+// Unique enums of the version keyed by FQN.
+func (v *SchemaVersion) enums() map[string]*EnumType {
+	m := make(map[string]*EnumType)
+	for _, f := range v.Files {
+		for key, e := range f.Enums {
+			if key == e.FQN {
+				m[key] = e
+			}
+		}
+	}
+	return m
+}
+
+// FQNs of every message in the version sorted.
+func (v *SchemaVersion) MessageNames() []string {
+	messages := v.messages()
+	names := make([]string, 0, len(messages))
+	for fqn := range messages {
+		names = append(names, fqn)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parses a serialized and possibly gzipped FileDescriptorSet into a
+// SchemaVersion that is not registered yet.
+func newSchemaVersion(pkg string, set []byte, timestamp time.Time) (*SchemaVersion, error) {
+	if pkg == "" {
+		return nil, ErrSchemaPackage
+	}
+
+	b, err := ungzip(set)
+	if err != nil {
+		b = set
+		set, err = gzipBytes(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fds := new(descriptor.FileDescriptorSet)
+	if err := proto.Unmarshal(b, fds); err != nil {
+		return nil, fmt.Errorf("malformed FileDescriptorSet: %v", err)
+	}
+
+	sha := sha256.New()
+	sha.Write(b)
+
+	v := &SchemaVersion{
+		Package:   pkg,
+		Hash:      base58.Encode(sha.Sum(nil)),
+		Timestamp: timestamp,
+		Set:       set,
+	}
+
+	for _, fd := range fds.File {
+		// Dependencies from other packages are allowed to be part of the set
+		if fd.GetPackage() != pkg {
+			continue
+		}
+		v.Files = append(v.Files, NewProtoFile("", "", fd))
+	}
+	if len(v.Files) == 0 {
+		return nil, ErrSchemaEmpty
+	}
+
+	// Resolve message and enum types that are declared in another file of the set
+	messages := v.messages()
+	enums := v.enums()
+	for _, m := range messages {
+		for _, f := range m.Fields {
+			switch f.WireType {
+			case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+				if f.Message == nil {
+					f.Message = messages[f.Descriptor.GetTypeName()]
+				}
+			case descriptor.FieldDescriptorProto_TYPE_ENUM:
+				if f.Enum == nil {
+					f.Enum = enums[f.Descriptor.GetTypeName()]
+				}
+			}
+		}
+	}
+
+	return v, nil
+}
+
+func (v *SchemaVersion) setVersion(version int32) {
+	v.Version = version
+	str := strconv.Itoa(int(version))
+	for _, m := range v.messages() {
+		m.Version = str
+		m.Timestamp = v.Timestamp
+	}
+}
+
+// Validates a new version of a package without registering it.
+func (s *ProtoService) CheckSchema(pkg string, compat Compatibility, set []byte) error {
+	next, err := newSchemaVersion(pkg, set, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+	return s.checkSchema(next, s.compatOf(pkg, compat))
+}
+
+func (s *ProtoService) checkSchema(next *SchemaVersion, compat Compatibility) error {
+	history := s.schemas[next.Package]
+	if len(history) > 0 && history[len(history)-1].Hash == next.Hash {
+		return ErrSchemaDuplicate
+	}
+	return CheckCompatibility(history, next, compat)
+}
+
+// Level a registration is checked against. An explicit level replaces
+// the one of the package.
+func (s *ProtoService) compatOf(pkg string, compat Compatibility) Compatibility {
+	if compat != CompatDefault {
+		return compat
+	}
+	if c, ok := s.compat[pkg]; ok {
+		return c
+	}
+	return CompatBackward
+}
+
+// Compatibility level of a package.
+func (s *ProtoService) Compat(pkg string) Compatibility {
+	s.RLock()
+	defer s.RUnlock()
+	return s.compatOf(pkg, CompatDefault)
+}
+
+// Registers a new version of a package. The level of the package becomes
+// compat unless it is CompatDefault. This must only be invoked from a Raft
+// FSM so every node registers the same versions in the same order.
+func (s *ProtoService) RegisterSchema(pkg string, compat Compatibility, set []byte, timestamp time.Time) (*SchemaVersion, error) {
+	next, err := newSchemaVersion(pkg, set, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	compat = s.compatOf(pkg, compat)
+	if err := s.checkSchema(next, compat); err != nil {
+		return nil, err
+	}
+	s.compat[pkg] = compat
+
+	history := s.schemas[pkg]
+	if len(history) == 0 {
+		next.setVersion(1)
+	} else {
+		next.setVersion(history[len(history)-1].Version + 1)
+	}
+	s.schemas[pkg] = append(history, next)
+
+	for fqn, m := range next.messages() {
+		versions, ok := s.messages[fqn]
+		if !ok {
+			versions = &MessageVersions{FQN: fqn}
+			s.messages[fqn] = versions
+		}
+		versions.Versions = append(versions.Versions, m)
+	}
+
+	return next, nil
+}
+
+// Removes a single version of a package.
+func (s *ProtoService) UnregisterSchema(pkg string, version int32) error {
+	s.Lock()
+	defer s.Unlock()
+
+	history := s.schemas[pkg]
+	for i, v := range history {
+		if v.Version != version {
+			continue
+		}
+
+		str := strconv.Itoa(int(version))
+		for fqn := range v.messages() {
+			if versions, ok := s.messages[fqn]; ok {
+				versions.remove(str)
+				if len(versions.Versions) == 0 {
+					delete(s.messages, fqn)
+				}
+			}
+		}
+
+		history = append(history[:i], history[i+1:]...)
+		if len(history) == 0 {
+			delete(s.schemas, pkg)
+			delete(s.compat, pkg)
+		} else {
+			s.schemas[pkg] = history
+		}
+		return nil
+	}
+	return ErrSchemaNotFound
+}
+
+// Names of every registered package sorted.
+func (s *ProtoService) Schemas() []string {
+	s.RLock()
+	defer s.RUnlock()
+
+	names := make([]string, 0, len(s.schemas))
+	for name := range s.schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Every registered version of a package oldest to newest.
+func (s *ProtoService) SchemaVersions(pkg string) []*SchemaVersion {
+	s.RLock()
+	defer s.RUnlock()
+
+	history := s.schemas[pkg]
+	versions := make([]*SchemaVersion, len(history))
+	copy(versions, history)
+	return versions
+}
+
+// Gets a version of a package. Version 0 is the latest.
+func (s *ProtoService) Schema(pkg string, version int32) (*SchemaVersion, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	history := s.schemas[pkg]
+	if len(history) == 0 {
+		return nil, false
+	}
+	if version == 0 {
+		return history[len(history)-1], true
+	}
+	for _, v := range history {
+		if v.Version == version {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Resolves the latest registered MessageType by FQN.
+func (s *ProtoService) MessageType(fqn string) (*MessageType, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	versions, ok := s.messages[fqn]
+	if !ok {
+		return nil, false
+	}
+	m := versions.Latest()
+	return m, m != nil
+}
+
+// Every version of a MessageType by FQN.
+func (s *ProtoService) MessageVersions(fqn string) (*MessageVersions, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	versions, ok := s.messages[fqn]
+	return versions, ok
+}
+
+// Serializes every registered version as a series of RESP arrays with the
+// level of its package. This is used by Raft snapshots.
+func (s *ProtoService) MarshalSchemas(b []byte) []byte {
+	s.RLock()
+	defer s.RUnlock()
+
+	names := make([]string, 0, len(s.schemas))
+	for name := range s.schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, v := range s.schemas[name] {
+			b = resp.AppendArray(b, 5)
+			b = resp.AppendBulkString(b, v.Package)
+			b = resp.AppendBulkInt32(b, v.Version)
+			b = resp.AppendBulkInt(b, int(s.compatOf(name, CompatDefault)))
+			b = resp.AppendBulkInt64(b, v.Timestamp.UnixNano())
+			b = resp.AppendBulk(b, v.Set)
+		}
+	}
+	return b
+}
+
+// Replaces all registered versions with the contents of MarshalSchemas.
+func (s *ProtoService) UnmarshalSchemas(b []byte) error {
+	schemas := make(map[string][]*SchemaVersion)
+	levels := make(map[string]Compatibility)
+	messages := make(map[string]*MessageVersions)
+
+	var (
+		args     [][]byte
+		complete bool
+		err      error
+	)
+	for len(b) > 0 {
+		_, complete, args, _, b, err = resp.ParseNextCommand(b, args[:0])
+		if err != nil {
+			return err
+		}
+		if !complete || len(args) != 5 {
+			return ErrInvalidSchemaSnapshot
+		}
+
+		version, err := strconv.ParseInt(string(args[1]), 10, 32)
+		if err != nil {
+			return err
+		}
+		compat, err := strconv.Atoi(string(args[2]))
+		if err != nil {
+			return err
+		}
+		ts, err := strconv.ParseInt(string(args[3]), 10, 64)
+		if err != nil {
+			return err
+		}
+
+		set := make([]byte, len(args[4]))
+		copy(set, args[4])
+
+		v, err := newSchemaVersion(string(args[0]), set, time.Unix(0, ts))
+		if err != nil {
+			return err
+		}
+		v.setVersion(int32(version))
+		schemas[v.Package] = append(schemas[v.Package], v)
+		levels[v.Package] = Compatibility(compat)
+
+		for fqn, m := range v.messages() {
+			versions, ok := messages[fqn]
+			if !ok {
+				versions = &MessageVersions{FQN: fqn}
+				messages[fqn] = versions
+			}
+			versions.Versions = append(versions.Versions, m)
+		}
+	}
+
+	s.Lock()
+	s.schemas = schemas
+	s.compat = levels
+	s.messages = messages
+	s.Unlock()
+	return nil
+}
@@ -0,0 +1,115 @@
+package document
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/events"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() {
+	api.RegisterApply(SchemaRegisterName, func() api.ApplyCommand { return &ApplySchemaRegister{} })
+	api.RegisterApply(SchemaUnregisterName, func() api.ApplyCommand { return &ApplySchemaUnregister{} })
+}
+
+const (
+	SchemaRegisterName   = "SCHEMAREG"
+	SchemaUnregisterName = "SCHEMAUNREG"
+)
+
+// Cluster log entry that registers a new version of a schema package.
+// The timestamp is assigned by the proposer so every node agrees on it.
+type ApplySchemaRegister struct {
+	Package   string
+	Compat    Compatibility
+	Timestamp int64
+	Set       []byte
+}
+
+func (c *ApplySchemaRegister) Name() string { return SchemaRegisterName }
+
+func (c *ApplySchemaRegister) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 5)
+	b = resp.AppendBulkString(b, c.Name())
+	b = resp.AppendBulkString(b, c.Package)
+	b = resp.AppendBulkInt(b, int(c.Compat))
+	b = resp.AppendBulkInt64(b, c.Timestamp)
+	b = resp.AppendBulk(b, c.Set)
+	return b
+}
+
+func (c *ApplySchemaRegister) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) != 5 {
+		return api.ErrInvalidParam
+	}
+
+	compat, err := strconv.Atoi(string(args[2]))
+	if err != nil {
+		return err
+	}
+	c.Timestamp, err = strconv.ParseInt(string(args[3]), 10, 64)
+	if err != nil {
+		return err
+	}
+	c.Package = string(args[1])
+	c.Compat = Compatibility(compat)
+	c.Set = append([]byte{}, args[4]...)
+	return nil
+}
+
+func (c *ApplySchemaRegister) Handle() api.CommandReply {
+	v, err := Service.RegisterSchema(c.Package, c.Compat, c.Set, time.Unix(0, c.Timestamp))
+	if err != nil {
+		return api.Err("ERR " + err.Error())
+	}
+	events.Publish(events.SchemaRegistered, c.Package, "version", strconv.Itoa(int(v.Version)))
+	return api.Int(v.Version)
+}
+
+// Cluster log entry that removes a version of a schema package.
+type ApplySchemaUnregister struct {
+	Package string
+	Version int32
+}
+
+func (c *ApplySchemaUnregister) Name() string { return SchemaUnregisterName }
+
+func (c *ApplySchemaUnregister) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 3)
+	b = resp.AppendBulkString(b, c.Name())
+	b = resp.AppendBulkString(b, c.Package)
+	b = resp.AppendBulkInt32(b, c.Version)
+	return b
+}
+
+func (c *ApplySchemaUnregister) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) != 3 {
+		return api.ErrInvalidParam
+	}
+
+	version, err := strconv.ParseInt(string(args[2]), 10, 32)
+	if err != nil {
+		return err
+	}
+	c.Package = string(args[1])
+	c.Version = int32(version)
+	return nil
+}
+
+func (c *ApplySchemaUnregister) Handle() api.CommandReply {
+	if err := Service.UnregisterSchema(c.Package, c.Version); err != nil {
+		return api.Err("ERR " + err.Error())
+	}
+	events.Publish(events.SchemaDeleted, c.Package, "version", strconv.Itoa(int(c.Version)))
+	return api.OK
+}
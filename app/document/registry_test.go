@@ -0,0 +1,126 @@
+package document
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+)
+
+func testField(name string, number int32, t descriptor.FieldDescriptorProto_Type, label descriptor.FieldDescriptorProto_Label) *descriptor.FieldDescriptorProto {
+	return &descriptor.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(number),
+		Type:     t.Enum(),
+		Label:    label.Enum(),
+	}
+}
+
+func testSet(t *testing.T, fields ...*descriptor.FieldDescriptorProto) []byte {
+	set := &descriptor.FileDescriptorSet{
+		File: []*descriptor.FileDescriptorProto{{
+			Name:    proto.String("test/user.proto"),
+			Package: proto.String("test"),
+			Syntax:  proto.String("proto3"),
+			MessageType: []*descriptor.DescriptorProto{{
+				Name:  proto.String("User"),
+				Field: fields,
+			}},
+		}},
+	}
+	b, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestProtoService_RegisterSchema(t *testing.T) {
+	s := NewProtoService()
+
+	v1 := testSet(t,
+		testField("id", 1, descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+		testField("name", 2, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+	)
+	version, err := s.RegisterSchema("test", CompatBackward, v1, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.Version != 1 {
+		t.Fatalf("expected version 1 got %d", version.Version)
+	}
+	if _, err := s.RegisterSchema("test", CompatBackward, v1, time.Now()); err != ErrSchemaDuplicate {
+		t.Fatalf("expected ErrSchemaDuplicate got %v", err)
+	}
+
+	// Adding a field is compatible
+	v2 := testSet(t,
+		testField("id", 1, descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+		testField("name", 2, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+		testField("email", 3, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+	)
+	if version, err = s.RegisterSchema("test", CompatBackward, v2, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if version.Version != 2 {
+		t.Fatalf("expected version 2 got %d", version.Version)
+	}
+
+	// Changing a type is not
+	v3 := testSet(t,
+		testField("id", 1, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+		testField("name", 2, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+		testField("email", 3, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+	)
+	if _, ok := s.CheckSchema("test", CompatBackward, v3).(*CompatibilityError); !ok {
+		t.Fatal("expected type change to be rejected")
+	}
+	// The package keeps its level when none is given
+	if _, ok := s.CheckSchema("test", CompatDefault, v3).(*CompatibilityError); !ok {
+		t.Fatal("expected type change to be rejected by the package level")
+	}
+
+	// Field numbers can't be reused even after removal
+	v4 := testSet(t,
+		testField("id", 1, descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+		testField("nickname", 2, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+	)
+	if _, ok := s.CheckSchema("test", CompatBackward, v4).(*CompatibilityError); !ok {
+		t.Fatal("expected field number reuse to be rejected")
+	}
+	if err := s.CheckSchema("test", CompatNone, v4); err != nil {
+		t.Fatal(err)
+	}
+
+	m, ok := s.MessageType(".test.User")
+	if !ok {
+		t.Fatal("expected .test.User")
+	}
+	if m.Version != "2" || len(m.Fields) != 3 {
+		t.Fatalf("expected latest version 2 with 3 fields got %s with %d", m.Version, len(m.Fields))
+	}
+}
+
+func TestProtoService_MarshalSchemas(t *testing.T) {
+	s := NewProtoService()
+	set := testSet(t,
+		testField("id", 1, descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+	)
+	if _, err := s.RegisterSchema("test", CompatFull, set, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewProtoService()
+	if err := restored.UnmarshalSchemas(s.MarshalSchemas(nil)); err != nil {
+		t.Fatal(err)
+	}
+	v, ok := restored.Schema("test", 0)
+	if !ok {
+		t.Fatal("expected schema 'test'")
+	}
+	if v.Version != 1 || restored.Compat("test") != CompatFull {
+		t.Fatalf("expected version 1 FULL got %d %s", v.Version, restored.Compat("test"))
+	}
+}
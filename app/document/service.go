@@ -30,12 +30,22 @@ type ProtoService struct {
 	files map[string]*ProtoFile
 
 	files2 *spmap.Map
+
+	// Registered schema versions by package path
+	schemas map[string][]*SchemaVersion
+	// Compatibility level by package path
+	compat map[string]Compatibility
+	// Registered message versions by FQN
+	messages map[string]*MessageVersions
 }
 
 func NewProtoService() *ProtoService {
 	s := &ProtoService{
-		files:  make(map[string]*ProtoFile),
-		files2: spmap.New(&spmap.Options{}),
+		files:    make(map[string]*ProtoFile),
+		files2:   spmap.New(&spmap.Options{}),
+		schemas:  make(map[string][]*SchemaVersion),
+		compat:   make(map[string]Compatibility),
+		messages: make(map[string]*MessageVersions),
 	}
 
 	s.BaseService = *service.NewBaseService(moved.Logger, "proto", Service)
@@ -98,16 +108,15 @@ func gzipBytes(b []byte) ([]byte, error) {
 	buffer := &bytes.Buffer{}
 	w := gzip.NewWriter(buffer)
 
-	defer w.Close()
-
 	_, err := w.Write(b)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create gzip writer: %v", err)
 	}
 
-	err = w.Flush()
+	// Close writes the gzip footer
+	err = w.Close()
 	if err != nil {
-		return nil, fmt.Errorf("failed to flush gzip writer: %v", err)
+		return nil, fmt.Errorf("failed to close gzip writer: %v", err)
 	}
 
 	return buffer.Bytes(), nil
@@ -0,0 +1,347 @@
+package document
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/genzai-io/sliced/proto/store"
+	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+	"github.com/valyala/fastjson"
+)
+
+var (
+	ErrCodecNotSupported = errors.New("codec not supported for validation")
+)
+
+// Returned when a record does not conform to it's MessageType.
+// Path is the dot separated path of the offending field.
+type ValidationError struct {
+	Message string
+	Path    string
+	Reason  string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("invalid %s record: %s", e.Message, e.Reason)
+	}
+	return fmt.Sprintf("invalid %s record: field '%s' %s", e.Message, e.Path, e.Reason)
+}
+
+func fieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// Validates a record encoded with the codec.
+func (mt *MessageType) Validate(codec store.Codec, record []byte) error {
+	switch codec {
+	case store.Codec_PROTOBUF:
+		return mt.ValidatePBUF(record)
+	case store.Codec_JSON:
+		return mt.ValidateJSON(record)
+	}
+	return ErrCodecNotSupported
+}
+
+// Validates a protobuf serialized record. Unknown field numbers, wire types
+// that do not match the field type, malformed nested messages, invalid UTF-8
+// strings and missing required fields are reported.
+func (mt *MessageType) ValidatePBUF(buf []byte) error {
+	if err := mt.validatePBUF(buf, ""); err != nil {
+		err.Message = mt.FQN
+		return err
+	}
+	return nil
+}
+
+func (mt *MessageType) validatePBUF(buf []byte, path string) *ValidationError {
+	var (
+		key  uint64
+		v    uint64
+		n    int
+		wire int
+		seen map[int32]bool
+	)
+
+	for len(buf) > 0 {
+		key, n = binary.Uvarint(buf)
+		if n <= 0 {
+			return &ValidationError{Path: path, Reason: ErrProtobufField.Error()}
+		}
+		buf = buf[n:]
+		wire = int(key & 7)
+
+		field := mt.FieldByNumber(int(key >> 3))
+		if field == nil {
+			return &ValidationError{Path: path, Reason: fmt.Sprintf("unknown field number %d", key>>3)}
+		}
+		p := fieldPath(path, field.Name)
+
+		if seen == nil {
+			seen = make(map[int32]bool)
+		}
+		seen[field.Number] = true
+
+		expected := pbufWireType(field.WireType)
+		packed := wire == 2 && expected != 2 && field.Descriptor.IsRepeated()
+		if wire != expected && !packed {
+			return &ValidationError{Path: p, Reason: fmt.Sprintf("wire type %d does not match %s", wire, fieldTypeName(field))}
+		}
+
+		switch wire {
+		case 0:
+			_, n = binary.Uvarint(buf)
+			if n <= 0 {
+				return &ValidationError{Path: p, Reason: ErrProtobufVarint.Error()}
+			}
+			buf = buf[n:]
+
+		case 5:
+			if len(buf) < 4 {
+				return &ValidationError{Path: p, Reason: ErrProtobuf32bit.Error()}
+			}
+			buf = buf[4:]
+
+		case 1:
+			if len(buf) < 8 {
+				return &ValidationError{Path: p, Reason: ErrProtobuf64bit.Error()}
+			}
+			buf = buf[8:]
+
+		case 2:
+			v, n = binary.Uvarint(buf)
+			if n <= 0 || v > uint64(len(buf)-n) {
+				return &ValidationError{Path: p, Reason: ErrProtobufLength.Error()}
+			}
+			vb := buf[n : n+int(v)]
+			buf = buf[n+int(v):]
+
+			switch {
+			case packed:
+				if err := validatePacked(vb, expected); err != "" {
+					return &ValidationError{Path: p, Reason: err}
+				}
+			case field.WireType == descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+				if field.Message == nil {
+					return &ValidationError{Path: p, Reason: "message type " + field.Descriptor.GetTypeName() + " not resolved"}
+				}
+				if err := field.Message.validatePBUF(vb, p); err != nil {
+					return err
+				}
+			case field.WireType == descriptor.FieldDescriptorProto_TYPE_STRING:
+				if !utf8.Valid(vb) {
+					return &ValidationError{Path: p, Reason: "is not valid UTF-8"}
+				}
+			}
+
+		default:
+			return &ValidationError{Path: p, Reason: ErrProtobufWireType.Error()}
+		}
+	}
+
+	return mt.validateRequired(path, func(f *FieldType) bool { return seen[f.Number] })
+}
+
+func (mt *MessageType) validateRequired(path string, present func(f *FieldType) bool) *ValidationError {
+	for _, f := range mt.Fields {
+		if f.Descriptor.IsRequired() && !present(f) {
+			return &ValidationError{Path: fieldPath(path, f.Name), Reason: "is required"}
+		}
+	}
+	return nil
+}
+
+func validatePacked(buf []byte, wire int) string {
+	for len(buf) > 0 {
+		switch wire {
+		case 0:
+			_, n := binary.Uvarint(buf)
+			if n <= 0 {
+				return ErrProtobufVarint.Error()
+			}
+			buf = buf[n:]
+		case 5:
+			if len(buf) < 4 {
+				return ErrProtobuf32bit.Error()
+			}
+			buf = buf[4:]
+		case 1:
+			if len(buf) < 8 {
+				return ErrProtobuf64bit.Error()
+			}
+			buf = buf[8:]
+		}
+	}
+	return ""
+}
+
+// Protobuf wire type of a field type.
+func pbufWireType(t descriptor.FieldDescriptorProto_Type) int {
+	switch t {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return 1
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return 5
+	case descriptor.FieldDescriptorProto_TYPE_STRING,
+		descriptor.FieldDescriptorProto_TYPE_BYTES,
+		descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		return 2
+	case descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return 3
+	}
+	return 0
+}
+
+// Validates a JSON record using the proto3 JSON mapping. Fields may be named
+// by either their JSON name or their proto name.
+func (mt *MessageType) ValidateJSON(buf []byte) error {
+	var parser fastjson.Parser
+	value, err := parser.ParseBytes(buf)
+	if err != nil {
+		return &ValidationError{Message: mt.FQN, Reason: err.Error()}
+	}
+	if verr := mt.validateJSON(value, ""); verr != nil {
+		verr.Message = mt.FQN
+		return verr
+	}
+	return nil
+}
+
+func (mt *MessageType) validateJSON(value *fastjson.Value, path string) *ValidationError {
+	object, err := value.Object()
+	if err != nil {
+		return &ValidationError{Path: path, Reason: "expected object got " + value.Type().String()}
+	}
+
+	var (
+		verr *ValidationError
+		seen = make(map[int32]bool)
+	)
+	object.Visit(func(key []byte, v *fastjson.Value) {
+		if verr != nil {
+			return
+		}
+		field := mt.fieldByJSONName(string(key))
+		if field == nil {
+			verr = &ValidationError{Path: fieldPath(path, string(key)), Reason: "is not a known field"}
+			return
+		}
+		seen[field.Number] = true
+
+		p := fieldPath(path, field.Name)
+		if v.Type() == fastjson.TypeNull {
+			return
+		}
+		if field.isMap() {
+			entries, err := v.Object()
+			if err != nil {
+				verr = &ValidationError{Path: p, Reason: "expected object got " + v.Type().String()}
+				return
+			}
+			value := field.Message.FieldByNumber(2)
+			entries.Visit(func(key []byte, item *fastjson.Value) {
+				if verr == nil && value != nil && item.Type() != fastjson.TypeNull {
+					verr = value.validateJSON(item, fieldPath(p, string(key)))
+				}
+			})
+			return
+		}
+		if field.Descriptor.IsRepeated() {
+			items, err := v.Array()
+			if err != nil {
+				verr = &ValidationError{Path: p, Reason: "expected array got " + v.Type().String()}
+				return
+			}
+			for _, item := range items {
+				if verr = field.validateJSON(item, p); verr != nil {
+					return
+				}
+			}
+			return
+		}
+		verr = field.validateJSON(v, p)
+	})
+	if verr != nil {
+		return verr
+	}
+
+	return mt.validateRequired(path, func(f *FieldType) bool { return seen[f.Number] })
+}
+
+func (mt *MessageType) fieldByJSONName(name string) *FieldType {
+	for _, f := range mt.Fields {
+		if f.JsonName == name || f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (f *FieldType) isMap() bool {
+	return f.Message != nil && f.Message.Descriptor.GetOptions().GetMapEntry()
+}
+
+func (f *FieldType) validateJSON(v *fastjson.Value, path string) *ValidationError {
+	t := v.Type()
+	mismatch := func() *ValidationError {
+		return &ValidationError{Path: path, Reason: fmt.Sprintf("expected %s got %s", fieldTypeName(f), t)}
+	}
+
+	switch f.WireType {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if f.Message == nil {
+			return &ValidationError{Path: path, Reason: "message type " + f.Descriptor.GetTypeName() + " not resolved"}
+		}
+		return f.Message.validateJSON(v, path)
+
+	case descriptor.FieldDescriptorProto_TYPE_STRING,
+		descriptor.FieldDescriptorProto_TYPE_BYTES:
+		if t != fastjson.TypeString {
+			return mismatch()
+		}
+
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		if t != fastjson.TypeTrue && t != fastjson.TypeFalse {
+			return mismatch()
+		}
+
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		switch t {
+		case fastjson.TypeNumber:
+		case fastjson.TypeString:
+			if f.Enum != nil {
+				name, _ := v.StringBytes()
+				if !f.Enum.hasValue(string(name)) {
+					return &ValidationError{Path: path, Reason: fmt.Sprintf("unknown enum value '%s'", name)}
+				}
+			}
+		default:
+			return mismatch()
+		}
+
+	default:
+		// 64-bit integers and special floats may be encoded as strings
+		if t != fastjson.TypeNumber && t != fastjson.TypeString {
+			return mismatch()
+		}
+	}
+	return nil
+}
+
+func (e *EnumType) hasValue(name string) bool {
+	for _, v := range e.Values {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
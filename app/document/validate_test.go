@@ -0,0 +1,73 @@
+package document
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+)
+
+func createUserType(t *testing.T) *MessageType {
+	s := NewProtoService()
+	set := testSet(t,
+		testField("id", 1, descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+		testField("name", 2, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+		testField("tags", 3, descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_LABEL_REPEATED),
+	)
+	if _, err := s.RegisterSchema("test", CompatNone, set, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	mt, ok := s.MessageType(".test.User")
+	if !ok {
+		t.Fatal("expected .test.User")
+	}
+	return mt
+}
+
+func TestMessageType_ValidatePBUF(t *testing.T) {
+	mt := createUserType(t)
+
+	// id = 150, name = "bob"
+	valid := []byte{0x08, 0x96, 0x01, 0x12, 0x03, 'b', 'o', 'b'}
+	if err := mt.ValidatePBUF(valid); err != nil {
+		t.Fatal(err)
+	}
+
+	// name encoded as a varint
+	err := mt.ValidatePBUF([]byte{0x10, 0x01})
+	if verr, ok := err.(*ValidationError); !ok || verr.Path != "name" {
+		t.Fatalf("expected error on 'name' got %v", err)
+	}
+
+	// unknown field 9
+	if _, ok := mt.ValidatePBUF([]byte{0x48, 0x01}).(*ValidationError); !ok {
+		t.Fatal("expected unknown field to be rejected")
+	}
+
+	// truncated string
+	if _, ok := mt.ValidatePBUF([]byte{0x12, 0x05, 'b'}).(*ValidationError); !ok {
+		t.Fatal("expected truncated field to be rejected")
+	}
+}
+
+func TestMessageType_ValidateJSON(t *testing.T) {
+	mt := createUserType(t)
+
+	if err := mt.ValidateJSON([]byte(`{"id":"150","name":"bob","tags":["a","b"]}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	err := mt.ValidateJSON([]byte(`{"id":1,"tags":["a",2]}`))
+	if verr, ok := err.(*ValidationError); !ok || verr.Path != "tags" {
+		t.Fatalf("expected error on 'tags' got %v", err)
+	}
+
+	err = mt.ValidateJSON([]byte(`{"id":1,"email":"bob@example.com"}`))
+	if verr, ok := err.(*ValidationError); !ok || verr.Path != "email" {
+		t.Fatalf("expected error on 'email' got %v", err)
+	}
+
+	if _, ok := mt.ValidateJSON([]byte(`[1,2]`)).(*ValidationError); !ok {
+		t.Fatal("expected non-object record to be rejected")
+	}
+}
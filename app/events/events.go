@@ -0,0 +1,256 @@
+package events
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/genzai-io/sliced/common/match"
+)
+
+var (
+	ErrTruncated = errors.New("events from the requested sequence are no longer retained")
+	ErrSlow      = errors.New("subscriber fell behind")
+)
+
+// Most events the default bus keeps for subscribers that resume.
+var HistorySize = 1024
+
+// Global bus of the events seen by this node.
+var Default = NewBus(HistorySize)
+
+// Kind mirrors api_pb.EventKind.
+type Kind int32
+
+const (
+	Unknown Kind = iota
+	LeaderChanged
+	NodeJoined
+	NodeLeft
+	DriveAdded
+	DriveRemoved
+	_ // Reserved
+	TopicCreated
+	SchemaRegistered
+	SchemaDeleted
+	_ // Reserved
+	DriveFull
+	SegmentMigrated
+	StoreReadOnly
+	StoreWritable
+)
+
+var kindNames = [...]string{
+	Unknown:          "unknown",
+	LeaderChanged:    "leader-changed",
+	NodeJoined:       "node-joined",
+	NodeLeft:         "node-left",
+	DriveAdded:       "drive-added",
+	DriveRemoved:     "drive-removed",
+	TopicCreated:     "topic-created",
+	SchemaRegistered: "schema-registered",
+	SchemaDeleted:    "schema-deleted",
+	DriveFull:        "drive-full",
+	SegmentMigrated:  "segment-migrated",
+	StoreReadOnly:    "store-read-only",
+	StoreWritable:    "store-writable",
+}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) || kindNames[k] == "" {
+		return kindNames[Unknown]
+	}
+	return kindNames[k]
+}
+
+type Attr struct {
+	Key   string
+	Value string
+}
+
+type Event struct {
+	Epoch   uint64
+	Seq     uint64
+	Time    time.Time
+	Kind    Kind
+	Subject string
+	Attrs   []Attr
+}
+
+// Selects events by kind and subject. The zero value matches every event.
+type Filter struct {
+	Kinds   []Kind
+	Subject string // Glob pattern
+}
+
+func (f Filter) Match(e *Event) bool {
+	if len(f.Kinds) > 0 {
+		found := false
+		for _, kind := range f.Kinds {
+			if kind == e.Kind {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return f.Subject == "" || match.Match(e.Subject, f.Subject)
+}
+
+// Delivers events to a single subscriber. C is closed when the
+// subscription ends and Err tells why.
+type Subscription struct {
+	C <-chan *Event
+
+	bus    *Bus
+	ch     chan *Event
+	filter Filter
+	err    error
+}
+
+// Ends the subscription.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	s.bus.remove(s, nil)
+	s.bus.mu.Unlock()
+}
+
+// ErrSlow when the subscriber could not keep up, otherwise nil.
+func (s *Subscription) Err() error {
+	s.bus.mu.Lock()
+	err := s.err
+	s.bus.mu.Unlock()
+	return err
+}
+
+// Numbers events and fans them out to subscribers. The latest events are
+// kept so a subscriber can resume from the last sequence it saw. Sequences
+// restart when the node does so each bus has its own epoch.
+type Bus struct {
+	mu      sync.Mutex
+	epoch   uint64
+	seq     uint64
+	history []*Event // Ring of the latest events
+	next    int      // Ring position of the next event
+	subs    map[*Subscription]struct{}
+}
+
+func NewBus(history int) *Bus {
+	if history < 1 {
+		history = 1
+	}
+	return &Bus{
+		epoch:   uint64(time.Now().UnixNano()),
+		history: make([]*Event, 0, history),
+		subs:    make(map[*Subscription]struct{}),
+	}
+}
+
+// Epoch of the sequences. It's set when the bus is created.
+func (b *Bus) Epoch() uint64 {
+	return b.epoch
+}
+
+// Sequence of the latest event.
+func (b *Bus) Seq() uint64 {
+	b.mu.Lock()
+	seq := b.seq
+	b.mu.Unlock()
+	return seq
+}
+
+// Publishes an event. Attributes are given as key value pairs.
+func (b *Bus) Publish(kind Kind, subject string, attrs ...string) *Event {
+	e := &Event{
+		Time:    time.Now(),
+		Kind:    kind,
+		Subject: subject,
+	}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		e.Attrs = append(e.Attrs, Attr{Key: attrs[i], Value: attrs[i+1]})
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	e.Epoch = b.epoch
+	e.Seq = b.seq
+	if len(b.history) < cap(b.history) {
+		b.history = append(b.history, e)
+	} else {
+		b.history[b.next] = e
+	}
+	b.next = (b.next + 1) % cap(b.history)
+
+	for s := range b.subs {
+		if !s.filter.Match(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			b.remove(s, ErrSlow)
+		}
+	}
+	return e
+}
+
+// Subscribes to the events matching the filter. Retained events from
+// fromSeq of the epoch on are delivered first and zero only delivers new
+// events. A sequence of another epoch gives ErrTruncated since the events
+// it follows are gone. Subscribers that have more than buffer events
+// waiting are dropped.
+func (b *Bus) Subscribe(epoch, fromSeq uint64, filter Filter, buffer int) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if fromSeq > 0 && epoch != b.epoch {
+		return nil, ErrTruncated
+	}
+
+	var replay []*Event
+	if fromSeq > 0 && fromSeq <= b.seq {
+		oldest := b.seq - uint64(len(b.history)) + 1
+		if fromSeq < oldest {
+			return nil, ErrTruncated
+		}
+		for i := 0; i < len(b.history); i++ {
+			e := b.history[(b.next+i)%len(b.history)]
+			if e.Seq >= fromSeq && filter.Match(e) {
+				replay = append(replay, e)
+			}
+		}
+	}
+
+	if buffer < len(replay) {
+		buffer = len(replay)
+	}
+	s := &Subscription{
+		bus:    b,
+		ch:     make(chan *Event, buffer),
+		filter: filter,
+	}
+	s.C = s.ch
+	for _, e := range replay {
+		s.ch <- e
+	}
+	b.subs[s] = struct{}{}
+	return s, nil
+}
+
+func (b *Bus) remove(s *Subscription, err error) {
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+	delete(b.subs, s)
+	s.err = err
+	close(s.ch)
+}
+
+// Publishes to the default bus.
+func Publish(kind Kind, subject string, attrs ...string) *Event {
+	return Default.Publish(kind, subject, attrs...)
+}
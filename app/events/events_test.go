@@ -0,0 +1,124 @@
+package events
+
+import (
+	"testing"
+)
+
+func receive(t *testing.T, s *Subscription) *Event {
+	select {
+	case e, ok := <-s.C:
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return e
+	default:
+		t.Fatal("expected an event")
+	}
+	return nil
+}
+
+func TestFilter(t *testing.T) {
+	e := &Event{Kind: DriveAdded, Subject: "/mnt/disk1"}
+	for _, f := range []Filter{
+		{},
+		{Kinds: []Kind{DriveAdded, DriveRemoved}},
+		{Subject: "/mnt/*"},
+		{Kinds: []Kind{DriveAdded}, Subject: "/mnt/disk?"},
+	} {
+		if !f.Match(e) {
+			t.Fatalf("expected %v to match", f)
+		}
+	}
+	for _, f := range []Filter{
+		{Kinds: []Kind{NodeJoined}},
+		{Subject: "/var/*"},
+		{Kinds: []Kind{DriveAdded}, Subject: "node*"},
+	} {
+		if f.Match(e) {
+			t.Fatalf("expected %v not to match", f)
+		}
+	}
+}
+
+func TestBusResume(t *testing.T) {
+	bus := NewBus(4)
+	for i := 0; i < 6; i++ {
+		bus.Publish(NodeJoined, "node", "index", string('0'+rune(i)))
+	}
+	if bus.Seq() != 6 {
+		t.Fatalf("expected sequence 6 got %d", bus.Seq())
+	}
+
+	if _, err := bus.Subscribe(bus.Epoch(), 2, Filter{}, 8); err != ErrTruncated {
+		t.Fatalf("expected ErrTruncated got %v", err)
+	}
+
+	s, err := bus.Subscribe(bus.Epoch(), 4, Filter{}, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for seq := uint64(4); seq <= 6; seq++ {
+		if e := receive(t, s); e.Seq != seq || e.Epoch != bus.Epoch() {
+			t.Fatalf("expected sequence %d got %d", seq, e.Seq)
+		}
+	}
+
+	bus.Publish(NodeLeft, "node")
+	if e := receive(t, s); e.Seq != 7 || e.Kind != NodeLeft {
+		t.Fatalf("unexpected event %v", e)
+	}
+
+	// New events only
+	s, err = bus.Subscribe(0, 0, Filter{Kinds: []Kind{LeaderChanged}}, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	bus.Publish(NodeLeft, "node")
+	bus.Publish(LeaderChanged, "node", "term", "2")
+	if e := receive(t, s); e.Kind != LeaderChanged || e.Attrs[0] != (Attr{"term", "2"}) {
+		t.Fatalf("unexpected event %v", e)
+	}
+}
+
+func TestBusRestart(t *testing.T) {
+	before := NewBus(4)
+	before.Publish(NodeJoined, "node")
+	before.Publish(NodeJoined, "node")
+
+	// Sequences restart with the node so the old epoch can't resume
+	bus := NewBus(4)
+	for bus.Epoch() == before.Epoch() {
+		bus = NewBus(4)
+	}
+	bus.Publish(NodeLeft, "node")
+	bus.Publish(NodeLeft, "node")
+	bus.Publish(NodeLeft, "node")
+	if _, err := bus.Subscribe(before.Epoch(), 2, Filter{}, 8); err != ErrTruncated {
+		t.Fatalf("expected ErrTruncated got %v", err)
+	}
+	if _, err := bus.Subscribe(0, 2, Filter{}, 8); err != ErrTruncated {
+		t.Fatalf("expected ErrTruncated got %v", err)
+	}
+}
+
+func TestBusSlowSubscriber(t *testing.T) {
+	bus := NewBus(16)
+	s, err := bus.Subscribe(0, 0, Filter{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		bus.Publish(DriveAdded, "/mnt/disk")
+	}
+
+	n := 0
+	for range s.C {
+		n++
+	}
+	if n != 2 || s.Err() != ErrSlow {
+		t.Fatalf("expected 2 events and ErrSlow got %d %v", n, s.Err())
+	}
+	s.Close()
+}
@@ -0,0 +1,30 @@
+package fs
+
+import (
+	"strconv"
+
+	"github.com/genzai-io/sliced/app/backup"
+)
+
+// Lists the segments on every drive. Segments that are still written to are
+// backed up as far as they were written when listed and get a new key every
+// time they grow.
+func (d *DriveService) backupSegments() ([]backup.File, error) {
+	var files []backup.File
+	for _, f := range d.segments() {
+		if f.writer == nil {
+			files = append(files, backup.File{Key: backup.Name(f.path), Path: f.path})
+			continue
+		}
+		size := f.writer.Size()
+		if size == 0 {
+			continue
+		}
+		files = append(files, backup.File{
+			Key:  backup.Name(f.path) + "@" + strconv.FormatInt(size, 10),
+			Path: f.path,
+			Size: size,
+		})
+	}
+	return files, nil
+}
@@ -3,14 +3,40 @@ package fs
 import (
 	"context"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/genzai-io/sliced"
-	store_pb "github.com/genzai-io/sliced/proto/store"
+	"github.com/genzai-io/sliced/app/mmap"
+	"github.com/genzai-io/sliced/common/diskio"
+	"github.com/genzai-io/sliced/common/metrics"
 	"github.com/genzai-io/sliced/common/service"
+	store_pb "github.com/genzai-io/sliced/proto/store"
+)
+
+// Suffix of segment files that are allocated ahead of their writer.
+const preallocSuffix = ".prealloc"
+
+var (
+	// Nanoseconds between syncs of the segments of BUSINESS topics. It's
+	// read and changed with SyncInterval and SetSyncInterval.
+	syncInterval = int64(time.Second)
+
+	syncTimer     = metrics.GetOrRegisterTimer("fs.sync", moved.Metrics)
+	syncErrors    = metrics.GetOrRegisterCounter("fs.sync.errors", moved.Metrics)
+	growTimer     = metrics.GetOrRegisterTimer("fs.grow", moved.Metrics)
+	preallocTimer = metrics.GetOrRegisterTimer("fs.prealloc", moved.Metrics)
 )
 
+type preallocation struct {
+	name string
+	size int64
+}
+
 type Drive struct {
 	service.BaseService
 
@@ -29,9 +55,15 @@ type Drive struct {
 	muMap       sync.Mutex
 	wg          sync.WaitGroup
 
+	// Held for writing while writes are paused for a snapshot
+	muQuiesce sync.RWMutex
+
 	// Channels
 	chTruncate chan *SegmentWriter
-	chClose    chan *SegmentWriter
+	chPrealloc chan preallocation
+
+	// Open writers protected by muSync
+	writers map[*SegmentWriter]struct{}
 
 	// Memory metrics
 	mapped      int64 // Number of bytes memory-mapped
@@ -56,6 +88,12 @@ type Drive struct {
 
 	// Counter of bytes written
 	written int64
+
+	// Set once the drive is within BufferSpace of full
+	full int32
+
+	// Read-only while writes fail because the disk is full or failing
+	guard *diskio.Guard
 }
 
 func newDrive(model store_pb.Drive) *Drive {
@@ -65,8 +103,10 @@ func newDrive(model store_pb.Drive) *Drive {
 		model:      model,
 		ctx:        ctx,
 		cancel:     cancel,
-		//chTruncate: make(chan *SegmentWriter, 16),
-		//chClose:    make(chan *SegmentWriter, 16),
+		chTruncate: make(chan *SegmentWriter, 16),
+		chPrealloc: make(chan preallocation, 16),
+		writers:    make(map[*SegmentWriter]struct{}),
+		guard:      diskio.NewGuard(model.Mount),
 	}
 
 	p.BaseService = *service.NewBaseService(moved.Logger, "drive", p)
@@ -87,6 +127,13 @@ func (p *Drive) OnStart() error {
 func (p *Drive) OnStop() {
 	p.cancel()
 	p.wg.Wait()
+	p.guard.Close()
+}
+
+// A *diskio.ReadOnlyError while writes to the drive failed because it is
+// full or failing and nil otherwise.
+func (p *Drive) ReadOnly() error {
+	return p.guard.Err()
 }
 
 func (d *Drive) Statfs() (*store_pb.DriveStats, error) {
@@ -101,27 +148,93 @@ func (d *Drive) Statfs() (*store_pb.DriveStats, error) {
 	return info, nil
 }
 
-func (p *Drive) Create(name string, expectedSize int64, mode os.FileMode) (*SegmentWriter, error) {
-	var file *SegmentWriter
-	var err error
+// Last known usage of the drive.
+func (d *Drive) stats() store_pb.DriveStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.model.Stats == nil {
+		return store_pb.DriveStats{}
+	}
+	return *d.model.Stats
+}
+
+// Bytes free above BufferSpace once size more bytes are written.
+func (d *Drive) headroom(size uint64) (uint64, bool) {
+	if d.isFull() {
+		return 0, false
+	}
+	need := size + uint64(BufferSpace)
+	stats := d.stats()
+	if stats.Avail <= need {
+		return 0, false
+	}
+	return stats.Avail - need, true
+}
+
+// Number of segments being written to on the drive.
+func (d *Drive) load() int {
+	d.muSync.Lock()
+	defer d.muSync.Unlock()
+	return len(d.writers)
+}
+
+func (d *Drive) isFull() bool {
+	return atomic.LoadInt32(&d.full) == 1
+}
+
+func (d *Drive) setFull(full bool) {
+	if full {
+		atomic.StoreInt32(&d.full, 1)
+	} else {
+		atomic.StoreInt32(&d.full, 0)
+	}
+}
+
+// Creates a segment writer that is synced by the drive according to the
+// durability level of its topic. A file allocated ahead of the writer is
+// used when there is one and the next segment is allocated in the background.
+func (p *Drive) Create(name string, expectedSize int64, mode os.FileMode, level store_pb.Level) (*SegmentWriter, error) {
+	if err := p.guard.Err(); err != nil {
+		return nil, err
+	}
+	if p.isFull() {
+		return nil, ErrOutOfDiskSpace
+	}
+	if _, err := os.Stat(name); os.IsNotExist(err) {
+		os.Rename(name+preallocSuffix, name)
+	}
+
+	file, err := createSegmentWriter(p, name, expectedSize, mode)
+	if err != nil {
+		return nil, p.guard.Check(err)
+	}
+	file.level = level
 
-	//p.muMap.Lock()
-	//existing, ok := p.writeMap[name]
-	//if ok && existing != nil {
-	//	file = existing
-	//} else {
-	//	file, err = open(p, name, expectedSize, mode)
-	//	if err != nil {
-	//		p.muMap.Unlock()
-	//		return nil, err
-	//	}
-	//
-	//	//file.truncate(0)
-	//	p.writeMap[name] = file
-	//}
-	//p.muMap.Unlock()
+	p.muSync.Lock()
+	p.writers[file] = struct{}{}
+	p.muSync.Unlock()
 
-	return file, err
+	if next := nextSegmentName(name); next != "" {
+		select {
+		case p.chPrealloc <- preallocation{name: next + preallocSuffix, size: file.filesz}:
+		default:
+		}
+	}
+	return file, nil
+}
+
+// Name of the segment after the segment at name or "" when name is not a
+// segment.
+func nextSegmentName(name string) string {
+	base := filepath.Base(name)
+	if !strings.HasSuffix(base, ".s") {
+		return ""
+	}
+	id, err := strconv.ParseInt(strings.TrimSuffix(base, ".s"), 10, 64)
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(name), strconv.FormatInt(id+1, 10)+".s")
 }
 
 func (p *Drive) Read(name string, mode os.FileMode) {
@@ -129,63 +242,128 @@ func (p *Drive) Read(name string, mode os.FileMode) {
 }
 
 func (p *Drive) close(file *SegmentWriter) {
-
+	p.muSync.Lock()
+	delete(p.writers, file)
+	p.muSync.Unlock()
 }
 
+// Grows writers ahead of their writes and allocates the next segments so
+// writers rarely wait on the file system.
 func (p *Drive) allocator() {
 	defer p.wg.Done()
 
-//loop:
-//	for {
-//		select {
-//		// Listen for cancel
-//		case <-p.ctx.Done():
-//			break loop
-//
-//		case file, ok := <-p.chTruncate:
-//			if ok && file != nil {
-//				if err := file.Truncate(0); err != nil {
-//					p.Logger.Error().Msgf("%s truncate() error", file.name)
-//					p.Logger.Error().Err(err)
-//				}
-//			}
-//		}
-//	}
+	for {
+		select {
+		// Listen for cancel
+		case <-p.ctx.Done():
+			return
+
+		case file := <-p.chTruncate:
+			start := time.Now()
+			if err := file.Truncate(0); err != nil {
+				p.Logger.Error().Err(err).Msgf("%s truncate() error", file.name)
+			}
+			growTimer.UpdateSince(start)
+
+		case next := <-p.chPrealloc:
+			if p.isFull() {
+				continue
+			}
+			start := time.Now()
+			if err := preallocate(next.name, next.size); err != nil && !os.IsExist(err) {
+				p.Logger.Warn().Err(err).Msgf("%s preallocate error", next.name)
+				continue
+			}
+			preallocTimer.UpdateSince(start)
+		}
+	}
+}
+
+func preallocate(name string, size int64) error {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if err := mmap.Fallocate(f, size); err != nil {
+		f.Close()
+		os.Remove(name)
+		return err
+	}
+	return f.Close()
+}
+
+// Interval the workers sync the segments of BUSINESS topics at. Segments
+// of MISSION topics are synced on every write and BACKGROUND topics are left
+// to the OS.
+func SyncInterval() time.Duration {
+	return time.Duration(atomic.LoadInt64(&syncInterval))
+}
+
+// Changes the interval between syncs while the drives run.
+func SetSyncInterval(interval time.Duration) {
+	atomic.StoreInt64(&syncInterval, int64(interval))
 }
 
+// Syncs every open writer whatever the level of its topic.
+func (p *Drive) syncWriters() error {
+	p.muSync.Lock()
+	set := make([]*SegmentWriter, 0, len(p.writers))
+	for writer := range p.writers {
+		set = append(set, writer)
+	}
+	p.muSync.Unlock()
+
+	for _, file := range set {
+		if err := file.sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Syncs the writers of BUSINESS topics every sync interval of their topic
+// or SyncInterval when the topic has none. The ticker follows the shortest
+// interval so a change to SyncInterval applies from the next sync.
 func (p *Drive) worker() {
 	defer p.wg.Done()
 
-//	set := make([]*SegmentWriter, 0, 256)
-//loop:
-//	for {
-//		select {
-//		// Listen for cancel
-//		case <-p.ctx.Done():
-//			break loop
-//
-//			// Sync to disk every second
-//		case <-time.After(time.Second):
-//			set = set[:]
-//
-//			p.muSync.Lock()
-//			// Iterate through list.
-//			//for e := p.writelist.Front(); e != nil; e = e.Next() {
-//			//	writer, ok := e.Value.(*SegmentWriter)
-//			//	if ok {
-//			//		set = append(set, writer)
-//			//	}
-//			//}
-//			p.muSync.Unlock()
-//
-//			for i, file := range set {
-//				err := file.sync()
-//				set[i] = nil
-//
-//				if err != nil {
-//					p.Logger.Error().Err(err)
-//				}
-//			}
-//		}
-//	}
+	set := make([]*SegmentWriter, 0, 256)
+	interval := SyncInterval()
+	ticker := time.NewTicker(interval)
+	defer func() { ticker.Stop() }()
+
+	for {
+		select {
+		// Listen for cancel
+		case <-p.ctx.Done():
+			return
+
+		case now := <-ticker.C:
+			next := SyncInterval()
+
+			set = set[:0]
+			p.muSync.Lock()
+			for writer := range p.writers {
+				set = append(set, writer)
+			}
+			p.muSync.Unlock()
+
+			for i, file := range set {
+				every, due := file.syncDue(now)
+				if due {
+					file.sync()
+				}
+				if every > 0 && every < next {
+					next = every
+				}
+				set[i] = nil
+			}
+
+			if next != interval && next > 0 {
+				interval = next
+				ticker.Stop()
+				ticker = time.NewTicker(interval)
+			}
+		}
+	}
 }
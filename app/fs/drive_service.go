@@ -3,13 +3,21 @@ package fs
 import (
 	"context"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/backup"
+	"github.com/genzai-io/sliced/app/events"
 	"github.com/genzai-io/sliced/proto/store"
+	"github.com/genzai-io/sliced/common/diskio"
 	"github.com/genzai-io/sliced/common/service"
 )
 
@@ -20,6 +28,9 @@ var (
 	// Amount of bytes to leave in each drive
 	BufferSpace = 1024 * 1024 * 1024 // 1GB
 
+	// Most drive events kept
+	maxDriveEvents = 64
+
 	Drives *DriveService = nil
 )
 
@@ -48,6 +59,11 @@ type DriveService struct {
 
 	// Store a slice of recent events
 	events []*DriveEvent
+
+	backup *backup.Source
+
+	// Called with the old and new path of every migrated segment
+	OnMigrated func(from, to string)
 }
 
 func NewDriveService() *DriveService {
@@ -66,6 +82,10 @@ func NewDriveService() *DriveService {
 func (d *DriveService) OnStart() error {
 	d.syncConfig()
 
+	d.backup = &backup.Source{Files: d.backupSegments}
+	backup.Register(d.backup)
+	diskio.Notify = d.readOnlyChanged
+
 	d.wg.Add(1)
 	go func() {
 		defer d.wg.Done()
@@ -73,16 +93,49 @@ func (d *DriveService) OnStart() error {
 		for {
 			select {
 			case <-d.ctx.Done():
+				return
 
 			case <-time.After(time.Second * 5):
+				d.checkConfig()
 				d.statfs()
 			}
 		}
 	}()
 
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		ticker := time.NewTicker(MigrateInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.ctx.Done():
+				return
+
+			case <-ticker.C:
+				d.migrate()
+			}
+		}
+	}()
+
 	return nil
 }
 
+func (d *DriveService) OnStop() {
+	backup.Unregister(d.backup)
+	diskio.Notify = nil
+
+	d.cancel()
+	d.wg.Wait()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, drive := range d.drives {
+		drive.Stop()
+	}
+}
+
 func (d *DriveService) statfs() {
 	d.mu.RLock()
 	for _, drive := range d.drives {
@@ -92,12 +145,39 @@ func (d *DriveService) statfs() {
 
 	d.mu.Lock()
 	defer d.mu.Unlock()
+	for _, drive := range d.drives {
+		d.checkFull(drive)
+	}
 	sort.Sort(d.hdd)
 	sort.Sort(d.ssd)
 	sort.Sort(d.nvm)
 }
 
+// Stops writes to a drive once it's within BufferSpace of full and resumes
+// them once a quarter more than that is free again. The lock must be held.
+func (d *DriveService) checkFull(drive *Drive) {
+	stats := drive.stats()
+	if stats.Size_ == 0 {
+		return
+	}
+	mount := drive.model.Mount
+	switch {
+	case !drive.isFull() && stats.Avail < uint64(BufferSpace):
+		drive.setFull(true)
+		d.event("\"%s\" is full with %s available", mount, humanize.IBytes(stats.Avail))
+		d.Logger.Warn().Msgf("\"%s\" drive is full with %s available. writes are stopped", mount, humanize.IBytes(stats.Avail))
+		events.Publish(events.DriveFull, mount, "avail", strconv.FormatUint(stats.Avail, 10))
+
+	case drive.isFull() && stats.Avail >= uint64(BufferSpace)+uint64(BufferSpace)/4:
+		drive.setFull(false)
+		d.event("\"%s\" accepts writes with %s available", mount, humanize.IBytes(stats.Avail))
+		d.Logger.Info().Msgf("\"%s\" drive accepts writes again with %s available", mount, humanize.IBytes(stats.Avail))
+	}
+}
+
+// Applies changes to the configured drives.
 func (d *DriveService) checkConfig() {
+	d.syncConfig()
 }
 
 func (d *DriveService) syncConfig() {
@@ -114,9 +194,11 @@ func (d *DriveService) syncConfig() {
 			// Drive removed
 			d.removed = append(d.removed, drive)
 			delete(d.drives, strings.ToLower(drive.model.Mount))
+			drive.Stop()
 			changes = true
 
 			d.Logger.Warn().Msgf("\"%s\" %s drive removed", drive.model.Mount, drive.model.Kind)
+			events.Publish(events.DriveRemoved, drive.model.Mount, "kind", drive.model.Kind.String())
 		}
 	}
 
@@ -125,10 +207,17 @@ func (d *DriveService) syncConfig() {
 		existing, ok := d.drives[strings.ToLower(model.Mount)]
 		// Was a new drive added?
 		if existing == nil || !ok {
-			d.drives[strings.ToLower(model.Mount)] = newDrive(*model)
+			drive := newDrive(*model)
+			if err := drive.Start(); err != nil {
+				d.Logger.Error().Err(err).Msgf("\"%s\" drive start failed", model.Mount)
+				continue
+			}
+			drive.Statfs()
+			d.drives[strings.ToLower(model.Mount)] = drive
 			changes = true
 
 			d.Logger.Warn().Msgf("\"%s\" %s drive added", model.Mount, model.Kind)
+			events.Publish(events.DriveAdded, model.Mount, "kind", model.Kind.String())
 		} else {
 			if existing.model.Kind != model.Kind {
 				changes = true
@@ -144,9 +233,9 @@ func (d *DriveService) syncConfig() {
 	}
 
 	if changes {
-		d.hdd = d.hdd[:]
-		d.ssd = d.ssd[:]
-		d.nvm = d.nvm[:]
+		d.hdd = d.hdd[:0]
+		d.ssd = d.ssd[:0]
+		d.nvm = d.nvm[:0]
 
 		for _, drive := range d.drives {
 			switch drive.model.Kind {
@@ -165,59 +254,64 @@ func (d *DriveService) syncConfig() {
 	}
 }
 
-// Picks a drives to put a new file onto.
+// Picks the drive to put a new segment of size bytes onto. Drives of the
+// preferred kind are tried first and within a kind segments are spread by
+// free space and write load. Drives that are within BufferSpace of full are
+// never picked.
 func (d *DriveService) Pick(kind store.Drive_Kind, size uint64) (*Drive, error) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
+	var order []DriveList
 	switch kind {
 	case store.Drive_HDD:
-		if drive := d.pick(d.hdd, size); drive != nil {
-			return drive, nil
-		}
-		if drive := d.pick(d.ssd, size); drive != nil {
-			return drive, nil
-		}
-		if drive := d.pick(d.nvm, size); drive != nil {
-			return drive, nil
-		}
-
+		order = []DriveList{d.hdd, d.ssd, d.nvm}
 	case store.Drive_SSD:
-		if drive := d.pick(d.ssd, size); drive != nil {
-			return drive, nil
-		}
-		if drive := d.pick(d.nvm, size); drive != nil {
-			return drive, nil
-		}
-		if drive := d.pick(d.hdd, size); drive != nil {
-			return drive, nil
-		}
-
+		order = []DriveList{d.ssd, d.nvm, d.hdd}
 	case store.Drive_NVME:
-		if drive := d.pick(d.nvm, size); drive != nil {
-			return drive, nil
-		}
-		if drive := d.pick(d.ssd, size); drive != nil {
-			return drive, nil
-		}
-		if drive := d.pick(d.hdd, size); drive != nil {
-			return drive, nil
-		}
-
+		order = []DriveList{d.nvm, d.ssd, d.hdd}
 	default:
 		return nil, ErrInvalidDriveKind
 	}
 
+	for _, list := range order {
+		if drive := list.PickNext(size); drive != nil {
+			return drive, nil
+		}
+	}
 	return nil, ErrOutOfDiskSpace
 }
 
-func (d *DriveService) pick(drives []*Drive, size uint64) *Drive {
-	for _, d := range drives {
-		if d.model.Stats.Avail > size {
-			return d
-		}
+// Creates a segment at name under the mount of the drive picked for it.
+func (d *DriveService) Create(kind store.Drive_Kind, name string, expectedSize int64, level store.Level) (*SegmentWriter, error) {
+	drive, err := d.Pick(kind, uint64(expectedSize))
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	name = filepath.Join(drive.model.Mount, name)
+	if err := os.MkdirAll(filepath.Dir(name), moved.PathMode); err != nil {
+		return nil, err
+	}
+	return drive.Create(name, expectedSize, 0644, level)
+}
+
+// Recent events of the drives, oldest first.
+func (d *DriveService) Events() []*DriveEvent {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return append([]*DriveEvent(nil), d.events...)
+}
+
+// Keeps a drive event. The lock must be held.
+func (d *DriveService) event(format string, args ...interface{}) {
+	if len(d.events) >= maxDriveEvents {
+		copy(d.events, d.events[1:])
+		d.events = d.events[:len(d.events)-1]
+	}
+	d.events = append(d.events, &DriveEvent{
+		Timestamp: time.Now(),
+		Message:   fmt.Sprintf(format, args...),
+	})
 }
 
 type DriveList []*Drive
@@ -230,9 +324,7 @@ func (d DriveList) Len() int {
 // Less reports whether the element with
 // index i should sort before the element with index j.
 func (d DriveList) Less(i, j int) bool {
-	ii := d[i]
-	jj := d[j]
-	return ii.model.Stats.Used < jj.model.Stats.Used
+	return d[i].stats().Used < d[j].stats().Used
 }
 
 // Swap swaps the elements with indexes i and j.
@@ -243,7 +335,23 @@ func (d DriveList) Swap(i, j int) {
 	d[j] = ii
 }
 
-// We want to evenly fill all available drives.
-func (d DriveList) PickNext() {
-
+// We want to evenly fill all available drives. Picks the drive with the most
+// free space per segment being written to it that has room for size bytes
+// above BufferSpace.
+func (d DriveList) PickNext(size uint64) *Drive {
+	var (
+		best      *Drive
+		bestScore float64
+	)
+	for _, drive := range d {
+		free, ok := drive.headroom(size)
+		if !ok {
+			continue
+		}
+		score := float64(free) / float64(1+drive.load())
+		if best == nil || score > bestScore {
+			best, bestScore = drive, score
+		}
+	}
+	return best
 }
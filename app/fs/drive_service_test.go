@@ -1,7 +1,195 @@
 package fs
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/genzai-io/sliced/proto/store"
+)
+
+const gb = 1024 * 1024 * 1024
 
 func TestDriveService_OnStart(t *testing.T) {
 
 }
+
+func testDrive(mount string, kind store.Drive_Kind, avail uint64) *Drive {
+	drive := newDrive(store.Drive{Mount: mount, Kind: kind})
+	drive.model.Stats = &store.DriveStats{Size_: 100 * gb, Avail: avail}
+	return drive
+}
+
+func TestDriveList_PickNext(t *testing.T) {
+	a := testDrive("/a", store.Drive_SSD, 10*gb)
+	b := testDrive("/b", store.Drive_SSD, 20*gb)
+	c := testDrive("/c", store.Drive_SSD, 50*gb)
+	c.setFull(true)
+	list := DriveList{a, b, c}
+
+	if drive := list.PickNext(gb); drive != b {
+		t.Fatalf("expected the drive with the most free space got %s", drive.model.Mount)
+	}
+
+	// Writers spread new segments to drives with less free space
+	b.writers[&SegmentWriter{}] = struct{}{}
+	b.writers[&SegmentWriter{}] = struct{}{}
+	if drive := list.PickNext(gb); drive != a {
+		t.Fatalf("expected the least loaded drive got %s", drive.model.Mount)
+	}
+
+	// Nothing is picked when no drive has room above BufferSpace
+	if drive := list.PickNext(30 * gb); drive != nil {
+		t.Fatalf("expected no drive got %s", drive.model.Mount)
+	}
+}
+
+func TestDriveService_Pick(t *testing.T) {
+	ds := NewDriveService()
+	ds.ssd = DriveList{testDrive("/ssd", store.Drive_SSD, 2*gb)}
+	ds.hdd = DriveList{testDrive("/hdd", store.Drive_HDD, 20*gb)}
+
+	// Falls back to a slower kind when the preferred one is out of room
+	drive, err := ds.Pick(store.Drive_SSD, 4*gb)
+	if err != nil || drive != ds.hdd[0] {
+		t.Fatalf("expected the hdd got %v %v", drive, err)
+	}
+	if _, err := ds.Pick(store.Drive_SSD, 100*gb); err != ErrOutOfDiskSpace {
+		t.Fatalf("expected ErrOutOfDiskSpace got %v", err)
+	}
+}
+
+func TestDriveService_CheckFull(t *testing.T) {
+	ds := NewDriveService()
+	drive := testDrive("/ssd", store.Drive_SSD, gb/2)
+
+	ds.checkFull(drive)
+	if !drive.isFull() {
+		t.Fatal("expected the drive to be full")
+	}
+	if _, err := drive.Create("/ssd/s/1/t/1/1.s", 1024, 0644, store.Level_BUSINESS); err != ErrOutOfDiskSpace {
+		t.Fatalf("expected ErrOutOfDiskSpace got %v", err)
+	}
+
+	// Stays full until there is more than BufferSpace free
+	drive.model.Stats.Avail = gb + 1
+	ds.checkFull(drive)
+	if !drive.isFull() {
+		t.Fatal("expected the drive to stay full")
+	}
+	drive.model.Stats.Avail = 2 * gb
+	ds.checkFull(drive)
+	if drive.isFull() {
+		t.Fatal("expected the drive to accept writes")
+	}
+	if events := ds.Events(); len(events) != 2 {
+		t.Fatalf("expected 2 drive events got %d", len(events))
+	}
+}
+
+func TestDriveService_Migrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	nvme := testDrive(filepath.Join(dir, "nvme"), store.Drive_NVME, 10*gb)
+	hdd := testDrive(filepath.Join(dir, "hdd"), store.Drive_HDD, 10*gb)
+	ds := NewDriveService()
+	ds.drives[nvme.model.Mount] = nvme
+	ds.drives[hdd.model.Mount] = hdd
+	ds.nvm = DriveList{nvme}
+	ds.hdd = DriveList{hdd}
+
+	topic := filepath.Join("s", "1", "t", "2")
+	os.MkdirAll(filepath.Join(nvme.model.Mount, topic), 0755)
+	for _, name := range []string{"1.s", "2.s", "3.s"} {
+		ioutil.WriteFile(filepath.Join(nvme.model.Mount, topic, name), []byte(name), 0644)
+	}
+
+	// Off by default
+	if n := ds.migrate(); n != 0 {
+		t.Fatalf("expected no migration by default got %d migrated", n)
+	}
+
+	after := MigrateAfter[store.Drive_NVME]
+	defer func() { MigrateAfter[store.Drive_NVME] = after }()
+
+	MigrateAfter[store.Drive_NVME] = time.Hour
+	if n := ds.migrate(); n != 0 {
+		t.Fatalf("expected new segments to stay got %d migrated", n)
+	}
+
+	// With no SSD the sealed segments skip to the HDD
+	var migrated []string
+	ds.OnMigrated = func(from, to string) { migrated = append(migrated, to) }
+	MigrateAfter[store.Drive_NVME] = time.Nanosecond
+	if n := ds.migrate(); n != 2 || len(migrated) != 2 {
+		t.Fatalf("expected 2 migrated segments got %d", n)
+	}
+	for _, name := range []string{"1.s", "2.s"} {
+		data, err := ioutil.ReadFile(filepath.Join(hdd.model.Mount, topic, name))
+		if err != nil || string(data) != name {
+			t.Fatalf("expected %s on the hdd got %q %v", name, data, err)
+		}
+		if _, err := os.Stat(filepath.Join(nvme.model.Mount, topic, name)); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be removed from the nvme", name)
+		}
+	}
+
+	// The segment being written to stays
+	if _, err := os.Stat(filepath.Join(nvme.model.Mount, topic, "3.s")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDriveService_BackupSegments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	drive := newDrive(store.Drive{Mount: dir})
+	if err := drive.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer drive.Stop()
+	ds := NewDriveService()
+	ds.drives[dir] = drive
+
+	topic := filepath.Join(dir, "topics", "7")
+	os.MkdirAll(topic, 0755)
+	ioutil.WriteFile(filepath.Join(topic, "1.s"), []byte("sealed"), 0644)
+	w, err := drive.Create(filepath.Join(topic, "5.s"), 0, 0644, store.Level_BUSINESS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// The tail is left out until something is written to it
+	files, err := ds.backupSegments()
+	if err != nil || len(files) != 1 || files[0].Path != filepath.Join(topic, "1.s") || files[0].Size != 0 {
+		t.Fatalf("expected the sealed segment got %+v %v", files, err)
+	}
+
+	// The tail is backed up as far as it is written
+	if _, err := w.Write([]byte("tail")); err != nil {
+		t.Fatal(err)
+	}
+	files, err = ds.backupSegments()
+	if err != nil || len(files) != 2 {
+		t.Fatalf("expected 2 segments got %+v %v", files, err)
+	}
+	for _, f := range files {
+		if f.Path == filepath.Join(topic, "5.s") && f.Size != 4 {
+			t.Fatalf("expected 4 bytes of the tail got %+v", f)
+		}
+	}
+	if files[0].Key == files[1].Key {
+		t.Fatalf("expected distinct keys got %+v", files)
+	}
+}
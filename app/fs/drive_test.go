@@ -1,7 +1,212 @@
 package fs
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/genzai-io/sliced/common/diskio"
+	"github.com/genzai-io/sliced/proto/store"
+)
 
 func TestStart(t *testing.T) {
 
 }
+
+func waitFor(t *testing.T, what string, fn func() bool) {
+	for deadline := time.Now().Add(2 * time.Second); !fn(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDrive_Workers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	interval := SyncInterval()
+	defer SetSyncInterval(interval)
+	SetSyncInterval(10 * time.Millisecond)
+
+	drive := newDrive(store.Drive{Mount: dir})
+	if err := drive.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer drive.Stop()
+
+	w, err := drive.Create(filepath.Join(dir, "1.s"), 0, 0644, store.Level_BUSINESS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The next segment is allocated ahead of the writer
+	next := filepath.Join(dir, "2.s"+preallocSuffix)
+	waitFor(t, "preallocation", func() bool {
+		_, err := os.Stat(next)
+		return err == nil
+	})
+
+	// Passing the truncate position grows the file in the background
+	w.RLock()
+	size, pos := w.filesz, w.truncatePos
+	w.RUnlock()
+	if _, err := w.Write(make([]byte, pos)); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "growth", func() bool {
+		w.RLock()
+		defer w.RUnlock()
+		return w.filesz > size && !w.extending
+	})
+
+	waitFor(t, "sync", func() bool {
+		w.RLock()
+		defer w.RUnlock()
+		return w.syncPos == w.writePos
+	})
+	w.Close()
+
+	// Creating the next segment uses the preallocated file
+	m, err := drive.Create(filepath.Join(dir, "2.s"), 0, 0644, store.Level_MISSION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if _, err := os.Stat(next); !os.IsNotExist(err) {
+		t.Fatal("expected the preallocated file to be used")
+	}
+	if _, err := m.Write([]byte("mission")); err != nil {
+		t.Fatal(err)
+	}
+	m.RLock()
+	synced := m.syncPos == m.writePos
+	m.RUnlock()
+	if !synced {
+		t.Fatal("expected MISSION writes to be synced on write")
+	}
+}
+
+func TestSegmentWriter_SyncDue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	interval := SyncInterval()
+	defer SetSyncInterval(interval)
+	SetSyncInterval(time.Second)
+
+	drive := newDrive(store.Drive{Mount: dir})
+	defer drive.guard.Close()
+	w, err := drive.Create(filepath.Join(dir, "1.s"), 0, 0644, store.Level_BUSINESS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// The topic interval wins over SyncInterval
+	now := time.Now()
+	if every, due := w.syncDue(now); every != time.Second || !due {
+		t.Fatalf("expected a due sync every second got %v %v", every, due)
+	}
+	w.SetLevel(store.Level_BUSINESS, 10*time.Millisecond)
+	for _, test := range []struct {
+		after time.Duration
+		due   bool
+	}{
+		{5 * time.Millisecond, false},
+		{10 * time.Millisecond, true},
+		{15 * time.Millisecond, false},
+		{20 * time.Millisecond, true},
+	} {
+		if every, due := w.syncDue(now.Add(test.after)); every != 10*time.Millisecond || due != test.due {
+			t.Fatalf("after %v expected due %v got %v %v", test.after, test.due, every, due)
+		}
+	}
+
+	// Other levels are not synced by the worker
+	w.SetLevel(store.Level_BACKGROUND, 0)
+	if every, due := w.syncDue(now.Add(time.Hour)); every != 0 || due {
+		t.Fatalf("expected no sync got %v %v", every, due)
+	}
+}
+
+func TestSegmentWriter_MissionSyncError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	drive := newDrive(store.Drive{Mount: dir})
+	defer drive.guard.Close()
+	w, err := drive.Create(filepath.Join(dir, "1.s"), 0, 0644, store.Level_MISSION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// A MISSION write is only acknowledged once it's synced
+	remove := diskio.Inject(dir, diskio.OpSync, syscall.EINVAL)
+	defer remove()
+	if _, err := w.Write([]byte("mission")); err == nil {
+		t.Fatal("expected the sync error")
+	}
+}
+
+func TestDrive_ReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	interval := diskio.ProbeInterval
+	defer func() { diskio.ProbeInterval = interval }()
+	diskio.ProbeInterval = 10 * time.Millisecond
+
+	ds := NewDriveService()
+	diskio.Notify = ds.readOnlyChanged
+	defer func() { diskio.Notify = nil }()
+
+	drive := newDrive(store.Drive{Mount: dir})
+	defer drive.guard.Close()
+	w, err := drive.Create(filepath.Join(dir, "1.s"), 0, 0644, store.Level_BUSINESS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// Growing the segment fails once the disk is full
+	remove := diskio.Inject(dir, diskio.OpTruncate, syscall.ENOSPC)
+	w.RLock()
+	size := w.filesz
+	w.RUnlock()
+	if _, err := w.Write(make([]byte, size+1)); !diskio.IsReadOnly(err) {
+		t.Fatalf("expected a read-only error got %v", err)
+	}
+	if _, err := w.Write([]byte("a")); !diskio.IsReadOnly(err) {
+		t.Fatalf("expected writes to be refused got %v", err)
+	}
+	if _, err := drive.Create(filepath.Join(dir, "2.s"), 0, 0644, store.Level_BUSINESS); !diskio.IsReadOnly(err) {
+		t.Fatalf("expected new segments to be refused got %v", err)
+	}
+
+	remove()
+	waitFor(t, "writes to resume", func() bool { return drive.ReadOnly() == nil })
+	if _, err := w.Write([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if events := ds.Events(); len(events) != 2 {
+		t.Fatalf("expected 2 drive events got %d", len(events))
+	}
+}
@@ -0,0 +1,134 @@
+package fs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/events"
+	"github.com/genzai-io/sliced/proto/store"
+)
+
+var (
+	// Age after which sealed segments move to a slower kind of drive. Zero
+	// keeps the segments of a kind where they are. Every kind is zero by
+	// default since only OnMigrated hears of a move, topic metadata and
+	// open readers don't.
+	MigrateAfter = map[store.Drive_Kind]time.Duration{}
+
+	// Interval between looks for segments to migrate.
+	MigrateInterval = time.Minute
+)
+
+// Next slower kind of drive.
+func slower(kind store.Drive_Kind) (store.Drive_Kind, bool) {
+	switch kind {
+	case store.Drive_NVME:
+		return store.Drive_SSD, true
+	case store.Drive_SSD:
+		return store.Drive_HDD, true
+	}
+	return kind, false
+}
+
+// Drives of a kind. The lock must be held.
+func (d *DriveService) tier(kind store.Drive_Kind) DriveList {
+	switch kind {
+	case store.Drive_HDD:
+		return d.hdd
+	case store.Drive_SSD:
+		return d.ssd
+	case store.Drive_NVME:
+		return d.nvm
+	}
+	return nil
+}
+
+// Moves the sealed segments that are older than MigrateAfter for their kind
+// of drive onto the next slower kind of drive that has room. Returns the
+// number of segments moved.
+func (d *DriveService) migrate() int {
+	now := time.Now()
+	count := 0
+	for _, f := range d.sealed() {
+		d.mu.RLock()
+		kind := f.drive.model.Kind
+		age := MigrateAfter[kind]
+		d.mu.RUnlock()
+		if age <= 0 || now.Sub(f.info.ModTime()) < age {
+			continue
+		}
+
+		// Skip kinds without a drive with room
+		var to *Drive
+		d.mu.RLock()
+		for k, ok := slower(kind); ok && to == nil; k, ok = slower(k) {
+			to = d.tier(k).PickNext(uint64(f.info.Size()))
+		}
+		d.mu.RUnlock()
+		if to == nil {
+			continue
+		}
+
+		rel, err := filepath.Rel(f.drive.model.Mount, f.path)
+		if err != nil {
+			continue
+		}
+		dst := filepath.Join(to.model.Mount, rel)
+		if err := moveFile(f.path, dst); err != nil {
+			d.Logger.Error().Err(err).Msgf("migrating \"%s\" to \"%s\" failed", f.path, dst)
+			continue
+		}
+		count++
+
+		d.mu.Lock()
+		d.event("\"%s\" migrated to \"%s\"", f.path, dst)
+		d.mu.Unlock()
+		d.Logger.Info().Msgf("\"%s\" migrated to \"%s\"", f.path, dst)
+		events.Publish(events.SegmentMigrated, f.path, "to", dst, "bytes", strconv.FormatInt(f.info.Size(), 10))
+		if d.OnMigrated != nil {
+			d.OnMigrated(f.path, dst)
+		}
+	}
+	return count
+}
+
+// Moves a file to another drive. The copy is synced before the original is
+// removed so a crash leaves at least one whole copy.
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), moved.PathMode); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".migrate"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(src)
+}
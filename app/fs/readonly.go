@@ -0,0 +1,25 @@
+package fs
+
+import (
+	"github.com/genzai-io/sliced/app/events"
+)
+
+// Records stores turning read-only because their disk is full or failing
+// and turning writable again as drive events.
+func (d *DriveService) readOnlyChanged(path string, err error) {
+	d.mu.Lock()
+	if err != nil {
+		d.event("\"%s\" is read-only: %s", path, err)
+	} else {
+		d.event("\"%s\" accepts writes", path)
+	}
+	d.mu.Unlock()
+
+	if err != nil {
+		d.Logger.Error().Err(err).Msgf("\"%s\" is read-only until its disk takes writes again", path)
+		events.Publish(events.StoreReadOnly, path, "error", err.Error())
+	} else {
+		d.Logger.Info().Msgf("\"%s\" accepts writes again", path)
+		events.Publish(events.StoreWritable, path)
+	}
+}
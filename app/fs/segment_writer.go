@@ -14,6 +14,7 @@ import (
 	"github.com/genzai-io/sliced/app/path"
 	"github.com/genzai-io/sliced/app/pool/pbufio"
 	"github.com/genzai-io/sliced/app/record"
+	"github.com/genzai-io/sliced/common/diskio"
 	"github.com/genzai-io/sliced/proto/store"
 )
 
@@ -67,6 +68,12 @@ type SegmentWriter struct {
 	lastgrow  time.Time
 	extending bool // A flag to determine whether next is being built
 
+	// Durability of the topic the segment belongs to. BUSINESS segments are
+	// synced every syncInterval or SyncInterval when it's zero.
+	level        store.Level
+	syncInterval time.Duration
+	synced       time.Time // Last scheduled sync
+
 	// Stats
 	extcount  int64
 	extdur    time.Duration
@@ -199,6 +206,9 @@ func openSegmentWriter(volume *Drive, segment *store.Segment, mode os.FileMode)
 //
 //
 func createSegmentWriter(volume *Drive, name string, expectedSize int64, mode os.FileMode) (*SegmentWriter, error) {
+	if err := diskio.Fault(name, diskio.OpOpen); err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
 	file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_APPEND, mode)
 	if err != nil {
 		return nil, err
@@ -248,7 +258,7 @@ func createSegmentWriter(volume *Drive, name string, expectedSize int64, mode os
 	}
 
 	if fsize != initialSize {
-		err = file.Truncate(initialSize)
+		err = truncateFile(file, initialSize)
 		if err != nil {
 			file.Close()
 			return nil, err
@@ -313,6 +323,10 @@ func (f *SegmentWriter) Close() (err error) {
 	}
 	f.Unlock()
 
+	if f.volume != nil {
+		f.volume.close(f)
+	}
+
 	return nil
 }
 
@@ -365,7 +379,6 @@ func (f *SegmentWriter) append(r *record.Record) (int, error) {
 	err := f.write(hbuf[:hsize])
 	if err != nil {
 		f.writePos = marked
-		f.Unlock()
 		return 0, err
 	}
 
@@ -374,7 +387,6 @@ func (f *SegmentWriter) append(r *record.Record) (int, error) {
 	err = f.write(r.Data)
 	if err != nil {
 		f.writePos = marked
-		f.Unlock()
 		return 0, err
 	}
 
@@ -382,7 +394,6 @@ func (f *SegmentWriter) append(r *record.Record) (int, error) {
 	err = f.writeByte(record.End)
 	if err != nil {
 		f.writePos = marked
-		f.Unlock()
 		return 0, err
 	}
 
@@ -429,6 +440,11 @@ func (f *SegmentWriter) append(r *record.Record) (int, error) {
 //
 //
 func (f *SegmentWriter) Append(record *record.Record) (n int, err error) {
+	if f.volume != nil {
+		f.volume.muQuiesce.RLock()
+		defer f.volume.muQuiesce.RUnlock()
+	}
+
 	// Obtain a write lock
 	f.Lock()
 	// Check if closed
@@ -436,16 +452,28 @@ func (f *SegmentWriter) Append(record *record.Record) (n int, err error) {
 		f.Unlock()
 		return 0, os.ErrClosed
 	}
+	if err = f.failed(); err != nil {
+		f.Unlock()
+		return 0, err
+	}
 
 	n, err = f.append(record)
 	if err != nil {
 		f.Unlock()
 		return 0, err
 	}
+	f.growAhead()
+	level := f.level
 
 	// Release write lock
 	f.Unlock()
 
+	if level == store.Level_MISSION {
+		if err = f.sync(); err != nil {
+			return 0, err
+		}
+	}
+
 	return
 }
 
@@ -454,7 +482,6 @@ func (f *SegmentWriter) Append(record *record.Record) (n int, err error) {
 //
 func (f *SegmentWriter) writeByte(b byte) (err error) {
 	if f.closed {
-		f.Unlock()
 		return os.ErrClosed
 	}
 
@@ -468,8 +495,6 @@ func (f *SegmentWriter) writeByte(b byte) (err error) {
 
 		// Did truncate fail?
 		if err != nil {
-			f.Unlock()
-
 			f.volume.Logger.Error().Msgf("%s: truncate() returned an error", f.name)
 			f.volume.Logger.Error().Err(err)
 			return
@@ -477,7 +502,6 @@ func (f *SegmentWriter) writeByte(b byte) (err error) {
 
 		available = f.filesz - f.writePos
 		if available <= 0 {
-			f.Unlock()
 			return ErrEmptyWrite
 		}
 	}
@@ -536,8 +560,6 @@ func (f *SegmentWriter) write(p []byte) (error) {
 
 			// Did truncate fail?
 			if err != nil {
-				f.Unlock()
-
 				f.volume.Logger.Error().Msgf("%s: truncate() returned an error", f.name)
 				f.volume.Logger.Error().Err(err)
 				return err
@@ -558,6 +580,11 @@ func (f *SegmentWriter) write(p []byte) (error) {
 //
 //
 func (f *SegmentWriter) Write(p []byte) (int, error) {
+	if f.volume != nil {
+		f.volume.muQuiesce.RLock()
+		defer f.volume.muQuiesce.RUnlock()
+	}
+
 	// obtain a write lock
 	// all writes are serialized
 	f.Lock()
@@ -566,16 +593,96 @@ func (f *SegmentWriter) Write(p []byte) (int, error) {
 		f.Unlock()
 		return 0, os.ErrClosed
 	}
+	if err := f.failed(); err != nil {
+		f.Unlock()
+		return 0, err
+	}
 
+	// A failed write is dropped whole
+	marked := f.writePos
 	err := f.write(p)
+	if err == nil {
+		f.growAhead()
+	} else {
+		f.writePos = marked
+	}
+	level := f.level
 
 	// release write lock
 	f.Unlock()
 
 	if err != nil {
 		return 0, err
-	} else {
-		return len(p), nil
+	}
+	if level == store.Level_MISSION {
+		if err := f.sync(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Changes how the segment is synced. MISSION syncs on every write, BUSINESS
+// every interval or SyncInterval when it's zero and BACKGROUND never.
+func (f *SegmentWriter) SetLevel(level store.Level, interval time.Duration) {
+	f.Lock()
+	f.level = level
+	f.syncInterval = interval
+	f.Unlock()
+}
+
+// The sync interval of a BUSINESS segment and whether a sync is due at now.
+// A due sync is counted as done at now so the next one follows the ticks of
+// the worker. Other levels have no interval.
+func (f *SegmentWriter) syncDue(now time.Time) (time.Duration, bool) {
+	f.Lock()
+	defer f.Unlock()
+	if f.closed || f.level != store.Level_BUSINESS {
+		return 0, false
+	}
+	interval := f.syncInterval
+	if interval <= 0 {
+		interval = SyncInterval()
+	}
+	if now.Sub(f.synced) < interval {
+		return interval, false
+	}
+	f.synced = now
+	return interval, true
+}
+
+// The error that stops writes to the segment. Writes stop for good once
+// the mapping is lost and while the drive is read-only. The write lock must
+// be held.
+func (f *SegmentWriter) failed() error {
+	if len(f.fatal) > 0 {
+		return f.fatal[0]
+	}
+	if f.volume != nil {
+		return f.volume.guard.Err()
+	}
+	return nil
+}
+
+// Truncates a segment file unless a fault is injected into it.
+func truncateFile(file *os.File, size int64) error {
+	if err := diskio.Fault(file.Name(), diskio.OpTruncate); err != nil {
+		return &os.PathError{Op: "truncate", Path: file.Name(), Err: err}
+	}
+	return file.Truncate(size)
+}
+
+// Asks the drive to grow the file once the writes pass the truncate position
+// so the writer doesn't wait on the truncate. The write lock must be held.
+func (f *SegmentWriter) growAhead() {
+	if f.volume == nil || f.extending || f.writePos < f.truncatePos {
+		return
+	}
+	select {
+	case f.volume.chTruncate <- f:
+		f.extending = true
+	default:
+		// The writer grows the file itself once it's full
 	}
 }
 
@@ -584,21 +691,34 @@ func (f *SegmentWriter) Write(p []byte) (int, error) {
 //
 func (f *SegmentWriter) sync() error {
 	f.RLock()
+	closed := f.closed
 	syncPos := f.syncPos
 	writePos := f.writePos
 	f.RUnlock()
 
-	if syncPos < writePos {
-		if err := mmap.Fdatasync(f.file); err != nil {
-			f.volume.Logger.Error().Msgf("%s: file.Sync() error")
+	if !closed && syncPos < writePos {
+		start := time.Now()
+		err := diskio.Fault(f.name, diskio.OpSync)
+		if err != nil {
+			err = &os.PathError{Op: "sync", Path: f.name, Err: err}
+		} else {
+			err = mmap.Fdatasync(f.file)
+		}
+		syncTimer.UpdateSince(start)
+		if err != nil {
+			syncErrors.Inc(1)
+			f.volume.Logger.Error().Msgf("%s: file.Sync() error", f.name)
 			f.volume.Logger.Error().Err(err)
 
 			f.Lock()
 			f.err = append(f.err, err)
 			f.Unlock()
+			return f.volume.guard.Check(err)
 		} else {
 			f.Lock()
-			f.syncPos = writePos
+			if writePos > f.syncPos {
+				f.syncPos = writePos
+			}
 			f.Unlock()
 		}
 	}
@@ -676,12 +796,20 @@ func (f *SegmentWriter) truncate(needed int) error {
 
 	newsize := (f.filesz/growsize + 1) * growsize
 
+	// Stop growing before the drive fills
+	if f.volume != nil && f.volume.isFull() {
+		f.extending = false
+		return ErrOutOfDiskSpace
+	}
+
 	// Truncate file
-	err := f.file.Truncate(newsize)
+	err := truncateFile(f.file, newsize)
 	if err != nil {
 		f.volume.Logger.Error().Msgf("%s: file.Truncate() error", f.name)
 		f.volume.Logger.Error().Err(err)
-		return err
+		f.extending = false
+		f.err = append(f.err, err)
+		return f.volume.guard.Check(err)
 	}
 
 	f.lastgrow = now
@@ -873,3 +1001,72 @@ func (f *SegmentWriter) OpenReader() (*record.MMapReader, error) {
 
 	return reader, nil
 }
+
+// Calls fn with every record from the one at pos to the last one written
+// until fn returns false. Records are copied out of the segment. The segment
+// is read locked while fn runs.
+func (f *SegmentWriter) Scan(pos int64, fn func(r *record.Record) bool) error {
+	f.RLock()
+	defer f.RUnlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	for pos < f.writePos {
+		r, n, err := parseRecord(&f.header, f.b[pos:f.writePos])
+		if err != nil {
+			return err
+		}
+		pos += int64(n)
+		if !fn(r) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Parses a record written by append and returns the bytes it takes.
+func parseRecord(header *store.SegmentHeader, b []byte) (*record.Record, int, error) {
+	r := &record.Record{}
+
+	ts, read := binary.Varint(b)
+	if read <= 0 {
+		return nil, 0, record.ErrParseEpoch
+	}
+	r.ID.Epoch = uint64(int64(header.Timestamp) + ts)
+
+	seq, n := binary.Uvarint(b[read:])
+	if n <= 0 {
+		return nil, 0, record.ErrParseSeq
+	}
+	r.ID.Seq = seq
+	read += n
+
+	logID, n := binary.Varint(b[read:])
+	if n <= 0 {
+		return nil, 0, record.ErrParseLogID
+	}
+	r.LogID = uint64(int64(header.LogID) + logID)
+	read += n
+
+	if len(b) < read+2 {
+		return nil, 0, record.ErrParseSlot
+	}
+	r.Slot = uint16(b[read]) | uint16(b[read+1])<<8
+	read += 2
+
+	size, n := binary.Uvarint(b[read:])
+	if n <= 0 {
+		return nil, 0, record.ErrParseLength
+	}
+	read += n
+
+	if uint64(len(b)-read) < size+1 {
+		return nil, 0, record.ErrParseBody
+	}
+	r.Data = append([]byte(nil), b[read:read+int(size)]...)
+	read += int(size)
+	if b[read] != record.End {
+		return nil, 0, record.ErrParseEnd
+	}
+	return r, read + 1, nil
+}
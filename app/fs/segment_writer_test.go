@@ -2,16 +2,20 @@ package fs
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/record"
 	"github.com/genzai-io/sliced/proto/store"
 	"github.com/rs/zerolog"
 )
 
 func TestFile(t *testing.T) {
 	os.Remove("dat.txt")
+	defer os.Remove("dat.txt")
 
 	volume := newDrive(store.Drive{})
 	err := volume.Start()
@@ -19,7 +23,7 @@ func TestFile(t *testing.T) {
 		volume.Logger.Panic().Err(err)
 	}
 
-	file, err := volume.Create("dat.txt", 0, 0755)
+	file, err := volume.Create("dat.txt", 0, 0755, store.Level_BUSINESS)
 	if err != nil {
 		volume.Logger.Panic().Err(err)
 	}
@@ -48,6 +52,67 @@ func TestFile(t *testing.T) {
 	}
 }
 
+func TestSegmentWriter_Scan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "segment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	volume := newDrive(store.Drive{Mount: dir})
+	if err := volume.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer volume.Stop()
+
+	file, err := volume.Create(filepath.Join(dir, "1.s"), 0, 0644, store.Level_BUSINESS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var positions []int64
+	for i, data := range []string{"one", "two", "three"} {
+		positions = append(positions, file.Size())
+		r := &record.Record{
+			ID:    store.RecordID{Epoch: uint64(1000 + i), Seq: uint64(i)},
+			LogID: uint64(10 + i),
+			Slot:  uint16(i),
+			Data:  []byte(data),
+		}
+		if _, err := file.Append(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var read []*record.Record
+	err = file.Scan(positions[1], func(r *record.Record) bool {
+		read = append(read, r)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 2 {
+		t.Fatalf("expected 2 records got %d", len(read))
+	}
+	for i, r := range read {
+		if want := []string{"two", "three"}[i]; string(r.Data) != want || r.LogID != uint64(11+i) || r.Slot != uint16(1+i) {
+			t.Fatalf("expected %s got %+v", want, r)
+		}
+	}
+
+	// Scanning stops when fn returns false
+	count := 0
+	file.Scan(0, func(r *record.Record) bool {
+		count++
+		return false
+	})
+	if count != 1 {
+		t.Fatalf("expected 1 record got %d", count)
+	}
+}
+
 func BenchmarkFile(b *testing.B) {
 	volume := newDrive(store.Drive{})
 	err := volume.Start()
@@ -57,7 +122,7 @@ func BenchmarkFile(b *testing.B) {
 
 	os.Remove(fmt.Sprintf("dat-%d.txt", b.N))
 
-	file, err := volume.Create(fmt.Sprintf("dat-%d.txt", b.N), 0, 0755)
+	file, err := volume.Create(fmt.Sprintf("dat-%d.txt", b.N), 0, 0755, store.Level_BUSINESS)
 	//file, err := volume.Create(fmt.Sprintf("dat-%d.txt", b.N), int64(b.N * 10), 0755)
 	if err != nil {
 		volume.Logger.Panic().Err(err)
@@ -0,0 +1,115 @@
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A segment file found on a drive.
+type segmentFile struct {
+	drive *Drive
+	path  string
+	id    int64
+	info  os.FileInfo
+	// Writer of the segment if it's open
+	writer *SegmentWriter
+	// Whether the segment has the highest id of its topic
+	last bool
+}
+
+type topicKey struct {
+	slice string
+	topic string
+}
+
+// Lists the sealed segments on every drive. The segment with the highest id
+// of each topic is still being written to and is left out, as is every
+// segment with an open writer.
+func (d *DriveService) sealed() []segmentFile {
+	var files []segmentFile
+	for _, f := range d.segments() {
+		if !f.last && f.writer == nil {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// Lists every segment on every drive.
+func (d *DriveService) segments() []segmentFile {
+	d.mu.RLock()
+	drives := make([]*Drive, 0, len(d.drives))
+	for _, drive := range d.drives {
+		drives = append(drives, drive)
+	}
+	d.mu.RUnlock()
+
+	segments := make(map[topicKey][]segmentFile)
+	last := make(map[topicKey]int64)
+	open := make(map[string]*SegmentWriter)
+	for _, drive := range drives {
+		drive.muSync.Lock()
+		for writer := range drive.writers {
+			open[writer.name] = writer
+		}
+		drive.muSync.Unlock()
+
+		for key, dir := range topicDirs(drive.model.Mount) {
+			entries, err := ioutil.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				if !strings.HasSuffix(entry.Name(), ".s") {
+					continue
+				}
+				id, err := strconv.ParseInt(strings.TrimSuffix(entry.Name(), ".s"), 10, 64)
+				if err != nil {
+					continue
+				}
+				path := filepath.Join(dir, entry.Name())
+				segments[key] = append(segments[key], segmentFile{
+					drive:  drive,
+					path:   path,
+					id:     id,
+					info:   entry,
+					writer: open[path],
+				})
+				if id > last[key] {
+					last[key] = id
+				}
+			}
+		}
+	}
+
+	var files []segmentFile
+	for key, list := range segments {
+		for _, f := range list {
+			f.last = f.id == last[key]
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// Directories of the topics on the drive mounted at mount. Slices keep their
+// topics under s/<slice>/t/<topic> and the cluster keeps the tails of its
+// topics under topics/<topic>.
+func topicDirs(mount string) map[topicKey]string {
+	dirs := make(map[topicKey]string)
+	slices, _ := ioutil.ReadDir(filepath.Join(mount, "s"))
+	for _, slice := range slices {
+		topics, _ := ioutil.ReadDir(filepath.Join(mount, "s", slice.Name(), "t"))
+		for _, topic := range topics {
+			dirs[topicKey{slice.Name(), topic.Name()}] = filepath.Join(mount, "s", slice.Name(), "t", topic.Name())
+		}
+	}
+	topics, _ := ioutil.ReadDir(filepath.Join(mount, "topics"))
+	for _, topic := range topics {
+		dirs[topicKey{"", topic.Name()}] = filepath.Join(mount, "topics", topic.Name())
+	}
+	return dirs
+}
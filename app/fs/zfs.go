@@ -0,0 +1,118 @@
+package fs
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/common/zfs"
+)
+
+var ErrNotZFS = errors.New("drive is not on a zfs dataset")
+
+// Dataset of the ZFS filesystem the drive is mounted on.
+func (d *Drive) Dataset() (*zfs.Dataset, error) {
+	mount, err := filepath.Abs(d.model.Mount)
+	if err != nil {
+		return nil, err
+	}
+	filesystems, err := zfs.Filesystems("")
+	if err != nil {
+		// No zfs tools means no zfs datasets
+		if e, ok := err.(*zfs.Error); ok {
+			if _, ok := e.Err.(*exec.Error); ok {
+				return nil, ErrNotZFS
+			}
+		}
+		return nil, err
+	}
+
+	// The deepest mountpoint that contains the drive
+	var found *zfs.Dataset
+	for _, fs := range filesystems {
+		mountpoint := fs.Mountpoint
+		if !filepath.IsAbs(mountpoint) {
+			// none or legacy
+			continue
+		}
+		if mount != mountpoint && !strings.HasPrefix(mount, strings.TrimSuffix(mountpoint, "/")+"/") {
+			continue
+		}
+		if found == nil || len(mountpoint) > len(found.Mountpoint) {
+			found = fs
+		}
+	}
+	if found == nil {
+		return nil, ErrNotZFS
+	}
+	return found, nil
+}
+
+// Takes a ZFS snapshot of the dataset of every drive. Writes to every drive
+// are paused while the snapshots are taken so they agree with each other.
+func (d *DriveService) Snapshot(name string) ([]api.DriveSnapshot, error) {
+	d.mu.RLock()
+	drives := make([]*Drive, 0, len(d.drives))
+	for _, drive := range d.drives {
+		drives = append(drives, drive)
+	}
+	d.mu.RUnlock()
+	sort.Slice(drives, func(i, j int) bool { return drives[i].model.Mount < drives[j].model.Mount })
+
+	var (
+		paused    []*Drive
+		snapshots []api.DriveSnapshot
+		datasets  = make(map[string]*zfs.Dataset)
+	)
+	for _, drive := range drives {
+		ds, err := drive.Dataset()
+		if err == ErrNotZFS {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		datasets[ds.Name] = ds
+		paused = append(paused, drive)
+		snapshots = append(snapshots, api.DriveSnapshot{
+			Mount:    drive.model.Mount,
+			Snapshot: ds.Name + "@" + name,
+		})
+	}
+	if len(datasets) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(datasets))
+	for ds := range datasets {
+		names = append(names, ds)
+	}
+	sort.Strings(names)
+
+	// Segment writers wait for the pause before they write. What they
+	// wrote before is synced so the snapshots have every acknowledged record.
+	for _, drive := range paused {
+		drive.muQuiesce.Lock()
+	}
+	defer func() {
+		for _, drive := range paused {
+			drive.muQuiesce.Unlock()
+		}
+	}()
+	for _, drive := range paused {
+		if err := drive.syncWriters(); err != nil {
+			return nil, err
+		}
+	}
+	for _, ds := range names {
+		if _, err := datasets[ds].Snapshot(name, false); err != nil {
+			return nil, err
+		}
+	}
+
+	d.Logger.Info().Msgf("zfs snapshot \"%s\" of %d datasets", name, len(names))
+	return snapshots, nil
+}
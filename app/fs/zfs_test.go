@@ -0,0 +1,114 @@
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/genzai-io/sliced/proto/store"
+)
+
+// Answers list and snapshot like zfs for a "tank/data" filesystem mounted at
+// $FAKE_ZFS_MOUNT and logs every call to $FAKE_ZFS_LOG.
+const fakeZFS = `#!/bin/sh
+echo "$@" >> "$FAKE_ZFS_LOG"
+line() {
+	printf '%s\t-\t1024\t4096\t%s\tlz4\t%s\t-\t0\t1024\t0\t1024\t1024\n' "$1" "$2" "$3"
+}
+case "$1" in
+list)
+	for last; do :; done
+	case "$last" in
+	*@*) line "$last" - snapshot ;;
+	*)
+		line tank - filesystem
+		line tank/data "$FAKE_ZFS_MOUNT" filesystem
+		;;
+	esac
+	;;
+snapshot) ;;
+*) echo "unsupported" >&2; exit 1 ;;
+esac
+`
+
+func TestDriveService_Snapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bin := filepath.Join(dir, "bin")
+	os.Mkdir(bin, 0755)
+	if err := ioutil.WriteFile(filepath.Join(bin, "zfs"), []byte(fakeZFS), 0755); err != nil {
+		t.Fatal(err)
+	}
+	log := filepath.Join(dir, "zfs.log")
+	mount := filepath.Join(dir, "tank")
+	os.Setenv("FAKE_ZFS_LOG", log)
+	os.Setenv("FAKE_ZFS_MOUNT", mount)
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", bin+string(os.PathListSeparator)+path)
+
+	ds := NewDriveService()
+	ds.drives["ssd"] = newDrive(store.Drive{Mount: filepath.Join(mount, "ssd")})
+	ds.drives["hdd"] = newDrive(store.Drive{Mount: filepath.Join(mount, "hdd")})
+	ds.drives["other"] = newDrive(store.Drive{Mount: filepath.Join(dir, "other")})
+
+	// Writes of a BUSINESS topic are synced before the snapshot
+	os.MkdirAll(filepath.Join(mount, "ssd"), 0755)
+	w, err := ds.drives["ssd"].Create(filepath.Join(mount, "ssd", "1.s"), 0, 0644, store.Level_BUSINESS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := ds.Snapshot("b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[0].Mount != filepath.Join(mount, "hdd") || snapshots[0].Snapshot != "tank/data@b1" {
+		t.Fatalf("unexpected snapshots %+v", snapshots)
+	}
+
+	// Both drives are on one dataset which is only snapshot once
+	calls, err := ioutil.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(calls), "snapshot tank/data@b1\n"); n != 1 {
+		t.Fatalf("expected a single snapshot got calls:\n%s", calls)
+	}
+	w.RLock()
+	synced := w.syncPos == w.writePos
+	w.RUnlock()
+	if !synced {
+		t.Fatal("expected the writer to be synced")
+	}
+
+	// Writes continue after the snapshot
+	done := make(chan struct{})
+	go func() {
+		ds.drives["ssd"].muQuiesce.RLock()
+		ds.drives["ssd"].muQuiesce.RUnlock()
+		close(done)
+	}()
+	<-done
+}
+
+func TestDrive_DatasetNotZFS(t *testing.T) {
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", "")
+
+	d := newDrive(store.Drive{Mount: "/tmp"})
+	if _, err := d.Dataset(); err != ErrNotZFS {
+		t.Fatalf("expected ErrNotZFS without zfs tools got %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+package mmap
+
+import (
+	"os"
+	"syscall"
+)
+
+// Fallocate reserves the blocks of the first size bytes of a file.
+func Fallocate(file *os.File, size int64) error {
+	err := syscall.Fallocate(int(file.Fd()), 0, 0, size)
+	if err == syscall.EOPNOTSUPP {
+		return file.Truncate(size)
+	}
+	return err
+}
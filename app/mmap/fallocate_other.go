@@ -0,0 +1,10 @@
+// +build !linux
+
+package mmap
+
+import "os"
+
+// Fallocate extends a file to size bytes. Blocks are only reserved on Linux.
+func Fallocate(file *os.File, size int64) error {
+	return file.Truncate(size)
+}
@@ -1,10 +1,16 @@
 package node
 
 import (
+	"crypto/tls"
+	"net"
+	"sync"
 	"time"
 
+	"github.com/genzai-io/sliced"
 	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/certs"
 	"github.com/genzai-io/sliced/common/redigo/redis"
+	"github.com/genzai-io/sliced/common/resp"
 )
 
 // Inter-node communication / forwarding
@@ -16,6 +22,24 @@ type Transport interface {
 	SendMany(commands ...api.Command) []api.CommandReply
 }
 
+var (
+	remotes   = make(map[string]*remoteTransport)
+	remotesMu sync.Mutex
+)
+
+// Transport to the node listening at address. Transports are pooled and
+// shared by every caller.
+func Dial(address string) Transport {
+	remotesMu.Lock()
+	defer remotesMu.Unlock()
+	t, ok := remotes[address]
+	if !ok {
+		t = newRemoteTransport(address)
+		remotes[address] = t
+	}
+	return t
+}
+
 type localTransport struct {
 }
 
@@ -45,10 +69,14 @@ func newRemoteTransport(target string) *remoteTransport {
 			IdleTimeout: time.Minute, //
 			Wait:        false,
 			Dial: func() (redis.Conn, error) {
-				c, err := redis.Dial("tcp", target)
+				c, err := DialRedis(target)
 				if err != nil {
 					return nil, err
 				}
+				if err := Authenticate(c); err != nil {
+					c.Close()
+					return nil, err
+				}
 				return c, err
 			},
 			TestOnBorrow: func(c redis.Conn, t time.Time) error {
@@ -72,20 +100,66 @@ func (t *remoteTransport) Get() Transport {
 
 func (t *remoteTransport) Send(command api.Command) api.CommandReply {
 	conn := t.pool.Get()
-	if conn == nil {
-		return nil
-	}
+	defer conn.Close()
+	return send(conn, command)
+}
 
-	var out []byte
-	out = command.Marshal(out)
+func (t *remoteTransport) SendMany(commands ...api.Command) []api.CommandReply {
+	conn := t.pool.Get()
+	defer conn.Close()
 
-	//conn.Send()
+	replies := make([]api.CommandReply, len(commands))
+	for i, command := range commands {
+		replies[i] = send(conn, command)
+	}
+	return replies
+}
 
-	return nil
+// Sends a command as it's marshaled arguments and waits for the reply.
+func send(conn redis.Conn, command api.Command) api.CommandReply {
+	args, _, err := resp.ParseCommand(command.Marshal(nil))
+	if err != nil {
+		return api.Err("ERR " + err.Error())
+	}
+	if len(args) == 0 {
+		return api.Err("ERR empty request")
+	}
+
+	params := make([]interface{}, len(args)-1)
+	for i, arg := range args[1:] {
+		params[i] = arg
+	}
+	reply, err := conn.Do(string(args[0]), params...)
+	if err != nil {
+		if e, ok := err.(redis.Error); ok {
+			return api.Err(string(e))
+		}
+		return api.Err("ERR " + err.Error())
+	}
+	return toReply(reply)
 }
 
-func (t *remoteTransport) SendMany(commands ...api.Command) []api.CommandReply {
-	return nil
+// Converts a redigo reply to a command reply.
+func toReply(reply interface{}) api.CommandReply {
+	switch r := reply.(type) {
+	case nil:
+		return api.NIL
+	case int64:
+		return api.Int(r)
+	case string:
+		return api.SimpleString(r)
+	case []byte:
+		return api.BulkString(r)
+	case redis.Error:
+		return api.Err(string(r))
+	case []interface{}:
+		arr := make(api.Array, len(r))
+		for i, element := range r {
+			arr[i] = toReply(element)
+		}
+		return arr
+	}
+	return api.NIL
 }
 
 type remoteTransportConn struct {
@@ -98,11 +172,45 @@ func (t *remoteTransportConn) Get() Transport {
 }
 
 func (t *remoteTransportConn) Send(command api.Command) api.CommandReply {
-	//resp.ParseCommand()
-	return nil
+	return send(t.conn, command)
 }
 
 func (t *remoteTransportConn) SendMany(commands ...api.Command) []api.CommandReply {
+	replies := make([]api.CommandReply, len(commands))
+	for i, command := range commands {
+		replies[i] = send(t.conn, command)
+	}
+	return replies
+}
 
-	return nil
+// Connects to another member over TLS when certificates are configured.
+func DialNet(target string) (net.Conn, error) {
+	if !certs.Default.Enabled() {
+		return net.Dial("tcp", target)
+	}
+	serverName := moved.TLSServerName
+	if serverName == "" {
+		if host, _, err := net.SplitHostPort(target); err == nil {
+			serverName = host
+		}
+	}
+	return tls.Dial("tcp", target, certs.Default.ClientConfig(serverName))
+}
+
+// Redis connection to another member made with DialNet.
+func DialRedis(target string) (redis.Conn, error) {
+	return redis.Dial("tcp", target, redis.DialNetDial(func(network, addr string) (net.Conn, error) {
+		return DialNet(addr)
+	}))
+}
+
+// Authenticates a connection to another member when the cluster has
+// credentials configured.
+func Authenticate(c redis.Conn) error {
+	user, password := moved.ClusterCredentials()
+	if password == "" {
+		return nil
+	}
+	_, err := c.Do("AUTH", user, password)
+	return err
 }
@@ -0,0 +1,201 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/record"
+	"github.com/genzai-io/sliced/app/ring"
+	"github.com/genzai-io/sliced/app/table"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+var (
+	ErrChangeRecord = errors.New("invalid change record")
+	ErrNoFeed       = errors.New("change feed topic does not exist")
+)
+
+// Durable destination of a change feed. slice.TopicPartition is one.
+// ErrNoFeed is returned while the destination does not exist.
+type Appender interface {
+	AppendRecords(records []*record.Record) error
+}
+
+// Appends every change to keys matching pattern to a topic. The record
+// LogID is the raft index of the change so consumers can resume from a
+// position. Observers run with the table lock held while the cluster log
+// entry that made the change is applied, so every node appends the same
+// records in the same order as the log and nothing is queued.
+//
+// Changes made before the topic exists are not appended. Backfill appends
+// the keys as they are when the topic is created instead. A node whose
+// append fails falls behind and backfills again once an append succeeds.
+func StartCDC(ks *table.Table, pattern string, appender Appender) *CDC {
+	feed := &CDC{
+		ks:       ks,
+		pattern:  pattern,
+		appender: appender,
+	}
+	feed.cancel = ks.Observe(pattern, feed.observe)
+	return feed
+}
+
+// A change feed started by StartCDC.
+type CDC struct {
+	ks       *table.Table
+	pattern  string
+	appender Appender
+	cancel   func()
+
+	// Log index of the first change that was not appended. Zero when
+	// every change was.
+	behind uint64
+	// Log index the next backfill of a node that is behind is tried at
+	// and the number of entries after that the one after is
+	retryAt    uint64
+	retryEvery uint64
+}
+
+// Most log entries a node that is behind waits between backfills.
+const maxRetryEvery = 1024
+
+func (f *CDC) observe(change *table.Change) {
+	if f.behind > 0 {
+		if change.Index >= f.retryAt {
+			f.backfill(change.Index)
+		}
+		return
+	}
+	err := f.appender.AppendRecords([]*record.Record{changeRecord(change)})
+	if err != nil && err != ErrNoFeed {
+		f.fail(change.Index, err)
+	}
+}
+
+// Appends a set of every key matching the pattern. The LogID of the records
+// is the index of the current log entry. Called by the cluster FSM when the
+// topic is created.
+func (f *CDC) Backfill() {
+	f.ks.View(func() error {
+		f.backfill(f.ks.CommitIndex())
+		return nil
+	})
+}
+
+// The table lock must be held.
+func (f *CDC) backfill(index uint64) {
+	var records []*record.Record
+	f.ks.AscendPrimary(func(item *table.ValueItem) bool {
+		if !item.Key.Match(f.pattern) {
+			return true
+		}
+		// Expired keys are left out
+		if _, err := f.ks.Get(item.Key); err != nil {
+			return true
+		}
+		records = append(records, changeRecord(&table.Change{
+			Kind:     table.ChangeSet,
+			Key:      item.Key,
+			Value:    item.Value,
+			Revision: item.Revision,
+			Index:    index,
+		}))
+		return true
+	})
+	if err := f.appender.AppendRecords(records); err == ErrNoFeed {
+		f.behind, f.retryAt, f.retryEvery = 0, 0, 0
+		return
+	} else if err != nil {
+		f.fail(index, err)
+		return
+	}
+	if f.behind > 0 {
+		moved.Logger.Info().
+			Uint64("from", f.behind).
+			Uint64("to", index).
+			Int("keys", len(records)).
+			Msg("change feed backfilled")
+	}
+	f.behind, f.retryAt, f.retryEvery = 0, 0, 0
+}
+
+// Marks the feed behind from index. The next backfill is tried after twice
+// as many entries as the last one up to maxRetryEvery.
+func (f *CDC) fail(index uint64, err error) {
+	if f.behind == 0 {
+		f.behind = index
+		moved.Logger.Error().
+			AnErr("err", err).
+			Uint64("from", index).
+			Msg("change feed append failed")
+	}
+	f.retryEvery *= 2
+	if f.retryEvery == 0 {
+		f.retryEvery = 1
+	} else if f.retryEvery > maxRetryEvery {
+		f.retryEvery = maxRetryEvery
+	}
+	f.retryAt = index + f.retryEvery
+}
+
+// Stops observing changes.
+func (f *CDC) Stop() {
+	f.cancel()
+}
+
+func changeRecord(change *table.Change) *record.Record {
+	return &record.Record{
+		LogID: change.Index,
+		Slot:  uint16(ring.Slot([]byte(ring.Key(fmt.Sprint(change.Key))))),
+		Data:  MarshalChange(nil, change),
+	}
+}
+
+// Changes are written as a RESP array of the event, key, revision and the
+// value for sets.
+func MarshalChange(b []byte, change *table.Change) []byte {
+	if change.Kind == table.ChangeSet {
+		b = resp.AppendArray(b, 4)
+	} else {
+		b = resp.AppendArray(b, 3)
+	}
+	b = resp.AppendBulkString(b, change.Kind.String())
+	b = resp.AppendBulkString(b, fmt.Sprint(change.Key))
+	b = resp.AppendBulkUint64(b, change.Revision)
+	if change.Kind == table.ChangeSet {
+		b = resp.AppendBulkString(b, change.Value)
+	}
+	return b
+}
+
+// Parses a change written by MarshalChange. Keys are returned as strings.
+func UnmarshalChange(b []byte) (*table.Change, error) {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != 3 && len(args) != 4 {
+		return nil, ErrChangeRecord
+	}
+
+	change := &table.Change{Key: table.StringKey(args[1])}
+	switch string(args[0]) {
+	case "set":
+		change.Kind = table.ChangeSet
+	case "del":
+		change.Kind = table.ChangeDel
+	case "expired":
+		change.Kind = table.ChangeExpire
+	default:
+		return nil, ErrChangeRecord
+	}
+	if change.Revision, err = strconv.ParseUint(string(args[2]), 10, 64); err != nil {
+		return nil, ErrChangeRecord
+	}
+	if len(args) == 4 {
+		change.Value = string(args[3])
+	}
+	return change, nil
+}
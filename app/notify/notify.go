@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/genzai-io/sliced/app/pubsub"
+	"github.com/genzai-io/sliced/app/table"
+)
+
+var (
+	ErrFlags = errors.New("invalid keyspace event flags")
+)
+
+// Classes of keyspace notifications in the style of Redis
+// notify-keyspace-events. At least one of Keyspace or Keyevent and one
+// event class must be set for anything to be published.
+type Flags uint16
+
+const (
+	// K: __keyspace@<db>__:<key> channels with the event as the message
+	Keyspace Flags = 1 << iota
+	// E: __keyevent@<db>__:<event> channels with the key as the message
+	Keyevent
+	// g: del
+	Generic
+	// $: set
+	String
+	// x: expired
+	Expired
+
+	// A: alias for "g$x"
+	All = Generic | String | Expired
+)
+
+// Parses flags like "KEA" or "Kx". An empty string disables notifications.
+func ParseFlags(s string) (Flags, error) {
+	var flags Flags
+	for _, c := range s {
+		switch c {
+		case 'K':
+			flags |= Keyspace
+		case 'E':
+			flags |= Keyevent
+		case 'g':
+			flags |= Generic
+		case '$':
+			flags |= String
+		case 'x':
+			flags |= Expired
+		case 'A':
+			flags |= All
+		default:
+			return 0, ErrFlags
+		}
+	}
+	return flags, nil
+}
+
+func (f Flags) String() string {
+	var b strings.Builder
+	if f&All == All {
+		b.WriteByte('A')
+	} else {
+		if f&Generic != 0 {
+			b.WriteByte('g')
+		}
+		if f&String != 0 {
+			b.WriteByte('$')
+		}
+		if f&Expired != 0 {
+			b.WriteByte('x')
+		}
+	}
+	if f&Keyspace != 0 {
+		b.WriteByte('K')
+	}
+	if f&Keyevent != 0 {
+		b.WriteByte('E')
+	}
+	return b.String()
+}
+
+// Whether changes of a kind are published.
+func (f Flags) Has(kind table.ChangeKind) bool {
+	if f&(Keyspace|Keyevent) == 0 {
+		return false
+	}
+	switch kind {
+	case table.ChangeSet:
+		return f&String != 0
+	case table.ChangeDel:
+		return f&Generic != 0
+	case table.ChangeExpire:
+		return f&Expired != 0
+	}
+	return false
+}
+
+// Channel of the keyspace notifications for a key.
+func KeyspaceChannel(db int, key string) string {
+	return fmt.Sprintf("__keyspace@%d__:%s", db, key)
+}
+
+// Channel of the keyevent notifications for an event.
+func KeyeventChannel(db int, event string) string {
+	return fmt.Sprintf("__keyevent@%d__:%s", db, event)
+}
+
+// Publishes the changes made to a table to the subscribers of this node.
+// Every member of the cluster applies the same changes so each one
+// publishes to it's own subscribers.
+func Start(ks *table.Table, db int, flags Flags) (stop func()) {
+	if !flags.Has(table.ChangeSet) && !flags.Has(table.ChangeDel) && !flags.Has(table.ChangeExpire) {
+		return func() {}
+	}
+
+	return ks.Observe("*", func(change *table.Change) {
+		if !flags.Has(change.Kind) {
+			return
+		}
+		key, event := fmt.Sprint(change.Key), change.Kind.String()
+		if flags&Keyspace != 0 {
+			pubsub.Default.Publish(KeyspaceChannel(db, key), event)
+		}
+		if flags&Keyevent != 0 {
+			pubsub.Default.Publish(KeyeventChannel(db, event), key)
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/genzai-io/sliced/app/pubsub"
+	"github.com/genzai-io/sliced/app/record"
+	"github.com/genzai-io/sliced/app/table"
+)
+
+func TestParseFlags(t *testing.T) {
+	flags, err := ParseFlags("KEA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags.String() != "AKE" {
+		t.Fatalf("expected AKE got %s", flags)
+	}
+	if !flags.Has(table.ChangeExpire) {
+		t.Fatal("expected expired events")
+	}
+
+	flags, _ = ParseFlags("g$")
+	if flags.Has(table.ChangeSet) {
+		t.Fatal("expected no events without K or E")
+	}
+	if _, err := ParseFlags("Kz"); err != ErrFlags {
+		t.Fatalf("expected ErrFlags got %v", err)
+	}
+}
+
+func TestStart(t *testing.T) {
+	var got [][]byte
+	s := pubsub.NewSubscriber(func(msg []byte) bool {
+		got = append(got, msg)
+		return true
+	})
+	pubsub.Default.Subscribe(s, KeyspaceChannel(0, "a"))
+	pubsub.Default.Subscribe(s, KeyeventChannel(0, "del"))
+	defer pubsub.Default.Close(s)
+
+	tbl := table.NewTable()
+	stop := Start(tbl, 0, Keyspace|Keyevent|Generic)
+	tbl.Set(table.StringKey("a"), "1", 0)
+	tbl.Delete(table.StringKey("a"))
+	stop()
+	tbl.Set(table.StringKey("a"), "1", 0)
+
+	expected := [][]byte{
+		pubsub.AppendMessage(nil, KeyspaceChannel(0, "a"), "del"),
+		pubsub.AppendMessage(nil, KeyeventChannel(0, "del"), "a"),
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d messages got %d", len(expected), len(got))
+	}
+	for i := range expected {
+		if string(got[i]) != string(expected[i]) {
+			t.Fatalf("expected %q got %q", expected[i], got[i])
+		}
+	}
+}
+
+type appender []*record.Record
+
+func (a *appender) AppendRecords(records []*record.Record) error {
+	*a = append(*a, records...)
+	return nil
+}
+
+func TestStartCDC(t *testing.T) {
+	feed := &appender{}
+	tbl := table.NewTable()
+	cdc := StartCDC(tbl, "user:*", feed)
+
+	tbl.SetCommitIndex(10)
+	tbl.Set(table.StringKey("user:1"), "a", 0)
+	tbl.SetCommitIndex(11)
+	tbl.Set(table.StringKey("order:1"), "b", 0)
+	tbl.SetCommitIndex(12)
+	tbl.Delete(table.StringKey("user:1"))
+
+	// Changes are appended as they're made
+	cdc.Stop()
+	tbl.Set(table.StringKey("user:2"), "c", 0)
+
+	if len(*feed) != 2 {
+		t.Fatalf("expected 2 records got %d", len(*feed))
+	}
+	if (*feed)[0].LogID != 10 || (*feed)[1].LogID != 12 {
+		t.Fatalf("expected log ids 10 and 12 got %d and %d", (*feed)[0].LogID, (*feed)[1].LogID)
+	}
+
+	change, err := UnmarshalChange((*feed)[0].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change.Kind != table.ChangeSet || change.Value != "a" || change.Revision == 0 {
+		t.Fatalf("unexpected change %+v", change)
+	}
+	if change, _ = UnmarshalChange((*feed)[1].Data); change.Kind != table.ChangeDel {
+		t.Fatalf("expected del got %s", change.Kind)
+	}
+}
+
+// Appender that fails until it's told to succeed.
+type failing struct {
+	appender
+	err error
+}
+
+func (a *failing) AppendRecords(records []*record.Record) error {
+	if a.err != nil {
+		return a.err
+	}
+	return a.appender.AppendRecords(records)
+}
+
+func TestCDC_Backfill(t *testing.T) {
+	feed := &failing{err: ErrNoFeed}
+	tbl := table.NewTable()
+	cdc := StartCDC(tbl, "user:*", feed)
+	defer cdc.Stop()
+
+	// Changes before the topic exists are backfilled when it's created
+	tbl.SetCommitIndex(1)
+	tbl.Set(table.StringKey("user:1"), "a", 0)
+	tbl.Set(table.StringKey("user:2"), "b", 0)
+	tbl.Set(table.StringKey("order:1"), "c", 0)
+	tbl.Delete(table.StringKey("user:2"))
+	feed.err = nil
+	tbl.SetCommitIndex(2)
+	cdc.Backfill()
+	if len(feed.appender) != 1 || feed.appender[0].LogID != 2 {
+		t.Fatalf("expected user:1 at 2 got %d records", len(feed.appender))
+	}
+	if change, _ := UnmarshalChange(feed.appender[0].Data); change.Key != table.StringKey("user:1") || change.Value != "a" {
+		t.Fatalf("unexpected change %+v", change)
+	}
+
+	// A failed append is backfilled by the next one that succeeds
+	feed.err = errors.New("disk failed")
+	tbl.SetCommitIndex(3)
+	tbl.Set(table.StringKey("user:3"), "d", 0)
+	feed.err = nil
+	tbl.SetCommitIndex(4)
+	tbl.Set(table.StringKey("user:4"), "e", 0)
+	if len(feed.appender) != 4 {
+		t.Fatalf("expected 3 keys to be backfilled got %d records", len(feed.appender)-1)
+	}
+	for _, r := range feed.appender[1:] {
+		if r.LogID != 4 {
+			t.Fatalf("expected the backfill at 4 got %d", r.LogID)
+		}
+	}
+	tbl.SetCommitIndex(5)
+	tbl.Delete(table.StringKey("user:1"))
+	if len(feed.appender) != 5 {
+		t.Fatalf("expected the feed to catch up got %d records", len(feed.appender))
+	}
+}
@@ -0,0 +1,198 @@
+package pubsub
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/genzai-io/sliced/common/match"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+// Most bytes a subscriber may have waiting to be written. Subscribers that
+// fall further behind are disconnected.
+var OutputLimit = 32 * 1024 * 1024
+
+// Global broker for the RESP connections of this node.
+var Default = NewBroker()
+
+// A connection that receives published messages. Push must not block and
+// returns false once the subscriber can no longer receive.
+type Subscriber struct {
+	push     func(b []byte) bool
+	channels map[string]struct{}
+	patterns map[string]struct{}
+}
+
+func NewSubscriber(push func(b []byte) bool) *Subscriber {
+	return &Subscriber{
+		push:     push,
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+	}
+}
+
+// Channels and patterns routes messages to subscribers. Patterns are glob
+// style and are matched against the channel of every published message.
+type Broker struct {
+	channels map[string]map[*Subscriber]struct{}
+	patterns map[string]map[*Subscriber]struct{}
+	mu       sync.RWMutex
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		channels: make(map[string]map[*Subscriber]struct{}),
+		patterns: make(map[string]map[*Subscriber]struct{}),
+	}
+}
+
+// Number of channels and patterns the subscriber is subscribed to.
+func (b *Broker) Count(s *Subscriber) int {
+	b.mu.RLock()
+	count := len(s.channels) + len(s.patterns)
+	b.mu.RUnlock()
+	return count
+}
+
+// Subscribes to a channel and returns the subscription count.
+func (b *Broker) Subscribe(s *Subscriber, channel string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	add(b.channels, s.channels, s, channel)
+	return len(s.channels) + len(s.patterns)
+}
+
+// Unsubscribes from a channel and returns the subscription count.
+func (b *Broker) Unsubscribe(s *Subscriber, channel string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	remove(b.channels, s.channels, s, channel)
+	return len(s.channels) + len(s.patterns)
+}
+
+// Subscribes to a pattern and returns the subscription count.
+func (b *Broker) PSubscribe(s *Subscriber, pattern string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	add(b.patterns, s.patterns, s, pattern)
+	return len(s.channels) + len(s.patterns)
+}
+
+// Unsubscribes from a pattern and returns the subscription count.
+func (b *Broker) PUnsubscribe(s *Subscriber, pattern string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	remove(b.patterns, s.patterns, s, pattern)
+	return len(s.channels) + len(s.patterns)
+}
+
+// Channels the subscriber is subscribed to in order.
+func (b *Broker) Channels(s *Subscriber) []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return sortedKeys(s.channels)
+}
+
+// Patterns the subscriber is subscribed to in order.
+func (b *Broker) Patterns(s *Subscriber) []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return sortedKeys(s.patterns)
+}
+
+// Removes every subscription of a closed subscriber.
+func (b *Broker) Close(s *Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for channel := range s.channels {
+		remove(b.channels, s.channels, s, channel)
+	}
+	for pattern := range s.patterns {
+		remove(b.patterns, s.patterns, s, pattern)
+	}
+}
+
+// Publishes a message to the subscribers of this node and returns the
+// number of subscribers that received it.
+func (b *Broker) Publish(channel, message string) int {
+	type delivery struct {
+		subscriber *Subscriber
+		msg        []byte
+	}
+
+	// Pushing wakes the connection and may close a slow subscriber which
+	// unsubscribes it so the receivers are copied and pushed to after the
+	// lock is released.
+	var deliveries []delivery
+	b.mu.RLock()
+	if subscribers := b.channels[channel]; len(subscribers) > 0 {
+		msg := AppendMessage(nil, channel, message)
+		for s := range subscribers {
+			deliveries = append(deliveries, delivery{s, msg})
+		}
+	}
+	for pattern, subscribers := range b.patterns {
+		if !match.Match(channel, pattern) {
+			continue
+		}
+		msg := AppendPMessage(nil, pattern, channel, message)
+		for s := range subscribers {
+			deliveries = append(deliveries, delivery{s, msg})
+		}
+	}
+	b.mu.RUnlock()
+
+	received := 0
+	for _, d := range deliveries {
+		if d.subscriber.push(d.msg) {
+			received++
+		}
+	}
+	return received
+}
+
+// Appends a "message" push reply.
+func AppendMessage(b []byte, channel, message string) []byte {
+	b = resp.AppendArray(b, 3)
+	b = resp.AppendBulkString(b, "message")
+	b = resp.AppendBulkString(b, channel)
+	return resp.AppendBulkString(b, message)
+}
+
+// Appends a "pmessage" push reply.
+func AppendPMessage(b []byte, pattern, channel, message string) []byte {
+	b = resp.AppendArray(b, 4)
+	b = resp.AppendBulkString(b, "pmessage")
+	b = resp.AppendBulkString(b, pattern)
+	b = resp.AppendBulkString(b, channel)
+	return resp.AppendBulkString(b, message)
+}
+
+func add(index map[string]map[*Subscriber]struct{}, own map[string]struct{}, s *Subscriber, name string) {
+	own[name] = struct{}{}
+	subscribers, ok := index[name]
+	if !ok {
+		subscribers = make(map[*Subscriber]struct{})
+		index[name] = subscribers
+	}
+	subscribers[s] = struct{}{}
+}
+
+func remove(index map[string]map[*Subscriber]struct{}, own map[string]struct{}, s *Subscriber, name string) {
+	delete(own, name)
+	if subscribers, ok := index[name]; ok {
+		delete(subscribers, s)
+		if len(subscribers) == 0 {
+			delete(index, name)
+		}
+	}
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
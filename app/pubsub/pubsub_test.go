@@ -0,0 +1,68 @@
+package pubsub
+
+import (
+	"testing"
+)
+
+func TestBroker(t *testing.T) {
+	b := NewBroker()
+
+	var got [][]byte
+	s := NewSubscriber(func(msg []byte) bool {
+		got = append(got, msg)
+		return true
+	})
+
+	if count := b.Subscribe(s, "orders"); count != 1 {
+		t.Fatalf("expected 1 got %d", count)
+	}
+	if count := b.PSubscribe(s, "orders.*"); count != 2 {
+		t.Fatalf("expected 2 got %d", count)
+	}
+
+	if received := b.Publish("orders", "a"); received != 1 {
+		t.Fatalf("expected 1 got %d", received)
+	}
+	if received := b.Publish("orders.eu", "b"); received != 1 {
+		t.Fatalf("expected 1 got %d", received)
+	}
+	if string(got[0]) != string(AppendMessage(nil, "orders", "a")) {
+		t.Fatalf("unexpected message %q", got[0])
+	}
+	if string(got[1]) != string(AppendPMessage(nil, "orders.*", "orders.eu", "b")) {
+		t.Fatalf("unexpected message %q", got[1])
+	}
+
+	if count := b.Unsubscribe(s, "orders"); count != 1 {
+		t.Fatalf("expected 1 got %d", count)
+	}
+	b.Close(s)
+	if count := b.Count(s); count != 0 {
+		t.Fatalf("expected 0 got %d", count)
+	}
+	if received := b.Publish("orders.eu", "c"); received != 0 {
+		t.Fatalf("expected 0 got %d", received)
+	}
+	if len(b.channels) != 0 || len(b.patterns) != 0 {
+		t.Fatal("expected empty indexes")
+	}
+}
+
+func TestBroker_PublishDrop(t *testing.T) {
+	b := NewBroker()
+
+	// A subscriber dropped while it's pushed to unsubscribes itself
+	var s *Subscriber
+	s = NewSubscriber(func(msg []byte) bool {
+		b.Close(s)
+		return false
+	})
+	b.Subscribe(s, "orders")
+
+	if received := b.Publish("orders", "a"); received != 0 {
+		t.Fatalf("expected 0 got %d", received)
+	}
+	if count := b.Count(s); count != 0 {
+		t.Fatalf("expected 0 got %d", count)
+	}
+}
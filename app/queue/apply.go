@@ -0,0 +1,255 @@
+package queue
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() {
+	api.RegisterApply(QueueCreateName, func() api.ApplyCommand { return &ApplyQueueCreate{} })
+	api.RegisterApply(QueueEnqueueName, func() api.ApplyCommand { return &ApplyQueueEnqueue{} })
+	api.RegisterApply(QueueDequeueName, func() api.ApplyCommand { return &ApplyQueueDequeue{} })
+	api.RegisterApply(QueueAckName, func() api.ApplyCommand { return &ApplyQueueAck{} })
+}
+
+const (
+	QueueCreateName  = "QUEUEDEF"
+	QueueEnqueueName = "QUEUEPUT"
+	QueueDequeueName = "QUEUEGET"
+	QueueAckName     = "QUEUEACK"
+)
+
+// Checks a new queue and returns the log entry that creates it.
+func (s *QueueStore) CreateEntry(name string) (api.ApplyCommand, error) {
+	if name == "" {
+		return nil, ErrQueueName
+	}
+	if _, ok := s.Get(name); ok {
+		return nil, ErrQueueExists
+	}
+	return &ApplyQueueCreate{Queue: name}, nil
+}
+
+// Returns the log entry that adds messages to a queue.
+func (s *QueueStore) EnqueueEntry(name string, messages []string) (api.ApplyCommand, error) {
+	if _, ok := s.Get(name); !ok {
+		return nil, ErrQueueNotFound
+	}
+	return &ApplyQueueEnqueue{Queue: name, Messages: messages}, nil
+}
+
+// Returns the log entry that delivers up to count messages of a queue and
+// hides them for visibility.
+func (s *QueueStore) DequeueEntry(name string, count int, visibility time.Duration) (api.ApplyCommand, error) {
+	if _, ok := s.Get(name); !ok {
+		return nil, ErrQueueNotFound
+	}
+	if count <= 0 || visibility <= 0 {
+		return nil, api.ErrInvalidParam
+	}
+	return &ApplyQueueDequeue{
+		Queue:      name,
+		Timestamp:  time.Now().UnixNano(),
+		Count:      count,
+		Visibility: int64(visibility),
+	}, nil
+}
+
+// Returns the log entry that acknowledges messages of a queue.
+func (s *QueueStore) AckEntry(name string, ids []uint64) (api.ApplyCommand, error) {
+	if _, ok := s.Get(name); !ok {
+		return nil, ErrQueueNotFound
+	}
+	return &ApplyQueueAck{Queue: name, IDs: ids}, nil
+}
+
+// Cluster log entry that creates a queue.
+type ApplyQueueCreate struct {
+	Queue string
+}
+
+func (c *ApplyQueueCreate) Name() string { return QueueCreateName }
+
+func (c *ApplyQueueCreate) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 2)
+	b = resp.AppendBulkString(b, c.Name())
+	return resp.AppendBulkString(b, c.Queue)
+}
+
+func (c *ApplyQueueCreate) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return api.ErrInvalidParam
+	}
+	c.Queue = string(args[1])
+	return nil
+}
+
+// Replies the ID of the queue.
+func (c *ApplyQueueCreate) Handle() api.CommandReply {
+	q, err := Queues.Create(c.Queue)
+	if err != nil {
+		return api.Err("ERR " + err.Error())
+	}
+	return api.Int(q.Model().Id)
+}
+
+// Cluster log entry that adds messages to the end of a queue.
+type ApplyQueueEnqueue struct {
+	Queue    string
+	Messages []string
+}
+
+func (c *ApplyQueueEnqueue) Name() string { return QueueEnqueueName }
+
+func (c *ApplyQueueEnqueue) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 2+len(c.Messages))
+	b = resp.AppendBulkString(b, c.Name())
+	b = resp.AppendBulkString(b, c.Queue)
+	for _, m := range c.Messages {
+		b = resp.AppendBulkString(b, m)
+	}
+	return b
+}
+
+func (c *ApplyQueueEnqueue) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) < 3 {
+		return api.ErrInvalidParam
+	}
+	c.Queue = string(args[1])
+	c.Messages = make([]string, len(args)-2)
+	for i, arg := range args[2:] {
+		c.Messages[i] = string(arg)
+	}
+	return nil
+}
+
+// Replies the ID of every message.
+func (c *ApplyQueueEnqueue) Handle() api.CommandReply {
+	q, ok := Queues.Get(c.Queue)
+	if !ok {
+		return api.Err("ERR " + ErrQueueNotFound.Error())
+	}
+	messages := make([][]byte, len(c.Messages))
+	for i, m := range c.Messages {
+		messages[i] = []byte(m)
+	}
+	ids := q.Enqueue(messages)
+	reply := make(api.Array, len(ids))
+	for i, id := range ids {
+		reply[i] = api.Int(id)
+	}
+	return reply
+}
+
+// Cluster log entry that delivers messages of a queue. The time it was
+// proposed at decides which messages are visible so every node delivers the
+// same messages.
+type ApplyQueueDequeue struct {
+	Queue      string
+	Timestamp  int64
+	Count      int
+	Visibility int64
+}
+
+func (c *ApplyQueueDequeue) Name() string { return QueueDequeueName }
+
+func (c *ApplyQueueDequeue) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 5)
+	b = resp.AppendBulkString(b, c.Name())
+	b = resp.AppendBulkString(b, c.Queue)
+	b = resp.AppendBulkInt64(b, c.Timestamp)
+	b = resp.AppendBulkInt64(b, int64(c.Count))
+	return resp.AppendBulkInt64(b, c.Visibility)
+}
+
+func (c *ApplyQueueDequeue) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) != 5 {
+		return api.ErrInvalidParam
+	}
+	if c.Timestamp, err = strconv.ParseInt(string(args[2]), 10, 64); err != nil {
+		return err
+	}
+	if c.Count, err = strconv.Atoi(string(args[3])); err != nil {
+		return err
+	}
+	if c.Visibility, err = strconv.ParseInt(string(args[4]), 10, 64); err != nil {
+		return err
+	}
+	c.Queue = string(args[1])
+	return nil
+}
+
+// Replies an [id, message] pair for every message delivered.
+func (c *ApplyQueueDequeue) Handle() api.CommandReply {
+	q, ok := Queues.Get(c.Queue)
+	if !ok {
+		return api.Err("ERR " + ErrQueueNotFound.Error())
+	}
+	messages := q.Dequeue(c.Timestamp, c.Count, c.Visibility)
+	reply := make(api.Array, len(messages))
+	for i, m := range messages {
+		reply[i] = api.Array{api.Int(m.ID), api.Bulk(m.Data)}
+	}
+	return reply
+}
+
+// Cluster log entry that removes delivered messages from a queue.
+type ApplyQueueAck struct {
+	Queue string
+	IDs   []uint64
+}
+
+func (c *ApplyQueueAck) Name() string { return QueueAckName }
+
+func (c *ApplyQueueAck) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 2+len(c.IDs))
+	b = resp.AppendBulkString(b, c.Name())
+	b = resp.AppendBulkString(b, c.Queue)
+	for _, id := range c.IDs {
+		b = resp.AppendBulkString(b, strconv.FormatUint(id, 10))
+	}
+	return b
+}
+
+func (c *ApplyQueueAck) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) < 3 {
+		return api.ErrInvalidParam
+	}
+	c.Queue = string(args[1])
+	c.IDs = make([]uint64, len(args)-2)
+	for i, arg := range args[2:] {
+		if c.IDs[i], err = strconv.ParseUint(string(arg), 10, 64); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replies the number of messages removed. Messages that were already
+// acknowledged are not counted.
+func (c *ApplyQueueAck) Handle() api.CommandReply {
+	q, ok := Queues.Get(c.Queue)
+	if !ok {
+		return api.Err("ERR " + ErrQueueNotFound.Error())
+	}
+	return api.Int(q.Ack(c.IDs))
+}
@@ -1,20 +1,97 @@
 package queue
 
-import "github.com/genzai-io/sliced/app/fs"
+import (
+	"sort"
+	"sync"
 
+	"github.com/genzai-io/sliced/proto/store"
+)
+
+// A message of a queue. A dequeued message is in flight and hidden until
+// Visible and is delivered again unless it is acknowledged before then.
+type Message struct {
+	ID         uint64
+	Data       []byte
+	Deliveries uint32
+	// Time in nanoseconds the message may be delivered again
+	Visible int64
+}
+
+// A queue of messages in the order they were enqueued. Queues are only
+// changed by the cluster log so every node holds the same messages.
 type Queue struct {
-	writer *fs.SegmentWriter
+	sync.RWMutex
+	model    store.Queue
+	nextID   uint64
+	messages []*Message
 }
 
-//type Queue struct {
-//	request *Topic
-//	reply   *Topic
-//	error   *Topic
-//}
-//
-//type QueueSlice struct {
-//	request *TopicPartition
-//	reply   *TopicPartition
-//	error   *TopicPartition
-//}
+func newQueue(model *store.Queue) *Queue {
+	return &Queue{model: *model}
+}
 
+// Copy of the definition.
+func (q *Queue) Model() store.Queue {
+	q.RLock()
+	defer q.RUnlock()
+	return q.model
+}
+
+// Number of messages that are not acknowledged.
+func (q *Queue) Len() int {
+	q.RLock()
+	defer q.RUnlock()
+	return len(q.messages)
+}
+
+// Adds messages to the end of the queue and returns the ID of each.
+func (q *Queue) Enqueue(messages [][]byte) []uint64 {
+	q.Lock()
+	defer q.Unlock()
+	ids := make([]uint64, len(messages))
+	for i, data := range messages {
+		q.nextID++
+		q.messages = append(q.messages, &Message{ID: q.nextID, Data: data})
+		ids[i] = q.nextID
+	}
+	return ids
+}
+
+// Delivers up to count messages that are visible at now and hides them
+// for visibility nanoseconds. now comes from the log entry so every node
+// delivers the same messages.
+func (q *Queue) Dequeue(now int64, count int, visibility int64) []Message {
+	q.Lock()
+	defer q.Unlock()
+	var delivered []Message
+	for _, m := range q.messages {
+		if len(delivered) == count {
+			break
+		}
+		if m.Visible > now {
+			continue
+		}
+		m.Deliveries++
+		m.Visible = now + visibility
+		delivered = append(delivered, *m)
+	}
+	return delivered
+}
+
+// Removes messages by ID and returns how many were removed.
+func (q *Queue) Ack(ids []uint64) int {
+	q.Lock()
+	defer q.Unlock()
+	acked := 0
+	for _, id := range ids {
+		i := sort.Search(len(q.messages), func(i int) bool { return q.messages[i].ID >= id })
+		if i == len(q.messages) || q.messages[i].ID != id {
+			continue
+		}
+		copy(q.messages[i:], q.messages[i+1:])
+		q.messages[len(q.messages)-1] = nil
+		q.messages = q.messages[:len(q.messages)-1]
+		acked++
+	}
+	return acked
+}
@@ -0,0 +1,173 @@
+package queue
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/genzai-io/sliced/common/resp"
+	"github.com/genzai-io/sliced/proto/store"
+)
+
+var (
+	ErrQueueExists   = errors.New("queue already exists")
+	ErrQueueNotFound = errors.New("queue not found")
+	ErrQueueName     = errors.New("queue name not set")
+
+	ErrInvalidQueueSnapshot = errors.New("invalid queue snapshot")
+)
+
+// Queues of the cluster.
+var Queues = NewQueueStore()
+
+// Every queue by name. Queues are created and their messages are enqueued,
+// dequeued and acknowledged through the cluster log so every node has the
+// same messages.
+type QueueStore struct {
+	mu     sync.RWMutex
+	nextID uint64
+	byName map[string]*Queue
+}
+
+func NewQueueStore() *QueueStore {
+	return &QueueStore{
+		byName: make(map[string]*Queue),
+	}
+}
+
+// Gets a queue by name.
+func (s *QueueStore) Get(name string) (*Queue, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	q, ok := s.byName[name]
+	return q, ok
+}
+
+// Names of every queue sorted.
+func (s *QueueStore) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.byName))
+	for name := range s.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Creates a queue and assigns it the next ID. This must only be invoked
+// from a Raft FSM so every node assigns the same IDs.
+func (s *QueueStore) Create(name string) (*Queue, error) {
+	if name == "" {
+		return nil, ErrQueueName
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.byName[name]; ok {
+		return nil, ErrQueueExists
+	}
+	s.nextID++
+	q := newQueue(&store.Queue{Id: s.nextID, Name: name})
+	s.byName[name] = q
+	return q, nil
+}
+
+// Serializes every queue and it's messages as a series of RESP arrays.
+// This is used by Raft snapshots.
+func (s *QueueStore) Marshal(b []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.byName))
+	for name := range s.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		q := s.byName[name]
+		q.RLock()
+		data, err := q.model.Marshal()
+		if err != nil {
+			q.RUnlock()
+			return nil, err
+		}
+		b = resp.AppendArray(b, 2+len(q.messages)*4)
+		b = resp.AppendBulk(b, data)
+		b = resp.AppendBulkString(b, strconv.FormatUint(q.nextID, 10))
+		for _, m := range q.messages {
+			b = resp.AppendBulkString(b, strconv.FormatUint(m.ID, 10))
+			b = resp.AppendBulkString(b, strconv.FormatUint(uint64(m.Deliveries), 10))
+			b = resp.AppendBulkString(b, strconv.FormatInt(m.Visible, 10))
+			b = resp.AppendBulk(b, m.Data)
+		}
+		q.RUnlock()
+	}
+	return b, nil
+}
+
+// Replaces every queue with the contents of Marshal.
+func (s *QueueStore) Unmarshal(b []byte) error {
+	byName := make(map[string]*Queue)
+	nextID := uint64(0)
+
+	var (
+		args     [][]byte
+		complete bool
+		err      error
+	)
+	for len(b) > 0 {
+		_, complete, args, _, b, err = resp.ParseNextCommand(b, args[:0])
+		if err != nil {
+			return err
+		}
+		if !complete || len(args) < 2 || (len(args)-2)%4 != 0 {
+			return ErrInvalidQueueSnapshot
+		}
+		q, err := unmarshalQueue(args)
+		if err != nil {
+			return err
+		}
+		byName[q.model.Name] = q
+		if q.model.Id > nextID {
+			nextID = q.model.Id
+		}
+	}
+
+	s.mu.Lock()
+	s.byName = byName
+	s.nextID = nextID
+	s.mu.Unlock()
+	return nil
+}
+
+func unmarshalQueue(args [][]byte) (*Queue, error) {
+	model := &store.Queue{}
+	if err := model.Unmarshal(args[0]); err != nil {
+		return nil, err
+	}
+	q := newQueue(model)
+	var err error
+	if q.nextID, err = strconv.ParseUint(string(args[1]), 10, 64); err != nil {
+		return nil, ErrInvalidQueueSnapshot
+	}
+	for args = args[2:]; len(args) > 0; args = args[4:] {
+		m := &Message{Data: append([]byte(nil), args[3]...)}
+		id, err := strconv.ParseUint(string(args[0]), 10, 64)
+		if err != nil {
+			return nil, ErrInvalidQueueSnapshot
+		}
+		deliveries, err := strconv.ParseUint(string(args[1]), 10, 32)
+		if err != nil {
+			return nil, ErrInvalidQueueSnapshot
+		}
+		if m.Visible, err = strconv.ParseInt(string(args[2]), 10, 64); err != nil {
+			return nil, ErrInvalidQueueSnapshot
+		}
+		m.ID, m.Deliveries = id, uint32(deliveries)
+		q.messages = append(q.messages, m)
+	}
+	return q, nil
+}
@@ -0,0 +1,99 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/proto/store"
+)
+
+func TestQueue_Dequeue(t *testing.T) {
+	q := newQueue(&store.Queue{Name: "jobs"})
+	ids := q.Enqueue([][]byte{[]byte("a"), []byte("b"), []byte("c")})
+	if len(ids) != 3 || ids[0] != 1 || ids[2] != 3 {
+		t.Fatalf("unexpected IDs %v", ids)
+	}
+
+	// Delivered messages are hidden until they are visible again
+	delivered := q.Dequeue(100, 2, 50)
+	if len(delivered) != 2 || string(delivered[0].Data) != "a" || string(delivered[1].Data) != "b" {
+		t.Fatalf("expected a and b got %v", delivered)
+	}
+	if delivered = q.Dequeue(120, 5, 50); len(delivered) != 1 || delivered[0].ID != 3 {
+		t.Fatalf("expected c got %v", delivered)
+	}
+
+	if acked := q.Ack([]uint64{1, 1, 9}); acked != 1 {
+		t.Fatalf("expected 1 message acknowledged got %d", acked)
+	}
+	delivered = q.Dequeue(150, 5, 50)
+	if len(delivered) != 1 || delivered[0].ID != 2 || delivered[0].Deliveries != 2 {
+		t.Fatalf("expected b again got %v", delivered)
+	}
+	if q.Len() != 2 {
+		t.Fatalf("expected 2 messages got %d", q.Len())
+	}
+}
+
+func TestQueueStore_Apply(t *testing.T) {
+	Queues = NewQueueStore()
+	defer func() { Queues = NewQueueStore() }()
+
+	// Every node applies the same entry
+	apply := func(entry api.ApplyCommand, err error) api.CommandReply {
+		if err != nil {
+			t.Fatal(err)
+		}
+		applied, err := api.UnmarshalApply(entry.Marshal(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return applied.Handle()
+	}
+
+	if reply := apply(Queues.CreateEntry("jobs")); reply != api.Int(1) {
+		t.Fatalf("expected ID 1 got %v", reply)
+	}
+	if _, err := Queues.CreateEntry("jobs"); err != ErrQueueExists {
+		t.Fatalf("expected ErrQueueExists got %v", err)
+	}
+	if _, err := Queues.EnqueueEntry("missing", []string{"a"}); err != ErrQueueNotFound {
+		t.Fatalf("expected ErrQueueNotFound got %v", err)
+	}
+
+	reply := apply(Queues.EnqueueEntry("jobs", []string{"a", "b"}))
+	if ids, ok := reply.(api.Array); !ok || len(ids) != 2 || ids[1] != api.Int(2) {
+		t.Fatalf("expected 2 IDs got %v", reply)
+	}
+	reply = apply(Queues.DequeueEntry("jobs", 1, time.Minute))
+	if messages, ok := reply.(api.Array); !ok || len(messages) != 1 {
+		t.Fatalf("expected 1 message got %v", reply)
+	}
+	if reply := apply(Queues.AckEntry("jobs", []uint64{2})); reply != api.Int(1) {
+		t.Fatalf("expected 1 acknowledged got %v", reply)
+	}
+
+	// Messages survive a snapshot with the delivery of each
+	b, err := Queues.Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewQueueStore()
+	if err := restored.Unmarshal(b); err != nil {
+		t.Fatal(err)
+	}
+	q, ok := restored.Get("jobs")
+	if !ok || q.Len() != 1 {
+		t.Fatal("expected queue 'jobs' with 1 message")
+	}
+	if delivered := q.Dequeue(time.Now().UnixNano(), 1, 1); len(delivered) != 0 {
+		t.Fatalf("expected the message to stay hidden got %v", delivered)
+	}
+	if ids := q.Enqueue([][]byte{[]byte("c")}); ids[0] != 3 {
+		t.Fatalf("expected ID 3 got %v", ids)
+	}
+	if created, _ := restored.Create("mail"); created.Model().Id != 2 {
+		t.Fatalf("expected ID 2 got %v", created.Model())
+	}
+}
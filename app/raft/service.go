@@ -5,12 +5,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/genzai-io/sliced"
 	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/backup"
+	"github.com/genzai-io/sliced/app/events"
 	"github.com/genzai-io/sliced/common/raft"
 	"github.com/genzai-io/sliced/common/service"
 )
@@ -37,6 +42,26 @@ var (
 	ErrNotRunning = errors.New("not running")
 )
 
+// Raft groups running on this node.
+var (
+	groupsMu sync.Mutex
+	groups   = make(map[*Service]struct{})
+)
+
+// Calls fn for every running raft group.
+func Each(fn func(rs *Service)) {
+	groupsMu.Lock()
+	list := make([]*Service, 0, len(groups))
+	for rs := range groups {
+		list = append(list, rs)
+	}
+	groupsMu.Unlock()
+
+	for _, rs := range list {
+		fn(rs)
+	}
+}
+
 type Service struct {
 	service.BaseService
 
@@ -82,6 +107,7 @@ type Service struct {
 	raft         *raft.Raft
 	snapshots    raft.SnapshotStore
 	transport    *RaftTransport
+	backup       *backup.Source
 }
 
 func NewService(dir string, databaseID int32, sliceID int32) *Service {
@@ -104,6 +130,16 @@ func NewService(dir string, databaseID int32, sliceID int32) *Service {
 	return c
 }
 
+// Database of the group or -1 for the cluster group.
+func (rs *Service) DatabaseID() int32 {
+	return rs.schemaID
+}
+
+// Slice of the group or -1 for the cluster group.
+func (rs *Service) SliceID() int32 {
+	return rs.sliceID
+}
+
 func (rs *Service) getLoggerName() string {
 	if rs.schemaID < 0 {
 		return "raft-cluster"
@@ -181,6 +217,20 @@ func (rs *Service) OnStart() error {
 	rs.goFunc(rs.observe)
 	rs.raft.RegisterObserver(rs.observer)
 
+	groupsMu.Lock()
+	groups[rs] = struct{}{}
+	groupsMu.Unlock()
+
+	if rs.dir != ":memory:" {
+		rs.backup = &backup.Source{
+			Path:    logname,
+			Prepare: rs.snapshot,
+			Save:    rs.store.Save,
+			Files:   rs.snapshotFiles,
+		}
+		backup.Register(rs.backup)
+	}
+
 	return nil
 }
 
@@ -188,6 +238,15 @@ func (rs *Service) OnStop() {
 	rs.muCluster.Lock()
 	defer rs.muCluster.Unlock()
 
+	groupsMu.Lock()
+	delete(groups, rs)
+	groupsMu.Unlock()
+
+	if rs.backup != nil {
+		backup.Unregister(rs.backup)
+		rs.backup = nil
+	}
+
 	if !rs.IsRunning() {
 		return
 	}
@@ -204,6 +263,39 @@ func (rs *Service) OnStop() {
 	}
 }
 
+// Takes a snapshot so a backup needs as little of the log as possible.
+func (rs *Service) snapshot() error {
+	if err := rs.raft.Snapshot().Error(); err != nil && err != raft.ErrNothingNewToSnapshot {
+		return err
+	}
+	return nil
+}
+
+// Files of the completed snapshots. A snapshot never changes once completed.
+func (rs *Service) snapshotFiles() ([]backup.File, error) {
+	dir := filepath.Join(rs.dir, "snapshots")
+	snapshots, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []backup.File
+	for _, snapshot := range snapshots {
+		if !snapshot.IsDir() || strings.HasSuffix(snapshot.Name(), ".tmp") {
+			continue
+		}
+		entries, err := ioutil.ReadDir(filepath.Join(dir, snapshot.Name()))
+		if err != nil {
+			// Reaped since it was listed
+			continue
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir, snapshot.Name(), entry.Name())
+			files = append(files, backup.File{Key: backup.Name(path), Path: path})
+		}
+	}
+	return files, nil
+}
+
 func (rs *Service) bootstrap() error {
 	rs.muCluster.Lock()
 	defer rs.muCluster.Unlock()
@@ -252,12 +344,14 @@ func (rs *Service) goFunc(f func()) {
 }
 
 func (rs *Service) observe() {
+	var leader raft.ServerAddress
 	for {
 		select {
 		case <-rs.ctx.Done():
 			return
 
 		case <-time.After(time.Second * 5):
+			leader = rs.publishLeader(leader)
 
 		case observation, ok := <-rs.observerCh:
 			if !ok {
@@ -271,10 +365,24 @@ func (rs *Service) observe() {
 				str = fmt.Sprintf("%s", data)
 			}
 			rs.Logger.Debug().Msgf("raft observation: %s", str)
+			leader = rs.publishLeader(leader)
 		}
 	}
 }
 
+// Publishes a new leader of the group. The raft library doesn't observe
+// leader changes so the current leader is compared with the last one.
+func (rs *Service) publishLeader(leader raft.ServerAddress) raft.ServerAddress {
+	current := rs.raft.Leader()
+	if current != leader && current != "" {
+		events.Publish(events.LeaderChanged, string(current),
+			"database", strconv.Itoa(int(rs.schemaID)),
+			"slice", strconv.Itoa(int(rs.sliceID)),
+			"term", rs.raft.Stats()["term"])
+	}
+	return current
+}
+
 //func (rs *Service) nodeRemoved(node *Node) {
 //
 //}
@@ -301,6 +409,25 @@ func (rs *Service) Vote(payload []byte) api.CommandReply {
 	return rs.transport.HandleRequestVote(payload)
 }
 
+// Appends to the log and waits for the FSM to apply it
+func (rs *Service) Apply(payload []byte) api.CommandReply {
+	// Refuse writes the log can't store rather than losing leadership
+	if err := rs.store.ReadOnly(); err != nil {
+		return api.ErrReply(err)
+	}
+	future := rs.raft.Apply(payload, moved.RaftTimeout)
+	if err := future.Error(); err != nil {
+		if err := rs.store.ReadOnly(); err != nil {
+			return api.ErrReply(err)
+		}
+		return api.Err("ERR " + err.Error())
+	}
+	if reply, ok := future.Response().(api.CommandReply); ok && reply != nil {
+		return reply
+	}
+	return api.OK
+}
+
 // Only for RaftTransport RAFTINSTALL
 func (rs *Service) Install(conn *api.Context, arg []byte) api.Command {
 	return rs.transport.HandleInstallSnapshot(conn, arg)
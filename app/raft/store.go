@@ -7,9 +7,11 @@ import (
 	"errors"
 	"io"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/genzai-io/sliced/common/diskio"
 	"github.com/genzai-io/sliced/common/raft"
 	"github.com/rs/zerolog"
 )
@@ -43,7 +45,8 @@ type LogStore struct {
 	mu         sync.RWMutex
 	path       string
 	durability Level
-	file       *os.File
+	file       diskio.File
+	guard      *diskio.Guard
 	kvm        map[string][]byte
 	lvm        map[uint64]*raft.Log
 	closed     bool
@@ -73,10 +76,11 @@ func NewLogStore(path string, durability Level, logger zerolog.Logger) (*LogStor
 	if b.persist {
 		// open file
 		var err error
-		b.file, err = os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0666)
+		b.file, err = diskio.OpenFile(path, os.O_CREATE|os.O_RDWR, 0666)
 		if err != nil {
 			return nil, err
 		}
+		b.guard = diskio.NewGuard(path)
 		// load file
 		if err := func() error {
 			start := time.Now()
@@ -199,6 +203,7 @@ func (b *LogStore) Close() error {
 		return ErrClosed
 	}
 	b.closed = true
+	b.guard.Close()
 	if b.persist {
 		b.file.Sync()
 		b.file.Close()
@@ -216,7 +221,9 @@ func (b *LogStore) run() {
 				return true
 			}
 			if b.durability == Medium && b.dirty {
-				b.file.Sync()
+				if err := b.file.Sync(); err != nil {
+					b.guard.Check(err)
+				}
 				b.dirty = false
 			}
 			shrink := (b.bsize < minShrinkSize && b.size > minShrinkSize) ||
@@ -279,7 +286,7 @@ func (b *LogStore) shrink() error {
 	b.mu.RUnlock()
 	// create the new file
 	npath := b.path + ".shrink"
-	nf, err := os.Create(npath)
+	nf, err := diskio.Create(npath)
 	if err != nil {
 		return err
 	}
@@ -356,20 +363,20 @@ func (b *LogStore) shrink() error {
 		of.Close()
 		nf.Close()
 		b.file.Close()
-		if err := os.Rename(b.path+".shrink", b.path); err != nil {
-			panic("shrink failed: " + err.Error())
-			return err
-		}
-		b.file, err = os.OpenFile(b.path, os.O_CREATE|os.O_RDWR, 0666)
+		// Keep the old file when the new one can't take its place
+		renameErr := os.Rename(b.path+".shrink", b.path)
+		b.file, err = diskio.OpenFile(b.path, os.O_CREATE|os.O_RDWR, 0666)
 		if err != nil {
-			panic("shrink failed: " + err.Error())
-			return err
+			return b.guard.Check(err)
 		}
 		size, err := b.file.Seek(0, 2)
 		if err != nil {
-			panic("shrink failed: " + err.Error())
 			return err
 		}
+		if renameErr != nil {
+			b.size = int(size)
+			return b.guard.Check(renameErr)
+		}
 		b.bsize = int(size)
 		b.size = int(size)
 		return nil
@@ -380,6 +387,39 @@ func (b *LogStore) shrink() error {
 	return nil
 }
 
+// Save writes a compacted copy of the store in the format of its file.
+// The copy is taken at a single point in time while writes continue.
+func (b *LogStore) Save(w io.Writer) error {
+	var buf []byte
+	b.mu.RLock()
+	for key, val := range b.kvm {
+		buf = bufferSet(buf, []byte(key), val)
+	}
+	// Logs are not modified once stored
+	logs := make([]*raft.Log, 0, len(b.lvm))
+	for _, log := range b.lvm {
+		logs = append(logs, log)
+	}
+	b.mu.RUnlock()
+	sort.Slice(logs, func(i, j int) bool { return logs[i].Index < logs[j].Index })
+
+	num := make([]byte, 8)
+	binary.LittleEndian.PutUint64(num, 1)
+	for _, log := range logs {
+		buf = append(buf, cmdStoreLogs)
+		buf = append(buf, num...)
+		buf = bufferLog(buf, log)
+		if len(buf) > 4*1024*1024 {
+			if _, err := w.Write(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
 func (b *LogStore) fillLimits() {
 	b.min, b.max = 0, 0
 	for idx := range b.lvm {
@@ -442,13 +482,29 @@ func (b *LogStore) StoreLog(log *raft.Log) error {
 	return b.StoreLogs([]*raft.Log{log})
 }
 
+// ReadOnly returns a *diskio.ReadOnlyError while writes are refused because
+// the disk is full or failing and nil otherwise.
+func (b *LogStore) ReadOnly() error {
+	return b.guard.Err()
+}
+
 func (b *LogStore) writeBuf() error {
-	if _, err := b.file.Write(b.buf); err != nil {
+	if err := b.guard.Err(); err != nil {
 		return err
 	}
+	if n, err := b.file.Write(b.buf); err != nil {
+		// Drop a partial write so the next one appends whole commands
+		if n > 0 {
+			b.file.Truncate(int64(b.size))
+			b.file.Seek(int64(b.size), 0)
+		}
+		return b.guard.Check(err)
+	}
 	b.size += len(b.buf)
 	if b.durability == High {
-		b.file.Sync()
+		if err := b.file.Sync(); err != nil {
+			return b.guard.Check(err)
+		}
 	} else if b.durability == Medium {
 		b.dirty = true
 	}
@@ -4,9 +4,15 @@ import (
 	"bytes"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/common/diskio"
 	"github.com/genzai-io/sliced/common/raft"
 	"github.com/genzai-io/sliced"
 )
@@ -406,3 +412,104 @@ func TestFastLogStore_SetUint64_GetUint64(t *testing.T) {
 		}
 	}
 }
+
+func TestFastLogStore_Save(t *testing.T) {
+	for p := 0; p < 2; p++ {
+		store := testFastLogStore(t, p == 1)
+		defer store.Close()
+		defer os.Remove(store.path)
+
+		logs := []*raft.Log{testRaftLog(1, "log1"), testRaftLog(2, "log2"), testRaftLog(3, "log3")}
+		if err := store.StoreLogs(logs); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := store.DeleteRange(1, 1); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := store.SetUint64([]byte("term"), 7); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		// The saved copy opens as a store with the same contents
+		fh, err := ioutil.TempFile("", "bunt")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		defer os.Remove(fh.Name())
+		if err := store.Save(fh); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		fh.Close()
+
+		saved, err := NewLogStore(fh.Name(), Medium, moved.Logger)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		defer saved.Close()
+		if idx, _ := saved.FirstIndex(); idx != 2 {
+			t.Fatalf("bad first index: %d", idx)
+		}
+		if idx, _ := saved.LastIndex(); idx != 3 {
+			t.Fatalf("bad last index: %d", idx)
+		}
+		var log raft.Log
+		if err := saved.GetLog(3, &log); err != nil || !reflect.DeepEqual(&log, logs[2]) {
+			t.Fatalf("bad log: %v %v", log, err)
+		}
+		if term, err := saved.GetUint64([]byte("term")); err != nil || term != 7 {
+			t.Fatalf("bad term: %d %v", term, err)
+		}
+	}
+}
+
+func TestFastLogStore_ReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "raft")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	interval := diskio.ProbeInterval
+	defer func() { diskio.ProbeInterval = interval }()
+	diskio.ProbeInterval = 10 * time.Millisecond
+
+	path := filepath.Join(dir, "raft.log")
+	store, err := NewLogStore(path, High, moved.Logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.StoreLog(testRaftLog(1, "log1")); err != nil {
+		t.Fatal(err)
+	}
+
+	remove := diskio.Inject(dir, diskio.OpWrite, syscall.EIO)
+	if err := store.StoreLog(testRaftLog(2, "log2")); !diskio.IsReadOnly(err) {
+		t.Fatalf("expected a read-only error got %v", err)
+	}
+	if err := store.Set([]byte("k"), []byte("v")); !diskio.IsReadOnly(err) {
+		t.Fatalf("expected a read-only error got %v", err)
+	}
+	if reply := api.ErrReply(store.ReadOnly()); !strings.HasPrefix(string(reply), "READONLY ") {
+		t.Fatalf("expected a READONLY reply got %s", reply)
+	}
+
+	remove()
+	for start := time.Now(); store.ReadOnly() != nil; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatal("expected writes to resume")
+		}
+	}
+	if err := store.StoreLog(testRaftLog(2, "log2")); err != nil {
+		t.Fatal(err)
+	}
+
+	store.Close()
+	store, err = NewLogStore(path, High, moved.Logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx, _ := store.LastIndex(); idx != 2 {
+		t.Fatalf("expected last index 2 got %d", idx)
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"net"
 	"strconv"
 	"sync"
 	"time"
@@ -18,6 +17,7 @@ import (
 	"github.com/genzai-io/sliced"
 	"github.com/genzai-io/sliced/app/api"
 	"github.com/genzai-io/sliced/app/cmd"
+	"github.com/genzai-io/sliced/app/node"
 	"github.com/genzai-io/sliced/common/evio"
 	"github.com/genzai-io/sliced/common/raft"
 	"github.com/genzai-io/sliced/common/redigo/redis"
@@ -88,10 +88,14 @@ func (t *RaftTransport) newTargetPool(target string) *redis.Pool {
 		MaxIdle:     5,           // figure 5 should suffice most clusters.
 		IdleTimeout: time.Minute, //
 		Dial: func() (redis.Conn, error) {
-			c, err := redis.Dial("tcp", target)
+			c, err := node.DialRedis(target)
 			if err != nil {
 				return nil, err
 			}
+			if err := node.Authenticate(c); err != nil {
+				c.Close()
+				return nil, err
+			}
 			// Build the connection for the correct slice
 			if t.schemaID < 0 {
 				reply, err := c.Do("RSLICE")
@@ -444,13 +448,23 @@ func (t *RaftTransport) InstallSnapshot(
 ) error {
 	// Use a dedicated connection for snapshots. This operation happens very infrequently, but when it does
 	// it often passes a lot of data.
-	conn, err := net.Dial("tcp", string(target))
+	conn, err := node.DialNet(string(target))
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
 
 	rd := bufio.NewReader(conn)
+	if user, password := moved.ClusterCredentials(); password != "" {
+		auth := buildCommand(nil, []byte("AUTH"), []byte(user), []byte(password))
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err := response(rd); err != nil {
+			return err
+		}
+	}
+
 	// use JSON encoded arguments for the initial request.
 	rdata, err := json.Marshal(args)
 	if err != nil {
@@ -748,7 +762,7 @@ func (t *RaftTransport) SetHeartbeatHandler(cb func(rpc raft.RPC)) {}
 // The nbuf is a reuseable buffer, this can be ignored.
 func Do(addr string, buf []byte, args ...[]byte) (resp []byte, nbuf []byte, err error) {
 	cmd := buildCommand(buf, args...)
-	conn, err := net.Dial("tcp", addr)
+	conn, err := node.DialNet(addr)
 	if err != nil {
 		return nil, cmd, err
 	}
@@ -0,0 +1,109 @@
+package script
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/ring"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() {
+	api.RegisterApply(EvalName, func() api.ApplyCommand { return &ApplyEval{} })
+}
+
+const EvalName = "SCRIPTEVAL"
+
+var (
+	ErrCrossSlot = errors.New("CROSSSLOT Keys in request don't hash to the same slot")
+)
+
+// Slot every key hashes to by it's hash tag. Scripts without keys map
+// to slot 0.
+func KeysSlot(keys []string) (int, error) {
+	slot := 0
+	for i, key := range keys {
+		s := ring.Slot([]byte(ring.Key(key)))
+		if i > 0 && s != slot {
+			return 0, ErrCrossSlot
+		}
+		slot = s
+	}
+	return slot, nil
+}
+
+// Cluster log entry for EVAL and EVALSHA. The script is replicated by it's
+// source and arguments and is run on every node against the keyspace.
+type ApplyEval struct {
+	Source string
+	// Instructions the script may run. Zero is the DefaultBudget.
+	Budget int
+	Keys   []string
+	Args   []string
+}
+
+func (c *ApplyEval) Name() string { return EvalName }
+
+func (c *ApplyEval) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 4+len(c.Keys)+len(c.Args))
+	b = resp.AppendBulkString(b, c.Name())
+	b = resp.AppendBulkString(b, c.Source)
+	b = resp.AppendBulkInt(b, c.Budget)
+	b = resp.AppendBulkInt(b, len(c.Keys))
+	for _, key := range c.Keys {
+		b = resp.AppendBulkString(b, key)
+	}
+	for _, arg := range c.Args {
+		b = resp.AppendBulkString(b, arg)
+	}
+	return b
+}
+
+func (c *ApplyEval) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) < 4 {
+		return api.ErrInvalidParam
+	}
+	budget, err := strconv.Atoi(string(args[2]))
+	if err != nil || budget < 0 {
+		return api.ErrInvalidParam
+	}
+	numKeys, err := strconv.Atoi(string(args[3]))
+	if err != nil || numKeys < 0 || numKeys > len(args)-4 {
+		return api.ErrInvalidParam
+	}
+
+	c.Source = string(args[1])
+	c.Budget = budget
+	c.Keys = make([]string, numKeys)
+	for i := range c.Keys {
+		c.Keys[i] = string(args[4+i])
+	}
+	c.Args = make([]string, len(args)-4-numKeys)
+	for i := range c.Args {
+		c.Args[i] = string(args[4+numKeys+i])
+	}
+	return nil
+}
+
+func (c *ApplyEval) Handle() api.CommandReply {
+	s, err := Scripts.Load(c.Source)
+	if err != nil {
+		return api.Err("ERR Error compiling script: " + err.Error())
+	}
+
+	var reply api.CommandReply
+	api.Keyspace.Update(func() error {
+		budget := c.Budget
+		if budget == 0 {
+			budget = DefaultBudget
+		}
+		reply = Run(s, api.Keyspace, budget, c.Keys, c.Args)
+		return nil
+	})
+	return reply
+}
@@ -0,0 +1,145 @@
+package script_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/genzai-io/sliced/app/api"
+	_ "github.com/genzai-io/sliced/app/cmd"
+	"github.com/genzai-io/sliced/app/script"
+	"github.com/genzai-io/sliced/app/table"
+)
+
+func run(t *testing.T, ks *table.Table, source string, keys, args []string) api.CommandReply {
+	return runBudget(t, ks, script.DefaultBudget, source, keys, args)
+}
+
+func runBudget(t *testing.T, ks *table.Table, budget int, source string, keys, args []string) api.CommandReply {
+	s, err := script.Compile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return script.Run(s, ks, budget, keys, args)
+}
+
+func TestRun(t *testing.T) {
+	ks := table.NewTable()
+
+	// Read-modify-write
+	incr := `
+local v = tonumber(sliced.call("GET", KEYS[1]) or "0")
+sliced.call("SET", KEYS[1], v + tonumber(ARGV[1]))
+return v + tonumber(ARGV[1])`
+	run(t, ks, incr, []string{"counter"}, []string{"5"})
+	if reply := run(t, ks, incr, []string{"counter"}, []string{"2"}); reply != api.Int(7) {
+		t.Fatalf("expected 7 got %v", reply)
+	}
+	if v, _ := ks.Get(table.StringKey("counter")); v != "7" {
+		t.Fatalf("expected 7 got %s", v)
+	}
+
+	reply := run(t, ks, `return {1, "a", {ok="OK"}}`, nil, nil)
+	if arr, ok := reply.(api.Array); !ok || len(arr) != 3 || arr[2] != api.SimpleString("OK") {
+		t.Fatalf("unexpected reply %v", reply)
+	}
+
+	// Errors raised by sliced.call are returned as is
+	ks.PFAdd(table.StringKey("hll"), []byte("a"))
+	if reply := run(t, ks, `return sliced.call("PFADD", "counter", "a")`, nil, nil); reply != api.Err(table.ErrWrongType.Error()) {
+		t.Fatalf("expected WRONGTYPE got %v", reply)
+	}
+	if reply := run(t, ks, `return sliced.pcall("PFADD", "counter", "a")["err"] ~= nil`, nil, nil); reply != api.Int(1) {
+		t.Fatalf("expected error table got %v", reply)
+	}
+
+	if reply := run(t, ks, `return sliced.call("SCRIPT", "FLUSH")`, nil, nil); !reply.IsError() {
+		t.Fatal("expected non keyspace command to be rejected")
+	}
+}
+
+func TestRun_Sandbox(t *testing.T) {
+	ks := table.NewTable()
+	for _, source := range []string{
+		`return os.time()`,
+		`return io.read()`,
+		`return dofile("/etc/passwd")`,
+		`return require("os")`,
+		`return math.random()`,
+	} {
+		if reply := run(t, ks, source, nil, nil); !reply.IsError() {
+			t.Fatalf("%s: expected error got %v", source, reply)
+		}
+	}
+}
+
+func TestRun_Budget(t *testing.T) {
+	budget := script.BudgetOf(5 * time.Millisecond)
+	if budget != 5*script.InstructionsPerMillisecond {
+		t.Fatalf("expected a budget of 5ms got %d", budget)
+	}
+
+	ks := table.NewTable()
+	ks.Set(table.StringKey("a"), "1", 0)
+	var changes int
+	ks.Observe("*", func(change *table.Change) { changes++ })
+
+	reply := runBudget(t, ks, budget, `sliced.call("SET", "a", "2") sliced.call("SET", "b", "2") while true do end`, nil, nil)
+	if !reply.IsError() {
+		t.Fatal("expected script to be aborted")
+	}
+
+	// Writes of a failed script are undone
+	if v, _ := ks.Get(table.StringKey("a")); v != "1" {
+		t.Fatalf("expected 1 got %s", v)
+	}
+	if _, err := ks.Get(table.StringKey("b")); err == nil {
+		t.Fatal("expected b to be removed")
+	}
+	reply = run(t, ks, `sliced.call("DEL", "a") error("failed")`, nil, nil)
+	if !reply.IsError() {
+		t.Fatal("expected script to fail")
+	}
+	if v, _ := ks.Get(table.StringKey("a")); v != "1" {
+		t.Fatalf("expected 1 got %s", v)
+	}
+	if changes != 0 {
+		t.Fatalf("expected no changes got %d", changes)
+	}
+
+	run(t, ks, `sliced.call("SET", "a", "3")`, nil, nil)
+	if changes != 1 {
+		t.Fatalf("expected 1 change got %d", changes)
+	}
+}
+
+func TestKeysSlot(t *testing.T) {
+	if _, err := script.KeysSlot([]string{"{user1}.a", "{user1}.b"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := script.KeysSlot([]string{"a", "b"}); err != script.ErrCrossSlot {
+		t.Fatalf("expected ErrCrossSlot got %v", err)
+	}
+}
+
+func TestApplyEval(t *testing.T) {
+	apply := &script.ApplyEval{
+		Source: `return sliced.call("GET", KEYS[1])`,
+		Budget: script.BudgetOf(time.Second),
+		Keys:   []string{"eval"},
+		Args:   []string{"a", "b"},
+	}
+	decoded := &script.ApplyEval{}
+	if err := decoded.Unmarshal(apply.Marshal(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Budget != apply.Budget || len(decoded.Keys) != 1 || len(decoded.Args) != 2 || decoded.Args[1] != "b" {
+		t.Fatalf("unexpected entry %+v", decoded)
+	}
+
+	// Every node stops the script at the same instruction
+	decoded.Source = `while true do end`
+	decoded.Budget = 1000
+	if reply := decoded.Handle(); !reply.IsError() {
+		t.Fatal("expected the script to run out of budget")
+	}
+}
@@ -1 +1,299 @@
 package script
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/table"
+	"github.com/yuin/gopher-lua"
+)
+
+var (
+	ErrBudget = errors.New("script exceeded the instruction budget")
+)
+
+const DefaultBudget = 10000000
+
+// Instructions the VM is taken to run in a millisecond when a timeout is
+// turned into a budget.
+const InstructionsPerMillisecond = 2000
+
+// Number of VM instructions a script may run in timeout. Scripts are run
+// inside the cluster FSM so the limit can not depend on the clock of the
+// node running them. The proposing node turns it's timeout into a budget
+// that is replicated with the script and every node spends the same
+// budget.
+func BudgetOf(timeout time.Duration) int {
+	if timeout <= 0 {
+		return DefaultBudget
+	}
+	ms := int64(timeout / time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+	if ms > math.MaxInt32/InstructionsPerMillisecond {
+		return math.MaxInt32
+	}
+	return int(ms) * InstructionsPerMillisecond
+}
+
+var exceeded = make(chan struct{})
+
+func init() {
+	close(exceeded)
+}
+
+// Context that is done once the instruction budget is spent. The VM
+// checks Done before every instruction. There is no deadline since the
+// scripts have no way to block.
+type budgetContext struct {
+	context.Context
+	remaining int
+}
+
+func (c *budgetContext) Done() <-chan struct{} {
+	c.remaining--
+	if c.remaining < 0 {
+		return exceeded
+	}
+	return c.Context.Done()
+}
+
+func (c *budgetContext) Err() error {
+	if c.remaining < 0 {
+		return ErrBudget
+	}
+	return c.Context.Err()
+}
+
+// Creates a sandboxed state. Only the base, table, string and math
+// libraries are opened and anything that reaches the filesystem, loads
+// modules or is not deterministic is removed.
+func newState() *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		fn   lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.fn))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+
+	for _, name := range []string{"dofile", "loadfile", "require", "module", "print"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+	if math, ok := L.GetGlobal(lua.MathLibName).(*lua.LTable); ok {
+		math.RawSetString("random", lua.LNil)
+		math.RawSetString("randomseed", lua.LNil)
+	}
+	return L
+}
+
+// Runs a script against the table with a budget of VM instructions. KEYS
+// and ARGV are set as globals. A script that raises an error or runs out
+// of budget leaves no writes behind. The caller must hold the table lock.
+func Run(s *Script, ks *table.Table, budget int, keys, args []string) api.CommandReply {
+	L := newState()
+	defer L.Close()
+
+	L.SetContext(&budgetContext{Context: context.Background(), remaining: budget})
+
+	L.SetGlobal("KEYS", stringTable(L, keys))
+	L.SetGlobal("ARGV", stringTable(L, args))
+
+	sliced := L.NewTable()
+	sliced.RawSetString("call", L.NewFunction(call(ks, false)))
+	sliced.RawSetString("pcall", L.NewFunction(call(ks, true)))
+	sliced.RawSetString("error_reply", L.NewFunction(errorReply))
+	sliced.RawSetString("status_reply", L.NewFunction(statusReply))
+	sliced.RawSetString("sha1hex", L.NewFunction(sha1hex))
+	L.SetGlobal("sliced", sliced)
+
+	fn, err := L.Load(strings.NewReader(s.Source), "@user_script")
+	if err != nil {
+		return api.Err("ERR Error compiling script: " + err.Error())
+	}
+	L.Push(fn)
+	ks.Begin()
+	if err := L.PCall(0, 1, nil); err != nil {
+		ks.Rollback()
+		if apiErr, ok := err.(*lua.ApiError); ok {
+			if t, ok := apiErr.Object.(*lua.LTable); ok {
+				if msg, ok := t.RawGetString("err").(lua.LString); ok {
+					return api.Err(string(msg))
+				}
+			}
+		}
+		return api.Err("ERR Error running script (" + s.SHA + "): " + err.Error())
+	}
+
+	ks.Commit()
+
+	ret := L.Get(-1)
+	L.Pop(1)
+	return toReply(ret)
+}
+
+// Parses and invokes a command against the table. Only KeyspaceCommands
+// may be called from a script.
+func Invoke(ks *table.Table, args [][]byte) api.CommandReply {
+	command, ok := api.Commands[strings.ToUpper(string(args[0]))]
+	if !ok || command == nil {
+		return api.Err("ERR Unknown sliced command called from Lua script")
+	}
+	command = command.Parse(args)
+	if command.IsError() {
+		if reply, ok := command.(api.CommandReply); ok {
+			return reply
+		}
+		return api.Err("ERR invalid command")
+	}
+	kc, ok := command.(api.KeyspaceCommand)
+	if !ok {
+		return api.Err("ERR This sliced command is not allowed from scripts")
+	}
+	return kc.Invoke(ks)
+}
+
+// sliced.call raises errors and sliced.pcall returns them as an error table.
+func call(ks *table.Table, protected bool) lua.LGFunction {
+	return func(L *lua.LState) int {
+		n := L.GetTop()
+		if n == 0 {
+			return raise(L, protected, "ERR Please specify at least one argument for sliced.call()")
+		}
+
+		args := make([][]byte, n)
+		for i := 1; i <= n; i++ {
+			switch v := L.Get(i).(type) {
+			case lua.LString:
+				args[i-1] = []byte(v)
+			case lua.LNumber:
+				args[i-1] = []byte(v.String())
+			default:
+				return raise(L, protected, "ERR Lua sliced.call() arguments must be strings or integers")
+			}
+		}
+
+		reply := Invoke(ks, args)
+		if err, ok := reply.(api.Err); ok {
+			return raise(L, protected, string(err))
+		}
+		L.Push(toLua(L, reply))
+		return 1
+	}
+}
+
+func raise(L *lua.LState, protected bool, message string) int {
+	t := L.NewTable()
+	t.RawSetString("err", lua.LString(message))
+	if protected {
+		L.Push(t)
+		return 1
+	}
+	L.Error(t, 1)
+	return 0
+}
+
+func errorReply(L *lua.LState) int {
+	t := L.NewTable()
+	t.RawSetString("err", lua.LString(L.CheckString(1)))
+	L.Push(t)
+	return 1
+}
+
+func statusReply(L *lua.LState) int {
+	t := L.NewTable()
+	t.RawSetString("ok", lua.LString(L.CheckString(1)))
+	L.Push(t)
+	return 1
+}
+
+func sha1hex(L *lua.LState) int {
+	L.Push(lua.LString(SHA1(L.CheckString(1))))
+	return 1
+}
+
+func stringTable(L *lua.LState, values []string) *lua.LTable {
+	t := L.CreateTable(len(values), 0)
+	for _, v := range values {
+		t.Append(lua.LString(v))
+	}
+	return t
+}
+
+// Converts a command reply to a Lua value.
+func toLua(L *lua.LState, reply api.CommandReply) lua.LValue {
+	switch r := reply.(type) {
+	case api.Int:
+		return lua.LNumber(r)
+	case api.Float:
+		return lua.LNumber(r)
+	case api.BulkString:
+		return lua.LString(r)
+	case api.Bulk:
+		return lua.LString(r)
+	case api.SimpleString:
+		t := L.NewTable()
+		t.RawSetString("ok", lua.LString(r))
+		return t
+	case api.Ok:
+		t := L.NewTable()
+		t.RawSetString("ok", lua.LString("OK"))
+		return t
+	case api.Err:
+		t := L.NewTable()
+		t.RawSetString("err", lua.LString(r))
+		return t
+	case api.Array:
+		t := L.CreateTable(len(r), 0)
+		for _, element := range r {
+			t.Append(toLua(L, element))
+		}
+		return t
+	}
+	return lua.LFalse
+}
+
+// Converts the value returned by a script to a command reply. Numbers are
+// truncated to integers and arrays stop at the first nil.
+func toReply(value lua.LValue) api.CommandReply {
+	switch v := value.(type) {
+	case lua.LNumber:
+		return api.Int(int64(v))
+	case lua.LString:
+		return api.BulkString(v)
+	case lua.LBool:
+		if v {
+			return api.Int(1)
+		}
+		return api.NIL
+	case *lua.LTable:
+		if msg, ok := v.RawGetString("err").(lua.LString); ok {
+			return api.Err(string(msg))
+		}
+		if msg, ok := v.RawGetString("ok").(lua.LString); ok {
+			return api.SimpleString(msg)
+		}
+		var arr api.Array
+		for i := 1; ; i++ {
+			element := v.RawGetInt(i)
+			if element == lua.LNil {
+				break
+			}
+			arr = append(arr, toReply(element))
+		}
+		return arr
+	}
+	return api.NIL
+}
@@ -0,0 +1,539 @@
+package script
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/table"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+func init() {
+	api.RegisterApply(MultiName, func() api.ApplyCommand { return &ApplyMulti{} })
+}
+
+const MultiName = "SCRIPTMULTI"
+
+var (
+	ErrMultiIfNotClosed  = errors.New("IF without END")
+	ErrMultiUnexpected   = errors.New("ELSE or END without IF")
+	ErrMultiElse         = errors.New("IF with more than one ELSE")
+	ErrMultiKeyVariable  = errors.New("keys of a conditional MULTI can not be variables")
+	ErrMultiVariable     = errors.New("invalid variable name")
+	ErrMultiCondition    = errors.New("IF expects 'operand' or 'operand op operand'")
+	ErrMultiNotSupported = errors.New("command is not allowed in a conditional MULTI")
+)
+
+// Kind of a statement in a conditional MULTI block.
+type StatementKind byte
+
+const (
+	StatementCommand StatementKind = iota
+	StatementIf
+	StatementElse
+	StatementEnd
+)
+
+// A single queued statement of a conditional MULTI block.
+//
+//	$x = GET key
+//	IF $x == nil
+//	  SET key 1
+//	ELSE
+//	  SET key $x.incr()
+//	END
+type Statement struct {
+	Kind StatementKind
+	// Variable the reply of a command is bound to
+	Var  string
+	Args []string
+}
+
+// Whether a command line uses the conditional MULTI syntax.
+func IsStatement(args [][]byte) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch strings.ToUpper(string(args[0])) {
+	case "IF", "ELSE", "END":
+		return true
+	}
+	for _, arg := range args {
+		if isReference(string(arg)) {
+			return true
+		}
+	}
+	return len(args) > 1 && string(args[1]) == "=" && isVariable(string(args[0]))
+}
+
+// Parses a single command line of a conditional MULTI block.
+func ParseStatement(args [][]byte) (Statement, error) {
+	stmt := Statement{Args: make([]string, len(args))}
+	for i, arg := range args {
+		stmt.Args[i] = string(arg)
+	}
+
+	switch strings.ToUpper(stmt.Args[0]) {
+	case "IF":
+		stmt.Kind = StatementIf
+		stmt.Args = stmt.Args[1:]
+		if len(stmt.Args) != 1 && len(stmt.Args) != 3 {
+			return stmt, ErrMultiCondition
+		}
+		if len(stmt.Args) == 3 && !isCompareOp(stmt.Args[1]) {
+			return stmt, ErrMultiCondition
+		}
+		return stmt, nil
+	case "ELSE":
+		stmt.Kind = StatementElse
+		stmt.Args = nil
+		return stmt, nil
+	case "END":
+		stmt.Kind = StatementEnd
+		stmt.Args = nil
+		return stmt, nil
+	}
+
+	if len(stmt.Args) > 1 && stmt.Args[1] == "=" {
+		if !isVariable(stmt.Args[0]) {
+			return stmt, ErrMultiVariable
+		}
+		if len(stmt.Args) < 3 {
+			return stmt, ErrMultiNotSupported
+		}
+		stmt.Var = stmt.Args[0][1:]
+		stmt.Args = stmt.Args[2:]
+	}
+
+	command, ok := api.Commands[strings.ToUpper(stmt.Args[0])]
+	if !ok || command == nil {
+		return stmt, errors.New("unknown command '" + stmt.Args[0] + "'")
+	}
+	if _, ok := command.(api.KeyspaceCommand); !ok {
+		return stmt, ErrMultiNotSupported
+	}
+	return stmt, nil
+}
+
+// Checks that every IF is closed with at most one ELSE and that every key
+// maps to the same slot so the block can run on a single slice.
+func ValidateProgram(program []Statement) error {
+	var (
+		// Whether each open IF has an ELSE
+		elses []bool
+		keys  []string
+	)
+	for _, stmt := range program {
+		switch stmt.Kind {
+		case StatementIf:
+			elses = append(elses, false)
+		case StatementElse:
+			if len(elses) == 0 {
+				return ErrMultiUnexpected
+			}
+			if elses[len(elses)-1] {
+				return ErrMultiElse
+			}
+			elses[len(elses)-1] = true
+		case StatementEnd:
+			if len(elses) == 0 {
+				return ErrMultiUnexpected
+			}
+			elses = elses[:len(elses)-1]
+		default:
+			args := make([][]byte, len(stmt.Args))
+			for i, arg := range stmt.Args {
+				args[i] = []byte(arg)
+			}
+			for _, key := range api.CommandKeys(args) {
+				if isReference(key) {
+					return ErrMultiKeyVariable
+				}
+				keys = append(keys, key)
+			}
+		}
+	}
+	if len(elses) > 0 {
+		return ErrMultiIfNotClosed
+	}
+	_, err := KeysSlot(keys)
+	return err
+}
+
+// Variable names start with a letter or underscore so literals like
+// $5 are not mistaken for variables.
+func isVariable(arg string) bool {
+	if len(arg) < 2 || arg[0] != '$' {
+		return false
+	}
+	for i, c := range arg[1:] {
+		letter := c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+		if !letter && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether the argument is $name or $name.method()
+func isReference(arg string) bool {
+	if dot := strings.IndexByte(arg, '.'); dot > -1 {
+		return isVariable(arg[:dot]) && strings.HasSuffix(arg[dot+1:], "()")
+	}
+	return isVariable(arg)
+}
+
+func isCompareOp(op string) bool {
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+// Runs a conditional MULTI block against the table. There is one reply per
+// statement. IF and ELSE reply 1 when their branch runs, commands that are
+// skipped reply nil and END replies OK. An IF whose condition fails replies
+// the error and neither of it's branches run. The caller must hold the
+// table lock.
+func RunMulti(ks *table.Table, program []Statement) api.Array {
+	type frame struct {
+		parent  bool
+		taken   bool
+		errored bool
+	}
+
+	var (
+		vars   = make(map[string]api.CommandReply)
+		stack  []frame
+		active = true
+		reply  = make(api.Array, len(program))
+	)
+
+	for i, stmt := range program {
+		switch stmt.Kind {
+		case StatementIf:
+			taken, errored := false, false
+			if active {
+				var err error
+				if taken, err = evalCondition(vars, stmt.Args); err != nil {
+					reply[i] = api.Err("ERR " + err.Error())
+					errored = true
+				}
+			}
+			stack = append(stack, frame{parent: active, taken: taken, errored: errored})
+			active = taken
+			if reply[i] == nil {
+				reply[i] = boolReply(taken)
+			}
+
+		case StatementElse:
+			f := stack[len(stack)-1]
+			active = f.parent && !f.taken && !f.errored
+			reply[i] = boolReply(active)
+
+		case StatementEnd:
+			active = stack[len(stack)-1].parent
+			stack = stack[:len(stack)-1]
+			reply[i] = api.OK
+
+		default:
+			if !active {
+				reply[i] = api.NIL
+				continue
+			}
+			args := make([][]byte, len(stmt.Args))
+			for j, arg := range stmt.Args {
+				value, err := resolve(vars, arg)
+				if err != nil {
+					reply[i] = api.Err("ERR " + err.Error())
+					break
+				}
+				args[j] = []byte(value)
+			}
+			if reply[i] == nil {
+				reply[i] = Invoke(ks, args)
+			}
+			if stmt.Var != "" {
+				vars[stmt.Var] = reply[i]
+			}
+		}
+	}
+	return reply
+}
+
+func boolReply(b bool) api.CommandReply {
+	if b {
+		return api.Int(1)
+	}
+	return api.Int(0)
+}
+
+// Resolves a variable reference. Literal arguments are returned as is.
+// Integer variables support $x.incr() and $x.decr() where nil counts as 0.
+func resolve(vars map[string]api.CommandReply, arg string) (string, error) {
+	if !isReference(arg) {
+		return arg, nil
+	}
+
+	name, method := arg[1:], ""
+	if dot := strings.IndexByte(name, '.'); dot > -1 {
+		name, method = name[:dot], name[dot+1:]
+	}
+	value, ok := vars[name]
+	if !ok {
+		return "", errors.New("undefined variable $" + name)
+	}
+
+	str, isNil := replyString(value)
+	switch method {
+	case "":
+		return str, nil
+	case "incr()", "decr()":
+		var n int64
+		if !isNil {
+			var err error
+			if n, err = strconv.ParseInt(str, 10, 64); err != nil {
+				return "", errors.New("$" + name + " is not an integer")
+			}
+		}
+		if method == "incr()" {
+			n++
+		} else {
+			n--
+		}
+		return strconv.FormatInt(n, 10), nil
+	}
+	return "", errors.New("unknown method '" + method + "' on $" + name)
+}
+
+func replyString(reply api.CommandReply) (string, bool) {
+	switch r := reply.(type) {
+	case api.Int:
+		return strconv.FormatInt(int64(r), 10), false
+	case api.Float:
+		return strconv.FormatFloat(float64(r), 'f', -1, 64), false
+	case api.BulkString:
+		return string(r), false
+	case api.Bulk:
+		return string(r), false
+	case api.SimpleString:
+		return string(r), false
+	case api.Ok:
+		return "OK", false
+	case api.Err:
+		return string(r), false
+	}
+	return "", true
+}
+
+// Evaluates 'operand' or 'operand op operand'. A single operand is true
+// unless it is nil, empty or 0. 'nil' matches a nil reply. Operands that
+// are both numbers are compared numerically.
+func evalCondition(vars map[string]api.CommandReply, args []string) (bool, error) {
+	operand := func(arg string) (string, bool, error) {
+		if arg == "nil" {
+			return "", true, nil
+		}
+		if isVariable(arg) {
+			value, ok := vars[arg[1:]]
+			if !ok {
+				return "", false, errors.New("undefined variable " + arg)
+			}
+			str, isNil := replyString(value)
+			return str, isNil, nil
+		}
+		str, err := resolve(vars, arg)
+		return str, false, err
+	}
+
+	a, aNil, err := operand(args[0])
+	if err != nil {
+		return false, err
+	}
+	if len(args) == 1 {
+		return !aNil && a != "" && a != "0", nil
+	}
+	b, bNil, err := operand(args[2])
+	if err != nil {
+		return false, err
+	}
+
+	var cmp int
+	switch {
+	case aNil || bNil:
+		if aNil == bNil {
+			cmp = 0
+		} else if aNil {
+			cmp = -1
+		} else {
+			cmp = 1
+		}
+	default:
+		af, aErr := strconv.ParseFloat(a, 64)
+		bf, bErr := strconv.ParseFloat(b, 64)
+		if aErr == nil && bErr == nil {
+			switch {
+			case af < bf:
+				cmp = -1
+			case af > bf:
+				cmp = 1
+			}
+		} else {
+			cmp = strings.Compare(a, b)
+		}
+	}
+
+	switch args[1] {
+	case "==":
+		return cmp == 0, nil
+	case "!=":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	}
+	return false, ErrMultiCondition
+}
+
+// Cluster log entry for a conditional MULTI block. The block is replicated
+// as it's statements and evaluated on every node against the keyspace.
+// Watched keys are checked first and the block is skipped with a nil reply
+// if any of them changed.
+type ApplyMulti struct {
+	Watched api.Watched
+	Program []Statement
+}
+
+func (c *ApplyMulti) Name() string { return MultiName }
+
+// Written as the number of watched keys followed by each key and revision.
+// Statements are then written as the kind, the variable and the arguments.
+func (c *ApplyMulti) Marshal(b []byte) []byte {
+	count := 2 + 2*len(c.Watched)
+	for _, stmt := range c.Program {
+		count += 3 + len(stmt.Args)
+	}
+	b = resp.AppendArray(b, count)
+	b = resp.AppendBulkString(b, c.Name())
+
+	keys := make([]string, 0, len(c.Watched))
+	for key := range c.Watched {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	b = resp.AppendBulkInt(b, len(keys))
+	for _, key := range keys {
+		b = resp.AppendBulkString(b, key)
+		b = resp.AppendBulkUint64(b, c.Watched[key])
+	}
+
+	for _, stmt := range c.Program {
+		b = resp.AppendBulkInt(b, int(stmt.Kind))
+		b = resp.AppendBulkString(b, stmt.Var)
+		b = resp.AppendBulkInt(b, len(stmt.Args))
+		for _, arg := range stmt.Args {
+			b = resp.AppendBulkString(b, arg)
+		}
+	}
+	return b
+}
+
+func (c *ApplyMulti) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return api.ErrInvalidParam
+	}
+
+	watched, err := strconv.Atoi(string(args[1]))
+	if err != nil || watched < 0 || 2*watched > len(args)-2 {
+		return api.ErrInvalidParam
+	}
+	c.Watched = nil
+	if watched > 0 {
+		c.Watched = make(api.Watched, watched)
+	}
+	for i := 0; i < watched; i++ {
+		revision, err := strconv.ParseUint(string(args[3+2*i]), 10, 64)
+		if err != nil {
+			return api.ErrInvalidParam
+		}
+		c.Watched[string(args[2+2*i])] = revision
+	}
+
+	c.Program = nil
+	for args = args[2+2*watched:]; len(args) > 0; {
+		if len(args) < 3 {
+			return api.ErrInvalidParam
+		}
+		kind, err := strconv.Atoi(string(args[0]))
+		if err != nil || kind > int(StatementEnd) {
+			return api.ErrInvalidParam
+		}
+		n, err := strconv.Atoi(string(args[2]))
+		if err != nil || n < 0 || n > len(args)-3 {
+			return api.ErrInvalidParam
+		}
+
+		stmt := Statement{
+			Kind: StatementKind(kind),
+			Var:  string(args[1]),
+			Args: make([]string, n),
+		}
+		for i := range stmt.Args {
+			stmt.Args[i] = string(args[3+i])
+		}
+		c.Program = append(c.Program, stmt)
+		args = args[3+n:]
+	}
+	return ValidateProgram(c.Program)
+}
+
+func (c *ApplyMulti) Handle() api.CommandReply {
+	var reply api.CommandReply
+	api.Keyspace.Update(func() error {
+		if c.Watched.Changed(api.Keyspace) {
+			reply = api.NIL
+			return nil
+		}
+		reply = RunMulti(api.Keyspace, c.Program)
+		return nil
+	})
+	return reply
+}
+
+// Command that proposes a MULTI block at EXEC. It's used for conditional
+// blocks and for blocks with watched keys so the check and the commands
+// are applied together.
+type MultiExec struct {
+	Watched api.Watched
+	Program []Statement
+}
+
+func (c *MultiExec) Name() string   { return "EXEC" }
+func (c *MultiExec) Help() string   { return "" }
+func (c *MultiExec) IsError() bool  { return false }
+func (c *MultiExec) IsWorker() bool { return true }
+
+func (c *MultiExec) Marshal(b []byte) []byte {
+	return (&ApplyMulti{Watched: c.Watched, Program: c.Program}).Marshal(b)
+}
+
+func (c *MultiExec) Parse(args [][]byte) api.Command {
+	return c
+}
+
+func (c *MultiExec) Handle(ctx *api.Context) api.CommandReply {
+	return api.Propose(&ApplyMulti{Watched: c.Watched, Program: c.Program})
+}
@@ -0,0 +1,219 @@
+package script_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/script"
+	"github.com/genzai-io/sliced/app/table"
+)
+
+func program(t *testing.T, lines ...string) []script.Statement {
+	result := parse(t, lines...)
+	if err := script.ValidateProgram(result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func parse(t *testing.T, lines ...string) []script.Statement {
+	var result []script.Statement
+	for _, line := range lines {
+		var args [][]byte
+		for _, arg := range strings.Fields(line) {
+			args = append(args, []byte(arg))
+		}
+		stmt, err := script.ParseStatement(args)
+		if err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		result = append(result, stmt)
+	}
+	return result
+}
+
+func TestRunMulti(t *testing.T) {
+	ks := table.NewTable()
+	incr := program(t,
+		"$x = GET counter",
+		"IF $x == nil",
+		"SET counter 1",
+		"ELSE",
+		"SET counter $x.incr()",
+		"END",
+	)
+
+	reply := script.RunMulti(ks, incr)
+	expected := api.Array{api.NIL, api.Int(1), api.OK, api.Int(0), api.NIL, api.OK}
+	for i := range expected {
+		if reply[i] != expected[i] {
+			t.Fatalf("reply[%d] expected %v got %v", i, expected[i], reply[i])
+		}
+	}
+
+	script.RunMulti(ks, incr)
+	script.RunMulti(ks, incr)
+	if v, _ := ks.Get(table.StringKey("counter")); v != "3" {
+		t.Fatalf("expected 3 got %s", v)
+	}
+
+	// Nested blocks are skipped with their parent
+	reply = script.RunMulti(ks, program(t,
+		"$x = GET counter",
+		"IF $x > 10",
+		"IF $x > 5",
+		"SET counter 0",
+		"END",
+		"ELSE",
+		"SET {counter}.big no",
+		"END",
+	))
+	if reply[3] != api.NIL || reply[6] != api.OK {
+		t.Fatalf("unexpected reply %v", reply)
+	}
+	if v, _ := ks.Get(table.StringKey("counter")); v != "3" {
+		t.Fatalf("expected 3 got %s", v)
+	}
+
+	// Literals that look like variables are left alone
+	script.RunMulti(ks, program(t, "SET price $5"))
+	if v, _ := ks.Get(table.StringKey("price")); v != "$5" {
+		t.Fatalf("expected $5 got %s", v)
+	}
+}
+
+func TestRunMulti_ConditionError(t *testing.T) {
+	ks := table.NewTable()
+	reply := script.RunMulti(ks, parse(t,
+		"IF $undefined == 1",
+		"SET then 1",
+		"ELSE",
+		"SET else 1",
+		"END",
+		"SET after 1",
+	))
+	if !reply[0].IsError() {
+		t.Fatalf("expected the condition to fail got %v", reply[0])
+	}
+	// Neither branch runs
+	expected := api.Array{reply[0], api.NIL, api.Int(0), api.NIL, api.OK, api.OK}
+	for i := range expected {
+		if reply[i] != expected[i] {
+			t.Fatalf("reply[%d] expected %v got %v", i, expected[i], reply[i])
+		}
+	}
+	for _, key := range []string{"then", "else"} {
+		if _, err := ks.Get(table.StringKey(key)); err == nil {
+			t.Fatalf("expected %s to be skipped", key)
+		}
+	}
+}
+
+func TestParseStatement(t *testing.T) {
+	for _, line := range []string{
+		"IF $x ~ 1",
+		"$1x = GET key",
+		"SCRIPT FLUSH",
+	} {
+		var args [][]byte
+		for _, arg := range strings.Fields(line) {
+			args = append(args, []byte(arg))
+		}
+		if _, err := script.ParseStatement(args); err == nil {
+			t.Fatalf("%s: expected error", line)
+		}
+	}
+
+	if err := script.ValidateProgram(parse(t, "IF $x")); err != script.ErrMultiIfNotClosed {
+		t.Fatalf("expected ErrMultiIfNotClosed got %v", err)
+	}
+	if err := script.ValidateProgram(parse(t, "IF $x", "ELSE", "ELSE", "END")); err != script.ErrMultiElse {
+		t.Fatalf("expected ErrMultiElse got %v", err)
+	}
+	if err := script.ValidateProgram(parse(t, "$x = GET a", "IF $x", "SET b 1", "END")); err != script.ErrCrossSlot {
+		t.Fatalf("expected ErrCrossSlot got %v", err)
+	}
+	if err := script.ValidateProgram(parse(t, "$x = GET {u}.a", "SET $x 1")); err != script.ErrMultiKeyVariable {
+		t.Fatalf("expected ErrMultiKeyVariable got %v", err)
+	}
+	if err := script.ValidateProgram(parse(t, "$x = GET {u}.a", "IF $x", "SET {u}.b 1", "END")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only an identifier makes an assignment
+	if script.IsStatement([][]byte{[]byte("SET"), []byte("="), []byte("x")}) {
+		t.Fatal("expected SET = x to be a command")
+	}
+	if !script.IsStatement([][]byte{[]byte("$x"), []byte("="), []byte("GET"), []byte("a")}) {
+		t.Fatal("expected an assignment")
+	}
+}
+
+func TestApplyMulti(t *testing.T) {
+	apply := &script.ApplyMulti{Program: program(t,
+		"$x = GET key",
+		"IF $x",
+		"SET key $x",
+		"END",
+	)}
+
+	decoded := &script.ApplyMulti{}
+	if err := decoded.Unmarshal(apply.Marshal(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Program) != 4 || decoded.Program[0].Var != "x" || decoded.Program[2].Args[2] != "$x" {
+		t.Fatalf("unexpected program %v", decoded.Program)
+	}
+}
+
+func TestApplyMulti_Watched(t *testing.T) {
+	api.Keyspace.Set(table.StringKey("watched"), "1", 0)
+	revision := api.Keyspace.WatchRevision(table.StringKey("watched"))
+	missing := api.Keyspace.WatchRevision(table.StringKey("missing"))
+
+	apply := &script.ApplyMulti{
+		Watched: api.Watched{"watched": revision, "missing": missing},
+		Program: program(t, "SET watched 2"),
+	}
+	decoded := &script.ApplyMulti{}
+	if err := decoded.Unmarshal(apply.Marshal(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Watched) != 2 || decoded.Watched["watched"] != revision {
+		t.Fatalf("unexpected watched keys %v", decoded.Watched)
+	}
+
+	if _, ok := decoded.Handle().(api.Array); !ok {
+		t.Fatal("expected the block to run")
+	}
+	// The block changed the key so running it again is aborted
+	if reply := decoded.Handle(); reply != api.NIL {
+		t.Fatalf("expected nil got %v", reply)
+	}
+}
+
+func TestApplyMulti_WatchedMissing(t *testing.T) {
+	key := table.StringKey("watched:missing")
+	watched := api.Watched{"watched:missing": api.Keyspace.WatchRevision(key)}
+	apply := &script.ApplyMulti{
+		Watched: watched,
+		Program: program(t, "SET other 1"),
+	}
+
+	// The key is written and deleted again before EXEC
+	api.Keyspace.Set(key, "1", 0)
+	api.Keyspace.Delete(key)
+	if !watched.Changed(api.Keyspace) {
+		t.Fatal("expected the key to be changed")
+	}
+	if reply := apply.Handle(); reply != api.NIL {
+		t.Fatalf("expected nil got %v", reply)
+	}
+
+	// Watching it after the delete runs the block
+	watched["watched:missing"] = api.Keyspace.WatchRevision(key)
+	if _, ok := apply.Handle().(api.Array); !ok {
+		t.Fatal("expected the block to run")
+	}
+}
@@ -0,0 +1,104 @@
+package script
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+var (
+	ErrNoScript = errors.New("NOSCRIPT No matching script. Please use EVAL.")
+)
+
+// Global cache of scripts by SHA1 digest.
+var Scripts = NewCache()
+
+// A Lua script and it's SHA1 digest.
+type Script struct {
+	SHA    string
+	Source string
+}
+
+// Hex encoded SHA1 digest of a script body.
+func SHA1(source string) string {
+	sum := sha1.Sum([]byte(source))
+	return hex.EncodeToString(sum[:])
+}
+
+// Compiles the source to check it for syntax errors.
+func Compile(source string) (*Script, error) {
+	sha := SHA1(source)
+	chunk, err := parse.Parse(strings.NewReader(source), "@user_script")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = lua.Compile(chunk, "@user_script"); err != nil {
+		return nil, err
+	}
+	return &Script{SHA: sha, Source: source}, nil
+}
+
+// Local cache of loaded scripts. The cache is not replicated. Scripts
+// are replicated by their source so every node that applies one adds it
+// to it's own cache.
+type Cache struct {
+	scripts map[string]*Script
+	mu      sync.RWMutex
+}
+
+func NewCache() *Cache {
+	return &Cache{
+		scripts: make(map[string]*Script),
+	}
+}
+
+// Compiles and adds a script to the cache.
+func (c *Cache) Load(source string) (*Script, error) {
+	sha := SHA1(source)
+	c.mu.RLock()
+	s, ok := c.scripts[sha]
+	c.mu.RUnlock()
+	if ok {
+		return s, nil
+	}
+
+	s, err := Compile(source)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.scripts[sha] = s
+	c.mu.Unlock()
+	return s, nil
+}
+
+// Script for a SHA1 digest.
+func (c *Cache) Get(sha string) (*Script, bool) {
+	c.mu.RLock()
+	s, ok := c.scripts[strings.ToLower(sha)]
+	c.mu.RUnlock()
+	return s, ok
+}
+
+// Whether each of the SHA1 digests is in the cache.
+func (c *Cache) Exists(shas ...string) []bool {
+	result := make([]bool, len(shas))
+	c.mu.RLock()
+	for i, sha := range shas {
+		_, result[i] = c.scripts[strings.ToLower(sha)]
+	}
+	c.mu.RUnlock()
+	return result
+}
+
+// Removes every script from the cache.
+func (c *Cache) Flush() {
+	c.mu.Lock()
+	c.scripts = make(map[string]*Script)
+	c.mu.Unlock()
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/genzai-io/sliced/app/acl"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var errInvalidToken = errors.New("invalid or expired token")
+
+// How long a token returned by Auth is accepted.
+var SessionTTL = time.Hour
+
+// Category each APIService method requires. Unknown methods require Admin
+// and zero allows anyone.
+var methodCategories = map[string]acl.Category{
+	"/api.APIService/Auth":           0,
+	"/api.APIService/Register":       acl.Admin,
+	"/api.APIService/Events":         acl.Read,
+	"/api.APIService/RegisterSchema": acl.Admin,
+	"/api.APIService/ListSchemas":    acl.Read,
+	"/api.APIService/GetSchema":      acl.Read,
+	"/api.APIService/DeleteSchema":   acl.Admin,
+}
+
+// Tokens handed out by Auth. Tokens are local to the node that created
+// them.
+type sessions struct {
+	mu     sync.Mutex
+	tokens map[string]session
+}
+
+type session struct {
+	user    string
+	expires time.Time
+}
+
+func newSessions() *sessions {
+	return &sessions{tokens: make(map[string]session)}
+}
+
+func (s *sessions) create(user string) (string, time.Time, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(b[:])
+	now := time.Now()
+	expires := now.Add(SessionTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t, existing := range s.tokens {
+		if now.After(existing.expires) {
+			delete(s.tokens, t)
+		}
+	}
+	s.tokens[token] = session{user: user, expires: expires}
+	return token, expires, nil
+}
+
+func (s *sessions) user(token string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.tokens[token]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(existing.expires) {
+		delete(s.tokens, token)
+		return "", false
+	}
+	return existing.user, true
+}
+
+// User of a call. Calls without a token use the default user as long as
+// it needs no password.
+func (s *Web) user(ctx context.Context) (*acl.User, error) {
+	name, authenticated := acl.DefaultUser, false
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, value := range md["authorization"] {
+			if !strings.HasPrefix(value, "Bearer ") {
+				continue
+			}
+			user, ok := s.sessions.user(strings.TrimPrefix(value, "Bearer "))
+			if !ok {
+				return nil, errInvalidToken
+			}
+			name, authenticated = user, true
+		}
+	}
+	return enabledUser(name, authenticated)
+}
+
+// User of an HTTP request. Requests carry a token returned by Auth or the
+// name and password of a user with basic auth. Unlike calls, requests without
+// either are rejected even when the default user needs no password.
+func (s *Web) httpUser(r *http.Request) (*acl.User, error) {
+	if name, password, ok := r.BasicAuth(); ok {
+		if _, err := acl.Default.Authenticate(name, password); err != nil {
+			return nil, err
+		}
+		return enabledUser(name, true)
+	}
+	value := r.Header.Get("Authorization")
+	if !strings.HasPrefix(value, "Bearer ") {
+		return nil, acl.ErrNoAuth
+	}
+	name, ok := s.sessions.user(strings.TrimPrefix(value, "Bearer "))
+	if !ok {
+		return nil, errInvalidToken
+	}
+	return enabledUser(name, true)
+}
+
+func enabledUser(name string, authenticated bool) (*acl.User, error) {
+	user, ok := acl.Default.Get(name)
+	if !ok || !user.Enabled || (!authenticated && !user.NoPass) {
+		return nil, acl.ErrNoAuth
+	}
+	return user, nil
+}
+
+func (s *Web) authorize(ctx context.Context, method string) error {
+	category, ok := methodCategories[method]
+	if !ok {
+		category = acl.Admin
+	}
+	if category == 0 {
+		return nil
+	}
+	user, err := s.user(ctx)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	if !user.Can(category) {
+		return status.Error(codes.PermissionDenied, acl.NoPermCommand(method[strings.LastIndex(method, "/")+1:]).Error())
+	}
+	return nil
+}
+
+func (s *Web) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.authorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Web) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authorize(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
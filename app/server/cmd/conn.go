@@ -1,9 +1,11 @@
 package cmd
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -11,7 +13,12 @@ import (
 
 	"strings"
 
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/acl"
 	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/certs"
+	"github.com/genzai-io/sliced/app/pubsub"
+	"github.com/genzai-io/sliced/app/script"
 	"github.com/genzai-io/sliced/common/evio"
 	"github.com/genzai-io/sliced/common/fastlane"
 	"github.com/genzai-io/sliced/common/resp"
@@ -19,6 +26,7 @@ import (
 
 var (
 	ErrBufferFilled = errors.New("buffer filled")
+	ErrNoClientCert = errors.New("NOPERM raft commands require a verified client certificate")
 	ErrWake         = func(err error) error {
 		return fmt.Errorf("wake error: %s", err.Error())
 	}
@@ -86,14 +94,29 @@ type Conn struct {
 	next      *cmdGroup
 	worker    cmdConnWorker
 
-	// For "multi" transactions this is registry of vars of named results.
-	// $x = GET key
-	// if $x == 0 SET key $x.incr()
-	register map[string]api.CommandReply
+	// A WATCH was parsed since the last EXEC, DISCARD or UNWATCH
+	watching bool
+
+	// Messages published to a subscribed connection waiting to be written
+	pushMu     sync.Mutex
+	pushed     []byte
+	pushClosed bool
 
 	onDetached func(rwc io.ReadWriteCloser)
 	onData     func(in []byte) (out []byte, action evio.Action)
 
+	// Handles the plaintext. It sniffs the protocol until it is known.
+	proto   func(in []byte) (out []byte, action evio.Action)
+	sniffed []byte
+
+	// Set when the connection is over TLS
+	tls *tlsConn
+
+	// Set when the connection speaks HTTP or was upgraded to a WebSocket
+	http    *httpConn
+	ws      *wsConn
+	handler http.Handler
+
 	stats connStats
 }
 
@@ -101,7 +124,11 @@ func NewConn(ev evio.Conn) *Conn {
 	conn := &Conn{
 		ev: ev,
 	}
+	if ev != nil && ev.RemoteAddr() != nil {
+		conn.Addr = ev.RemoteAddr().String()
+	}
 	conn.onData = conn.OnData
+	conn.proto = conn.OnData
 	return conn
 }
 
@@ -138,13 +165,84 @@ func (c *Conn) OnClosed() {
 	c.done = true
 	c.Action = evio.Close
 	c.ev = nil
+	if c.tls != nil {
+		c.tls.Close()
+	}
 	c.stopWorker()
+	if c.Subscriber != nil {
+		pubsub.Default.Close(c.Subscriber)
+	}
+}
+
+// Queues a published message and wakes the event-loop to write it.
+// Subscribers that have more than pubsub.OutputLimit bytes waiting are
+// disconnected. This is called from the publishing goroutine.
+func (c *Conn) push(b []byte) bool {
+	c.pushMu.Lock()
+	if c.pushClosed {
+		c.pushMu.Unlock()
+		return false
+	}
+	if len(c.pushed)+len(b) > pubsub.OutputLimit {
+		c.pushed = nil
+		c.pushClosed = true
+		c.pushMu.Unlock()
+		c.Reason = ErrBufferFilled
+		c.Close()
+		return false
+	}
+	wake := len(c.pushed) == 0
+	c.pushed = append(c.pushed, b...)
+	c.pushMu.Unlock()
+
+	// A wake is already pending otherwise
+	if wake {
+		c.wake()
+	}
+	return true
 }
 
 func (c *Conn) Conn() evio.Conn {
 	return c.ev
 }
 
+// Terminates TLS on the connection. The handshake starts right away.
+func (c *Conn) startTLS(config *tls.Config) {
+	c.tls = newTLSConn(c.ev, config)
+	c.onData = c.onTLSData
+	go c.tls.handshake()
+}
+
+func (c *Conn) onTLSData(in []byte) ([]byte, evio.Action) {
+	out, action := c.tls.data(in, c.proto)
+	// Closed before the handshake finished
+	if action == evio.None && c.Action == evio.Close {
+		action = evio.Close
+	}
+	return out, action
+}
+
+// TLS state of the connection or nil when it is not over TLS or the
+// handshake has not finished.
+func (c *Conn) TLS() *tls.ConnectionState {
+	if c.tls == nil {
+		return nil
+	}
+	return c.tls.connectionState()
+}
+
+// Raft commands need a verified client certificate when
+// tls.raft-client-auth is on.
+func (c *Conn) checkClientCert(args [][]byte) error {
+	if !moved.TLSRaftClientAuth || certs.Verified(c.TLS()) {
+		return nil
+	}
+	if api.CommandAccess(*(*string)(unsafe.Pointer(&args[0]))).Category == acl.Raft {
+		return ErrNoClientCert
+	}
+	return nil
+}
+
 func (c *Conn) Stats() {
 }
 
@@ -197,6 +295,14 @@ func (c *Conn) OnData(in []byte) ([]byte, evio.Action) {
 		}
 	}
 
+	// Flush published messages
+	c.pushMu.Lock()
+	if len(c.pushed) > 0 {
+		out = append(out, c.pushed...)
+		c.pushed = c.pushed[:0]
+	}
+	c.pushMu.Unlock()
+
 	if c.next == nil {
 		c.next = &cmdGroup{}
 	}
@@ -221,6 +327,7 @@ func (c *Conn) OnData(in []byte) ([]byte, evio.Action) {
 		for {
 			// Read next command.
 			packet, complete, args, _, input, err = resp.ParseNextCommand(input, args[:0])
+			command = nil
 
 			if err != nil {
 				c.Action = evio.Close
@@ -258,6 +365,20 @@ func (c *Conn) OnData(in []byte) ([]byte, evio.Action) {
 
 				case "exec":
 					if c.next.isMulti {
+						if c.next.conditional {
+							if err := c.next.compile(); err != nil {
+								c.next.list = append(c.next.list, api.Err("ERR "+err.Error()))
+							}
+						} else if c.watching {
+							// Check the watched keys and run the commands as
+							// a single log entry. A block that can't be one
+							// entry is aborted since checking the keys first
+							// would race with other writers.
+							if err := c.next.compile(); err != nil {
+								c.next.list = append(c.next.list, api.Err("EXECABORT Transaction discarded because of WATCH: "+err.Error()))
+							}
+						}
+						c.watching = false
 						c.backlog = append(c.backlog, c.next)
 						c.next = &cmdGroup{}
 						goto Parse
@@ -269,18 +390,70 @@ func (c *Conn) OnData(in []byte) ([]byte, evio.Action) {
 				case "discard":
 					if c.next.isMulti {
 						c.next = &cmdGroup{}
-						c.next.list = append(c.next.list, api.Ok{})
+						c.next.list = append(c.next.list, discard{})
+						c.watching = false
 						goto Parse
 					} else {
 						c.next.list = append(c.next.list, api.Err("ERR discard not expected"))
 						goto Parse
 					}
 				}
+			}
 
-			default:
-				// Do we have an expression?
-				if len(args[1]) > 0 && args[1][0] == '=' {
+			name := *(*string)(unsafe.Pointer(&args[0]))
+
+			// Conditional statements are checked by the command they run
+			statement := c.next.isMulti && script.IsStatement(args)
+			if !statement {
+				if err := c.CheckAccess(args); err != nil {
+					command = api.Err(err.Error())
+				} else if err := c.checkClientCert(args); err != nil {
+					command = api.Err(err.Error())
+				}
+			}
 
+			switch {
+			case command != nil:
+			case strings.EqualFold(name, "auth"), strings.EqualFold(name, "hello"):
+				// Authenticate right away so the commands that follow
+				// are checked against the new user.
+				if auth := api.ParseCommand(packet, args); auth != nil {
+					command = replyCommand(auth.Handle(&c.Context))
+				}
+			case strings.EqualFold(name, "watch"):
+				if c.next.isMulti {
+					command = api.Err("ERR WATCH inside MULTI is not allowed")
+				} else {
+					c.watching = true
+				}
+			case strings.EqualFold(name, "unwatch"):
+				if !c.next.isMulti {
+					c.watching = false
+				}
+			case strings.EqualFold(name, "subscribe"), strings.EqualFold(name, "psubscribe"):
+				if c.Subscriber == nil {
+					c.Subscriber = pubsub.NewSubscriber(c.push)
+				}
+			}
+
+			// Subscribed connections only accept PUB/SUB commands
+			if command == nil && c.GetKind() == api.ConnPubSub && !isPubSubCommand(name) {
+				command = api.Err("ERR only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT allowed in this context")
+			}
+
+			if c.next.isMulti {
+				c.next.statements = append(c.next.statements, copyArgs(args))
+
+				// Do we have an expression?
+				if command == nil && statement {
+					c.next.conditional = true
+					if stmt, err := script.ParseStatement(args); err != nil {
+						command = api.Err("ERR " + err.Error())
+					} else if err := c.checkStatement(stmt); err != nil {
+						command = api.Err(err.Error())
+					} else {
+						command = api.QUEUED
+					}
 				}
 			}
 
@@ -309,7 +482,9 @@ AfterParse:
 			// Let's try to save a slice append.
 			// Benchmarking revealed around 8-10% throughput increase under heavy load,
 			// so that's pretty nifty.
-			if !c.next.isWorker && len(c.backlog) == 0 {
+			// Commands must wait in the backlog while the worker owns
+			// the connection to keep them in order.
+			if ownership == loopOwner && !c.next.isWorker && len(c.backlog) == 0 {
 				out = c.execute(out, c.next)
 				c.next.clear()
 			} else {
@@ -448,12 +623,27 @@ func (c *Conn) sendQueued(out []byte, group *cmdGroup) ([]byte, bool) {
 
 func (c *Conn) execute(out []byte, group *cmdGroup) ([]byte) {
 	if group.isMulti {
+		// Watched keys only apply to the next transaction
+		watched := c.Watched
+		c.Watched = nil
+
 		var ok bool
 		out, ok = c.sendQueued(out, group)
 		if !ok {
 			return out
 		}
 
+		// Compiled blocks reply with an Array of their own
+		if group.program != nil {
+			group.program.Watched = watched
+			return c.AppendCommand(out, group.program)
+		}
+
+		// Otherwise the watched keys are checked before the commands run
+		if len(watched) > 0 && watchedChanged(watched) {
+			return resp.AppendNull(out)
+		}
+
 		// let's out as a single Array
 		out = resp.AppendArray(out, int(group.size()))
 
@@ -488,12 +678,25 @@ type cmdGroup struct {
 	isWorker bool
 	qidx     int32
 	list     []api.Command
+
+	// For "multi" transactions that bind named results or use IF/ELSE/END
+	// the whole block runs as a single program on "exec".
+	// $x = GET key
+	// IF $x == nil
+	// SET key $x.incr()
+	// END
+	conditional bool
+	statements  [][][]byte
+	program     *script.MultiExec
 }
 
 func (c *cmdGroup) clear() {
 	c.isMulti = false
 	c.isWorker = false
 	c.qidx = -1
+	c.conditional = false
+	c.statements = nil
+	c.program = nil
 	// Reset already allocated slice
 	//c.list = c.list[:0]
 	c.list = nil
@@ -502,6 +705,86 @@ func (c *cmdGroup) clear() {
 // Size of the list
 func (c *cmdGroup) size() int32 { return int32(len(c.list)) }
 
+// Compiles the statements of a "multi" into a program that runs as a
+// single cluster log entry.
+func (c *cmdGroup) compile() error {
+	program := make([]script.Statement, 0, len(c.statements))
+	for _, args := range c.statements {
+		stmt, err := script.ParseStatement(args)
+		if err != nil {
+			return err
+		}
+		program = append(program, stmt)
+	}
+	if err := script.ValidateProgram(program); err != nil {
+		return err
+	}
+	c.program = &script.MultiExec{Program: program}
+	c.isWorker = true
+	return nil
+}
+
+// Whether any watched key changed since it was watched.
+func watchedChanged(watched api.Watched) bool {
+	var changed bool
+	api.Keyspace.View(func() error {
+		changed = watched.Changed(api.Keyspace)
+		return nil
+	})
+	return changed
+}
+
+// Reply to DISCARD. Discarding a transaction also forgets watched keys.
+type discard struct {
+	api.Ok
+}
+
+func (d discard) Handle(ctx *api.Context) api.CommandReply {
+	ctx.Watched = nil
+	return api.OK
+}
+
+// Checks the command a conditional statement runs. Keys that are
+// variables are checked as is so they only pass with allkeys.
+func (c *Conn) checkStatement(stmt script.Statement) error {
+	if stmt.Kind != script.StatementCommand {
+		return nil
+	}
+	args := make([][]byte, len(stmt.Args))
+	for i, arg := range stmt.Args {
+		args[i] = []byte(arg)
+	}
+	return c.CheckAccess(args)
+}
+
+// Replies that are not commands are queued as an error.
+func replyCommand(reply api.CommandReply) api.Command {
+	// OK is a worker command but this reply is already known
+	if _, ok := reply.(api.Ok); ok {
+		return api.SimpleString("OK")
+	}
+	if command, ok := reply.(api.Command); ok {
+		return command
+	}
+	return api.Err("ERR unexpected reply")
+}
+
+func isPubSubCommand(name string) bool {
+	switch strings.ToLower(name) {
+	case "subscribe", "psubscribe", "unsubscribe", "punsubscribe", "ping", "quit":
+		return true
+	}
+	return false
+}
+
+func copyArgs(args [][]byte) [][]byte {
+	result := make([][]byte, len(args))
+	for i, arg := range args {
+		result[i] = append([]byte{}, arg...)
+	}
+	return result
+}
+
 //
 type cmdConnWorker struct {
 	wg      sync.WaitGroup
@@ -567,7 +850,9 @@ func (c *Conn) workerLoop() {
 
 			// Process the group
 			var b []byte
+			c.OnWorker = true
 			b = c.execute(b, msg)
+			c.OnWorker = false
 			msg.clear()
 
 			// Decrement count
@@ -3,12 +3,17 @@ package cmd_test
 import (
 	"fmt"
 	"sync"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/genzai-io/sliced/app/acl"
 	"github.com/genzai-io/sliced/app/api"
 	"github.com/genzai-io/sliced/app/cmd"
+	"github.com/genzai-io/sliced/app/pubsub"
+	"github.com/genzai-io/sliced/app/table"
 	"github.com/genzai-io/sliced/common/fastlane"
+	"github.com/genzai-io/sliced/common/resp"
 )
 
 func TestFastlane(t *testing.T) {
@@ -48,6 +53,9 @@ func TestCmdConnMultipleWorkers(t *testing.T) {
 	//defaultTimeout = time.Hour
 	dumpPackets = false
 
+	// GET reads the keyspace
+	api.Keyspace.Set(table.StringKey("hi"), "hello", 0)
+
 	conn := newMockConn()
 	defer conn.close()
 
@@ -185,3 +193,313 @@ func TestCmdConnMultiWorkerDiscard(t *testing.T) {
 	// Should received all replies
 	conn.ShouldCountReplies(t, len(conn.Commands()))
 }
+
+// Sends the arguments as is
+type rawCommand []string
+
+func (c rawCommand) Name() string                             { return c[0] }
+func (c rawCommand) Help() string                             { return "" }
+func (c rawCommand) IsError() bool                            { return false }
+func (c rawCommand) IsWorker() bool                           { return false }
+func (c rawCommand) Parse(args [][]byte) api.Command          { return c }
+func (c rawCommand) Handle(ctx *api.Context) api.CommandReply { return api.OK }
+func (c rawCommand) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, len(c))
+	for _, arg := range c {
+		b = resp.AppendBulkString(b, arg)
+	}
+	return b
+}
+
+//
+func TestCmdConnMultiConditional(t *testing.T) {
+	conn := newMockConn()
+	defer conn.close()
+
+	conn.
+		Send(api.BulkString("multi")).
+		ExpectOK(t).
+		Send(rawCommand{"$x", "=", "GET", "visits"}).
+		ExpectQueued(t).
+		Send(rawCommand{"IF", "$x", "==", "nil"}).
+		ExpectQueued(t).
+		Send(rawCommand{"SET", "visits", "1"}).
+		ExpectQueued(t).
+		Send(rawCommand{"ELSE"}).
+		ExpectQueued(t).
+		Send(rawCommand{"SET", "visits", "$x.incr()"}).
+		ExpectQueued(t).
+		Send(rawCommand{"END"}).
+		ExpectQueued(t).
+		Send(api.BulkString("exec")).
+		ExpectEmpty(t)
+
+	time.Sleep(time.Millisecond * 100)
+	conn.ShouldCountReplies(t, len(conn.Commands()))
+
+	if v, _ := api.Keyspace.Get(table.StringKey("visits")); v != "1" {
+		t.Fatalf("expected 1 got %s", v)
+	}
+
+	conn.
+		Send(api.BulkString("multi")).
+		ExpectOK(t).
+		Send(rawCommand{"IF", "$missing"}).
+		ExpectQueued(t).
+		Send(api.BulkString("exec")).
+		ExpectError(t)
+}
+
+//
+func TestCmdConnWatch(t *testing.T) {
+	api.Keyspace.Set(table.StringKey("balance"), "10", 0)
+
+	conn := newMockConn()
+	defer conn.close()
+
+	// Nothing touched the key so EXEC runs
+	conn.
+		Send(rawCommand{"WATCH", "balance"}).
+		ExpectOK(t).
+		Send(api.BulkString("multi")).
+		ExpectOK(t).
+		Send(rawCommand{"SET", "balance", "20"}).
+		ExpectQueued(t).
+		Send(api.BulkString("exec")).
+		ExpectEmpty(t)
+
+	time.Sleep(time.Millisecond * 100)
+	conn.ShouldCountReplies(t, len(conn.Commands()))
+	if v, _ := api.Keyspace.Get(table.StringKey("balance")); v != "20" {
+		t.Fatalf("expected 20 got %s", v)
+	}
+
+	// Another client writes the key after WATCH
+	conn.
+		Send(rawCommand{"WATCH", "balance"}).
+		ExpectOK(t)
+
+	api.Keyspace.Update(func() error {
+		api.Keyspace.Set(table.StringKey("balance"), "5", 0)
+		return nil
+	})
+
+	conn.
+		Send(api.BulkString("multi")).
+		ExpectOK(t).
+		Send(rawCommand{"SET", "balance", "30"}).
+		ExpectQueued(t).
+		Send(api.BulkString("exec")).
+		ExpectEmpty(t)
+
+	time.Sleep(time.Millisecond * 100)
+	replies := conn.Replies()
+	if len(replies) != len(conn.Commands()) {
+		t.Fatalf("expected %d replies got %d", len(conn.Commands()), len(replies))
+	}
+	if reply := replies[len(replies)-1]; reply != nil && reply != api.NIL {
+		t.Fatalf("expected nil reply got %v", reply)
+	}
+	if v, _ := api.Keyspace.Get(table.StringKey("balance")); v != "5" {
+		t.Fatalf("expected 5 got %s", v)
+	}
+
+	// EXEC forgets watched keys
+	conn.
+		Send(api.BulkString("multi")).
+		ExpectOK(t).
+		Send(rawCommand{"SET", "balance", "30"}).
+		ExpectQueued(t).
+		Send(api.BulkString("exec")).
+		ExpectEmpty(t)
+
+	time.Sleep(time.Millisecond * 100)
+	if v, _ := api.Keyspace.Get(table.StringKey("balance")); v != "30" {
+		t.Fatalf("expected 30 got %s", v)
+	}
+
+	conn.
+		Send(api.BulkString("multi")).
+		ExpectOK(t).
+		Send(rawCommand{"WATCH", "balance"}).
+		ExpectError(t)
+
+	// Blocks that can't be checked and run as one entry are aborted
+	conn.
+		Send(rawCommand{"WATCH", "balance"}).
+		ExpectOK(t).
+		Send(api.BulkString("multi")).
+		ExpectOK(t).
+		Send(rawCommand{"SET", "balance", "40"}).
+		ExpectQueued(t).
+		Send(rawCommand{"SET", "other", "40"}).
+		ExpectQueued(t)
+
+	p := conn.Send(api.BulkString("exec"))
+	if len(p.replies) != 1 {
+		t.Fatalf("expected 1 reply got %d", len(p.replies))
+	}
+	if err, ok := p.replies[0].(api.Err); !ok || !strings.HasPrefix(string(err), "EXECABORT") {
+		t.Fatalf("expected EXECABORT got %v", p.replies[0])
+	}
+	if v, _ := api.Keyspace.Get(table.StringKey("balance")); v != "30" {
+		t.Fatalf("expected 30 got %s", v)
+	}
+}
+
+//
+func TestCmdConnPubSub(t *testing.T) {
+	conn := newMockConn()
+	defer conn.close()
+
+	conn.
+		Send(rawCommand{"SUBSCRIBE", "news"}).
+		ExpectArray(t).
+		Send(rawCommand{"PSUBSCRIBE", "n*"}).
+		ExpectArray(t).
+		Send(&cmd.Get{Key: "a"}).
+		ExpectError(t)
+
+	if received := pubsub.Default.Publish("news", "hello"); received != 2 {
+		t.Fatalf("expected 2 receivers got %d", received)
+	}
+	if received := pubsub.Default.Publish("other", "hello"); received != 0 {
+		t.Fatalf("expected 0 receivers got %d", received)
+	}
+
+	time.Sleep(time.Millisecond * 50)
+	replies := conn.Replies()
+	if len(replies) != 5 {
+		t.Fatalf("expected 5 replies got %d", len(replies))
+	}
+	for i, kind := range []string{"message", "pmessage"} {
+		arr, ok := replies[3+i].(api.Array)
+		if !ok || arr[0] != api.BulkString(kind) || arr[len(arr)-1] != api.BulkString("hello") {
+			t.Fatalf("expected %s got %v", kind, replies[3+i])
+		}
+	}
+
+	// Back to normal once every subscription is gone
+	conn.
+		Send(rawCommand{"UNSUBSCRIBE"}).
+		ExpectArray(t).
+		Send(rawCommand{"PUNSUBSCRIBE"}).
+		ExpectArray(t)
+
+	if packet := conn.Send(&cmd.Get{Key: "a"}); len(packet.replies) != 1 || packet.replies[0] != nil {
+		t.Fatalf("expected nil reply got %v", packet.replies)
+	}
+
+	if received := pubsub.Default.Publish("news", "hello"); received != 0 {
+		t.Fatalf("expected 0 receivers got %d", received)
+	}
+}
+
+//
+func TestCmdConnPubSubOutputLimit(t *testing.T) {
+	limit := pubsub.OutputLimit
+	pubsub.OutputLimit = 64
+	defer func() { pubsub.OutputLimit = limit }()
+
+	conn := newMockConn()
+	defer conn.close()
+
+	conn.
+		Send(rawCommand{"SUBSCRIBE", "firehose"}).
+		ExpectArray(t)
+
+	// The subscriber is disconnected instead of buffering without bound
+	for i := 0; i < 10; i++ {
+		pubsub.Default.Publish("firehose", "0123456789")
+	}
+	if received := pubsub.Default.Publish("firehose", "0123456789"); received != 0 {
+		t.Fatalf("expected the slow subscriber to be dropped")
+	}
+}
+
+func TestCmdConnAuth(t *testing.T) {
+	if err := acl.Default.SetUser("reader", "on", ">secret", "~public:*", "+@read"); err != nil {
+		t.Fatal(err)
+	}
+	if err := acl.Default.SetUser(acl.DefaultUser, ">admin"); err != nil {
+		t.Fatal(err)
+	}
+	defer acl.Default.Unmarshal(nil)
+	api.Keyspace.Set(table.StringKey("public:a"), "hello", 0)
+
+	conn := newMockConn()
+	defer conn.close()
+
+	expectErr := func(p *packet, prefix string) {
+		t.Helper()
+		if len(p.replies) != 1 {
+			t.Fatalf("expected 1 reply got %d", len(p.replies))
+		}
+		if err, ok := p.replies[0].(api.Err); !ok || !strings.HasPrefix(string(err), prefix) {
+			t.Fatalf("expected %s got %v", prefix, p.replies[0])
+		}
+	}
+
+	expectErr(conn.Send(&cmd.Get{Key: "public:a"}), "NOAUTH")
+	expectErr(conn.Send(rawCommand{"AUTH", "reader", "wrong"}), "WRONGPASS")
+	conn.Send(rawCommand{"AUTH", "reader", "secret"}).ExpectOK(t)
+
+	conn.Send(&cmd.Get{Key: "public:a"}).ExpectBulk(t)
+	expectErr(conn.Send(&cmd.Get{Key: "private:a"}), "NOPERM")
+	expectErr(conn.Send(rawCommand{"SET", "public:a", "1"}), "NOPERM")
+	expectErr(conn.Send(rawCommand{"REMOVE", "127.0.0.1:9002"}), "NOPERM")
+
+	// Commands queued in a transaction are checked as they are queued
+	conn.
+		Send(api.BulkString("multi")).
+		ExpectOK(t).
+		Send(rawCommand{"$x", "=", "GET", "private:a"}).
+		ExpectError(t)
+
+	// The password of the default user also unlocks it
+	conn.Send(rawCommand{"AUTH", "admin"}).ExpectOK(t)
+	conn.Send(rawCommand{"ACL", "WHOAMI"}).ExpectBulk(t)
+}
+
+func TestCmdConnTopicAccess(t *testing.T) {
+	if err := acl.Default.SetUser("writer", "on", ">secret", "&orders.*", "+@read", "+@write"); err != nil {
+		t.Fatal(err)
+	}
+	defer acl.Default.Unmarshal(nil)
+
+	conn := newMockConn()
+	defer conn.close()
+	conn.Send(rawCommand{"AUTH", "writer", "secret"}).ExpectOK(t)
+
+	// Topic and queue names are checked against the channels of the user.
+	// Creating topics and queues and changing settings, schemas and indexes
+	// is Admin.
+	commands := []struct {
+		args   rawCommand
+		denied bool
+	}{
+		{rawCommand{"TOPIC", "APPEND", "orders.eu", "{}"}, false},
+		{rawCommand{"TOPIC", "APPEND", "users", "{}"}, true},
+		{rawCommand{"TOPIC", "LIST"}, false},
+		{rawCommand{"TOPIC", "CREATE", "orders.us"}, true},
+		{rawCommand{"CONFIG", "GET", "*"}, true},
+		{rawCommand{"SCHEMADEL", "pkg", "1"}, true},
+		{rawCommand{"CREATEINDEX", "idx", "orders:*", "JSON", "id"}, true},
+		{rawCommand{"QUEUE", "ENQUEUE", "orders.jobs", "{}"}, false},
+		{rawCommand{"QUEUE", "ACK", "users", "1"}, true},
+		{rawCommand{"QUEUE", "CREATE", "orders.jobs"}, true},
+	}
+	for _, c := range commands {
+		conn.Send(c.args)
+	}
+	replies, err := conn.WaitForReplies(1, len(commands), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range commands {
+		err, _ := replies[i].(api.Err)
+		if denied := strings.HasPrefix(string(err), "NOPERM"); denied != c.denied {
+			t.Fatalf("%v: expected denied %v got %v", c.args, c.denied, replies[i])
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/common/evio"
+)
+
+var (
+	errHeaderTooLarge = errors.New("request header too large")
+	errBodyTooLarge   = errors.New("request body too large")
+	errLengthRequired = errors.New("length required")
+)
+
+const (
+	// Longest first line that is checked for an HTTP request line. Longer
+	// lines are RESP.
+	maxRequestLine = 8 * 1024
+	maxHeaderBytes = 1 << 20
+	maxBodyBytes   = 32 << 20
+)
+
+// Decides the protocol from the first line of a connection. RESP arrays
+// start with '*' and anything that is not an HTTP/1.x request line is left
+// to RESP to reject.
+func (c *Conn) sniff(in []byte) ([]byte, evio.Action) {
+	c.sniffed = append(c.sniffed, in...)
+	if c.Action == evio.Close {
+		return nil, evio.Close
+	}
+	if len(c.sniffed) == 0 {
+		return nil, evio.None
+	}
+
+	kind := api.ConnCommand
+	if c.sniffed[0] != '*' {
+		i := bytes.IndexByte(c.sniffed, '\n')
+		if i == -1 {
+			if len(c.sniffed) <= maxRequestLine {
+				return nil, evio.None
+			}
+		} else if isRequestLine(c.sniffed[:i]) {
+			kind = api.ConnHTTP
+		}
+	}
+
+	in, c.sniffed = c.sniffed, nil
+	if kind == api.ConnHTTP {
+		c.SetKind(api.ConnHTTP)
+		c.http = &httpConn{}
+		c.setProtocol(c.onHTTPData)
+	} else {
+		c.setProtocol(c.OnData)
+	}
+	return c.proto(in)
+}
+
+// Sets the handler of the plaintext.
+func (c *Conn) setProtocol(proto func(in []byte) ([]byte, evio.Action)) {
+	c.proto = proto
+	if c.tls == nil {
+		c.onData = proto
+	}
+}
+
+// Whether the line is "METHOD target HTTP/1.x".
+func isRequestLine(line []byte) bool {
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+	fields := bytes.Split(line, []byte{' '})
+	if len(fields) != 3 || len(fields[0]) == 0 || len(fields[1]) == 0 {
+		return false
+	}
+	for _, b := range fields[0] {
+		if b < 'A' || b > 'Z' {
+			return false
+		}
+	}
+	return bytes.HasPrefix(fields[2], []byte("HTTP/1."))
+}
+
+// HTTP/1.1 served on an event loop connection. Requests are parsed on the
+// event loop and served one at a time by the handler on it's own
+// goroutine. Responses are written by waking the event loop.
+type httpConn struct {
+	in   []byte // Input not parsed yet
+	busy bool   // A request is being served
+
+	mu    sync.Mutex
+	out   []byte
+	done  bool // The request being served finished
+	close bool // Close once the response is written
+}
+
+func (h *httpConn) write(b []byte) {
+	h.mu.Lock()
+	h.out = append(h.out, b...)
+	h.mu.Unlock()
+}
+
+func (c *Conn) onHTTPData(in []byte) ([]byte, evio.Action) {
+	h := c.http
+	h.in = append(h.in, in...)
+
+	h.mu.Lock()
+	out := h.out
+	h.out = nil
+	if h.done {
+		h.busy, h.done = false, false
+		if h.close {
+			h.mu.Unlock()
+			return out, evio.Close
+		}
+	}
+	h.mu.Unlock()
+
+	if c.Action == evio.Close {
+		return out, evio.Close
+	}
+	if h.busy || len(h.in) == 0 {
+		return out, evio.None
+	}
+
+	req, n, err := parseRequest(h.in)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch err {
+		case errHeaderTooLarge:
+			status = http.StatusRequestHeaderFieldsTooLarge
+		case errBodyTooLarge:
+			status = http.StatusRequestEntityTooLarge
+		case errLengthRequired:
+			status = http.StatusLengthRequired
+		}
+		out = append(out, fmt.Sprintf("HTTP/1.1 %d %s\r\nConnection: close\r\nContent-Length: 0\r\n\r\n", status, http.StatusText(status))...)
+		return out, evio.Close
+	}
+	if req == nil {
+		return out, evio.None
+	}
+	h.in = h.in[n:]
+	if ev := c.ev; ev != nil {
+		req.RemoteAddr = ev.RemoteAddr().String()
+	}
+	if c.tls != nil {
+		req.TLS = c.TLS()
+	}
+
+	if isWebSocketUpgrade(req) {
+		out = append(out, upgradeResponse(req)...)
+		rest := h.in
+		c.http = nil
+		c.SetKind(api.ConnWebSocket)
+		c.ws = &wsConn{}
+		c.setProtocol(c.onWebSocketData)
+		more, action := c.proto(rest)
+		return append(out, more...), action
+	}
+
+	h.busy = true
+	go c.serveHTTP(req)
+	return out, evio.None
+}
+
+// Parses the next request. A nil request means more input is needed.
+func parseRequest(b []byte) (*http.Request, int, error) {
+	end := bytes.Index(b, []byte("\r\n\r\n"))
+	if end == -1 {
+		if len(b) > maxHeaderBytes {
+			return nil, 0, errHeaderTooLarge
+		}
+		return nil, 0, nil
+	}
+	end += 4
+
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(b[:end])))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(req.TransferEncoding) > 0 {
+		return nil, 0, errLengthRequired
+	}
+	if req.ContentLength > maxBodyBytes {
+		return nil, 0, errBodyTooLarge
+	}
+	n := end + int(req.ContentLength)
+	if len(b) < n {
+		return nil, 0, nil
+	}
+
+	// The handler runs after the input buffer is reused
+	data := append([]byte{}, b[:n]...)
+	req, err = http.ReadRequest(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return nil, 0, err
+	}
+	return req, n, nil
+}
+
+func (c *Conn) serveHTTP(req *http.Request) {
+	w := &httpResponse{conn: c, req: req, header: make(http.Header)}
+	defer func() {
+		if r := recover(); r != nil && !w.sent {
+			w.status = http.StatusInternalServerError
+			w.body = w.body[:0]
+		}
+		w.finish()
+
+		h := c.http
+		h.mu.Lock()
+		h.done = true
+		h.close = w.close
+		h.mu.Unlock()
+		c.wake()
+	}()
+
+	handler := c.handler
+	if handler == nil {
+		handler = http.NotFoundHandler()
+	}
+	handler.ServeHTTP(w, req)
+}
+
+// Buffers the response unless the handler flushes. A flushed response is
+// sent with chunked encoding.
+type httpResponse struct {
+	conn   *Conn
+	req    *http.Request
+	header http.Header
+	status int
+	body   []byte
+	sent   bool // The header was written
+	close  bool
+}
+
+func (w *httpResponse) Header() http.Header { return w.header }
+
+func (w *httpResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *httpResponse) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.req.Method == http.MethodHead {
+		return len(b), nil
+	}
+	if !w.sent {
+		w.body = append(w.body, b...)
+		return len(b), nil
+	}
+	w.chunk(b)
+	return len(b), nil
+}
+
+// Flush implements http.Flusher for streaming responses.
+func (w *httpResponse) Flush() {
+	w.WriteHeader(http.StatusOK)
+	if !w.sent {
+		w.header.Del("Content-Length")
+		w.header.Set("Transfer-Encoding", "chunked")
+		w.writeHeader()
+		body := w.body
+		w.body = nil
+		w.chunk(body)
+	}
+	w.conn.wake()
+}
+
+func (w *httpResponse) chunk(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	buf := append([]byte(strconv.FormatInt(int64(len(b)), 16)), "\r\n"...)
+	buf = append(buf, b...)
+	w.conn.http.write(append(buf, "\r\n"...))
+}
+
+func (w *httpResponse) writeHeader() {
+	w.sent = true
+	w.close = w.req.Close || w.header.Get("Connection") == "close"
+	if w.close {
+		w.header.Set("Connection", "close")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "HTTP/1.1 %d %s\r\n", w.status, http.StatusText(w.status))
+	w.header.Write(&buf)
+	buf.WriteString("\r\n")
+	w.conn.http.write(buf.Bytes())
+}
+
+func (w *httpResponse) finish() {
+	w.WriteHeader(http.StatusOK)
+	if w.sent {
+		w.conn.http.write([]byte("0\r\n\r\n"))
+		return
+	}
+	if w.header.Get("Content-Type") == "" && len(w.body) > 0 {
+		w.header.Set("Content-Type", http.DetectContentType(w.body))
+	}
+	if w.status != http.StatusNoContent && w.status != http.StatusNotModified {
+		w.header.Set("Content-Length", strconv.Itoa(len(w.body)))
+	}
+	w.writeHeader()
+	w.conn.http.write(w.body)
+}
+
+func headerContains(header http.Header, name, value string) bool {
+	for _, v := range header[name] {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
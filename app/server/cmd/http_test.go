@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/pubsub"
+	"github.com/genzai-io/sliced/app/table"
+)
+
+func serveSniffed(t *testing.T, handler http.Handler) string {
+	return serveConn(t, func(conn *Conn) {
+		conn.handler = handler
+		conn.setProtocol(conn.sniff)
+	})
+}
+
+func TestIsRequestLine(t *testing.T) {
+	for line, expected := range map[string]bool{
+		"GET / HTTP/1.1\r":       true,
+		"POST /api HTTP/1.0":     true,
+		"GET key":                false,
+		"SET key HTTP/1.1 value": false,
+		"get / HTTP/1.1":         false,
+		"GET / HTTP/2.0":         false,
+		"PING":                   false,
+	} {
+		if isRequestLine([]byte(line)) != expected {
+			t.Fatalf("expected %v for %q", expected, line)
+		}
+	}
+}
+
+func TestSniffRESP(t *testing.T) {
+	api.Keyspace.Set(table.StringKey("sniff"), "hello", 0)
+
+	conn, err := net.Dial("tcp", serveSniffed(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	if reply := request(t, conn, rd, "GET", "sniff"); reply != "hello" {
+		t.Fatalf("expected hello got %s", reply)
+	}
+
+	// Lines that are not HTTP are left to RESP
+	conn, err = net.Dial("tcp", serveSniffed(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET sniff\r\n"))
+	if line, _ := bufio.NewReader(conn).ReadString('\n'); !strings.HasPrefix(line, "-") {
+		t.Fatalf("expected a RESP error got %q", line)
+	}
+}
+
+func TestSniffHTTP(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stream":
+			for i := 0; i < 3; i++ {
+				io.WriteString(w, "chunk\n")
+				w.(http.Flusher).Flush()
+			}
+		default:
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "text/plain")
+			io.WriteString(w, r.Method+" "+r.URL.Path+" "+string(body))
+		}
+	})
+
+	addr := serveSniffed(t, handler)
+	client := &http.Client{Transport: &http.Transport{
+		// Every request goes over the single served connection
+		MaxIdleConnsPerHost: 1,
+	}}
+
+	for _, path := range []string{"/a", "/b"} {
+		res, err := client.Post("http://"+addr+path, "text/plain", strings.NewReader("body"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if string(body) != "POST "+path+" body" {
+			t.Fatalf("unexpected body %q", body)
+		}
+	}
+
+	res, err := client.Get("http://" + addr + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != strings.Repeat("chunk\n", 3) || len(res.TransferEncoding) == 0 {
+		t.Fatalf("unexpected streamed body %q", body)
+	}
+}
+
+// Writes a masked client frame.
+func writeFrame(t *testing.T, conn net.Conn, opcode byte, payload []byte) {
+	frame := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	default:
+		frame = append(frame, 0x80|126, byte(n>>8), byte(n))
+	}
+	mask := make([]byte, 4)
+	rand.Read(mask)
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Reads a server frame.
+func readFrame(t *testing.T, rd *bufio.Reader) (byte, []byte) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(rd, header); err != nil {
+		t.Fatal(err)
+	}
+	length := int(header[1] & 0x7F)
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		io.ReadFull(rd, ext)
+		length = int(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		io.ReadFull(rd, ext)
+		length = int(binary.BigEndian.Uint64(ext))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(rd, payload); err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+func TestSniffWebSocket(t *testing.T) {
+	api.Keyspace.Set(table.StringKey("ws"), "hello", 0)
+
+	conn, err := net.Dial("tcp", serveSniffed(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	conn.Write([]byte("GET /ws HTTP/1.1\r\n" +
+		"Host: localhost\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"))
+
+	rd := bufio.NewReader(conn)
+	res, err := http.ReadResponse(rd, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols || res.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected upgrade response %d %v", res.StatusCode, res.Header)
+	}
+
+	writeFrame(t, conn, opText, []byte("*2\r\n$3\r\nGET\r\n$2\r\nws\r\n"))
+	if opcode, payload := readFrame(t, rd); opcode != opBinary || string(payload) != "$5\r\nhello\r\n" {
+		t.Fatalf("unexpected reply %d %q", opcode, payload)
+	}
+
+	writeFrame(t, conn, opPing, []byte("hi"))
+	if opcode, payload := readFrame(t, rd); opcode != opPong || string(payload) != "hi" {
+		t.Fatalf("unexpected pong %d %q", opcode, payload)
+	}
+
+	// Published messages are pushed
+	writeFrame(t, conn, opBinary, []byte("*2\r\n$9\r\nSUBSCRIBE\r\n$4\r\nnews\r\n"))
+	readFrame(t, rd)
+	pubsub.Default.Publish("news", "extra")
+	if _, payload := readFrame(t, rd); !strings.Contains(string(payload), "extra") {
+		t.Fatalf("expected the published message got %q", payload)
+	}
+
+	writeFrame(t, conn, opClose, []byte{0x03, 0xE8})
+	if opcode, _ := readFrame(t, rd); opcode != opClose {
+		t.Fatalf("expected a close frame got %d", opcode)
+	}
+}
@@ -0,0 +1,140 @@
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/genzai-io/sliced/app/acl"
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/table"
+)
+
+// Keys of the index in order.
+func indexKeys(t *testing.T, ks *table.Table, index string) []string {
+	var keys []string
+	err := ks.View(func() error {
+		return ks.Ascend(index, func(item table.IndexItem) bool {
+			keys = append(keys, fmt.Sprint(item.Value().Key))
+			return true
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestCmdConnCreateIndex(t *testing.T) {
+	conn := newMockConn()
+	defer conn.close()
+
+	// Commands after a worker wait in the backlog so replies are checked
+	// once they all ran
+	conn.Send(rawCommand{"SET", "person:1", `{"name":"carol","age":40}`})
+	conn.Send(rawCommand{"CREATEINDEX", "people", "person:*", "JSON", "name"})
+	conn.Send(rawCommand{"SET", "person:2", `{"name":"alice","age":30}`})
+	conn.Send(rawCommand{"SET", "person:3", `{"name":"bob","age":20}`})
+	conn.Send(rawCommand{"CREATEINDEX", "people", "person:*", "JSON", "age"})
+	conn.Send(rawCommand{"CREATEINDEX", "wide", "person:*", "JSON", "name", "age", "city"})
+
+	time.Sleep(time.Millisecond * 100)
+	replies := conn.Replies()
+	if len(replies) != 6 {
+		t.Fatalf("expected 6 replies got %v", replies)
+	}
+	for i, reply := range replies[:4] {
+		if _, ok := reply.(api.Ok); !ok {
+			t.Fatalf("replies[%d] expected OK got %v", i, reply)
+		}
+	}
+	for i, reply := range replies[4:] {
+		if _, ok := reply.(api.Err); !ok {
+			t.Fatalf("replies[%d] expected an error got %v", 4+i, reply)
+		}
+	}
+
+	expected := []string{"person:2", "person:3", "person:1"}
+	check := func(keys []string) {
+		if len(keys) != len(expected) {
+			t.Fatalf("expected %v got %v", expected, keys)
+		}
+		for i := range expected {
+			if keys[i] != expected[i] {
+				t.Fatalf("expected %v got %v", expected, keys)
+			}
+		}
+	}
+	check(indexKeys(t, api.Keyspace, "people"))
+
+	// The definition is restored with a snapshot
+	var buf bytes.Buffer
+	if err := api.Keyspace.View(func() error { return api.Keyspace.Save(&buf) }); err != nil {
+		t.Fatal(err)
+	}
+	restored := table.NewTable()
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	check(indexKeys(t, restored, "people"))
+}
+
+// Keys of the INDEX QUERY reply of a packet.
+func queryKeys(t *testing.T, p *packet) []string {
+	if len(p.replies) != 1 {
+		t.Fatalf("expected 1 reply got %v", p.replies)
+	}
+	entries, ok := p.replies[0].(api.Array)
+	if !ok {
+		t.Fatalf("expected an array got %v", p.replies[0])
+	}
+	keys := make([]string, len(entries))
+	for i, entry := range entries {
+		keys[i] = string(entry.(api.Array)[0].(api.BulkString))
+	}
+	return keys
+}
+
+func TestCmdConnIndexQuery(t *testing.T) {
+	if err := acl.Default.SetUser("member", "on", ">secret", "~member:1*", "+@read"); err != nil {
+		t.Fatal(err)
+	}
+	defer acl.Default.Unmarshal(nil)
+
+	conn := newMockConn()
+	defer conn.close()
+
+	conn.Send(rawCommand{"CREATEINDEX", "ages", "member:*", "JSON", "age"})
+	conn.Send(rawCommand{"SET", "member:1", `{"age":40}`})
+	conn.Send(rawCommand{"SET", "member:2", `{"age":30}`})
+	conn.Send(rawCommand{"SET", "member:3", `{"age":20}`})
+	conn.Send(rawCommand{"SET", "member:10", `{"age":30}`})
+	if _, err := conn.WaitForReplies(0, 5, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		args     rawCommand
+		expected string
+	}{
+		{rawCommand{"INDEX", "QUERY", "ages"}, "[member:3 member:10 member:2 member:1]"},
+		{rawCommand{"INDEX", "QUERY", "ages", "EQ", "30"}, "[member:10 member:2]"},
+		{rawCommand{"INDEX", "QUERY", "ages", "MIN", "25", "MAX", "35"}, "[member:10 member:2]"},
+		{rawCommand{"INDEX", "QUERY", "ages", "MIN", "30", "DESC"}, "[member:1 member:2 member:10]"},
+		{rawCommand{"INDEX", "QUERY", "ages", "LIMIT", "1"}, "[member:3]"},
+	} {
+		if keys := fmt.Sprint(queryKeys(t, conn.Send(test.args))); keys != test.expected {
+			t.Fatalf("%v: expected %s got %s", test.args, test.expected, keys)
+		}
+	}
+	if p := conn.Send(rawCommand{"INDEX", "QUERY", "missing"}); len(p.replies) != 1 || p.replies[0] != api.Err("ERR index not found") {
+		t.Fatalf("expected index not found got %v", p.replies)
+	}
+
+	// Keys the user may not read are left out
+	conn.Send(rawCommand{"AUTH", "member", "secret"}).ExpectOK(t)
+	if keys := fmt.Sprint(queryKeys(t, conn.Send(rawCommand{"INDEX", "QUERY", "ages"}))); keys != "[member:10 member:1]" {
+		t.Fatalf("expected the keys of member got %s", keys)
+	}
+}
@@ -1,8 +1,10 @@
 package cmd
 
 import (
+	"crypto/tls"
 	"fmt"
 	"io"
+	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -10,6 +12,7 @@ import (
 
 	"github.com/genzai-io/sliced"
 	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/certs"
 	"github.com/genzai-io/sliced/common/celltree"
 	"github.com/genzai-io/sliced/common/evio"
 	"github.com/genzai-io/sliced/common/metrics"
@@ -32,6 +35,12 @@ type Server struct {
 
 	connectionCount uint64
 
+	// Every connection is over TLS when set
+	tlsConfig *tls.Config
+
+	// Serves HTTP requests made to the API port
+	Handler http.Handler
+
 	statsConns       metrics.Counter // counter for total connections
 	statsOpened      metrics.Counter
 	statsClosed      metrics.Counter
@@ -44,16 +53,19 @@ type Server struct {
 
 func NewServer() *Server {
 	e := &Server{
-		statsConns:       metrics.NewCounter(),
-		statsOpened:      metrics.NewCounter(),
-		statsClosed:      metrics.NewCounter(),
-		statsCommands:    metrics.NewCounter(),
-		statsIngress:     metrics.NewCounter(),
-		statsEgress:      metrics.NewCounter(),
-		statsActiveConns: metrics.NewCounter(),
-		statsWakes:       metrics.NewCounter(),
+		statsConns:       metrics.GetOrRegisterCounter("server.conns", moved.Metrics),
+		statsOpened:      metrics.GetOrRegisterCounter("server.opened", moved.Metrics),
+		statsClosed:      metrics.GetOrRegisterCounter("server.closed", moved.Metrics),
+		statsCommands:    metrics.GetOrRegisterCounter("server.commands", moved.Metrics),
+		statsIngress:     metrics.GetOrRegisterCounter("server.ingress.bytes", moved.Metrics),
+		statsEgress:      metrics.GetOrRegisterCounter("server.egress.bytes", moved.Metrics),
+		statsActiveConns: metrics.GetOrRegisterCounter("server.active.conns", moved.Metrics),
+		statsWakes:       metrics.GetOrRegisterCounter("server.wakes", moved.Metrics),
 	}
 	//e.connections = btree.New(64, e)
+	if certs.Default.Enabled() {
+		e.tlsConfig = certs.Default.ServerConfig()
+	}
 	e.BaseService = *service.NewBaseService(moved.Logger, "svr", e)
 	return e
 }
@@ -126,7 +138,11 @@ func (e *Server) serve() {
 			ev: c,
 			//Out: &emptyBuffer,
 		}
-		co.onData = co.OnData
+		co.handler = e.Handler
+		co.setProtocol(co.sniff)
+		if e.tlsConfig != nil {
+			co.startTLS(e.tlsConfig)
+		}
 
 		// Let's reuse the read buffer
 		opts.ReuseInputBuffer = true
@@ -206,9 +222,15 @@ func (e *Server) serve() {
 			return
 		}
 
-		e.statsIngress.Inc(int64(len(in)))
+		if in == nil {
+			e.statsWakes.Inc(1)
+		} else {
+			e.statsIngress.Inc(int64(len(in)))
+		}
 
-		return c.onData(in)
+		out, action = c.onData(in)
+		e.statsEgress.Inc(int64(len(out)))
+		return
 	}
 
 	err := evio.Serve(events, fmt.Sprintf("tcp://0.0.0.0:%d?reuseport=true", moved.ApiPort))
@@ -0,0 +1,92 @@
+package cmd_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/cmd"
+	"github.com/genzai-io/sliced/app/table"
+)
+
+func TestCmdConnStats(t *testing.T) {
+	api.Stats.Reset()
+	api.Slowlog.Configure(20*time.Millisecond, 4)
+	api.Slowlog.Reset()
+	defer api.Slowlog.Configure(10*time.Millisecond, 128)
+	api.Keyspace.Set(table.StringKey("stats"), "hello", 0)
+
+	conn := newMockConn()
+	defer conn.close()
+
+	conn.Send(&cmd.Get{Key: "stats"}).ExpectBulk(t)
+	conn.Send(&cmd.Get{Key: "stats"}).ExpectBulk(t)
+	conn.Send(&cmd.Sleep{Millis: 30}).ShouldNotReply(t)
+	time.Sleep(100 * time.Millisecond)
+
+	get, ok := api.Stats.Get("GET")
+	if !ok || get.Calls != 2 || get.WorkerUsec != 0 || get.Latency.TotalCount() != 2 {
+		t.Fatalf("unexpected GET stats %+v", get)
+	}
+	sleep, ok := api.Stats.Get("sleep")
+	if !ok || sleep.Calls != 1 || sleep.WorkerUsec < 30000 || sleep.LoopUsec != 0 {
+		t.Fatalf("unexpected SLEEP stats %+v", sleep)
+	}
+
+	// Only SLEEP is slower than the threshold
+	entries := api.Slowlog.Get(-1)
+	if len(entries) != 1 || entries[0].Args[0] != "SLEEP" || entries[0].Args[1] != "30" {
+		t.Fatalf("unexpected slowlog %+v", entries)
+	}
+	reply := conn.Send(rawCommand{"SLOWLOG", "GET"}).ExpectArray(t).replies[0].(api.Array)
+	if len(reply) != 1 || len(reply[0].(api.Array)) != 6 {
+		t.Fatalf("unexpected SLOWLOG GET reply %v", reply)
+	}
+	conn.Send(rawCommand{"SLOWLOG", "RESET"}).ExpectOK(t)
+	if api.Slowlog.Len() != 0 {
+		t.Fatal("expected an empty slowlog")
+	}
+
+	info := conn.Send(rawCommand{"INFO", "commandstats"}).ExpectBulk(t).replies[0].(api.BulkString)
+	if !strings.Contains(string(info), "cmdstat_get:calls=2,") || !strings.Contains(string(info), "cmdstat_sleep:calls=1,") {
+		t.Fatalf("unexpected INFO reply %q", info)
+	}
+
+	histogram := conn.Send(rawCommand{"LATENCY", "HISTOGRAM", "sleep"}).ExpectArray(t).replies[0].(api.Array)
+	if len(histogram) != 2 || histogram[0] != api.BulkString("sleep") {
+		t.Fatalf("unexpected LATENCY HISTOGRAM reply %v", histogram)
+	}
+	buckets := histogram[1].(api.Array)[3].(api.Array)
+	if len(buckets) != 2 || buckets[1] != api.Int(1) || buckets[0].(api.Int) < 32768 {
+		t.Fatalf("unexpected buckets %v", buckets)
+	}
+}
+
+func TestSlowLogRing(t *testing.T) {
+	log := api.NewSlowLog(0, 3)
+	ctx := &api.Context{Addr: "127.0.0.1:1"}
+	for i := 0; i < 5; i++ {
+		log.Add(ctx, rawCommand{"SET", "k", strings.Repeat("x", 200)}, time.Millisecond)
+	}
+
+	entries := log.Get(-1)
+	if len(entries) != 3 || entries[0].ID != 4 || entries[2].ID != 2 {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+	if arg := entries[0].Args[2]; !strings.HasSuffix(arg, "... (72 more bytes)") {
+		t.Fatalf("expected the argument to be shortened got %q", arg)
+	}
+
+	// Shrinking keeps the newest
+	log.Configure(0, 2)
+	if entries = log.Get(-1); len(entries) != 2 || entries[0].ID != 4 || entries[1].ID != 3 {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+
+	log.Configure(-1, 2)
+	log.Add(ctx, rawCommand{"GET", "k"}, time.Second)
+	if log.Len() != 2 {
+		t.Fatal("expected a negative threshold to disable the log")
+	}
+}
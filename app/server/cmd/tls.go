@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/genzai-io/sliced/common/evio"
+)
+
+var errWouldBlock = &wouldBlock{}
+
+// Returned by tlsConn.Read when the event loop has no more input. It is
+// temporary so crypto/tls keeps a partial record for the next read.
+type wouldBlock struct{}
+
+func (*wouldBlock) Error() string   { return "tls: would block" }
+func (*wouldBlock) Timeout() bool   { return true }
+func (*wouldBlock) Temporary() bool { return true }
+
+// How long a client has to finish the TLS handshake.
+var HandshakeTimeout = time.Second * 10
+
+const (
+	tlsHandshaking int32 = iota
+	tlsReady
+	tlsFailed
+)
+
+// TLS termination for an event loop connection.
+//
+// crypto/tls cannot resume a handshake that ran out of input so the
+// handshake runs on its own goroutine that blocks on the input the event
+// loop feeds it. Once it finishes records are decrypted and encrypted on
+// the event loop and reads return errWouldBlock instead of blocking.
+type tlsConn struct {
+	ev    evio.Conn
+	conn  *tls.Conn
+	state int32
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	in     []byte // Ciphertext not read by crypto/tls yet
+	out    []byte // Ciphertext waiting to be written
+	closed bool
+
+	plain []byte
+	buf   []byte
+}
+
+func newTLSConn(ev evio.Conn, config *tls.Config) *tlsConn {
+	t := &tlsConn{ev: ev}
+	t.cond = sync.NewCond(&t.mu)
+	t.conn = tls.Server(t, config)
+	return t
+}
+
+func (t *tlsConn) handshake() {
+	ctx, cancel := context.WithTimeout(context.Background(), HandshakeTimeout)
+	defer cancel()
+
+	if err := t.conn.HandshakeContext(ctx); err != nil {
+		atomic.StoreInt32(&t.state, tlsFailed)
+	} else {
+		atomic.StoreInt32(&t.state, tlsReady)
+	}
+
+	// Let the event loop write the last flight and read any records that
+	// arrived with it.
+	t.ev.Wake()
+}
+
+// Connection state once the handshake finished.
+func (t *tlsConn) connectionState() *tls.ConnectionState {
+	if atomic.LoadInt32(&t.state) != tlsReady {
+		return nil
+	}
+	state := t.conn.ConnectionState()
+	return &state
+}
+
+// Decrypts the input for next and encrypts it's output. This runs on the
+// event loop.
+func (t *tlsConn) data(in []byte, next func([]byte) ([]byte, evio.Action)) (out []byte, action evio.Action) {
+	t.mu.Lock()
+	if len(in) > 0 {
+		t.in = append(t.in, in...)
+		t.cond.Broadcast()
+	}
+	t.mu.Unlock()
+
+	switch atomic.LoadInt32(&t.state) {
+	case tlsHandshaking:
+		return t.flush(), evio.None
+	case tlsFailed:
+		return t.flush(), evio.Close
+	}
+
+	if t.buf == nil {
+		t.buf = make([]byte, 16*1024)
+	}
+	t.plain = t.plain[:0]
+	for {
+		n, err := t.conn.Read(t.buf)
+		t.plain = append(t.plain, t.buf[:n]...)
+		if err == errWouldBlock {
+			break
+		}
+		if err != nil {
+			out, _ = next(t.plain)
+			if len(out) > 0 {
+				t.conn.Write(out)
+			}
+			return t.flush(), evio.Close
+		}
+	}
+
+	out, action = next(t.plain)
+	if len(out) > 0 {
+		if _, err := t.conn.Write(out); err != nil {
+			action = evio.Close
+		}
+	}
+	if action == evio.Close || action == evio.Shutdown {
+		t.conn.CloseWrite()
+	}
+	return t.flush(), action
+}
+
+func (t *tlsConn) flush() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := t.out
+	t.out = nil
+	return out
+}
+
+// Read implements net.Conn for crypto/tls.
+func (t *tlsConn) Read(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for len(t.in) == 0 {
+		if t.closed {
+			return 0, io.EOF
+		}
+		if atomic.LoadInt32(&t.state) != tlsHandshaking {
+			return 0, errWouldBlock
+		}
+		t.cond.Wait()
+	}
+	n := copy(b, t.in)
+	t.in = t.in[n:]
+	if len(t.in) == 0 {
+		t.in = nil
+	}
+	return n, nil
+}
+
+// Write implements net.Conn for crypto/tls. Handshake messages wake the
+// event loop to write them.
+func (t *tlsConn) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return 0, io.ErrClosedPipe
+	}
+	t.out = append(t.out, b...)
+	t.mu.Unlock()
+
+	if atomic.LoadInt32(&t.state) == tlsHandshaking {
+		t.ev.Wake()
+	}
+	return len(b), nil
+}
+
+// Close implements net.Conn. A handshake waiting for input fails.
+func (t *tlsConn) Close() error {
+	t.mu.Lock()
+	t.closed = true
+	t.cond.Broadcast()
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *tlsConn) LocalAddr() net.Addr  { return t.ev.LocalAddr() }
+func (t *tlsConn) RemoteAddr() net.Addr { return t.ev.RemoteAddr() }
+
+func (t *tlsConn) SetDeadline(time.Time) error      { return nil }
+func (t *tlsConn) SetReadDeadline(time.Time) error  { return nil }
+func (t *tlsConn) SetWriteDeadline(time.Time) error { return nil }
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/certs"
+	_ "github.com/genzai-io/sliced/app/cmd"
+	"github.com/genzai-io/sliced/app/table"
+	"github.com/genzai-io/sliced/common/evio"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+// evio.Conn over a real socket driven by a single goroutine acting as the
+// event loop.
+type socketEvConn struct {
+	net.Conn
+	wakes chan struct{}
+}
+
+func (c *socketEvConn) LoopIndex() int         { return 0 }
+func (c *socketEvConn) Context() interface{}   { return nil }
+func (c *socketEvConn) SetContext(interface{}) {}
+func (c *socketEvConn) AddrIndex() int         { return 0 }
+func (c *socketEvConn) LocalAddr() net.Addr    { return c.Conn.LocalAddr() }
+func (c *socketEvConn) RemoteAddr() net.Addr   { return c.Conn.RemoteAddr() }
+func (c *socketEvConn) Wake() error {
+	select {
+	case c.wakes <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Serves one TLS connection and returns the address to dial.
+func serveTLS(t *testing.T, config *tls.Config) string {
+	return serveConn(t, func(conn *Conn) {
+		conn.startTLS(config)
+	})
+}
+
+// Serves one connection set up by setup and returns the address to dial.
+func serveConn(t *testing.T, setup func(conn *Conn)) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		nc, err := ln.Accept()
+		ln.Close()
+		if err != nil {
+			return
+		}
+		ev := &socketEvConn{Conn: nc, wakes: make(chan struct{}, 1)}
+		conn := NewConn(ev)
+		setup(conn)
+
+		data := make(chan []byte)
+		go func() {
+			defer close(data)
+			buf := make([]byte, 4096)
+			for {
+				n, err := nc.Read(buf)
+				if err != nil {
+					return
+				}
+				data <- append([]byte{}, buf[:n]...)
+			}
+		}()
+
+		for {
+			var in []byte
+			select {
+			case b, ok := <-data:
+				if !ok {
+					conn.OnClosed()
+					return
+				}
+				in = b
+			case <-ev.wakes:
+			}
+
+			out, action := conn.onData(in)
+			if len(out) > 0 {
+				nc.Write(out)
+			}
+			if action == evio.Close {
+				nc.Close()
+				conn.OnClosed()
+				return
+			}
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func testCerts(t *testing.T) (*certs.Store, *x509.CertPool) {
+	dir, err := ioutil.TempDir("", "sliced-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile, err := certs.WriteSelfSigned(dir, "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &certs.Store{}
+	if err := store.Configure(certFile, keyFile, certFile); err != nil {
+		t.Fatal(err)
+	}
+
+	pem, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(pem)
+	return store, pool
+}
+
+func request(t *testing.T, conn net.Conn, rd *bufio.Reader, args ...string) string {
+	buf := resp.AppendArray(nil, len(args))
+	for _, arg := range args {
+		buf = resp.AppendBulkString(buf, arg)
+	}
+	if _, err := conn.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.HasPrefix(line, "$") && line != "$-1\r\n" {
+		value, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSuffix(value, "\r\n")
+	}
+	return strings.TrimSuffix(line, "\r\n")
+}
+
+func TestTLSConn(t *testing.T) {
+	store, pool := testCerts(t)
+	api.Keyspace.Set(table.StringKey("tls"), "hello", 0)
+
+	conn, err := tls.Dial("tcp", serveTLS(t, store.ServerConfig()), &tls.Config{RootCAs: pool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+
+	for i := 0; i < 3; i++ {
+		if reply := request(t, conn, rd, "GET", "tls"); reply != "hello" {
+			t.Fatalf("expected hello got %s", reply)
+		}
+	}
+
+	// Values larger than a single record
+	value := strings.Repeat("x", 64*1024)
+	api.Keyspace.Set(table.StringKey("tls:large"), value, 0)
+	if reply := request(t, conn, rd, "GET", "tls:large"); reply != value {
+		t.Fatalf("expected %d bytes got %d", len(value), len(reply))
+	}
+}
+
+func TestTLSRaftClientAuth(t *testing.T) {
+	moved.TLSRaftClientAuth = true
+	defer func() { moved.TLSRaftClientAuth = false }()
+
+	store, pool := testCerts(t)
+
+	// Without a client certificate
+	conn, err := tls.Dial("tcp", serveTLS(t, store.ServerConfig()), &tls.Config{RootCAs: pool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if reply := request(t, conn, bufio.NewReader(conn), api.RaftStateName); !strings.HasPrefix(reply, "-NOPERM") {
+		t.Fatalf("expected NOPERM got %s", reply)
+	}
+
+	// With the node certificate
+	conn, err = tls.Dial("tcp", serveTLS(t, store.ServerConfig()), store.ClientConfig("127.0.0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if reply := request(t, conn, bufio.NewReader(conn), api.RaftStateName); strings.HasPrefix(reply, "-NOPERM") {
+		t.Fatalf("expected the certificate to be accepted got %s", reply)
+	}
+}
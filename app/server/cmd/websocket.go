@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+
+	"github.com/genzai-io/sliced/common/evio"
+)
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Largest message a WebSocket client may send.
+const maxMessageBytes = 32 << 20
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+const (
+	closeProtocolError = 1002
+	closeTooBig        = 1009
+)
+
+func isWebSocketUpgrade(req *http.Request) bool {
+	return req.Method == http.MethodGet &&
+		headerContains(req.Header, "Connection", "upgrade") &&
+		headerContains(req.Header, "Upgrade", "websocket") &&
+		req.Header.Get("Sec-WebSocket-Version") == "13" &&
+		req.Header.Get("Sec-WebSocket-Key") != ""
+}
+
+func upgradeResponse(req *http.Request) []byte {
+	h := sha1.New()
+	h.Write([]byte(req.Header.Get("Sec-WebSocket-Key") + websocketGUID))
+	accept := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	return []byte("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n")
+}
+
+// WebSocket framing of a RESP connection. Text and binary messages hold
+// RESP commands and every reply, including published messages, is sent
+// back as a binary message.
+type wsConn struct {
+	in      []byte // Input not parsed yet
+	message []byte // Fragments of the current message
+	closed  bool
+}
+
+func (c *Conn) onWebSocketData(in []byte) ([]byte, evio.Action) {
+	ws := c.ws
+	ws.in = append(ws.in, in...)
+
+	var (
+		out      []byte
+		commands []byte
+		action   = evio.None
+	)
+
+	for !ws.closed {
+		fin, opcode, payload, n, code := nextFrame(ws.in)
+		if code != 0 {
+			out = appendCloseFrame(out, code)
+			ws.closed = true
+			action = evio.Close
+			break
+		}
+		if n == 0 {
+			break
+		}
+		ws.in = ws.in[n:]
+
+		switch opcode {
+		case opPing:
+			out = appendFrame(out, opPong, payload)
+		case opPong:
+		case opClose:
+			out = appendFrame(out, opClose, payload)
+			ws.closed = true
+			action = evio.Close
+		case opText, opBinary, opContinuation:
+			ws.message = append(ws.message, payload...)
+			if len(ws.message) > maxMessageBytes {
+				out = appendCloseFrame(out, closeTooBig)
+				ws.closed = true
+				action = evio.Close
+				break
+			}
+			if fin {
+				commands = append(commands, ws.message...)
+				ws.message = ws.message[:0]
+			}
+		default:
+			out = appendCloseFrame(out, closeProtocolError)
+			ws.closed = true
+			action = evio.Close
+		}
+	}
+	if len(ws.in) == 0 {
+		ws.in = nil
+	}
+
+	replies, replyAction := c.OnData(commands)
+	if len(replies) > 0 {
+		out = appendFrame(out, opBinary, replies)
+	}
+	if action == evio.None {
+		action = replyAction
+	}
+	return out, action
+}
+
+// Parses the next client frame. n is 0 when more input is needed and code
+// is the close code of a protocol error.
+func nextFrame(b []byte) (fin bool, opcode byte, payload []byte, n int, code int) {
+	if len(b) < 2 {
+		return
+	}
+	fin = b[0]&0x80 != 0
+	opcode = b[0] & 0x0F
+	if b[0]&0x70 != 0 || b[1]&0x80 == 0 {
+		// Reserved bits or an unmasked client frame
+		return false, 0, nil, 0, closeProtocolError
+	}
+
+	length := uint64(b[1] & 0x7F)
+	header := 2
+	switch length {
+	case 126:
+		if len(b) < 4 {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(b[2:]))
+		header = 4
+	case 127:
+		if len(b) < 10 {
+			return
+		}
+		length = binary.BigEndian.Uint64(b[2:])
+		header = 10
+	}
+	if opcode >= opClose && (length > 125 || !fin) {
+		return false, 0, nil, 0, closeProtocolError
+	}
+	if length > maxMessageBytes {
+		return false, 0, nil, 0, closeTooBig
+	}
+
+	end := header + 4 + int(length)
+	if len(b) < end {
+		return
+	}
+	mask := b[header : header+4]
+	payload = b[header+4 : end]
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, end, 0
+}
+
+func appendFrame(out []byte, opcode byte, payload []byte) []byte {
+	out = append(out, 0x80|opcode)
+	switch n := len(payload); {
+	case n < 126:
+		out = append(out, byte(n))
+	case n <= 0xFFFF:
+		out = append(out, 126, byte(n>>8), byte(n))
+	default:
+		out = append(out, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(out[len(out)-8:], uint64(n))
+	}
+	return append(out, payload...)
+}
+
+func appendCloseFrame(out []byte, code int) []byte {
+	return appendFrame(out, opClose, []byte{byte(code >> 8), byte(code)})
+}
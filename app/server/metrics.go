@@ -0,0 +1,139 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/acl"
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/raft"
+	"github.com/genzai-io/sliced/common/metrics"
+)
+
+const metricsPrefix = "sliced"
+
+// Upper bounds in microseconds of the command latency histogram buckets.
+var latencyBuckets = []int64{
+	100, 250, 500, 1000, 2500, 5000, 10000,
+	25000, 50000, 100000, 250000, 500000, 1000000, 2500000, 5000000, 10000000,
+}
+
+// Serves the metrics registry, the command latencies and the stats of
+// every raft group in the Prometheus text format. Scrapers authenticate as a
+// user with the Admin category unless metrics.anonymous is set.
+func (s *Web) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&moved.MetricsAnonymous) == 0 {
+		user, err := s.httpUser(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="sliced"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !user.Can(acl.Admin) {
+			http.Error(w, acl.NoPermCommand("metrics").Error(), http.StatusForbidden)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	bw := bufio.NewWriter(w)
+	if err := metrics.WritePrometheus(bw, moved.Metrics, metricsPrefix); err != nil {
+		return
+	}
+	writeCommandStats(bw, api.Stats)
+	writeRaftStats(bw)
+	bw.Flush()
+}
+
+func writeCommandStats(w io.Writer, stats *api.StatsTable) {
+	var list []*api.CommandStats
+	stats.Each(func(s *api.CommandStats) {
+		list = append(list, s)
+	})
+
+	name := metrics.PrometheusName(metricsPrefix, "command_duration_seconds")
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	for _, s := range list {
+		counts := s.Buckets(latencyBuckets)
+		for i, bound := range latencyBuckets {
+			le := strconv.FormatFloat(float64(bound)/1e6, 'g', -1, 64)
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name,
+				metrics.PrometheusLabels("command", s.Name, "le", le), counts[i])
+		}
+		labels := metrics.PrometheusLabels("command", s.Name)
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name,
+			metrics.PrometheusLabels("command", s.Name, "le", "+Inf"), s.Calls)
+		fmt.Fprintf(w, "%s_sum%s %g\n", name, labels, float64(s.Usec)/1e6)
+		fmt.Fprintf(w, "%s_count%s %d\n", name, labels, s.Calls)
+	}
+
+	name = metrics.PrometheusName(metricsPrefix, "command_errors_total")
+	fmt.Fprintf(w, "# TYPE %s counter\n", name)
+	for _, s := range list {
+		fmt.Fprintf(w, "%s%s %d\n", name, metrics.PrometheusLabels("command", s.Name), s.Errors)
+	}
+
+	name = metrics.PrometheusName(metricsPrefix, "command_seconds_total")
+	fmt.Fprintf(w, "# TYPE %s counter\n", name)
+	for _, s := range list {
+		fmt.Fprintf(w, "%s%s %g\n", name, metrics.PrometheusLabels("command", s.Name, "on", "loop"), float64(s.LoopUsec)/1e6)
+		fmt.Fprintf(w, "%s%s %g\n", name, metrics.PrometheusLabels("command", s.Name, "on", "worker"), float64(s.WorkerUsec)/1e6)
+	}
+}
+
+// Numeric raft stats become gauges labeled with the database and slice of
+// the group. The cluster group has -1 for both.
+func writeRaftStats(w io.Writer) {
+	type sample struct {
+		labels string
+		value  string
+	}
+	var (
+		gauges = make(map[string][]sample)
+		states []sample
+	)
+
+	raft_service.Each(func(rs *raft_service.Service) {
+		database := strconv.Itoa(int(rs.DatabaseID()))
+		slice := strconv.Itoa(int(rs.SliceID()))
+		states = append(states, sample{
+			labels: metrics.PrometheusLabels("database", database, "slice", slice, "state", rs.State().String()),
+			value:  "1",
+		})
+
+		labels := metrics.PrometheusLabels("database", database, "slice", slice)
+		for key, value := range rs.Stats() {
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+			name := metrics.PrometheusName(metricsPrefix, "raft_"+key)
+			gauges[name] = append(gauges[name], sample{labels: labels, value: value})
+		}
+	})
+
+	if len(states) > 0 {
+		name := metrics.PrometheusName(metricsPrefix, "raft_state")
+		fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+		for _, s := range states {
+			fmt.Fprintf(w, "%s%s %s\n", name, s.labels, s.value)
+		}
+	}
+
+	names := make([]string, 0, len(gauges))
+	for name := range gauges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+		for _, s := range gauges[name] {
+			fmt.Fprintf(w, "%s%s %s\n", name, s.labels, s.value)
+		}
+	}
+}
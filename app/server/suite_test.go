@@ -2,8 +2,14 @@ package server
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/acl"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -48,6 +54,54 @@ var _ = Describe("Web", func() {
 		})
 	})
 
+	Describe("Serving metrics", func() {
+		scrape := func(setup func(r *http.Request)) int {
+			r := httptest.NewRequest("GET", "/metrics", nil)
+			if setup != nil {
+				setup(r)
+			}
+			w := httptest.NewRecorder()
+			server.serveMetrics(w, r)
+			return w.Code
+		}
+
+		BeforeEach(func() {
+			Expect(acl.Default.SetUser("scraper", "on", ">secret", "allcommands")).To(Succeed())
+			Expect(acl.Default.SetUser("reader", "on", ">secret", "+@read")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			acl.Default.DelUser("scraper", "reader")
+			atomic.StoreInt32(&moved.MetricsAnonymous, 0)
+		})
+
+		Context("when metrics.anonymous is off", func() {
+			It("should reject requests without credentials", func() {
+				Expect(scrape(nil)).To(Equal(http.StatusUnauthorized))
+				Expect(scrape(func(r *http.Request) { r.SetBasicAuth("scraper", "wrong") })).To(Equal(http.StatusUnauthorized))
+				Expect(scrape(func(r *http.Request) { r.Header.Set("Authorization", "Bearer unknown") })).To(Equal(http.StatusUnauthorized))
+			})
+
+			It("should require the Admin category", func() {
+				Expect(scrape(func(r *http.Request) { r.SetBasicAuth("reader", "secret") })).To(Equal(http.StatusForbidden))
+			})
+
+			It("should serve users with basic auth or a token", func() {
+				Expect(scrape(func(r *http.Request) { r.SetBasicAuth("scraper", "secret") })).To(Equal(http.StatusOK))
+				token, _, err := server.sessions.create("scraper")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(scrape(func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) })).To(Equal(http.StatusOK))
+			})
+		})
+
+		Context("when metrics.anonymous is on", func() {
+			It("should serve requests without credentials", func() {
+				atomic.StoreInt32(&moved.MetricsAnonymous, 1)
+				Expect(scrape(nil)).To(Equal(http.StatusOK))
+			})
+		})
+	})
+
 	//Describe("Listing all browsers", func() {
 	//	Context("when at least Chrome is installed locally", func() {
 	//		It("should have at least Chrome in the reply", func() {
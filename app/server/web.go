@@ -1,6 +1,7 @@
 package server
 
 import (
+	"crypto/tls"
 	"net"
 	"net/http"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	"github.com/improbable-eng/grpc-web/go/grpcweb"
 	_ "github.com/improbable-eng/grpc-web/go/grpcweb"
 	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/certs"
 	"github.com/genzai-io/sliced/assets/gzip"
 	"github.com/genzai-io/sliced/assets/ui"
 	api_pb "github.com/genzai-io/sliced/proto/api"
@@ -31,22 +33,26 @@ type Web struct {
 	grpcServer *grpc.Server
 	router     chi.Router
 	handler    http.Handler
+	sessions   *sessions
 	Err        error
 }
 
 func NewWeb(host string) *Web {
-	grpcServer := grpc.NewServer()
-	wrappedGrpc := grpcweb.WrapServer(grpcServer, grpcweb.WithWebsockets(true))
-
 	server := &Web{
-		WrappedGrpcServer: wrappedGrpc,
-		host:              host,
-		grpcServer:        grpcServer,
-		Err:               nil,
+		host:     host,
+		sessions: newSessions(),
+		Err:      nil,
 	}
 
+	// Every call is checked against the user of it's token
+	server.grpcServer = grpc.NewServer(
+		grpc.UnaryInterceptor(server.unaryInterceptor),
+		grpc.StreamInterceptor(server.streamInterceptor),
+	)
+	server.WrappedGrpcServer = grpcweb.WrapServer(server.grpcServer, grpcweb.WithWebsockets(true))
+
 	// Register services
-	api_pb.RegisterAPIServiceServer(grpcServer, server)
+	api_pb.RegisterAPIServiceServer(server.grpcServer, server)
 
 	router := chi.NewRouter()
 	router.Use(
@@ -64,6 +70,7 @@ func NewWeb(host string) *Web {
 	)
 
 	FileServer(router, "/s", ui_data.FS())
+	router.Get("/metrics", server.serveMetrics)
 
 	router.NotFound(func(writer http.ResponseWriter, request *http.Request) {
 		writer.WriteHeader(404)
@@ -86,6 +93,12 @@ func (s *Web) Handler(next http.Handler) http.Handler {
 	})
 }
 
+// Routes of the web server. HTTP requests to the API port are served by
+// it too.
+func (s *Web) Router() http.Handler {
+	return s.router
+}
+
 func (s *Web) Addr() *net.TCPAddr {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -126,6 +139,11 @@ func (s *Web) OnStart() error {
 
 	s.addr = s.listener.Addr().(*net.TCPAddr)
 
+	// grpc-web needs HTTP/2 which is negotiated with ALPN
+	if certs.Default.Enabled() {
+		s.listener = tls.NewListener(s.listener, certs.Default.ServerConfig("h2", "http/1.1"))
+	}
+
 	go func() {
 		defer s.Stop()
 		if err := http.Serve(s.listener, s.router); err != nil {
@@ -2,27 +2,207 @@ package server
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"github.com/genzai-io/sliced/app/acl"
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/cmd"
+	"github.com/genzai-io/sliced/app/document"
+	"github.com/genzai-io/sliced/app/events"
 	api_pb "github.com/genzai-io/sliced/proto/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-//
-//
-//
+var errUnexpectedReply = errors.New("unexpected reply")
+
+// Exchanges a username and password for a token.
 func (s *Web) Auth(ctx context.Context, req *api_pb.AuthRequest) (*api_pb.AuthReply, error) {
-	return nil, nil
+	if _, err := acl.Default.Authenticate(req.Username, req.Password); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	token, expires, err := s.sessions.create(req.Username)
+	if err != nil {
+		return nil, err
+	}
+	return &api_pb.AuthReply{Token: token, Expires: expires.Unix()}, nil
 }
 
-//
-//
-//
+// Creates or modifies a user the same way as ACL SETUSER.
 func (s *Web) Register(ctx context.Context, req *api_pb.RegisterRequest) (*api_pb.RegisterReply, error) {
-	return nil, nil
+	rules := req.Rules
+	if req.Password != "" {
+		rules = append([]string{">" + req.Password}, rules...)
+	}
+
+	user, ok := acl.Default.Get(req.Username)
+	if ok {
+		user = user.Clone()
+	} else {
+		user = acl.NewUser(req.Username)
+	}
+	if err := user.SetRules(rules...); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	reply := api.Propose(&cmd.ApplyACLSetUser{
+		Username: req.Username,
+		Rules:    acl.HashRules(rules),
+	})
+	if err, ok := reply.(api.Err); ok {
+		return nil, err
+	}
+	return &api_pb.RegisterReply{}, nil
 }
 
-//
-//
-//
+// Most events a stream may have waiting to be sent before it's ended.
+const eventsBuffer = 256
+
+// Streams the events of this node that match the request until the client
+// goes away.
 func (s *Web) Events(req *api_pb.EventsRequest, srv api_pb.APIService_EventsServer) error {
-	return nil
+	filter := events.Filter{Subject: req.Subject}
+	for _, kind := range req.Kinds {
+		filter.Kinds = append(filter.Kinds, events.Kind(kind))
+	}
+
+	sub, err := events.Default.Subscribe(req.Epoch, req.FromSeq, filter, eventsBuffer)
+	if err != nil {
+		return status.Error(codes.OutOfRange, err.Error())
+	}
+	defer sub.Close()
+
+	for {
+		select {
+		case <-srv.Context().Done():
+			return srv.Context().Err()
+
+		case e, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, sub.Err().Error())
+			}
+			if err := srv.Send(eventToProto(e)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func eventToProto(e *events.Event) *api_pb.Event {
+	event := &api_pb.Event{
+		Epoch:   e.Epoch,
+		Seq:     e.Seq,
+		Time:    e.Time.UnixNano(),
+		Kind:    api_pb.EventKind(e.Kind),
+		Subject: e.Subject,
+	}
+	for _, attr := range e.Attrs {
+		event.Attributes = append(event.Attributes, &api_pb.EventAttribute{Key: attr.Key, Value: attr.Value})
+	}
+	return event
+}
+
+func (s *Web) RegisterSchema(ctx context.Context, req *api_pb.RegisterSchemaRequest) (*api_pb.SchemaInfo, error) {
+	compat := compatibility(req.Compatibility)
+	if err := document.Service.CheckSchema(req.Package, compat, req.DescriptorSet); err != nil {
+		return nil, err
+	}
+
+	reply := api.Propose(&document.ApplySchemaRegister{
+		Package:   req.Package,
+		Compat:    compat,
+		Timestamp: time.Now().UnixNano(),
+		Set:       req.DescriptorSet,
+	})
+	switch r := reply.(type) {
+	case api.Err:
+		return nil, r
+	case api.Int:
+		v, ok := document.Service.Schema(req.Package, int32(r))
+		if !ok {
+			return nil, document.ErrSchemaNotFound
+		}
+		return schemaInfo(v, true), nil
+	}
+	return nil, errUnexpectedReply
+}
+
+func (s *Web) ListSchemas(ctx context.Context, req *api_pb.ListSchemasRequest) (*api_pb.ListSchemasReply, error) {
+	reply := &api_pb.ListSchemasReply{}
+	if req.Package == "" {
+		for _, pkg := range document.Service.Schemas() {
+			if v, ok := document.Service.Schema(pkg, 0); ok {
+				reply.Schemas = append(reply.Schemas, schemaInfo(v, false))
+			}
+		}
+	} else {
+		for _, v := range document.Service.SchemaVersions(req.Package) {
+			reply.Schemas = append(reply.Schemas, schemaInfo(v, false))
+		}
+	}
+	return reply, nil
+}
+
+func (s *Web) GetSchema(ctx context.Context, req *api_pb.GetSchemaRequest) (*api_pb.SchemaInfo, error) {
+	v, ok := document.Service.Schema(req.Package, req.Version)
+	if !ok {
+		return nil, document.ErrSchemaNotFound
+	}
+	return schemaInfo(v, true), nil
+}
+
+func (s *Web) DeleteSchema(ctx context.Context, req *api_pb.DeleteSchemaRequest) (*api_pb.DeleteSchemaReply, error) {
+	reply := api.Propose(&document.ApplySchemaUnregister{
+		Package: req.Package,
+		Version: req.Version,
+	})
+	if err, ok := reply.(api.Err); ok {
+		return nil, err
+	}
+	return &api_pb.DeleteSchemaReply{}, nil
+}
+
+// Unset keeps the level of the package like SCHEMAADD without one.
+func compatibility(c api_pb.Compatibility) document.Compatibility {
+	switch c {
+	case api_pb.Compatibility_BACKWARD:
+		return document.CompatBackward
+	case api_pb.Compatibility_FORWARD:
+		return document.CompatForward
+	case api_pb.Compatibility_FULL:
+		return document.CompatFull
+	case api_pb.Compatibility_NONE:
+		return document.CompatNone
+	}
+	return document.CompatDefault
+}
+
+func compatibilityPb(c document.Compatibility) api_pb.Compatibility {
+	switch c {
+	case document.CompatBackward:
+		return api_pb.Compatibility_BACKWARD
+	case document.CompatForward:
+		return api_pb.Compatibility_FORWARD
+	case document.CompatFull:
+		return api_pb.Compatibility_FULL
+	case document.CompatNone:
+		return api_pb.Compatibility_NONE
+	}
+	return api_pb.Compatibility_DEFAULT
+}
+
+func schemaInfo(v *document.SchemaVersion, withSet bool) *api_pb.SchemaInfo {
+	info := &api_pb.SchemaInfo{
+		Package:       v.Package,
+		Version:       v.Version,
+		Compatibility: compatibilityPb(document.Service.Compat(v.Package)),
+		Hash:          v.Hash,
+		Timestamp:     v.Timestamp.UnixNano(),
+		Messages:      v.MessageNames(),
+	}
+	if withSet {
+		info.DescriptorSet = v.Set
+	}
+	return info
 }
@@ -7,6 +7,7 @@ import (
 	//"github.com/coreos/bbolt"
 	"github.com/genzai-io/sliced"
 	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/backup"
 	"github.com/genzai-io/sliced/app/raft"
 	"github.com/genzai-io/sliced/common/btrdb"
 	"github.com/genzai-io/sliced/common/service"
@@ -19,7 +20,8 @@ type Service struct {
 	ID   uint16
 	Path string
 
-	db *btrdb.DB
+	db     *btrdb.DB
+	backup *backup.Source
 
 	topics       map[int64]*TopicSlice
 	topicsByName map[string]*TopicSlice
@@ -58,17 +60,28 @@ func (b *Service) OnStart() error {
 		return nil
 	})
 
+	if b.Path != ":memory:" {
+		b.backup = b.Backup()
+		backup.Register(b.backup)
+	}
+
 	return nil
 }
 
 func (b *Service) OnStop() {
+	if b.backup != nil {
+		backup.Unregister(b.backup)
+		b.backup = nil
+	}
 	if err := b.db.Close(); err != nil {
 		b.Logger.Error().AnErr("err", err).Msg("db.Close() error OnStop()")
 	}
 }
 
-func (b *Service) Backup() {
-
+// Source that backs up the slice store. Segments of the slice are backed up
+// by the drives they are on.
+func (b *Service) Backup() *backup.Source {
+	return &backup.Source{Path: b.Path, Save: b.db.Save}
 }
 
 func (g *Service) onExpired(keys []string) {
@@ -1,10 +1,19 @@
 package slice
 
 import (
+	"errors"
 	"sync"
 
-	"github.com/genzai-io/sliced/proto/store"
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/document"
+	"github.com/genzai-io/sliced/app/record"
 	"github.com/genzai-io/sliced/common/btrdb"
+	"github.com/genzai-io/sliced/proto/store"
+)
+
+var (
+	ErrTopicNoSchema      = errors.New("topic has no schema")
+	ErrTopicSchemaMissing = errors.New("topic schema is not registered")
 )
 
 var (
@@ -28,16 +37,106 @@ type Topic struct {
 	sync.RWMutex
 
 	model store.Topic
+
+	// Records of a topic go to a single partition until slices are wired
+	root *TopicPartition
 }
 
 func newTopic(model *store.Topic) *Topic {
 	topic := &Topic{
 		model: *model,
 	}
+	topic.root = &TopicPartition{
+		slice: &TopicSlice{parent: topic},
+	}
+	topic.root.slice.root = topic.root
 
 	return topic
 }
 
+// Copy of the definition of the topic.
+func (t *Topic) Model() store.Topic {
+	t.RLock()
+	defer t.RUnlock()
+	return t.model
+}
+
+// Changes the schema, codec and validation mode records are checked with
+// and how appends are synced.
+func (t *Topic) alter(model *store.Topic) {
+	t.Lock()
+	t.model.Schema = model.Schema
+	t.model.Codec = model.Codec
+	t.model.Validation = model.Validation
+	t.model.Level = model.Level
+	t.model.SyncInterval = model.SyncInterval
+	t.Unlock()
+
+	t.root.setLevel(model.Level, model.SyncInterval)
+}
+
+// Reads up to count records of the topic starting at offset.
+func (t *Topic) Read(offset uint64, count int) ([]*record.Record, error) {
+	return t.root.Read(offset, count)
+}
+
+// Validates and appends records to the topic. A *RecordError is returned for
+// the first record that is rejected and nothing is appended.
+func (t *Topic) Append(records []*record.Record) error {
+	return t.root.AppendRecords(records)
+}
+
+// Validates a record against the topic's schema according to it's validation
+// mode. In WARN mode invalid records are logged and nil is returned. A nil
+// error means the record may be appended.
+func (t *Topic) Validate(record []byte) error {
+	t.RLock()
+	mode := t.model.Validation
+	name := t.model.Name
+	t.RUnlock()
+
+	if mode == store.Topic_OFF {
+		return nil
+	}
+
+	err := t.validate(record)
+	if err == nil {
+		return nil
+	}
+	if mode == store.Topic_WARN {
+		moved.Logger.Warn().
+			Str("topic", name).
+			AnErr("err", err).
+			Msg("invalid record appended")
+		return nil
+	}
+	return err
+}
+
+func (t *Topic) validate(record []byte) error {
+	t.RLock()
+	defer t.RUnlock()
+
+	mt, err := t.resolveMessageType()
+	if err != nil {
+		return err
+	}
+	return mt.Validate(t.model.Codec, record)
+}
+
+// Finds the latest registered MessageType for the topic's schema by FQN.
+func (t *Topic) resolveMessageType() (*document.MessageType, error) {
+	if t.model.Schema == "" {
+		return nil, ErrTopicNoSchema
+	}
+
+	mt, ok := document.Service.MessageType(t.model.Schema)
+	if !ok {
+		return nil, ErrTopicSchemaMissing
+	}
+	return mt, nil
+}
+
 // Roll segment file
 func (t *Topic) roll() {
 	// Create the future segment
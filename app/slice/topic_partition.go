@@ -2,14 +2,42 @@ package slice
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/genzai-io/sliced/app/fs"
 	"github.com/genzai-io/sliced/app/record"
 	"github.com/genzai-io/sliced/proto/store"
 )
 
+var (
+	ErrNoTail = errors.New("topic partition has no tail segment")
+)
+
+// Creates the tail segment of a topic. first is the log index of the first
+// record that goes into it. Set once the drives are started. Partitions
+// without a tail reject appends with ErrNoTail.
+var CreateTail func(topic *store.Topic, first uint64) (*fs.SegmentWriter, error)
+
+// Called by the cluster FSM once a topic is created and before the next
+// log entry is applied. Set by the node to backfill the change feed.
+var TopicCreated func(topic *Topic)
+
+// Returned when a record in an append batch is rejected.
+type RecordError struct {
+	Index int
+	Err   error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("record %d: %s", e.Index, e.Err.Error())
+}
+
 // An instance of a Topic definition.
 type TopicPartition struct {
+	mu       sync.Mutex
 	slice    *TopicSlice
 	key      string
 	segments record.Tree
@@ -21,11 +49,97 @@ type TopicPartition struct {
 
 	// Tail of the topic where new records go
 	tail *fs.SegmentWriter
+	// Position of every record in the tail by offset
+	positions []int64
 
 	// The next segments is initialized before it is needed
 	next *Segment
 }
 
+// Appends records to the tail segment. Every record is validated against
+// the topic schema first so a single bad record rejects the whole batch.
+func (tp *TopicPartition) Append(records [][]byte) error {
+	batch := make([]*record.Record, len(records))
+	for i, data := range records {
+		batch[i] = &record.Record{Data: data}
+	}
+	return tp.AppendRecords(batch)
+}
+
+// Same as Append for records that already carry a LogID or Slot.
+func (tp *TopicPartition) AppendRecords(records []*record.Record) error {
+	for i, r := range records {
+		if err := tp.slice.parent.Validate(r.Data); err != nil {
+			return &RecordError{Index: i, Err: err}
+		}
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	if tp.tail == nil {
+		if CreateTail == nil {
+			return ErrNoTail
+		}
+		model := tp.slice.parent.Model()
+		tail, err := CreateTail(&model, records[0].LogID)
+		if err != nil {
+			return err
+		}
+		tp.tail = tail
+	}
+	for _, r := range records {
+		pos := tp.tail.Size()
+		if _, err := tp.tail.Append(r); err != nil {
+			return err
+		}
+		tp.positions = append(tp.positions, pos)
+	}
+	return nil
+}
+
+// Reads up to count records starting at offset. Offsets number the records
+// of the partition from zero in the order they were appended.
+func (tp *TopicPartition) Read(offset uint64, count int) ([]*record.Record, error) {
+	tp.mu.Lock()
+	tail := tp.tail
+	if tail == nil || offset >= uint64(len(tp.positions)) || count <= 0 {
+		tp.mu.Unlock()
+		return nil, nil
+	}
+	pos := tp.positions[offset]
+	tp.mu.Unlock()
+
+	var records []*record.Record
+	err := tail.Scan(pos, func(r *record.Record) bool {
+		records = append(records, r)
+		return len(records) < count
+	})
+	return records, err
+}
+
+// Changes how the tail segment is synced.
+func (tp *TopicPartition) setLevel(level store.Level, interval uint32) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	if tp.tail != nil {
+		tp.tail.SetLevel(level, time.Duration(interval)*time.Millisecond)
+	}
+}
+
+// Closes the tail segment.
+func (tp *TopicPartition) close() {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	if tp.tail != nil {
+		tp.tail.Close()
+		tp.tail = nil
+		tp.positions = nil
+	}
+}
+
 func (tp *TopicPartition) prepareNext() {
 
 }
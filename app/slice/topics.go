@@ -0,0 +1,170 @@
+package slice
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/common/resp"
+	"github.com/genzai-io/sliced/proto/store"
+)
+
+var (
+	ErrTopicExists   = errors.New("topic already exists")
+	ErrTopicNotFound = errors.New("topic not found")
+	ErrTopicName     = errors.New("topic name not set")
+
+	ErrInvalidTopicSnapshot = errors.New("invalid topic snapshot")
+)
+
+// Topics of the cluster.
+var Topics = NewTopicStore()
+
+// Definitions of every topic by name. Topics are created and altered
+// through the cluster log so every node has the same definitions.
+type TopicStore struct {
+	mu     sync.RWMutex
+	nextID int64
+	byName map[string]*Topic
+}
+
+func NewTopicStore() *TopicStore {
+	return &TopicStore{
+		byName: make(map[string]*Topic),
+	}
+}
+
+// Gets a topic by name.
+func (s *TopicStore) Get(name string) (*Topic, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.byName[name]
+	return t, ok
+}
+
+// Names of every topic sorted.
+func (s *TopicStore) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.byName))
+	for name := range s.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Reads up to count records of a topic starting at offset.
+func (s *TopicStore) Read(name string, offset uint64, count int) ([]api.TopicRecord, error) {
+	t, ok := s.Get(name)
+	if !ok {
+		return nil, ErrTopicNotFound
+	}
+	records, err := t.Read(offset, count)
+	if err != nil {
+		return nil, err
+	}
+	read := make([]api.TopicRecord, len(records))
+	for i, r := range records {
+		read[i] = api.TopicRecord{Offset: offset + uint64(i), LogID: r.LogID, Data: r.Data}
+	}
+	return read, nil
+}
+
+// Creates a topic and assigns it the next ID. This must only be invoked
+// from a Raft FSM so every node assigns the same IDs.
+func (s *TopicStore) Create(model *store.Topic) (*Topic, error) {
+	if model.Name == "" {
+		return nil, ErrTopicName
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.byName[model.Name]; ok {
+		return nil, ErrTopicExists
+	}
+	s.nextID++
+	m := *model
+	m.Id = s.nextID
+	t := newTopic(&m)
+	s.byName[m.Name] = t
+	return t, nil
+}
+
+// Serializes every topic definition as a series of RESP arrays.
+// This is used by Raft snapshots.
+func (s *TopicStore) Marshal(b []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.byName))
+	for name := range s.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		model := s.byName[name].Model()
+		data, err := model.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		b = resp.AppendArray(b, 1)
+		b = resp.AppendBulk(b, data)
+	}
+	return b, nil
+}
+
+// Replaces every topic definition with the contents of Marshal. Topics
+// that still exist keep their open segments.
+func (s *TopicStore) Unmarshal(b []byte) error {
+	models := make([]*store.Topic, 0)
+
+	var (
+		args     [][]byte
+		complete bool
+		err      error
+	)
+	for len(b) > 0 {
+		_, complete, args, _, b, err = resp.ParseNextCommand(b, args[:0])
+		if err != nil {
+			return err
+		}
+		if !complete || len(args) != 1 {
+			return ErrInvalidTopicSnapshot
+		}
+		model := &store.Topic{}
+		if err := model.Unmarshal(args[0]); err != nil {
+			return err
+		}
+		models = append(models, model)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byName := make(map[string]*Topic, len(models))
+	nextID := int64(0)
+	for _, model := range models {
+		if t, ok := s.byName[model.Name]; ok && t.Model().Id == model.Id {
+			t.Lock()
+			t.model = *model
+			t.Unlock()
+			byName[model.Name] = t
+		} else {
+			byName[model.Name] = newTopic(model)
+		}
+		if model.Id > nextID {
+			nextID = model.Id
+		}
+	}
+	for name, t := range s.byName {
+		if byName[name] != t {
+			t.root.close()
+		}
+	}
+	s.byName = byName
+	s.nextID = nextID
+	return nil
+}
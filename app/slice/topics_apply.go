@@ -0,0 +1,280 @@
+package slice
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/events"
+	"github.com/genzai-io/sliced/app/record"
+	"github.com/genzai-io/sliced/common/resp"
+	"github.com/genzai-io/sliced/proto/store"
+)
+
+func init() {
+	api.RegisterApply(TopicCreateName, func() api.ApplyCommand { return &ApplyTopicCreate{} })
+	api.RegisterApply(TopicAlterName, func() api.ApplyCommand { return &ApplyTopicAlter{} })
+	api.RegisterApply(TopicAppendName, func() api.ApplyCommand { return &ApplyTopicAppend{} })
+}
+
+const (
+	TopicCreateName = "TOPICDEF"
+	TopicAlterName  = "TOPICALT"
+	TopicAppendName = "TOPICREC"
+)
+
+// Checks a new topic and returns the log entry that creates it.
+func (s *TopicStore) CreateEntry(name string, options []string) (api.ApplyCommand, error) {
+	if _, ok := s.Get(name); ok {
+		return nil, ErrTopicExists
+	}
+	if err := applyTopicOptions(&store.Topic{Name: name}, options); err != nil {
+		return nil, err
+	}
+	return &ApplyTopicCreate{Topic: name, Options: options}, nil
+}
+
+// Checks a change to a topic and returns the log entry that makes it.
+func (s *TopicStore) AlterEntry(name string, options []string) (api.ApplyCommand, error) {
+	t, ok := s.Get(name)
+	if !ok {
+		return nil, ErrTopicNotFound
+	}
+	model := t.Model()
+	if err := applyTopicOptions(&model, options); err != nil {
+		return nil, err
+	}
+	return &ApplyTopicAlter{Topic: name, Options: options}, nil
+}
+
+// Validates records and returns the log entry that appends them. The first
+// rejected record is returned as a *RecordError.
+func (s *TopicStore) AppendEntry(name string, records []string) (api.ApplyCommand, error) {
+	t, ok := s.Get(name)
+	if !ok {
+		return nil, ErrTopicNotFound
+	}
+	for i, data := range records {
+		if err := t.Validate([]byte(data)); err != nil {
+			return nil, &RecordError{Index: i, Err: err}
+		}
+	}
+	return &ApplyTopicAppend{
+		Topic:     name,
+		Timestamp: time.Now().UnixNano(),
+		Records:   records,
+	}, nil
+}
+
+// Changes model by SCHEMA, CODEC, VALIDATION and SYNC pairs. Validating
+// records requires a schema.
+func applyTopicOptions(model *store.Topic, options []string) error {
+	if len(options)%2 != 0 {
+		return api.ErrInvalidParam
+	}
+	for i := 0; i < len(options); i += 2 {
+		value := strings.ToUpper(options[i+1])
+		switch strings.ToUpper(options[i]) {
+		default:
+			return api.ErrInvalidParam
+
+		case "SCHEMA":
+			model.Schema = options[i+1]
+
+		case "CODEC":
+			switch value {
+			default:
+				return api.ErrInvalidParam
+			case "PROTOBUF":
+				model.Codec = store.Codec_PROTOBUF
+			case "JSON":
+				model.Codec = store.Codec_JSON
+			}
+
+		case "VALIDATION":
+			mode, ok := store.Topic_Validation_value[value]
+			if !ok {
+				return api.ErrInvalidParam
+			}
+			model.Validation = store.Topic_Validation(mode)
+
+		case "SYNC":
+			switch value {
+			case "ALWAYS":
+				model.Level, model.SyncInterval = store.Level_MISSION, 0
+			case "NEVER":
+				model.Level, model.SyncInterval = store.Level_BACKGROUND, 0
+			default:
+				ms, err := strconv.ParseUint(value, 10, 32)
+				if err != nil {
+					return api.ErrInvalidParam
+				}
+				model.Level, model.SyncInterval = store.Level_BUSINESS, uint32(ms)
+			}
+		}
+	}
+	if model.Validation != store.Topic_OFF && model.Schema == "" {
+		return ErrTopicNoSchema
+	}
+	return nil
+}
+
+func appendStrings(b []byte, values []string) []byte {
+	for _, value := range values {
+		b = resp.AppendBulkString(b, value)
+	}
+	return b
+}
+
+func parseStrings(args [][]byte) []string {
+	values := make([]string, len(args))
+	for i, arg := range args {
+		values[i] = string(arg)
+	}
+	return values
+}
+
+// Cluster log entry that creates a topic.
+type ApplyTopicCreate struct {
+	Topic   string
+	Options []string
+}
+
+func (c *ApplyTopicCreate) Name() string { return TopicCreateName }
+
+func (c *ApplyTopicCreate) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 2+len(c.Options))
+	b = resp.AppendBulkString(b, c.Name())
+	b = resp.AppendBulkString(b, c.Topic)
+	return appendStrings(b, c.Options)
+}
+
+func (c *ApplyTopicCreate) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return api.ErrInvalidParam
+	}
+	c.Topic = string(args[1])
+	c.Options = parseStrings(args[2:])
+	return nil
+}
+
+// Replies the ID of the topic.
+func (c *ApplyTopicCreate) Handle() api.CommandReply {
+	model := &store.Topic{Name: c.Topic, Level: store.Level_BUSINESS}
+	if err := applyTopicOptions(model, c.Options); err != nil {
+		return api.Err("ERR " + err.Error())
+	}
+	t, err := Topics.Create(model)
+	if err != nil {
+		return api.Err("ERR " + err.Error())
+	}
+	if TopicCreated != nil {
+		TopicCreated(t)
+	}
+	events.Publish(events.TopicCreated, c.Topic, "id", strconv.FormatInt(t.Model().Id, 10))
+	return api.Int(t.Model().Id)
+}
+
+// Cluster log entry that changes the schema or validation of a topic.
+type ApplyTopicAlter struct {
+	Topic   string
+	Options []string
+}
+
+func (c *ApplyTopicAlter) Name() string { return TopicAlterName }
+
+func (c *ApplyTopicAlter) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 2+len(c.Options))
+	b = resp.AppendBulkString(b, c.Name())
+	b = resp.AppendBulkString(b, c.Topic)
+	return appendStrings(b, c.Options)
+}
+
+func (c *ApplyTopicAlter) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return api.ErrInvalidParam
+	}
+	c.Topic = string(args[1])
+	c.Options = parseStrings(args[2:])
+	return nil
+}
+
+func (c *ApplyTopicAlter) Handle() api.CommandReply {
+	t, ok := Topics.Get(c.Topic)
+	if !ok {
+		return api.Err("ERR " + ErrTopicNotFound.Error())
+	}
+	model := t.Model()
+	if err := applyTopicOptions(&model, c.Options); err != nil {
+		return api.Err("ERR " + err.Error())
+	}
+	t.alter(&model)
+	return api.OK
+}
+
+// Cluster log entry that appends records to a topic. The timestamp is
+// assigned by the proposer so every node writes the same record IDs.
+type ApplyTopicAppend struct {
+	Topic     string
+	Timestamp int64
+	Records   []string
+}
+
+func (c *ApplyTopicAppend) Name() string { return TopicAppendName }
+
+func (c *ApplyTopicAppend) Marshal(b []byte) []byte {
+	b = resp.AppendArray(b, 3+len(c.Records))
+	b = resp.AppendBulkString(b, c.Name())
+	b = resp.AppendBulkString(b, c.Topic)
+	b = resp.AppendBulkInt64(b, c.Timestamp)
+	return appendStrings(b, c.Records)
+}
+
+func (c *ApplyTopicAppend) Unmarshal(b []byte) error {
+	args, _, err := resp.ParseCommand(b)
+	if err != nil {
+		return err
+	}
+	if len(args) < 3 {
+		return api.ErrInvalidParam
+	}
+	c.Timestamp, err = strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return err
+	}
+	c.Topic = string(args[1])
+	c.Records = parseStrings(args[3:])
+	return nil
+}
+
+// Replies the number of records appended. Records are validated again since
+// the schema or mode may have changed after the proposer checked them.
+func (c *ApplyTopicAppend) Handle() api.CommandReply {
+	t, ok := Topics.Get(c.Topic)
+	if !ok {
+		return api.Err("ERR " + ErrTopicNotFound.Error())
+	}
+	epoch := uint64(time.Duration(c.Timestamp) / time.Millisecond)
+	logID := api.Keyspace.CommitIndex()
+	records := make([]*record.Record, len(c.Records))
+	for i, data := range c.Records {
+		records[i] = &record.Record{
+			ID:    store.RecordID{Epoch: epoch, Seq: uint64(i)},
+			LogID: logID,
+			Data:  []byte(data),
+		}
+	}
+	if err := t.Append(records); err != nil {
+		return api.Err("ERR " + err.Error())
+	}
+	return api.Int(len(records))
+}
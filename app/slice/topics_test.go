@@ -0,0 +1,153 @@
+package slice
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/document"
+	"github.com/genzai-io/sliced/app/fs"
+	"github.com/genzai-io/sliced/proto/store"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+)
+
+func registerUser(t *testing.T) {
+	field := func(name string, number int32, typ descriptor.FieldDescriptorProto_Type) *descriptor.FieldDescriptorProto {
+		return &descriptor.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Type:     typ.Enum(),
+			Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+	}
+	set, err := proto.Marshal(&descriptor.FileDescriptorSet{
+		File: []*descriptor.FileDescriptorProto{{
+			Name:    proto.String("topictest/user.proto"),
+			Package: proto.String("topictest"),
+			Syntax:  proto.String("proto3"),
+			MessageType: []*descriptor.DescriptorProto{{
+				Name: proto.String("User"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("id", 1, descriptor.FieldDescriptorProto_TYPE_INT64),
+					field("name", 2, descriptor.FieldDescriptorProto_TYPE_STRING),
+				},
+			}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = document.Service.RegisterSchema("topictest", document.CompatNone, set, time.Now())
+	if err != nil && err != document.ErrSchemaDuplicate {
+		t.Fatal(err)
+	}
+}
+
+func TestTopicStore_Validation(t *testing.T) {
+	registerUser(t)
+	errTail := errors.New("tail")
+	CreateTail = func(topic *store.Topic, first uint64) (*fs.SegmentWriter, error) {
+		return nil, errTail
+	}
+	defer func() { CreateTail = nil }()
+
+	// Validating needs a schema
+	if _, err := Topics.CreateEntry("users", []string{"VALIDATION", "REJECT"}); err != ErrTopicNoSchema {
+		t.Fatalf("expected ErrTopicNoSchema, got %v", err)
+	}
+	entry, err := Topics.CreateEntry("users", []string{"SCHEMA", ".topictest.User", "CODEC", "JSON", "VALIDATION", "REJECT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := entry.Handle().(api.Int); !ok {
+		t.Fatal("expected the topic ID")
+	}
+	if _, err := Topics.CreateEntry("users", nil); err != ErrTopicExists {
+		t.Fatalf("expected ErrTopicExists, got %v", err)
+	}
+
+	records := []string{`{"id":"1","name":"bob"}`, `{"id":"2","email":"bob@example.com"}`}
+	_, err = Topics.AppendEntry("users", records)
+	if rerr, ok := err.(*RecordError); !ok || rerr.Index != 1 || !strings.Contains(rerr.Error(), "email") {
+		t.Fatalf("expected record 1 to be rejected on email, got %v", err)
+	}
+
+	// Checked again when applied
+	apply := &ApplyTopicAppend{Topic: "users", Records: records}
+	if reply, ok := apply.Handle().(api.Err); !ok || !strings.HasPrefix(string(reply), "ERR record 1:") {
+		t.Fatalf("expected record 1 to be rejected, got %v", reply)
+	}
+	apply.Records = records[:1]
+	if reply := apply.Handle(); reply != api.Err("ERR tail") {
+		t.Fatalf("expected the valid record to reach the tail, got %v", reply)
+	}
+
+	// Invalid records are appended in WARN mode
+	entry, err = Topics.AlterEntry("users", []string{"VALIDATION", "WARN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply := entry.Handle(); reply != api.OK {
+		t.Fatalf("expected OK, got %v", reply)
+	}
+	if _, err := Topics.AppendEntry("users", records); err != nil {
+		t.Fatal(err)
+	}
+
+	// Definitions survive a snapshot
+	b, err := Topics.Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewTopicStore()
+	if err := restored.Unmarshal(b); err != nil {
+		t.Fatal(err)
+	}
+	topic, ok := restored.Get("users")
+	if !ok {
+		t.Fatal("expected topic 'users'")
+	}
+	if model := topic.Model(); model.Validation != store.Topic_WARN || model.Schema != ".topictest.User" || model.Codec != store.Codec_JSON {
+		t.Fatalf("unexpected topic %v", model)
+	}
+}
+
+func TestTopicStore_Sync(t *testing.T) {
+	entry, err := Topics.CreateEntry("clicks", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.Handle()
+	topic, _ := Topics.Get("clicks")
+	if model := topic.Model(); model.Level != store.Level_BUSINESS || model.SyncInterval != 0 {
+		t.Fatalf("expected BUSINESS by default got %v", model)
+	}
+
+	for _, test := range []struct {
+		sync     string
+		level    store.Level
+		interval uint32
+	}{
+		{"50", store.Level_BUSINESS, 50},
+		{"always", store.Level_MISSION, 0},
+		{"NEVER", store.Level_BACKGROUND, 0},
+	} {
+		entry, err := Topics.AlterEntry("clicks", []string{"SYNC", test.sync})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reply := entry.Handle(); reply != api.OK {
+			t.Fatalf("expected OK, got %v", reply)
+		}
+		if model := topic.Model(); model.Level != test.level || model.SyncInterval != test.interval {
+			t.Fatalf("SYNC %s: unexpected topic %v", test.sync, model)
+		}
+	}
+	if _, err := Topics.AlterEntry("clicks", []string{"SYNC", "soon"}); err != api.ErrInvalidParam {
+		t.Fatalf("expected ErrInvalidParam, got %v", err)
+	}
+}
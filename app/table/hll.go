@@ -0,0 +1,246 @@
+package table
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc64"
+	"strings"
+
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/common/hyperloglog"
+)
+
+var (
+	ErrWrongType    = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	ErrInvalidHLL   = errors.New("INVALIDOBJ Corrupted HLL object detected")
+	ErrDumpPayload  = errors.New("DUMP payload version or checksum are wrong")
+	ErrBusyKey      = errors.New("BUSYKEY Target key name already exists")
+	ErrDumpDataType = errors.New("DUMP payload data type not supported")
+)
+
+// Prefix of every HyperLogLog value. Values are stored as the prefix
+// followed by the binary encoding of the sketch.
+const hllMagic = "HYLL"
+
+// Version of the DUMP payload format.
+const dumpVersion = 1
+
+var dumpCRCTable = crc64.MakeTable(crc64.ECMA)
+
+// IsHyperLogLog reports whether the value holds a HyperLogLog sketch.
+func IsHyperLogLog(value string) bool {
+	return strings.HasPrefix(value, hllMagic)
+}
+
+func decodeHLL(value string) (sk *hyperloglog.Sketch, err error) {
+	if !IsHyperLogLog(value) {
+		return nil, ErrWrongType
+	}
+	data := []byte(value[len(hllMagic):])
+	// version, p, b, sparse flag and a 4 byte length
+	if len(data) < 8 {
+		return nil, ErrInvalidHLL
+	}
+
+	// The sketch decoder trusts it's input
+	defer func() {
+		if r := recover(); r != nil {
+			sk, err = nil, ErrInvalidHLL
+		}
+	}()
+	sk = hyperloglog.New()
+	if err = sk.UnmarshalBinary(data); err != nil {
+		return nil, ErrInvalidHLL
+	}
+	return sk, nil
+}
+
+func encodeHLL(sk *hyperloglog.Sketch) (string, error) {
+	// Estimate merges the temporary set into the sparse list. The set is
+	// a map so this keeps the encoding identical on every node.
+	sk.Estimate()
+	data, err := sk.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	return hllMagic + string(data), nil
+}
+
+// Sketch stored at key. A nil sketch is returned if the key does not exist.
+func (s *Table) getHLL(key Key) (*hyperloglog.Sketch, *ValueItem, error) {
+	item := s.get(key)
+	if item == nil || item.expired(s.now()) {
+		return nil, nil, nil
+	}
+	sk, err := decodeHLL(item.Value)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sk, item, nil
+}
+
+// PFAdd adds the elements to the HyperLogLog stored at key, creating it if
+// it does not exist. Updated is true when the sketch was created or an
+// internal register was altered.
+func (s *Table) PFAdd(key Key, elements ...[]byte) (updated bool, err error) {
+	sk, item, err := s.getHLL(key)
+	if err != nil {
+		return false, err
+	}
+
+	var (
+		prev    string
+		expires int64
+	)
+	if sk == nil {
+		sk = hyperloglog.New()
+	} else {
+		prev, expires = item.Value, item.Expires
+	}
+	for _, e := range elements {
+		sk.Insert(e)
+	}
+
+	value, err := encodeHLL(sk)
+	if err != nil {
+		return false, err
+	}
+	if value == prev {
+		return false, nil
+	}
+	if _, _, err = s.Set(key, value, expires); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PFCount returns the approximated cardinality of the union of the
+// HyperLogLogs stored at keys. Missing keys count as empty sketches.
+func (s *Table) PFCount(keys ...Key) (uint64, error) {
+	var union *hyperloglog.Sketch
+	for _, key := range keys {
+		sk, _, err := s.getHLL(key)
+		if err != nil {
+			return 0, err
+		}
+		if sk == nil {
+			continue
+		}
+		if union == nil {
+			union = sk
+			continue
+		}
+		if err = union.Merge(sk); err != nil {
+			return 0, err
+		}
+	}
+	if union == nil {
+		return 0, nil
+	}
+	return union.Estimate(), nil
+}
+
+// PFMerge merges the HyperLogLogs stored at sources into dest. An existing
+// dest is included in the union.
+func (s *Table) PFMerge(dest Key, sources ...Key) error {
+	union, item, err := s.getHLL(dest)
+	if err != nil {
+		return err
+	}
+	var expires int64
+	if union == nil {
+		union = hyperloglog.New()
+	} else {
+		expires = item.Expires
+	}
+
+	for _, key := range sources {
+		sk, _, err := s.getHLL(key)
+		if err != nil {
+			return err
+		}
+		if sk == nil {
+			continue
+		}
+		if err = union.Merge(sk); err != nil {
+			return err
+		}
+	}
+
+	value, err := encodeHLL(union)
+	if err != nil {
+		return err
+	}
+	_, _, err = s.Set(dest, value, expires)
+	return err
+}
+
+// Dump serializes the value stored at key. The payload is the data type,
+// a format version, the value and a CRC-64 of everything before it.
+func (s *Table) Dump(key Key) (string, error) {
+	item := s.get(key)
+	if item == nil || item.expired(s.now()) {
+		return "", moved.ErrNotFound
+	}
+
+	dataType := moved.String
+	if IsHyperLogLog(item.Value) {
+		if _, err := decodeHLL(item.Value); err != nil {
+			return "", err
+		}
+		dataType = moved.HyperLogLog
+	}
+
+	var scratch [binary.MaxVarintLen64]byte
+	buf := make([]byte, 0, len(item.Value)+20)
+	buf = append(buf, byte(dataType), dumpVersion)
+	buf = append(buf, scratch[:binary.PutUvarint(scratch[:], uint64(len(item.Value)))]...)
+	buf = append(buf, item.Value...)
+	binary.LittleEndian.PutUint64(scratch[:8], crc64.Checksum(buf, dumpCRCTable))
+	buf = append(buf, scratch[:8]...)
+	return string(buf), nil
+}
+
+// Restore creates a key from a Dump payload. Expires is the absolute
+// expiration in unix seconds or 0 for none. An existing key is only
+// overwritten when replace is set.
+func (s *Table) Restore(key Key, expires int64, payload string, replace bool) error {
+	value, err := parseDump([]byte(payload))
+	if err != nil {
+		return err
+	}
+	if !replace {
+		if item := s.get(key); item != nil && !item.expired(s.now()) {
+			return ErrBusyKey
+		}
+	}
+	_, _, err = s.Set(key, value, expires)
+	return err
+}
+
+func parseDump(buf []byte) (string, error) {
+	if len(buf) < 11 {
+		return "", ErrDumpPayload
+	}
+	body, sum := buf[:len(buf)-8], buf[len(buf)-8:]
+	if buf[1] != dumpVersion || crc64.Checksum(body, dumpCRCTable) != binary.LittleEndian.Uint64(sum) {
+		return "", ErrDumpPayload
+	}
+
+	size, n := binary.Uvarint(body[2:])
+	if n <= 0 || size != uint64(len(body)-2-n) {
+		return "", ErrDumpPayload
+	}
+	value := string(body[2+n:])
+
+	switch moved.DataType(body[0]) {
+	case moved.String:
+		return value, nil
+	case moved.HyperLogLog:
+		if _, err := decodeHLL(value); err != nil {
+			return "", err
+		}
+		return value, nil
+	}
+	return "", ErrDumpDataType
+}
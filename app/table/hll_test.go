@@ -0,0 +1,128 @@
+package table
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+)
+
+func TestTable_PFAdd(t *testing.T) {
+	tbl := NewTable()
+
+	updated, err := tbl.PFAdd(StringKey("a"), []byte("x"), []byte("y"), []byte("z"))
+	if err != nil || !updated {
+		t.Fatalf("expected update got %v %v", updated, err)
+	}
+	updated, err = tbl.PFAdd(StringKey("a"), []byte("y"))
+	if err != nil || updated {
+		t.Fatalf("expected no update got %v %v", updated, err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		tbl.PFAdd(StringKey("b"), []byte(strconv.Itoa(i)))
+	}
+	count, err := tbl.PFCount(StringKey("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count < 980 || count > 1020 {
+		t.Fatalf("expected ~1000 got %d", count)
+	}
+
+	// Union with a missing key
+	count, _ = tbl.PFCount(StringKey("a"), StringKey("b"), StringKey("missing"))
+	if count < 983 || count > 1023 {
+		t.Fatalf("expected ~1003 got %d", count)
+	}
+
+	tbl.Set(StringKey("s"), "hello", 0)
+	if _, err := tbl.PFAdd(StringKey("s"), []byte("x")); err != ErrWrongType {
+		t.Fatalf("expected ErrWrongType got %v", err)
+	}
+	if _, err := tbl.PFCount(StringKey("s")); err != ErrWrongType {
+		t.Fatalf("expected ErrWrongType got %v", err)
+	}
+}
+
+func TestTable_PFMerge(t *testing.T) {
+	tbl := NewTable()
+	tbl.PFAdd(StringKey("a"), []byte("1"), []byte("2"))
+	tbl.PFAdd(StringKey("b"), []byte("2"), []byte("3"))
+
+	if err := tbl.PFMerge(StringKey("c"), StringKey("a"), StringKey("b")); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := tbl.PFCount(StringKey("c")); count != 3 {
+		t.Fatalf("expected 3 got %d", count)
+	}
+}
+
+func TestTable_DumpRestore(t *testing.T) {
+	tbl := NewTable()
+	tbl.PFAdd(StringKey("a"), []byte("1"), []byte("2"), []byte("3"))
+	tbl.Set(StringKey("s"), "hello", 0)
+
+	payload, err := tbl.Dump(StringKey("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.Restore(StringKey("a"), 0, payload, false); err != ErrBusyKey {
+		t.Fatalf("expected ErrBusyKey got %v", err)
+	}
+	if err := tbl.Restore(StringKey("b"), 0, payload, false); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := tbl.PFCount(StringKey("b")); count != 3 {
+		t.Fatalf("expected 3 got %d", count)
+	}
+
+	payload, _ = tbl.Dump(StringKey("s"))
+	if err := tbl.Restore(StringKey("s2"), 0, payload, false); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := tbl.Get(StringKey("s2")); v != "hello" {
+		t.Fatalf("expected hello got %s", v)
+	}
+
+	corrupt := []byte(payload)
+	corrupt[3] ^= 0xff
+	if err := tbl.Restore(StringKey("s3"), 0, string(corrupt), true); err != ErrDumpPayload {
+		t.Fatalf("expected ErrDumpPayload got %v", err)
+	}
+}
+
+func TestTable_SaveLoad(t *testing.T) {
+	tbl := NewTable()
+	tbl.PFAdd(StringKey("a"), []byte("1"), []byte("2"))
+	tbl.Set(StringKey("s"), "hello", 0)
+	tbl.Set(StringKey("e"), "expiring", 1<<40)
+	tbl.Set(IntKey(7), "int", 0)
+	tbl.Set(FloatKey(1.5), "float", 0)
+	tbl.Set(StringKey("7"), "string", 0)
+
+	var buf bytes.Buffer
+	if err := tbl.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewTable()
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Length() != 6 {
+		t.Fatalf("expected 6 items got %d", restored.Length())
+	}
+	if count, _ := restored.PFCount(StringKey("a")); count != 2 {
+		t.Fatalf("expected 2 got %d", count)
+	}
+	if item := restored.get(StringKey("e")); item == nil || item.Expires != 1<<40 {
+		t.Fatal("expected expiration to be restored")
+	}
+
+	// Keys keep their type
+	for key, value := range map[Key]string{IntKey(7): "int", FloatKey(1.5): "float", StringKey("7"): "string"} {
+		if v, err := restored.Get(key); err != nil || v != value {
+			t.Fatalf("%v: expected %s got %s %v", key, value, v, err)
+		}
+	}
+}
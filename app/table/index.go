@@ -39,6 +39,9 @@ type Index struct {
 
 	// Indexer projects keys from raw values and creates IndexItems
 	indexer Indexer
+	// Definition the indexer was created from. Only indexes with a
+	// definition are saved with snapshots.
+	def []byte
 }
 
 func (i *Index) Length() int {
@@ -99,6 +102,30 @@ func (idx *Index) clearCopy() *Index {
 	return nidx
 }
 
+// Entries of a value in the idx. Multi-valued fields have an entry for
+// every value of the field.
+func (idx *Index) entries(item *ValueItem) []IndexItem {
+	if m, ok := idx.indexer.(MultiIndexer); ok {
+		return m.IndexAll(idx, item)
+	}
+	if sk := idx.indexer.Index(idx, item); sk != nil {
+		return []IndexItem{sk}
+	}
+	return nil
+}
+
+// Adds the entries of a value to the idx.
+func (idx *Index) add(item *ValueItem) {
+	for _, sk := range idx.entries(item) {
+		item.Indexes = append(item.Indexes, sk)
+		if idx.btr != nil {
+			idx.btr.ReplaceOrInsert(sk)
+		} else if idx.rtr != nil {
+			idx.rtr.Insert(sk)
+		}
+	}
+}
+
 // rebuild rebuilds the idx
 // may need to be invoked from a worker if the data set is large
 func (idx *Index) rebuild() {
@@ -116,49 +143,15 @@ func (idx *Index) rebuild() {
 			return true
 		}
 
-		var sk IndexItem
-		if len(dbi.Indexes) > 0 {
-			for _, sec := range dbi.Indexes {
-				secIdx := sec.index()
-				if secIdx != nil {
-					secIdx.remove(sec)
-				}
-			}
-		}
-
-		switch idx.t {
-		case BTree:
-			if sk == nil {
-				sk = idx.indexer.Index(idx, dbi)
-				if sk != nil {
-					dbi.Indexes = append(dbi.Indexes, sk)
-				}
-			} else {
-				idx.btr.Delete(sk)
-			}
-
-			if sk != nil {
-				idx.btr.ReplaceOrInsert(sk)
-			} else {
-				// Ignored.
-			}
-
-		case RTree:
-			if sk == nil {
-				sk = idx.indexer.Index(idx, dbi)
-				if sk != nil {
-					dbi.Indexes = append(dbi.Indexes, sk)
-				}
-			} else {
-				idx.rtr.Remove(sk)
-			}
-
-			if sk != nil {
-				idx.rtr.Insert(sk)
-			} else {
-				// Ignored.
+		// Entries of other indexes stay
+		kept := dbi.Indexes[:0]
+		for _, sec := range dbi.Indexes {
+			if sec.index() != idx {
+				kept = append(kept, sec)
 			}
 		}
+		dbi.Indexes = kept
+		idx.add(dbi)
 		return true
 	})
 }
@@ -179,7 +172,14 @@ func (idx *Index) rebuild() {
 // There are some default less function that can be used such as
 // IndexString, IndexBinary, etc.
 func (s *Table) CreateIndex(name, pattern string, indexer Indexer) error {
-	return s.createIndex(BTree, name, pattern, indexer)
+	return s.createIndex(BTree, name, pattern, indexer, nil)
+}
+
+// CreateIndexDef builds a new idx like CreateIndex and keeps the definition
+// the indexer was created from. The definition is written by Save and Load
+// recreates the indexer from it with IndexerFromDef.
+func (s *Table) CreateIndexDef(name, pattern string, def []byte, indexer Indexer) error {
+	return s.createIndex(BTree, name, pattern, indexer, def)
 }
 
 // CreateSpatialIndex builds a new idx and populates it with items.
@@ -197,7 +197,7 @@ func (s *Table) CreateIndex(name, pattern string, indexer Indexer) error {
 // The IndexRect is a default function that can be used for the rect
 // parameter.
 func (s *Table) CreateSpatialIndex(name, pattern string, indexer Indexer) error {
-	return s.createIndex(RTree, name, pattern, indexer)
+	return s.createIndex(RTree, name, pattern, indexer, nil)
 }
 
 // createIndex is called by CreateIndex() and CreateSpatialIndex()
@@ -206,6 +206,7 @@ func (s *Table) createIndex(
 	name string,
 	pattern string,
 	indexer Indexer,
+	def []byte,
 ) error {
 	if name == "" {
 		// cannot create an idx without a name.
@@ -225,6 +226,7 @@ func (s *Table) createIndex(
 		pattern: pattern,
 		db:      s,
 		indexer: indexer,
+		def:     def,
 	}
 
 	// save the idx
@@ -22,6 +22,10 @@ const (
 // Project as single key from a value
 type KeyProjector func(item *ValueItem) Key
 
+// Projects every key of a multi-valued field from a value. A value gets an
+// index entry for each key.
+type MultiKeyProjector func(item *ValueItem) []Key
+
 //
 type Indexer interface {
 	// Parses raw RESP args
@@ -36,6 +40,13 @@ type Indexer interface {
 	Index(index *Index, item *ValueItem) IndexItem
 }
 
+// Indexer that may give a value more than one index entry.
+type MultiIndexer interface {
+	Indexer
+	// Every entry of a value. Nil when the value is skipped.
+	IndexAll(index *Index, item *ValueItem) []IndexItem
+}
+
 //
 //
 //
@@ -122,6 +133,13 @@ func JSONIndexer(path string, opts IndexOpts) *IndexField {
 //
 //
 func JSONComposite(fields... *IndexField) *CompositeIndex {
+	return Composite(fields...)
+}
+
+//
+//
+//
+func Composite(fields ...*IndexField) *CompositeIndex {
 	return &CompositeIndex{fields: fields}
 }
 
@@ -209,12 +227,38 @@ func NewIndexer(
 	}
 }
 
+// Creates an index field with an entry for every key the projector returns.
+// The projector should not return the same key twice for a value.
+func NewMultiIndexer(
+	name string,
+	opts IndexOpts,
+	projector MultiKeyProjector,
+) *IndexField {
+	return &IndexField{
+		name:  name,
+		opts:  opts,
+		multi: projector,
+		projector: func(item *ValueItem) Key {
+			if keys := projector(item); len(keys) > 0 {
+				return keys[0]
+			}
+			return SkipKey
+		},
+	}
+}
+
 // Meta data to describe the behavior of an index dimension
 type IndexField struct {
 	name      string
 	length    int
 	opts      IndexOpts
 	projector KeyProjector
+	multi     MultiKeyProjector
+}
+
+// Whether a value may have more than one entry.
+func (i *IndexField) IsMulti() bool {
+	return i.multi != nil
 }
 
 func (i *IndexField) ParseArgs(offset int, buf [][]byte) Key {
@@ -368,8 +412,28 @@ func (i *IndexField) Key(index *Index, item *ValueItem) Key {
 
 func (i *IndexField) Index(index *Index, item *ValueItem) IndexItem {
 	// Project a key from the value
-	val := i.projector(item)
+	return i.index(index, item, i.projector(item))
+}
+
+func (i *IndexField) IndexAll(index *Index, item *ValueItem) []IndexItem {
+	if i.multi == nil {
+		if sk := i.Index(index, item); sk != nil {
+			return []IndexItem{sk}
+		}
+		return nil
+	}
+
+	keys := i.multi(item)
+	entries := make([]IndexItem, 0, len(keys))
+	for _, key := range keys {
+		if sk := i.index(index, item, key); sk != nil {
+			entries = append(entries, sk)
+		}
+	}
+	return entries
+}
 
+func (i *IndexField) index(index *Index, item *ValueItem, val Key) IndexItem {
 	// Should we skip?
 	if val == SkipKey {
 		return nil
@@ -546,7 +610,7 @@ func (i *CompositeIndex) ParseArgs(offset int, buf [][]byte) Key {
 	case 2:
 		return Key2{
 			i.fields[0].ParseArgs(offset, buf),
-			i.fields[2].ParseArgs(offset+1, buf),
+			i.fields[1].ParseArgs(offset+1, buf),
 		}
 	}
 }
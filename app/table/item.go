@@ -2,7 +2,6 @@ package table
 
 import (
 	"errors"
-	"time"
 
 	"github.com/genzai-io/sliced"
 	"github.com/genzai-io/sliced/app/table/index/btree"
@@ -21,7 +20,9 @@ type ValueItem struct {
 	Expires int64
 	Slot    uint16
 	LogID   uint64
-	Indexes []IndexItem
+	// Table revision when the value was written
+	Revision uint64
+	Indexes  []IndexItem
 }
 
 // BTree key comparison.
@@ -32,8 +33,8 @@ func (dbi *ValueItem) Less(than btree.Item, ctx interface{}) bool {
 
 // expired evaluates id the value has expired. This will always return false when
 // the value does not have `opts.ex` set to true.
-func (dbi *ValueItem) expired() bool {
-	return dbi.Expires > 0 && time.Now().Unix() > dbi.Expires
+func (dbi *ValueItem) expired(now int64) bool {
+	return dbi.Expires > 0 && now > dbi.Expires
 	//return dbi.opts != nil && dbi.opts.ex && time.Now().After(dbi.opts.exat)
 }
 
@@ -1487,7 +1487,7 @@ func (k IntKey) LessThanItem(than btree.Item, item *ValueItem) bool {
 			} else if t.value == nil {
 				return true
 			} else {
-				return item.Key.LessThan(t.key)
+				return item.Key.LessThan(t.value.Key)
 			}
 		}
 	case FloatKey:
@@ -1506,7 +1506,7 @@ func (k IntKey) LessThanItem(than btree.Item, item *ValueItem) bool {
 			} else if t.value == nil {
 				return true
 			} else {
-				return item.Key.LessThan(t.key)
+				return item.Key.LessThan(t.value.Key)
 			}
 		}
 	case StringKey, *StringKey, *stringItem, StringMaxKey, *StringMaxKey:
@@ -1673,7 +1673,7 @@ func (k IntDescKey) LessThanItem(than btree.Item, item *ValueItem) bool {
 			} else if t.value == nil {
 				return true
 			} else {
-				return item.Key.LessThan(t.key)
+				return item.Key.LessThan(t.value.Key)
 			}
 		}
 	case IntKey:
@@ -1692,7 +1692,7 @@ func (k IntDescKey) LessThanItem(than btree.Item, item *ValueItem) bool {
 			} else if t.value == nil {
 				return true
 			} else {
-				return item.Key.LessThan(t.key)
+				return item.Key.LessThan(t.value.Key)
 			}
 		}
 	case FloatKey:
@@ -1711,7 +1711,7 @@ func (k IntDescKey) LessThanItem(than btree.Item, item *ValueItem) bool {
 			} else if t.value == nil {
 				return true
 			} else {
-				return item.Key.LessThan(t.key)
+				return item.Key.LessThan(t.value.Key)
 			}
 		}
 	case StringKey, *StringKey, *stringItem, StringMaxKey, *StringMaxKey:
@@ -2072,7 +2072,7 @@ func (k FloatDescKey) LessThanItem(than btree.Item, item *ValueItem) bool {
 			} else if t.value == nil {
 				return true
 			} else {
-				return item.Key.LessThan(t.key)
+				return item.Key.LessThan(t.value.Key)
 			}
 		}
 	case IntKey:
@@ -2091,7 +2091,7 @@ func (k FloatDescKey) LessThanItem(than btree.Item, item *ValueItem) bool {
 			} else if t.value == nil {
 				return true
 			} else {
-				return item.Key.LessThan(t.key)
+				return item.Key.LessThan(t.value.Key)
 			}
 		}
 	case StringKey, *StringKey, *stringItem, StringMaxKey, *StringMaxKey:
@@ -0,0 +1,156 @@
+package table
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/genzai-io/sliced/app/table/index/btree"
+)
+
+// Kind of change made to a key.
+type ChangeKind byte
+
+const (
+	ChangeSet ChangeKind = iota
+	ChangeDel
+	ChangeExpire
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeSet:
+		return "set"
+	case ChangeDel:
+		return "del"
+	case ChangeExpire:
+		return "expired"
+	}
+	return "unknown"
+}
+
+// A single change made to a key. Index is the commit index of the table
+// when the change was made which is the raft index of the log entry being
+// applied for replicated tables.
+type Change struct {
+	Kind     ChangeKind
+	Key      Key
+	Value    string
+	Revision uint64
+	Index    uint64
+}
+
+// Observers are called with the table lock held so they must not block or
+// call back into the table.
+type Observer func(change *Change)
+
+type observer struct {
+	pattern string
+	fn      Observer
+}
+
+// Registers an observer for changes to keys matching a glob pattern. The
+// returned function removes the observer.
+func (s *Table) Observe(pattern string, fn Observer) (cancel func()) {
+	o := &observer{pattern: pattern, fn: fn}
+	s.observersMu.Lock()
+	s.observers = append(s.observers, o)
+	s.observersMu.Unlock()
+
+	return func() {
+		s.observersMu.Lock()
+		defer s.observersMu.Unlock()
+		for i, existing := range s.observers {
+			if existing == o {
+				s.observers = append(s.observers[:i:i], s.observers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (s *Table) notify(kind ChangeKind, item *ValueItem) {
+	if s.loading {
+		return
+	}
+	s.observersMu.RLock()
+	observed := len(s.observers) > 0
+	s.observersMu.RUnlock()
+	if !observed {
+		return
+	}
+
+	change := &Change{
+		Kind:     kind,
+		Key:      item.Key,
+		Value:    item.Value,
+		Revision: item.Revision,
+		Index:    s.CommitIndex(),
+	}
+	if s.undo != nil {
+		s.undo.changes = append(s.undo.changes, change)
+		return
+	}
+	s.dispatch(change)
+}
+
+// Notifies the observers matching the key of a change.
+func (s *Table) dispatch(change *Change) {
+	s.observersMu.RLock()
+	defer s.observersMu.RUnlock()
+	for _, o := range s.observers {
+		if change.Key.Match(o.pattern) {
+			o.fn(change)
+		}
+	}
+}
+
+// Sets the commit index that changes are tagged with.
+func (s *Table) SetCommitIndex(index uint64) {
+	atomic.StoreUint64(&s.commitIndex, index)
+}
+
+func (s *Table) CommitIndex() uint64 {
+	return atomic.LoadUint64(&s.commitIndex)
+}
+
+// Sets the time in unix seconds that expirations are checked against.
+// Replicated tables are set to the time of the log entry being applied so
+// every node agrees on which keys expired. Zero uses the local clock.
+func (s *Table) SetNow(now int64) {
+	atomic.StoreInt64(&s.clock, now)
+}
+
+func (s *Table) now() int64 {
+	if now := atomic.LoadInt64(&s.clock); now != 0 {
+		return now
+	}
+	return time.Now().Unix()
+}
+
+// Keys that expired before now in unix seconds. At most limit keys are
+// returned.
+func (s *Table) Expired(now int64, limit int) []Key {
+	var keys []Key
+	s.exps.Ascend(func(i btree.Item) bool {
+		item := i.(*ValueItem)
+		if item.Expires > 0 && item.Expires < now {
+			keys = append(keys, item.Key)
+		}
+		return len(keys) < limit
+	})
+	return keys
+}
+
+// Removes the key if it expired before now in unix seconds. Expiring keys
+// through the cluster log keeps every member in agreement on when a key
+// disappears.
+func (s *Table) Expire(key Key, now int64) bool {
+	item := s.get(key)
+	if item == nil || item.Expires == 0 || item.Expires >= now {
+		return false
+	}
+	s.delete(item)
+	s.pruneTombstones()
+	s.notify(ChangeExpire, item)
+	return true
+}
@@ -1,9 +1,31 @@
 package table
 
-import "strconv"
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/genzai-io/sliced/app/table/index/btree"
+	"github.com/genzai-io/sliced/common/resp"
+)
+
+var (
+	ErrSnapshotRecord = errors.New("invalid snapshot record")
+	ErrSnapshotIndex  = errors.New("snapshot index definitions are not supported")
+	ErrSnapshotKey    = errors.New("key type can not be snapshot")
+)
+
+// Recreates the Indexer of an index from the definition passed to
+// CreateIndexDef. Set by the package that defines indexes.
+var IndexerFromDef func(def []byte) (Indexer, error)
 
 func (dbi *ValueItem) AppendValue(buf []byte) []byte {
-	return buf
+	return append(buf, dbi.Value...)
 }
 
 func appendArray(buf []byte, count int) []byte {
@@ -22,29 +44,288 @@ func appendBulkString(buf []byte, s string) []byte {
 	return buf
 }
 
+// Formats a primary key and names it's type. Strings are not named.
+func snapshotKey(key Key) (kind, value string, err error) {
+	switch k := key.(type) {
+	case StringKey:
+		return "", string(k), nil
+	case IntKey:
+		return "INT", strconv.FormatInt(int64(k), 10), nil
+	case FloatKey:
+		return "FLOAT", strconv.FormatFloat(float64(k), 'g', -1, 64), nil
+	}
+	return "", "", ErrSnapshotKey
+}
+
+// Parses a key formatted by snapshotKey.
+func parseSnapshotKey(kind string, value []byte) (Key, error) {
+	switch kind {
+	case "":
+		return StringKey(value), nil
+	case "INT":
+		v, err := strconv.ParseInt(string(value), 10, 64)
+		return IntKey(v), err
+	case "FLOAT":
+		v, err := strconv.ParseFloat(string(value), 64)
+		return FloatKey(v), err
+	}
+	return nil, ErrSnapshotRecord
+}
+
 // writeSetTo writes an value as a single SET record to the a bufio Writer.
-func (dbi *ValueItem) writeSetTo(buf []byte) []byte {
+// Keys that are not strings are followed by a KEYTYPE.
+func (dbi *ValueItem) writeSetTo(buf []byte) ([]byte, error) {
+	kind, key, err := snapshotKey(dbi.Key)
+	if err != nil {
+		return buf, err
+	}
+	count := 5
 	if dbi.Expires > 0 {
-		ex := dbi.Expires
-		buf = appendArray(buf, 5)
-		buf = appendBulkString(buf, "SET")
-		//buf = appendBulkString(buf, dbi.Extract)
-		buf = appendBulkString(buf, string(dbi.AppendValue(nil)))
-		buf = appendBulkString(buf, "EX")
-		buf = appendBulkString(buf, strconv.FormatUint(uint64(ex), 10))
-	} else {
-		buf = appendArray(buf, 3)
-		buf = appendBulkString(buf, "SET")
-		//buf = appendBulkString(buf, dbi.Extract)
-		buf = appendBulkString(buf, string(dbi.AppendValue(nil)))
+		count += 2
 	}
-	return buf
+	if kind != "" {
+		count += 2
+	}
+	buf = appendArray(buf, count)
+	buf = appendBulkString(buf, "SET")
+	buf = appendBulkString(buf, key)
+	buf = appendBulkString(buf, string(dbi.AppendValue(nil)))
+	if kind != "" {
+		buf = appendBulkString(buf, "KEYTYPE")
+		buf = appendBulkString(buf, kind)
+	}
+	if dbi.Expires > 0 {
+		buf = appendBulkString(buf, "EXAT")
+		buf = appendBulkString(buf, strconv.FormatUint(uint64(dbi.Expires), 10))
+	}
+	buf = appendBulkString(buf, "REV")
+	buf = appendBulkString(buf, strconv.FormatUint(dbi.Revision, 10))
+	return buf, nil
 }
 
 // writeSetTo writes an value as a single DEL record to the a bufio Writer.
 func (dbi *ValueItem) writeDeleteTo(buf []byte) []byte {
 	buf = appendArray(buf, 2)
 	buf = appendBulkString(buf, "DEL")
-	//buf = appendBulkString(buf, dbi.Extract)
+	buf = appendBulkString(buf, fmt.Sprint(dbi.Key))
 	return buf
 }
+
+// Save writes the table revision, the definition of every index created with
+// CreateIndexDef, every item as a SET record and every tombstone as a DEL
+// record. Expirations are written as absolute unix seconds so they survive
+// being restored on another node. Revisions, tombstones and items that
+// expired but were not removed yet are kept so WATCH behaves the same on
+// every node.
+func (s *Table) Save(w io.Writer) error {
+	var (
+		err error
+		buf []byte
+		bw  = bufio.NewWriter(w)
+	)
+	buf = appendArray(buf, 3)
+	buf = appendBulkString(buf, "REV")
+	buf = appendBulkString(buf, strconv.FormatUint(s.revision, 10))
+	buf = appendBulkString(buf, strconv.FormatUint(s.pruned, 10))
+	if _, err = bw.Write(buf); err != nil {
+		return err
+	}
+	keys := make([]Key, 0, len(s.tombstones))
+	for key := range s.tombstones {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return s.tombstones[keys[i]] < s.tombstones[keys[j]] })
+	for _, key := range keys {
+		kind, value, err := snapshotKey(key)
+		if err != nil {
+			return err
+		}
+		count := 4
+		if kind != "" {
+			count += 2
+		}
+		buf = appendArray(buf[:0], count)
+		buf = appendBulkString(buf, "DEL")
+		buf = appendBulkString(buf, value)
+		buf = appendBulkString(buf, "REV")
+		buf = appendBulkString(buf, strconv.FormatUint(s.tombstones[key], 10))
+		if kind != "" {
+			buf = appendBulkString(buf, "KEYTYPE")
+			buf = appendBulkString(buf, kind)
+		}
+		if _, err = bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	names := make([]string, 0, len(s.idxs))
+	for name, idx := range s.idxs {
+		if idx.def != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		idx := s.idxs[name]
+		buf = appendArray(buf[:0], 4)
+		buf = appendBulkString(buf, "INDEX")
+		buf = appendBulkString(buf, idx.name)
+		buf = appendBulkString(buf, idx.pattern)
+		buf = appendBulkString(buf, string(idx.def))
+		if _, err = bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	s.items.Ascend(func(item btree.Item) bool {
+		dbi, ok := item.(*ValueItem)
+		if !ok {
+			return true
+		}
+		if buf, err = dbi.writeSetTo(buf[:0]); err != nil {
+			return false
+		}
+		_, err = bw.Write(buf)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Load replaces the contents of the table with the records written by Save.
+// Keys are restored with the type they were saved with.
+func (s *Table) Load(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if err = s.DeleteAll(); err != nil {
+		return err
+	}
+	s.loading = true
+	defer func() { s.loading = false }()
+
+	var (
+		args     [][]byte
+		complete bool
+		revision uint64
+		pruned   uint64
+	)
+	for len(data) > 0 {
+		complete, args, _, data, err = resp.ReadNextCommand(data, args)
+		if err != nil {
+			return err
+		}
+		if !complete || len(args) < 2 {
+			return ErrSnapshotRecord
+		}
+
+		if strings.EqualFold(string(args[0]), "REV") {
+			if revision, err = strconv.ParseUint(string(args[1]), 10, 64); err != nil {
+				return ErrSnapshotRecord
+			}
+			if len(args) > 2 {
+				if pruned, err = strconv.ParseUint(string(args[2]), 10, 64); err != nil {
+					return ErrSnapshotRecord
+				}
+			}
+			continue
+		}
+		if strings.EqualFold(string(args[0]), "DEL") {
+			if err = s.loadTombstone(args[1:]); err != nil {
+				return err
+			}
+			continue
+		}
+		if strings.EqualFold(string(args[0]), "INDEX") {
+			if len(args) != 4 {
+				return ErrSnapshotRecord
+			}
+			if IndexerFromDef == nil {
+				return ErrSnapshotIndex
+			}
+			def := []byte(string(args[3]))
+			indexer, err := IndexerFromDef(def)
+			if err != nil {
+				return err
+			}
+			if err = s.CreateIndexDef(string(args[1]), string(args[2]), def, indexer); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(args) < 3 || len(args)%2 == 0 || !strings.EqualFold(string(args[0]), "SET") {
+			return ErrSnapshotRecord
+		}
+
+		var (
+			expires int64
+			rev     uint64
+			kind    string
+		)
+		for i := 3; i < len(args); i += 2 {
+			switch strings.ToUpper(string(args[i])) {
+			case "KEYTYPE":
+				kind = strings.ToUpper(string(args[i+1]))
+			case "EXAT":
+				expires, err = strconv.ParseInt(string(args[i+1]), 10, 64)
+			case "REV":
+				rev, err = strconv.ParseUint(string(args[i+1]), 10, 64)
+			default:
+				err = ErrSnapshotRecord
+			}
+			if err != nil {
+				return ErrSnapshotRecord
+			}
+		}
+		key, err := parseSnapshotKey(kind, args[1])
+		if err != nil {
+			return ErrSnapshotRecord
+		}
+		if _, _, err = s.Set(key, string(args[2]), expires); err != nil {
+			return err
+		}
+		if rev > 0 {
+			s.get(key).Revision = rev
+		}
+	}
+	if revision > 0 {
+		s.revision = revision
+	}
+	s.pruned = pruned
+	return nil
+}
+
+// Restores a tombstone from the arguments of a DEL record.
+func (s *Table) loadTombstone(args [][]byte) error {
+	if len(args)%2 == 0 {
+		return ErrSnapshotRecord
+	}
+	var (
+		rev  uint64
+		kind string
+		err  error
+	)
+	for i := 1; i < len(args); i += 2 {
+		switch strings.ToUpper(string(args[i])) {
+		case "KEYTYPE":
+			kind = strings.ToUpper(string(args[i+1]))
+		case "REV":
+			rev, err = strconv.ParseUint(string(args[i+1]), 10, 64)
+		default:
+			err = ErrSnapshotRecord
+		}
+		if err != nil {
+			return ErrSnapshotRecord
+		}
+	}
+	key, err := parseSnapshotKey(kind, args[0])
+	if err != nil || rev == 0 {
+		return ErrSnapshotRecord
+	}
+	if s.tombstones == nil {
+		s.tombstones = make(map[Key]uint64)
+	}
+	s.tombstones[key] = rev
+	return nil
+}
@@ -22,6 +22,14 @@ var defaultFreeList = new(btree.FreeList)
 //
 type Table struct {
 	commitIndex uint64
+	// Time of the log entry being applied in unix seconds
+	clock int64
+	// Incremented on every write and stamped on the written item
+	revision uint64
+	// Revision every deleted key was deleted at and the revision tombstones
+	// were last dropped at
+	tombstones map[Key]uint64
+	pruned     uint64
 
 	hash   map[Key]*ValueItem
 	items  *btree.BTree
@@ -30,6 +38,14 @@ type Table struct {
 	closed bool
 	mu     sync.RWMutex
 
+	// Notified of every change. Suppressed while loading a snapshot.
+	observers   []*observer
+	observersMu sync.RWMutex
+	loading     bool
+
+	// Changes since Begin
+	undo *undoLog
+
 	// Stats
 	itemMemory             uint64
 	itemMemoryUncompressed uint64
@@ -104,6 +120,7 @@ func (s *Table) DeleteAll() error {
 	s.items = btree.NewWithFreeList(btreeDegrees, defaultFreeList, nil)
 	s.exps = btree.NewWithFreeList(btreeDegrees, defaultFreeList, &exctx{s})
 	s.idxs = make(map[string]*Index)
+	s.tombstones, s.pruned = nil, 0
 	return nil
 }
 
@@ -112,26 +129,27 @@ func (s *Table) DeleteAll() error {
 // will be replaced with the new one, and return the previous value.
 func (s *Table) insert(item *ValueItem) *ValueItem {
 	var pdbi *ValueItem
+	s.revision++
+	item.Revision = s.revision
 	prev := s.items.ReplaceOrInsert(item)
 	//_i := -1
-	if prev != nil {
+	if prev == nil {
+		s.record(item.Key, nil)
+		delete(s.tombstones, item.Key)
+	} else {
 		// A previous value was removed from the keys tree. Let's
 		// fully delete this value from all indexes.
 		pdbi = prev.(*ValueItem)
-		item.Indexes = pdbi.Indexes
+		s.record(item.Key, pdbi)
 
 		if pdbi.Expires > 0 {
 			// Remove it from the exipres tree.
 			s.exps.Delete(pdbi)
 		}
 
-		for _, sec := range item.Indexes {
+		for _, sec := range pdbi.Indexes {
 			if sec != nil {
 				sec.index().remove(sec)
-				idxItem := sec.index().indexer.Index(sec.index(), item)
-				if idxItem != nil {
-					sec.index().btr.ReplaceOrInsert(idxItem)
-				}
 			}
 		}
 	}
@@ -140,32 +158,12 @@ func (s *Table) insert(item *ValueItem) *ValueItem {
 		// expires tree
 		s.exps.ReplaceOrInsert(item)
 	}
-	if prev == nil {
-		for _, idx := range s.idxs {
-			if !idx.match(item.Key) {
-				continue
-			}
-
-			sk := idx.indexer.Index(idx, item)
-			if sk == nil {
-				continue
-			}
-
-			item.Indexes = append(item.Indexes, sk)
-
-			if idx.btr != nil {
-				if sk != nil {
-					idx.btr.ReplaceOrInsert(sk)
-				} else {
-					// Ignored.
-				}
-			} else if idx.rtr != nil {
-				if sk != nil {
-					idx.rtr.Insert(sk)
-				} else {
-					// Ignored.
-				}
-			}
+	// The entries are projected again since the value changed and may
+	// have a different number of them
+	item.Indexes = nil
+	for _, idx := range s.idxs {
+		if idx.match(item.Key) {
+			idx.add(item)
 		}
 	}
 	// we must return the previous value to the caller.
@@ -183,6 +181,8 @@ func (s *Table) delete(item *ValueItem) *ValueItem {
 	prev := s.items.Delete(item)
 	if prev != nil {
 		pdbi = prev.(*ValueItem)
+		s.record(pdbi.Key, pdbi)
+		s.tombstone(pdbi.Key)
 		if pdbi.Expires > 0 {
 			// Remove it from the exipres tree.
 			s.exps.Delete(pdbi)
@@ -215,6 +215,7 @@ func (s *Table) Set(key Key, value string, expires int64) (previousValue string,
 	}
 	// Insert the value into the keys tree.
 	prev = s.insert(item)
+	s.notify(ChangeSet, item)
 
 	if prev == nil {
 		return "", false, nil
@@ -230,7 +231,7 @@ func (s *Table) Get(key Key) (val string, err error) {
 		return "", os.ErrNotExist
 	}
 	item := s.get(key)
-	if item == nil || item.expired() {
+	if item == nil || item.expired(s.now()) {
 		// The value does not exists or has expired. Let's assume that
 		// the caller is only interested in items that have not expired.
 		return "", moved.ErrNotFound
@@ -238,6 +239,16 @@ func (s *Table) Get(key Key) (val string, err error) {
 	return item.Value, nil
 }
 
+// Revision of the value stored at key or 0 if it does not exist or has
+// expired. Every write to a key gives it a new revision.
+func (s *Table) Revision(key Key) uint64 {
+	item := s.get(key)
+	if item == nil || item.expired(s.now()) {
+		return 0
+	}
+	return item.Revision
+}
+
 // Delete removes an value from the database based on the value's key. If the value
 // does not exist or if the value has expired then ErrNotFound is returned.
 //
@@ -249,14 +260,16 @@ func (s *Table) Delete(key Key) (val string, err error) {
 	if item == nil {
 		return "", moved.ErrNotFound
 	}
+	s.pruneTombstones()
 
 	// Even though the value has been deleted, we still want to check
 	// if it has expired. An expired value should not be returned.
-	if item.expired() {
+	if item.expired(s.now()) {
 		// The value exists in the tree, but has expired. Let's assume that
 		// the caller is only interested in items that have not expired.
 		return "", moved.ErrNotFound
 	}
+	s.notify(ChangeDel, item)
 	return item.Value, nil
 }
 
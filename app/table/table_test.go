@@ -1,6 +1,7 @@
 package table
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 	"time"
@@ -351,3 +352,216 @@ func TestDesc(t *testing.T) {
 
 func BenchmarkIndexJSON(b *testing.B) {
 }
+
+func TestRevision(t *testing.T) {
+	tbl := NewTable()
+	if rev := tbl.Revision(StringKey("a")); rev != 0 {
+		t.Fatalf("expected 0 for a missing key got %d", rev)
+	}
+
+	tbl.Set(StringKey("a"), "1", 0)
+	tbl.Set(StringKey("b"), "1", 0)
+	first := tbl.Revision(StringKey("a"))
+	tbl.Set(StringKey("a"), "1", 0)
+	if rev := tbl.Revision(StringKey("a")); rev == first || rev == 0 {
+		t.Fatalf("expected a new revision got %d", rev)
+	}
+	tbl.Delete(StringKey("b"))
+	if rev := tbl.Revision(StringKey("b")); rev != 0 {
+		t.Fatalf("expected 0 for a deleted key got %d", rev)
+	}
+
+	// Revisions survive a snapshot so the next write matches on every node
+	var buf bytes.Buffer
+	if err := tbl.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewTable()
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Revision(StringKey("a")) != tbl.Revision(StringKey("a")) {
+		t.Fatal("expected revision to be restored")
+	}
+	tbl.Set(StringKey("c"), "1", 0)
+	restored.Set(StringKey("c"), "1", 0)
+	if restored.Revision(StringKey("c")) != tbl.Revision(StringKey("c")) {
+		t.Fatal("expected table revision to be restored")
+	}
+}
+
+func TestChangedSince(t *testing.T) {
+	tbl := NewTable()
+	tbl.Set(StringKey("a"), "1", 0)
+	a, b := tbl.WatchRevision(StringKey("a")), tbl.WatchRevision(StringKey("b"))
+	if tbl.ChangedSince(StringKey("a"), a) || tbl.ChangedSince(StringKey("b"), b) {
+		t.Fatal("expected nothing to change")
+	}
+
+	// Written and deleted again
+	tbl.Set(StringKey("b"), "1", 0)
+	tbl.Delete(StringKey("b"))
+	if !tbl.ChangedSince(StringKey("b"), b) {
+		t.Fatal("expected b to be changed")
+	}
+	tbl.Delete(StringKey("a"))
+	if !tbl.ChangedSince(StringKey("a"), a) {
+		t.Fatal("expected a to be changed")
+	}
+
+	// Rolled back deletes keep the tombstone they replaced
+	b = tbl.WatchRevision(StringKey("b"))
+	tbl.Begin()
+	tbl.Set(StringKey("b"), "2", 0)
+	tbl.Delete(StringKey("b"))
+	tbl.Rollback()
+	if tbl.ChangedSince(StringKey("b"), b) {
+		t.Fatal("expected the rollback to leave b unchanged")
+	}
+
+	// Expired keys are changed and tombstones survive a snapshot
+	tbl.SetNow(1000)
+	tbl.Set(StringKey("c"), "1", 1001)
+	c := tbl.WatchRevision(StringKey("c"))
+	tbl.SetNow(1002)
+	var buf bytes.Buffer
+	if err := tbl.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewTable()
+	restored.SetNow(1002)
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []*Table{tbl, restored} {
+		if !table.ChangedSince(StringKey("c"), c) || !table.ChangedSince(StringKey("a"), a) {
+			t.Fatal("expected a and c to be changed")
+		}
+		if table.ChangedSince(StringKey("b"), b) {
+			t.Fatal("expected b to be unchanged")
+		}
+	}
+
+	// Dropped tombstones count as a delete of every missing key
+	for i := 0; i <= maxTombstones; i++ {
+		tbl.Set(IntKey(i), "1", 0)
+		tbl.Delete(IntKey(i))
+	}
+	if tbl.pruned == 0 || len(tbl.tombstones) > maxTombstones || !tbl.ChangedSince(StringKey("b"), b) {
+		t.Fatal("expected the tombstones to be dropped")
+	}
+}
+
+func TestObserve(t *testing.T) {
+	tbl := NewTable()
+	tbl.SetCommitIndex(7)
+
+	var changes []Change
+	cancel := tbl.Observe("user:*", func(change *Change) {
+		changes = append(changes, *change)
+	})
+
+	tbl.Set(StringKey("user:1"), "a", 0)
+	tbl.Set(StringKey("order:1"), "b", 0)
+	tbl.Delete(StringKey("user:1"))
+	tbl.Set(StringKey("user:2"), "c", 1)
+	if tbl.Expire(StringKey("user:1"), time.Now().Unix()) {
+		t.Fatal("expected a missing key to not expire")
+	}
+	if !tbl.Expire(StringKey("user:2"), time.Now().Unix()) {
+		t.Fatal("expected key to expire")
+	}
+
+	kinds := []ChangeKind{ChangeSet, ChangeDel, ChangeSet, ChangeExpire}
+	if len(changes) != len(kinds) {
+		t.Fatalf("expected %d changes got %d", len(kinds), len(changes))
+	}
+	for i, kind := range kinds {
+		if changes[i].Kind != kind {
+			t.Fatalf("change %d: expected %s got %s", i, kind, changes[i].Kind)
+		}
+		if changes[i].Index != 7 {
+			t.Fatalf("change %d: expected index 7 got %d", i, changes[i].Index)
+		}
+	}
+	if changes[0].Value != "a" || changes[0].Revision == 0 {
+		t.Fatalf("unexpected change %+v", changes[0])
+	}
+
+	// Loading a snapshot does not replay changes
+	var buf bytes.Buffer
+	tbl.Save(&buf)
+	tbl.Load(&buf)
+
+	cancel()
+	tbl.Set(StringKey("user:3"), "d", 0)
+	if len(changes) != len(kinds) {
+		t.Fatalf("expected no changes after cancel got %d", len(changes)-len(kinds))
+	}
+}
+
+func TestTable_SetNow(t *testing.T) {
+	tbl := NewTable()
+	tbl.Set(StringKey("a"), "1", 1000)
+
+	// Expirations follow the time of the log entry being applied
+	tbl.SetNow(999)
+	if _, err := tbl.Get(StringKey("a")); err != nil {
+		t.Fatalf("expected a to exist at 999 got %v", err)
+	}
+	tbl.SetNow(1001)
+	if _, err := tbl.Get(StringKey("a")); err == nil {
+		t.Fatal("expected a to be expired at 1001")
+	}
+
+	// The local clock is used otherwise
+	tbl.SetNow(0)
+	if _, err := tbl.Get(StringKey("a")); err == nil {
+		t.Fatal("expected a to be expired")
+	}
+}
+
+func TestMultiIndex(t *testing.T) {
+	db := NewTable()
+	tags := NewMultiIndexer("tags", IndexInt(false), func(item *ValueItem) []Key {
+		var keys []Key
+		gjson.Get(item.Value, "tags").ForEach(func(_, value gjson.Result) bool {
+			keys = append(keys, IntKey(value.Int()))
+			return true
+		})
+		return keys
+	})
+	entries := func(index string) string {
+		var out []string
+		db.Ascend(index, func(item IndexItem) bool {
+			out = append(out, fmt.Sprintf("%v:%v", item.Key(), item.Value().Key))
+			return true
+		})
+		return fmt.Sprint(out)
+	}
+
+	db.Set(StringKey("a"), `{"tags":[1,2],"name":"x"}`, 0)
+	db.Set(StringKey("b"), `{"tags":[2,3],"name":"y"}`, 0)
+	if err := db.CreateIndex("tags", "*", tags); err != nil {
+		t.Fatal(err)
+	}
+	// A second index leaves the entries of the first alone
+	if err := db.CreateIndex("name", "*", JSONIndexer("name", IncludeString)); err != nil {
+		t.Fatal(err)
+	}
+	if got := entries("tags"); got != "[1:a 2:a 2:b 3:b]" {
+		t.Fatalf("unexpected entries %s", got)
+	}
+
+	db.Set(StringKey("a"), `{"tags":[5],"name":"x"}`, 0)
+	if got := entries("tags"); got != "[2:b 3:b 5:a]" {
+		t.Fatalf("unexpected entries after update %s", got)
+	}
+	db.Delete(StringKey("b"))
+	if got := entries("tags"); got != "[5:a]" {
+		t.Fatalf("unexpected entries after delete %s", got)
+	}
+	if got := entries("name"); got != "[x:a]" {
+		t.Fatalf("unexpected name entries %s", got)
+	}
+}
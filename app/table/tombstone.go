@@ -0,0 +1,53 @@
+package table
+
+// Most tombstones a table keeps. Once there are more they're dropped
+// together and every key without one is taken to have been deleted at the
+// revision they were dropped.
+const maxTombstones = 1 << 16
+
+// Records the revision a key was deleted at so WATCH notices a key that was
+// written and deleted again. Deletes get a revision of their own.
+func (s *Table) tombstone(key Key) {
+	s.revision++
+	if s.tombstones == nil {
+		s.tombstones = make(map[Key]uint64)
+	}
+	s.tombstones[key] = s.revision
+}
+
+// Drops the tombstones once there are too many. Tombstones are kept while
+// changes can still be rolled back.
+func (s *Table) pruneTombstones() {
+	if s.undo != nil || len(s.tombstones) <= maxTombstones {
+		return
+	}
+	s.tombstones = nil
+	s.pruned = s.revision
+}
+
+// Revision to pass to ChangedSince. It is the revision of the value stored at
+// key or the table revision if the key does not exist or has expired.
+func (s *Table) WatchRevision(key Key) uint64 {
+	item := s.get(key)
+	if item == nil || item.expired(s.now()) {
+		return s.revision
+	}
+	return item.Revision
+}
+
+// Whether the key was written, deleted or expired since WatchRevision
+// returned revision. A key that expired at the revision it was watched at is
+// reported as changed.
+func (s *Table) ChangedSince(key Key, revision uint64) bool {
+	item := s.get(key)
+	if item != nil && !item.expired(s.now()) {
+		return item.Revision != revision
+	}
+	if item != nil && item.Revision >= revision {
+		return true
+	}
+	if deleted, ok := s.tombstones[key]; ok {
+		return deleted > revision
+	}
+	return s.pruned > 0 && s.pruned >= revision
+}
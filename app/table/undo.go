@@ -0,0 +1,82 @@
+package table
+
+// Changes made since Begin. The previous item of every written key is kept
+// so the changes can be undone and notifications are held so observers
+// never see a change that is rolled back.
+type undoLog struct {
+	revision uint64
+	previous []undoEntry
+	changes  []*Change
+}
+
+type undoEntry struct {
+	key Key
+	// Copy of the item before the change or nil if the key did not exist
+	item *ValueItem
+	// Revision the key was deleted at before the change
+	tombstone uint64
+}
+
+// Begin records every change until Commit or Rollback so a failed script
+// leaves no writes behind. The caller must hold the table lock.
+func (s *Table) Begin() {
+	s.undo = &undoLog{revision: s.revision}
+}
+
+// Commit keeps the changes made since Begin and notifies observers of them.
+func (s *Table) Commit() {
+	undo := s.undo
+	s.undo = nil
+	if undo == nil {
+		return
+	}
+	s.pruneTombstones()
+	for _, change := range undo.changes {
+		s.dispatch(change)
+	}
+}
+
+// Rollback restores every key written since Begin in reverse order.
+// Observers are not notified.
+func (s *Table) Rollback() {
+	undo := s.undo
+	s.undo = nil
+	if undo == nil {
+		return
+	}
+	for i := len(undo.previous) - 1; i >= 0; i-- {
+		entry := undo.previous[i]
+		if entry.item == nil {
+			s.delete(&ValueItem{Key: entry.key})
+		} else {
+			revision := entry.item.Revision
+			s.insert(entry.item)
+			entry.item.Revision = revision
+		}
+		if entry.tombstone > 0 {
+			s.tombstones[entry.key] = entry.tombstone
+		} else {
+			delete(s.tombstones, entry.key)
+		}
+	}
+	s.revision = undo.revision
+}
+
+// Records the item a key had before it's changed.
+func (s *Table) record(key Key, prev *ValueItem) {
+	if s.undo == nil {
+		return
+	}
+	entry := undoEntry{key: key, tombstone: s.tombstones[key]}
+	if prev != nil {
+		entry.item = &ValueItem{
+			Key:      prev.Key,
+			Value:    prev.Value,
+			Expires:  prev.Expires,
+			Slot:     prev.Slot,
+			LogID:    prev.LogID,
+			Revision: prev.Revision,
+		}
+	}
+	s.undo.previous = append(s.undo.previous, entry)
+}
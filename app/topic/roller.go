@@ -3,6 +3,7 @@ package topic
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/genzai-io/sliced"
@@ -24,6 +25,7 @@ type Roller struct {
 func newRoller(model *store.Roller) *Roller {
 	ctx, cancel := context.WithCancel(context.Background())
 	roller := &Roller{
+		model:  withDefaults(model),
 		ctx:    ctx,
 		cancel: cancel,
 		//topics: make(map[int64]*Topic),
@@ -34,6 +36,21 @@ func newRoller(model *store.Roller) *Roller {
 	return roller
 }
 
+// Copy of the model with the limits it leaves unset taken from the config.
+func withDefaults(model *store.Roller) *store.Roller {
+	m := *model
+	if m.MaxBytes == 0 {
+		m.MaxBytes = atomic.LoadUint64(&moved.RollerMaxBytes)
+	}
+	if m.MaxAge == 0 {
+		m.MaxAge = atomic.LoadUint64(&moved.RollerMaxAge)
+	}
+	if m.MaxCount == 0 {
+		m.MaxCount = atomic.LoadUint64(&moved.RollerMaxCount)
+	}
+	return &m
+}
+
 func (r *Roller) OnStart() error {
 	// Run the timer goroutine
 	r.wg.Add(1)
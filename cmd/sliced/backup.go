@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/backup"
+	"github.com/genzai-io/sliced/app/certs"
+	"github.com/genzai-io/sliced/app/node"
+	"github.com/genzai-io/sliced/common/redigo/redis"
+	"github.com/spf13/cobra"
+)
+
+func backupCommands() []*cobra.Command {
+	var (
+		base string
+		addr string
+	)
+	var cmdBackup = &cobra.Command{
+		Use:   "backup <archive>",
+		Short: "Writes a backup of the running daemon to an archive",
+		Long: `Asks the running daemon to write a consistent backup of its stores and sealed
+segments to an archive on the node. With --base only the segments that are
+missing from the base archive are included.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runBackup(addr, args[0], base); err != nil {
+				moved.Logger.Error().Err(err).Msg("backup failed")
+				os.Exit(1)
+			}
+		},
+	}
+	cmdBackup.Flags().StringVar(&base, "base", "", "Archive the backup is incremental to")
+	cmdBackup.Flags().StringVarP(&addr, "addr", "a", moved.ApiHost, "API address of the daemon")
+
+	var force bool
+	var cmdRestore = &cobra.Command{
+		Use:   "restore <archive> [incremental ...]",
+		Short: "Rebuilds the data directories of a stopped node from backups",
+		Long: `Restores a full backup followed by its incremental backups in order. The
+daemon must not be running. Existing stores and segments are only replaced
+with --force.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runRestore(args, force); err != nil {
+				moved.Logger.Error().Err(err).Msg("restore failed")
+				os.Exit(1)
+			}
+		},
+	}
+	cmdRestore.Flags().BoolVarP(&force, "force", "f", false, "Replace existing stores and segments")
+
+	return []*cobra.Command{cmdBackup, cmdRestore}
+}
+
+func runBackup(addr, path, base string) error {
+	moved.Configure()
+	if err := certs.Default.Configure(moved.TLSCertFile, moved.TLSKeyFile, moved.TLSCAFile); err != nil {
+		return err
+	}
+
+	// The daemon writes the archive so paths must not depend on this directory
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	args := []interface{}{path}
+	if base != "" {
+		if base, err = filepath.Abs(base); err != nil {
+			return err
+		}
+		args = append(args, "BASE", base)
+	}
+
+	c, err := node.DialRedis(addr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := node.Authenticate(c); err != nil {
+		return err
+	}
+	values, err := redis.Values(c.Do("BACKUP", args...))
+	if err != nil {
+		return err
+	}
+	reply := make(map[string]interface{}, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		key, _ := redis.String(values[i], nil)
+		reply[key] = values[i+1]
+	}
+	id, _ := redis.String(reply["id"], nil)
+	stores, _ := redis.Int64(reply["stores"], nil)
+	files, _ := redis.Int64(reply["files"], nil)
+	size, _ := redis.Int64(reply["bytes"], nil)
+	fmt.Printf("backup %s of %d stores and %d files (%d new bytes) written to %s\n",
+		id, stores, files, size, path)
+	return nil
+}
+
+func runRestore(paths []string, force bool) error {
+	moved.Configure()
+
+	l := moved.Lock()
+	if l.Err != nil {
+		return l.Err
+	}
+	if !l.Success {
+		return fmt.Errorf("daemon is running pid %d", l.Pid)
+	}
+	defer moved.Unlock()
+
+	m, err := backup.Restore(paths, force)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("restored backup %s of %s taken %s\n", m.ID, m.Node, m.Time)
+	return nil
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/core"
+	"github.com/genzai-io/sliced/app/node"
+	cmd_server "github.com/genzai-io/sliced/app/server/cmd"
+	"github.com/genzai-io/sliced/common/redbench"
+	"github.com/genzai-io/sliced/common/redigo/redis"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Workloads run when none are named.
+const defaultWorkloads = "ping,set,get,mix"
+
+// Commands of the workloads that ship with bench. Placeholders are replaced
+// on every request: {key} with a random key, {value} with the value, {n}
+// with the number of the request and {round} with the number of the round
+// of the workload's commands the request belongs to counting from 1.
+var benchWorkloads = map[string][]string{
+	"ping":  {"PING"},
+	"set":   {"SET {key} {value}"},
+	"get":   {"GET {key}"},
+	"topic": {"TOPIC APPEND bench {value}", "TOPIC READ bench FROM {round} COUNT 1"},
+	"queue": {"QUEUE ENQUEUE bench {value}", "QUEUE ACK bench {round}"},
+	"index": {`SET bench:{key} '{"n":{n}}'`, "INDEX QUERY bench MIN {n} LIMIT 10"},
+}
+
+// Commands run once before a workload. Objects they create may exist
+// already.
+var benchSetup = map[string][]string{
+	"topic": {"TOPIC CREATE bench"},
+	"queue": {"QUEUE CREATE bench"},
+	"index": {"CREATEINDEX bench bench:* JSON n"},
+}
+
+// Results of a bench run written with --json.
+type benchReport struct {
+	Version string            `json:"version"`
+	Time    time.Time         `json:"time"`
+	Addr    string            `json:"addr"`
+	Local   bool              `json:"local"`
+	Keys    int               `json:"keys"`
+	Size    int               `json:"size"`
+	Results []redbench.Report `json:"results"`
+	Skipped map[string]string `json:"skipped,omitempty"`
+}
+
+type bench struct {
+	client *client
+
+	workloads []string
+	commands  []string
+	requests  int
+	clients   int
+	pipeline  int
+	keys      int
+	size      int
+	ratio     string
+	local     bool
+	json      bool
+}
+
+func benchCommand() *cobra.Command {
+	b := &bench{client: &client{}}
+	var cmdBench = &cobra.Command{
+		Use:   "bench",
+		Short: "Measures the throughput and latency of the daemon",
+		Long: `Runs workloads against the API of the daemon, or of a node started in this
+process with --local, and reports the requests per second and the latency
+percentiles of every workload.
+
+Workloads: ping, set, get, mix (SET and GET at --ratio), topic, which appends
+to and reads the topic "bench", queue, which enqueues to and acknowledges the
+queue "bench", and index, which sets JSON values and queries the index "bench"
+over them. Workloads the node does not serve are skipped. Other commands are
+measured with --command where {key}, {value}, {n} and {round} are replaced on
+every request with a random key, the value, the number of the request and the
+number of the round of the workload's commands.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			// Only the commands run when they are given without workloads
+			if len(b.commands) > 0 && !cmd.Flags().Changed("tests") {
+				b.workloads = nil
+			}
+			if err := b.run(); err != nil {
+				fmt.Fprintf(os.Stderr, "(error) %s\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+	flags := cmdBench.Flags()
+	flags.StringVarP(&b.client.addr, "addr", "a", moved.ApiHost, "API address of the daemon")
+	flags.StringVar(&b.client.user, "user", "", "User to AUTH as. Defaults to the cluster user")
+	flags.StringVar(&b.client.password, "password", "", "Password to AUTH with. Defaults to the cluster password")
+	flags.StringSliceVarP(&b.workloads, "tests", "t", strings.Split(defaultWorkloads, ","), "Workloads to run")
+	flags.StringArrayVar(&b.commands, "command", nil, "Command to measure. Repeat to measure several")
+	flags.IntVarP(&b.requests, "requests", "n", 100000, "Requests of every workload")
+	flags.IntVarP(&b.clients, "clients", "c", 50, "Connections sending requests at once")
+	flags.IntVarP(&b.pipeline, "pipeline", "P", 1, "Requests sent by a connection before reading the replies")
+	flags.IntVarP(&b.keys, "keys", "r", 100000, "Random keys to spread the requests over")
+	flags.IntVarP(&b.size, "size", "d", 3, "Bytes of the values")
+	flags.StringVar(&b.ratio, "ratio", "1:10", "SET to GET ratio of the mix workload")
+	flags.BoolVar(&b.local, "local", false, "Start a node in this process and measure it")
+	flags.BoolVar(&b.json, "json", false, "Write the results as JSON")
+	return cmdBench
+}
+
+func (b *bench) run() error {
+	workloads, err := b.templates()
+	if err != nil {
+		return err
+	}
+
+	if b.local {
+		stop, err := b.startLocal()
+		if err != nil {
+			return err
+		}
+		defer stop()
+	} else if err := b.client.open(); err != nil {
+		return err
+	}
+	defer b.client.Close()
+
+	report := &benchReport{
+		Version: moved.VersionStr,
+		Time:    time.Now().UTC(),
+		Addr:    b.client.addr,
+		Local:   b.local,
+		Keys:    b.keys,
+		Size:    b.size,
+	}
+	opts := &redbench.Options{
+		Requests: b.requests,
+		Clients:  b.clients,
+		Pipeline: b.pipeline,
+		Dial:     node.DialNet,
+	}
+	value := strings.Repeat("x", b.size)
+
+	for _, w := range workloads {
+		if reason := b.probe(w, value); reason != "" {
+			if report.Skipped == nil {
+				report.Skipped = make(map[string]string)
+			}
+			report.Skipped[w.name] = reason
+			if !b.json {
+				fmt.Printf("%s: skipped, %s\n", w.name, reason)
+			}
+			continue
+		}
+
+		result, err := redbench.Run(w.name, b.client.addr, opts, b.prep, b.fill(w.commands, value))
+		if err != nil {
+			return err
+		}
+		r := result.Report()
+		report.Results = append(report.Results, r)
+		if !b.json {
+			printBenchReport(r)
+		}
+	}
+
+	if b.json {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	}
+	return nil
+}
+
+type benchWorkload struct {
+	name     string
+	setup    [][]string
+	commands [][]string
+}
+
+// Workloads to run in the order they were named followed by the commands.
+func (b *bench) templates() ([]benchWorkload, error) {
+	var workloads []benchWorkload
+	for _, name := range b.workloads {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		var commands []string
+		if name == "mix" {
+			sets, gets, err := parseRatio(b.ratio)
+			if err != nil {
+				return nil, err
+			}
+			for i := 0; i < sets; i++ {
+				commands = append(commands, benchWorkloads["set"]...)
+			}
+			for i := 0; i < gets; i++ {
+				commands = append(commands, benchWorkloads["get"]...)
+			}
+		} else if commands = benchWorkloads[name]; commands == nil {
+			return nil, fmt.Errorf("unknown workload '%s'", name)
+		}
+		w, err := newBenchWorkload(name, benchSetup[name], commands)
+		if err != nil {
+			return nil, err
+		}
+		workloads = append(workloads, w)
+	}
+	for _, command := range b.commands {
+		w, err := newBenchWorkload(command, nil, []string{command})
+		if err != nil {
+			return nil, err
+		}
+		workloads = append(workloads, w)
+	}
+	if len(workloads) == 0 {
+		return nil, errors.New("no workloads to run")
+	}
+	return workloads, nil
+}
+
+func newBenchWorkload(name string, setup, commands []string) (benchWorkload, error) {
+	w := benchWorkload{name: name}
+	var err error
+	if w.setup, err = splitCommands(setup); err != nil {
+		return w, err
+	}
+	w.commands, err = splitCommands(commands)
+	return w, err
+}
+
+func splitCommands(commands []string) ([][]string, error) {
+	var split [][]string
+	for _, command := range commands {
+		args, err := splitArgs(command)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", command, err)
+		}
+		if len(args) == 0 {
+			return nil, errors.New("empty command")
+		}
+		split = append(split, args)
+	}
+	return split, nil
+}
+
+// Parses a "sets:gets" ratio.
+func parseRatio(ratio string) (sets, gets int, err error) {
+	parts := strings.Split(ratio, ":")
+	if len(parts) == 2 {
+		sets, err = strconv.Atoi(parts[0])
+		if err == nil {
+			gets, err = strconv.Atoi(parts[1])
+		}
+	}
+	if len(parts) != 2 || err != nil || sets < 0 || gets < 0 || sets+gets == 0 {
+		return 0, 0, fmt.Errorf("invalid ratio '%s'", ratio)
+	}
+	return sets, gets, nil
+}
+
+// Replaces the placeholders of a command.
+func expand(args []string, key, value string, n, round int64) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		if strings.IndexByte(arg, '{') < 0 {
+			out[i] = arg
+			continue
+		}
+		arg = strings.Replace(arg, "{key}", key, -1)
+		arg = strings.Replace(arg, "{value}", value, -1)
+		arg = strings.Replace(arg, "{round}", strconv.FormatInt(round, 10), -1)
+		out[i] = strings.Replace(arg, "{n}", strconv.FormatInt(n, 10), -1)
+	}
+	return out
+}
+
+func (b *bench) key() string {
+	return "key:" + strconv.Itoa(rand.Intn(b.keys))
+}
+
+// Appends the commands of a workload in turn. Called by every client at once.
+func (b *bench) fill(commands [][]string, value string) func([]byte) []byte {
+	var n int64
+	return func(buf []byte) []byte {
+		i := atomic.AddInt64(&n, 1) - 1
+		count := int64(len(commands))
+		args := expand(commands[int(i%count)], b.key(), value, i, i/count+1)
+		return redbench.AppendCommand(buf, args...)
+	}
+}
+
+// Runs the setup of a workload and sends every command of it once. Returns
+// why the workload cannot run when the node does not serve one of them.
+func (b *bench) probe(w benchWorkload, value string) string {
+	conn, err := b.client.conn(b.client.addr)
+	if err != nil {
+		return err.Error()
+	}
+	for _, args := range w.setup {
+		_, err := conn.Do(args[0], commandArgs(args)...)
+		if e, ok := err.(redis.Error); ok && !exists(e) {
+			return string(e)
+		} else if err != nil && !ok {
+			b.client.drop(b.client.addr)
+			return err.Error()
+		}
+	}
+	for _, args := range w.commands {
+		args = expand(args, b.key(), value, 0, 1)
+		_, err := conn.Do(args[0], commandArgs(args)...)
+		if e, ok := err.(redis.Error); ok && strings.Contains(string(e), "not found") {
+			return "the node does not serve " + strings.ToUpper(args[0])
+		} else if err != nil {
+			if _, ok := err.(redis.Error); !ok {
+				b.client.drop(b.client.addr)
+				return err.Error()
+			}
+		}
+	}
+	return ""
+}
+
+// Whether a setup command failed because what it creates exists.
+func exists(err redis.Error) bool {
+	return strings.Contains(string(err), "already exists") ||
+		strings.HasSuffix(string(err), moved.ErrIndexExists.Error())
+}
+
+// Arguments of a command after its name.
+func commandArgs(args []string) []interface{} {
+	values := make([]interface{}, len(args)-1)
+	for i, arg := range args[1:] {
+		values[i] = arg
+	}
+	return values
+}
+
+// Authenticates the connections of the clients.
+func (b *bench) prep(conn net.Conn) bool {
+	user, password := b.client.user, b.client.password
+	if password == "" {
+		password = moved.ClusterPassword
+	}
+	if user == "" {
+		user = moved.ClusterUser
+	}
+	if password == "" {
+		return true
+	}
+	if _, err := conn.Write(redbench.AppendCommand(nil, "AUTH", user, password)); err != nil {
+		return false
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	return err == nil && strings.HasPrefix(line, "+")
+}
+
+// Starts a node bootstrapped in a temporary home directory with the API on a
+// free local port. Stop shuts it down and removes the directory.
+func (b *bench) startLocal() (stop func(), err error) {
+	home, err := ioutil.TempDir("", "sliced-bench")
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		os.RemoveAll(home)
+		return nil, err
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	// Flags and the config file do not apply to the local node
+	moved.LogLevel = int(zerolog.ErrorLevel)
+	moved.HomeDir = home
+	viper.Set("data.path", home+"/data")
+	viper.Set("store.path", home+"/store")
+	viper.Set("api.host", addr)
+	viper.Set("bootstrap", true)
+	if err := moved.Configure(); err != nil {
+		os.RemoveAll(home)
+		return nil, err
+	}
+	// Keep the results alone on stdout
+	moved.Logger = moved.Logger.Output(os.Stderr)
+
+	if err := core.Instance.Start(); err != nil {
+		os.RemoveAll(home)
+		return nil, err
+	}
+	server := cmd_server.NewServer()
+	stop = func() {
+		server.Stop()
+		core.Instance.Stop()
+		os.RemoveAll(home)
+	}
+	if err := server.Start(); err != nil {
+		core.Instance.Stop()
+		os.RemoveAll(home)
+		return nil, err
+	}
+
+	for deadline := time.Now().Add(10 * time.Second); !core.Instance.Cluster.IsLeader(); time.Sleep(50 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			stop()
+			return nil, errors.New("local node did not become the leader")
+		}
+	}
+	b.client.addr = addr
+	return stop, nil
+}
+
+func printBenchReport(r redbench.Report) {
+	us := func(v int64) string { return (time.Duration(v) * time.Microsecond).String() }
+	fmt.Printf("%s: %.2f requests per second\n", r.Name, r.Throughput)
+	fmt.Printf("  %d requests in %.2fs, %d clients, pipeline %d\n", r.Requests, r.Seconds, r.Clients, r.Pipeline)
+	fmt.Printf("  latency p50 %s  p90 %s  p99 %s  p99.9 %s  max %s\n",
+		us(r.Latency.P50), us(r.Latency.P90), us(r.Latency.P99), us(r.Latency.P999), us(r.Latency.Max))
+	if r.Errors > 0 {
+		fmt.Printf("  %d errors: %s\n", r.Errors, r.FirstError)
+	}
+}
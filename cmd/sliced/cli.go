@@ -0,0 +1,750 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/api"
+	"github.com/genzai-io/sliced/app/certs"
+	_ "github.com/genzai-io/sliced/app/cmd"
+	"github.com/genzai-io/sliced/app/node"
+	"github.com/genzai-io/sliced/app/ring"
+	"github.com/genzai-io/sliced/common/prompt"
+	"github.com/genzai-io/sliced/common/raft"
+	"github.com/genzai-io/sliced/common/redigo/redis"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+)
+
+// Redirects followed for a single command.
+const maxRedirects = 5
+
+func cliCommand() *cobra.Command {
+	c := &client{}
+	var cmdCli = &cobra.Command{
+		Use:   "cli [command ...]",
+		Short: "Runs commands against the daemon",
+		Long: `Connects to the API of the daemon and runs a command or, without one, reads
+commands from the terminal. Commands complete with TAB and replies are
+printed the way redis-cli prints them. Commands sent to a node that does not
+own them follow the MOVED and ASK redirects and go to the raft leader when the
+node is not the leader.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := c.open(); err != nil {
+				moved.Logger.Error().Err(err).Msg("cli failed")
+				os.Exit(1)
+			}
+			defer c.Close()
+
+			if len(args) > 0 {
+				if err := c.run(os.Stdout, args); err != nil {
+					os.Exit(1)
+				}
+				return
+			}
+			c.repl()
+		},
+	}
+	cmdCli.PersistentFlags().StringVarP(&c.addr, "addr", "a", moved.ApiHost, "API address of the daemon")
+	cmdCli.PersistentFlags().StringVar(&c.user, "user", "", "User to AUTH as. Defaults to the cluster user")
+	cmdCli.PersistentFlags().StringVar(&c.password, "password", "", "Password to AUTH with. Defaults to the cluster password")
+
+	cmdCli.AddCommand(
+		raftCommand(c),
+		dbCommand(c),
+		topicCommand(c),
+		ringCommand(),
+	)
+	return cmdCli
+}
+
+// Connections to the members of a cluster. Commands go to addr and follow
+// the redirects of the members.
+type client struct {
+	addr     string
+	user     string
+	password string
+
+	conns map[string]redis.Conn
+}
+
+func (c *client) open() error {
+	// Keep the replies readable
+	moved.LogLevel = int(zerolog.WarnLevel)
+	moved.Configure()
+	if err := certs.Default.Configure(moved.TLSCertFile, moved.TLSKeyFile, moved.TLSCAFile); err != nil {
+		return err
+	}
+	_, err := c.conn(c.addr)
+	return err
+}
+
+// Connection to addr dialed and authenticated on first use.
+func (c *client) conn(addr string) (redis.Conn, error) {
+	if conn, ok := c.conns[addr]; ok {
+		return conn, nil
+	}
+	conn, err := node.DialRedis(addr)
+	if err != nil {
+		return nil, err
+	}
+	if c.password != "" {
+		user := c.user
+		if user == "" {
+			user = moved.ClusterUser
+		}
+		_, err = conn.Do("AUTH", user, c.password)
+	} else {
+		err = node.Authenticate(conn)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if c.conns == nil {
+		c.conns = make(map[string]redis.Conn)
+	}
+	c.conns[addr] = conn
+	return conn, nil
+}
+
+// Drops the connection to addr.
+func (c *client) drop(addr string) {
+	if conn, ok := c.conns[addr]; ok {
+		conn.Close()
+		delete(c.conns, addr)
+	}
+}
+
+func (c *client) Close() {
+	for addr := range c.conns {
+		c.drop(addr)
+	}
+}
+
+// Sends a command to addr and follows the redirects of the cluster. MOVED
+// makes the new member the default for later commands.
+func (c *client) Do(name string, args ...interface{}) (interface{}, error) {
+	return c.do(c.addr, name, args...)
+}
+
+func (c *client) do(addr, name string, args ...interface{}) (interface{}, error) {
+	asking := false
+	for i := 0; ; i++ {
+		conn, err := c.conn(addr)
+		if err != nil {
+			return nil, err
+		}
+		if asking {
+			if _, err := conn.Do("ASKING"); err != nil {
+				return nil, err
+			}
+			asking = false
+		}
+
+		reply, err := conn.Do(name, args...)
+		if err != nil {
+			if _, ok := err.(redis.Error); !ok {
+				// The connection is broken
+				c.drop(addr)
+			}
+			if i == maxRedirects {
+				return reply, err
+			}
+		}
+
+		switch redirect, to := redirected(err); redirect {
+		case "MOVED":
+			addr = to
+			c.addr = to
+		case "ASK":
+			addr = to
+			asking = true
+		case "LEADER":
+			leader, lerr := redis.String(conn.Do("LEADER"))
+			if lerr != nil || leader == "" || leader == addr {
+				return reply, err
+			}
+			addr = leader
+		default:
+			return reply, err
+		}
+	}
+}
+
+// Kind of redirect an error asks for and the member to redirect to.
+func redirected(err error) (string, string) {
+	e, ok := err.(redis.Error)
+	if !ok {
+		return "", ""
+	}
+	fields := strings.Fields(string(e))
+	switch {
+	case len(fields) == 3 && (fields[0] == "MOVED" || fields[0] == "ASK"):
+		return fields[0], fields[2]
+	case strings.Contains(string(e), raft.ErrNotLeader.Error()):
+		return "LEADER", ""
+	}
+	return "", ""
+}
+
+// Runs a command and prints the reply. SUBSCRIBE and PSUBSCRIBE print
+// messages until interrupted.
+func (c *client) run(w io.Writer, args []string) error {
+	name := strings.ToUpper(args[0])
+	params := make([]interface{}, len(args)-1)
+	for i, arg := range args[1:] {
+		params[i] = arg
+	}
+
+	switch name {
+	case "SUBSCRIBE", "PSUBSCRIBE":
+		return c.follow(w, name, params...)
+	}
+
+	reply, err := c.Do(name, params...)
+	if err != nil {
+		if _, ok := err.(redis.Error); !ok {
+			fmt.Fprintf(w, "(error) %s\n", err)
+			return err
+		}
+		reply = err
+	}
+	printReply(w, reply)
+	if _, ok := reply.(redis.Error); ok {
+		return reply.(redis.Error)
+	}
+	return nil
+}
+
+// Sends a command that streams replies and prints them until interrupted.
+func (c *client) follow(w io.Writer, name string, args ...interface{}) error {
+	addr := c.addr
+	conn, err := c.conn(addr)
+	if err != nil {
+		return err
+	}
+	if err := conn.Send(name, args...); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+
+	// The connection is left in push mode so it is closed when done
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			conn.Close()
+		}
+	}()
+	defer func() {
+		c.drop(addr)
+		close(interrupt)
+	}()
+
+	fmt.Fprintln(w, "Reading messages... (press Ctrl-C to quit)")
+	for {
+		reply, err := conn.Receive()
+		if err != nil {
+			if _, ok := err.(redis.Error); !ok {
+				return nil
+			}
+			reply = err
+		}
+		printReply(w, reply)
+	}
+}
+
+// Records read from a topic by a single TOPIC READ.
+const tailCount = 100
+
+// How long tail waits for new records once it has read every record.
+const tailInterval = 250 * time.Millisecond
+
+// Reads the records of a topic from offset and prints them until interrupted.
+func (c *client) tail(w io.Writer, name string, offset uint64) error {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	fmt.Fprintln(w, "Reading records... (press Ctrl-C to quit)")
+	for {
+		values, err := redis.Values(c.Do("TOPIC", "READ", name, "FROM", offset, "COUNT", tailCount))
+		if err != nil {
+			return err
+		}
+		for _, value := range values {
+			record, err := redis.Values(value, nil)
+			if err != nil || len(record) != 3 {
+				return errors.New("invalid TOPIC READ reply")
+			}
+			at, _ := redis.Uint64(record[0], nil)
+			data, _ := redis.Bytes(record[2], nil)
+			fmt.Fprintf(w, "%d) %s\n", at, data)
+			offset = at + 1
+		}
+		if len(values) == tailCount {
+			continue
+		}
+		select {
+		case <-interrupt:
+			return nil
+		case <-time.After(tailInterval):
+		}
+	}
+}
+
+// Reads commands from the terminal until Ctrl-D or quit.
+func (c *client) repl() {
+	fd := int(os.Stdin.Fd())
+	p := prompt.New(os.Stdin, os.Stdout)
+	p.Echo = prompt.IsTerminal(fd)
+	p.Complete = complete
+
+	for {
+		var restore func()
+		if p.Echo {
+			restore, _ = prompt.MakeRaw(fd)
+		}
+		line, err := p.Line(c.addr + "> ")
+		if restore != nil {
+			restore()
+		}
+		if err == prompt.ErrInterrupted {
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		args, err := splitArgs(line)
+		if err != nil {
+			fmt.Printf("(error) %s\n", err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(args[0]) {
+		case "QUIT", "EXIT":
+			return
+		case "HELP":
+			printHelp(os.Stdout, args[1:])
+		case "CONNECT":
+			if len(args) != 2 {
+				fmt.Println("(error) usage: connect addr")
+				continue
+			}
+			if _, err := c.conn(args[1]); err != nil {
+				fmt.Printf("(error) %s\n", err)
+				continue
+			}
+			c.addr = args[1]
+		default:
+			c.run(os.Stdout, args)
+		}
+	}
+}
+
+// Commands of the cli itself.
+var builtins = map[string]string{
+	"HELP":    "HELP [command]",
+	"CONNECT": "CONNECT addr",
+	"QUIT":    "QUIT",
+}
+
+// Help of every command keyed by name.
+func commandHelp() map[string]string {
+	help := make(map[string]string, len(api.Commands)/2+len(builtins))
+	for name, command := range api.Commands {
+		help[strings.ToUpper(name)] = command.Help()
+	}
+	for name, h := range builtins {
+		help[name] = h
+	}
+	return help
+}
+
+// Completes command names and the subcommands listed in their help.
+func complete(args []string) []string {
+	help := commandHelp()
+	word := strings.ToUpper(args[len(args)-1])
+
+	switch len(args) {
+	case 1:
+		var candidates []string
+		for name := range help {
+			if strings.HasPrefix(name, word) {
+				candidates = append(candidates, name)
+			}
+		}
+		return candidates
+	case 2:
+		return subcommands(help[strings.ToUpper(args[0])], word)
+	}
+	return nil
+}
+
+// Subcommands in help starting with prefix. Help lists alternatives
+// separated by "|" which start with the subcommand in upper case.
+func subcommands(help, prefix string) []string {
+	var candidates []string
+	for i, alt := range strings.Split(help, "|") {
+		fields := strings.Fields(alt)
+		// The first alternative starts with the command
+		if i == 0 && len(fields) > 0 {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 || fields[0] != strings.ToUpper(fields[0]) || strings.ContainsAny(fields[0], "[]") {
+			continue
+		}
+		if strings.HasPrefix(fields[0], prefix) {
+			candidates = append(candidates, fields[0])
+		}
+	}
+	return candidates
+}
+
+func printHelp(w io.Writer, args []string) {
+	help := commandHelp()
+	if len(args) > 0 {
+		for _, arg := range args {
+			if h, ok := help[strings.ToUpper(arg)]; ok && h != "" {
+				fmt.Fprintln(w, h)
+			} else {
+				fmt.Fprintf(w, "no help for '%s'\n", arg)
+			}
+		}
+		return
+	}
+
+	names := make([]string, 0, len(help))
+	for name, h := range help {
+		if h != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "%s\t%s\n", name, help[name])
+	}
+	tw.Flush()
+}
+
+// Splits a line into arguments. Arguments with spaces are quoted with double
+// or single quotes. Double quoted arguments take Go escapes.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	for i := 0; i < len(line); {
+		switch line[i] {
+		case ' ', '\t':
+			i++
+
+		case '"':
+			end := i + 1
+			for ; end < len(line) && line[end] != '"'; end++ {
+				if line[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(line) {
+				return nil, errors.New("unbalanced quotes")
+			}
+			arg, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			i = end + 1
+
+		case '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unbalanced quotes")
+			}
+			args = append(args, line[i+1:i+1+end])
+			i += end + 2
+
+		default:
+			end := strings.IndexAny(line[i:], " \t")
+			if end < 0 {
+				end = len(line) - i
+			}
+			args = append(args, line[i:i+end])
+			i += end
+		}
+	}
+	return args, nil
+}
+
+// Prints a reply the way redis-cli does with nested arrays numbered and
+// indented. Text of many lines like INFO prints as is.
+func printReply(w io.Writer, reply interface{}) {
+	if text, ok := reply.([]byte); ok && strings.Contains(string(text), "\n") {
+		fmt.Fprintln(w, strings.TrimRight(strings.Replace(string(text), "\r\n", "\n", -1), "\n"))
+		return
+	}
+	for _, line := range formatReply(reply) {
+		fmt.Fprintln(w, line)
+	}
+}
+
+func formatReply(reply interface{}) []string {
+	switch v := reply.(type) {
+	case nil:
+		return []string{"(nil)"}
+	case redis.Error:
+		return []string{"(error) " + string(v)}
+	case error:
+		return []string{"(error) " + v.Error()}
+	case int64:
+		return []string{"(integer) " + strconv.FormatInt(v, 10)}
+	case string:
+		return []string{v}
+	case []byte:
+		return []string{strconv.Quote(string(v))}
+	case []interface{}:
+		if len(v) == 0 {
+			return []string{"(empty array)"}
+		}
+		width := len(strconv.Itoa(len(v)))
+		var lines []string
+		for i, item := range v {
+			label := fmt.Sprintf("%*d) ", width, i+1)
+			pad := strings.Repeat(" ", len(label))
+			for j, line := range formatReply(item) {
+				if j == 0 {
+					lines = append(lines, label+line)
+				} else {
+					lines = append(lines, pad+line)
+				}
+			}
+		}
+		return lines
+	}
+	return []string{fmt.Sprintf("%v", reply)}
+}
+
+// Exits when a step of an admin subcommand fails.
+func check(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "(error) %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// Runs a single command for an admin subcommand and exits when it fails.
+func (c *client) exec(args ...string) {
+	check(c.open())
+	defer c.Close()
+	if c.run(os.Stdout, args) != nil {
+		c.Close()
+		os.Exit(1)
+	}
+}
+
+func raftCommand(c *client) *cobra.Command {
+	var cmdRaft = &cobra.Command{
+		Use:   "raft",
+		Short: "Manages the membership of the cluster",
+	}
+
+	cmdRaft.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "Prints the leader and members of the cluster",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			check(c.open())
+			defer c.Close()
+
+			leader, err := redis.String(c.Do("LEADER"))
+			check(err)
+			values, err := redis.Values(c.Do("RAFTCONFIG"))
+			check(err)
+			if len(values) == 0 {
+				check(errors.New("empty raft configuration"))
+			}
+			index, _ := redis.Int64(values[0], nil)
+
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintf(tw, "leader\t%s\n", leader)
+			fmt.Fprintf(tw, "index\t%d\n\n", index)
+			fmt.Fprintf(tw, "ID\tADDRESS\tSUFFRAGE\t\n")
+			for _, v := range values[1:] {
+				server, _ := redis.Strings(v, nil)
+				if len(server) != 3 {
+					continue
+				}
+				role := ""
+				if server[1] == leader {
+					role = "leader"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", server[0], server[1], server[2], role)
+			}
+			tw.Flush()
+		},
+	})
+
+	var nonvoter bool
+	var cmdJoin = &cobra.Command{
+		Use:   "join <addr>",
+		Short: "Adds the member at addr to the cluster",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			voter := "1"
+			if nonvoter {
+				voter = "0"
+			}
+			c.exec("JOIN", args[0], voter)
+		},
+	}
+	cmdJoin.Flags().BoolVar(&nonvoter, "nonvoter", false, "Join without a vote")
+
+	cmdRaft.AddCommand(cmdJoin, &cobra.Command{
+		Use:   "remove <addr>",
+		Short: "Removes the member at addr from the cluster",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			c.exec("REMOVE", args[0])
+		},
+	})
+	return cmdRaft
+}
+
+func dbCommand(c *client) *cobra.Command {
+	var cmdDB = &cobra.Command{
+		Use:   "db",
+		Short: "Manages databases",
+	}
+	cmdDB.AddCommand(&cobra.Command{
+		Use:   "create <name>",
+		Short: "Creates a database",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			c.exec("DB", "CREATE", args[0])
+		},
+	}, &cobra.Command{
+		Use:   "list",
+		Short: "Lists the databases",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			c.exec("DB", "LIST")
+		},
+	})
+	return cmdDB
+}
+
+func topicCommand(c *client) *cobra.Command {
+	var cmdTopic = &cobra.Command{
+		Use:   "topic",
+		Short: "Manages topics",
+	}
+
+	var schema, codec, validation, sync string
+	var cmdCreate = &cobra.Command{
+		Use:   "create <name>",
+		Short: "Creates a topic",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			params := []string{"TOPIC", "CREATE", args[0]}
+			for _, option := range []struct{ name, value string }{
+				{"SCHEMA", schema},
+				{"CODEC", codec},
+				{"VALIDATION", validation},
+				{"SYNC", sync},
+			} {
+				if option.value != "" {
+					params = append(params, option.name, option.value)
+				}
+			}
+			c.exec(params...)
+		},
+	}
+	flags := cmdCreate.Flags()
+	flags.StringVar(&schema, "schema", "", "Message type the records are validated against")
+	flags.StringVar(&codec, "codec", "", "Encoding of the records: PROTOBUF or JSON")
+	flags.StringVar(&validation, "validation", "", "What happens to invalid records: OFF, WARN or REJECT")
+	flags.StringVar(&sync, "sync", "", "When appends are synced to disk: ALWAYS, NEVER or a number of milliseconds")
+
+	cmdTopic.AddCommand(cmdCreate, &cobra.Command{
+		Use:   "list",
+		Short: "Lists the topics",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			c.exec("TOPIC", "LIST")
+		},
+	})
+
+	var from uint64
+	var cmdTail = &cobra.Command{
+		Use:   "tail <name>",
+		Short: "Prints the records of a topic and then the records appended to it until interrupted",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			check(c.open())
+			defer c.Close()
+			check(c.tail(os.Stdout, args[0], from))
+		},
+	}
+	cmdTail.Flags().Uint64Var(&from, "from", 0, "Offset of the first record to print")
+	cmdTopic.AddCommand(cmdTail)
+	return cmdTopic
+}
+
+func ringCommand() *cobra.Command {
+	var cmdRing = &cobra.Command{
+		Use:   "ring",
+		Short: "Plans how the slots of a database spread across its slices",
+	}
+
+	var slices int32
+	var cmdShow = &cobra.Command{
+		Use:   "show [key ...]",
+		Short: "Prints the slot ranges of a balanced ring and the slice of each key",
+		Run: func(cmd *cobra.Command, args []string) {
+			r := ring.Balanced(slices)
+			fmt.Print(r)
+			for _, key := range args {
+				slot := ring.Slot([]byte(ring.Key(key)))
+				fmt.Printf("%s -> slot %d slice %d\n", key, slot, r.Slots[slot])
+			}
+		},
+	}
+	cmdShow.Flags().Int32VarP(&slices, "slices", "n", 1, "Number of slices")
+
+	var from, to int32
+	var cmdRebalance = &cobra.Command{
+		Use:   "rebalance",
+		Short: "Prints the slot moves that rebalance a ring onto more or fewer slices",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			plan, err := ring.Balanced(from).Migrate(ring.Balanced(to))
+			check(err)
+			fmt.Print(ring.RebalanceString(plan))
+			moves := int32(0)
+			for _, task := range plan.Tasks {
+				moves += task.Count
+			}
+			fmt.Printf("%d of %d slots move\n", moves, ring.Slots)
+		},
+	}
+	cmdRebalance.Flags().Int32Var(&from, "from", 1, "Number of slices now")
+	cmdRebalance.Flags().Int32Var(&to, "to", 2, "Number of slices after")
+
+	cmdRing.AddCommand(cmdShow, cmdRebalance)
+	return cmdRing
+}
@@ -5,8 +5,10 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/genzai-io/sliced/app/core"
+	"github.com/genzai-io/sliced/common/pid"
 	"github.com/genzai-io/sliced/common/service"
 	"github.com/spf13/cobra"
 
@@ -41,22 +43,34 @@ func main() {
 				moved.Unlock()
 				moved.Logger.Info().Msg("daemon is not running")
 				return
+			} else if l.Err != nil {
+				moved.Logger.Error().Err(l.Err).Msgf("failed to read %s", moved.PIDPath())
+				os.Exit(1)
 			} else {
-				moved.Logger.Info().Msgf("daemon pid %d", l.Pid)
+				moved.Logger.Info().Msgf("daemon pid %d -- localhost:%d", l.Pid, l.Port)
 			}
 		},
 	}
 
 	var force bool
+	var timeout time.Duration
 	var cmdStop = &cobra.Command{
 		Use:   "stop",
 		Short: "Stops the daemon process if it's running",
-		Long:  `Determines the daemon PID from the daemon pid lock file and sends a SIGTERM signal if running.`,
-		Args:  cobra.MinimumNArgs(0),
+		Long: `Determines the daemon PID from the daemon pid lock file and sends a SIGTERM signal if running.
+Waits for the daemon to exit for up to the timeout.`,
+		Args: cobra.MinimumNArgs(0),
 		Run: func(cmd *cobra.Command, args []string) {
-			stop(force)
+			stop(force, timeout)
 		},
 	}
+	cmdStop.Flags().DurationVarP(
+		&timeout,
+		"timeout",
+		"t",
+		30*time.Second,
+		"Time to wait for the daemon to exit gracefully",
+	)
 	cmdStop.Flags().BoolVarP(
 		&force,
 		"force",
@@ -80,6 +94,10 @@ func main() {
 		},
 	})
 	cmdRoot.AddCommand(cmdStart, cmdStop, cmdStatus)
+	cmdRoot.AddCommand(backupCommands()...)
+	cmdRoot.AddCommand(zfsCommand())
+	cmdRoot.AddCommand(cliCommand())
+	cmdRoot.AddCommand(benchCommand())
 
 	//moved.BindCLI(cmdRoot)
 	//moved.BindCLI(cmdStart)
@@ -139,17 +157,17 @@ func configureStart(cmd *cobra.Command) {
 		"Log level",
 	)
 	cmd.Flags().StringVarP(
-		&moved.DataDir,
+		&moved.PIDName,
 		"pid",
 		"p",
-		"0",
+		moved.PIDName,
 		"Name of PID file",
 	)
 	cmd.Flags().StringVarP(
 		&moved.DataDir,
 		"data.path",
 		"d",
-		"",
+		moved.DataDir,
 		"Path to persist slice data",
 	)
 	cmd.Flags().StringVarP(
@@ -175,6 +193,10 @@ func start(cmd *cobra.Command, args []string) {
 	if !moved.Console {
 		// Ensure only 1 instance through PID lock
 		lockResult := moved.Lock()
+		if lockResult.Err != nil {
+			moved.Logger.Error().Err(lockResult.Err).Msgf("failed to lock %s", moved.PIDPath())
+			return
+		}
 		if !lockResult.Success {
 			moved.Logger.Error().Msgf("process already running pid:%d -- localhost:%d", lockResult.Pid, lockResult.Port)
 			return
@@ -193,7 +215,7 @@ func start(cmd *cobra.Command, args []string) {
 	app.Wait()
 }
 
-func stop(force bool) {
+func stop(force bool, timeout time.Duration) {
 	// Ensure only 1 instance.
 	l := moved.Lock()
 	if l.Success {
@@ -201,36 +223,44 @@ func stop(force bool) {
 		moved.Logger.Info().Msg("daemon is not running")
 		return
 	}
+	if l.Err != nil {
+		moved.Logger.Error().Err(l.Err).Msgf("failed to read %s", moved.PIDPath())
+		os.Exit(1)
+	}
+	if l.Pid <= 0 {
+		moved.Logger.Info().Msg("daemon is not running")
+		return
+	}
 
-	if l.Pid > 0 {
-		process, err := os.FindProcess(l.Pid)
-		if err != nil {
-			moved.Logger.Info().Msgf("failed to find daemon pid %d", l.Pid)
-			moved.Logger.Error().Err(err)
-		} else if process == nil {
-			moved.Logger.Info().Msgf("failed to find daemon pid %d", l.Pid)
-		} else {
-			if force {
-				moved.Logger.Info().Msgf("killing daemon pid %d", l.Pid)
-				err = process.Kill()
-				if err != nil {
-					moved.Logger.Error().Err(err)
-				} else {
-					moved.Logger.Info().Msg("daemon was killed")
-				}
-			} else {
-				moved.Logger.Info().Msgf("sending SIGTERM signal to pid %d", l.Pid)
-				err := process.Signal(syscall.SIGTERM)
-				if err != nil {
-					moved.Logger.Error().Err(err)
-				} else {
-					moved.Logger.Info().Msgf("SIGTERM pid %d", l.Pid)
-				}
-			}
+	process, err := os.FindProcess(l.Pid)
+	if err != nil {
+		moved.Logger.Info().Msgf("failed to find daemon pid %d", l.Pid)
+		moved.Logger.Error().Err(err)
+		os.Exit(1)
+	}
+
+	if force {
+		moved.Logger.Info().Msgf("killing daemon pid %d", l.Pid)
+		if err := process.Kill(); err != nil {
+			moved.Logger.Error().Err(err).Msg("kill failed")
+			os.Exit(1)
 		}
-	} else {
-		moved.Logger.Info().Msg("daemon is not running")
+		moved.Logger.Info().Msg("daemon was killed")
+		return
+	}
+
+	moved.Logger.Info().Msgf("sending SIGTERM signal to pid %d", l.Pid)
+	if err := process.Signal(syscall.SIGTERM); err != nil {
+		moved.Logger.Error().Err(err).Msg("SIGTERM failed")
+		os.Exit(1)
 	}
+	for deadline := time.Now().Add(timeout); single.Alive(l.Pid); time.Sleep(100 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			moved.Logger.Error().Msgf("daemon pid %d did not exit within %s. use -f to kill it", l.Pid, timeout)
+			os.Exit(1)
+		}
+	}
+	moved.Logger.Info().Msgf("daemon pid %d stopped", l.Pid)
 }
 
 type Daemon struct {
@@ -254,6 +284,7 @@ func (d *Daemon) OnStart() error {
 
 		// Stop app
 		d.Stop()
+		moved.Unlock()
 
 		switch {
 		default:
@@ -274,13 +305,14 @@ func (d *Daemon) OnStart() error {
 	}
 
 	// Start Web.
+	d.webServer = server.NewWeb(moved.WebHost)
 	d.server = cmd_server.NewServer()
+	d.server.Handler = d.webServer.Router()
 	if err := d.server.Start(); err != nil {
 		core.Instance.Stop()
 		return err
 	}
 
-	d.webServer = server.NewWeb(moved.WebHost)
 	if err := d.webServer.Start(); err != nil {
 		d.server.Stop()
 		core.Instance.Stop()
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/genzai-io/sliced"
+	"github.com/genzai-io/sliced/app/certs"
+	"github.com/genzai-io/sliced/app/node"
+	"github.com/genzai-io/sliced/common/redigo/redis"
+	"github.com/genzai-io/sliced/common/zfs"
+	"github.com/spf13/cobra"
+)
+
+func zfsCommand() *cobra.Command {
+	var cmdZFS = &cobra.Command{
+		Use:   "zfs",
+		Short: "Snapshots and streams the drives that are on ZFS datasets",
+		Long: `Backs up drives on ZFS datasets with atomic snapshots. The stream of a
+snapshot may be received on another host to seed a new replica.`,
+	}
+
+	var addr string
+	var cmdSnapshot = &cobra.Command{
+		Use:   "snapshot [name]",
+		Short: "Snapshots every drive of the running daemon with writes paused",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			name := strconv.FormatInt(time.Now().Unix(), 10)
+			if len(args) > 0 {
+				name = args[0]
+			}
+			if err := runZFSSnapshot(addr, name); err != nil {
+				moved.Logger.Error().Err(err).Msg("zfs snapshot failed")
+				os.Exit(1)
+			}
+		},
+	}
+	cmdSnapshot.Flags().StringVarP(&addr, "addr", "a", moved.ApiHost, "API address of the daemon")
+
+	var from, output string
+	var cmdSend = &cobra.Command{
+		Use:   "send <snapshot>",
+		Short: "Writes the stream of a snapshot",
+		Long: `Writes the ZFS stream of a snapshot to stdout or a file. With --from only the
+changes since an earlier snapshot are written.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runZFSSend(args[0], from, output); err != nil {
+				moved.Logger.Error().Err(err).Msg("zfs send failed")
+				os.Exit(1)
+			}
+		},
+	}
+	cmdSend.Flags().StringVar(&from, "from", "", "Earlier snapshot to send the changes since")
+	cmdSend.Flags().StringVarP(&output, "output", "o", "", "File to write the stream to instead of stdout")
+
+	var input string
+	var cmdReceive = &cobra.Command{
+		Use:   "receive <dataset>",
+		Short: "Receives a snapshot stream into a dataset to seed a node",
+		Long: `Receives the ZFS stream of a snapshot from stdin or a file into a dataset.
+The daemon must not be running.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runZFSReceive(args[0], input); err != nil {
+				moved.Logger.Error().Err(err).Msg("zfs receive failed")
+				os.Exit(1)
+			}
+		},
+	}
+	cmdReceive.Flags().StringVarP(&input, "input", "i", "", "File to read the stream from instead of stdin")
+
+	cmdZFS.AddCommand(cmdSnapshot, cmdSend, cmdReceive)
+	return cmdZFS
+}
+
+func runZFSSnapshot(addr, name string) error {
+	moved.Configure()
+	if err := certs.Default.Configure(moved.TLSCertFile, moved.TLSKeyFile, moved.TLSCAFile); err != nil {
+		return err
+	}
+
+	c, err := node.DialRedis(addr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := node.Authenticate(c); err != nil {
+		return err
+	}
+	values, err := redis.Strings(c.Do("DRIVES", "SNAPSHOT", name))
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("no drives are on zfs datasets")
+	}
+	for i := 0; i+1 < len(values); i += 2 {
+		fmt.Printf("%s\t%s\n", values[i], values[i+1])
+	}
+	return nil
+}
+
+func runZFSSend(snapshot, from, output string) error {
+	snap, err := zfs.GetDataset(snapshot)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if from == "" {
+		return snap.SendSnapshot(w)
+	}
+	base, err := zfs.GetDataset(from)
+	if err != nil {
+		return err
+	}
+	return snap.IncrementalSend(base, w)
+}
+
+func runZFSReceive(dataset, input string) error {
+	moved.Configure()
+
+	l := moved.Lock()
+	if l.Err != nil {
+		return l.Err
+	}
+	if !l.Success {
+		return fmt.Errorf("daemon is running pid %d", l.Pid)
+	}
+	defer moved.Unlock()
+
+	var r io.Reader = os.Stdin
+	if input != "" {
+		f, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	ds, err := zfs.ReceiveSnapshot(r, dataset)
+	if err != nil {
+		return err
+	}
+	moved.Logger.Info().Msgf("received %s mounted at %s", ds.Name, ds.Mountpoint)
+	return nil
+}
@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/genzai-io/sliced/common/btree"
+	"github.com/genzai-io/sliced/common/diskio"
 	"github.com/genzai-io/sliced/common/gjson"
 	"github.com/genzai-io/sliced/common/grect"
 	"github.com/genzai-io/sliced/common/match"
@@ -26,7 +27,8 @@ import (
 // Transactions are used for all forms of data access to the DB.
 type DB struct {
 	mu        sync.RWMutex      // the gatekeeper for all fields
-	file      *os.File          // the underlying file
+	file      diskio.File       // the underlying file
+	guard     *diskio.Guard     // read-only while the disk is full or failing
 	buf       []byte            // a buffer to write to
 	keys      *btree.BTree      // a tree of all item ordered by key
 	exps      *btree.BTree      // a tree of items ordered by expiration
@@ -118,10 +120,11 @@ func Open(path string) (*DB, error) {
 	if db.persist {
 		var err error
 		// hardcoding 0666 as the default mode.
-		db.file, err = os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0666)
+		db.file, err = diskio.OpenFile(path, os.O_CREATE|os.O_RDWR, 0666)
 		if err != nil {
 			return nil, err
 		}
+		db.guard = diskio.NewGuard(path)
 		// load the database from disk
 		if err := db.load(); err != nil {
 			// close on error, ignore close error
@@ -152,10 +155,11 @@ func OpenWithConfig(path string, config Config) (*DB, error) {
 	if db.persist {
 		var err error
 		// hardcoding 0666 as the default mode.
-		db.file, err = os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0666)
+		db.file, err = diskio.OpenFile(path, os.O_CREATE|os.O_RDWR, 0666)
 		if err != nil {
 			return nil, err
 		}
+		db.guard = diskio.NewGuard(path)
 		// load the database from disk
 		if err := db.load(); err != nil {
 			// close on error, ignore close error
@@ -177,6 +181,7 @@ func (db *DB) Close() error {
 		return ErrDatabaseClosed
 	}
 	db.closed = true
+	db.guard.Close()
 	if db.persist {
 		db.file.Sync() // do a sync but ignore the error
 		if err := db.file.Close(); err != nil {
@@ -610,7 +615,9 @@ func (db *DB) backgroundManager() {
 			defer db.mu.Unlock()
 			if db.persist && db.config.SyncPolicy == EverySecond &&
 				flushes != db.flushes {
-				_ = db.file.Sync()
+				if err := db.file.Sync(); err != nil {
+					db.guard.Check(err)
+				}
 				flushes = db.flushes
 			}
 		}()
@@ -659,7 +666,7 @@ func (db *DB) Shrink() error {
 	}
 	db.mu.Unlock()
 	time.Sleep(time.Second / 4) // wait just a bit before starting
-	f, err := os.Create(tmpname)
+	f, err := diskio.Create(tmpname)
 	if err != nil {
 		return err
 	}
@@ -745,13 +752,17 @@ func (db *DB) Shrink() error {
 		if err := db.file.Close(); err != nil {
 			return err
 		}
-		// Any failures below here is really bad. So just panic.
-		if err := os.Rename(tmpname, fname); err != nil {
-			panic(err)
-		}
-		db.file, err = os.OpenFile(fname, os.O_CREATE|os.O_RDWR, 0666)
+		// Keep the old file when the new one can't take its place
+		renameErr := os.Rename(tmpname, fname)
+		db.file, err = diskio.OpenFile(fname, os.O_CREATE|os.O_RDWR, 0666)
 		if err != nil {
-			panic(err)
+			return db.guard.Check(err)
+		}
+		if renameErr != nil {
+			if _, err := db.file.Seek(0, 2); err != nil {
+				return err
+			}
+			return db.guard.Check(renameErr)
 		}
 		pos, err := db.file.Seek(0, 2)
 		if err != nil {
@@ -928,6 +939,12 @@ func (db *DB) load() error {
 	return nil
 }
 
+// ReadOnly returns a *diskio.ReadOnlyError while writes are refused because
+// the disk is full or failing and nil otherwise.
+func (db *DB) ReadOnly() error {
+	return db.guard.Err()
+}
+
 // managed calls a block of code that is fully contained in a transaction.
 // This method is intended to be wrapped by Update and View
 func (db *DB) managed(writable bool, fn func(tx *Tx) error) (err error) {
@@ -1066,6 +1083,10 @@ func (db *DB) Begin(writable bool) (*Tx, error) {
 		return nil, ErrDatabaseClosed
 	}
 	if writable {
+		if err := db.guard.Err(); err != nil {
+			tx.unlock()
+			return nil, err
+		}
 		// writable transactions have a writeContext object that
 		// contains information about changes to the database.
 		tx.wc = &txWriteContext{}
@@ -1155,11 +1176,19 @@ func (tx *Tx) Commit() error {
 		// Flushing the buffer only once per transaction.
 		// If this operation fails then the write did failed and we must
 		// rollback.
-		if _, err = tx.db.file.Write(tx.db.buf); err != nil {
+		if n, werr := tx.db.file.Write(tx.db.buf); werr != nil {
+			// Drop a partial write so the next commit appends whole commands
+			if n > 0 {
+				if pos, serr := tx.db.file.Seek(int64(-n), 1); serr == nil {
+					_ = tx.db.file.Truncate(pos)
+				}
+			}
+			err = tx.db.guard.Check(werr)
 			tx.rollbackInner()
-		}
-		if tx.db.config.SyncPolicy == Always {
-			_ = tx.db.file.Sync()
+		} else if tx.db.config.SyncPolicy == Always {
+			if serr := tx.db.file.Sync(); serr != nil {
+				tx.db.guard.Check(serr)
+			}
 		}
 		// Increment the number of flushes. The background syncing uses this.
 		tx.db.flushes++
@@ -7,11 +7,15 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/genzai-io/sliced/common/diskio"
 )
 
 func testOpen(t testing.TB) *DB {
@@ -2645,4 +2649,70 @@ OUTER1:
 	if err != nil {
 		t.Fail()
 	}
-}
\ No newline at end of file
+}
+func TestReadOnlyOnDiskFull(t *testing.T) {
+	dir, err := ioutil.TempDir("", "btrdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	interval := diskio.ProbeInterval
+	defer func() { diskio.ProbeInterval = interval }()
+	diskio.ProbeInterval = 10 * time.Millisecond
+
+	path := filepath.Join(dir, "data.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	set := func(key string) error {
+		return db.Update(func(tx *Tx) error {
+			_, _, err := tx.Set(key, "value", nil)
+			return err
+		})
+	}
+	if err := set("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	remove := diskio.Inject(dir, diskio.OpWrite, syscall.ENOSPC)
+	if err := set("b"); !diskio.IsReadOnly(err) {
+		t.Fatalf("expected a read-only error got %v", err)
+	}
+	if err := set("c"); !diskio.IsReadOnly(err) || !diskio.IsReadOnly(db.ReadOnly()) {
+		t.Fatalf("expected writes to be refused got %v", err)
+	}
+	if err := db.View(func(tx *Tx) error {
+		_, err := tx.Get("a")
+		return err
+	}); err != nil {
+		t.Fatalf("expected reads to continue got %v", err)
+	}
+
+	remove()
+	for start := time.Now(); db.ReadOnly() != nil; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatal("expected writes to resume")
+		}
+	}
+	if err := set("d"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The failed writes left nothing behind
+	db.Close()
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	db.View(func(tx *Tx) error {
+		n, err = tx.Len()
+		return err
+	})
+	if n != 2 {
+		t.Fatalf("expected 2 keys got %d", n)
+	}
+}
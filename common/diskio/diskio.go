@@ -0,0 +1,158 @@
+// Package diskio is the file layer the stores write through. It tells disk
+// failures apart from other errors, keeps stores read-only while their disk
+// is full or failing and lets tests inject faults into any file.
+package diskio
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// The part of *os.File the stores use.
+type File interface {
+	io.Reader
+	io.Writer
+	io.Seeker
+	io.Closer
+
+	Name() string
+	Stat() (os.FileInfo, error)
+	Sync() error
+	Truncate(size int64) error
+}
+
+// Opens a file like os.OpenFile. Operations on it fail with the faults
+// injected for its path.
+func OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	if err := Fault(name, OpOpen); err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f}, nil
+}
+
+// Creates or truncates a file like os.Create.
+func Create(name string) (File, error) {
+	return OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+type file struct {
+	*os.File
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	if err := Fault(f.Name(), OpWrite); err != nil {
+		return 0, &os.PathError{Op: "write", Path: f.Name(), Err: err}
+	}
+	return f.File.Write(p)
+}
+
+func (f *file) Sync() error {
+	if err := Fault(f.Name(), OpSync); err != nil {
+		return &os.PathError{Op: "sync", Path: f.Name(), Err: err}
+	}
+	return f.File.Sync()
+}
+
+func (f *file) Truncate(size int64) error {
+	if err := Fault(f.Name(), OpTruncate); err != nil {
+		return &os.PathError{Op: "truncate", Path: f.Name(), Err: err}
+	}
+	return f.File.Truncate(size)
+}
+
+// Whether the error is from a disk that is out of space.
+func IsNoSpace(err error) bool {
+	return cause(err) == syscall.ENOSPC
+}
+
+// Whether the error is from a disk that failed to read or write.
+func IsIOError(err error) bool {
+	return cause(err) == syscall.EIO
+}
+
+// Whether the error is from a disk that is full or failing.
+func IsDiskError(err error) bool {
+	return IsNoSpace(err) || IsIOError(err)
+}
+
+func cause(err error) error {
+	switch e := err.(type) {
+	case *os.PathError:
+		return e.Err
+	case *os.LinkError:
+		return e.Err
+	case *os.SyscallError:
+		return e.Err
+	}
+	return err
+}
+
+// File operations a fault may be injected into.
+type Op int
+
+const (
+	OpOpen Op = iota
+	OpWrite
+	OpSync
+	OpTruncate
+)
+
+type injected struct {
+	path string
+	op   Op
+	err  error
+}
+
+var (
+	muFaults  sync.RWMutex
+	faults    []*injected
+	numFaults int32
+)
+
+// Fails the operation op on every file under path with err until the
+// returned function is called.
+func Inject(path string, op Op, err error) (remove func()) {
+	f := &injected{path: filepath.Clean(path), op: op, err: err}
+	muFaults.Lock()
+	faults = append(faults, f)
+	atomic.StoreInt32(&numFaults, int32(len(faults)))
+	muFaults.Unlock()
+
+	return func() {
+		muFaults.Lock()
+		defer muFaults.Unlock()
+		for i, other := range faults {
+			if other == f {
+				faults = append(faults[:i], faults[i+1:]...)
+				break
+			}
+		}
+		atomic.StoreInt32(&numFaults, int32(len(faults)))
+	}
+}
+
+// The fault injected into op on the file at name. Files that are memory
+// mapped and can't be opened through OpenFile check it themselves.
+func Fault(name string, op Op) error {
+	if atomic.LoadInt32(&numFaults) == 0 {
+		return nil
+	}
+	name = filepath.Clean(name)
+	muFaults.RLock()
+	defer muFaults.RUnlock()
+	for _, f := range faults {
+		if f.op == op && (name == f.path || strings.HasPrefix(name, f.path+string(filepath.Separator))) {
+			return f.err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package diskio
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestInject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := Create(filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	remove := Inject(dir, OpWrite, syscall.ENOSPC)
+	if _, err := f.Write([]byte("a")); !IsNoSpace(err) {
+		t.Fatalf("expected ENOSPC got %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		t.Fatalf("expected only writes to fail got %v", err)
+	}
+	remove()
+	if _, err := f.Write([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only files under the path fail
+	defer Inject(filepath.Join(dir, "b"), OpOpen, syscall.EIO)()
+	if _, err := OpenFile(filepath.Join(dir, "b"), os.O_CREATE|os.O_RDWR, 0644); !IsIOError(err) {
+		t.Fatalf("expected EIO got %v", err)
+	}
+	if _, err := OpenFile(filepath.Join(dir, "bb"), os.O_CREATE|os.O_RDWR, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGuard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	interval := ProbeInterval
+	defer func() { ProbeInterval = interval }()
+	ProbeInterval = time.Millisecond
+
+	changed := make(chan error, 2)
+	Notify = func(path string, err error) { changed <- err }
+	defer func() { Notify = nil }()
+
+	g := NewGuard(filepath.Join(dir, "store.db"))
+	defer g.Close()
+
+	other := errors.New("other")
+	if err := g.Check(other); err != other || g.Err() != nil {
+		t.Fatalf("expected other errors to pass through got %v", err)
+	}
+
+	remove := Inject(dir, OpWrite, syscall.ENOSPC)
+	err = g.Check(&os.PathError{Op: "write", Path: "store.db", Err: syscall.ENOSPC})
+	if !IsReadOnly(err) || !IsReadOnly(g.Err()) {
+		t.Fatalf("expected read-only got %v", err)
+	}
+	if err := <-changed; !IsNoSpace(err) {
+		t.Fatalf("expected ENOSPC notification got %v", err)
+	}
+
+	// Stays read-only while the disk is full
+	time.Sleep(20 * time.Millisecond)
+	if g.Err() == nil {
+		t.Fatal("expected the store to stay read-only")
+	}
+
+	remove()
+	select {
+	case err := <-changed:
+		if err != nil {
+			t.Fatalf("expected writable notification got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected writes to resume")
+	}
+	if err := g.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
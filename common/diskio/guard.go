@@ -0,0 +1,168 @@
+package diskio
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	// Interval between probes of a read-only store's disk.
+	ProbeInterval = 5 * time.Second
+
+	// Bytes a probe writes. Writes resume once they fit.
+	ProbeSize = 1024 * 1024
+
+	// Called with the cause when a store turns read-only and with nil when
+	// it is writable again.
+	Notify func(path string, err error)
+)
+
+// Returned for writes to a store that is read-only.
+type ReadOnlyError struct {
+	Path  string
+	Cause error
+}
+
+func (e *ReadOnlyError) Error() string {
+	return e.Path + " is read-only: " + e.Cause.Error()
+}
+
+// Whether the error is from a store that is read-only.
+func IsReadOnly(err error) bool {
+	_, ok := err.(*ReadOnlyError)
+	return ok
+}
+
+// Keeps a store writable only while its disk is. A write that fails because
+// the disk is full or failing turns the store read-only until a probe write
+// next to the store succeeds again.
+type Guard struct {
+	path string
+
+	mu     sync.Mutex
+	err    *ReadOnlyError
+	since  time.Time
+	closed bool
+	stop   chan struct{}
+}
+
+// Guards the store at path, which is either its file or its directory.
+func NewGuard(path string) *Guard {
+	return &Guard{path: path}
+}
+
+// Nil while the store is writable, otherwise a *ReadOnlyError.
+func (g *Guard) Err() error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.err == nil {
+		return nil
+	}
+	return g.err
+}
+
+// When the store turned read-only.
+func (g *Guard) Since() time.Time {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.since
+}
+
+// Checks the error of a write. Disk errors turn the store read-only and are
+// returned as a *ReadOnlyError. Other errors are returned as is.
+func (g *Guard) Check(err error) error {
+	if g == nil || err == nil || !IsDiskError(err) {
+		return err
+	}
+
+	g.mu.Lock()
+	if g.err != nil {
+		err := g.err
+		g.mu.Unlock()
+		return err
+	}
+	g.err = &ReadOnlyError{Path: g.path, Cause: err}
+	g.since = time.Now()
+	readOnly := g.err
+	if !g.closed {
+		g.stop = make(chan struct{})
+		go g.probe(g.stop)
+	}
+	g.mu.Unlock()
+
+	if Notify != nil {
+		Notify(g.path, err)
+	}
+	return readOnly
+}
+
+// Stops probing the disk.
+func (g *Guard) Close() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.closed = true
+	if g.stop != nil {
+		close(g.stop)
+		g.stop = nil
+	}
+}
+
+// Probes the disk until a write succeeds then turns the store writable.
+func (g *Guard) probe(stop chan struct{}) {
+	ticker := time.NewTicker(ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+
+		case <-ticker.C:
+			dir := g.path
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				dir = filepath.Dir(dir)
+			}
+			if Probe(dir) != nil {
+				continue
+			}
+			g.mu.Lock()
+			if g.stop != stop {
+				g.mu.Unlock()
+				return
+			}
+			g.err = nil
+			g.stop = nil
+			g.mu.Unlock()
+
+			if Notify != nil {
+				Notify(g.path, nil)
+			}
+			return
+		}
+	}
+}
+
+// Writes and syncs ProbeSize bytes in dir to tell whether it takes writes.
+func Probe(dir string) error {
+	name := filepath.Join(dir, ".probe")
+	f, err := OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(name)
+	_, err = f.Write(make([]byte, ProbeSize))
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
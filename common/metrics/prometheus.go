@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Quantiles exported from timers and histograms as Prometheus summaries.
+var PrometheusQuantiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+
+// WritePrometheus writes every metric in r to w in the Prometheus text
+// exposition format. Metric names are prepended with prefix and characters
+// Prometheus does not allow are replaced with underscores. Timers are
+// exported in seconds.
+func WritePrometheus(w io.Writer, r Registry, prefix string) error {
+	metrics := make(map[string]interface{})
+	r.Each(func(name string, i interface{}) {
+		metrics[PrometheusName(prefix, name)] = i
+	})
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		switch metric := metrics[name].(type) {
+		case Counter:
+			fmt.Fprintf(bw, "# TYPE %s counter\n%s %d\n", name, name, metric.Count())
+		case Gauge:
+			fmt.Fprintf(bw, "# TYPE %s gauge\n%s %d\n", name, name, metric.Value())
+		case GaugeFloat64:
+			fmt.Fprintf(bw, "# TYPE %s gauge\n%s %s\n", name, name, formatFloat(metric.Value()))
+		case Histogram:
+			h := metric.Snapshot()
+			writeSummary(bw, name, h.Percentiles(PrometheusQuantiles), float64(h.Sum()), h.Count(), 1)
+		case Meter:
+			m := metric.Snapshot()
+			writeMeter(bw, name, m.Count(), m.Rate1(), m.Rate5(), m.Rate15())
+		case Timer:
+			t := metric.Snapshot()
+			writeSummary(bw, name+"_seconds", t.Percentiles(PrometheusQuantiles), float64(t.Sum()), t.Count(), float64(time.Second))
+			writeMeter(bw, name, t.Count(), t.Rate1(), t.Rate5(), t.Rate15())
+		}
+	}
+	return bw.Flush()
+}
+
+func writeSummary(w io.Writer, name string, ps []float64, sum float64, count int64, unit float64) {
+	fmt.Fprintf(w, "# TYPE %s summary\n", name)
+	for i, q := range PrometheusQuantiles {
+		fmt.Fprintf(w, "%s{quantile=\"%s\"} %s\n", name, formatFloat(q), formatFloat(ps[i]/unit))
+	}
+	fmt.Fprintf(w, "%s_sum %s\n%s_count %d\n", name, formatFloat(sum/unit), name, count)
+}
+
+func writeMeter(w io.Writer, name string, count int64, rate1, rate5, rate15 float64) {
+	fmt.Fprintf(w, "# TYPE %s_total counter\n%s_total %d\n", name, name, count)
+	fmt.Fprintf(w, "# TYPE %s_rate gauge\n", name)
+	fmt.Fprintf(w, "%s_rate{window=\"1m\"} %s\n", name, formatFloat(rate1))
+	fmt.Fprintf(w, "%s_rate{window=\"5m\"} %s\n", name, formatFloat(rate5))
+	fmt.Fprintf(w, "%s_rate{window=\"15m\"} %s\n", name, formatFloat(rate15))
+}
+
+// PrometheusName joins prefix and name with an underscore and replaces
+// the characters that are not allowed in a Prometheus metric name.
+func PrometheusName(prefix, name string) string {
+	if prefix != "" {
+		name = prefix + "_" + name
+	}
+	b := []byte(name)
+	for i, c := range b {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':' ||
+			c >= '0' && c <= '9' && i > 0 {
+			continue
+		}
+		b[i] = '_'
+	}
+	return string(b)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// PrometheusLabels formats label pairs given as name value pairs.
+func PrometheusLabels(pairs ...string) string {
+	if len(pairs) < 2 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(PrometheusName("", pairs[i]))
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
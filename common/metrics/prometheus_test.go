@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("server.conns", r).Inc(3)
+	NewRegisteredGauge("queue-depth", r).Update(7)
+	NewRegisteredTimer("apply", r).Update(2 * time.Second)
+
+	var buf bytes.Buffer
+	if err := WritePrometheus(&buf, r, "sliced"); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, line := range []string{
+		"# TYPE sliced_server_conns counter\nsliced_server_conns 3\n",
+		"# TYPE sliced_queue_depth gauge\nsliced_queue_depth 7\n",
+		"sliced_apply_seconds{quantile=\"0.5\"} 2\n",
+		"sliced_apply_seconds_sum 2\nsliced_apply_seconds_count 1\n",
+		"sliced_apply_total 1\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Fatalf("expected %q in\n%s", line, out)
+		}
+	}
+	// Sorted by name
+	if strings.Index(out, "sliced_apply") > strings.Index(out, "sliced_server_conns") {
+		t.Fatalf("expected metrics sorted by name\n%s", out)
+	}
+}
+
+func TestPrometheusLabels(t *testing.T) {
+	if name := PrometheusName("", "1st.metric-name"); name != "_st_metric_name" {
+		t.Fatalf("unexpected name %s", name)
+	}
+	labels := PrometheusLabels("command", "get", "path", "a\"b\\c\n")
+	if labels != `{command="get",path="a\"b\\c\n"}` {
+		t.Fatalf("unexpected labels %s", labels)
+	}
+	if PrometheusLabels() != "" {
+		t.Fatal("expected no labels")
+	}
+}
@@ -4,17 +4,19 @@ package single
 
 import (
 	"errors"
-	"os"
+	"fmt"
 	"io/ioutil"
-	"strings"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 var (
 	// ErrAlreadyRunning
-	ErrAlreadyRunning = errors.New("the program is already running")
-	ErrPIDInvalid     = errors.New("PID in lockfile is invalid")
+	ErrAlreadyRunning  = errors.New("the program is already running")
+	ErrPIDInvalid      = errors.New("PID in lockfile is invalid")
+	ErrLockfileRemoved = errors.New("lockfile kept being removed while locking it")
 	//
 	Lockfile string
 )
@@ -22,6 +24,7 @@ var (
 // Single represents the name and the open file descriptor
 type Single struct {
 	name string
+	path string
 	pid  int
 	port int
 	file *os.File
@@ -32,6 +35,9 @@ type LockResult struct {
 	Err     error
 	Pid     int
 	Port    int
+
+	// Pid of an earlier owner that exited without removing the lockfile
+	Stale int
 }
 
 // New creates a Single instance
@@ -39,6 +45,29 @@ func New(name string) *Single {
 	return &Single{name: name}
 }
 
+// NewFile creates a Single instance locking the file at path.
+func NewFile(path string) *Single {
+	return &Single{path: path}
+}
+
+// Writes the pid of this process and the port it serves on to the locked file.
+func (s *Single) write(port int) error {
+	if err := s.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := s.file.WriteAt([]byte(fmt.Sprintf("%d\n%d", os.Getpid(), port)), 0); err != nil {
+		return err
+	}
+	s.pid = os.Getpid()
+	s.port = port
+	return s.file.Sync()
+}
+
+// Pid and port of the owner of the lockfile at name without locking it.
+func Read(name string) LockResult {
+	return readPIDFile(name)
+}
+
 func readPIDFile(name string) LockResult {
 	for i := 0; i < 10; i++ {
 		contents, err := ioutil.ReadFile(name)
@@ -48,48 +77,45 @@ func readPIDFile(name string) LockResult {
 				Err:     err,
 			}
 		}
-		if len(contents) == 0 {
+		// The owner may not have written it yet
+		if len(strings.TrimSpace(string(contents))) == 0 {
 			time.Sleep(200 * time.Millisecond)
 			continue
 		}
 
-		split := strings.Split(string(contents), "\n")
-		splitLen := len(split)
-
-		if splitLen == 0 {
-			time.Sleep(200 * time.Millisecond)
-			continue
-		}
-		if splitLen == 1 {
-			pid, err := strconv.Atoi(split[0])
-			if err != nil {
-				return LockResult{
-					Success: false,
-					Err:     ErrPIDInvalid,
-				}
-			}
-			return LockResult{
-				Success: false,
-				Pid:     pid,
-			}
-		} else {
-			pid, err := strconv.Atoi(split[0])
-			if err != nil {
-				return LockResult{
-					Success: false,
-					Err:     ErrPIDInvalid,
-				}
-			}
-			port, err := strconv.Atoi(split[1])
+		split := strings.Split(strings.TrimSpace(string(contents)), "\n")
+		pid, err := strconv.Atoi(split[0])
+		if err != nil {
 			return LockResult{
 				Success: false,
-				Pid:     pid,
-				Port:    port,
+				Err:     ErrPIDInvalid,
 			}
 		}
+		result := LockResult{
+			Success: false,
+			Pid:     pid,
+		}
+		if len(split) > 1 {
+			result.Port, _ = strconv.Atoi(split[1])
+		}
+		return result
 	}
 
 	return LockResult{
 		Success: false,
 	}
 }
+
+// Pid of the owner of a lockfile that was just locked when that owner is no
+// longer running.
+func stalePid(name string) int {
+	contents, err := ioutil.ReadFile(name)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.Split(strings.TrimSpace(string(contents)), "\n")[0])
+	if err != nil || pid <= 0 || pid == os.Getpid() || Alive(pid) {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,26 @@
+// +build solaris darwin freebsd
+
+package single
+
+import (
+	"os"
+	"syscall"
+)
+
+// Takes an exclusive fcntl lock of the file. FcntlFlock seems to be the
+// portable *ix way.
+func lockFile(f *os.File) error {
+	flock := syscall.Flock_t{
+		Type: syscall.F_WRLCK,
+		Pid:  int32(os.Getpid()),
+	}
+	return syscall.FcntlFlock(f.Fd(), syscall.F_SETLK, &flock)
+}
+
+func unlockFile(f *os.File) error {
+	flock := syscall.Flock_t{
+		Type: syscall.F_UNLCK,
+		Pid:  int32(os.Getpid()),
+	}
+	return syscall.FcntlFlock(f.Fd(), syscall.F_SETLK, &flock)
+}
@@ -8,6 +8,9 @@ import (
 
 // Filename returns an absolute filename, appropriate for the operating system
 func (s *Single) Filename() string {
+	if len(s.path) > 0 {
+		return s.path
+	}
 	if len(Lockfile) > 0 {
 		return Lockfile
 	}
@@ -2,13 +2,28 @@ package single
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
+	"syscall"
 )
 
 // Filename returns an absolute filename, appropriate for the operating system
 func (s *Single) Filename() string {
+	if len(s.path) > 0 {
+		return s.path
+	}
 	if len(Lockfile) > 0 {
 		return Lockfile
 	}
 	return filepath.Join("/var/lock", fmt.Sprintf("%s.lock", s.name))
 }
+
+// Takes an exclusive flock of the file. The kernel drops it when the process
+// exits however it exits.
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,111 @@
+package single
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestSingle_Lock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "single")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "0.pid")
+
+	s := NewFile(path)
+	l := s.Lock(9002)
+	if !l.Success || l.Pid != os.Getpid() || l.Stale != 0 {
+		t.Fatalf("expected the lock got %+v", l)
+	}
+
+	// A second owner finds the first
+	other := NewFile(path)
+	l = other.Lock(9003)
+	if l.Success || l.Pid != os.Getpid() || l.Port != 9002 {
+		t.Fatalf("expected the first owner got %+v", l)
+	}
+	if err := s.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("expected the lockfile to be removed")
+	}
+}
+
+func TestSingle_LockRemoved(t *testing.T) {
+	dir, err := ioutil.TempDir("", "single")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "0.pid")
+
+	s := NewFile(path)
+	if l := s.Lock(9002); !l.Success {
+		t.Fatalf("expected the lock got %+v", l)
+	}
+
+	// Opened before the owner removes and unlocks the file
+	old, err := os.OpenFile(path, os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+	if err := s.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := lockFile(old); err != nil {
+		t.Fatal(err)
+	}
+	if current(old, path) {
+		t.Fatal("expected the removed file not to be current")
+	}
+
+	// The lock of the removed file doesn't keep a new owner out
+	other := NewFile(path)
+	l := other.Lock(9003)
+	if !l.Success {
+		t.Fatalf("expected the lock got %+v", l)
+	}
+	defer other.Unlock()
+	if r := Read(path); r.Pid != os.Getpid() || r.Port != 9003 {
+		t.Fatalf("expected the new owner got %+v", r)
+	}
+}
+
+func TestSingle_Stale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "single")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "0.pid")
+
+	// The pid of a process that has exited
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skip(err)
+	}
+	dead := cmd.Process.Pid
+	if err := ioutil.WriteFile(path, []byte(strconv.Itoa(dead)+"\n9002"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if Alive(dead) {
+		t.Skip("pid was reused")
+	}
+
+	s := NewFile(path)
+	l := s.Lock(9004)
+	if !l.Success || l.Stale != dead {
+		t.Fatalf("expected to take over the stale lock got %+v", l)
+	}
+	defer s.Unlock()
+	if r := Read(path); r.Pid != os.Getpid() || r.Port != 9004 {
+		t.Fatalf("expected the new owner got %+v", r)
+	}
+}
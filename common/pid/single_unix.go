@@ -5,32 +5,50 @@ package single
 import (
 	"os"
 	"syscall"
-	"fmt"
 )
 
-// Lock tries to obtain an exclude lock on a lockfile and exits the program if an error occurs
-func (s *Single) Lock() LockResult {
-	// open/create lock file
-	f, err := os.OpenFile(s.Filename(), os.O_RDWR|os.O_CREATE, 0600)
-	if err != nil {
-		return LockResult{
-			Success: false,
-			Err:     err,
+// Most times the lockfile is opened again when it was removed before the
+// lock was taken.
+const lockAttempts = 10
+
+// Lock tries to obtain an exclusive lock on a lockfile and writes the pid and
+// port of this process to it. The result holds the pid and port of the owner
+// when another running process holds the lock.
+func (s *Single) Lock(port int) LockResult {
+	var f *os.File
+	for i := 0; f == nil; i++ {
+		if i == lockAttempts {
+			return LockResult{
+				Success: false,
+				Err:     ErrLockfileRemoved,
+			}
 		}
+
+		// open/create lock file
+		file, err := os.OpenFile(s.Filename(), os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return LockResult{
+				Success: false,
+				Err:     err,
+			}
+		}
+		if err := lockFile(file); err != nil {
+			file.Close()
+			return readPIDFile(s.Filename())
+		}
+		// An owner removes the file before unlocking it so the lock may be
+		// of a file that is gone. Only the file at the path counts.
+		if !current(file, s.Filename()) {
+			file.Close()
+			continue
+		}
+		f = file
 	}
 	s.file = f
-	// set the lock type to F_WRLCK, therefor the file has to be opened writable
-	flock := syscall.Flock_t{
-		Type: syscall.F_WRLCK,
-		Pid:  int32(os.Getpid()),
-	}
-	// try to obtain an exclusive lock - FcntlFlock seems to be the portable *ix way
-	if err := syscall.FcntlFlock(s.file.Fd(), syscall.F_SETLK, &flock); err != nil {
-		return readPIDFile(s.Filename())
-	}
 
-	_, err = f.Write([]byte(fmt.Sprintf("%d\n%d", os.Getpid(), 0)))
-	if err != nil {
+	// The lock is free so an owner still in the file has exited
+	stale := stalePid(s.Filename())
+	if err := s.write(port); err != nil {
 		s.Unlock()
 		return LockResult{
 			Success: false,
@@ -41,25 +59,47 @@ func (s *Single) Lock() LockResult {
 	return LockResult{
 		Success: true,
 		Pid:     os.Getpid(),
-		Port:    0,
+		Port:    port,
+		Stale:   stale,
+	}
+}
+
+// Whether f is still the file at name.
+func current(f *os.File, name string) bool {
+	opened, err := f.Stat()
+	if err != nil {
+		return false
 	}
+	named, err := os.Stat(name)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(opened, named)
 }
 
 // Unlock releases the lock, closes and removes the lockfile. All errors will be reported directly.
 func (s *Single) Unlock() error {
-	// set the lock type to F_UNLCK
-	flock := syscall.Flock_t{
-		Type: syscall.F_UNLCK,
-		Pid:  int32(os.Getpid()),
+	if s.file == nil {
+		return nil
 	}
-	if err := syscall.FcntlFlock(s.file.Fd(), syscall.F_SETLK, &flock); err != nil {
+	// Removed while still locked. A process that opened the file before
+	// finds it gone once it takes the lock and opens the path again
+	removeErr := os.Remove(s.Filename())
+	if err := unlockFile(s.file); err != nil {
 		return err
 	}
 	if err := s.file.Close(); err != nil {
 		return err
 	}
-	if err := os.Remove(s.Filename()); err != nil {
-		return err
+	s.file = nil
+	return removeErr
+}
+
+// Whether a process with the pid is running.
+func Alive(pid int) bool {
+	if pid <= 0 {
+		return false
 	}
-	return nil
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
 }
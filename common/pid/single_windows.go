@@ -4,13 +4,15 @@ package single
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 )
 
 // Filename returns an absolute filename, appropriate for the operating system
 func (s *Single) Filename() string {
+	if len(s.path) > 0 {
+		return s.path
+	}
 	if len(Lockfile) > 0 {
 		return Lockfile
 	}
@@ -19,14 +21,14 @@ func (s *Single) Filename() string {
 
 // Lock tries to remove the lock file, if it exists.
 // If the file is already open by another instance of the program,
-// remove will fail and exit the program.
-func (s *Single) Lock() LockResult {
-
+// remove will fail and the pid and port of that instance are returned.
+func (s *Single) Lock(port int) LockResult {
+	stale := stalePid(s.Filename())
 	if err := os.Remove(s.Filename()); err != nil && !os.IsNotExist(err) {
 		return readPIDFile(s.Filename())
 	}
 
-	file, err := os.OpenFile(s.Filename(), os.O_EXCL|os.O_CREATE, 0600)
+	file, err := os.OpenFile(s.Filename(), os.O_EXCL|os.O_CREATE|os.O_RDWR, 0600)
 	if err != nil {
 		return LockResult{
 			Success: false,
@@ -35,8 +37,7 @@ func (s *Single) Lock() LockResult {
 	}
 	s.file = file
 
-	_, err = f.Write([]byte(fmt.Sprintf("%d\n%d", os.Getpid(), 0)))
-	if err != nil {
+	if err := s.write(port); err != nil {
 		s.Unlock()
 		return LockResult{
 			Success: false,
@@ -47,17 +48,35 @@ func (s *Single) Lock() LockResult {
 	return LockResult{
 		Success: true,
 		Pid:     os.Getpid(),
-		Port:    0,
+		Port:    port,
+		Stale:   stale,
 	}
 }
 
 // Unlock closes and removes the lockfile.
 func (s *Single) Unlock() error {
+	if s.file == nil {
+		return nil
+	}
 	if err := s.file.Close(); err != nil {
 		return err
 	}
+	s.file = nil
 	if err := os.Remove(s.Filename()); err != nil {
 		return err
 	}
 	return nil
 }
+
+// Whether a process with the pid is running.
+func Alive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -0,0 +1,325 @@
+// Package prompt reads lines from a terminal with editing, history and tab
+// completion.
+package prompt
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Returned by Line when the line is cancelled with Ctrl-C.
+var ErrInterrupted = errors.New("interrupted")
+
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyTab       = 9
+	keyEnter     = 13
+	keyNewline   = 10
+	keyEscape    = 27
+	keyBackspace = 127
+	keyCtrlH     = 8
+)
+
+// Reads lines from a terminal in raw mode. Without Echo the input is read as
+// plain lines which suits input that is not a terminal.
+type Prompt struct {
+	// Candidates for the last of args which is the word before the cursor.
+	// The others are the words before it.
+	Complete func(args []string) []string
+
+	// Lines that were read, oldest first
+	History []string
+	// Lines kept in the history
+	HistoryLen int
+
+	// Whether to draw the line being edited
+	Echo bool
+
+	in  *bufio.Reader
+	out io.Writer
+
+	prefix string
+	line   []rune
+	pos    int
+}
+
+// Reads from in and draws to out.
+func New(in io.Reader, out io.Writer) *Prompt {
+	return &Prompt{
+		HistoryLen: 1000,
+		in:         bufio.NewReader(in),
+		out:        out,
+	}
+}
+
+// Reads a line after drawing prefix. Returns io.EOF on Ctrl-D on an empty
+// line and ErrInterrupted on Ctrl-C.
+func (p *Prompt) Line(prefix string) (string, error) {
+	if !p.Echo {
+		line, err := p.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		p.remember(line)
+		return line, nil
+	}
+
+	p.prefix = prefix
+	p.line = p.line[:0]
+	p.pos = 0
+	history := len(p.History)
+	p.redraw()
+
+	for {
+		r, _, err := p.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case keyEnter, keyNewline:
+			p.write("\r\n")
+			line := string(p.line)
+			p.remember(line)
+			return line, nil
+
+		case keyCtrlC:
+			p.write("^C\r\n")
+			return "", ErrInterrupted
+
+		case keyCtrlD:
+			if len(p.line) == 0 {
+				p.write("\r\n")
+				return "", io.EOF
+			}
+			p.delete()
+
+		case keyBackspace, keyCtrlH:
+			if p.pos > 0 {
+				p.pos--
+				p.delete()
+			}
+
+		case keyCtrlA:
+			p.pos = 0
+		case keyCtrlE:
+			p.pos = len(p.line)
+		case keyCtrlB:
+			p.left()
+		case keyCtrlF:
+			p.right()
+		case keyCtrlK:
+			p.line = p.line[:p.pos]
+		case keyCtrlU:
+			p.line = append(p.line[:0], p.line[p.pos:]...)
+			p.pos = 0
+		case keyCtrlW:
+			start := p.wordStart()
+			p.line = append(p.line[:start], p.line[p.pos:]...)
+			p.pos = start
+		case keyCtrlL:
+			p.write("\x1b[H\x1b[2J")
+		case keyCtrlP:
+			history = p.recall(history - 1)
+		case keyCtrlN:
+			history = p.recall(history + 1)
+
+		case keyTab:
+			p.complete()
+
+		case keyEscape:
+			switch p.escape() {
+			case 'A':
+				history = p.recall(history - 1)
+			case 'B':
+				history = p.recall(history + 1)
+			case 'C':
+				p.right()
+			case 'D':
+				p.left()
+			case 'H':
+				p.pos = 0
+			case 'F':
+				p.pos = len(p.line)
+			case '3':
+				p.delete()
+			}
+
+		default:
+			if r < 32 || r == utf8.RuneError {
+				continue
+			}
+			p.line = append(p.line, 0)
+			copy(p.line[p.pos+1:], p.line[p.pos:])
+			p.line[p.pos] = r
+			p.pos++
+		}
+		p.redraw()
+	}
+}
+
+// Reads the rest of an escape sequence and returns its final byte.
+func (p *Prompt) escape() byte {
+	b, err := p.in.ReadByte()
+	if err != nil || (b != '[' && b != 'O') {
+		return 0
+	}
+	for {
+		b, err = p.in.ReadByte()
+		if err != nil {
+			return 0
+		}
+		switch {
+		case b >= '0' && b <= '9':
+			// Delete is ESC [ 3 ~
+			if next, _ := p.in.Peek(1); len(next) == 1 && next[0] == '~' {
+				p.in.ReadByte()
+				return b
+			}
+		case b == ';':
+		default:
+			return b
+		}
+	}
+}
+
+func (p *Prompt) left() {
+	if p.pos > 0 {
+		p.pos--
+	}
+}
+
+func (p *Prompt) right() {
+	if p.pos < len(p.line) {
+		p.pos++
+	}
+}
+
+// Deletes the rune at the cursor.
+func (p *Prompt) delete() {
+	if p.pos < len(p.line) {
+		p.line = append(p.line[:p.pos], p.line[p.pos+1:]...)
+	}
+}
+
+// Start of the word before the cursor.
+func (p *Prompt) wordStart() int {
+	start := p.pos
+	for start > 0 && p.line[start-1] == ' ' {
+		start--
+	}
+	for start > 0 && p.line[start-1] != ' ' {
+		start--
+	}
+	return start
+}
+
+// Replaces the line with the history entry at i. Past the newest entry the
+// line is empty.
+func (p *Prompt) recall(i int) int {
+	if i < 0 {
+		return 0
+	}
+	if i >= len(p.History) {
+		p.line = p.line[:0]
+		p.pos = 0
+		return len(p.History)
+	}
+	p.line = append(p.line[:0], []rune(p.History[i])...)
+	p.pos = len(p.line)
+	return i
+}
+
+func (p *Prompt) remember(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(p.History); n > 0 && p.History[n-1] == line {
+		return
+	}
+	p.History = append(p.History, line)
+	if p.HistoryLen > 0 && len(p.History) > p.HistoryLen {
+		p.History = p.History[len(p.History)-p.HistoryLen:]
+	}
+}
+
+// Completes the word before the cursor. A single candidate replaces the word.
+// Several fill in their common prefix and are listed below the line when
+// there is nothing to fill in.
+func (p *Prompt) complete() {
+	if p.Complete == nil {
+		return
+	}
+	start := p.pos
+	for start > 0 && p.line[start-1] != ' ' {
+		start--
+	}
+	word := string(p.line[start:p.pos])
+	args := append(strings.Fields(string(p.line[:start])), word)
+
+	candidates := p.Complete(args)
+	if len(candidates) == 0 {
+		return
+	}
+	sort.Strings(candidates)
+
+	fill := candidates[0]
+	if len(candidates) == 1 {
+		fill += " "
+	} else {
+		for _, c := range candidates[1:] {
+			fill = commonPrefix(fill, c)
+		}
+	}
+	if len(fill) <= len(word) {
+		p.write("\r\n" + strings.Join(candidates, "  ") + "\r\n")
+		return
+	}
+
+	rest := append([]rune(fill), p.line[p.pos:]...)
+	p.line = append(p.line[:start], rest...)
+	p.pos = start + utf8.RuneCountInString(fill)
+}
+
+func commonPrefix(a, b string) string {
+	i := 0
+	for i < len(a) && i < len(b) && strings.ToUpper(a[:i+1]) == strings.ToUpper(b[:i+1]) {
+		i++
+	}
+	return a[:i]
+}
+
+// Draws the line and puts the cursor in place.
+func (p *Prompt) redraw() {
+	buf := make([]byte, 0, len(p.prefix)+len(p.line)*2+16)
+	buf = append(buf, "\r\x1b[K"...)
+	buf = append(buf, p.prefix...)
+	buf = append(buf, string(p.line)...)
+	if back := len(p.line) - p.pos; back > 0 {
+		buf = append(buf, "\x1b["...)
+		buf = strconv.AppendInt(buf, int64(back), 10)
+		buf = append(buf, 'D')
+	}
+	p.out.Write(buf)
+}
+
+func (p *Prompt) write(s string) {
+	io.WriteString(p.out, s)
+}
@@ -0,0 +1,59 @@
+package prompt
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPrompt_Line(t *testing.T) {
+	in := strings.NewReader(
+		"slo\tget 10\r" + // Completes the command
+			"x\x1b[Dab\x1b[C!\r" + // Moves the cursor
+			"\x1b[A\x1b[A\x7f\r" + // Recalls the history
+			"one two\x17\x17\r" + // Deletes words
+			"\x03")
+	p := New(in, &bytes.Buffer{})
+	p.Echo = true
+	p.Complete = func(args []string) []string {
+		var candidates []string
+		for _, name := range []string{"SLOWLOG", "SET", "SLEEP"} {
+			if len(args) == 1 && strings.HasPrefix(name, strings.ToUpper(args[0])) {
+				candidates = append(candidates, name)
+			}
+		}
+		return candidates
+	}
+
+	for _, expected := range []string{"SLOWLOG get 10", "abx!", "SLOWLOG get 1", ""} {
+		line, err := p.Line("> ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line != expected {
+			t.Fatalf("expected \"%s\" got \"%s\"", expected, line)
+		}
+	}
+	if _, err := p.Line("> "); err != ErrInterrupted {
+		t.Fatalf("expected ErrInterrupted got %v", err)
+	}
+	if _, err := p.Line("> "); err != io.EOF {
+		t.Fatalf("expected io.EOF got %v", err)
+	}
+	if len(p.History) != 3 {
+		t.Fatalf("expected 3 lines of history got %v", p.History)
+	}
+}
+
+func TestPrompt_Plain(t *testing.T) {
+	p := New(strings.NewReader("PING\nGET a"), &bytes.Buffer{})
+	for _, expected := range []string{"PING", "GET a"} {
+		if line, err := p.Line("> "); err != nil || line != expected {
+			t.Fatalf("expected \"%s\" got \"%s\" %v", expected, line, err)
+		}
+	}
+	if _, err := p.Line("> "); err != io.EOF {
+		t.Fatalf("expected io.EOF got %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package prompt
+
+import "errors"
+
+// Whether fd is a terminal. Terminals are only known on unix.
+func IsTerminal(fd int) bool {
+	return false
+}
+
+// Raw mode is only supported on unix.
+func MakeRaw(fd int) (restore func(), err error) {
+	return nil, errors.New("raw mode is not supported")
+}
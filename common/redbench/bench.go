@@ -55,6 +55,9 @@ type Options struct {
 	CSV      bool
 	Stdout   io.Writer
 	Stderr   io.Writer
+
+	// Dials the clients of Run. Defaults to TCP.
+	Dial func(addr string) (net.Conn, error)
 }
 
 // DefaultsOptions are the default options used by the Bench() function.
@@ -0,0 +1,282 @@
+package redbench
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/genzai-io/sliced/common/histogram"
+)
+
+// Bounds of the latencies recorded in microseconds.
+const (
+	latencyMin     = 1
+	latencyMax     = int64(time.Minute / time.Microsecond)
+	latencySigFigs = 3
+)
+
+// Result of a benchmark performed by Run.
+type Result struct {
+	Name     string
+	Clients  int
+	Pipeline int
+
+	// Requests that got a reply
+	Requests int64
+	// Requests that got an error reply
+	Errors int64
+	// First error reply
+	FirstError string
+	// Bytes of the requests sent
+	Bytes    int64
+	Duration time.Duration
+
+	// Round trip of every request in microseconds. Pipelined requests take
+	// the round trip of their pipeline.
+	Latency *hdrhistogram.Histogram
+}
+
+// Requests per second.
+func (r *Result) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Requests) / r.Duration.Seconds()
+}
+
+// Summary of a Result that marshals to JSON.
+type Report struct {
+	Name            string  `json:"name"`
+	Clients         int     `json:"clients"`
+	Pipeline        int     `json:"pipeline"`
+	Requests        int64   `json:"requests"`
+	Errors          int64   `json:"errors"`
+	FirstError      string  `json:"first_error,omitempty"`
+	Seconds         float64 `json:"seconds"`
+	Throughput      float64 `json:"requests_per_second"`
+	BytesPerRequest int64   `json:"bytes_per_request"`
+
+	// Latency percentiles in microseconds
+	Latency LatencyReport `json:"latency_us"`
+}
+
+type LatencyReport struct {
+	Min  int64   `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  int64   `json:"p50"`
+	P90  int64   `json:"p90"`
+	P99  int64   `json:"p99"`
+	P999 int64   `json:"p99.9"`
+	Max  int64   `json:"max"`
+}
+
+func (r *Result) Report() Report {
+	report := Report{
+		Name:       r.Name,
+		Clients:    r.Clients,
+		Pipeline:   r.Pipeline,
+		Requests:   r.Requests,
+		Errors:     r.Errors,
+		FirstError: r.FirstError,
+		Seconds:    r.Duration.Seconds(),
+		Throughput: r.Throughput(),
+	}
+	if r.Requests > 0 {
+		report.BytesPerRequest = r.Bytes / r.Requests
+	}
+	if r.Latency != nil && r.Latency.TotalCount() > 0 {
+		report.Latency = LatencyReport{
+			Min:  r.Latency.Min(),
+			Mean: r.Latency.Mean(),
+			P50:  r.Latency.ValueAtQuantile(50),
+			P90:  r.Latency.ValueAtQuantile(90),
+			P99:  r.Latency.ValueAtQuantile(99),
+			P999: r.Latency.ValueAtQuantile(99.9),
+			Max:  r.Latency.Max(),
+		}
+	}
+	return report
+}
+
+// Performs a benchmark like Bench but returns the results instead of printing
+// them. Fill is called by every client at once. Opts.Dial dials the clients
+// when set. Only a failure of the first client to connect is returned. The
+// errors of other clients end their requests early.
+func Run(
+	name string,
+	addr string,
+	opts *Options,
+	prep func(conn net.Conn) bool,
+	fill func(buf []byte) []byte,
+) (*Result, error) {
+	if opts == nil {
+		opts = DefaultOptions
+	}
+	dial := opts.Dial
+	if dial == nil {
+		dial = func(addr string) (net.Conn, error) { return net.Dial("tcp", addr) }
+	}
+	pipeline := opts.Pipeline
+	if pipeline < 1 {
+		pipeline = 1
+	}
+	clients := opts.Clients
+	if clients < 1 {
+		clients = 1
+	}
+
+	conns := make([]net.Conn, clients)
+	defer func() {
+		for _, conn := range conns {
+			if conn != nil {
+				conn.Close()
+			}
+		}
+	}()
+	for i := range conns {
+		conn, err := dial(addr)
+		if err != nil {
+			if i == 0 {
+				return nil, err
+			}
+			continue
+		}
+		if prep != nil && !prep(conn) {
+			conn.Close()
+			if i == 0 {
+				return nil, errors.New("client could not be prepared")
+			}
+			continue
+		}
+		conns[i] = conn
+	}
+
+	result := &Result{
+		Name:     name,
+		Clients:  clients,
+		Pipeline: pipeline,
+		Latency:  hdrhistogram.New(latencyMin, latencyMax, latencySigFigs),
+	}
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		requests int64
+		errs     int64
+		bytes    int64
+	)
+
+	start := time.Now()
+	for i, conn := range conns {
+		n := opts.Requests / clients
+		if i == clients-1 {
+			n += opts.Requests % clients
+		}
+		if conn == nil || n == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func(conn net.Conn, n int) {
+			defer wg.Done()
+			latency := hdrhistogram.New(latencyMin, latencyMax, latencySigFigs)
+			rd := bufio.NewReader(conn)
+			var buf []byte
+			var firstError string
+
+			for sent := 0; sent < n; sent += pipeline {
+				count := pipeline
+				if sent+count > n {
+					count = n - sent
+				}
+				buf = buf[:0]
+				for j := 0; j < count; j++ {
+					buf = fill(buf)
+				}
+				atomic.AddInt64(&bytes, int64(len(buf)))
+
+				began := time.Now()
+				if _, err := conn.Write(buf); err != nil {
+					break
+				}
+				failed, first, err := readReplies(rd, count)
+				if err != nil {
+					break
+				}
+				took := int64(time.Since(began)/time.Microsecond) + 1
+				if took > latencyMax {
+					took = latencyMax
+				}
+				latency.RecordValues(took, int64(count))
+				atomic.AddInt64(&requests, int64(count))
+				if failed > 0 {
+					atomic.AddInt64(&errs, int64(failed))
+					if firstError == "" {
+						firstError = first
+					}
+				}
+			}
+
+			mu.Lock()
+			result.Latency.Merge(latency)
+			if result.FirstError == "" {
+				result.FirstError = firstError
+			}
+			mu.Unlock()
+		}(conn, n)
+	}
+	wg.Wait()
+
+	result.Duration = time.Since(start)
+	result.Requests = requests
+	result.Errors = errs
+	result.Bytes = bytes
+	return result, nil
+}
+
+// Reads n replies. Returns the number of error replies and the first of them.
+func readReplies(rd *bufio.Reader, n int) (failed int, first string, err error) {
+	for i := 0; i < n; i++ {
+		line, err := rd.ReadBytes('\n')
+		if err != nil {
+			return failed, first, err
+		}
+		if len(line) < 3 {
+			return failed, first, errors.New("invalid server response")
+		}
+		switch line[0] {
+		default:
+			return failed, first, errors.New("invalid server response")
+		case '+', ':':
+		case '-':
+			if failed == 0 {
+				first = string(line[1 : len(line)-2])
+			}
+			failed++
+		case '$':
+			size, err := strconv.ParseInt(string(line[1:len(line)-2]), 10, 64)
+			if err != nil {
+				return failed, first, err
+			}
+			if size >= 0 {
+				if _, err = io.CopyN(ioutil.Discard, rd, size+2); err != nil {
+					return failed, first, err
+				}
+			}
+		case '*':
+			size, err := strconv.ParseInt(string(line[1:len(line)-2]), 10, 64)
+			if err != nil {
+				return failed, first, err
+			}
+			if _, _, err := readReplies(rd, int(size)); err != nil {
+				return failed, first, err
+			}
+		}
+	}
+	return failed, first, nil
+}
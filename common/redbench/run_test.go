@@ -0,0 +1,101 @@
+package redbench
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Replies +OK to every command but FAIL which gets an error.
+func serveFake(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				rd := bufio.NewReader(conn)
+				for {
+					header, err := rd.ReadString('\n')
+					if err != nil {
+						return
+					}
+					n, _ := strconv.Atoi(strings.TrimSpace(header[1:]))
+					var args []string
+					for i := 0; i < n; i++ {
+						rd.ReadString('\n')
+						arg, err := rd.ReadString('\n')
+						if err != nil {
+							return
+						}
+						args = append(args, strings.TrimSpace(arg))
+					}
+					if len(args) > 0 && args[0] == "FAIL" {
+						conn.Write([]byte("-ERR failed\r\n"))
+					} else {
+						conn.Write([]byte("+OK\r\n"))
+					}
+				}
+			}(conn)
+		}
+	}()
+	return l
+}
+
+func TestRun(t *testing.T) {
+	l := serveFake(t)
+	defer l.Close()
+
+	opts := &Options{Requests: 1003, Clients: 4, Pipeline: 10}
+	result, err := Run("SET", l.Addr().String(), opts, nil, func(buf []byte) []byte {
+		return AppendCommand(buf, "SET", "key", "value")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Requests != 1003 || result.Errors != 0 {
+		t.Fatalf("expected 1003 requests without errors, got %d and %d errors", result.Requests, result.Errors)
+	}
+	if result.Latency.TotalCount() != 1003 {
+		t.Fatalf("expected 1003 latencies, got %d", result.Latency.TotalCount())
+	}
+
+	report := result.Report()
+	if report.Latency.P50 <= 0 || report.Latency.Max < report.Latency.P99 {
+		t.Fatalf("bad latency report %+v", report.Latency)
+	}
+	if _, err := json.Marshal(report); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	l := serveFake(t)
+	defer l.Close()
+
+	opts := &Options{Requests: 10, Clients: 1, Pipeline: 1}
+	result, err := Run("FAIL", l.Addr().String(), opts, nil, func(buf []byte) []byte {
+		return AppendCommand(buf, "FAIL")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Errors != 10 || result.FirstError != "ERR failed" {
+		t.Fatalf("expected 10 errors, got %d %q", result.Errors, result.FirstError)
+	}
+}
+
+func TestRun_Dial(t *testing.T) {
+	if _, err := Run("SET", "127.0.0.1:1", &Options{Requests: 1, Clients: 1}, nil, nil); err == nil {
+		t.Fatal("expected dial error")
+	}
+}
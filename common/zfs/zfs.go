@@ -101,6 +101,10 @@ func (*defaultLogger) Log(cmd []string) {
 
 var logger Logger = &defaultLogger{}
 
+// Binary is the zfs command line tool. A name without a path separator is
+// searched for in the directories named by the PATH environment variable.
+var Binary = "zfs"
+
 // SetLogger set a log handler to log all commands including arguments before
 // they are executed
 func SetLogger(l Logger) {
@@ -111,7 +115,7 @@ func SetLogger(l Logger) {
 
 // zfs is a helper function to wrap typical calls to zfs.
 func zfs(arg ...string) ([][]string, error) {
-	c := command{Command: "zfs"}
+	c := command{Command: Binary}
 	return c.Run(arg...)
 }
 
@@ -225,7 +229,7 @@ func (d *Dataset) Mount(overlay bool, options []string) (*Dataset, error) {
 // new snapshot with the specified name, and streams the input data into the
 // newly-created snapshot.
 func ReceiveSnapshot(input io.Reader, name string) (*Dataset, error) {
-	c := command{Command: "zfs", Stdin: input}
+	c := command{Command: Binary, Stdin: input}
 	_, err := c.Run("receive", name)
 	if err != nil {
 		return nil, err
@@ -240,11 +244,24 @@ func (d *Dataset) SendSnapshot(output io.Writer) error {
 		return errors.New("can only send snapshots")
 	}
 
-	c := command{Command: "zfs", Stdout: output}
+	c := command{Command: Binary, Stdout: output}
 	_, err := c.Run("send", d.Name)
 	return err
 }
 
+// IncrementalSend sends a ZFS stream of the changes between baseSnapshot and
+// the receiving snapshot to the input io.Writer.
+// An error will be returned if either dataset is not of snapshot type.
+func (d *Dataset) IncrementalSend(baseSnapshot *Dataset, output io.Writer) error {
+	if d.Type != DatasetSnapshot || baseSnapshot.Type != DatasetSnapshot {
+		return errors.New("can only send snapshots")
+	}
+
+	c := command{Command: Binary, Stdout: output}
+	_, err := c.Run("send", "-i", baseSnapshot.Name, d.Name)
+	return err
+}
+
 // CreateVolume creates a new ZFS volume with the specified name, size, and
 // properties.
 // A full list of available ZFS properties may be found here:
@@ -0,0 +1,511 @@
+package moved
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+var (
+	ErrConfigUnknown = errors.New("unknown config setting")
+	ErrConfigRestart = errors.New("config setting cannot change until a restart")
+)
+
+// Settings read from the config file and the CLI flags. The config tag is
+// the key of a setting. Settings tagged live apply while the node runs, the
+// others need a restart.
+type Config struct {
+	DataPath  string `config:"data.path"`
+	StorePath string `config:"store.path"`
+	PID       string `config:"pid"`
+	WebHost   string `config:"web.host"`
+	ApiHost   string `config:"api.host"`
+	ApiLoops  int    `config:"api.loops"`
+	Bootstrap bool   `config:"bootstrap"`
+
+	LogLevel string `config:"logging.level" live:"true"`
+
+	// Mounts of the drives mapped to their kind. An empty kind is taken from
+	// the mount.
+	Drives map[string]string `config:"drives" live:"true"`
+
+	NotifyKeyspaceEvents string `config:"notify.keyspace-events"`
+	NotifyCDCTopic       string `config:"notify.cdc-topic"`
+
+	ClusterUser     string `config:"cluster.user" live:"true"`
+	ClusterPassword string `config:"cluster.password" live:"true"`
+
+	// Users of this node only as "name rule ..." lines. They take precedence
+	// over the users of the cluster with the same name.
+	ACLUsers []string `config:"acl.users" live:"true"`
+
+	TLSCertFile       string `config:"tls.cert"`
+	TLSKeyFile        string `config:"tls.key"`
+	TLSCAFile         string `config:"tls.ca"`
+	TLSRaftClientAuth bool   `config:"tls.raft-client-auth"`
+	TLSServerName     string `config:"tls.server-name"`
+
+	SlowlogSlowerThan int64 `config:"slowlog.slower-than" live:"true"`
+	SlowlogMaxLen     int   `config:"slowlog.max-len" live:"true"`
+
+	SegmentSyncInterval int64 `config:"segment.sync-interval" live:"true"`
+
+	ScriptTimeout int64 `config:"script.timeout" live:"true"`
+
+	MetricsAnonymous bool `config:"metrics.anonymous" live:"true"`
+
+	RollerMaxBytes uint64 `config:"roller.max-bytes" live:"true"`
+	RollerMaxAge   uint64 `config:"roller.max-age" live:"true"`
+	RollerMaxCount uint64 `config:"roller.max-count" live:"true"`
+}
+
+// A setting that differs between two configs.
+type ConfigChange struct {
+	Key  string
+	From string
+	To   string
+	// Whether the change applies without a restart
+	Live bool
+}
+
+func (c ConfigChange) String() string {
+	return fmt.Sprintf("%s changed from \"%s\" to \"%s\"", c.Key, c.From, c.To)
+}
+
+type configField struct {
+	key   string
+	index int
+	live  bool
+}
+
+var configFields = func() []configField {
+	t := reflect.TypeOf(Config{})
+	fields := make([]configField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fields = append(fields, configField{
+			key:   f.Tag.Get("config"),
+			index: i,
+			live:  f.Tag.Get("live") == "true",
+		})
+	}
+	return fields
+}()
+
+func lookupField(key string) (configField, bool) {
+	key = strings.ToLower(key)
+	for _, f := range configFields {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return configField{}, false
+}
+
+// Keys of every setting in the order of Config.
+func ConfigKeys() []string {
+	keys := make([]string, len(configFields))
+	for i, f := range configFields {
+		keys[i] = f.key
+	}
+	return keys
+}
+
+// Whether the setting applies without a restart.
+func IsLive(key string) bool {
+	f, ok := lookupField(key)
+	return ok && f.live
+}
+
+// Reads the config from viper.
+func loadConfig() *Config {
+	c := &Config{}
+	v := reflect.ValueOf(c).Elem()
+	for _, f := range configFields {
+		field := v.Field(f.index)
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(viper.GetString(f.key))
+		case reflect.Bool:
+			field.SetBool(viper.GetBool(f.key))
+		case reflect.Int, reflect.Int64:
+			field.SetInt(viper.GetInt64(f.key))
+		case reflect.Uint64:
+			field.SetUint(cast.ToUint64(viper.Get(f.key)))
+		case reflect.Slice:
+			field.Set(reflect.ValueOf(viper.GetStringSlice(f.key)))
+		}
+	}
+	c.Drives = readDrives(viper.GetStringMap("drives"))
+
+	if c.DataPath == "" {
+		c.DataPath = DataDir
+	}
+	// Need at least 1 event loop
+	if c.ApiLoops <= 0 {
+		c.ApiLoops = 1
+	}
+	// Provide a sane upper event loop limit
+	if c.ApiLoops > runtime.NumCPU()*3 {
+		c.ApiLoops = runtime.NumCPU() * 3
+	}
+	c.LogLevel = strings.ToLower(c.LogLevel)
+	return c
+}
+
+func readDrives(m map[string]interface{}) map[string]string {
+	drives := make(map[string]string, len(m))
+	for mount, v := range m {
+		kind := ""
+		if props, ok := v.(map[string]interface{}); ok {
+			for k, v := range props {
+				switch strings.ToLower(k) {
+				case "type", "kind":
+					kind = strings.ToLower(fmt.Sprintf("%v", v))
+				}
+			}
+		}
+		drives[mount] = kind
+	}
+	return drives
+}
+
+// Checks every setting holds a usable value.
+func (c *Config) Validate() error {
+	if _, err := net.ResolveTCPAddr("tcp", c.ApiHost); err != nil {
+		return fmt.Errorf("api.host: %s", err)
+	}
+	if _, err := net.ResolveTCPAddr("tcp", c.WebHost); err != nil {
+		return fmt.Errorf("web.host: %s", err)
+	}
+	if c.LogLevel != "" {
+		if _, err := zerolog.ParseLevel(c.LogLevel); err != nil {
+			return fmt.Errorf("logging.level: unknown level \"%s\"", c.LogLevel)
+		}
+	}
+	for mount, kind := range c.Drives {
+		if _, ok := driveKind(kind); !ok {
+			return fmt.Errorf("drives: invalid drive kind \"%s\" for drive mounted at \"%s\"", kind, mount)
+		}
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return errors.New("tls.cert and tls.key are set together")
+	}
+	if c.SlowlogMaxLen < 0 {
+		return errors.New("slowlog.max-len cannot be negative")
+	}
+	if c.SegmentSyncInterval < 0 {
+		return errors.New("segment.sync-interval cannot be negative")
+	}
+	if c.ScriptTimeout <= 0 {
+		return errors.New("script.timeout must be positive")
+	}
+
+	configMu.Lock()
+	validators := configValidators
+	configMu.Unlock()
+	for _, validate := range validators {
+		if err := validate(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Value of the setting at key as it is written to the config file.
+func (c *Config) Get(key string) (string, bool) {
+	f, ok := lookupField(key)
+	if !ok {
+		return "", false
+	}
+	field := reflect.ValueOf(c).Elem().Field(f.index)
+	switch field.Kind() {
+	case reflect.Map:
+		return formatDrives(c.Drives), true
+	case reflect.Slice:
+		return strings.Join(field.Interface().([]string), "; "), true
+	default:
+		return fmt.Sprintf("%v", field.Interface()), true
+	}
+}
+
+// Parses value into the setting at key.
+func (c *Config) Set(key, value string) error {
+	f, ok := lookupField(key)
+	if !ok {
+		return ErrConfigUnknown
+	}
+	field := reflect.ValueOf(c).Elem().Field(f.index)
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s expects a boolean", f.key)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s expects an integer", f.key)
+		}
+		field.SetInt(n)
+	case reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s expects a positive integer", f.key)
+		}
+		field.SetUint(n)
+	case reflect.Map:
+		drives, err := parseDrives(value)
+		if err != nil {
+			return err
+		}
+		c.Drives = drives
+	case reflect.Slice:
+		field.Set(reflect.ValueOf(parseList(value)))
+	}
+	if f.key == "logging.level" {
+		c.LogLevel = strings.ToLower(c.LogLevel)
+	}
+	return nil
+}
+
+// Formats drives as comma separated mount=kind pairs.
+func formatDrives(drives map[string]string) string {
+	mounts := make([]string, 0, len(drives))
+	for mount := range drives {
+		mounts = append(mounts, mount)
+	}
+	sort.Strings(mounts)
+	for i, mount := range mounts {
+		if kind := drives[mount]; kind != "" {
+			mounts[i] = mount + "=" + kind
+		}
+	}
+	return strings.Join(mounts, ",")
+}
+
+// Splits a value of semicolon separated items.
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseDrives(value string) (map[string]string, error) {
+	drives := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		mount, kind := pair, ""
+		if i := strings.LastIndexByte(pair, '='); i > -1 {
+			mount, kind = pair[:i], strings.ToLower(pair[i+1:])
+		}
+		if mount == "" {
+			return nil, fmt.Errorf("drives: missing mount in \"%s\"", pair)
+		}
+		drives[mount] = kind
+	}
+	return drives, nil
+}
+
+// Settings that differ from one config to the other.
+func Diff(from, to *Config) []ConfigChange {
+	var changes []ConfigChange
+	for _, f := range configFields {
+		a, _ := from.Get(f.key)
+		b, _ := to.Get(f.key)
+		if a != b {
+			changes = append(changes, ConfigChange{Key: f.key, From: a, To: b, Live: f.live})
+		}
+	}
+	return changes
+}
+
+func (c *Config) clone() *Config {
+	cp := *c
+	cp.Drives = make(map[string]string, len(c.Drives))
+	for mount, kind := range c.Drives {
+		cp.Drives[mount] = kind
+	}
+	cp.ACLUsers = append([]string(nil), c.ACLUsers...)
+	return &cp
+}
+
+// Copies the setting at key from another config.
+func (c *Config) copyFrom(other *Config, key string) {
+	f, _ := lookupField(key)
+	if f.key == "drives" {
+		c.Drives = other.clone().Drives
+		return
+	}
+	reflect.ValueOf(c).Elem().Field(f.index).Set(reflect.ValueOf(other).Elem().Field(f.index))
+}
+
+var (
+	configMu         sync.Mutex
+	running          = &Config{}
+	configHooks      []func(changes []ConfigChange)
+	configValidators []func(c *Config) error
+)
+
+// Copy of the config the node runs with.
+func CurrentConfig() *Config {
+	configMu.Lock()
+	defer configMu.Unlock()
+	return running.clone()
+}
+
+// Calls fn with the changes every time settings apply while the node runs.
+func OnConfigChange(fn func(changes []ConfigChange)) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	configHooks = append(configHooks, fn)
+}
+
+// Calls fn to check every config before it applies. Settings that other
+// packages give meaning to are checked this way.
+func OnConfigValidate(fn func(c *Config) error) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	configValidators = append(configValidators, fn)
+}
+
+// User and password nodes authenticate to each other with.
+func ClusterCredentials() (user, password string) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	return ClusterUser, ClusterPassword
+}
+
+// Applies the live settings of c to the package settings. The lock must be
+// held. Numbers are stored atomically since they're read while the node
+// runs without the lock.
+func applyLive(c *Config) {
+	if c.LogLevel != "" {
+		if level, err := zerolog.ParseLevel(c.LogLevel); err == nil {
+			LogLevel = int(level)
+			zerolog.SetGlobalLevel(level)
+		}
+	}
+	ClusterUser = c.ClusterUser
+	ClusterPassword = c.ClusterPassword
+	atomic.StoreInt64(&SlowlogSlowerThan, c.SlowlogSlowerThan)
+	atomic.StoreInt64(&SlowlogMaxLen, int64(c.SlowlogMaxLen))
+	atomic.StoreInt64(&SegmentSyncInterval, c.SegmentSyncInterval)
+	atomic.StoreInt64(&ScriptTimeout, c.ScriptTimeout)
+	var anonymous int32
+	if c.MetricsAnonymous {
+		anonymous = 1
+	}
+	atomic.StoreInt32(&MetricsAnonymous, anonymous)
+	atomic.StoreUint64(&RollerMaxBytes, c.RollerMaxBytes)
+	atomic.StoreUint64(&RollerMaxAge, c.RollerMaxAge)
+	atomic.StoreUint64(&RollerMaxCount, c.RollerMaxCount)
+}
+
+// Applies the live changes of a reloaded config. Changes that need a restart
+// are rejected and keep their running value.
+func reloadConfig(next *Config) (applied, rejected []ConfigChange, err error) {
+	if err = next.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	configMu.Lock()
+	updated := running.clone()
+	for _, change := range Diff(running, next) {
+		if !change.Live {
+			rejected = append(rejected, change)
+			continue
+		}
+		updated.copyFrom(next, change.Key)
+		applied = append(applied, change)
+	}
+	running = updated
+	if len(applied) > 0 {
+		applyLive(updated)
+	}
+	hooks := configHooks
+	configMu.Unlock()
+
+	if len(applied) > 0 {
+		for _, hook := range hooks {
+			hook(applied)
+		}
+	}
+	return applied, rejected, nil
+}
+
+// Changes a live setting while the node runs. The config file is left as is
+// until RewriteConfig.
+func SetConfig(key, value string) error {
+	if _, ok := lookupField(key); !ok {
+		return ErrConfigUnknown
+	}
+	if !IsLive(key) {
+		return ErrConfigRestart
+	}
+	next := CurrentConfig()
+	if err := next.Set(key, value); err != nil {
+		return err
+	}
+	_, _, err := reloadConfig(next)
+	return err
+}
+
+// Writes the running config to the config file and returns its name. Keys of
+// the file that are not settings are kept.
+func RewriteConfig() (string, error) {
+	filename := viper.ConfigFileUsed()
+	if filename == "" {
+		filename = "config.yml"
+	}
+
+	c := CurrentConfig()
+	w := viper.New()
+	for _, key := range viper.AllKeys() {
+		if _, ok := lookupField(key); !ok && !strings.HasPrefix(key, "drives.") {
+			w.Set(key, viper.Get(key))
+		}
+	}
+	v := reflect.ValueOf(c).Elem()
+	for _, f := range configFields {
+		if f.key == "drives" {
+			drives := make(map[string]interface{}, len(c.Drives))
+			for mount, kind := range c.Drives {
+				drives[mount] = map[string]interface{}{"type": kind}
+			}
+			w.Set(f.key, drives)
+			continue
+		}
+		w.Set(f.key, v.Field(f.index).Interface())
+	}
+	// Replace the file whole so it is never read half written
+	tmp := filepath.Join(filepath.Dir(filename), ".rewrite."+filepath.Base(filename))
+	if err := w.WriteConfigAs(tmp); err != nil {
+		os.Remove(tmp)
+		return filename, err
+	}
+	return filename, os.Rename(tmp, filename)
+}
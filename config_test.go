@@ -1,8 +1,11 @@
 package moved
 
 import (
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/rs/zerolog"
 )
 
 func TestConfig(t *testing.T) {
@@ -11,4 +14,141 @@ func TestConfig(t *testing.T) {
 	}
 
 	time.Sleep(time.Second * 100000)
-}
\ No newline at end of file
+}
+
+func testConfig() *Config {
+	return &Config{
+		ApiHost:  ":9002",
+		WebHost:  ":9003",
+		ApiLoops: 1,
+		LogLevel: "debug",
+		Drives:   map[string]string{"home": "ssd"},
+
+		ScriptTimeout: 5000,
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	c := testConfig()
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("drives", "home=ssd,/mnt/a=floppy"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected an invalid drive kind")
+	}
+	if err := c.Set("slowlog.max-len", "many"); err == nil {
+		t.Fatal("expected an integer")
+	}
+	if err := c.Set("nope", "1"); err != ErrConfigUnknown {
+		t.Fatalf("expected ErrConfigUnknown got %v", err)
+	}
+}
+
+// Restores the package settings a reload changes.
+func keepLive() func() {
+	prev, prevHooks := running, configHooks
+	logLevel, globalLevel := LogLevel, zerolog.GlobalLevel()
+	user, password := ClusterUser, ClusterPassword
+	slowerThan, maxLen := SlowlogSlowerThan, SlowlogMaxLen
+	syncInterval, scriptTimeout := SegmentSyncInterval, ScriptTimeout
+	metricsAnonymous := MetricsAnonymous
+	maxBytes, maxAge, maxCount := RollerMaxBytes, RollerMaxAge, RollerMaxCount
+	return func() {
+		// The drives are read from the running config
+		running, configHooks = prev, prevHooks
+		LogLevel = logLevel
+		zerolog.SetGlobalLevel(globalLevel)
+		ClusterUser, ClusterPassword = user, password
+		SlowlogSlowerThan, SlowlogMaxLen = slowerThan, maxLen
+		SegmentSyncInterval, ScriptTimeout = syncInterval, scriptTimeout
+		MetricsAnonymous = metricsAnonymous
+		RollerMaxBytes, RollerMaxAge, RollerMaxCount = maxBytes, maxAge, maxCount
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	defer keepLive()()
+	running, configHooks = testConfig(), nil
+
+	var changed []ConfigChange
+	OnConfigChange(func(changes []ConfigChange) { changed = changes })
+
+	next := testConfig()
+	next.Set("slowlog.max-len", "64")
+	next.Set("drives", "home=ssd,/mnt/a=hdd")
+	next.Set("api.host", ":9102")
+
+	applied, rejected, err := reloadConfig(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 || len(changed) != 2 || atomic.LoadInt64(&SlowlogMaxLen) != 64 {
+		t.Fatalf("expected slowlog.max-len and drives to apply got %v", applied)
+	}
+	if len(rejected) != 1 || rejected[0].Key != "api.host" || rejected[0].To != ":9102" {
+		t.Fatalf("expected api.host to be rejected got %v", rejected)
+	}
+
+	current := CurrentConfig()
+	if current.ApiHost != ":9002" || current.Drives["/mnt/a"] != "hdd" {
+		t.Fatalf("expected only live settings to change got %+v", current)
+	}
+	if _, ok := GetDrives()["/mnt/a"]; !ok {
+		t.Fatal("expected the new drive")
+	}
+
+	if err := SetConfig("api.host", ":9102"); err != ErrConfigRestart {
+		t.Fatalf("expected ErrConfigRestart got %v", err)
+	}
+	if err := SetConfig("logging.level", "loud"); err == nil {
+		t.Fatal("expected an invalid level")
+	}
+	if err := SetConfig("roller.max-count", "1000"); err != nil || atomic.LoadUint64(&RollerMaxCount) != 1000 {
+		t.Fatalf("expected roller.max-count to apply got %v", err)
+	}
+	if err := SetConfig("script.timeout", "0"); err == nil {
+		t.Fatal("expected a positive script.timeout")
+	}
+	if err := SetConfig("script.timeout", "250"); err != nil || atomic.LoadInt64(&ScriptTimeout) != 250 {
+		t.Fatalf("expected script.timeout to apply got %v", err)
+	}
+	if atomic.LoadInt32(&MetricsAnonymous) != 0 {
+		t.Fatal("expected metrics.anonymous to default to off")
+	}
+	if err := SetConfig("metrics.anonymous", "true"); err != nil || atomic.LoadInt32(&MetricsAnonymous) != 1 {
+		t.Fatalf("expected metrics.anonymous to apply got %v", err)
+	}
+
+	if err := SetConfig("acl.users", "ops on >secret allcommands; audit on nopass"); err != nil {
+		t.Fatal(err)
+	}
+	if users := CurrentConfig().ACLUsers; len(users) != 2 || users[1] != "audit on nopass" {
+		t.Fatalf("expected two users got %q", users)
+	}
+	if value, _ := CurrentConfig().Get("acl.users"); value != "ops on >secret allcommands; audit on nopass" {
+		t.Fatalf("expected the users joined got %q", value)
+	}
+}
+
+func TestConfig_ValidateHook(t *testing.T) {
+	defer keepLive()()
+	running, configHooks = testConfig(), nil
+	prevValidators := configValidators
+	defer func() { configValidators = prevValidators }()
+
+	OnConfigValidate(func(c *Config) error {
+		if len(c.ACLUsers) > 1 {
+			return ErrConfigUnknown
+		}
+		return nil
+	})
+	if err := SetConfig("acl.users", "a on nopass; b on nopass"); err != ErrConfigUnknown {
+		t.Fatalf("expected the validator to reject got %v", err)
+	}
+	if len(CurrentConfig().ACLUsers) != 0 {
+		t.Fatal("expected the users to be unchanged")
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
-	"runtime"
 	"strings"
 	"time"
 
@@ -77,6 +76,52 @@ var (
 
 	RaftTimeout = time.Second * 10
 
+	// Credentials members use to AUTH with each other
+	ClusterUser     = "default"
+	ClusterPassword = ""
+
+	// TLS for the API, raft and web listeners. TLS is off without a
+	// certificate.
+	TLSCertFile = ""
+	TLSKeyFile  = ""
+	TLSCAFile   = ""
+	// Raft commands require a client certificate verified by the CA
+	TLSRaftClientAuth = false
+	// Name checked in the certificates of other members instead of their
+	// address
+	TLSServerName = ""
+
+	// Keyspace notification classes in the notify-keyspace-events format
+	NotifyKeyspaceEvents = ""
+	// Topic every keyspace change is appended to. Empty disables the feed.
+	NotifyCDCTopic = ""
+
+	// The slowlog, segment sync, script, metrics and roller settings change while the
+	// node runs and are read with sync/atomic.
+
+	// Commands taking at least this many microseconds are kept in the
+	// SLOWLOG. Negative disables the log.
+	SlowlogSlowerThan int64 = 10000
+	SlowlogMaxLen     int64 = 128
+
+	// Milliseconds between syncs of the segments of BUSINESS topics
+	SegmentSyncInterval int64 = 1000
+
+	// Milliseconds a script may run. It is turned into a budget of VM
+	// instructions when the script is proposed so every node stops it at the
+	// same instruction.
+	ScriptTimeout int64 = 5000
+
+	// Whether /metrics is served without authentication. It is one when
+	// set.
+	MetricsAnonymous int32
+
+	// Limits of rollers that leave them unset. Zero is no limit. The age is
+	// in seconds.
+	RollerMaxBytes uint64
+	RollerMaxAge   uint64
+	RollerMaxCount uint64
+
 	// File system stuff
 	UserHomeDir    = ""
 	HomeDir        = ""
@@ -194,6 +239,24 @@ func readConfig() error {
 	viper.SetDefault("api.loops", EventLoops)
 	viper.SetDefault("store.path", StoreDir)
 	viper.SetDefault("pid", PIDName)
+	viper.SetDefault("notify.keyspace-events", NotifyKeyspaceEvents)
+	viper.SetDefault("notify.cdc-topic", NotifyCDCTopic)
+	viper.SetDefault("cluster.user", ClusterUser)
+	viper.SetDefault("cluster.password", ClusterPassword)
+	viper.SetDefault("tls.cert", TLSCertFile)
+	viper.SetDefault("tls.key", TLSKeyFile)
+	viper.SetDefault("tls.ca", TLSCAFile)
+	viper.SetDefault("tls.raft-client-auth", TLSRaftClientAuth)
+	viper.SetDefault("tls.server-name", TLSServerName)
+	viper.SetDefault("slowlog.slower-than", SlowlogSlowerThan)
+	viper.SetDefault("slowlog.max-len", SlowlogMaxLen)
+	viper.SetDefault("segment.sync-interval", SegmentSyncInterval)
+	viper.SetDefault("script.timeout", ScriptTimeout)
+	viper.SetDefault("metrics.anonymous", MetricsAnonymous == 1)
+	viper.SetDefault("roller.max-bytes", RollerMaxBytes)
+	viper.SetDefault("roller.max-age", RollerMaxAge)
+	viper.SetDefault("roller.max-count", RollerMaxCount)
+	viper.SetDefault("logging.level", zerolog.Level(LogLevel).String())
 	//viper.SetDefault("raft.host", RaftHost)
 
 	// Setup config file name and directories to search for it
@@ -221,114 +284,138 @@ func readConfig() error {
 	}
 
 	// Watch config file for changes
-	//viper.OnConfigChange(configChanged)
-	//viper.WatchConfig()
+	if viper.ConfigFileUsed() != "" {
+		viper.OnConfigChange(configChanged)
+		viper.WatchConfig()
+	}
 	return nil
 }
 
+// Time given to editors to finish writing the config file before it is read
+// again.
+var configSettle = 100 * time.Millisecond
+
 func configChanged(in fsnotify.Event) {
 	Logger.Warn().Msgf("config file changed: %s", in.Name)
+	time.Sleep(configSettle)
+	if err := viper.ReadInConfig(); err != nil {
+		Logger.Error().Err(err).Msg("config file could not be read")
+		return
+	}
 	applyConfig()
 }
 
-//func Lock() *single.LockResult {
-//	if PID != nil {
-//		return PIDLock
-//	}
-//
-//	PID = single.New(PIDName)
-//	result := PID.Lock()
-//
-//	PIDLock = &result
-//	return PIDLock
-//}
+// Path of the PID lock file of the node.
+func PIDPath() string {
+	return filepath.Join(HomeDir, PIDName+".pid")
+}
 
+// Takes the PID lock so a single daemon runs per home directory. The lock
+// file holds the pid and API port of the daemon. When another process holds
+// the lock the result has its pid and port.
 func Lock() *single.LockResult {
-	return &single.LockResult{
-		Success: true,
-		Err:     nil,
-		Pid:     0,
-		Port:    0,
+	if PID != nil {
+		return PIDLock
 	}
-}
-
-func Unlock() error {
-	return nil
-}
-
-//func Unlock() error {
-//	if PID == nil {
-//		return nil
-//	} else {
-//		return PID.Unlock()
-//	}
-//}
-
-func applyConfig() error {
-	path := viper.GetString("data.path")
-	webHost := viper.GetString("web.host")
-	loops := viper.GetInt("api.loops")
-	apiHost := viper.GetString("api.host")
-	storePath := viper.GetString("store.path")
-	PIDName = viper.GetString("pid")
-	Bootstrap = viper.GetBool("bootstrap")
-	//RaftHost = viper.GetString("raft.host")
-
-	if path == "" {
-		path = DataDir
+	if err := os.MkdirAll(HomeDir, PathMode); err != nil {
+		return &single.LockResult{Err: err}
 	}
-	// Need at least 1 event loop
-	if loops <= 0 {
-		loops = 1
+
+	lock := single.NewFile(PIDPath())
+	result := lock.Lock(ApiPort)
+	if !result.Success {
+		return &result
 	}
-	// Provide a sane upper event loop limit
-	if loops > runtime.NumCPU()*3 {
-		loops = runtime.NumCPU() * 3
+	if result.Stale > 0 {
+		Logger.Warn().Msgf("removed the stale lock of pid %d", result.Stale)
 	}
 
-	if first {
-		first = false
-		DataDir = path
-		EventLoops = loops
-		WebHost = webHost
-		ApiHost = apiHost
-		StoreDir = storePath
+	PID = lock
+	PIDLock = &result
+	return PIDLock
+}
 
-		layoutDisk()
+func Unlock() error {
+	if PID == nil {
+		return nil
+	}
+	err := PID.Unlock()
+	PID, PIDLock = nil, nil
+	return err
+}
 
-		addresses, err := findLocalIPs()
-		if err != nil {
+func applyConfig() error {
+	config := loadConfig()
+	if err := config.Validate(); err != nil {
+		if first {
 			return err
 		}
+		Logger.Error().Err(err).Msg("invalid config was not applied")
+		return err
+	}
 
-		apiAddr, err := net.ResolveTCPAddr("tcp", ApiHost)
+	if !first {
+		applied, rejected, err := reloadConfig(config)
 		if err != nil {
 			return err
 		}
-		ApiAddr = apiAddr
-
-		// Was a local interface IP discovered?
-		if len(addresses) > 0 {
-			// Use the first IP as the Raft address
-			ClusterAddress = raft.ServerAddress(fmt.Sprintf("%s:%d", addresses[0].To4(), apiAddr.Port))
-			ClusterID = raft.ServerID(ClusterAddress)
-			ApiPort = apiAddr.Port
-
-			Logger.Info().Msgf("raft cluster address: %s", ClusterAddress)
-			Logger.Info().Msgf("raft cluster id: %s", ClusterID)
-		} else {
-			// A Raft address could not be determined
-			return ErrClusterAddress
+		for _, change := range applied {
+			Logger.Info().Msgf("%s", change)
 		}
-	} else {
-		// See what changed
-		if path != DataDir {
-			Logger.Warn().Msgf("path changed from %s to %s but cannot apply this change until a restart", DataDir, path)
+		// See what cannot change yet
+		for _, change := range rejected {
+			Logger.Warn().Msgf("%s but cannot apply this change until a restart", change)
 		}
+		return nil
+	}
 
-		if loops != EventLoops {
-			Logger.Warn().Msgf("loops changed from %d to %d but cannot apply this change until a restart", EventLoops, loops)
-		}
+	first = false
+	PIDName = config.PID
+	Bootstrap = config.Bootstrap
+	NotifyKeyspaceEvents = config.NotifyKeyspaceEvents
+	NotifyCDCTopic = config.NotifyCDCTopic
+	TLSCertFile = config.TLSCertFile
+	TLSKeyFile = config.TLSKeyFile
+	TLSCAFile = config.TLSCAFile
+	TLSRaftClientAuth = config.TLSRaftClientAuth
+	TLSServerName = config.TLSServerName
+	DataDir = config.DataPath
+	EventLoops = config.ApiLoops
+	WebHost = config.WebHost
+	ApiHost = config.ApiHost
+	StoreDir = config.StorePath
+	//RaftHost = viper.GetString("raft.host")
+
+	configMu.Lock()
+	running = config
+	applyLive(config)
+	configMu.Unlock()
+
+	layoutDisk()
+
+	addresses, err := findLocalIPs()
+	if err != nil {
+		return err
+	}
+
+	apiAddr, err := net.ResolveTCPAddr("tcp", ApiHost)
+	if err != nil {
+		return err
+	}
+	ApiAddr = apiAddr
+
+	// Was a local interface IP discovered?
+	if len(addresses) > 0 {
+		// Use the first IP as the Raft address
+		ClusterAddress = raft.ServerAddress(fmt.Sprintf("%s:%d", addresses[0].To4(), apiAddr.Port))
+		ClusterID = raft.ServerID(ClusterAddress)
+		ApiPort = apiAddr.Port
+
+		Logger.Info().Msgf("raft cluster address: %s", ClusterAddress)
+		Logger.Info().Msgf("raft cluster id: %s", ClusterID)
+	} else {
+		// A Raft address could not be determined
+		return ErrClusterAddress
 	}
 
 	return nil
@@ -343,9 +430,32 @@ func GetDrivesList() []*store.Drive {
 	return drives
 }
 
-// Retrieves the drives from config
+// Kind of drive named in the config. Empty is left to the mount.
+func driveKind(kind string) (store.Drive_Kind, bool) {
+	switch strings.ToLower(kind) {
+	case "":
+		return store.Drive_HDD, true
+
+	case "hdd", "hard", "harddrive", "hard-drive":
+		return store.Drive_HDD, true
+
+	case "ssd", "solid-state", "solid":
+		return store.Drive_SSD, true
+
+	case "nvm", "nvme", "pci", "pcie":
+		return store.Drive_NVME, true
+	}
+	return store.Drive_HDD, false
+}
+
+// Retrieves the drives from the running config
 func GetDrives() (drives map[string]*store.Drive) {
-	driveMap := viper.GetStringMap("drives")
+	configMu.Lock()
+	driveMap := make(map[string]string, len(running.Drives))
+	for k, v := range running.Drives {
+		driveMap[k] = v
+	}
+	configMu.Unlock()
 	drives = make(map[string]*store.Drive)
 
 	var working *store.Drive
@@ -375,31 +485,12 @@ loop:
 			drive.Working = true
 		}
 
-		switch m := v.(type) {
-		case map[string]interface{}:
-			for k2, v := range m {
-				switch strings.ToLower(k2) {
-				case "type", "kind":
-					val := fmt.Sprintf("%s", v)
-					switch strings.ToLower(val) {
-					case "hdd", "hard", "harddrive", "hard-drive":
-						drive.Kind = store.Drive_HDD
-
-					case "ssd", "solid-state", "solid":
-						drive.Kind = store.Drive_SSD
-
-					case "nvm", "nvme", "pci", "pcie":
-						drive.Kind = store.Drive_NVME
-
-					default:
-						Logger.Warn().Msgf("invalid drive kind '%s' for drive mounted at: '%s' defaulting to 'hdd'", val, drive.Mount)
-						drive.Kind = store.Drive_HDD
-					}
-				}
-				Logger.Debug().Msgf("property: %s -> %s", k2, v)
+		if v != "" {
+			kind, ok := driveKind(v)
+			if !ok {
+				Logger.Warn().Msgf("invalid drive kind '%s' for drive mounted at: '%s' defaulting to 'hdd'", v, drive.Mount)
 			}
-		default:
-			Logger.Debug().Msgf("drive named: %s has an invalid value %v", k, m)
+			drive.Kind = kind
 		}
 
 		drives[drive.Mount] = drive
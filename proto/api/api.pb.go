@@ -13,7 +13,15 @@ It has these top-level messages:
 	RegisterRequest
 	RegisterReply
 	EventsRequest
+	EventAttribute
 	Event
+	RegisterSchemaRequest
+	SchemaInfo
+	ListSchemasRequest
+	ListSchemasReply
+	GetSchemaRequest
+	DeleteSchemaRequest
+	DeleteSchemaReply
 */
 package api
 
@@ -37,7 +45,94 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+type EventKind int32
+
+const (
+	EventKind_EVENT_UNKNOWN     EventKind = 0
+	EventKind_LEADER_CHANGED    EventKind = 1
+	EventKind_NODE_JOINED       EventKind = 2
+	EventKind_NODE_LEFT         EventKind = 3
+	EventKind_DRIVE_ADDED       EventKind = 4
+	EventKind_DRIVE_REMOVED     EventKind = 5
+	EventKind_TOPIC_CREATED     EventKind = 7
+	EventKind_SCHEMA_REGISTERED EventKind = 8
+	EventKind_SCHEMA_DELETED    EventKind = 9
+	EventKind_DRIVE_FULL        EventKind = 11
+	EventKind_SEGMENT_MIGRATED  EventKind = 12
+	EventKind_STORE_READ_ONLY   EventKind = 13
+	EventKind_STORE_WRITABLE    EventKind = 14
+)
+
+var EventKind_name = map[int32]string{
+	0:  "EVENT_UNKNOWN",
+	1:  "LEADER_CHANGED",
+	2:  "NODE_JOINED",
+	3:  "NODE_LEFT",
+	4:  "DRIVE_ADDED",
+	5:  "DRIVE_REMOVED",
+	7:  "TOPIC_CREATED",
+	8:  "SCHEMA_REGISTERED",
+	9:  "SCHEMA_DELETED",
+	11: "DRIVE_FULL",
+	12: "SEGMENT_MIGRATED",
+	13: "STORE_READ_ONLY",
+	14: "STORE_WRITABLE",
+}
+var EventKind_value = map[string]int32{
+	"EVENT_UNKNOWN":     0,
+	"LEADER_CHANGED":    1,
+	"NODE_JOINED":       2,
+	"NODE_LEFT":         3,
+	"DRIVE_ADDED":       4,
+	"DRIVE_REMOVED":     5,
+	"TOPIC_CREATED":     7,
+	"SCHEMA_REGISTERED": 8,
+	"SCHEMA_DELETED":    9,
+	"DRIVE_FULL":        11,
+	"SEGMENT_MIGRATED":  12,
+	"STORE_READ_ONLY":   13,
+	"STORE_WRITABLE":    14,
+}
+
+func (x EventKind) String() string {
+	return proto.EnumName(EventKind_name, int32(x))
+}
+func (EventKind) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+type Compatibility int32
+
+const (
+	// Unset keeps the level of the package, BACKWARD for a new one
+	Compatibility_DEFAULT  Compatibility = 0
+	Compatibility_BACKWARD Compatibility = 1
+	Compatibility_FORWARD  Compatibility = 2
+	Compatibility_FULL     Compatibility = 3
+	Compatibility_NONE     Compatibility = 4
+)
+
+var Compatibility_name = map[int32]string{
+	0: "DEFAULT",
+	1: "BACKWARD",
+	2: "FORWARD",
+	3: "FULL",
+	4: "NONE",
+}
+var Compatibility_value = map[string]int32{
+	"DEFAULT":  0,
+	"BACKWARD": 1,
+	"FORWARD":  2,
+	"FULL":     3,
+	"NONE":     4,
+}
+
+func (x Compatibility) String() string {
+	return proto.EnumName(Compatibility_name, int32(x))
+}
+func (Compatibility) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
 type AuthRequest struct {
+	Username string `protobuf:"bytes,1,opt,name=username" json:"username,omitempty"`
+	Password string `protobuf:"bytes,2,opt,name=password" json:"password,omitempty"`
 }
 
 func (m *AuthRequest) Reset()                    { *m = AuthRequest{} }
@@ -45,7 +140,25 @@ func (m *AuthRequest) String() string            { return proto.CompactTextStrin
 func (*AuthRequest) ProtoMessage()               {}
 func (*AuthRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
 
+func (m *AuthRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *AuthRequest) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
 type AuthReply struct {
+	// Sent as "authorization: Bearer <token>" metadata with later calls
+	Token string `protobuf:"bytes,1,opt,name=token" json:"token,omitempty"`
+	// Unix seconds
+	Expires int64 `protobuf:"varint,2,opt,name=expires" json:"expires,omitempty"`
 }
 
 func (m *AuthReply) Reset()                    { *m = AuthReply{} }
@@ -53,7 +166,25 @@ func (m *AuthReply) String() string            { return proto.CompactTextString(
 func (*AuthReply) ProtoMessage()               {}
 func (*AuthReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
 
+func (m *AuthReply) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+func (m *AuthReply) GetExpires() int64 {
+	if m != nil {
+		return m.Expires
+	}
+	return 0
+}
+
 type RegisterRequest struct {
+	Username string `protobuf:"bytes,1,opt,name=username" json:"username,omitempty"`
+	Password string `protobuf:"bytes,2,opt,name=password" json:"password,omitempty"`
+	// ACL SETUSER rules applied after the password
+	Rules []string `protobuf:"bytes,3,rep,name=rules" json:"rules,omitempty"`
 }
 
 func (m *RegisterRequest) Reset()                    { *m = RegisterRequest{} }
@@ -61,6 +192,27 @@ func (m *RegisterRequest) String() string            { return proto.CompactTextS
 func (*RegisterRequest) ProtoMessage()               {}
 func (*RegisterRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
 
+func (m *RegisterRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *RegisterRequest) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+func (m *RegisterRequest) GetRules() []string {
+	if m != nil {
+		return m.Rules
+	}
+	return nil
+}
+
 type RegisterReply struct {
 }
 
@@ -70,6 +222,15 @@ func (*RegisterReply) ProtoMessage()               {}
 func (*RegisterReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
 
 type EventsRequest struct {
+	// Empty streams every kind
+	Kinds []EventKind `protobuf:"varint,1,rep,name=kinds,enum=api.EventKind" json:"kinds,omitempty"`
+	// Glob pattern the subject must match. Empty matches every subject.
+	Subject string `protobuf:"bytes,2,opt,name=subject" json:"subject,omitempty"`
+	// Replays the events from this sequence that the node still has before
+	// streaming new ones. Zero only streams new events.
+	FromSeq uint64 `protobuf:"varint,3,opt,name=fromSeq" json:"fromSeq,omitempty"`
+	// Epoch of fromSeq. The stream fails when it's not the node's epoch.
+	Epoch uint64 `protobuf:"varint,4,opt,name=epoch" json:"epoch,omitempty"`
 }
 
 func (m *EventsRequest) Reset()                    { *m = EventsRequest{} }
@@ -77,13 +238,303 @@ func (m *EventsRequest) String() string            { return proto.CompactTextStr
 func (*EventsRequest) ProtoMessage()               {}
 func (*EventsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
 
+func (m *EventsRequest) GetKinds() []EventKind {
+	if m != nil {
+		return m.Kinds
+	}
+	return nil
+}
+
+func (m *EventsRequest) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+func (m *EventsRequest) GetFromSeq() uint64 {
+	if m != nil {
+		return m.FromSeq
+	}
+	return 0
+}
+
+func (m *EventsRequest) GetEpoch() uint64 {
+	if m != nil {
+		return m.Epoch
+	}
+	return 0
+}
+
+type EventAttribute struct {
+	Key   string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+}
+
+func (m *EventAttribute) Reset()                    { *m = EventAttribute{} }
+func (m *EventAttribute) String() string            { return proto.CompactTextString(m) }
+func (*EventAttribute) ProtoMessage()               {}
+func (*EventAttribute) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *EventAttribute) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *EventAttribute) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
 type Event struct {
+	Seq uint64 `protobuf:"varint,1,opt,name=seq" json:"seq,omitempty"`
+	// Unix nanoseconds
+	Time int64     `protobuf:"varint,2,opt,name=time" json:"time,omitempty"`
+	Kind EventKind `protobuf:"varint,3,opt,name=kind,enum=api.EventKind" json:"kind,omitempty"`
+	// Node address, drive mount, schema package or topic name
+	Subject    string            `protobuf:"bytes,4,opt,name=subject" json:"subject,omitempty"`
+	Attributes []*EventAttribute `protobuf:"bytes,5,rep,name=attributes" json:"attributes,omitempty"`
+	Epoch      uint64            `protobuf:"varint,6,opt,name=epoch" json:"epoch,omitempty"`
 }
 
 func (m *Event) Reset()                    { *m = Event{} }
 func (m *Event) String() string            { return proto.CompactTextString(m) }
 func (*Event) ProtoMessage()               {}
-func (*Event) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*Event) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *Event) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+func (m *Event) GetTime() int64 {
+	if m != nil {
+		return m.Time
+	}
+	return 0
+}
+
+func (m *Event) GetKind() EventKind {
+	if m != nil {
+		return m.Kind
+	}
+	return EventKind_EVENT_UNKNOWN
+}
+
+func (m *Event) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+func (m *Event) GetAttributes() []*EventAttribute {
+	if m != nil {
+		return m.Attributes
+	}
+	return nil
+}
+
+func (m *Event) GetEpoch() uint64 {
+	if m != nil {
+		return m.Epoch
+	}
+	return 0
+}
+
+type RegisterSchemaRequest struct {
+	Package       string        `protobuf:"bytes,1,opt,name=package" json:"package,omitempty"`
+	Compatibility Compatibility `protobuf:"varint,2,opt,name=compatibility,enum=api.Compatibility" json:"compatibility,omitempty"`
+	// Serialized and optionally gzipped FileDescriptorSet
+	DescriptorSet []byte `protobuf:"bytes,3,opt,name=descriptorSet,proto3" json:"descriptorSet,omitempty"`
+}
+
+func (m *RegisterSchemaRequest) Reset()                    { *m = RegisterSchemaRequest{} }
+func (m *RegisterSchemaRequest) String() string            { return proto.CompactTextString(m) }
+func (*RegisterSchemaRequest) ProtoMessage()               {}
+func (*RegisterSchemaRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *RegisterSchemaRequest) GetPackage() string {
+	if m != nil {
+		return m.Package
+	}
+	return ""
+}
+
+func (m *RegisterSchemaRequest) GetCompatibility() Compatibility {
+	if m != nil {
+		return m.Compatibility
+	}
+	return Compatibility_DEFAULT
+}
+
+func (m *RegisterSchemaRequest) GetDescriptorSet() []byte {
+	if m != nil {
+		return m.DescriptorSet
+	}
+	return nil
+}
+
+type SchemaInfo struct {
+	Package       string        `protobuf:"bytes,1,opt,name=package" json:"package,omitempty"`
+	Version       int32         `protobuf:"varint,2,opt,name=version" json:"version,omitempty"`
+	Compatibility Compatibility `protobuf:"varint,3,opt,name=compatibility,enum=api.Compatibility" json:"compatibility,omitempty"`
+	Hash          string        `protobuf:"bytes,4,opt,name=hash" json:"hash,omitempty"`
+	Timestamp     int64         `protobuf:"varint,5,opt,name=timestamp" json:"timestamp,omitempty"`
+	// Gzipped FileDescriptorSet
+	DescriptorSet []byte   `protobuf:"bytes,6,opt,name=descriptorSet,proto3" json:"descriptorSet,omitempty"`
+	Messages      []string `protobuf:"bytes,7,rep,name=messages" json:"messages,omitempty"`
+}
+
+func (m *SchemaInfo) Reset()                    { *m = SchemaInfo{} }
+func (m *SchemaInfo) String() string            { return proto.CompactTextString(m) }
+func (*SchemaInfo) ProtoMessage()               {}
+func (*SchemaInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *SchemaInfo) GetPackage() string {
+	if m != nil {
+		return m.Package
+	}
+	return ""
+}
+
+func (m *SchemaInfo) GetVersion() int32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *SchemaInfo) GetCompatibility() Compatibility {
+	if m != nil {
+		return m.Compatibility
+	}
+	return Compatibility_DEFAULT
+}
+
+func (m *SchemaInfo) GetHash() string {
+	if m != nil {
+		return m.Hash
+	}
+	return ""
+}
+
+func (m *SchemaInfo) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *SchemaInfo) GetDescriptorSet() []byte {
+	if m != nil {
+		return m.DescriptorSet
+	}
+	return nil
+}
+
+func (m *SchemaInfo) GetMessages() []string {
+	if m != nil {
+		return m.Messages
+	}
+	return nil
+}
+
+type ListSchemasRequest struct {
+	// Empty lists the latest version of every package
+	Package string `protobuf:"bytes,1,opt,name=package" json:"package,omitempty"`
+}
+
+func (m *ListSchemasRequest) Reset()                    { *m = ListSchemasRequest{} }
+func (m *ListSchemasRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListSchemasRequest) ProtoMessage()               {}
+func (*ListSchemasRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *ListSchemasRequest) GetPackage() string {
+	if m != nil {
+		return m.Package
+	}
+	return ""
+}
+
+type ListSchemasReply struct {
+	Schemas []*SchemaInfo `protobuf:"bytes,1,rep,name=schemas" json:"schemas,omitempty"`
+}
+
+func (m *ListSchemasReply) Reset()                    { *m = ListSchemasReply{} }
+func (m *ListSchemasReply) String() string            { return proto.CompactTextString(m) }
+func (*ListSchemasReply) ProtoMessage()               {}
+func (*ListSchemasReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *ListSchemasReply) GetSchemas() []*SchemaInfo {
+	if m != nil {
+		return m.Schemas
+	}
+	return nil
+}
+
+type GetSchemaRequest struct {
+	Package string `protobuf:"bytes,1,opt,name=package" json:"package,omitempty"`
+	// 0 is the latest version
+	Version int32 `protobuf:"varint,2,opt,name=version" json:"version,omitempty"`
+}
+
+func (m *GetSchemaRequest) Reset()                    { *m = GetSchemaRequest{} }
+func (m *GetSchemaRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetSchemaRequest) ProtoMessage()               {}
+func (*GetSchemaRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *GetSchemaRequest) GetPackage() string {
+	if m != nil {
+		return m.Package
+	}
+	return ""
+}
+
+func (m *GetSchemaRequest) GetVersion() int32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+type DeleteSchemaRequest struct {
+	Package string `protobuf:"bytes,1,opt,name=package" json:"package,omitempty"`
+	Version int32  `protobuf:"varint,2,opt,name=version" json:"version,omitempty"`
+}
+
+func (m *DeleteSchemaRequest) Reset()                    { *m = DeleteSchemaRequest{} }
+func (m *DeleteSchemaRequest) String() string            { return proto.CompactTextString(m) }
+func (*DeleteSchemaRequest) ProtoMessage()               {}
+func (*DeleteSchemaRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *DeleteSchemaRequest) GetPackage() string {
+	if m != nil {
+		return m.Package
+	}
+	return ""
+}
+
+func (m *DeleteSchemaRequest) GetVersion() int32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+type DeleteSchemaReply struct {
+}
+
+func (m *DeleteSchemaReply) Reset()                    { *m = DeleteSchemaReply{} }
+func (m *DeleteSchemaReply) String() string            { return proto.CompactTextString(m) }
+func (*DeleteSchemaReply) ProtoMessage()               {}
+func (*DeleteSchemaReply) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
 
 func init() {
 	proto.RegisterType((*AuthRequest)(nil), "api.AuthRequest")
@@ -91,7 +542,17 @@ func init() {
 	proto.RegisterType((*RegisterRequest)(nil), "api.RegisterRequest")
 	proto.RegisterType((*RegisterReply)(nil), "api.RegisterReply")
 	proto.RegisterType((*EventsRequest)(nil), "api.EventsRequest")
+	proto.RegisterType((*EventAttribute)(nil), "api.EventAttribute")
 	proto.RegisterType((*Event)(nil), "api.Event")
+	proto.RegisterType((*RegisterSchemaRequest)(nil), "api.RegisterSchemaRequest")
+	proto.RegisterType((*SchemaInfo)(nil), "api.SchemaInfo")
+	proto.RegisterType((*ListSchemasRequest)(nil), "api.ListSchemasRequest")
+	proto.RegisterType((*ListSchemasReply)(nil), "api.ListSchemasReply")
+	proto.RegisterType((*GetSchemaRequest)(nil), "api.GetSchemaRequest")
+	proto.RegisterType((*DeleteSchemaRequest)(nil), "api.DeleteSchemaRequest")
+	proto.RegisterType((*DeleteSchemaReply)(nil), "api.DeleteSchemaReply")
+	proto.RegisterEnum("api.EventKind", EventKind_name, EventKind_value)
+	proto.RegisterEnum("api.Compatibility", Compatibility_name, Compatibility_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -108,6 +569,10 @@ type APIServiceClient interface {
 	Auth(ctx context.Context, in *AuthRequest, opts ...grpc.CallOption) (*AuthReply, error)
 	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterReply, error)
 	Events(ctx context.Context, in *EventsRequest, opts ...grpc.CallOption) (APIService_EventsClient, error)
+	RegisterSchema(ctx context.Context, in *RegisterSchemaRequest, opts ...grpc.CallOption) (*SchemaInfo, error)
+	ListSchemas(ctx context.Context, in *ListSchemasRequest, opts ...grpc.CallOption) (*ListSchemasReply, error)
+	GetSchema(ctx context.Context, in *GetSchemaRequest, opts ...grpc.CallOption) (*SchemaInfo, error)
+	DeleteSchema(ctx context.Context, in *DeleteSchemaRequest, opts ...grpc.CallOption) (*DeleteSchemaReply, error)
 }
 
 type aPIServiceClient struct {
@@ -168,12 +633,52 @@ func (x *aPIServiceEventsClient) Recv() (*Event, error) {
 	return m, nil
 }
 
+func (c *aPIServiceClient) RegisterSchema(ctx context.Context, in *RegisterSchemaRequest, opts ...grpc.CallOption) (*SchemaInfo, error) {
+	out := new(SchemaInfo)
+	err := grpc.Invoke(ctx, "/api.APIService/RegisterSchema", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIServiceClient) ListSchemas(ctx context.Context, in *ListSchemasRequest, opts ...grpc.CallOption) (*ListSchemasReply, error) {
+	out := new(ListSchemasReply)
+	err := grpc.Invoke(ctx, "/api.APIService/ListSchemas", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIServiceClient) GetSchema(ctx context.Context, in *GetSchemaRequest, opts ...grpc.CallOption) (*SchemaInfo, error) {
+	out := new(SchemaInfo)
+	err := grpc.Invoke(ctx, "/api.APIService/GetSchema", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIServiceClient) DeleteSchema(ctx context.Context, in *DeleteSchemaRequest, opts ...grpc.CallOption) (*DeleteSchemaReply, error) {
+	out := new(DeleteSchemaReply)
+	err := grpc.Invoke(ctx, "/api.APIService/DeleteSchema", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for APIService service
 
 type APIServiceServer interface {
 	Auth(context.Context, *AuthRequest) (*AuthReply, error)
 	Register(context.Context, *RegisterRequest) (*RegisterReply, error)
 	Events(*EventsRequest, APIService_EventsServer) error
+	RegisterSchema(context.Context, *RegisterSchemaRequest) (*SchemaInfo, error)
+	ListSchemas(context.Context, *ListSchemasRequest) (*ListSchemasReply, error)
+	GetSchema(context.Context, *GetSchemaRequest) (*SchemaInfo, error)
+	DeleteSchema(context.Context, *DeleteSchemaRequest) (*DeleteSchemaReply, error)
 }
 
 func RegisterAPIServiceServer(s *grpc.Server, srv APIServiceServer) {
@@ -237,6 +742,78 @@ func (x *aPIServiceEventsServer) Send(m *Event) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _APIService_RegisterSchema_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterSchemaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServiceServer).RegisterSchema(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.APIService/RegisterSchema",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServiceServer).RegisterSchema(ctx, req.(*RegisterSchemaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _APIService_ListSchemas_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSchemasRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServiceServer).ListSchemas(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.APIService/ListSchemas",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServiceServer).ListSchemas(ctx, req.(*ListSchemasRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _APIService_GetSchema_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSchemaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServiceServer).GetSchema(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.APIService/GetSchema",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServiceServer).GetSchema(ctx, req.(*GetSchemaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _APIService_DeleteSchema_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSchemaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServiceServer).DeleteSchema(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.APIService/DeleteSchema",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServiceServer).DeleteSchema(ctx, req.(*DeleteSchemaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _APIService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "api.APIService",
 	HandlerType: (*APIServiceServer)(nil),
@@ -249,6 +826,22 @@ var _APIService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Register",
 			Handler:    _APIService_Register_Handler,
 		},
+		{
+			MethodName: "RegisterSchema",
+			Handler:    _APIService_RegisterSchema_Handler,
+		},
+		{
+			MethodName: "ListSchemas",
+			Handler:    _APIService_ListSchemas_Handler,
+		},
+		{
+			MethodName: "GetSchema",
+			Handler:    _APIService_GetSchema_Handler,
+		},
+		{
+			MethodName: "DeleteSchema",
+			Handler:    _APIService_DeleteSchema_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -263,19 +856,67 @@ var _APIService_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("proto/api.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 219 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0x90, 0xb1, 0x4e, 0x85, 0x30,
-	0x14, 0x86, 0x73, 0xa3, 0x5e, 0xf5, 0x10, 0xac, 0x36, 0x4e, 0x8c, 0x4c, 0xc6, 0x04, 0x6a, 0xd4,
-	0x17, 0xc0, 0xc4, 0xc1, 0xcd, 0xe0, 0xe6, 0x56, 0xf0, 0x04, 0x9a, 0x00, 0xad, 0x6d, 0x69, 0xc2,
-	0x9b, 0xf8, 0xb8, 0xa6, 0x25, 0x08, 0xb2, 0x9d, 0x7e, 0xff, 0x69, 0xff, 0x2f, 0x05, 0xa2, 0xb4,
-	0xb4, 0x92, 0x71, 0x25, 0xf2, 0x30, 0xd1, 0x13, 0xae, 0x44, 0x1a, 0x43, 0x54, 0x8c, 0xb6, 0x2d,
-	0xf1, 0x7b, 0x44, 0x63, 0xd3, 0x08, 0x2e, 0xe7, 0xa3, 0xea, 0xa6, 0xf4, 0x06, 0x48, 0x89, 0x8d,
-	0x30, 0x16, 0xf5, 0x92, 0x13, 0x88, 0x57, 0xe4, 0x77, 0x08, 0xc4, 0xaf, 0x0e, 0x07, 0x6b, 0x96,
-	0x8d, 0x73, 0x38, 0x0b, 0xe0, 0xf1, 0xe7, 0x00, 0x50, 0xbc, 0xbf, 0x7d, 0xa0, 0x76, 0xa2, 0x46,
-	0x7a, 0x07, 0xa7, 0xfe, 0x65, 0x7a, 0x9d, 0x7b, 0x83, 0x4d, 0x67, 0x72, 0xb5, 0x21, 0xaa, 0x9b,
-	0xe8, 0x33, 0x5c, 0x2c, 0x1d, 0xf4, 0x36, 0x64, 0x3b, 0x8b, 0x84, 0xee, 0xa8, 0xbf, 0x75, 0x0f,
-	0xc7, 0x59, 0x84, 0xce, 0xe9, 0x3f, 0xab, 0x04, 0x56, 0xf6, 0x70, 0x78, 0x61, 0x9f, 0x59, 0x23,
-	0x6c, 0x3b, 0x56, 0x79, 0x2d, 0x7b, 0xd6, 0x4b, 0x87, 0x3d, 0x7e, 0x89, 0x9a, 0x77, 0xcc, 0xa0,
-	0x76, 0xa8, 0x33, 0xde, 0xe0, 0x60, 0xd9, 0xdf, 0x87, 0x55, 0xc7, 0x30, 0x3e, 0xfd, 0x06, 0x00,
-	0x00, 0xff, 0xff, 0x16, 0xfe, 0x87, 0xb3, 0x44, 0x01, 0x00, 0x00,
+	// 978 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x56, 0xdd, 0x4e, 0xeb, 0xc6,
+	0x13, 0x3f, 0xc1, 0xce, 0xd7, 0x84, 0x24, 0xcb, 0x02, 0xff, 0xbf, 0x15, 0xf5, 0x02, 0x59, 0xa7,
+	0x12, 0x45, 0x6a, 0xa8, 0xa0, 0x17, 0x47, 0xaa, 0x90, 0x6a, 0xe2, 0x85, 0x93, 0x12, 0x1c, 0xb4,
+	0x09, 0xa0, 0xf6, 0x26, 0x32, 0xc9, 0x9e, 0x64, 0x8b, 0x13, 0x1b, 0xef, 0x86, 0x36, 0xbd, 0xe9,
+	0x13, 0x54, 0x55, 0x5f, 0xa6, 0x8f, 0xd5, 0x67, 0xa8, 0x76, 0x1d, 0xe7, 0x83, 0xd0, 0xf6, 0x48,
+	0xe7, 0x6e, 0x7e, 0x33, 0xb3, 0x33, 0xbf, 0x99, 0x9d, 0x59, 0x1b, 0xaa, 0x51, 0x1c, 0xca, 0xf0,
+	0xd8, 0x8f, 0x78, 0x5d, 0x4b, 0xd8, 0xf0, 0x23, 0x6e, 0x13, 0x28, 0x39, 0x53, 0x39, 0xa2, 0xec,
+	0x69, 0xca, 0x84, 0xc4, 0x35, 0x28, 0x4c, 0x05, 0x8b, 0x27, 0xfe, 0x98, 0x59, 0x99, 0x83, 0xcc,
+	0x61, 0x91, 0x2e, 0xb0, 0xb2, 0x45, 0xbe, 0x10, 0x3f, 0x85, 0xf1, 0xc0, 0xda, 0x4a, 0x6c, 0x29,
+	0xb6, 0xbf, 0x81, 0x62, 0x12, 0x26, 0x0a, 0x66, 0x78, 0x0f, 0xb2, 0x32, 0x7c, 0x64, 0x93, 0x79,
+	0x84, 0x04, 0x60, 0x0b, 0xf2, 0xec, 0xe7, 0x88, 0xc7, 0x4c, 0xe8, 0xd3, 0x06, 0x4d, 0xa1, 0xdd,
+	0x83, 0x2a, 0x65, 0x43, 0x2e, 0x24, 0x8b, 0x3f, 0x91, 0x87, 0x4a, 0x1d, 0x4f, 0x03, 0x26, 0x2c,
+	0xe3, 0xc0, 0x50, 0xa9, 0x35, 0xb0, 0xab, 0x50, 0x5e, 0x26, 0x88, 0x82, 0x99, 0xfd, 0x2b, 0x94,
+	0xc9, 0x33, 0x9b, 0x48, 0x91, 0xe6, 0x7b, 0x0b, 0xd9, 0x47, 0x3e, 0x19, 0x08, 0x2b, 0x73, 0x60,
+	0x1c, 0x56, 0x4e, 0x2a, 0x75, 0xd5, 0x26, 0xed, 0x72, 0xc5, 0x27, 0x03, 0x9a, 0x18, 0x55, 0x09,
+	0x62, 0xfa, 0xf0, 0x23, 0xeb, 0xcb, 0x79, 0xe2, 0x14, 0x2a, 0xcb, 0x87, 0x38, 0x1c, 0x77, 0xd8,
+	0x93, 0x65, 0x1c, 0x64, 0x0e, 0x4d, 0x9a, 0x42, 0xc5, 0x88, 0x45, 0x61, 0x7f, 0x64, 0x99, 0x5a,
+	0x9f, 0x00, 0xfb, 0x1d, 0x54, 0x74, 0x74, 0x47, 0xca, 0x98, 0x3f, 0x4c, 0x25, 0xc3, 0x08, 0x8c,
+	0x47, 0x36, 0x9b, 0x17, 0xab, 0x44, 0x75, 0xf2, 0xd9, 0x0f, 0xa6, 0x6c, 0x9e, 0x2b, 0x01, 0xf6,
+	0x9f, 0x19, 0xc8, 0xea, 0xa3, 0xea, 0x84, 0x60, 0x4f, 0xfa, 0x84, 0x49, 0x95, 0x88, 0x31, 0x98,
+	0x92, 0x8f, 0xd9, 0xbc, 0xbf, 0x5a, 0xc6, 0x36, 0x98, 0x8a, 0xbc, 0xa6, 0xb5, 0x59, 0x98, 0xb6,
+	0xad, 0xd6, 0x65, 0xae, 0xd7, 0x75, 0x0a, 0xe0, 0xa7, 0x14, 0x85, 0x95, 0x3d, 0x30, 0x0e, 0x4b,
+	0x27, 0xbb, 0xcb, 0x18, 0x0b, 0xfa, 0x74, 0xc5, 0x6d, 0x59, 0x72, 0x6e, 0xb5, 0xe4, 0x3f, 0x32,
+	0xb0, 0x9f, 0xde, 0x42, 0xa7, 0x3f, 0x62, 0x63, 0x3f, 0x6d, 0xbe, 0x05, 0xf9, 0xc8, 0xef, 0x3f,
+	0xfa, 0xc3, 0xf4, 0xae, 0x53, 0x88, 0xdf, 0x41, 0xb9, 0x1f, 0x8e, 0x23, 0x5f, 0xf2, 0x07, 0x1e,
+	0x70, 0x39, 0xd3, 0x95, 0x55, 0x4e, 0xb0, 0x66, 0xd0, 0x58, 0xb5, 0xd0, 0x75, 0x47, 0xfc, 0x16,
+	0xca, 0x03, 0x26, 0xfa, 0x31, 0x8f, 0x64, 0x18, 0x77, 0x98, 0xd4, 0xf5, 0x6f, 0xd3, 0x75, 0xa5,
+	0xfd, 0x57, 0x06, 0x20, 0xe1, 0xd2, 0x9c, 0x7c, 0x08, 0xff, 0x85, 0x88, 0x05, 0xf9, 0x67, 0x16,
+	0x0b, 0x1e, 0x4e, 0x34, 0x85, 0x2c, 0x4d, 0xe1, 0x26, 0x45, 0xe3, 0x63, 0x29, 0x62, 0x30, 0x47,
+	0xbe, 0x18, 0xcd, 0x5b, 0xae, 0x65, 0xfc, 0x19, 0x14, 0xd5, 0xad, 0x09, 0xe9, 0x8f, 0x23, 0x2b,
+	0xab, 0xaf, 0x71, 0xa9, 0xd8, 0x2c, 0x2a, 0xf7, 0x4a, 0x51, 0x6a, 0x3f, 0xc6, 0x4c, 0x08, 0x7f,
+	0xc8, 0x84, 0x95, 0xd7, 0x6b, 0xb0, 0xc0, 0x76, 0x1d, 0x70, 0x8b, 0x0b, 0x99, 0xd4, 0x2c, 0xfe,
+	0xf3, 0x02, 0xec, 0x33, 0x40, 0x6b, 0xfe, 0x6a, 0xbd, 0xbf, 0x80, 0xbc, 0x48, 0xb0, 0xde, 0x96,
+	0xd2, 0x49, 0x55, 0xd7, 0xba, 0xec, 0x23, 0x4d, 0xed, 0xf6, 0x05, 0xa0, 0x4b, 0x26, 0x3f, 0xf6,
+	0xb6, 0xff, 0xb1, 0xc9, 0x76, 0x13, 0x76, 0x5d, 0x16, 0x30, 0xc9, 0x3e, 0x3d, 0xd4, 0x2e, 0xec,
+	0xac, 0x87, 0x8a, 0x82, 0xd9, 0xd1, 0xef, 0x5b, 0x50, 0x5c, 0x2c, 0x05, 0xde, 0x81, 0x32, 0xb9,
+	0x23, 0x5e, 0xb7, 0x77, 0xeb, 0x5d, 0x79, 0xed, 0x7b, 0x0f, 0xbd, 0xc1, 0x18, 0x2a, 0x2d, 0xe2,
+	0xb8, 0x84, 0xf6, 0x1a, 0xef, 0x1d, 0xef, 0x92, 0xb8, 0x28, 0x83, 0xab, 0x50, 0xf2, 0xda, 0x2e,
+	0xe9, 0x7d, 0xd7, 0x6e, 0x7a, 0xc4, 0x45, 0x5b, 0xb8, 0x0c, 0x45, 0xad, 0x68, 0x91, 0x8b, 0x2e,
+	0x32, 0x94, 0xdd, 0xa5, 0xcd, 0x3b, 0xd2, 0x73, 0x5c, 0x97, 0xb8, 0xc8, 0x54, 0x71, 0x13, 0x05,
+	0x25, 0xd7, 0xed, 0x3b, 0xe2, 0xa2, 0xac, 0x52, 0x75, 0xdb, 0x37, 0xcd, 0x46, 0xaf, 0x41, 0x89,
+	0xd3, 0x25, 0x2e, 0xca, 0xe3, 0x7d, 0xd8, 0xe9, 0x34, 0xde, 0x93, 0x6b, 0xa7, 0x47, 0xc9, 0x65,
+	0xb3, 0xd3, 0x25, 0x94, 0xb8, 0xa8, 0xa0, 0x18, 0xcc, 0xd5, 0x2e, 0x69, 0x11, 0xe5, 0x5a, 0xc4,
+	0x15, 0x80, 0x24, 0xe0, 0xc5, 0x6d, 0xab, 0x85, 0x4a, 0x78, 0x0f, 0x50, 0x87, 0x5c, 0x5e, 0x2b,
+	0xea, 0xd7, 0xcd, 0x4b, 0xaa, 0x03, 0x6e, 0xe3, 0x5d, 0xa8, 0x76, 0xba, 0x6d, 0xaa, 0xd2, 0x3a,
+	0x6e, 0xaf, 0xed, 0xb5, 0xbe, 0x47, 0x65, 0x1d, 0x4e, 0x2b, 0xef, 0x69, 0xb3, 0xeb, 0x9c, 0xb7,
+	0x08, 0xaa, 0xd8, 0x66, 0x21, 0x87, 0x72, 0xb6, 0x59, 0x00, 0x04, 0x47, 0x57, 0x50, 0x5e, 0x1b,
+	0x5e, 0x5c, 0x82, 0xbc, 0x4b, 0x2e, 0x9c, 0xdb, 0x56, 0x17, 0xbd, 0xc1, 0xdb, 0x50, 0x38, 0x77,
+	0x1a, 0x57, 0xf7, 0x0e, 0x55, 0x8d, 0x28, 0x41, 0xfe, 0xa2, 0x4d, 0x35, 0xd8, 0xc2, 0x05, 0x30,
+	0x35, 0x1b, 0x43, 0x49, 0x5e, 0xdb, 0x23, 0xc8, 0x3c, 0xf9, 0xcd, 0x00, 0x70, 0x6e, 0x9a, 0x1d,
+	0x16, 0x3f, 0xf3, 0x3e, 0xc3, 0x87, 0x60, 0xaa, 0x8f, 0x05, 0x46, 0x7a, 0x6e, 0x56, 0x3e, 0x3f,
+	0xb5, 0xca, 0x8a, 0x46, 0x8d, 0xda, 0xd7, 0x50, 0x48, 0x9f, 0x0c, 0xbc, 0xa7, 0x6d, 0x2f, 0x3e,
+	0x14, 0x35, 0xfc, 0x42, 0xab, 0x4e, 0x1d, 0x41, 0x2e, 0x79, 0xdd, 0x31, 0x5e, 0x3e, 0x55, 0xe9,
+	0xb0, 0xd7, 0x60, 0xa9, 0xfb, 0x2a, 0x83, 0xcf, 0xa0, 0xb2, 0xfe, 0x28, 0xe1, 0xda, 0x5a, 0xc4,
+	0xb5, 0x81, 0xab, 0xbd, 0x9c, 0x74, 0x7c, 0x06, 0xa5, 0x95, 0xfd, 0xc0, 0xff, 0xd7, 0xf6, 0xcd,
+	0x0d, 0xab, 0xed, 0x6f, 0x1a, 0x14, 0xd3, 0x53, 0x28, 0x2e, 0xf6, 0x03, 0x27, 0x3e, 0x2f, 0xf7,
+	0x65, 0x33, 0xe7, 0xb7, 0xb0, 0xbd, 0x3a, 0xc1, 0xd8, 0xd2, 0x0e, 0xaf, 0xec, 0x47, 0xed, 0x7f,
+	0xaf, 0x58, 0xa2, 0x60, 0x76, 0x4e, 0xa0, 0xc6, 0xc3, 0xfa, 0x90, 0x4d, 0x7e, 0xf1, 0x79, 0x5d,
+	0x04, 0xbc, 0xcf, 0x06, 0xf5, 0x71, 0x38, 0x60, 0x81, 0xf2, 0xbf, 0xc9, 0xfc, 0xf0, 0xf9, 0x90,
+	0xcb, 0xd1, 0xf4, 0xa1, 0xde, 0x0f, 0xc7, 0xc7, 0x89, 0xd7, 0x97, 0x3c, 0x3c, 0x4e, 0x1c, 0x8f,
+	0x17, 0xbf, 0x11, 0x0f, 0x39, 0x2d, 0x9e, 0xfe, 0x3d, 0x00, 0x41, 0xe0, 0xe6, 0x02, 0x5a, 0x08,
+	0x00, 0x00,
 }
@@ -292,6 +292,34 @@ func (x Topic_Mode) String() string {
 }
 func (Topic_Mode) EnumDescriptor() ([]byte, []int) { return fileDescriptorStore, []int{19, 1} }
 
+// How appended records are validated against the topic schema
+type Topic_Validation int32
+
+const (
+	// Records are not validated
+	Topic_OFF Topic_Validation = 0
+	// Invalid records are logged but still appended
+	Topic_WARN Topic_Validation = 1
+	// Invalid records are rejected
+	Topic_REJECT Topic_Validation = 2
+)
+
+var Topic_Validation_name = map[int32]string{
+	0: "OFF",
+	1: "WARN",
+	2: "REJECT",
+}
+var Topic_Validation_value = map[string]int32{
+	"OFF":    0,
+	"WARN":   1,
+	"REJECT": 2,
+}
+
+func (x Topic_Validation) String() string {
+	return proto.EnumName(Topic_Validation_name, int32(x))
+}
+func (Topic_Validation) EnumDescriptor() ([]byte, []int) { return fileDescriptorStore, []int{19, 2} }
+
 type Path_Type int32
 
 const (
@@ -1298,6 +1326,13 @@ type Topic struct {
 	SliceKey *Projection `protobuf:"bytes,11,opt,name=sliceKey" json:"sliceKey,omitempty"`
 	// Recommended Drive type
 	Drive Drive_Kind `protobuf:"varint,12,opt,name=drive,proto3,enum=store_pb.Drive_Kind" json:"drive,omitempty"`
+	// Serialized FileDescriptorSet of the schema
+	Descriptor_ []byte           `protobuf:"bytes,16,opt,name=descriptor,proto3" json:"descriptor,omitempty"`
+	Validation  Topic_Validation `protobuf:"varint,17,opt,name=validation,proto3,enum=store_pb.Topic_Validation" json:"validation,omitempty"`
+	// How appends are synced to disk. BUSINESS topics are synced every
+	// syncInterval milliseconds or segment.sync-interval when it's zero.
+	Level        Level  `protobuf:"varint,18,opt,name=level,proto3,enum=store_pb.Level" json:"level,omitempty"`
+	SyncInterval uint32 `protobuf:"varint,19,opt,name=syncInterval,proto3" json:"syncInterval,omitempty"`
 }
 
 func (m *Topic) Reset()                    { *m = Topic{} }
@@ -1396,6 +1431,34 @@ func (m *Topic) GetDrive() Drive_Kind {
 	return Drive_HDD
 }
 
+func (m *Topic) GetDescriptor_() []byte {
+	if m != nil {
+		return m.Descriptor_
+	}
+	return nil
+}
+
+func (m *Topic) GetValidation() Topic_Validation {
+	if m != nil {
+		return m.Validation
+	}
+	return Topic_OFF
+}
+
+func (m *Topic) GetLevel() Level {
+	if m != nil {
+		return m.Level
+	}
+	return Level_MISSION
+}
+
+func (m *Topic) GetSyncInterval() uint32 {
+	if m != nil {
+		return m.SyncInterval
+	}
+	return 0
+}
+
 // Definition of a roller which decides when to create new segment files.
 type Roller struct {
 	Id       uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -2295,6 +2358,7 @@ func init() {
 	proto.RegisterEnum("store_pb.Index_Type", Index_Type_name, Index_Type_value)
 	proto.RegisterEnum("store_pb.Topic_Type", Topic_Type_name, Topic_Type_value)
 	proto.RegisterEnum("store_pb.Topic_Mode", Topic_Mode_name, Topic_Mode_value)
+	proto.RegisterEnum("store_pb.Topic_Validation", Topic_Validation_name, Topic_Validation_value)
 	proto.RegisterEnum("store_pb.Path_Type", Path_Type_name, Path_Type_value)
 	proto.RegisterEnum("store_pb.Hash_Algorithm", Hash_Algorithm_name, Hash_Algorithm_value)
 }
@@ -3449,6 +3513,35 @@ func (m *Topic) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintStore(dAtA, i, uint64(len(m.Schema)))
 		i += copy(dAtA[i:], m.Schema)
 	}
+	if len(m.Descriptor_) > 0 {
+		dAtA[i] = 0x82
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintStore(dAtA, i, uint64(len(m.Descriptor_)))
+		i += copy(dAtA[i:], m.Descriptor_)
+	}
+	if m.Validation != 0 {
+		dAtA[i] = 0x88
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintStore(dAtA, i, uint64(m.Validation))
+	}
+	if m.Level != 0 {
+		dAtA[i] = 0x90
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintStore(dAtA, i, uint64(m.Level))
+	}
+	if m.SyncInterval != 0 {
+		dAtA[i] = 0x98
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintStore(dAtA, i, uint64(m.SyncInterval))
+	}
 	return i, nil
 }
 
@@ -4893,6 +4986,19 @@ func (m *Topic) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovStore(uint64(l))
 	}
+	l = len(m.Descriptor_)
+	if l > 0 {
+		n += 2 + l + sovStore(uint64(l))
+	}
+	if m.Validation != 0 {
+		n += 2 + sovStore(uint64(m.Validation))
+	}
+	if m.Level != 0 {
+		n += 2 + sovStore(uint64(m.Level))
+	}
+	if m.SyncInterval != 0 {
+		n += 2 + sovStore(uint64(m.SyncInterval))
+	}
 	return n
 }
 
@@ -8963,6 +9069,94 @@ func (m *Topic) Unmarshal(dAtA []byte) error {
 			}
 			m.Schema = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 16:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Descriptor_", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStore
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStore
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Descriptor_ = append(m.Descriptor_[:0], dAtA[iNdEx:postIndex]...)
+			if m.Descriptor_ == nil {
+				m.Descriptor_ = []byte{}
+			}
+			iNdEx = postIndex
+		case 17:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Validation", wireType)
+			}
+			m.Validation = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStore
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Validation |= (Topic_Validation(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 18:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Level", wireType)
+			}
+			m.Level = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStore
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Level |= (Level(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 19:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SyncInterval", wireType)
+			}
+			m.SyncInterval = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStore
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SyncInterval |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipStore(dAtA[iNdEx:])